@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/ghodss/yaml"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyCmd = &cobra.Command{
+		Use:   "apply",
+		Short: "Apply a cluster spec file to create or scale k3s cluster",
+		Example: `  autok3s apply -f cluster.yaml
+
+  # cluster.yaml
+  apiVersion: autok3s.cattle.io/v1alpha1
+  kind: Cluster
+  metadata:
+    name: myk3s
+    provider: aws
+    master: "1"
+    worker: "1"
+  ssh:
+    user: ubuntu
+    ssh-key-path: ~/.ssh/id_rsa
+  options:
+    region: us-east-1
+    instance-type: t2.micro`,
+	}
+
+	aFile = ""
+)
+
+func init() {
+	applyCmd.Flags().StringVarP(&aFile, "file", "f", aFile, "The path of cluster spec file, both yaml and json format are supported")
+}
+
+func ApplyCommand() *cobra.Command {
+	applyCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if aFile == "" {
+			logrus.Fatalln("required flags(s) \"--file\" not set")
+		}
+		return nil
+	}
+
+	applyCmd.Run = func(cmd *cobra.Command, args []string) {
+		spec, err := readClusterSpec(aFile)
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+		if err := applyClusterSpec(spec); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return applyCmd
+}
+
+func readClusterSpec(file string) (*types.ClusterSpec, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read cluster spec file %s error, msg: %v", file, err)
+	}
+	spec := &types.ClusterSpec{}
+	// yaml is a superset of json, so both formats can be unmarshalled here.
+	if err := yaml.Unmarshal(b, spec); err != nil {
+		return nil, fmt.Errorf("unmarshal cluster spec file %s error, msg: %v", file, err)
+	}
+	if spec.APIVersion != types.ClusterSpecAPIVersion {
+		return nil, fmt.Errorf("unsupported apiVersion %q of cluster spec, must be %q", spec.APIVersion, types.ClusterSpecAPIVersion)
+	}
+	if spec.Kind != types.ClusterSpecKind {
+		return nil, fmt.Errorf("unsupported kind %q of cluster spec, must be %q", spec.Kind, types.ClusterSpecKind)
+	}
+	if spec.Metadata.Provider == "" {
+		return nil, fmt.Errorf("required field \"metadata.provider\" of cluster spec not set")
+	}
+	if spec.Metadata.Name == "" {
+		return nil, fmt.Errorf("required field \"metadata.name\" of cluster spec not set")
+	}
+	return spec, nil
+}
+
+func applyClusterSpec(spec *types.ClusterSpec) error {
+	p, err := providers.GetProvider(spec.Metadata.Provider)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(types.Cluster{
+		Metadata: spec.Metadata,
+		Options:  spec.Options,
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.SetConfig(b); err != nil {
+		return err
+	}
+
+	ssh := p.GetSSHConfig()
+	utils.MergeConfig(reflect.ValueOf(ssh).Elem(), reflect.ValueOf(&spec.SSH).Elem())
+
+	// merge options from state before generating cluster name, the same as join command.
+	if err := p.MergeClusterOptions(); err != nil {
+		return err
+	}
+
+	// generate cluster name. e.g. input: "name: k3s1, region: cn-hangzhou" output: "k3s1.cn-hangzhou.<provider>"
+	p.GenerateClusterName()
+
+	exist, _, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		if err := p.CreateCheck(ssh); err != nil {
+			return err
+		}
+		if err := p.CreateK3sCluster(context.Background(), ssh); err != nil {
+			return rollback(p, err)
+		}
+		return nil
+	}
+
+	info := p.GetCluster(filepath.Join(common.CfgPath, common.KubeCfgFile))
+	masterDelta, err := countDelta("master", spec.Metadata.Master, info.Master)
+	if err != nil {
+		return err
+	}
+	workerDelta, err := countDelta("worker", spec.Metadata.Worker, info.Worker)
+	if err != nil {
+		return err
+	}
+
+	if masterDelta < 0 || workerDelta < 0 {
+		fmt.Printf("cluster %s has more nodes than desired, these nodes will not be removed automatically:\n", info.Name)
+		if masterDelta < 0 {
+			fmt.Printf("  master: current %s, desired %s (%d)\n", info.Master, spec.Metadata.Master, masterDelta)
+		}
+		if workerDelta < 0 {
+			fmt.Printf("  worker: current %s, desired %s (%d)\n", info.Worker, spec.Metadata.Worker, workerDelta)
+		}
+	}
+
+	if masterDelta <= 0 && workerDelta <= 0 {
+		if masterDelta == 0 && workerDelta == 0 {
+			fmt.Printf("cluster %s is up to date\n", info.Name)
+		}
+		return nil
+	}
+
+	// only join the number of nodes which are missing from the cluster.
+	b, err = json.Marshal(types.Cluster{
+		Metadata: types.Metadata{
+			Master: strconv.Itoa(positive(masterDelta)),
+			Worker: strconv.Itoa(positive(workerDelta)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.SetConfig(b); err != nil {
+		return err
+	}
+
+	if err := p.JoinK3sNode(context.Background(), ssh); err != nil {
+		return rollback(p, err)
+	}
+	return nil
+}
+
+// rollback rolls back the nodes created by the failed operation and returns its error,
+// which is wrapped with the error of rolling back if any.
+func rollback(p providers.Provider, err error) error {
+	if rErr := p.Rollback(); rErr != nil {
+		return fmt.Errorf("%v, and failed to roll back: %v", err, rErr)
+	}
+	return err
+}
+
+func countDelta(role, desired, current string) (int, error) {
+	// an empty desired number means it's not managed by the spec.
+	if desired == "" {
+		return 0, nil
+	}
+	d, err := strconv.Atoi(desired)
+	if err != nil {
+		return 0, fmt.Errorf("field \"metadata.%s\" of cluster spec must be number, got %q", role, desired)
+	}
+	c, err := strconv.Atoi(current)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current %s number of cluster, got %q", role, current)
+	}
+	return d - c, nil
+}
+
+func positive(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the health of k3s cluster nodes and repair them",
+	}
+	chProvider = ""
+	chRepair   = false
+	chp        providers.Provider
+)
+
+func init() {
+	checkCmd.Flags().StringVarP(&chProvider, "provider", "p", chProvider, "Provider is a module which provides an interface for managing cloud resources")
+	checkCmd.Flags().BoolVar(&chRepair, "repair", chRepair, "Start stopped instances, restart k3s services and re-join nodes which are unhealthy")
+}
+
+func CheckCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			chp = reg
+		}
+
+		checkCmd.Flags().AddFlagSet(utils.ConvertFlags(checkCmd, chp.GetCredentialFlags()))
+		checkCmd.Flags().AddFlagSet(chp.GetDeleteFlags(checkCmd))
+		checkCmd.Example = chp.GetUsageExample("check")
+	}
+
+	checkCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if chProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		common.InitPFlags(cmd, chp)
+		err := chp.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), chp)
+	}
+
+	checkCmd.Run = func(cmd *cobra.Command, args []string) {
+		chp.GenerateClusterName()
+
+		reports, err := chp.CheckK3sCluster(chRepair)
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+		printCheckReports(reports)
+	}
+
+	return checkCmd
+}
+
+func printCheckReports(reports []types.NodeCheck) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Instance", "Role", "Instance Status", "Node Status", "Service", "Token", "Problems"})
+	for _, r := range reports {
+		role := "worker"
+		if r.Master {
+			role = "master"
+		}
+		token := "matched"
+		if !r.TokenMatched {
+			token = "mismatched"
+		}
+		problems := "-"
+		if len(r.Problems) > 0 {
+			problems = strings.Join(r.Problems, "; ")
+		}
+		table.Append([]string{r.InstanceID, role, r.InstanceStatus, r.NodeStatus, r.ServiceStatus, token, problems})
+	}
+	table.Render()
+}
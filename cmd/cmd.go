@@ -10,6 +10,12 @@ import (
 	// import custom provider
 	_ "github.com/cnrancher/autok3s/pkg/providers/alibaba"
 	_ "github.com/cnrancher/autok3s/pkg/providers/aws"
+	_ "github.com/cnrancher/autok3s/pkg/providers/azure"
+	_ "github.com/cnrancher/autok3s/pkg/providers/digitalocean"
+	_ "github.com/cnrancher/autok3s/pkg/providers/docker"
+	_ "github.com/cnrancher/autok3s/pkg/providers/google"
+	_ "github.com/cnrancher/autok3s/pkg/providers/hetzner"
+	_ "github.com/cnrancher/autok3s/pkg/providers/libvirt"
 	_ "github.com/cnrancher/autok3s/pkg/providers/native"
 	_ "github.com/cnrancher/autok3s/pkg/providers/tencent"
 
@@ -45,6 +51,10 @@ func init() {
 	cmd.PersistentFlags().BoolVarP(&common.Debug, "debug", "d", common.Debug, "Enable log debug level")
 	cmd.Flags().StringVarP(&common.CfgPath, "cfg", "c", common.CfgPath, "Path to the cfg file to use for CLI requests")
 	cmd.Flags().IntVarP(&common.Backoff.Steps, "retry", "r", common.Backoff.Steps, "The number of retries waiting for the desired state")
+	cmd.Flags().StringVar(&common.StateBackend, "state-backend", common.StateBackend, "The backend to store cluster states, supports file and bolt")
+	cmd.Flags().IntVar(&common.Concurrency, "concurrency", common.Concurrency, "The max number of nodes to install k3s concurrently")
+	cmd.Flags().StringVar(&common.MasterKeyFile, "master-key-file", common.MasterKeyFile, "Path to the master key file to encrypt secrets, defaults to .master.key under cfg path, env AUTOK3S_MASTER_KEY takes precedence")
+	cmd.Flags().BoolVar(&common.MasterKeyring, "master-key-keyring", common.MasterKeyring, "Read and save the master key to encrypt secrets in the OS keyring instead of the key file")
 }
 
 func Command() *cobra.Command {
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/spf13/pflag"
+)
+
+// BastionUsage is the usage of bastion flag.
+const BastionUsage = "Bastion to connect hosts through, can be specified multiple times to chain bastions in order. " +
+	"e.g.(--bastion 'root@1.2.3.4:22,ssh-key-path=~/.ssh/bastion_rsa'), supports ssh-user, ssh-port, ssh-password, " +
+	"ssh-key-path, ssh-key-pass, ssh-key-cert-path and ssh-agent, credentials of hosts are used if not specified"
+
+type bastionsValue struct {
+	bastions *[]types.Bastion
+}
+
+// NewBastionsValue returns a flag value which appends bastion to bastions every time it is set.
+func NewBastionsValue(bastions *[]types.Bastion) pflag.Value {
+	return &bastionsValue{bastions: bastions}
+}
+
+func (v *bastionsValue) Set(s string) error {
+	b, err := parseBastion(s)
+	if err != nil {
+		return err
+	}
+	*v.bastions = append(*v.bastions, b)
+	return nil
+}
+
+func (v *bastionsValue) Type() string {
+	return "stringArray"
+}
+
+func (v *bastionsValue) String() string {
+	hosts := make([]string, 0, len(*v.bastions))
+	for _, b := range *v.bastions {
+		hosts = append(hosts, b.Host)
+	}
+	return "[" + strings.Join(hosts, ",") + "]"
+}
+
+// parseBastion parses bastion in format of `[user@]host[:port][,key=value...]`.
+func parseBastion(s string) (types.Bastion, error) {
+	b := types.Bastion{}
+	for i, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 1 {
+			if i != 0 {
+				return b, fmt.Errorf("invalid bastion option %s, must be key=value", item)
+			}
+			if at := strings.LastIndex(item, "@"); at >= 0 {
+				b.User = item[:at]
+				item = item[at+1:]
+			}
+			if host, port, err := net.SplitHostPort(item); err == nil {
+				b.Host, b.Port = host, port
+			} else {
+				b.Host = item
+			}
+			continue
+		}
+
+		switch kv[0] {
+		case "host":
+			b.Host = kv[1]
+		case "ssh-user":
+			b.User = kv[1]
+		case "ssh-port":
+			b.Port = kv[1]
+		case "ssh-password":
+			b.Password = kv[1]
+		case "ssh-key-path":
+			b.SSHKeyPath = kv[1]
+		case "ssh-key-pass":
+			b.SSHKeyPassphrase = kv[1]
+		case "ssh-key-cert-path":
+			b.SSHCertPath = kv[1]
+		case "ssh-agent":
+			agent, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return b, fmt.Errorf("invalid bastion option %s: %v", item, err)
+			}
+			b.SSHAgentAuth = agent
+		default:
+			return b, fmt.Errorf("unknown bastion option %s", kv[0])
+		}
+	}
+
+	if b.Host == "" {
+		return b, fmt.Errorf("host of bastion %s is not specified", s)
+	}
+	return b, nil
+}
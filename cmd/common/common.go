@@ -3,18 +3,34 @@ package common
 import (
 	"fmt"
 	"os"
+	"os/user"
 	"strings"
 
-	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/providers/alibaba"
 	"github.com/cnrancher/autok3s/pkg/providers/aws"
+	"github.com/cnrancher/autok3s/pkg/providers/azure"
+	"github.com/cnrancher/autok3s/pkg/providers/digitalocean"
+	"github.com/cnrancher/autok3s/pkg/providers/docker"
+	"github.com/cnrancher/autok3s/pkg/providers/google"
+	"github.com/cnrancher/autok3s/pkg/providers/hetzner"
+	"github.com/cnrancher/autok3s/pkg/providers/libvirt"
+	"github.com/cnrancher/autok3s/pkg/providers/native"
 	"github.com/cnrancher/autok3s/pkg/providers/tencent"
 	"github.com/cnrancher/autok3s/pkg/types"
 	typesAli "github.com/cnrancher/autok3s/pkg/types/alibaba"
 	typesaws "github.com/cnrancher/autok3s/pkg/types/aws"
+	typesAzure "github.com/cnrancher/autok3s/pkg/types/azure"
+	typesDigitalocean "github.com/cnrancher/autok3s/pkg/types/digitalocean"
+	typesDocker "github.com/cnrancher/autok3s/pkg/types/docker"
+	typesGoogle "github.com/cnrancher/autok3s/pkg/types/google"
+	typesHetzner "github.com/cnrancher/autok3s/pkg/types/hetzner"
+	typesLibvirt "github.com/cnrancher/autok3s/pkg/types/libvirt"
+	typesNative "github.com/cnrancher/autok3s/pkg/types/native"
 	typesTencent "github.com/cnrancher/autok3s/pkg/types/tencent"
 	"github.com/cnrancher/autok3s/pkg/utils"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
 
 	"github.com/ghodss/yaml"
 	"github.com/sirupsen/logrus"
@@ -31,7 +47,7 @@ func bindPFlags(cmd *cobra.Command, p providers.Provider) {
 
 	cmd.Flags().Visit(func(f *pflag.Flag) {
 		if IsCredentialFlag(f.Name, p.BindCredentialFlags()) {
-			if err := viper.BindPFlag(fmt.Sprintf(common.BindPrefix, name, f.Name), f); err != nil {
+			if err := viper.BindPFlag(autok3sviper.CredentialKey(name, p.GetCredentialProfile(), f.Name), f); err != nil {
 				logrus.Fatalln(err)
 			}
 		}
@@ -48,8 +64,8 @@ func InitPFlags(cmd *cobra.Command, p providers.Provider) {
 		logrus.Fatalln(err)
 	}
 
-	// sync config data to local cfg path.
-	if err := viper.WriteConfig(); err != nil {
+	// sync config data to local cfg path, credentials are encrypted.
+	if err := autok3sviper.WriteConfig(); err != nil {
 		logrus.Fatalln(err)
 	}
 }
@@ -103,16 +119,66 @@ func IsCredentialFlag(s string, nfs *pflag.FlagSet) bool {
 }
 
 func MakeSureCredentialFlag(flags *pflag.FlagSet, p providers.Provider) error {
+	profile := p.GetCredentialProfile()
+	if err := autok3sviper.ValidateProfileName(profile); err != nil {
+		return err
+	}
+
+	exist := false
 	flags.VisitAll(func(flag *pflag.Flag) {
+		if !IsCredentialFlag(flag.Name, p.BindCredentialFlags()) {
+			return
+		}
 		// if viper has set the value, make sure flag has the value set to pass require check
-		if IsCredentialFlag(flag.Name, p.BindCredentialFlags()) && viper.IsSet(fmt.Sprintf(common.BindPrefix, p.GetProviderName(), flag.Name)) {
-			flags.Set(flag.Name, viper.GetString(fmt.Sprintf(common.BindPrefix, p.GetProviderName(), flag.Name)))
+		if viper.IsSet(autok3sviper.CredentialKey(p.GetProviderName(), profile, flag.Name)) {
+			exist = true
+			if !flag.Changed {
+				flags.Set(flag.Name, autok3sviper.GetCredential(p.GetProviderName(), profile, flag.Name))
+			}
+		}
+		if flag.Changed {
+			exist = true
 		}
 	})
 
+	if !exist && !autok3sviper.IsDefaultProfile(profile) {
+		return fmt.Errorf("credential profile %s of provider %s is not exist", profile, p.GetProviderName())
+	}
 	return nil
 }
 
+// BeginOperation records the start of operation on the cluster of provider in the history,
+// flags set by user are recorded as parameters with credentials and secrets redacted.
+func BeginOperation(cmd *cobra.Command, action string, p providers.Provider) *types.Operation {
+	params := map[string]interface{}{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		params[f.Name] = f.Value.String()
+	})
+	sensitive := make([]string, 0)
+	for _, f := range p.GetCredentialFlags() {
+		sensitive = append(sensitive, f.Name)
+	}
+	op := &types.Operation{
+		Action:   action,
+		Cluster:  p.GetClusterName(),
+		Provider: p.GetProviderName(),
+		Actor:    currentUser(),
+		Source:   types.OperationSourceCLI,
+	}
+	cluster.BeginOperation(op, params, sensitive...)
+	return op
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
 func GetProviderByState(c types.Cluster) (providers.Provider, error) {
 	b, err := yaml.Marshal(c.Options)
 	if err != nil {
@@ -149,6 +215,96 @@ func GetProviderByState(c types.Cluster) (providers.Provider, error) {
 			Options:  *option,
 			Status:   c.Status,
 		}, nil
+	case "azure":
+		option := &typesAzure.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		// the provider is created by its factory, which sets up the cluster logic shared by cloud providers.
+		p, err := providers.GetProvider(c.Provider)
+		if err != nil {
+			return nil, err
+		}
+		v := p.(*azure.Azure)
+		v.Metadata = c.Metadata
+		v.Options = *option
+		v.Status = c.Status
+		return v, nil
+	case "docker":
+		option := &typesDocker.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		return &docker.Docker{
+			Metadata: c.Metadata,
+			Options:  *option,
+			Status:   c.Status,
+		}, nil
+	case "digitalocean":
+		option := &typesDigitalocean.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		// the provider is created by its factory, which sets up the cluster logic shared by cloud providers.
+		p, err := providers.GetProvider(c.Provider)
+		if err != nil {
+			return nil, err
+		}
+		v := p.(*digitalocean.DigitalOcean)
+		v.Metadata = c.Metadata
+		v.Options = *option
+		v.Status = c.Status
+		return v, nil
+	case "google":
+		option := &typesGoogle.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		// the provider is created by its factory, which sets up the cluster logic shared by cloud providers.
+		p, err := providers.GetProvider(c.Provider)
+		if err != nil {
+			return nil, err
+		}
+		v := p.(*google.Google)
+		v.Metadata = c.Metadata
+		v.Options = *option
+		v.Status = c.Status
+		return v, nil
+	case "hetzner":
+		option := &typesHetzner.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		// the provider is created by its factory, which sets up the cluster logic shared by cloud providers.
+		p, err := providers.GetProvider(c.Provider)
+		if err != nil {
+			return nil, err
+		}
+		v := p.(*hetzner.Hetzner)
+		v.Metadata = c.Metadata
+		v.Options = *option
+		v.Status = c.Status
+		return v, nil
+	case "libvirt":
+		option := &typesLibvirt.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		return &libvirt.Libvirt{
+			Metadata: c.Metadata,
+			Options:  *option,
+			Status:   c.Status,
+		}, nil
+	case "native":
+		option := &typesNative.Options{}
+		if err := yaml.Unmarshal(b, option); err != nil {
+			return nil, err
+		}
+		return &native.Native{
+			Metadata: c.Metadata,
+			Options:  *option,
+			Status:   c.Status,
+		}, nil
 	default:
 		return nil, fmt.Errorf("invalid provider name %s", c.Provider)
 	}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cpCmd = &cobra.Command{
+		Use:   "cp <src> <dst>",
+		Short: "Copy files between local and k3s nodes",
+		Long: `Copy files between local and k3s nodes through sftp, the path on node is in the format of <cluster>:<node>:<path>.
+The cluster is matched by the full name or the name without region and provider in state,
+the node is matched by instance id, public ip or internal ip. The checksum is verified after copied.`,
+		Args: cobra.ExactArgs(2),
+		Example: `  autok3s cp ./registries.yaml myk3s:i-xxxxx:/tmp/registries.yaml
+  autok3s cp --sudo --mode 0600 ./registries.yaml myk3s:192.168.1.10:/etc/rancher/k3s/registries.yaml
+  autok3s cp --sudo myk3s.ap-southeast-1.aws:i-xxxxx:/etc/rancher/k3s/k3s.yaml ./k3s.yaml`,
+	}
+	cpMode     = ""
+	cpOptions  = hosts.TransferOptions{}
+	cpProgress = true
+)
+
+func init() {
+	cpCmd.Flags().StringVar(&cpMode, "mode", cpMode, "Permission of the destination file in octal, e.g. 0644, the mode of source file is used if not specified")
+	cpCmd.Flags().StringVar(&cpOptions.Owner, "owner", cpOptions.Owner, "Owner of the file copied to node")
+	cpCmd.Flags().StringVar(&cpOptions.Group, "group", cpOptions.Group, "Group of the file copied to node")
+	cpCmd.Flags().BoolVar(&cpOptions.Sudo, "sudo", cpOptions.Sudo, "Copy the file with sudo, which is required for files owned by root")
+	cpCmd.Flags().BoolVar(&cpProgress, "progress", cpProgress, "Show the progress of copying")
+}
+
+func CpCommand() *cobra.Command {
+	cpCmd.Run = func(cmd *cobra.Command, args []string) {
+		if cpMode != "" {
+			mode, err := strconv.ParseUint(cpMode, 8, 32)
+			if err != nil || mode == 0 || mode > 0777 {
+				logrus.Fatalf("invalid file mode %s, must be octal permission like 0644\n", cpMode)
+			}
+			cpOptions.Mode = os.FileMode(mode)
+		}
+		if cpProgress {
+			cpOptions.Progress = copyProgress()
+		}
+
+		err := cluster.CopyFile(context.Background(), args[0], args[1], cpOptions)
+		if cpProgress {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+	return cpCmd
+}
+
+// copyProgress returns the callback printing the progress of copying, which is only refreshed when the percentage changes.
+func copyProgress() func(transferred, total int64) {
+	last := int64(-1)
+	return func(transferred, total int64) {
+		if total <= 0 {
+			fmt.Fprintf(os.Stderr, "\r%d bytes", transferred)
+			return
+		}
+		if percent := transferred * 100 / total; percent != last {
+			last = percent
+			fmt.Fprintf(os.Stderr, "\r%d/%d bytes (%d%%)", transferred, total, percent)
+		}
+	}
+}
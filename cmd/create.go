@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/utils"
@@ -22,10 +25,12 @@ var (
 	cSSH = &types.SSH{
 		Port: "22",
 	}
+	cPreflightOnly = false
 )
 
 func init() {
 	createCmd.Flags().StringVarP(&cProvider, "provider", "p", cProvider, "Provider is a module which provides an interface for managing cloud resources")
+	createCmd.Flags().BoolVar(&cPreflightOnly, "preflight-only", cPreflightOnly, "Only run pre-flight checks on nodes without installing k3s")
 }
 
 func CreateCommand() *cobra.Command {
@@ -46,6 +51,7 @@ func CreateCommand() *cobra.Command {
 		createCmd.Flags().StringVar(&cSSH.SSHCertPath, "ssh-key-cert-path", cSSH.SSHCertPath, "SSH private key certificate path")
 		createCmd.Flags().StringVar(&cSSH.Password, "ssh-password", cSSH.Password, "SSH login password")
 		createCmd.Flags().BoolVar(&cSSH.SSHAgentAuth, "ssh-agent", cSSH.SSHAgentAuth, "Enable ssh agent")
+		createCmd.Flags().Var(common.NewBastionsValue(&cSSH.Bastions), "bastion", common.BastionUsage)
 
 		createCmd.Flags().AddFlagSet(utils.ConvertFlags(createCmd, cp.GetCredentialFlags()))
 		createCmd.Flags().AddFlagSet(utils.ConvertFlags(createCmd, cp.GetOptionFlags()))
@@ -63,12 +69,26 @@ func CreateCommand() *cobra.Command {
 	createCmd.Run = func(cmd *cobra.Command, args []string) {
 		// generate cluster name. e.g. input: "--name k3s1 --region cn-hangzhou" output: "k3s1.cn-hangzhou.<provider>"
 		cp.GenerateClusterName()
+		if cPreflightOnly {
+			err := cp.CreateCheck(cSSH)
+			if err == nil {
+				_, err = cp.PreflightK3sNodes(cSSH)
+			}
+			if err != nil {
+				logrus.Fatalln(err)
+			}
+			return
+		}
+		op := common.BeginOperation(cmd, types.OperationCreate, cp)
 		if err := cp.CreateCheck(cSSH); err != nil {
+			cluster.EndOperation(op, err)
 			logrus.Fatalln(err)
 		}
 
 		// create k3s cluster with generated cluster name.
-		if err := cp.CreateK3sCluster(cSSH); err != nil {
+		err := cp.CreateK3sCluster(cluster.WithOperation(context.Background(), op), cSSH)
+		cluster.EndOperation(op, err)
+		if err != nil {
 			logrus.Errorln(err)
 			if rErr := cp.Rollback(); rErr != nil {
 				logrus.Fatalln(rErr)
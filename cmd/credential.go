@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	pkgcommon "github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	credentialCmd = &cobra.Command{
+		Use:       "credential [create|list|update|delete]",
+		Short:     "Create, list, update or delete credential profiles of providers",
+		ValidArgs: []string{"create", "list", "update", "delete"},
+		Args:      cobra.ExactValidArgs(1),
+		Example: `  autok3s credential create --provider alibaba --name prod --access-key <access-key> --access-secret <access-secret>
+  autok3s credential list
+  autok3s credential update --provider alibaba --name prod --access-secret <access-secret>
+  autok3s credential delete --provider alibaba --name prod
+  autok3s create --provider alibaba --name myk3s --credential prod --master 1`,
+	}
+	crProvider = ""
+	crName     = ""
+	crp        providers.Provider
+)
+
+func init() {
+	credentialCmd.Flags().StringVarP(&crProvider, "provider", "p", crProvider, "Provider is a module which provides an interface for managing cloud resources")
+	credentialCmd.Flags().StringVarP(&crName, "name", "n", crName, "Name of the credential profile, the default credential is used if not specified")
+}
+
+func CredentialCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			crp = reg
+		}
+
+		// credential flags are checked on create, so that profiles can be updated partially.
+		fs := crp.GetCredentialFlags()
+		flags := make([]types.Flag, 0, len(fs))
+		for _, f := range fs {
+			f.Required = false
+			flags = append(flags, f)
+		}
+		credentialCmd.Flags().AddFlagSet(utils.ConvertFlags(credentialCmd, flags))
+	}
+
+	credentialCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if args[0] != "list" && crProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		if err := autok3sviper.ValidateProfileName(crName); err != nil {
+			return err
+		}
+		return viper.ReadInConfig()
+	}
+
+	credentialCmd.Run = func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "create":
+			err = createCredential(cmd.Flags())
+		case "list":
+			listCredential()
+		case "update":
+			err = updateCredential(cmd.Flags())
+		case "delete":
+			err = deleteCredential()
+		}
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return credentialCmd
+}
+
+func createCredential(flags *pflag.FlagSet) error {
+	name := crp.GetProviderName()
+	if autok3sviper.ProfileExists(name, crName, credentialFlagNames(crp)) {
+		return fmt.Errorf("credential profile %s of provider %s is already exist", profileDisplayName(crName), name)
+	}
+	for _, f := range crp.GetCredentialFlags() {
+		if !flags.Changed(f.Name) {
+			return fmt.Errorf("required flags(s) \"[%s]\" not set", f.Name)
+		}
+	}
+	return saveCredential(flags)
+}
+
+func updateCredential(flags *pflag.FlagSet) error {
+	name := crp.GetProviderName()
+	if !autok3sviper.ProfileExists(name, crName, credentialFlagNames(crp)) {
+		return fmt.Errorf("credential profile %s of provider %s is not exist", profileDisplayName(crName), name)
+	}
+	return saveCredential(flags)
+}
+
+func saveCredential(flags *pflag.FlagSet) error {
+	name := crp.GetProviderName()
+	for _, f := range crp.GetCredentialFlags() {
+		if !flags.Changed(f.Name) {
+			continue
+		}
+		v, err := flags.GetString(f.Name)
+		if err != nil {
+			return err
+		}
+		viper.Set(autok3sviper.CredentialKey(name, crName, f.Name), v)
+	}
+	if err := autok3sviper.WriteConfig(); err != nil {
+		return err
+	}
+	logrus.Infof("credential profile %s of provider %s is saved", profileDisplayName(crName), name)
+	return nil
+}
+
+func deleteCredential() error {
+	name := crp.GetProviderName()
+	if !autok3sviper.IsDefaultProfile(crName) {
+		clusters, err := cluster.ListClustersByCredential(name, crName)
+		if err != nil {
+			return err
+		}
+		if len(clusters) > 0 {
+			return fmt.Errorf("credential profile %s of provider %s is used by clusters %s", crName, name, strings.Join(clusters, ", "))
+		}
+	}
+	if err := autok3sviper.DeleteProfile(name, crName); err != nil {
+		return err
+	}
+	logrus.Infof("credential profile %s of provider %s is deleted", profileDisplayName(crName), name)
+	return nil
+}
+
+func listCredential() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Provider", "Profile"})
+
+	list := providers.ListProviders()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	for _, info := range list {
+		if crProvider != "" && info.Name != crProvider {
+			continue
+		}
+		p, err := providers.GetProvider(info.Name)
+		if err != nil {
+			logrus.Errorf("failed to get provider %s: %v", info.Name, err)
+			continue
+		}
+		names := credentialFlagNames(p)
+		if len(names) == 0 {
+			continue
+		}
+		if autok3sviper.ProfileExists(info.Name, "", names) {
+			table.Append([]string{info.Name, pkgcommon.DefaultProfile})
+		}
+		for _, profile := range autok3sviper.ListProfiles(info.Name) {
+			table.Append([]string{info.Name, profile})
+		}
+	}
+
+	table.Render()
+}
+
+func credentialFlagNames(p providers.Provider) []string {
+	names := make([]string, 0)
+	for _, f := range p.GetCredentialFlags() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func profileDisplayName(profile string) string {
+	if profile == "" {
+		return pkgcommon.DefaultProfile
+	}
+	return profile
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/utils"
 
 	"github.com/sirupsen/logrus"
@@ -54,8 +58,11 @@ func DeleteCommand() *cobra.Command {
 
 	deleteCmd.Run = func(cmd *cobra.Command, args []string) {
 		dp.GenerateClusterName()
+		op := common.BeginOperation(cmd, types.OperationDelete, dp)
 
-		if err := dp.DeleteK3sCluster(force); err != nil {
+		err := dp.DeleteK3sCluster(cluster.WithOperation(context.Background(), op), force)
+		cluster.EndOperation(op, err)
+		if err != nil {
 			logrus.Fatalln(err)
 		}
 	}
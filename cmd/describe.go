@@ -9,7 +9,6 @@ import (
 	c "github.com/cnrancher/autok3s/cmd/common"
 	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/common"
-	"github.com/cnrancher/autok3s/pkg/utils"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -51,19 +50,14 @@ func describeCluster(args []string) {
 	if len(args) < 2 {
 		logrus.Fatalln("you must specify the type of resource to describe, e.g. autok3s describe cluster <cluster name>")
 	}
-	v := common.CfgPath
-	if v == "" {
-		logrus.Fatalln("state path is empty")
-	}
 	// get all clusters from state
-	clusters, err := utils.ReadYaml(v, common.StateFile)
+	store, err := cluster.GetStateStore()
 	if err != nil {
-		logrus.Fatalf("read state file error, msg: %v\n", err)
+		logrus.Fatalln(err)
 	}
-
-	result, err := cluster.ConvertToClusters(clusters)
+	result, err := store.List()
 	if err != nil {
-		logrus.Fatalf("failed to unmarshal state file, msg: %v\n", err)
+		logrus.Fatalf("read cluster state error, msg: %v\n", err)
 	}
 
 	resourceNames := args[1:]
@@ -126,8 +120,11 @@ func describeCluster(args []string) {
 }
 
 func isSpecifiedCluster(context, name, region, provider string) bool {
-	// context format is <name>.<region>.<provider>
+	// context format is <name>.<region>.<provider>, native cluster context is <name>.
 	contextArray := strings.Split(context, ".")
+	if len(contextArray) == 1 {
+		return context == name && region == "" && (provider == "" || provider == "native")
+	}
 	if region == "" && provider == "" {
 		return contextArray[0] == name
 	}
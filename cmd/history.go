@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/ghodss/yaml"
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const historyErrorWidth = 60
+
+var (
+	historyCmd = &cobra.Command{
+		Use:   "history [operation-id]",
+		Short: "Show operation history of k3s clusters",
+		Long:  "Show who created, joined, deleted, upgraded clusters and connected to them through ssh or kubectl, the detail of operation is shown if operation id is specified",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `  autok3s history
+  autok3s history --cluster myk3s --action create
+  autok3s history <operation-id>`,
+	}
+	hFilter = cluster.OperationFilter{
+		Limit: 20,
+	}
+)
+
+func init() {
+	historyCmd.Flags().StringVar(&hFilter.Cluster, "cluster", hFilter.Cluster, "Show operations of the cluster")
+	historyCmd.Flags().StringVarP(&hFilter.Provider, "provider", "p", hFilter.Provider, "Show operations of clusters of the provider")
+	historyCmd.Flags().StringVar(&hFilter.Action, "action", hFilter.Action, "Show operations of the action, supports create, join, delete, upgrade, ssh and kubectl")
+	historyCmd.Flags().StringVar(&hFilter.Actor, "actor", hFilter.Actor, "Show operations of the actor")
+	historyCmd.Flags().IntVar(&hFilter.Limit, "limit", hFilter.Limit, "The max number of the latest operations to show, 0 means no limit")
+}
+
+func HistoryCommand() *cobra.Command {
+	historyCmd.Run = func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			describeOperation(args[0])
+			return
+		}
+		listOperations()
+	}
+	return historyCmd
+}
+
+func listOperations() {
+	ops, err := cluster.ListOperations(hFilter)
+	if err != nil {
+		logrus.Fatalf("read operation history error, msg: %v\n", err)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"ID", "Action", "Cluster", "Provider", "Actor", "Source", "Start", "Duration", "Result", "Error"})
+	for _, op := range ops {
+		table.Append([]string{
+			op.ID,
+			op.Action,
+			op.Cluster,
+			op.Provider,
+			op.Actor,
+			op.Source,
+			op.StartTime,
+			operationDuration(op),
+			op.Result,
+			truncate(op.Error, historyErrorWidth),
+		})
+	}
+	table.Render()
+}
+
+func describeOperation(id string) {
+	op, err := cluster.GetOperation(id)
+	if err != nil {
+		logrus.Fatalf("read operation history error, msg: %v\n", err)
+	}
+	if op == nil {
+		logrus.Fatalf("operation %s is not found", id)
+	}
+	b, err := yaml.Marshal(op)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+	fmt.Print(string(b))
+}
+
+func operationDuration(op types.Operation) string {
+	if op.EndTime == "" {
+		return ""
+	}
+	start, err := time.Parse(time.RFC3339, op.StartTime)
+	if err != nil {
+		return ""
+	}
+	end, err := time.Parse(time.RFC3339, op.EndTime)
+	if err != nil {
+		return ""
+	}
+	return end.Sub(start).String()
+}
+
+func truncate(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= width {
+		return s
+	}
+	return s[:width-3] + "..."
+}
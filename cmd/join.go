@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/utils"
@@ -21,10 +24,12 @@ var (
 	jSSH = &types.SSH{
 		Port: "22",
 	}
+	jPreflightOnly = false
 )
 
 func init() {
 	joinCmd.Flags().StringVarP(&jProvider, "provider", "p", jProvider, "Provider is a module which provides an interface for managing cloud resources")
+	joinCmd.Flags().BoolVar(&jPreflightOnly, "preflight-only", jPreflightOnly, "Only run pre-flight checks on nodes without installing k3s")
 }
 
 func JoinCommand() *cobra.Command {
@@ -44,6 +49,7 @@ func JoinCommand() *cobra.Command {
 		joinCmd.Flags().StringVar(&jSSH.SSHCertPath, "ssh-key-cert-path", jSSH.SSHCertPath, "SSH private key certificate path")
 		joinCmd.Flags().StringVar(&jSSH.Password, "ssh-password", jSSH.Password, "SSH login password")
 		joinCmd.Flags().BoolVar(&jSSH.SSHAgentAuth, "ssh-agent", jSSH.SSHAgentAuth, "Enable ssh agent")
+		joinCmd.Flags().Var(common.NewBastionsValue(&jSSH.Bastions), "bastion", common.BastionUsage)
 
 		joinCmd.Flags().AddFlagSet(utils.ConvertFlags(joinCmd, jp.GetCredentialFlags()))
 		joinCmd.Flags().AddFlagSet(jp.GetJoinFlags(joinCmd))
@@ -66,9 +72,18 @@ func JoinCommand() *cobra.Command {
 	joinCmd.Run = func(cmd *cobra.Command, args []string) {
 		// generate cluster name. e.g. input: "--name k3s1 --region cn-hangzhou" output: "k3s1.cn-hangzhou"
 		jp.GenerateClusterName()
+		if jPreflightOnly {
+			if _, err := jp.PreflightK3sNodes(jSSH); err != nil {
+				logrus.Fatalln(err)
+			}
+			return
+		}
+		op := common.BeginOperation(cmd, types.OperationJoin, jp)
 
 		// join k3s node to the cluster which named with generated cluster name.
-		if err := jp.JoinK3sNode(jSSH); err != nil {
+		err := jp.JoinK3sNode(cluster.WithOperation(context.Background(), op), jSSH)
+		cluster.EndOperation(op, err)
+		if err != nil {
 			logrus.Errorln(err)
 			if rErr := jp.Rollback(); rErr != nil {
 				logrus.Fatalln(rErr)
@@ -10,7 +10,6 @@ import (
 	"github.com/cnrancher/autok3s/pkg/common"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/types"
-	"github.com/cnrancher/autok3s/pkg/utils"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/sirupsen/logrus"
@@ -40,19 +39,14 @@ func listCluster() {
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.SetHeader([]string{"Name", "Region", "Provider", "Status", "Masters", "Workers", "Version"})
 
-	v := common.CfgPath
-	if v == "" {
-		logrus.Fatalln("state path is empty")
-	}
 	// get all clusters from state
-	clusters, err := utils.ReadYaml(v, common.StateFile)
+	store, err := cluster.GetStateStore()
 	if err != nil {
-		logrus.Fatalf("read state file error, msg: %v\n", err)
+		logrus.Fatalln(err)
 	}
-
-	result, err := cluster.ConvertToClusters(clusters)
+	result, err := store.List()
 	if err != nil {
-		logrus.Fatalf("failed to unmarshal state file, msg: %v\n", err)
+		logrus.Fatalf("read cluster state error, msg: %v\n", err)
 	}
 	var p providers.Provider
 	kubeCfg := fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile)
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/secrets"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	rekeyCmd = &cobra.Command{
+		Use:   "rekey",
+		Short: "Re-encrypt credentials and cluster secrets with a new master key",
+		Long: `Re-encrypt credentials in config file and ssh secrets in cluster states with a new master key,
+plaintext secrets written by old versions are encrypted as well.
+The master key is read from env AUTOK3S_MASTER_KEY, the OS keyring (--master-key-keyring) or the key file (--master-key-file) in order.
+Please stop other autok3s processes before running it.`,
+	}
+	rkNewKeyFile = ""
+)
+
+func init() {
+	rekeyCmd.Flags().StringVar(&rkNewKeyFile, "new-key-file", rkNewKeyFile, "Path to the file of new master key, a random key is generated if not specified, required if the master key is from env")
+}
+
+func RekeyCommand() *cobra.Command {
+	rekeyCmd.Run = func(cmd *cobra.Command, args []string) {
+		if err := rekey(); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+	return rekeyCmd
+}
+
+func rekey() error {
+	// the new key must be set to env by user, so it can't be generated and shown in logs.
+	if secrets.IsEnvKey() && rkNewKeyFile == "" {
+		return fmt.Errorf("the master key is from env %s, please specify the new master key with --new-key-file", secrets.EnvMasterKey)
+	}
+	newKey := ""
+	if rkNewKeyFile != "" {
+		b, err := ioutil.ReadFile(rkNewKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read new master key: %v", err)
+		}
+		newKey = strings.TrimSpace(string(b))
+	} else {
+		var err error
+		if newKey, err = secrets.GenerateKey(); err != nil {
+			return err
+		}
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	// make sure secrets can be decrypted with the current key before rotating.
+	for _, key := range viper.AllKeys() {
+		if v, ok := viper.Get(key).(string); ok {
+			if _, err := secrets.Decrypt(v); err != nil {
+				return fmt.Errorf("failed to decrypt %s: %v", key, err)
+			}
+		}
+	}
+	store, err := cluster.GetStateStore()
+	if err != nil {
+		return err
+	}
+	if _, err := store.List(); err != nil {
+		return err
+	}
+
+	if err := secrets.Rotate(newKey); err != nil {
+		return err
+	}
+	if err := autok3sviper.ReencryptConfig(); err != nil {
+		return err
+	}
+	if err := cluster.ReencryptStates(); err != nil {
+		return err
+	}
+	if err := secrets.CompleteRotation(); err != nil {
+		return err
+	}
+
+	if secrets.IsEnvKey() {
+		logrus.Infof("secrets are re-encrypted, please set env %s to the new master key in %s", secrets.EnvMasterKey, rkNewKeyFile)
+		return nil
+	}
+	logrus.Info("secrets are re-encrypted with the new master key")
+	return nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeNodeCmd = &cobra.Command{
+		Use:   "remove-node",
+		Short: "Remove a node from k3s cluster",
+	}
+	rnProvider = ""
+	rnNode     = ""
+	rnForce    = false
+	rnp        providers.Provider
+)
+
+func init() {
+	removeNodeCmd.Flags().StringVarP(&rnProvider, "provider", "p", rnProvider, "Provider is a module which provides an interface for managing cloud resources")
+	removeNodeCmd.Flags().StringVar(&rnNode, "node", rnNode, "The instance id, public ip or internal ip of the node to be removed")
+	removeNodeCmd.Flags().BoolVarP(&rnForce, "force", "f", rnForce, "Force remove node without confirmation")
+}
+
+func RemoveNodeCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			rnp = reg
+		}
+
+		removeNodeCmd.Flags().AddFlagSet(utils.ConvertFlags(removeNodeCmd, rnp.GetCredentialFlags()))
+		removeNodeCmd.Flags().AddFlagSet(rnp.GetDeleteFlags(removeNodeCmd))
+		removeNodeCmd.Example = rnp.GetUsageExample("remove-node")
+	}
+
+	removeNodeCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if rnProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		if rnNode == "" {
+			logrus.Fatalln("required flags(s) \"[node]\" not set")
+		}
+		common.InitPFlags(cmd, rnp)
+		err := rnp.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), rnp)
+	}
+
+	removeNodeCmd.Run = func(cmd *cobra.Command, args []string) {
+		rnp.GenerateClusterName()
+
+		if err := rnp.RemoveK3sNode(rnNode, rnForce); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return removeNodeCmd
+}
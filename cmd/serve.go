@@ -18,20 +18,44 @@ var (
 
 	bindPort    = "8080"
 	bindAddress = "127.0.0.1"
+
+	sOptions = server.Options{ProxyDefaultRole: "viewer"}
 )
 
 func init() {
 	// only support localhost for now
 	serveCmd.Flags().StringVar(&bindPort, "bind-port", bindPort, "HTTP/HTTPS bind port")
 	//serveCmd.Flags().StringVar(&bindAddress, "bind-address", bindAddress, "HTTP/HTTPS bind address")
+	serveCmd.Flags().StringVar(&sOptions.AuthFile, "auth-file", sOptions.AuthFile, "Path to the file of users with bcrypt passwords and bearer tokens to authenticate requests, the role of each can be viewer, operator or admin")
+	serveCmd.Flags().StringVar(&sOptions.ProxyUserHeader, "auth-proxy-user-header", sOptions.ProxyUserHeader, "Header of the user authenticated by proxy, e.g.(X-Remote-User), only enable it when the server can only be reached through the proxy")
+	serveCmd.Flags().StringVar(&sOptions.ProxyRoleHeader, "auth-proxy-role-header", sOptions.ProxyRoleHeader, "Header of the role of user authenticated by proxy, e.g.(X-Remote-Role)")
+	serveCmd.Flags().StringVar(&sOptions.ProxyDefaultRole, "auth-proxy-default-role", sOptions.ProxyDefaultRole, "Role of user authenticated by proxy if role header is not set")
+	serveCmd.Flags().StringSliceVar(&sOptions.AllowedOrigins, "allowed-origins", sOptions.AllowedOrigins, "Origins allowed to open websocket besides the same origin, e.g.(https://autok3s.example.com)")
+	serveCmd.Flags().StringVar(&sOptions.TLSCert, "tls-cert", sOptions.TLSCert, "Path to the HTTPS serving certificate, a self-signed certificate is generated under cfg path if not specified")
+	serveCmd.Flags().StringVar(&sOptions.TLSKey, "tls-key", sOptions.TLSKey, "Path to the HTTPS serving key")
+	serveCmd.Flags().StringVar(&sOptions.TLSClientCA, "tls-client-ca", sOptions.TLSClientCA, "Path to the CA bundle to verify client certificates, verified clients are authenticated by the common name, and the role in organization (viewer by default)")
+	serveCmd.Flags().BoolVar(&sOptions.EnablePprof, "enable-pprof", sOptions.EnablePprof, "Enable profiling handlers under /debug/pprof")
 }
 
 func ServeCommand() *cobra.Command {
 	serveCmd.Run = func(cmd *cobra.Command, args []string) {
-		router := server.Start()
-
-		logrus.Infof("run as daemon, listening on %s:%s", bindAddress, bindPort)
-		logrus.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%s", bindAddress, bindPort), router))
+		router, err := server.Start(sOptions)
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+
+		tlsConfig, err := server.NewTLSConfig(sOptions, bindAddress)
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+		s := &http.Server{
+			Addr:      fmt.Sprintf("%s:%s", bindAddress, bindPort),
+			Handler:   router,
+			TLSConfig: tlsConfig,
+		}
+
+		logrus.Infof("run as daemon, listening on https://%s:%s", bindAddress, bindPort)
+		logrus.Fatal(s.ListenAndServeTLS("", ""))
 	}
 
 	return serveCmd
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotCmd = &cobra.Command{
+		Use:       "snapshot [save|list|restore]",
+		Short:     "Save, list or restore etcd snapshots of k3s cluster with embedded etcd",
+		ValidArgs: []string{"save", "list", "restore"},
+		Args:      cobra.ExactValidArgs(1),
+	}
+	snProvider = ""
+	snName     = ""
+	snp        providers.Provider
+)
+
+func init() {
+	snapshotCmd.Flags().StringVarP(&snProvider, "provider", "p", snProvider, "Provider is a module which provides an interface for managing cloud resources")
+	snapshotCmd.Flags().StringVar(&snName, "snapshot-name", snName, "The name of snapshot to save, or the file name of snapshot to restore")
+}
+
+func SnapshotCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			snp = reg
+		}
+
+		snapshotCmd.Flags().AddFlagSet(utils.ConvertFlags(snapshotCmd, snp.GetCredentialFlags()))
+		snapshotCmd.Flags().AddFlagSet(snp.GetDeleteFlags(snapshotCmd))
+		snapshotCmd.Example = snp.GetUsageExample("snapshot")
+	}
+
+	snapshotCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if snProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		if args[0] == "restore" && snName == "" {
+			logrus.Fatalln("required flags(s) \"[snapshot-name]\" not set")
+		}
+		common.InitPFlags(cmd, snp)
+		err := snp.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), snp)
+	}
+
+	snapshotCmd.Run = func(cmd *cobra.Command, args []string) {
+		snp.GenerateClusterName()
+
+		switch args[0] {
+		case "save":
+			snapshot, err := snp.SaveSnapshot(snName)
+			if err != nil {
+				logrus.Fatalln(err)
+			}
+			fmt.Printf("snapshot %s is saved\n", snapshot.Name)
+		case "list":
+			listSnapshots()
+		case "restore":
+			if err := snp.RestoreSnapshot(snName); err != nil {
+				logrus.Fatalln(err)
+			}
+		}
+	}
+
+	return snapshotCmd
+}
+
+func listSnapshots() {
+	snapshots, err := snp.ListSnapshots()
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Name", "Size", "Created"})
+	for _, s := range snapshots {
+		table.Append([]string{s.Name, fmt.Sprintf("%d", s.Size), s.Created})
+	}
+	table.Render()
+}
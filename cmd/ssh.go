@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/utils"
@@ -43,6 +44,7 @@ func SSHCommand() *cobra.Command {
 		sshCmd.Flags().StringVar(&sSSH.SSHCertPath, "ssh-key-cert-path", sSSH.SSHCertPath, "SSH private key certificate path")
 		sshCmd.Flags().StringVar(&sSSH.Password, "ssh-password", sSSH.Password, "SSH login password")
 		sshCmd.Flags().BoolVar(&sSSH.SSHAgentAuth, "ssh-agent", sSSH.SSHAgentAuth, "Enable ssh agent")
+		sshCmd.Flags().Var(common.NewBastionsValue(&sSSH.Bastions), "bastion", common.BastionUsage)
 
 		sshCmd.Flags().AddFlagSet(utils.ConvertFlags(sshCmd, sp.GetCredentialFlags()))
 		sshCmd.Flags().AddFlagSet(sp.GetSSHFlags(sshCmd))
@@ -67,7 +69,10 @@ func SSHCommand() *cobra.Command {
 		if len(args) > 0 {
 			node = args[0]
 		}
-		if err := sp.SSHK3sNode(sSSH, node); err != nil {
+		op := common.BeginOperation(cmd, types.OperationSSH, sp)
+		err := sp.SSHK3sNode(sSSH, node)
+		cluster.EndOperation(op, err)
+		if err != nil {
 			logrus.Fatalln(err)
 		}
 	}
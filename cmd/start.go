@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	startCmd = &cobra.Command{
+		Use:   "start",
+		Short: "Start all instances of k3s cluster",
+	}
+	saProvider = ""
+	sap        providers.Provider
+)
+
+func init() {
+	startCmd.Flags().StringVarP(&saProvider, "provider", "p", saProvider, "Provider is a module which provides an interface for managing cloud resources")
+}
+
+func StartCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			sap = reg
+		}
+
+		startCmd.Flags().AddFlagSet(utils.ConvertFlags(startCmd, sap.GetCredentialFlags()))
+		startCmd.Flags().AddFlagSet(sap.GetDeleteFlags(startCmd))
+		startCmd.Example = sap.GetUsageExample("start")
+	}
+
+	startCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if saProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		common.InitPFlags(cmd, sap)
+		err := sap.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), sap)
+	}
+
+	startCmd.Run = func(cmd *cobra.Command, args []string) {
+		sap.GenerateClusterName()
+
+		if err := sap.StartK3sCluster(); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return startCmd
+}
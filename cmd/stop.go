@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop all instances of k3s cluster",
+	}
+	soProvider = ""
+	sop        providers.Provider
+)
+
+func init() {
+	stopCmd.Flags().StringVarP(&soProvider, "provider", "p", soProvider, "Provider is a module which provides an interface for managing cloud resources")
+}
+
+func StopCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			sop = reg
+		}
+
+		stopCmd.Flags().AddFlagSet(utils.ConvertFlags(stopCmd, sop.GetCredentialFlags()))
+		stopCmd.Flags().AddFlagSet(sop.GetDeleteFlags(stopCmd))
+		stopCmd.Example = sop.GetUsageExample("stop")
+	}
+
+	stopCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if soProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		common.InitPFlags(cmd, sop)
+		err := sop.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), sop)
+	}
+
+	stopCmd.Run = func(cmd *cobra.Command, args []string) {
+		sop.GenerateClusterName()
+
+		if err := sop.StopK3sCluster(); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return stopCmd
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cnrancher/autok3s/cmd/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	pkgcommon "github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeCmd = &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade k3s cluster to specified version",
+	}
+	uProvider   = ""
+	uK3sVersion = ""
+	up          providers.Provider
+)
+
+func init() {
+	upgradeCmd.Flags().StringVarP(&uProvider, "provider", "p", uProvider, "Provider is a module which provides an interface for managing cloud resources")
+	upgradeCmd.Flags().StringVar(&uK3sVersion, "k3s-version", uK3sVersion, "The k3s version which cluster will be upgraded to, e.g.(v1.19.5+k3s1)")
+	upgradeCmd.Flags().DurationVar(&pkgcommon.UpgradeNodeTimeout, "node-timeout", pkgcommon.UpgradeNodeTimeout, "The timeout of waiting for each node to be ready in the new version")
+}
+
+func UpgradeCommand() *cobra.Command {
+	pStr := common.FlagHackLookup("--provider")
+
+	if pStr != "" {
+		if reg, err := providers.GetProvider(pStr); err != nil {
+			logrus.Fatalln(err)
+		} else {
+			up = reg
+		}
+
+		upgradeCmd.Flags().AddFlagSet(utils.ConvertFlags(upgradeCmd, up.GetCredentialFlags()))
+		upgradeCmd.Flags().AddFlagSet(up.GetDeleteFlags(upgradeCmd))
+		upgradeCmd.Example = up.GetUsageExample("upgrade")
+	}
+
+	upgradeCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if uProvider == "" {
+			logrus.Fatalln("required flags(s) \"[provider]\" not set")
+		}
+		if uK3sVersion == "" {
+			logrus.Fatalln("required flags(s) \"[k3s-version]\" not set")
+		}
+		common.InitPFlags(cmd, up)
+		err := up.MergeClusterOptions()
+		if err != nil {
+			return err
+		}
+
+		return common.MakeSureCredentialFlag(cmd.Flags(), up)
+	}
+
+	upgradeCmd.Run = func(cmd *cobra.Command, args []string) {
+		up.GenerateClusterName()
+		op := common.BeginOperation(cmd, types.OperationUpgrade, up)
+
+		err := up.UpgradeK3sCluster(cluster.WithOperation(context.Background(), op), uK3sVersion)
+		cluster.EndOperation(op, err)
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
+	return upgradeCmd
+}
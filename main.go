@@ -39,7 +39,8 @@ func main() {
 	rootCmd := cmd.Command()
 	rootCmd.AddCommand(cmd.CompletionCommand(), cmd.VersionCommand(gitVersion, gitCommit, gitTreeState, buildDate),
 		cmd.ListCommand(), cmd.CreateCommand(), cmd.JoinCommand(), cmd.KubectlCommand(), cmd.DeleteCommand(),
-		cmd.SSHCommand(), cmd.DescribeCommand(), cmd.ServeCommand())
+		cmd.SSHCommand(), cmd.DescribeCommand(), cmd.ServeCommand(), cmd.ApplyCommand(), cmd.RemoveNodeCommand(), cmd.UpgradeCommand(), cmd.SnapshotCommand(),
+		cmd.CheckCommand(), cmd.StartCommand(), cmd.StopCommand(), cmd.RekeyCommand(), cmd.CredentialCommand(), cmd.HistoryCommand(), cmd.CpCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const refreshAddressCommand = "sudo sed -i 's/\\b%s\\b/%s/g' /etc/systemd/system/%s.service && sudo systemctl daemon-reload && sudo systemctl restart %s"
+
+// RefreshNodeAddresses updates the k3s services and kube config with the new public ip addresses of nodes,
+// which are changed after instances restarted. previous is the public ip of node before restarted, keyed by instance id.
+func RefreshNodeAddresses(merged *types.Cluster, previous map[string]string) error {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing refresh node addresses logic...\n", merged.Provider)
+
+	changed := make(map[string]string)
+	for _, nodes := range [][]types.Node{merged.MasterNodes, merged.WorkerNodes} {
+		for _, node := range nodes {
+			old := previous[node.InstanceID]
+			if old == "" || len(node.PublicIPAddress) <= 0 || node.PublicIPAddress[0] == "" || node.PublicIPAddress[0] == old {
+				continue
+			}
+			changed[old] = node.PublicIPAddress[0]
+
+			// --tls-san and --node-external-ip of k3s service are replaced with the new address.
+			logger.Infof("[%s] public ip of node %s is changed from %s to %s, updating k3s service...\n",
+				merged.Provider, node.InstanceID, old, node.PublicIPAddress[0])
+			service := getServiceName(node)
+			if _, err := execute(&hosts.Host{Node: node}, []string{fmt.Sprintf(refreshAddressCommand,
+				regexp.QuoteMeta(old), node.PublicIPAddress[0], service, service)}); err != nil {
+				return fmt.Errorf("[cluster] failed to update k3s service of node %s: %v", node.InstanceID, err)
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := updateCfgServer(merged.Name, changed); err != nil {
+			return fmt.Errorf("[cluster] failed to update kubecfg of cluster %s: %v", merged.Name, err)
+		}
+	}
+
+	client, err := GetClusterConfig(merged.Name, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
+	if err != nil {
+		return err
+	}
+	if err := utils.WaitFor(func() (bool, error) {
+		return GetClusterStatus(client) == types.ClusterStatusRunning, nil
+	}); err != nil {
+		return fmt.Errorf("[cluster] cluster is not ready after started: %v", err)
+	}
+
+	logger.Infof("[%s] successfully executed refresh node addresses logic\n", merged.Provider)
+	return nil
+}
+
+// updateCfgServer replaces the server address of context in kube config.
+func updateCfgServer(context string, changed map[string]string) error {
+	path := fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile)
+	c, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return err
+	}
+
+	cluster, ok := c.Clusters[context]
+	if !ok {
+		return nil
+	}
+	u, err := url.Parse(cluster.Server)
+	if err != nil {
+		return err
+	}
+	ip, ok := changed[u.Hostname()]
+	if !ok {
+		return nil
+	}
+	if u.Port() != "" {
+		u.Host = fmt.Sprintf("%s:%s", ip, u.Port())
+	} else {
+		u.Host = ip
+	}
+	cluster.Server = u.String()
+
+	return clientcmd.WriteToFile(*c, path)
+}
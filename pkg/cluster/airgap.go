@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+const (
+	// files in the air-gap directory, images tarball is optional when images are pulled from private registry.
+	airGapBinary        = "k3s"
+	airGapInstallScript = "install.sh"
+	airGapImages        = "k3s-airgap-images-*"
+
+	// airGapUploadDir is the directory on nodes which the install script and the k3s binary to check are uploaded to,
+	// it's owned by root and only accessible by root, so that the files can't be replaced by other users before run as root.
+	airGapUploadDir       = "/var/lib/rancher/autok3s/airgap"
+	airGapUploadDirCmd    = "sudo install -d -m 0700 -o root -g root %s"
+	airGapBinaryPath      = "/usr/local/bin/k3s"
+	airGapImagesDir       = "/var/lib/rancher/k3s/agent/images"
+	airGapSkipDownloadEnv = "INSTALL_K3S_SKIP_DOWNLOAD=true"
+)
+
+// airGapFile is the file in the air-gap directory and where it is uploaded to on nodes.
+type airGapFile struct {
+	name   string
+	target string
+	opts   hosts.TransferOptions
+}
+
+// CheckAirGap checks the air-gap directory contains k3s binary and install script.
+func CheckAirGap(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("[cluster] invalid air-gap directory: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("[cluster] air-gap directory %s is not a directory", dir)
+	}
+	for _, name := range []string{airGapBinary, airGapInstallScript} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("[cluster] %s is required in air-gap directory %s: %v", name, dir, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("[cluster] %s in air-gap directory %s is not a file", name, dir)
+		}
+	}
+	return nil
+}
+
+// installScript returns the command which outputs k3s install script, the script uploaded to nodes is used in air-gap mode.
+func installScript(cluster *types.Cluster) string {
+	if cluster.AirGapDir != "" {
+		return "sudo cat " + path.Join(airGapUploadDir, airGapInstallScript)
+	}
+	return "curl -sLS " + cluster.InstallScript
+}
+
+// installEnv returns env of k3s install script, k3s binary is not downloaded in air-gap mode.
+func installEnv(cluster *types.Cluster) string {
+	if cluster.AirGapDir != "" {
+		return airGapSkipDownloadEnv
+	}
+	return cluster.Mirror
+}
+
+// prepareAirGap uploads k3s binary, install script and images tarball in the air-gap directory to the paths used by k3s on the node,
+// so that k3s can be installed without Internet access.
+func prepareAirGap(ctx context.Context, cluster *types.Cluster, node types.Node) error {
+	if cluster.AirGapDir == "" {
+		return nil
+	}
+	matched, err := filepath.Glob(filepath.Join(cluster.AirGapDir, airGapImages))
+	if err != nil {
+		return err
+	}
+	uploads := []airGapFile{
+		{airGapBinary, airGapBinaryPath, hosts.TransferOptions{Mode: 0755, Sudo: true}},
+		{airGapInstallScript, path.Join(airGapUploadDir, airGapInstallScript), hosts.TransferOptions{Mode: 0600, Sudo: true}},
+	}
+	for _, f := range matched {
+		name := filepath.Base(f)
+		uploads = append(uploads, airGapFile{name, path.Join(airGapImagesDir, name), hosts.TransferOptions{Mode: 0644, Sudo: true}})
+	}
+
+	return withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		if _, err := tunnel.Output(fmt.Sprintf(airGapUploadDirCmd, airGapUploadDir)); err != nil {
+			return fmt.Errorf("[cluster] failed to create directory %s on node %s: %w", airGapUploadDir, node.InstanceID, err)
+		}
+		for _, u := range uploads {
+			logger.Infof("[cluster] uploading %s to node %s...\n", u.name, node.InstanceID)
+			if err := tunnel.UploadContext(ctx, filepath.Join(cluster.AirGapDir, u.name), u.target, u.opts); err != nil {
+				return fmt.Errorf("[cluster] failed to upload %s to node %s: %w", u.name, node.InstanceID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkAirGapVersion checks the k3s binary in the air-gap directory is in the version, because the binary is installed
+// instead of downloading the version in air-gap mode, it's run on the node as it may not be runnable locally.
+func checkAirGapVersion(ctx context.Context, cluster *types.Cluster, node types.Node, version string) error {
+	if cluster.AirGapDir == "" {
+		return nil
+	}
+	remote := path.Join(airGapUploadDir, airGapBinary)
+	out := ""
+	if err := withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		if _, err := tunnel.Output(fmt.Sprintf(airGapUploadDirCmd, airGapUploadDir)); err != nil {
+			return err
+		}
+		if err := tunnel.UploadContext(ctx, filepath.Join(cluster.AirGapDir, airGapBinary), remote, hosts.TransferOptions{Mode: 0700, Sudo: true}); err != nil {
+			return err
+		}
+		defer func() {
+			_, _ = tunnel.Output("sudo rm -f " + remote)
+		}()
+		var err error
+		out, err = tunnel.Output("sudo " + remote + " --version")
+		return err
+	}); err != nil {
+		return fmt.Errorf("[cluster] failed to check version of k3s binary in air-gap directory %s: %w", cluster.AirGapDir, err)
+	}
+
+	// the output is in the format of `k3s version v1.19.5+k3s1 (b11612e2)`.
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[2] != version {
+		return fmt.Errorf("[cluster] version of k3s binary in air-gap directory %s is not %s: %s", cluster.AirGapDir, version, out)
+	}
+	return nil
+}
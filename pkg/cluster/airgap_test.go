@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+// setupAirGap returns the air-gap directory with k3s binary, install script and images tarball,
+// the node dialed through the fake ssh server, and the func which removes the directory and stops the server.
+func setupAirGap(t *testing.T) (string, *fake.SSHServer, types.Node, func()) {
+	dir, err := ioutil.TempDir("", "autok3s-airgap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		airGapBinary:                  "k3s",
+		airGapInstallScript:           "#!/bin/sh",
+		"k3s-airgap-images-amd64.tar": "images",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := fake.NewSSHServer()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	logger = fake.Logger()
+	node := types.Node{
+		SSH:             types.SSH{User: "ubuntu", Port: "22", Password: "fake", Bastions: []types.Bastion{server.Bastion()}},
+		InstanceID:      "fake",
+		PublicIPAddress: []string{"203.0.113.1"},
+	}
+	return dir, server, node, func() {
+		_ = server.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestPrepareAirGap(t *testing.T) {
+	dir, server, node, cleanup := setupAirGap(t)
+	defer cleanup()
+
+	cluster := &types.Cluster{Metadata: types.Metadata{AirGapDir: dir}}
+	if err := prepareAirGap(context.Background(), cluster, node); err != nil {
+		t.Fatal(err)
+	}
+	n := server.Node(node.PublicIPAddress[0])
+	commands := n.Commands()
+	if len(commands) == 0 || commands[0] != "sudo install -d -m 0700 -o root -g root "+airGapUploadDir {
+		t.Errorf("the upload directory is not created as a private directory of root before uploading: %v", commands)
+	}
+	for file, mode := range map[string]os.FileMode{
+		airGapBinaryPath: 0755,
+		airGapUploadDir + "/" + airGapInstallScript:           0600,
+		airGapImagesDir + "/" + "k3s-airgap-images-amd64.tar": 0644,
+	} {
+		if _, ok := n.ReadFile(file); !ok {
+			t.Errorf("%s is not uploaded", file)
+		} else if n.FileMode(file) != mode {
+			t.Errorf("mode of %s is %v, expected %v", file, n.FileMode(file), mode)
+		}
+	}
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "install -m") && !strings.HasPrefix(cmd, "sudo ") {
+			t.Errorf("file is installed without sudo: %s", cmd)
+		}
+	}
+	if script := installScript(cluster); script != "sudo cat "+airGapUploadDir+"/"+airGapInstallScript {
+		t.Errorf("unexpected install script command %s", script)
+	}
+}
+
+func TestCheckAirGapVersion(t *testing.T) {
+	dir, server, node, cleanup := setupAirGap(t)
+	defer cleanup()
+	server.Handle(func(n *fake.Node, cmd string) (string, uint32) {
+		if cmd == "sudo "+airGapUploadDir+"/"+airGapBinary+" --version" {
+			return "k3s version v1.19.5+k3s1 (b11612e2)\n", 0
+		}
+		return n.Run(cmd)
+	})
+
+	cluster := &types.Cluster{Metadata: types.Metadata{AirGapDir: dir}}
+	if err := checkAirGapVersion(context.Background(), cluster, node, "v1.19.5+k3s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAirGapVersion(context.Background(), cluster, node, "v1.20.0+k3s1"); err == nil {
+		t.Error("expected error of mismatched version")
+	}
+	n := server.Node(node.PublicIPAddress[0])
+	if _, ok := n.ReadFile(airGapUploadDir + "/" + airGapBinary); ok {
+		t.Error("k3s binary to check is not removed")
+	}
+	for _, cmd := range n.Commands() {
+		if strings.HasSuffix(cmd, "--version") && !strings.HasPrefix(cmd, "sudo "+airGapUploadDir) {
+			t.Errorf("k3s binary is run out of the upload directory: %s", cmd)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	masterService         = "k3s"
+	workerService         = "k3s-agent"
+	serviceActive         = "active"
+	serviceStatusCommand  = "sudo systemctl is-active %s || true"
+	serviceRestartCommand = "sudo systemctl restart %s"
+	serviceTokenCommand   = "sudo grep '^K3S_TOKEN=' /etc/systemd/system/%s.service.env || true"
+	nodeStatusReady       = "Ready"
+	nodeStatusNotReady    = "NotReady"
+	nodeStatusMissing     = "NotRegistered"
+	statusUnknown         = "Unknown"
+	instanceStatusRunning = "running"
+)
+
+// CheckK3sCluster checks instance status, node status, k3s service and token of every node.
+func CheckK3sCluster(merged *types.Cluster) ([]types.NodeCheck, error) {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing check k3s cluster logic...\n", merged.Provider)
+
+	// the kube api server may be unavailable, node status will be unknown in that case.
+	client, err := GetClusterConfig(merged.Name, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
+	if err != nil {
+		logger.Warnf("[%s] failed to get kube client of cluster %s: %v\n", merged.Provider, merged.Name, err)
+		client = nil
+	}
+
+	nodes := make([]types.Node, 0, len(merged.MasterNodes)+len(merged.WorkerNodes))
+	nodes = append(nodes, merged.MasterNodes...)
+	nodes = append(nodes, merged.WorkerNodes...)
+
+	reports := make([]types.NodeCheck, 0, len(nodes))
+	for _, node := range nodes {
+		reports = append(reports, checkNode(client, merged, node))
+	}
+
+	logger.Infof("[%s] successfully executed check k3s cluster logic\n", merged.Provider)
+	return reports, nil
+}
+
+// RepairK3sCluster restarts k3s services or re-joins nodes with problems, then checks the cluster again.
+// stopped instances must be started by provider before calling this.
+func RepairK3sCluster(merged *types.Cluster, reports []types.NodeCheck) ([]types.NodeCheck, error) {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing repair k3s cluster logic...\n", merged.Provider)
+
+	p, err := providers.GetProvider(merged.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	repaired := false
+	for _, report := range reports {
+		if len(report.Problems) == 0 {
+			continue
+		}
+		node, ok := findClusterNode(merged, report.InstanceID)
+		if !ok || !strings.EqualFold(node.InstanceStatus, instanceStatusRunning) {
+			logger.Warnf("[%s] node %s is not running, skip repairing\n", merged.Provider, report.InstanceID)
+			continue
+		}
+
+		// the first master can not be re-joined, or it will be separated from others.
+		primary := node.Master && len(node.InternalIPAddress) > 0 && node.InternalIPAddress[0] == merged.IP
+		rejoin := !report.TokenMatched || report.ServiceStatus == statusUnknown || report.NodeStatus == nodeStatusMissing
+		if rejoin && !primary {
+			logger.Infof("[%s] re-joining node %s...\n", merged.Provider, node.InstanceID)
+			// files uploaded in air-gap mode may be cleaned up, e.g. node is rebooted.
+			if err := prepareAirGap(context.Background(), merged, node); err != nil {
+				logger.Errorf("[%s] failed to re-join node %s: %v\n", merged.Provider, node.InstanceID, err)
+				continue
+			}
+			cmd := genInstallCommand(p, merged, node, merged.K3sVersion, merged.K3sChannel)
+			logger.Debugf("[cluster] k3s re-join command: %s\n", cmd)
+			if _, err := execute(&hosts.Host{Node: node}, []string{cmd}); err != nil {
+				logger.Errorf("[%s] failed to re-join node %s: %v\n", merged.Provider, node.InstanceID, err)
+				continue
+			}
+		} else {
+			logger.Infof("[%s] restarting k3s service on node %s...\n", merged.Provider, node.InstanceID)
+			if _, err := execute(&hosts.Host{Node: node}, []string{fmt.Sprintf(serviceRestartCommand, getServiceName(node))}); err != nil {
+				logger.Errorf("[%s] failed to restart k3s service on node %s: %v\n", merged.Provider, node.InstanceID, err)
+				continue
+			}
+		}
+		repaired = true
+	}
+
+	if repaired {
+		// wait for repaired nodes to be ready, the result will be reported by the next check anyway.
+		_ = utils.WaitFor(func() (bool, error) {
+			checks, err := CheckK3sCluster(merged)
+			if err != nil {
+				return false, nil
+			}
+			for _, c := range checks {
+				if strings.EqualFold(c.InstanceStatus, instanceStatusRunning) && len(c.Problems) > 0 {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+	}
+
+	logger.Infof("[%s] successfully executed repair k3s cluster logic\n", merged.Provider)
+	return CheckK3sCluster(merged)
+}
+
+func checkNode(client *kubernetes.Clientset, merged *types.Cluster, node types.Node) types.NodeCheck {
+	report := types.NodeCheck{
+		InstanceID:     node.InstanceID,
+		Master:         node.Master,
+		InstanceStatus: node.InstanceStatus,
+		NodeStatus:     statusUnknown,
+		ServiceStatus:  statusUnknown,
+		Problems:       make([]string, 0),
+	}
+
+	if client != nil {
+		kubeNode, err := getKubeNode(client, node)
+		if err == nil {
+			report.NodeStatus = getKubeNodeStatus(kubeNode)
+		}
+	}
+	switch report.NodeStatus {
+	case nodeStatusMissing:
+		report.Problems = append(report.Problems, "node is not registered in k3s cluster")
+	case nodeStatusNotReady:
+		report.Problems = append(report.Problems, "node is not ready")
+	}
+
+	if !strings.EqualFold(node.InstanceStatus, instanceStatusRunning) {
+		report.Problems = append(report.Problems, fmt.Sprintf("instance is %s", strings.ToLower(node.InstanceStatus)))
+		return report
+	}
+
+	service := getServiceName(node)
+	out, err := execute(&hosts.Host{Node: node}, []string{fmt.Sprintf(serviceStatusCommand, service), fmt.Sprintf(serviceTokenCommand, service)})
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("failed to connect node: %v", err))
+		return report
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if status := strings.TrimSpace(lines[0]); status != "" {
+		report.ServiceStatus = status
+	}
+	if report.ServiceStatus != serviceActive {
+		report.Problems = append(report.Problems, fmt.Sprintf("%s service is %s", service, report.ServiceStatus))
+	}
+
+	token := ""
+	if len(lines) > 1 {
+		token = strings.Trim(strings.TrimPrefix(strings.TrimSpace(lines[1]), "K3S_TOKEN="), "'\"")
+	}
+	report.TokenMatched = token == merged.Token
+	if !report.TokenMatched {
+		report.Problems = append(report.Problems, "k3s token mismatch")
+	}
+
+	return report
+}
+
+func getKubeNodeStatus(node *v1.Node) string {
+	if node == nil {
+		return nodeStatusMissing
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady {
+			if c.Status == v1.ConditionTrue {
+				return nodeStatusReady
+			}
+			return nodeStatusNotReady
+		}
+	}
+	return statusUnknown
+}
+
+func getServiceName(node types.Node) string {
+	if node.Master {
+		return masterService
+	}
+	return workerService
+}
+
+func findClusterNode(merged *types.Cluster, instanceID string) (types.Node, bool) {
+	for _, nodes := range [][]types.Node{merged.MasterNodes, merged.WorkerNodes} {
+		for _, node := range nodes {
+			if node.InstanceID == instanceID {
+				return node, true
+			}
+		}
+	}
+	return types.Node{}, false
+}
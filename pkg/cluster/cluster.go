@@ -3,14 +3,13 @@ package cluster
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/cnrancher/autok3s/pkg/common"
@@ -19,6 +18,10 @@ import (
 	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/types/alibaba"
 	"github.com/cnrancher/autok3s/pkg/types/aws"
+	"github.com/cnrancher/autok3s/pkg/types/azure"
+	"github.com/cnrancher/autok3s/pkg/types/digitalocean"
+	"github.com/cnrancher/autok3s/pkg/types/google"
+	"github.com/cnrancher/autok3s/pkg/types/hetzner"
 	"github.com/cnrancher/autok3s/pkg/types/tencent"
 	"github.com/cnrancher/autok3s/pkg/utils"
 
@@ -27,29 +30,35 @@ import (
 	"github.com/sirupsen/logrus"
 	yamlv3 "gopkg.in/yaml.v3"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kubectl/pkg/cmd/config"
+	"k8s.io/kubectl/pkg/drain"
 	"k8s.io/kubectl/pkg/scheme"
 )
 
 var (
 	logger                 *logrus.Logger
-	initCommand            = "curl -sLS %s | %s K3S_TOKEN='%s' INSTALL_K3S_EXEC='server --tls-san %s --node-external-ip %s %s' %s sh -"
-	joinCommand            = "curl -sLS %s | %s K3S_URL='https://%s:6443' K3S_TOKEN='%s' INSTALL_K3S_EXEC='%s' %s sh -"
+	initCommand            = "%s | %s K3S_TOKEN='%s' INSTALL_K3S_EXEC='server --tls-san %s --node-external-ip %s %s' %s sh -"
+	joinCommand            = "%s | %s K3S_URL='https://%s:6443' K3S_TOKEN='%s' INSTALL_K3S_EXEC='%s' %s sh -"
 	getTokenCommand        = "sudo cat /var/lib/rancher/k3s/server/node-token"
 	catCfgCommand          = "sudo cat /etc/rancher/k3s/k3s.yaml"
+	masterReadyCommand     = "sudo k3s kubectl get --raw=/readyz"
 	dockerCommand          = "curl http://rancher-mirror.cnrancher.com/autok3s/docker-install.sh | sh -s - %s"
-	deployUICommand        = "echo \"%s\" | base64 -d | sudo tee \"%s/ui.yaml\""
+	uiManifest             = "ui.yaml"
 	masterUninstallCommand = "sh /usr/local/bin/k3s-uninstall.sh"
 	workerUninstallCommand = "sh /usr/local/bin/k3s-agent-uninstall.sh"
 	registryPath           = "/etc/rancher/k3s"
+	registryFile           = registryPath + "/registries.yaml"
+	drainTimeout           = 5 * time.Minute
 )
 
-func InitK3sCluster(cluster *types.Cluster) error {
+func InitK3sCluster(ctx context.Context, cluster *types.Cluster) error {
 	if cluster.Logger != nil {
 		logger = cluster.Logger
 	} else {
@@ -61,9 +70,19 @@ func InitK3sCluster(cluster *types.Cluster) error {
 	if err != nil {
 		return err
 	}
+	if err := CheckAirGap(cluster.AirGapDir); err != nil {
+		return err
+	}
+	nodes := make([]types.Node, 0, len(cluster.MasterNodes)+len(cluster.WorkerNodes))
+	nodes = append(nodes, cluster.MasterNodes...)
+	nodes = append(nodes, cluster.WorkerNodes...)
+	if _, err := PreflightK3sNodes(ctx, cluster, nodes); err != nil {
+		return err
+	}
+	ReportPhase(ctx, types.PhaseInstalling)
 
-	k3sScript := cluster.InstallScript
-	k3sMirror := cluster.Mirror
+	k3sScript := installScript(cluster)
+	k3sMirror := installEnv(cluster)
 	dockerMirror := cluster.DockerMirror
 
 	if cluster.Token == "" {
@@ -81,8 +100,10 @@ func InitK3sCluster(cluster *types.Cluster) error {
 	publicIP := cluster.IP
 	if cluster.IP == "" {
 		cluster.IP = cluster.MasterNodes[0].InternalIPAddress[0]
-		publicIP = cluster.MasterNodes[0].PublicIPAddress[0]
+		publicIP = cluster.MasterNodes[0].ExternalIP()
 	}
+	// the ip may be of a load balancer, so the master initialized the cluster is recorded by its instance id.
+	cluster.Status.InitMaster = cluster.MasterNodes[0].InstanceID
 
 	masterExtraArgs := cluster.MasterExtraArgs
 	workerExtraArgs := cluster.WorkerExtraArgs
@@ -114,70 +135,84 @@ func InitK3sCluster(cluster *types.Cluster) error {
 	if cluster.DockerScript != "" {
 		dockerCommand = cluster.DockerScript
 	}
-	if err := initMaster(k3sScript, k3sMirror, dockerMirror, publicIP, master0ExtraArgs, cluster, cluster.MasterNodes[0]); err != nil {
+	master0 := nodeTask{name: fmt.Sprintf("master-%d(%s)", 1, cluster.MasterNodes[0].InstanceID), node: cluster.MasterNodes[0]}
+	reportNode(ctx, master0.progress(types.OperationRunning, nil))
+	if err := initMaster(ctx, k3sScript, k3sMirror, dockerMirror, publicIP, master0ExtraArgs, cluster, cluster.MasterNodes[0]); err != nil {
+		reportNode(ctx, master0.progress(types.OperationFailed, err))
 		return err
 	}
+	reportNode(ctx, master0.progress(types.OperationSucceeded, nil))
 	logger.Infof("[%s] successfully created k3s master-%d\n", cluster.Provider, 1)
 
+	// additional masters and workers join the first master concurrently.
+	tasks := make([]nodeTask, 0, len(cluster.MasterNodes)+len(cluster.WorkerNodes))
 	for i, master := range cluster.MasterNodes {
 		// skip first master nodes
 		if i == 0 {
 			continue
 		}
-		logger.Infof("[%s] creating k3s master-%d...\n", cluster.Provider, i+1)
+		i, master := i, master
 		masterNExtraArgs := masterExtraArgs
 		providerExtraArgs := p.GenerateMasterExtraArgs(cluster, master)
 		if providerExtraArgs != "" {
 			masterNExtraArgs += providerExtraArgs
 		}
-		if err := initAdditionalMaster(k3sScript, k3sMirror, dockerMirror, publicIP, masterNExtraArgs, cluster, master); err != nil {
-			return err
-		}
-		logger.Infof("[%s] successfully created k3s master-%d\n", cluster.Provider, i+1)
+		tasks = append(tasks, nodeTask{
+			name: fmt.Sprintf("master-%d(%s)", i+1, master.InstanceID),
+			node: master,
+			run: func(ctx context.Context) error {
+				logger.Infof("[%s] creating k3s master-%d...\n", cluster.Provider, i+1)
+				if err := initAdditionalMaster(ctx, k3sScript, k3sMirror, dockerMirror, publicIP, masterNExtraArgs, cluster, master); err != nil {
+					return err
+				}
+				logger.Infof("[%s] successfully created k3s master-%d\n", cluster.Provider, i+1)
+				return nil
+			},
+		})
 	}
 
-	workerErrChan := make(chan error)
-	workerWaitGroupDone := make(chan bool)
-	workerWaitGroup := &sync.WaitGroup{}
-	workerWaitGroup.Add(len(cluster.WorkerNodes))
-
 	for i, worker := range cluster.WorkerNodes {
-		go func(i int, worker types.Node) {
-			logger.Infof("[%s] creating k3s worker-%d...\n", cluster.Provider, i+1)
-			extraArgs := workerExtraArgs
-			providerExtraArgs := p.GenerateWorkerExtraArgs(cluster, worker)
-			if providerExtraArgs != "" {
-				extraArgs += providerExtraArgs
-			}
-			initWorker(workerWaitGroup, workerErrChan, k3sScript, k3sMirror, dockerMirror, extraArgs, cluster, worker)
-			logger.Infof("[%s] successfully created k3s worker-%d\n", cluster.Provider, i+1)
-		}(i, worker)
+		i, worker := i, worker
+		extraArgs := workerExtraArgs
+		providerExtraArgs := p.GenerateWorkerExtraArgs(cluster, worker)
+		if providerExtraArgs != "" {
+			extraArgs += providerExtraArgs
+		}
+		tasks = append(tasks, nodeTask{
+			name: fmt.Sprintf("worker-%d(%s)", i+1, worker.InstanceID),
+			node: worker,
+			run: func(ctx context.Context) error {
+				logger.Infof("[%s] creating k3s worker-%d...\n", cluster.Provider, i+1)
+				if err := initWorker(ctx, k3sScript, k3sMirror, dockerMirror, extraArgs, cluster, worker); err != nil {
+					return err
+				}
+				logger.Infof("[%s] successfully created k3s worker-%d\n", cluster.Provider, i+1)
+				return nil
+			},
+		})
 	}
 
-	go func() {
-		workerWaitGroup.Wait()
-		close(workerWaitGroupDone)
-	}()
-
-	select {
-	case <-workerWaitGroupDone:
-		break
-	case err := <-workerErrChan:
-		return err
+	if len(tasks) > 0 {
+		if err := waitForMaster(ctx, cluster.MasterNodes[0]); err != nil {
+			return err
+		}
+		if err := runNodeTasks(ctx, tasks); err != nil {
+			return err
+		}
 	}
 
 	// get k3s cluster config.
-	cfg, err := execute(&hosts.Host{Node: cluster.MasterNodes[0]}, []string{catCfgCommand})
+	cfg, err := executeWithContext(ctx, &hosts.Host{Node: cluster.MasterNodes[0]}, []string{catCfgCommand})
 	if err != nil {
 		return err
 	}
 
 	logger.Infof("[%s] deploying additional manifests\n", cluster.Provider)
+	ReportPhase(ctx, types.PhaseDeploying)
 
 	// deploy additional UI manifests.
 	if cluster.UI {
-		if _, err := execute(&hosts.Host{Node: cluster.MasterNodes[0]}, []string{fmt.Sprintf(deployUICommand,
-			base64.StdEncoding.EncodeToString([]byte(dashboardTmpl)), common.K3sManifestsDir)}); err != nil {
+		if err := uploadContent(ctx, cluster.MasterNodes[0], []byte(dashboardTmpl), path.Join(common.K3sManifestsDir, uiManifest), 0644); err != nil {
 			return err
 		}
 	}
@@ -192,18 +227,15 @@ func InitK3sCluster(cluster *types.Cluster) error {
 	cluster.Status.Status = common.StatusRunning
 
 	// write current cluster to state file.
-	// native provider no need to operate .state file.
-	if p.GetProviderName() != "native" {
-		if err := SaveState(cluster); err != nil {
-			return err
-		}
+	if err := SaveState(cluster); err != nil {
+		return err
 	}
 
 	logger.Infof("[%s] successfully executed init k3s cluster logic\n", cluster.Provider)
 	return nil
 }
 
-func JoinK3sNode(merged, added *types.Cluster) error {
+func JoinK3sNode(ctx context.Context, merged, added *types.Cluster) error {
 	if merged.Logger != nil {
 		logger = merged.Logger
 	} else {
@@ -216,8 +248,18 @@ func JoinK3sNode(merged, added *types.Cluster) error {
 	if err != nil {
 		return err
 	}
-	k3sScript := merged.InstallScript
-	k3sMirror := merged.Mirror
+	if err := CheckAirGap(merged.AirGapDir); err != nil {
+		return err
+	}
+	nodes := make([]types.Node, 0, len(added.MasterNodes)+len(added.WorkerNodes))
+	nodes = append(nodes, added.MasterNodes...)
+	nodes = append(nodes, added.WorkerNodes...)
+	if _, err := PreflightK3sNodes(ctx, merged, nodes); err != nil {
+		return err
+	}
+	ReportPhase(ctx, types.PhaseInstalling)
+	k3sScript := installScript(merged)
+	k3sMirror := installEnv(merged)
 	dockerMirror := merged.DockerMirror
 
 	if merged.IP == "" {
@@ -236,7 +278,7 @@ func JoinK3sNode(merged, added *types.Cluster) error {
 			serverNode = added.WorkerNodes[0]
 		}
 		serverNode.PublicIPAddress = []string{merged.IP}
-		token, err := execute(&hosts.Host{Node: serverNode}, []string{getTokenCommand})
+		token, err := executeWithContext(ctx, &hosts.Host{Node: serverNode}, []string{getTokenCommand})
 		if err != nil {
 			return err
 		}
@@ -250,24 +292,28 @@ func JoinK3sNode(merged, added *types.Cluster) error {
 	if merged.DockerScript != "" {
 		dockerCommand = merged.DockerScript
 	}
-	errChan := make(chan error)
-	waitGroupDone := make(chan bool)
-	waitGroup := &sync.WaitGroup{}
-	waitGroup.Add(len(added.WorkerNodes))
-
+	tasks := make([]nodeTask, 0, len(added.Status.MasterNodes)+len(added.Status.WorkerNodes))
 	for i := 0; i < len(added.Status.MasterNodes); i++ {
 		for _, full := range merged.MasterNodes {
 			extraArgs := merged.MasterExtraArgs
 			if added.Status.MasterNodes[i].InstanceID == full.InstanceID {
-				logger.Infof("[%s] joining k3s master-%d...\n", merged.Provider, i+1)
+				i, full := i, full
 				additionalExtraArgs := p.GenerateMasterExtraArgs(added, full)
 				if additionalExtraArgs != "" {
 					extraArgs += additionalExtraArgs
 				}
-				if err := joinMaster(k3sScript, k3sMirror, dockerMirror, extraArgs, merged, full); err != nil {
-					return err
-				}
-				logger.Infof("[%s] successfully joined k3s master-%d\n", merged.Provider, i+1)
+				tasks = append(tasks, nodeTask{
+					name: fmt.Sprintf("master-%d(%s)", i+1, full.InstanceID),
+					node: full,
+					run: func(ctx context.Context) error {
+						logger.Infof("[%s] joining k3s master-%d...\n", merged.Provider, i+1)
+						if err := joinMaster(ctx, k3sScript, k3sMirror, dockerMirror, extraArgs, merged, full); err != nil {
+							return err
+						}
+						logger.Infof("[%s] successfully joined k3s master-%d\n", merged.Provider, i+1)
+						return nil
+					},
+				})
 				break
 			}
 		}
@@ -277,29 +323,29 @@ func JoinK3sNode(merged, added *types.Cluster) error {
 		for _, full := range merged.WorkerNodes {
 			extraArgs := merged.WorkerExtraArgs
 			if added.Status.WorkerNodes[i].InstanceID == full.InstanceID {
-				go func(i int, full types.Node) {
-					logger.Infof("[%s] joining k3s worker-%d...\n", merged.Provider, i+1)
-					additionalExtraArgs := p.GenerateWorkerExtraArgs(added, full)
-					if additionalExtraArgs != "" {
-						extraArgs += additionalExtraArgs
-					}
-					joinWorker(waitGroup, errChan, k3sScript, k3sMirror, dockerMirror, extraArgs, merged, full)
-					logger.Infof("[%s] successfully joined k3s worker-%d\n", merged.Provider, i+1)
-				}(i, full)
+				i, full := i, full
+				additionalExtraArgs := p.GenerateWorkerExtraArgs(added, full)
+				if additionalExtraArgs != "" {
+					extraArgs += additionalExtraArgs
+				}
+				tasks = append(tasks, nodeTask{
+					name: fmt.Sprintf("worker-%d(%s)", i+1, full.InstanceID),
+					node: full,
+					run: func(ctx context.Context) error {
+						logger.Infof("[%s] joining k3s worker-%d...\n", merged.Provider, i+1)
+						if err := joinWorker(ctx, k3sScript, k3sMirror, dockerMirror, extraArgs, merged, full); err != nil {
+							return err
+						}
+						logger.Infof("[%s] successfully joined k3s worker-%d\n", merged.Provider, i+1)
+						return nil
+					},
+				})
 				break
 			}
 		}
 	}
 
-	go func() {
-		waitGroup.Wait()
-		close(waitGroupDone)
-	}()
-
-	select {
-	case <-waitGroupDone:
-		break
-	case err := <-errChan:
+	if err := runNodeTasks(ctx, tasks); err != nil {
 		return err
 	}
 
@@ -308,11 +354,8 @@ func JoinK3sNode(merged, added *types.Cluster) error {
 	merged.Worker = strconv.Itoa(len(merged.WorkerNodes))
 
 	// write current cluster to state file.
-	// native provider no need to operate .state file.
-	if p.GetProviderName() != "native" {
-		if err := SaveState(merged); err != nil {
-			return nil
-		}
+	if err := SaveState(merged); err != nil {
+		return nil
 	}
 
 	logger.Infof("[%s] successfully executed join k3s node logic\n", merged.Provider)
@@ -323,14 +366,14 @@ func SSHK3sNode(ip string, cluster *types.Cluster, ssh *types.SSH) error {
 	var node types.Node
 
 	for _, n := range cluster.Status.MasterNodes {
-		if n.PublicIPAddress[0] == ip {
+		if n.ExternalIP() == ip {
 			node = n
 			break
 		}
 	}
 
 	for _, n := range cluster.Status.WorkerNodes {
-		if n.PublicIPAddress[0] == ip {
+		if n.ExternalIP() == ip {
 			node = n
 			break
 		}
@@ -363,7 +406,10 @@ func SSHK3sNode(ip string, cluster *types.Cluster, ssh *types.SSH) error {
 	if ssh.SSHAgentAuth {
 		node.SSH.SSHAgentAuth = ssh.SSHAgentAuth
 	}
-	if node.PublicIPAddress == nil {
+	if len(ssh.Bastions) > 0 {
+		node.SSH.Bastions = ssh.Bastions
+	}
+	if node.ExternalIP() == "" {
 		node.PublicIPAddress = []string{ip}
 	}
 
@@ -381,19 +427,14 @@ func SSHK3sNode(ip string, cluster *types.Cluster, ssh *types.SSH) error {
 
 func ReadFromState(cluster *types.Cluster) ([]types.Cluster, error) {
 	r := make([]types.Cluster, 0)
-	v := common.CfgPath
-	if v == "" {
-		return r, errors.New("[cluster] cfg path is empty")
-	}
-
-	clusters, err := utils.ReadYaml(v, common.StateFile)
+	store, err := GetStateStore()
 	if err != nil {
 		return r, err
 	}
 
-	converts, err := ConvertToClusters(clusters)
+	converts, err := store.List()
 	if err != nil {
-		return r, fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
+		return r, err
 	}
 
 	for _, c := range converts {
@@ -412,6 +453,28 @@ func ReadFromState(cluster *types.Cluster) ([]types.Cluster, error) {
 			if option, ok := cluster.Options.(aws.Options); ok {
 				name = fmt.Sprintf("%s.%s.%s", cluster.Name, option.Region, cluster.Provider)
 			}
+		case "azure":
+			if option, ok := cluster.Options.(azure.Options); ok {
+				name = fmt.Sprintf("%s.%s.%s", cluster.Name, option.Location, cluster.Provider)
+			}
+		case "digitalocean":
+			if option, ok := cluster.Options.(digitalocean.Options); ok {
+				name = fmt.Sprintf("%s.%s.%s", cluster.Name, option.Region, cluster.Provider)
+			}
+		case "google":
+			if option, ok := cluster.Options.(google.Options); ok {
+				name = fmt.Sprintf("%s.%s.%s", cluster.Name, option.Region, cluster.Provider)
+			}
+		case "hetzner":
+			if option, ok := cluster.Options.(hetzner.Options); ok {
+				name = fmt.Sprintf("%s.%s.%s", cluster.Name, option.Location, cluster.Provider)
+			}
+		case "docker":
+			// containers always run on the local docker host.
+			name = fmt.Sprintf("%s.local.%s", cluster.Name, cluster.Provider)
+		case "libvirt":
+			// virtual machines always run on the local hypervisor.
+			name = fmt.Sprintf("%s.local.%s", cluster.Name, cluster.Provider)
 		}
 
 		if c.Provider == cluster.Provider && c.Name == name {
@@ -422,50 +485,16 @@ func ReadFromState(cluster *types.Cluster) ([]types.Cluster, error) {
 	return r, nil
 }
 
-func AppendToState(cluster *types.Cluster) ([]types.Cluster, error) {
-	v := common.CfgPath
-	if v == "" {
-		return nil, errors.New("[cluster] cfg path is empty")
-	}
-
-	clusters, err := utils.ReadYaml(v, common.StateFile)
-	if err != nil {
-		return nil, err
-	}
-
-	converts, err := ConvertToClusters(clusters)
-	if err != nil {
-		return nil, fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
-	}
-
-	index := -1
-
-	for i, c := range converts {
-		if c.Provider == cluster.Provider && c.Name == cluster.Name {
-			index = i
-		}
-	}
-
-	if index > -1 {
-		converts[index] = *cluster
-	} else {
-		converts = append(converts, *cluster)
-	}
-
-	return converts, nil
-}
-
 func DeleteState(name string, provider string) error {
-	v := common.CfgPath
-	if v == "" {
-		return errors.New("[cluster] cfg path is empty")
-	}
-
-	r, err := deleteClusterFromState(name, provider)
+	store, err := GetStateStore()
 	if err != nil {
 		return err
 	}
-	return utils.WriteYaml(r, v, common.StateFile)
+	err = store.Delete(name, provider)
+	// failed clusters are only saved in status files and not found in state,
+	// so the removal is published anyway once their resources are deleted.
+	publishState(types.ClusterEventRemove, name, provider, "")
+	return err
 }
 
 func UninstallK3sNodes(nodes []types.Node) (warnMsg []string) {
@@ -486,6 +515,55 @@ func UninstallK3sNodes(nodes []types.Node) (warnMsg []string) {
 	return
 }
 
+func RemoveK3sNode(merged *types.Cluster, node types.Node) error {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing remove k3s node logic\n", merged.Provider)
+
+	if node.Master {
+		if len(merged.MasterNodes) <= 1 {
+			return fmt.Errorf("[cluster] can not remove the last master node %s", node.InstanceID)
+		}
+		if len(node.InternalIPAddress) > 0 && node.InternalIPAddress[0] == merged.IP {
+			return fmt.Errorf("[cluster] can not remove master node %s which is used as the cluster server address", node.InstanceID)
+		}
+	}
+
+	client, err := GetClusterConfig(merged.Name, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
+	if err != nil {
+		return err
+	}
+
+	kubeNode, err := getKubeNode(client, node)
+	if err != nil {
+		return err
+	}
+
+	if kubeNode != nil {
+		logger.Infof("[%s] draining k3s node %s...\n", merged.Provider, kubeNode.Name)
+		if err := drainNode(client, kubeNode); err != nil {
+			return err
+		}
+		if err := client.CoreV1().Nodes().Delete(context.TODO(), kubeNode.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("[cluster] failed to delete k3s node %s: %v", kubeNode.Name, err)
+		}
+		logger.Infof("[%s] successfully deleted k3s node %s\n", merged.Provider, kubeNode.Name)
+	} else {
+		logger.Warnf("[%s] node %s is not registered in k3s cluster, skip draining\n", merged.Provider, node.InstanceID)
+	}
+
+	for _, msg := range UninstallK3sNodes([]types.Node{node}) {
+		logger.Warnln(msg)
+	}
+
+	logger.Infof("[%s] successfully executed remove k3s node logic\n", merged.Provider)
+	return nil
+}
+
 func ConvertToClusters(origin []interface{}) ([]types.Cluster, error) {
 	result := make([]types.Cluster, 0)
 
@@ -503,16 +581,11 @@ func ConvertToClusters(origin []interface{}) ([]types.Cluster, error) {
 }
 
 func SaveState(cluster *types.Cluster) error {
-	v := common.CfgPath
-	if v == "" {
-		return errors.New("[cluster] cfg path is empty")
-	}
-	r, err := AppendToState(cluster)
+	store, err := GetStateStore()
 	if err != nil {
 		return err
 	}
-
-	return utils.WriteYaml(r, v, common.StateFile)
+	return store.Save(cluster)
 }
 
 func SaveCfg(cfg, ip, context string) error {
@@ -562,31 +635,43 @@ func OverwriteCfg(context string) error {
 	return clientcmd.WriteToFile(*c, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
 }
 
-func DeployExtraManifest(cluster *types.Cluster, cmds []string) error {
-	if _, err := execute(&hosts.Host{Node: cluster.MasterNodes[0]}, cmds); err != nil {
+// DeployManifest writes the manifest to the auto-deploying manifests folder of k3s on the first master,
+// it's uploaded as a file only readable by root because it may contain credentials of cloud providers.
+func DeployManifest(ctx context.Context, cluster *types.Cluster, name string, manifest []byte) error {
+	ReportPhase(ctx, types.PhaseDeploying)
+	return uploadContent(ctx, cluster.MasterNodes[0], manifest, path.Join(common.K3sManifestsDir, name), 0600)
+}
+
+func DeployExtraManifest(ctx context.Context, cluster *types.Cluster, cmds []string) error {
+	ReportPhase(ctx, types.PhaseDeploying)
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: cluster.MasterNodes[0]}, cmds); err != nil {
 		return err
 	}
 	return nil
 }
 
-func initMaster(k3sScript, k3sMirror, dockerMirror, ip, extraArgs string, cluster *types.Cluster, master types.Node) error {
+func initMaster(ctx context.Context, k3sScript, k3sMirror, dockerMirror, ip, extraArgs string, cluster *types.Cluster, master types.Node) error {
 	if strings.Contains(extraArgs, "--docker") {
 		logger.Debugf("[cluster] install docker command %s", fmt.Sprintf(dockerCommand, dockerMirror))
-		if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
+		if _, err := executeWithContext(ctx, &hosts.Host{Node: master}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
 			return err
 		}
 	}
 
 	if cluster.Registry != "" {
-		if err := handleRegistry(&hosts.Host{Node: master}, cluster.Registry); err != nil {
+		if err := handleRegistry(ctx, master, cluster.Registry); err != nil {
 			return err
 		}
 	}
 
+	if err := prepareAirGap(ctx, cluster, master); err != nil {
+		return err
+	}
+
 	logger.Debugf("[cluster] k3s master command: %s\n", fmt.Sprintf(initCommand, k3sScript, k3sMirror, cluster.Token,
 		ip, ip, strings.TrimSpace(extraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel)))
 
-	if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(initCommand, k3sScript, k3sMirror,
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: master}, []string{fmt.Sprintf(initCommand, k3sScript, k3sMirror,
 		cluster.Token, ip, ip, strings.TrimSpace(extraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel))}); err != nil {
 		return err
 	}
@@ -594,23 +679,49 @@ func initMaster(k3sScript, k3sMirror, dockerMirror, ip, extraArgs string, cluste
 	return nil
 }
 
-func initAdditionalMaster(k3sScript, k3sMirror, dockerMirror, ip, extraArgs string, cluster *types.Cluster, master types.Node) error {
+// waitForMaster waits for the api server of the first master to be ready before other nodes join it.
+func waitForMaster(ctx context.Context, master types.Node) error {
+	backoff := wait.Backoff{
+		Duration: 5 * time.Second,
+		Factor:   1,
+		Steps:    36,
+	} // retry 36 times, total 180 seconds.
+	if err := utils.WaitForBackoff(func() (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		_, err := executeWithContext(ctx, &hosts.Host{Node: master}, []string{masterReadyCommand})
+		return err == nil, nil
+	}, backoff); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("[cluster] k3s master %s is not ready: %v", master.InstanceID, err)
+	}
+	return nil
+}
+
+func initAdditionalMaster(ctx context.Context, k3sScript, k3sMirror, dockerMirror, ip, extraArgs string, cluster *types.Cluster, master types.Node) error {
 	sortedExtraArgs := ""
 
 	if strings.Contains(extraArgs, "--docker") {
-		if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
+		if _, err := executeWithContext(ctx, &hosts.Host{Node: master}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
 			return err
 		}
 	}
 
 	if cluster.Registry != "" {
-		if err := handleRegistry(&hosts.Host{Node: master}, cluster.Registry); err != nil {
+		if err := handleRegistry(ctx, master, cluster.Registry); err != nil {
 			return err
 		}
 	}
 
+	if err := prepareAirGap(ctx, cluster, master); err != nil {
+		return err
+	}
+
 	if !strings.Contains(extraArgs, "server --server") {
-		sortedExtraArgs += fmt.Sprintf(" server --server %s --tls-san %s --node-external-ip %s", fmt.Sprintf("https://%s:6443", ip), master.PublicIPAddress[0], master.PublicIPAddress[0])
+		sortedExtraArgs += fmt.Sprintf(" server --server %s --tls-san %s --node-external-ip %s", fmt.Sprintf("https://%s:6443", ip), master.ExternalIP(), master.ExternalIP())
 	}
 
 	sortedExtraArgs += " " + extraArgs
@@ -618,7 +729,7 @@ func initAdditionalMaster(k3sScript, k3sMirror, dockerMirror, ip, extraArgs stri
 	logger.Debugf("[cluster] k3s additional master command: %s\n", fmt.Sprintf(joinCommand, k3sScript, k3sMirror,
 		ip, cluster.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel)))
 
-	if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, ip,
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: master}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, ip,
 		cluster.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel))}); err != nil {
 		return err
 	}
@@ -626,41 +737,45 @@ func initAdditionalMaster(k3sScript, k3sMirror, dockerMirror, ip, extraArgs stri
 	return nil
 }
 
-func initWorker(wg *sync.WaitGroup, errChan chan error, k3sScript, k3sMirror, dockerMirror, extraArgs string,
-	cluster *types.Cluster, worker types.Node) {
+func initWorker(ctx context.Context, k3sScript, k3sMirror, dockerMirror, extraArgs string,
+	cluster *types.Cluster, worker types.Node) error {
 	sortedExtraArgs := ""
 
 	if strings.Contains(extraArgs, "--docker") {
-		if _, err := execute(&hosts.Host{Node: worker}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
-			errChan <- err
+		if _, err := executeWithContext(ctx, &hosts.Host{Node: worker}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
+			return err
 		}
 	}
 
 	if cluster.Registry != "" {
-		if err := handleRegistry(&hosts.Host{Node: worker}, cluster.Registry); err != nil {
-			errChan <- err
+		if err := handleRegistry(ctx, worker, cluster.Registry); err != nil {
+			return err
 		}
 	}
-	sortedExtraArgs += fmt.Sprintf(" --node-external-ip %s", worker.PublicIPAddress[0])
+
+	if err := prepareAirGap(ctx, cluster, worker); err != nil {
+		return err
+	}
+	sortedExtraArgs += fmt.Sprintf(" --node-external-ip %s", worker.ExternalIP())
 	sortedExtraArgs += " " + extraArgs
 
 	logger.Debugf("[cluster] k3s worker command: %s\n", fmt.Sprintf(joinCommand, k3sScript, k3sMirror, cluster.IP,
 		cluster.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel)))
 
-	if _, err := execute(&hosts.Host{Node: worker}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, cluster.IP,
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: worker}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, cluster.IP,
 		cluster.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(cluster.K3sVersion, cluster.K3sChannel))}); err != nil {
-		errChan <- err
+		return err
 	}
 
-	wg.Done()
+	return nil
 }
 
-func joinMaster(k3sScript, k3sMirror, dockerMirror,
+func joinMaster(ctx context.Context, k3sScript, k3sMirror, dockerMirror,
 	extraArgs string, merged *types.Cluster, full types.Node) error {
 	sortedExtraArgs := ""
 
 	if !strings.Contains(extraArgs, "server --server") {
-		sortedExtraArgs += fmt.Sprintf(" server --server %s --tls-san %s --node-external-ip %s", fmt.Sprintf("https://%s:6443", merged.IP), full.PublicIPAddress[0], full.PublicIPAddress[0])
+		sortedExtraArgs += fmt.Sprintf(" server --server %s --tls-san %s --node-external-ip %s", fmt.Sprintf("https://%s:6443", merged.IP), full.ExternalIP(), full.ExternalIP())
 	}
 
 	if merged.DataStore != "" {
@@ -672,24 +787,28 @@ func joinMaster(k3sScript, k3sMirror, dockerMirror,
 	}
 
 	if strings.Contains(extraArgs, "--docker") {
-		if _, err := execute(&hosts.Host{Node: full}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
+		if _, err := executeWithContext(ctx, &hosts.Host{Node: full}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
 			return err
 		}
 	}
 
 	if merged.Registry != "" {
-		if err := handleRegistry(&hosts.Host{Node: full}, merged.Registry); err != nil {
+		if err := handleRegistry(ctx, full, merged.Registry); err != nil {
 			return err
 		}
 	}
 
+	if err := prepareAirGap(ctx, merged, full); err != nil {
+		return err
+	}
+
 	sortedExtraArgs += " " + extraArgs
 
 	logger.Debugf("[cluster] k3s master command: %s\n", fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
 		merged.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(merged.K3sVersion, merged.K3sChannel)))
 
 	// for now, use the workerCommand to join the additional master server node.
-	if _, err := execute(&hosts.Host{Node: full}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: full}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
 		merged.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(merged.K3sVersion, merged.K3sChannel))}); err != nil {
 		return err
 	}
@@ -697,47 +816,59 @@ func joinMaster(k3sScript, k3sMirror, dockerMirror,
 	return nil
 }
 
-func joinWorker(wg *sync.WaitGroup, errChan chan error, k3sScript, k3sMirror, dockerMirror, extraArgs string,
-	merged *types.Cluster, full types.Node) {
+func joinWorker(ctx context.Context, k3sScript, k3sMirror, dockerMirror, extraArgs string,
+	merged *types.Cluster, full types.Node) error {
 	sortedExtraArgs := ""
 
 	if strings.Contains(extraArgs, "--docker") {
-		if _, err := execute(&hosts.Host{Node: full}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
-			errChan <- err
+		if _, err := executeWithContext(ctx, &hosts.Host{Node: full}, []string{fmt.Sprintf(dockerCommand, dockerMirror)}); err != nil {
+			return err
 		}
 	}
 
 	if merged.Registry != "" {
-		if err := handleRegistry(&hosts.Host{Node: full}, merged.Registry); err != nil {
-			errChan <- err
+		if err := handleRegistry(ctx, full, merged.Registry); err != nil {
+			return err
 		}
 	}
 
-	sortedExtraArgs += fmt.Sprintf(" --node-external-ip %s", full.PublicIPAddress[0])
+	if err := prepareAirGap(ctx, merged, full); err != nil {
+		return err
+	}
+
+	sortedExtraArgs += fmt.Sprintf(" --node-external-ip %s", full.ExternalIP())
 	sortedExtraArgs += " " + extraArgs
 
 	logger.Debugf("[cluster] k3s worker command: %s\n", fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
 		merged.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(merged.K3sVersion, merged.K3sChannel)))
 
-	if _, err := execute(&hosts.Host{Node: full}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: full}, []string{fmt.Sprintf(joinCommand, k3sScript, k3sMirror, merged.IP,
 		merged.Token, strings.TrimSpace(sortedExtraArgs), genK3sVersion(merged.K3sVersion, merged.K3sChannel))}); err != nil {
-		errChan <- err
+		return err
 	}
 
-	wg.Done()
+	return nil
 }
 
 func execute(host *hosts.Host, cmds []string) (string, error) {
+	return executeWithContext(context.Background(), host, cmds)
+}
+
+// executeWithContext executes commands on host, the running commands are cancelled once ctx is done.
+func executeWithContext(ctx context.Context, host *hosts.Host, cmds []string) (string, error) {
 	if len(cmds) <= 0 {
 		return "", nil
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 
 	dialer, err := hosts.SSHDialer(host)
 	if err != nil {
 		return "", err
 	}
 
-	tunnel, err := dialer.OpenTunnel(true)
+	tunnel, err := dialer.OpenTunnelContext(ctx, true)
 	if err != nil {
 		return "", err
 	}
@@ -756,7 +887,10 @@ func execute(host *hosts.Host, cmds []string) (string, error) {
 	)
 	tunnel.SetStdio(&stdout, &stderr)
 
-	if err := tunnel.Run(); err != nil {
+	if err := tunnel.RunContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", fmt.Errorf("%w: %s", err, stderr.String())
 	}
 
@@ -822,75 +956,60 @@ func mergeCfg(context, right string) error {
 	return utils.WriteBytesToYaml(out.Bytes(), common.CfgPath, common.KubeCfgFile)
 }
 
-func deleteClusterFromState(name string, provider string) ([]types.Cluster, error) {
-	v := common.CfgPath
-	if v == "" {
-		return nil, errors.New("[cluster] cfg path is empty")
-	}
-
-	clusters, err := utils.ReadYaml(v, common.StateFile)
-	if err != nil {
-		return nil, err
+func genK3sVersion(version, channel string) string {
+	if version != "" {
+		return fmt.Sprintf("INSTALL_K3S_VERSION='%s'", version)
 	}
+	return fmt.Sprintf("INSTALL_K3S_CHANNEL='%s'", channel)
+}
 
-	converts, err := ConvertToClusters(clusters)
+// handleRegistry uploads the registry file and the tls files referenced by it to the node.
+func handleRegistry(ctx context.Context, node types.Node, file string) error {
+	files, err := RegistryFiles(file)
 	if err != nil {
-		return nil, fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
+		return err
 	}
 
-	index := -1
-
-	for i, c := range converts {
-		if c.Provider == provider && c.Name == name {
-			index = i
+	return withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		for file, b := range files {
+			mode := os.FileMode(0644)
+			if file == registryFile || path.Base(file) == "key" {
+				mode = 0600
+			}
+			if err := tunnel.UploadReader(bytes.NewReader(b), int64(len(b)), file, hosts.TransferOptions{Mode: mode, Sudo: true}); err != nil {
+				return err
+			}
 		}
-	}
-
-	if index > -1 {
-		converts = append(converts[:index], converts[index+1:]...)
-	} else {
-		return nil, fmt.Errorf("[cluster] was not found in the .state file")
-	}
-
-	return converts, nil
+		return nil
+	})
 }
 
-func genK3sVersion(version, channel string) string {
-	if version != "" {
-		return fmt.Sprintf("INSTALL_K3S_VERSION='%s'", version)
-	}
-	return fmt.Sprintf("INSTALL_K3S_CHANNEL='%s'", channel)
-}
-
-func handleRegistry(host *hosts.Host, file string) error {
-	cmd := make([]string, 0)
-	cmd = append(cmd, fmt.Sprintf("sudo mkdir -p %s", registryPath))
+// RegistryFiles returns the k3s registry file and the tls files referenced by it, keyed by the path on nodes.
+func RegistryFiles(file string) (map[string][]byte, error) {
 	registry, err := unmarshalRegistryFile(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	tls, err := registryTLSMap(registry)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	files := map[string][]byte{}
 	if tls != nil && len(tls) > 0 {
-		registry, cmd, err = saveRegistryTLS(registry, tls)
+		registry, files, err = saveRegistryTLS(registry, tls)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	registryContent, err := registryToString(registry)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	cmd = append(cmd, fmt.Sprintf("echo \"%s\" | base64 -d | sudo tee \"/etc/rancher/k3s/registries.yaml\"",
-		base64.StdEncoding.EncodeToString([]byte(registryContent))))
-	_, err = execute(host, cmd)
-	return err
+	files[registryFile] = []byte(registryContent)
+	return files, nil
 }
 
 func unmarshalRegistryFile(file string) (*templates.Registry, error) {
@@ -952,23 +1071,21 @@ func registryTLSMap(registry *templates.Registry) (m map[string]map[string][]byt
 	return
 }
 
-func saveRegistryTLS(registry *templates.Registry, m map[string]map[string][]byte) (*templates.Registry, []string, error) {
-	cmd := make([]string, 0)
+func saveRegistryTLS(registry *templates.Registry, m map[string]map[string][]byte) (*templates.Registry, map[string][]byte, error) {
+	files := map[string][]byte{}
 	for r, c := range m {
 		if r != "" {
 			if _, ok := registry.Configs[r]; !ok {
-				return nil, cmd, fmt.Errorf("registry map is not match the struct: %s", r)
+				return nil, files, fmt.Errorf("registry map is not match the struct: %s", r)
 			}
 
 			// e.g /etc/rancher/k3s/mycustomreg:5000/
-			path := fmt.Sprintf("/etc/rancher/k3s/%s", r)
-			cmd = append(cmd, fmt.Sprintf("sudo mkdir -p %s", path))
+			dir := fmt.Sprintf("%s/%s", registryPath, r)
 
 			for f, b := range c {
 				// e.g /etc/rancher/k3s/mycustomreg:5000/{ca,key,cert}
-				file := fmt.Sprintf("%s/%s", path, f)
-				cmd = append(cmd, fmt.Sprintf("echo \"%s\" | base64 -d | sudo tee \"%s\"", base64.StdEncoding.EncodeToString(b), file))
-				cmd = append(cmd, fmt.Sprintf("sudo chmod 755 %s", file))
+				file := fmt.Sprintf("%s/%s", dir, f)
+				files[file] = b
 
 				switch f {
 				case "cert":
@@ -982,7 +1099,7 @@ func saveRegistryTLS(registry *templates.Registry, m map[string]map[string][]byt
 		}
 	}
 
-	return registry, cmd, nil
+	return registry, files, nil
 }
 
 func registryToString(registry *templates.Registry) (string, error) {
@@ -1097,20 +1214,57 @@ func DescribeClusterNodes(client *kubernetes.Clientset, instanceNodes []types.Cl
 	return instanceNodes, nil
 }
 
-func GetClusterByID(id string) (*types.Cluster, error) {
-	v := common.CfgPath
-	if v == "" {
-		return nil, errors.New("[cluster] cfg path is empty")
+func getKubeNode(client *kubernetes.Clientset, node types.Node) (*v1.Node, error) {
+	nodeList, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("[cluster] failed to list k3s nodes: %v", err)
+	}
+	for _, n := range nodeList.Items {
+		for _, address := range n.Status.Addresses {
+			if address.Type != v1.NodeInternalIP {
+				continue
+			}
+			for _, ip := range node.InternalIPAddress {
+				if address.Address == ip {
+					kubeNode := n
+					return &kubeNode, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func drainNode(client *kubernetes.Clientset, node *v1.Node) error {
+	helper := &drain.Helper{
+		Ctx:                 context.TODO(),
+		Client:              client,
+		Force:               true,
+		GracePeriodSeconds:  -1,
+		IgnoreAllDaemonSets: true,
+		DeleteLocalData:     true,
+		Timeout:             drainTimeout,
+		Out:                 logger.Out,
+		ErrOut:              logger.Out,
+	}
+	if err := drain.RunCordonOrUncordon(helper, node, true); err != nil {
+		return fmt.Errorf("[cluster] failed to cordon k3s node %s: %v", node.Name, err)
 	}
+	if err := drain.RunNodeDrain(helper, node.Name); err != nil {
+		return fmt.Errorf("[cluster] failed to drain k3s node %s: %v", node.Name, err)
+	}
+	return nil
+}
 
-	clusters, err := utils.ReadYaml(v, common.StateFile)
+func GetClusterByID(id string) (*types.Cluster, error) {
+	store, err := GetStateStore()
 	if err != nil {
 		return nil, err
 	}
 
-	converts, err := ConvertToClusters(clusters)
+	converts, err := store.List()
 	if err != nil {
-		return nil, fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
+		return nil, err
 	}
 	for _, c := range converts {
 		if c.Name == id {
@@ -1121,6 +1275,18 @@ func GetClusterByID(id string) (*types.Cluster, error) {
 }
 
 func SaveClusterState(cluster *types.Cluster, status string) error {
+	encrypted, err := encryptClusterSecrets(cluster)
+	if err != nil {
+		return err
+	}
 	path := common.GetClusterStatePath()
-	return utils.WriteYaml(cluster, path, fmt.Sprintf("%s_%s", cluster.Name, status))
+	if err := utils.WriteYaml(encrypted, path, fmt.Sprintf("%s_%s", cluster.Name, status)); err != nil {
+		return err
+	}
+	eventType := types.ClusterEventUpdate
+	if status == common.StatusCreating {
+		eventType = types.ClusterEventCreate
+	}
+	publishState(eventType, cluster.Name, cluster.Provider, status)
+	return nil
 }
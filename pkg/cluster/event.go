@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// eventBufferSize is the number of events buffered for each subscriber,
+// events are dropped for the subscriber if its buffer is full, so that slow subscribers will not block operations.
+const eventBufferSize = 256
+
+var (
+	subscribers     = map[chan types.ClusterEvent]struct{}{}
+	subscribersLock sync.RWMutex
+)
+
+// Subscribe returns a channel receiving cluster events published after subscribed,
+// the channel is closed once ctx is done.
+func Subscribe(ctx context.Context) <-chan types.ClusterEvent {
+	ch := make(chan types.ClusterEvent, eventBufferSize)
+	subscribersLock.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersLock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		subscribersLock.Lock()
+		delete(subscribers, ch)
+		close(ch)
+		subscribersLock.Unlock()
+	}()
+	return ch
+}
+
+func publish(e types.ClusterEvent) {
+	e.Time = time.Now().UTC().Format(time.RFC3339)
+
+	subscribersLock.RLock()
+	defer subscribersLock.RUnlock()
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			logrus.Warnf("[event] subscriber is too slow, drop %s event of cluster %s", e.Type, e.Cluster)
+		}
+	}
+}
+
+// publishState publishes the event of state changed.
+func publishState(eventType, name, provider, status string) {
+	publish(types.ClusterEvent{
+		Type:     eventType,
+		Cluster:  name,
+		Provider: provider,
+		Status:   status,
+	})
+}
+
+// progressEvent returns the progress event of operation.
+func progressEvent(op *types.Operation) types.ClusterEvent {
+	return types.ClusterEvent{
+		Type:      types.ClusterEventProgress,
+		Cluster:   op.Cluster,
+		Provider:  op.Provider,
+		Operation: op.ID,
+		Action:    op.Action,
+		Phase:     op.Phase,
+		Result:    op.Result,
+		Error:     op.Error,
+	}
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// nodeTask installs or joins k3s on a node.
+type nodeTask struct {
+	name string
+	node types.Node
+	run  func(ctx context.Context) error
+}
+
+// progress returns progress of the task with result, which is reported to the operation carried by context.
+func (t nodeTask) progress(result string, err error) types.OperationNode {
+	n := types.OperationNode{
+		Name:       t.name,
+		InstanceID: t.node.InstanceID,
+		Master:     t.node.Master,
+		Result:     result,
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	return n
+}
+
+// runNodeTasks runs tasks concurrently with at most common.Concurrency tasks in flight.
+// once any task fails, the running tasks are cancelled through the context and the pending tasks are skipped,
+// errors of all failed tasks are returned, or the error of parent context if the tasks are cancelled by it.
+func runNodeTasks(parent context.Context, tasks []nodeTask) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	concurrency := common.Concurrency
+	if concurrency <= 0 || concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+	sem := make(chan struct{}, concurrency)
+	for _, task := range tasks {
+		reportNode(ctx, task.progress(types.OperationPending, nil))
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	// tasks are started in order, so that masters are installed before workers.
+	for _, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			logger.Warnf("[cluster] %s is skipped: %v\n", task.name, ctx.Err())
+			reportNode(ctx, task.progress(types.OperationCanceled, nil))
+			continue
+		}
+
+		wg.Add(1)
+		go func(task nodeTask) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			reportNode(ctx, task.progress(types.OperationRunning, nil))
+			err := task.run(ctx)
+			if err == nil {
+				reportNode(ctx, task.progress(types.OperationSucceeded, nil))
+				return
+			}
+			// tasks cancelled by the failure of others are not counted as errors.
+			if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+				logger.Warnf("[cluster] %s is cancelled\n", task.name)
+				reportNode(ctx, task.progress(types.OperationCanceled, nil))
+				return
+			}
+			reportNode(ctx, task.progress(types.OperationFailed, err))
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("%s: %w", task.name, err))
+			mu.Unlock()
+			cancel()
+		}(task)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return parent.Err()
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,236 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+const redactedValue = "******"
+
+// parameters with these names contain secrets, e.g. ssh-password, ssh-key, access-key, token and
+// datastore which contains password of database.
+var sensitiveParameter = regexp.MustCompile(`(password|secret|token|passphrase|datastore|pass$|(^|-)key$)`)
+
+// OperationFilter filters operations in the history, empty fields match all operations.
+type OperationFilter struct {
+	Cluster  string
+	Provider string
+	Action   string
+	Actor    string
+	// Limit is the max number of the latest operations to return, 0 means no limit.
+	Limit int
+}
+
+// BeginOperation fills id and start time of the operation, redacts secrets in parameters and appends it to the history,
+// sensitive are names of extra parameters to be redacted, e.g. credential flags of provider.
+// The history is for audit only, so failures of writing it are logged and will not break the operation.
+func BeginOperation(op *types.Operation, params interface{}, sensitive ...string) {
+	id, err := utils.RandomToken(8)
+	if err != nil {
+		id = fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	op.ID = id
+	op.StartTime = time.Now().UTC().Format(time.RFC3339)
+	op.Result = types.OperationRunning
+	op.Parameters = redactParameters(params, sensitive)
+	recordOperation(op)
+	registerOperation(op)
+}
+
+// EndOperation records the result of the operation started by BeginOperation.
+func EndOperation(op *types.Operation, err error) {
+	unregisterOperation(op, err)
+	recordOperation(op)
+	// sessions of ssh and kubectl don't change the cluster.
+	if op.Action != types.OperationSSH && op.Action != types.OperationKubectl {
+		publish(progressEvent(op))
+	}
+}
+
+// ListOperations returns operations in the history matched the filter, the latest operation goes first,
+// progress of running operations in this process is included.
+func ListOperations(filter OperationFilter) ([]types.Operation, error) {
+	ops, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.Operation, 0)
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := runningSnapshot(ops[i])
+		if filter.Cluster != "" && op.Cluster != filter.Cluster && strings.Split(op.Cluster, ".")[0] != filter.Cluster {
+			continue
+		}
+		if (filter.Provider != "" && op.Provider != filter.Provider) ||
+			(filter.Action != "" && op.Action != filter.Action) ||
+			(filter.Actor != "" && op.Actor != filter.Actor) {
+			continue
+		}
+		result = append(result, op)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// GetOperation returns the operation with the id in the history, nil is returned if not found.
+func GetOperation(id string) (*types.Operation, error) {
+	ops, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ops {
+		if ops[i].ID == id {
+			op := runningSnapshot(ops[i])
+			return &op, nil
+		}
+	}
+	return nil, nil
+}
+
+func recordOperation(op *types.Operation) {
+	if err := appendHistory(op); err != nil {
+		logrus.Warnf("[history] failed to record %s operation of cluster %s: %v", op.Action, op.Cluster, err)
+	}
+}
+
+// appendHistory appends the operation as a json line to the history file, the start and the end of
+// an operation are two lines with the same id, and the latter one takes effect.
+func appendHistory(op *types.Operation) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(common.CfgPath, common.HistoryFile)
+	unlock, err := lockFile(name + stateLockSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readHistory returns operations in the history file ordered by start time.
+func readHistory() ([]types.Operation, error) {
+	f, err := os.Open(filepath.Join(common.CfgPath, common.HistoryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []types.Operation{}, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	ops := make([]types.Operation, 0)
+	index := map[string]int{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			op := types.Operation{}
+			if uErr := json.Unmarshal(line, &op); uErr != nil {
+				// the line may be partially written by a crashed process.
+				logrus.Debugf("[history] skip invalid line in history file: %v", uErr)
+			} else if i, ok := index[op.ID]; ok {
+				ops[i] = op
+			} else {
+				index[op.ID] = len(ops)
+				ops = append(ops, op)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].StartTime < ops[j].StartTime
+	})
+	return ops, nil
+}
+
+// RedactSecrets returns a copy of the object without secrets, e.g. token, ssh secrets and credentials of provider,
+// sensitive are names of extra fields to be removed.
+// Secrets are removed instead of replaced, so the object sent back by clients will not overwrite them.
+func RedactSecrets(obj interface{}, sensitive ...string) map[string]interface{} {
+	return toRedactedMap(obj, sensitive, true)
+}
+
+// redactParameters returns a copy of parameters with values of secrets replaced.
+func redactParameters(params interface{}, sensitive []string) map[string]interface{} {
+	return toRedactedMap(params, sensitive, false)
+}
+
+func toRedactedMap(obj interface{}, sensitive []string, remove bool) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil
+	}
+	redact(result, sensitive, remove)
+	return result
+}
+
+func redact(v interface{}, sensitive []string, remove bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, value := range t {
+			if isSensitive(k, sensitive) {
+				if remove {
+					delete(t, k)
+				} else if s, ok := value.(string); !ok || s != "" {
+					t[k] = redactedValue
+				}
+				continue
+			}
+			redact(value, sensitive, remove)
+		}
+	case []interface{}:
+		for _, value := range t {
+			redact(value, sensitive, remove)
+		}
+	}
+}
+
+func isSensitive(key string, sensitive []string) bool {
+	for _, s := range sensitive {
+		if strings.EqualFold(key, s) {
+			return true
+		}
+	}
+	return sensitiveParameter.MatchString(strings.ToLower(key))
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package cluster
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile acquires the exclusive lock of f without blocking, false is returned if it's held by others.
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+type operationKey struct{}
+
+// runningOperation is the operation started by BeginOperation and not ended yet,
+// whose progress is kept in memory and only written to the history when it is ended.
+type runningOperation struct {
+	op       *types.Operation
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+var (
+	runningOperations     = map[string]*runningOperation{}
+	runningOperationsLock sync.Mutex
+)
+
+// WithOperation returns a context carrying the operation started by BeginOperation, progress reported with the context
+// is updated to the operation, and the context is done once the operation is canceled by CancelOperation.
+func WithOperation(parent context.Context, op *types.Operation) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	if r, ok := runningOperations[op.ID]; ok {
+		r.cancel = cancel
+	} else {
+		cancel()
+	}
+	return context.WithValue(ctx, operationKey{}, op.ID)
+}
+
+// CancelOperation cancels the running operation with the id.
+func CancelOperation(id string) error {
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	r, ok := runningOperations[id]
+	if !ok {
+		return fmt.Errorf("[cluster] operation %s is not running", id)
+	}
+	if r.cancel == nil {
+		return fmt.Errorf("[cluster] %s operation %s can not be canceled", r.op.Action, id)
+	}
+	r.canceled = true
+	r.cancel()
+	return nil
+}
+
+// ReportPhase updates phase of the operation carried by ctx and publishes the progress.
+func ReportPhase(ctx context.Context, phase string) {
+	if e, ok := updateOperation(ctx, func(op *types.Operation) {
+		op.Phase = phase
+	}); ok {
+		publish(e)
+	}
+}
+
+// reportNode updates progress of the node in the operation carried by ctx and publishes the progress.
+func reportNode(ctx context.Context, node types.OperationNode) {
+	if e, ok := updateOperation(ctx, func(op *types.Operation) {
+		for i := range op.Nodes {
+			if op.Nodes[i].Name == node.Name {
+				op.Nodes[i] = node
+				return
+			}
+		}
+		op.Nodes = append(op.Nodes, node)
+	}); ok {
+		e.Node = &node
+		publish(e)
+	}
+}
+
+// updateOperation updates the running operation carried by ctx, the progress event of updated operation is returned.
+func updateOperation(ctx context.Context, fn func(op *types.Operation)) (types.ClusterEvent, bool) {
+	id, ok := ctx.Value(operationKey{}).(string)
+	if !ok {
+		return types.ClusterEvent{}, false
+	}
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	r, ok := runningOperations[id]
+	if !ok {
+		return types.ClusterEvent{}, false
+	}
+	fn(r.op)
+	return progressEvent(r.op), true
+}
+
+func registerOperation(op *types.Operation) {
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	runningOperations[op.ID] = &runningOperation{op: op}
+}
+
+// unregisterOperation removes the operation from running ones and fills its end time and result.
+func unregisterOperation(op *types.Operation, err error) {
+	runningOperationsLock.Lock()
+	r, ok := runningOperations[op.ID]
+	delete(runningOperations, op.ID)
+	canceled := ok && r.canceled
+
+	op.EndTime = time.Now().UTC().Format(time.RFC3339)
+	op.Result = types.OperationSucceeded
+	if err != nil {
+		op.Result = types.OperationFailed
+		op.Error = err.Error()
+		if canceled {
+			op.Result = types.OperationCanceled
+		}
+	}
+	for i := range op.Nodes {
+		if op.Nodes[i].Result == types.OperationPending || op.Nodes[i].Result == types.OperationRunning {
+			op.Nodes[i].Result = types.OperationCanceled
+		}
+	}
+	runningOperationsLock.Unlock()
+
+	if ok && r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// SnapshotOperation returns a copy of the operation started by BeginOperation, which is safe to read
+// while the operation is still running.
+func SnapshotOperation(op *types.Operation) types.Operation {
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	result := *op
+	result.Nodes = append([]types.OperationNode(nil), op.Nodes...)
+	return result
+}
+
+// runningSnapshot returns a copy of op with its in-memory progress if it is running.
+func runningSnapshot(op types.Operation) types.Operation {
+	runningOperationsLock.Lock()
+	defer runningOperationsLock.Unlock()
+	r, ok := runningOperations[op.ID]
+	if !ok {
+		return op
+	}
+	result := *r.op
+	result.Nodes = append([]types.OperationNode(nil), r.op.Nodes...)
+	return result
+}
@@ -0,0 +1,358 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	preflightSudoCommand    = "sudo -n true"
+	preflightOSCommand      = "uname -s; uname -m; (. /etc/os-release 2>/dev/null && echo \"$ID\") || echo unknown"
+	preflightCurlCommand    = "command -v curl || true"
+	preflightPortsCommand   = "if command -v ss >/dev/null 2>&1; then ss -ltn; elif command -v netstat >/dev/null 2>&1; then netstat -ltn; else echo unsupported; fi"
+	preflightDiskCommand    = "df -Pk /var/lib | tail -n 1"
+	preflightMemoryCommand  = "grep MemTotal /proc/meminfo"
+	preflightClockCommand   = "date +%s"
+	preflightSELinuxCommand = "getenforce 2>/dev/null || echo Disabled; rpm -q k3s-selinux >/dev/null 2>&1 && echo installed || true"
+
+	// disk space of /var/lib in bytes, which is used by k3s to store images and data.
+	preflightMinDisk         = 2 << 30
+	preflightRecommendedDisk = 10 << 30
+	// memory in bytes of masters and workers.
+	preflightMinMasterMemory         = 512 << 20
+	preflightRecommendedMasterMemory = 1 << 30
+	preflightMinWorkerMemory         = 256 << 20
+	preflightRecommendedWorkerMemory = 512 << 20
+	// clock skew between local and nodes, certificates issued by masters may be not valid on nodes with large skew.
+	preflightMaxClockSkew  = 5 * time.Minute
+	preflightWarnClockSkew = 30 * time.Second
+)
+
+var (
+	preflightArches = []string{"x86_64", "amd64", "aarch64", "arm64", "armv7l", "armhf", "s390x"}
+	preflightOSes   = []string{"ubuntu", "debian", "raspbian", "centos", "rhel", "rocky", "almalinux", "ol", "fedora",
+		"amzn", "opensuse", "opensuse-leap", "sles", "sle-micro", "alpine"}
+)
+
+// preflightCheck checks the node through ssh, and returns the result and message of the check.
+type preflightCheck struct {
+	name string
+	run  func(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string)
+}
+
+var preflightChecks = []preflightCheck{
+	{name: "sudo", run: checkSudo},
+	{name: "os", run: checkOS},
+	{name: "curl", run: checkCurl},
+	{name: "ports", run: checkPorts},
+	{name: "disk", run: checkDisk},
+	{name: "memory", run: checkMemory},
+	{name: "clock", run: checkClock},
+	{name: "selinux", run: checkSELinux},
+}
+
+// PreflightK3sNodes checks the nodes concurrently through ssh before installing k3s,
+// the results matrix is logged and error is returned if any check fails.
+func PreflightK3sNodes(ctx context.Context, cluster *types.Cluster, nodes []types.Node) ([]types.NodePreflight, error) {
+	if cluster.Logger != nil {
+		logger = cluster.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	logger.Infof("[%s] executing pre-flight checks on %d nodes...\n", cluster.Provider, len(nodes))
+	ReportPhase(ctx, types.PhasePreflight)
+
+	concurrency := common.Concurrency
+	if concurrency <= 0 || concurrency > len(nodes) {
+		concurrency = len(nodes)
+	}
+	sem := make(chan struct{}, concurrency)
+	reports := make([]types.NodePreflight, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node types.Node) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() {
+				<-sem
+			}()
+			reports[i] = preflightNode(ctx, cluster, node)
+		}(i, node)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return reports, err
+	}
+
+	var matrix strings.Builder
+	WritePreflightMatrix(&matrix, reports)
+	logger.Infof("[%s] pre-flight check results:\n", cluster.Provider)
+	for _, line := range strings.Split(strings.TrimRight(matrix.String(), "\n"), "\n") {
+		logger.Info(line)
+	}
+
+	failed := make([]string, 0)
+	for _, r := range reports {
+		for _, c := range r.Checks {
+			if c.Result == types.PreflightFail {
+				failed = append(failed, r.InstanceID)
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return reports, fmt.Errorf("[cluster] pre-flight checks failed on nodes %s", strings.Join(failed, ","))
+	}
+	logger.Infof("[%s] successfully executed pre-flight checks\n", cluster.Provider)
+	return reports, nil
+}
+
+// WritePreflightMatrix writes the pass/warn/fail matrix of nodes and checks, messages of warned or failed checks are listed as problems.
+func WritePreflightMatrix(w io.Writer, reports []types.NodePreflight) {
+	table := tablewriter.NewWriter(w)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	header := []string{"Instance", "Role", "ssh"}
+	for _, c := range preflightChecks {
+		header = append(header, c.name)
+	}
+	table.SetHeader(append(header, "Problems"))
+
+	for _, r := range reports {
+		role := "worker"
+		if r.Master {
+			role = "master"
+		}
+		results := map[string]string{}
+		problems := make([]string, 0)
+		for _, c := range r.Checks {
+			results[c.Name] = c.Result
+			if c.Result != types.PreflightPass {
+				problems = append(problems, fmt.Sprintf("%s: %s", c.Name, c.Message))
+			}
+		}
+		row := []string{r.InstanceID, role}
+		for _, name := range header[2:] {
+			result, ok := results[name]
+			if !ok {
+				result = "-"
+			}
+			row = append(row, result)
+		}
+		if len(problems) == 0 {
+			problems = append(problems, "-")
+		}
+		table.Append(append(row, strings.Join(problems, "; ")))
+	}
+	table.Render()
+}
+
+func preflightNode(ctx context.Context, cluster *types.Cluster, node types.Node) types.NodePreflight {
+	report := types.NodePreflight{InstanceID: node.InstanceID, Master: node.Master}
+	err := withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		report.Checks = append(report.Checks, types.PreflightCheck{Name: "ssh", Result: types.PreflightPass})
+		for _, c := range preflightChecks {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result, message := c.run(tunnel, cluster, node)
+			report.Checks = append(report.Checks, types.PreflightCheck{Name: c.name, Result: result, Message: message})
+		}
+		return nil
+	})
+	if err != nil && len(report.Checks) == 0 {
+		report.Checks = append(report.Checks, types.PreflightCheck{Name: "ssh", Result: types.PreflightFail, Message: err.Error()})
+	}
+	return report
+}
+
+func checkSudo(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	if _, err := tunnel.Output(preflightSudoCommand); err != nil {
+		return types.PreflightFail, fmt.Sprintf("passwordless sudo is required: %v", err)
+	}
+	return types.PreflightPass, ""
+}
+
+func checkOS(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	out, err := tunnel.Output(preflightOSCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to get os: %v", err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return types.PreflightWarn, fmt.Sprintf("unexpected os info %q", out)
+	}
+	kernel, arch, id := fields[0], fields[1], fields[2]
+	if kernel != "Linux" {
+		return types.PreflightFail, fmt.Sprintf("unsupported os %s", kernel)
+	}
+	if !containsString(preflightArches, arch) {
+		return types.PreflightFail, fmt.Sprintf("unsupported architecture %s", arch)
+	}
+	if !containsString(preflightOSes, id) {
+		return types.PreflightWarn, fmt.Sprintf("k3s is not tested on %s", id)
+	}
+	return types.PreflightPass, ""
+}
+
+func checkCurl(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	// install script and k3s binary are uploaded in air-gap mode.
+	if cluster.AirGapDir != "" {
+		return types.PreflightPass, ""
+	}
+	out, err := tunnel.Output(preflightCurlCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to find curl: %v", err)
+	}
+	if out == "" {
+		return types.PreflightFail, "curl is required to download k3s install script"
+	}
+	return types.PreflightPass, ""
+}
+
+func checkPorts(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	ports := []string{"10250"}
+	if node.Master {
+		ports = append(ports, "6443")
+		if cluster.Cluster {
+			ports = append(ports, "2379", "2380")
+		}
+	}
+	out, err := tunnel.Output(preflightPortsCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to list listening ports: %v", err)
+	}
+	if out == "unsupported" {
+		return types.PreflightWarn, "neither ss nor netstat is found to list listening ports"
+	}
+
+	busy := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		for _, port := range ports {
+			if strings.HasSuffix(fields[3], ":"+port) && !containsString(busy, port) {
+				busy = append(busy, port)
+			}
+		}
+	}
+	if len(busy) > 0 {
+		return types.PreflightFail, fmt.Sprintf("ports in use: %s", strings.Join(busy, ","))
+	}
+	return types.PreflightPass, ""
+}
+
+func checkDisk(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	out, err := tunnel.Output(preflightDiskCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to get disk space: %v", err)
+	}
+	// Filesystem 1024-blocks Used Available Capacity Mounted on
+	fields := strings.Fields(out)
+	if len(fields) < 4 {
+		return types.PreflightWarn, fmt.Sprintf("unexpected disk info %q", out)
+	}
+	available, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("unexpected disk info %q", out)
+	}
+	available *= 1024
+	return checkSize("available disk space of /var/lib", available, preflightMinDisk, preflightRecommendedDisk)
+}
+
+func checkMemory(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	out, err := tunnel.Output(preflightMemoryCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to get memory: %v", err)
+	}
+	// MemTotal:        2035652 kB
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return types.PreflightWarn, fmt.Sprintf("unexpected memory info %q", out)
+	}
+	total, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("unexpected memory info %q", out)
+	}
+	total *= 1024
+	if node.Master {
+		return checkSize("memory", total, preflightMinMasterMemory, preflightRecommendedMasterMemory)
+	}
+	return checkSize("memory", total, preflightMinWorkerMemory, preflightRecommendedWorkerMemory)
+}
+
+func checkClock(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	start := time.Now()
+	out, err := tunnel.Output(preflightClockCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to get time: %v", err)
+	}
+	remote, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("unexpected time %q", out)
+	}
+	// the remote time is compared with the local time in the middle of the round trip.
+	local := start.Add(time.Since(start) / 2)
+	skew := time.Unix(remote, 0).Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+	skew = skew.Round(time.Second)
+	switch {
+	case skew > preflightMaxClockSkew:
+		return types.PreflightFail, fmt.Sprintf("clock skew %s exceeds %s", skew, preflightMaxClockSkew)
+	case skew > preflightWarnClockSkew:
+		return types.PreflightWarn, fmt.Sprintf("clock skew %s exceeds %s", skew, preflightWarnClockSkew)
+	}
+	return types.PreflightPass, ""
+}
+
+func checkSELinux(tunnel *hosts.Tunnel, cluster *types.Cluster, node types.Node) (string, string) {
+	out, err := tunnel.Output(preflightSELinuxCommand)
+	if err != nil {
+		return types.PreflightWarn, fmt.Sprintf("failed to get selinux state: %v", err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 || fields[0] != "Enforcing" {
+		return types.PreflightPass, ""
+	}
+	if len(fields) > 1 && fields[1] == "installed" {
+		return types.PreflightPass, ""
+	}
+	// k3s-selinux is installed by the install script, which can't be downloaded in air-gap mode.
+	if cluster.AirGapDir != "" {
+		return types.PreflightFail, "selinux is enforcing, k3s-selinux policy must be installed in air-gap mode"
+	}
+	return types.PreflightWarn, "selinux is enforcing, k3s-selinux policy will be installed by the install script"
+}
+
+func checkSize(name string, size, min, recommended int64) (string, string) {
+	switch {
+	case size < min:
+		return types.PreflightFail, fmt.Sprintf("%s %dMiB is less than %dMiB", name, size>>20, min>>20)
+	case size < recommended:
+		return types.PreflightWarn, fmt.Sprintf("%s %dMiB is less than recommended %dMiB", name, size>>20, recommended>>20)
+	}
+	return types.PreflightPass, ""
+}
@@ -0,0 +1,38 @@
+package cluster
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleRegistry(t *testing.T) {
+	dir, server, node, cleanup := setupAirGap(t)
+	defer cleanup()
+	file := filepath.Join(dir, "registries.yaml")
+	if err := ioutil.WriteFile(file, []byte("mirrors:\n  docker.io:\n    endpoint:\n    - https://mirror.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upload is stopped with the context of creating cluster.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := handleRegistry(ctx, node, file); err == nil {
+		t.Error("expected error of canceled context")
+	}
+	n := server.Node(node.PublicIPAddress[0])
+	if _, ok := n.ReadFile(registryFile); ok {
+		t.Error("registry file is uploaded with canceled context")
+	}
+
+	if err := handleRegistry(context.Background(), node, file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.ReadFile(registryFile); !ok {
+		t.Error("registry file is not uploaded")
+	} else if n.FileMode(registryFile) != os.FileMode(0600) {
+		t.Errorf("mode of registry file is %v, expected 0600", n.FileMode(registryFile))
+	}
+}
@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/secrets"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/ghodss/yaml"
+)
+
+// encryptClusterSecrets returns a copy of cluster with secrets encrypted, which are the token,
+// credentials of provider in options and ssh secrets of nodes and bastions.
+func encryptClusterSecrets(c *types.Cluster) (*types.Cluster, error) {
+	return transformClusterSecrets(c, secrets.Encrypt)
+}
+
+// decryptClusterSecrets decrypts secrets encrypted by encryptClusterSecrets in place.
+func decryptClusterSecrets(c *types.Cluster) error {
+	decrypted, err := transformClusterSecrets(c, secrets.Decrypt)
+	if err != nil {
+		return err
+	}
+	*c = *decrypted
+	return nil
+}
+
+func transformClusterSecrets(c *types.Cluster, fn func(string) (string, error)) (*types.Cluster, error) {
+	result := *c
+	var err error
+	if result.Token, err = fn(c.Token); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to process secrets of cluster %s: %v", c.Name, err)
+	}
+	if result.Options, err = transformOptionSecrets(c.Provider, c.Options, fn); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to process secrets of cluster %s: %v", c.Name, err)
+	}
+	if result.MasterNodes, err = transformNodeSecrets(c.MasterNodes, fn); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to process secrets of cluster %s: %v", c.Name, err)
+	}
+	if result.WorkerNodes, err = transformNodeSecrets(c.WorkerNodes, fn); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to process secrets of cluster %s: %v", c.Name, err)
+	}
+	return &result, nil
+}
+
+// transformOptionSecrets returns a copy of options with credential fields of provider transformed,
+// options are returned as is if the provider has no credential fields.
+func transformOptionSecrets(provider string, options interface{}, fn func(string) (string, error)) (interface{}, error) {
+	if options == nil {
+		return nil, nil
+	}
+	p, err := providers.GetProvider(provider)
+	if err != nil {
+		return options, nil
+	}
+	credFlags := p.GetCredentialFlags()
+	if len(credFlags) == 0 {
+		return options, nil
+	}
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	for _, flag := range credFlags {
+		v, ok := result[flag.Name].(string)
+		if !ok {
+			continue
+		}
+		if result[flag.Name], err = fn(v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func transformNodeSecrets(nodes []types.Node, fn func(string) (string, error)) ([]types.Node, error) {
+	if nodes == nil {
+		return nil, nil
+	}
+	result := make([]types.Node, len(nodes))
+	for i, node := range nodes {
+		for _, s := range []*string{&node.Password, &node.SSHKey, &node.SSHKeyPassphrase} {
+			v, err := fn(*s)
+			if err != nil {
+				return nil, err
+			}
+			*s = v
+		}
+		if node.Bastions != nil {
+			bastions := make([]types.Bastion, len(node.Bastions))
+			for j, b := range node.Bastions {
+				for _, s := range []*string{&b.Password, &b.SSHKey, &b.SSHKeyPassphrase} {
+					v, err := fn(*s)
+					if err != nil {
+						return nil, err
+					}
+					*s = v
+				}
+				bastions[j] = b
+			}
+			node.Bastions = bastions
+		}
+		result[i] = node
+	}
+	return result, nil
+}
+
+// ReadClusterState reads the cluster from the status file written by SaveClusterState.
+func ReadClusterState(name string) (*types.Cluster, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &types.Cluster{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if err := decryptClusterSecrets(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReencryptStates saves clusters in state store and status files again,
+// so that their secrets are encrypted with the current master key.
+func ReencryptStates() error {
+	store, err := GetStateStore()
+	if err != nil {
+		return err
+	}
+	clusters, err := store.List()
+	if err != nil {
+		return err
+	}
+	for i := range clusters {
+		if err := store.Save(&clusters[i]); err != nil {
+			return err
+		}
+	}
+
+	path := common.GetClusterStatePath()
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		c, err := ReadClusterState(filepath.Join(path, f.Name()))
+		if err != nil {
+			return fmt.Errorf("[cluster] failed to read cluster status file %s: %v", f.Name(), err)
+		}
+		encrypted, err := encryptClusterSecrets(c)
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteYaml(encrypted, path, f.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cluster_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typesaws "github.com/cnrancher/autok3s/pkg/types/aws"
+
+	// register the provider whose credentials are encrypted.
+	_ "github.com/cnrancher/autok3s/pkg/providers/aws"
+)
+
+func TestClusterStateEncryptsSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autok3s-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := common.CfgPath
+	common.CfgPath = dir
+	defer func() {
+		common.CfgPath = origin
+		_ = os.RemoveAll(dir)
+	}()
+
+	c := &types.Cluster{
+		Metadata: types.Metadata{
+			Name:     "test.us-east-1.aws",
+			Provider: "aws",
+			Token:    "k3s-token",
+		},
+		Options: typesaws.Options{
+			AccessKey: "aws-access-key",
+			SecretKey: "aws-secret-key",
+			Region:    "us-east-1",
+		},
+		Status: types.Status{
+			MasterNodes: []types.Node{{SSH: types.SSH{Password: "ssh-password"}}},
+		},
+	}
+	if err := os.MkdirAll(common.GetClusterStatePath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusCreating); err != nil {
+		t.Fatalf("SaveClusterState() error: %v", err)
+	}
+
+	name := filepath.Join(common.GetClusterStatePath(), c.Name+"_"+common.StatusCreating)
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"k3s-token", "aws-access-key", "aws-secret-key", "ssh-password"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("secret %q is saved in plaintext", secret)
+		}
+	}
+	if !strings.Contains(string(b), "us-east-1") {
+		t.Errorf("options without secrets should be saved in plaintext")
+	}
+
+	got, err := cluster.ReadClusterState(name)
+	if err != nil {
+		t.Fatalf("ReadClusterState() error: %v", err)
+	}
+	options, ok := got.Options.(map[string]interface{})
+	if !ok {
+		t.Fatalf("options = %T, want map", got.Options)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want string
+	}{
+		{name: "token", got: got.Token, want: "k3s-token"},
+		{name: "access-key", got: options["access-key"], want: "aws-access-key"},
+		{name: "secret-key", got: options["secret-key"], want: "aws-secret-key"},
+		{name: "ssh password", got: got.MasterNodes[0].Password, want: "ssh-password"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("decrypted %s = %v, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
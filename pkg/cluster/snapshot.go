@@ -0,0 +1,242 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+)
+
+const (
+	snapshotDir            = "/var/lib/rancher/k3s/server/db/snapshots"
+	snapshotLocalDir       = "snapshots"
+	saveSnapshotCommand    = "sudo k3s etcd-snapshot %s"
+	latestSnapshotCommand  = "sudo sh -c 'ls -t %s/%s* | head -n 1'"
+	stopK3sCommand         = "sudo systemctl stop k3s"
+	startK3sCommand        = "sudo systemctl start k3s"
+	resetClusterCommand    = "sudo k3s server --cluster-reset --cluster-reset-restore-path=%s --token-file=%s"
+	removeTokenFileCommand = "sudo rm -f %s"
+	restoreTokenFile       = "/var/lib/rancher/k3s/server/restore-token"
+	removeDBCommand        = "sudo rm -rf /var/lib/rancher/k3s/server/db"
+	defaultSnapshotPrefix  = "on-demand"
+	snapshotTimeFormat     = "2006-01-02 15:04:05"
+	snapshotNameValidation = "^[a-zA-Z0-9][a-zA-Z0-9._-]*$"
+)
+
+var snapshotNameRegexp = regexp.MustCompile(snapshotNameValidation)
+
+// SaveSnapshot takes an etcd snapshot on the first master and copies it back to local.
+func SaveSnapshot(merged *types.Cluster, name string) (*types.Snapshot, error) {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing save etcd snapshot logic...\n", merged.Provider)
+
+	if name != "" && !snapshotNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("[cluster] invalid snapshot name %s, must match %s", name, snapshotNameValidation)
+	}
+	master, err := getEtcdMaster(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	args := ""
+	prefix := defaultSnapshotPrefix
+	if name != "" {
+		args = "--name " + name
+		prefix = name
+	}
+	if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(saveSnapshotCommand, args)}); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to save etcd snapshot on node %s: %v", master.InstanceID, err)
+	}
+
+	// k3s appends node name and timestamp to the snapshot name, pick the newest one.
+	remote, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(latestSnapshotCommand, snapshotDir, prefix)})
+	if err != nil {
+		return nil, fmt.Errorf("[cluster] failed to find etcd snapshot on node %s: %v", master.InstanceID, err)
+	}
+	remote = strings.TrimSpace(remote)
+	if remote == "" {
+		return nil, fmt.Errorf("[cluster] etcd snapshot %s is not found on node %s", prefix, master.InstanceID)
+	}
+
+	dir := getSnapshotPath(merged)
+	if err := utils.EnsureFolderExist(dir); err != nil {
+		return nil, err
+	}
+	local := filepath.Join(dir, filepath.Base(remote))
+	logger.Infof("[%s] copying etcd snapshot %s to %s\n", merged.Provider, remote, local)
+	if err := downloadFile(context.Background(), master, remote, local); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to copy etcd snapshot from node %s: %v", master.InstanceID, err)
+	}
+
+	info, err := os.Stat(local)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("[%s] successfully executed save etcd snapshot logic\n", merged.Provider)
+	return convertSnapshot(info), nil
+}
+
+// ListSnapshots returns the etcd snapshots saved in local, the newest one comes first.
+func ListSnapshots(merged *types.Cluster) ([]types.Snapshot, error) {
+	result := make([]types.Snapshot, 0)
+	infos, err := ioutil.ReadDir(getSnapshotPath(merged))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		result = append(result, *convertSnapshot(info))
+	}
+	return result, nil
+}
+
+// RestoreSnapshot restores the cluster from the local etcd snapshot:
+// stops k3s on all masters, resets the first master with the snapshot and re-joins the others.
+// k3s is started again on the stopped masters if the restore fails, so the cluster will not be left down.
+func RestoreSnapshot(merged *types.Cluster, name string) (err error) {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing restore etcd snapshot logic...\n", merged.Provider)
+
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("[cluster] invalid snapshot name %s, must match %s", name, snapshotNameValidation)
+	}
+	master, err := getEtcdMaster(merged)
+	if err != nil {
+		return err
+	}
+
+	local := filepath.Join(getSnapshotPath(merged), name)
+	if _, err := os.Stat(local); err != nil {
+		return fmt.Errorf("[cluster] failed to open etcd snapshot %s: %v", name, err)
+	}
+
+	remote := fmt.Sprintf("%s/%s", snapshotDir, name)
+	logger.Infof("[%s] copying etcd snapshot %s to node %s\n", merged.Provider, name, master.InstanceID)
+	if err := uploadFile(context.Background(), master, local, remote, 0600); err != nil {
+		return fmt.Errorf("[cluster] failed to copy etcd snapshot to node %s: %v", master.InstanceID, err)
+	}
+
+	// the token is passed through file, so it will not show up in process arguments of node.
+	if err := uploadContent(context.Background(), master, []byte(merged.Token), restoreTokenFile, 0600); err != nil {
+		return fmt.Errorf("[cluster] failed to copy token to node %s: %v", master.InstanceID, err)
+	}
+	defer func() {
+		if _, e := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(removeTokenFileCommand, restoreTokenFile)}); e != nil {
+			logger.Warnf("[%s] failed to remove token file on node %s: %v\n", merged.Provider, master.InstanceID, e)
+		}
+	}()
+
+	stopped := make([]types.Node, 0, len(merged.MasterNodes))
+	defer func() {
+		if err != nil {
+			rollbackRestore(merged.Provider, stopped)
+		}
+	}()
+	for _, node := range merged.MasterNodes {
+		logger.Infof("[%s] stopping k3s on node %s...\n", merged.Provider, node.InstanceID)
+		stopped = append(stopped, node)
+		if _, err := execute(&hosts.Host{Node: node}, []string{stopK3sCommand}); err != nil {
+			return fmt.Errorf("[cluster] failed to stop k3s on node %s: %v", node.InstanceID, err)
+		}
+	}
+
+	logger.Infof("[%s] resetting cluster on node %s with snapshot %s...\n", merged.Provider, master.InstanceID, name)
+	if _, err := execute(&hosts.Host{Node: master}, []string{fmt.Sprintf(resetClusterCommand, remote, restoreTokenFile), startK3sCommand}); err != nil {
+		return fmt.Errorf("[cluster] failed to reset cluster on node %s: %v", master.InstanceID, err)
+	}
+	// other masters can't be started with their old data after the cluster is reset, they must re-join it.
+	stopped = nil
+
+	for _, node := range merged.MasterNodes {
+		if node.InstanceID == master.InstanceID {
+			continue
+		}
+		// the data of other masters must be removed before re-joining the reset cluster.
+		logger.Infof("[%s] re-joining node %s...\n", merged.Provider, node.InstanceID)
+		if _, err := execute(&hosts.Host{Node: node}, []string{removeDBCommand, startK3sCommand}); err != nil {
+			return fmt.Errorf("[cluster] failed to re-join node %s: %v", node.InstanceID, err)
+		}
+	}
+
+	client, err := GetClusterConfig(merged.Name, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
+	if err != nil {
+		return err
+	}
+	if err := utils.WaitFor(func() (bool, error) {
+		return GetClusterStatus(client) == types.ClusterStatusRunning, nil
+	}); err != nil {
+		return fmt.Errorf("[cluster] cluster is not ready after restored: %v", err)
+	}
+
+	logger.Infof("[%s] successfully executed restore etcd snapshot logic\n", merged.Provider)
+	return nil
+}
+
+// rollbackRestore starts k3s on the masters stopped by the failed restore,
+// failures are only logged as the restore error will be returned.
+func rollbackRestore(provider string, stopped []types.Node) {
+	for _, node := range stopped {
+		logger.Infof("[%s] restore failed, starting k3s on node %s...\n", provider, node.InstanceID)
+		if _, err := execute(&hosts.Host{Node: node}, []string{startK3sCommand}); err != nil {
+			logger.Errorf("[%s] failed to start k3s on node %s: %v\n", provider, node.InstanceID, err)
+		}
+	}
+}
+
+// getEtcdMaster returns the first master, which is the one initialized the embedded etcd.
+func getEtcdMaster(merged *types.Cluster) (types.Node, error) {
+	if !merged.Cluster {
+		return types.Node{}, errors.New("[cluster] etcd snapshot is only supported by cluster with embedded etcd")
+	}
+	if len(merged.MasterNodes) <= 0 {
+		return types.Node{}, errors.New("[cluster] master node can not be empty")
+	}
+	for _, node := range merged.MasterNodes {
+		if isInitMaster(merged, node) {
+			return node, nil
+		}
+	}
+	return merged.MasterNodes[0], nil
+}
+
+func getSnapshotPath(merged *types.Cluster) string {
+	return filepath.Join(common.GetClusterPath(merged.Name, merged.Provider), snapshotLocalDir)
+}
+
+func convertSnapshot(info os.FileInfo) *types.Snapshot {
+	return &types.Snapshot{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Created: info.ModTime().Format(snapshotTimeFormat),
+	}
+}
@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	stateLockSuffix   = ".lock"
+	stateLockTimeout  = 30 * time.Second
+	stateLockInterval = 100 * time.Millisecond
+)
+
+// StateStore is the storage interface of cluster states.
+type StateStore interface {
+	// List returns all clusters in the store.
+	List() ([]types.Cluster, error)
+	// Get returns the cluster with the name and provider, nil is returned if not found.
+	Get(name, provider string) (*types.Cluster, error)
+	// Save inserts the cluster or replaces the existing one with the same name and provider.
+	Save(cluster *types.Cluster) error
+	// Delete removes the cluster with the name and provider.
+	Delete(name, provider string) error
+}
+
+var (
+	stateStore     StateStore
+	stateStoreKey  string
+	stateStoreLock sync.Mutex
+)
+
+// GetStateStore returns the state store of current cfg path and state backend.
+func GetStateStore() (StateStore, error) {
+	stateStoreLock.Lock()
+	defer stateStoreLock.Unlock()
+
+	if common.CfgPath == "" {
+		return nil, errors.New("[cluster] cfg path is empty")
+	}
+
+	key := fmt.Sprintf("%s:%s", common.StateBackend, common.CfgPath)
+	if stateStore != nil && stateStoreKey == key {
+		return stateStore, nil
+	}
+
+	var (
+		s   StateStore
+		err error
+	)
+	switch common.StateBackend {
+	case "", common.StateBackendFile:
+		s = NewFileStateStore(common.CfgPath)
+	case common.StateBackendBolt:
+		s, err = NewBoltStateStore(common.CfgPath)
+	default:
+		err = fmt.Errorf("[cluster] unsupported state backend %s, must be %s or %s",
+			common.StateBackend, common.StateBackendFile, common.StateBackendBolt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stateStore, stateStoreKey = s, key
+	return s, nil
+}
+
+type fileStateStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStateStore returns a state store saving clusters to the .state file under path.
+func NewFileStateStore(path string) StateStore {
+	return &fileStateStore{path: path}
+}
+
+func (s *fileStateStore) List() ([]types.Cluster, error) {
+	clusters, err := utils.ReadYaml(s.path, common.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	converts, err := ConvertToClusters(clusters)
+	if err != nil {
+		return nil, fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
+	}
+	for i := range converts {
+		if err := decryptClusterSecrets(&converts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return converts, nil
+}
+
+func (s *fileStateStore) Get(name, provider string) (*types.Cluster, error) {
+	clusters, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusters {
+		if clusters[i].Name == name && clusters[i].Provider == provider {
+			return &clusters[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fileStateStore) Save(cluster *types.Cluster) error {
+	return s.update(func(clusters []types.Cluster) ([]types.Cluster, error) {
+		for i, c := range clusters {
+			if c.Name == cluster.Name && c.Provider == cluster.Provider {
+				clusters[i] = *cluster
+				return clusters, nil
+			}
+		}
+		return append(clusters, *cluster), nil
+	})
+}
+
+func (s *fileStateStore) Delete(name, provider string) error {
+	return s.update(func(clusters []types.Cluster) ([]types.Cluster, error) {
+		for i, c := range clusters {
+			if c.Name == name && c.Provider == provider {
+				return append(clusters[:i], clusters[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("[cluster] was not found in the .state file")
+	})
+}
+
+// update reads, modifies and writes back the .state file while holding the lock,
+// so that concurrent CLI and server processes will not overwrite each other.
+func (s *fileStateStore) update(fn func([]types.Cluster) ([]types.Cluster, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(s.path, common.StateFile+stateLockSuffix))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	clusters, err := s.List()
+	if err != nil {
+		return err
+	}
+	clusters, err = fn(clusters)
+	if err != nil {
+		return err
+	}
+	encrypted := make([]types.Cluster, 0, len(clusters))
+	for i := range clusters {
+		c, err := encryptClusterSecrets(&clusters[i])
+		if err != nil {
+			return err
+		}
+		encrypted = append(encrypted, *c)
+	}
+
+	b, err := yaml.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.path, common.StateFile), b, 0644)
+}
+
+// lockFile holds the exclusive lock of the lock file, the lock is released by the OS if the process crashes,
+// so there is no stale lock to clean up.
+func lockFile(name string) (func(), error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("[cluster] failed to open lock file %s: %v", name, err)
+	}
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("[cluster] failed to lock file %s: %v", name, err)
+		}
+		if locked {
+			return func() {
+				_ = unlockFile(f)
+				_ = f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("[cluster] timeout waiting for lock file %s, another autok3s process is updating the state", name)
+		}
+		time.Sleep(stateLockInterval)
+	}
+}
+
+// writeFileAtomic writes data to a temp file and renames it to the target,
+// so the target will never be partially written.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// ListClustersByCredential returns names of clusters which use the credential profile of provider.
+func ListClustersByCredential(provider, profile string) ([]string, error) {
+	store, err := GetStateStore()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, c := range clusters {
+		if c.Provider != provider {
+			continue
+		}
+		if c.Credential == profile || (isDefaultProfile(c.Credential) && isDefaultProfile(profile)) {
+			names = append(names, c.Name)
+		}
+	}
+	return names, nil
+}
+
+func isDefaultProfile(profile string) bool {
+	return profile == "" || profile == common.DefaultProfile
+}
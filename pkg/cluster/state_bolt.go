@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const stateBackupSuffix = ".bak"
+
+var clustersBucket = []byte("clusters")
+
+type boltStateStore struct {
+	path string
+}
+
+// NewBoltStateStore returns a state store saving clusters to the .state.db file under path,
+// clusters in the existing .state file will be migrated to it.
+func NewBoltStateStore(path string) (StateStore, error) {
+	s := &boltStateStore{path: path}
+	if err := s.update(func(b *bolt.Bucket) error {
+		return s.migrate(b)
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the database for each access instead of keeping it open, because the database is locked exclusively
+// while it's open, which would block other autok3s processes, e.g. CLI commands while the server is running.
+func (s *boltStateStore) open() (*bolt.DB, error) {
+	db, err := bolt.Open(filepath.Join(s.path, common.StateDBFile), 0600, &bolt.Options{Timeout: stateLockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("[cluster] failed to open state database: %v", err)
+	}
+	return db, nil
+}
+
+func (s *boltStateStore) view(fn func(b *bolt.Bucket) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clustersBucket)
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+func (s *boltStateStore) update(fn func(b *bolt.Bucket) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(clustersBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// migrate imports clusters from the .state file and renames it to .state.bak.
+func (s *boltStateStore) migrate(b *bolt.Bucket) error {
+	name := filepath.Join(s.path, common.StateFile)
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return nil
+	}
+
+	origin, err := utils.ReadYaml(s.path, common.StateFile)
+	if err != nil {
+		return err
+	}
+	clusters, err := ConvertToClusters(origin)
+	if err != nil {
+		return fmt.Errorf("[cluster] failed to unmarshal state file, msg: %s", err)
+	}
+	for i := range clusters {
+		key := clusterKey(clusters[i].Name, clusters[i].Provider)
+		// clusters already in database are newer than the ones in .state file.
+		if b.Get(key) != nil {
+			continue
+		}
+		data, err := json.Marshal(clusters[i])
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, data); err != nil {
+			return fmt.Errorf("[cluster] failed to migrate cluster %s to state database: %v", clusters[i].Name, err)
+		}
+	}
+
+	return os.Rename(name, name+stateBackupSuffix)
+}
+
+func (s *boltStateStore) List() ([]types.Cluster, error) {
+	result := make([]types.Cluster, 0)
+	err := s.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, data []byte) error {
+			c, err := unmarshalClusterState(data)
+			if err != nil {
+				return err
+			}
+			result = append(result, *c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStateStore) Get(name, provider string) (*types.Cluster, error) {
+	var c *types.Cluster
+	err := s.view(func(b *bolt.Bucket) error {
+		data := b.Get(clusterKey(name, provider))
+		if data == nil {
+			return nil
+		}
+		var err error
+		c, err = unmarshalClusterState(data)
+		return err
+	})
+	return c, err
+}
+
+func (s *boltStateStore) Save(cluster *types.Cluster) error {
+	encrypted, err := encryptClusterSecrets(cluster)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put(clusterKey(cluster.Name, cluster.Provider), data)
+	})
+}
+
+func (s *boltStateStore) Delete(name, provider string) error {
+	return s.update(func(b *bolt.Bucket) error {
+		key := clusterKey(name, provider)
+		if b.Get(key) == nil {
+			return fmt.Errorf("[cluster] was not found in the state database")
+		}
+		return b.Delete(key)
+	})
+}
+
+// clusterKey returns the key of cluster in the database, cluster names never contain `/`.
+func clusterKey(name, provider string) []byte {
+	return []byte(provider + "/" + name)
+}
+
+func unmarshalClusterState(data []byte) (*types.Cluster, error) {
+	c := &types.Cluster{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("[cluster] failed to unmarshal cluster state, msg: %s", err)
+	}
+	if err := decryptClusterSecrets(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,241 @@
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/secrets"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+)
+
+// setupCfgPath points cfg path to a temp dir, the returned func restores it.
+func setupCfgPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "autok3s-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := common.CfgPath
+	common.CfgPath = dir
+	return dir, func() {
+		common.CfgPath = origin
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func testCluster(name, provider string) *types.Cluster {
+	return &types.Cluster{
+		Metadata: types.Metadata{
+			Name:     name,
+			Provider: provider,
+			Master:   "1",
+			Worker:   "0",
+			Token:    "token",
+		},
+		Status: types.Status{
+			MasterNodes: []types.Node{{
+				SSH: types.SSH{
+					User:     "root",
+					Password: "password",
+					SSHKey:   "ssh-key",
+				},
+				InstanceID: "master-0",
+				Master:     true,
+			}},
+		},
+	}
+}
+
+func TestStateStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(path string) (StateStore, error)
+	}{
+		{
+			name: common.StateBackendFile,
+			store: func(path string) (StateStore, error) {
+				return NewFileStateStore(path), nil
+			},
+		},
+		{
+			name:  common.StateBackendBolt,
+			store: NewBoltStateStore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup := setupCfgPath(t)
+			defer cleanup()
+			s, err := tt.store(dir)
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+
+			if c, err := s.Get("missing", "aws"); err != nil || c != nil {
+				t.Fatalf("Get() of missing cluster = %v, %v, want nil, nil", c, err)
+			}
+
+			a, b := testCluster("a.cn-hangzhou.alibaba", "alibaba"), testCluster("b.us-east-1.aws", "aws")
+			for _, c := range []*types.Cluster{a, b} {
+				if err := s.Save(c); err != nil {
+					t.Fatalf("Save(%s) error: %v", c.Name, err)
+				}
+			}
+			got, err := s.Get(a.Name, a.Provider)
+			if err != nil {
+				t.Fatalf("Get() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, a) {
+				t.Errorf("Get() = %+v, want %+v", got, a)
+			}
+
+			a.Worker = "2"
+			if err := s.Save(a); err != nil {
+				t.Fatalf("Save() of existing cluster error: %v", err)
+			}
+			list, err := s.List()
+			if err != nil {
+				t.Fatalf("List() error: %v", err)
+			}
+			if len(list) != 2 {
+				t.Fatalf("List() returns %d clusters, want 2", len(list))
+			}
+			for _, c := range list {
+				if c.Name == a.Name && c.Worker != "2" {
+					t.Errorf("cluster %s is not replaced by Save(), worker = %s", c.Name, c.Worker)
+				}
+			}
+
+			if err := s.Delete(b.Name, b.Provider); err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+			if err := s.Delete(b.Name, b.Provider); err == nil {
+				t.Errorf("Delete() of missing cluster should fail")
+			}
+			if c, err := s.Get(b.Name, b.Provider); err != nil || c != nil {
+				t.Errorf("Get() of deleted cluster = %v, %v, want nil, nil", c, err)
+			}
+		})
+	}
+}
+
+func TestStateStoreEncryptsSecrets(t *testing.T) {
+	dir, cleanup := setupCfgPath(t)
+	defer cleanup()
+	if err := NewFileStateStore(dir).Save(testCluster("a.cn-hangzhou.alibaba", "alibaba")); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	clusters, err := ConvertToClusters(mustReadYaml(t, dir, common.StateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := clusters[0].MasterNodes[0]
+	for _, v := range []string{node.Password, node.SSHKey} {
+		if !secrets.IsEncrypted(v) {
+			t.Errorf("secret %q is saved in plaintext", v)
+		}
+	}
+}
+
+func TestBoltStateStoreMigrate(t *testing.T) {
+	dir, cleanup := setupCfgPath(t)
+	defer cleanup()
+	c := testCluster("a.cn-hangzhou.alibaba", "alibaba")
+	if err := NewFileStateStore(dir).Save(c); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	s, err := NewBoltStateStore(dir)
+	if err != nil {
+		t.Fatalf("NewBoltStateStore() error: %v", err)
+	}
+	got, err := s.Get(c.Name, c.Provider)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("migrated cluster = %+v, want %+v", got, c)
+	}
+	if _, err := os.Stat(filepath.Join(dir, common.StateFile)); !os.IsNotExist(err) {
+		t.Errorf(".state file should be renamed after migration, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, common.StateFile+stateBackupSuffix)); err != nil {
+		t.Errorf(".state file should be backed up: %v", err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	dir, cleanup := setupCfgPath(t)
+	defer cleanup()
+	name := filepath.Join(dir, common.StateFile+stateLockSuffix)
+
+	unlock, err := lockFile(name)
+	if err != nil {
+		t.Fatalf("lockFile() error: %v", err)
+	}
+	acquired := make(chan struct{})
+	go func() {
+		unlockSecond, err := lockFile(name)
+		if err != nil {
+			t.Errorf("lockFile() after unlock error: %v", err)
+			close(acquired)
+			return
+		}
+		unlockSecond()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock is acquired while it's held")
+	case <-time.After(3 * stateLockInterval):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(stateLockTimeout):
+		t.Fatal("lock is not acquired after it's released")
+	}
+}
+
+func TestFileStateStoreConcurrentSave(t *testing.T) {
+	dir, cleanup := setupCfgPath(t)
+	defer cleanup()
+
+	// each store has its own mutex, so they are only serialized by the lock file like different processes.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := NewFileStateStore(dir).Save(testCluster(fmt.Sprintf("c%d.us-east-1.aws", i), "aws")); err != nil {
+				t.Errorf("Save() error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	list, err := NewFileStateStore(dir).List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(list) != 10 {
+		t.Errorf("List() returns %d clusters, want 10", len(list))
+	}
+}
+
+func mustReadYaml(t *testing.T, path, name string) []interface{} {
+	result, err := utils.ReadYaml(path, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+// NodePath is a path on node of cluster in the format of <cluster>:<node>:<path>,
+// node is matched by instance id, public ip or internal ip.
+type NodePath struct {
+	Cluster string
+	Node    string
+	Path    string
+}
+
+// ParseNodePath parses s as the path on node, false is returned if s is a local path.
+func ParseNodePath(s string) (*NodePath, bool) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return nil, false
+	}
+	return &NodePath{Cluster: parts[0], Node: parts[1], Path: parts[2]}, true
+}
+
+// CopyFile copies the file between local and node of clusters in state, one of src and dst must be a node path.
+func CopyFile(ctx context.Context, src, dst string, opts hosts.TransferOptions) error {
+	srcNode, srcRemote := ParseNodePath(src)
+	dstNode, dstRemote := ParseNodePath(dst)
+	switch {
+	case srcRemote && dstRemote:
+		return fmt.Errorf("[cluster] copying files between nodes is not supported")
+	case !srcRemote && !dstRemote:
+		return fmt.Errorf("[cluster] one of source and destination must be in the format of <cluster>:<node>:<path>")
+	case dstRemote:
+		node, err := getNodeByPath(dstNode)
+		if err != nil {
+			return err
+		}
+		remote := dstNode.Path
+		if remote == "" || strings.HasSuffix(remote, "/") {
+			remote = path.Join(remote, filepath.Base(src))
+		}
+		if err := withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+			return tunnel.UploadContext(ctx, src, remote, opts)
+		}); err != nil {
+			return fmt.Errorf("[cluster] failed to copy %s to %s: %w", src, dst, err)
+		}
+		return nil
+	default:
+		node, err := getNodeByPath(srcNode)
+		if err != nil {
+			return err
+		}
+		if srcNode.Path == "" {
+			return fmt.Errorf("[cluster] path of %s is required", src)
+		}
+		local := dst
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			local = filepath.Join(local, path.Base(srcNode.Path))
+		}
+		if err := withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+			return tunnel.DownloadContext(ctx, srcNode.Path, local, opts)
+		}); err != nil {
+			return fmt.Errorf("[cluster] failed to copy %s to %s: %w", src, dst, err)
+		}
+		return nil
+	}
+}
+
+// getNodeByPath returns the node in state which the node path refers to,
+// the cluster is matched by the full name or the name without region and provider.
+func getNodeByPath(p *NodePath) (types.Node, error) {
+	store, err := GetStateStore()
+	if err != nil {
+		return types.Node{}, err
+	}
+	clusters, err := store.List()
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	matched := make([]types.Cluster, 0)
+	for _, c := range clusters {
+		if c.Name == p.Cluster {
+			matched = []types.Cluster{c}
+			break
+		}
+		if strings.Split(c.Name, ".")[0] == p.Cluster {
+			matched = append(matched, c)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return types.Node{}, fmt.Errorf("[cluster] cluster %s is not found", p.Cluster)
+	case 1:
+	default:
+		return types.Node{}, fmt.Errorf("[cluster] cluster name %s is ambiguous, please use the full name", p.Cluster)
+	}
+
+	c := matched[0]
+	for _, nodes := range [][]types.Node{c.MasterNodes, c.WorkerNodes} {
+		for _, n := range nodes {
+			if n.InstanceID == p.Node || containsString(n.PublicIPAddress, p.Node) || containsString(n.InternalIPAddress, p.Node) {
+				return n, nil
+			}
+		}
+	}
+	return types.Node{}, fmt.Errorf("[cluster] node %s is not found in cluster %s", p.Node, c.Name)
+}
+
+// uploadFile copies the local file to the node as root.
+func uploadFile(ctx context.Context, node types.Node, local, remote string, mode os.FileMode) error {
+	return withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		return tunnel.UploadContext(ctx, local, remote, hosts.TransferOptions{Mode: mode, Sudo: true})
+	})
+}
+
+// uploadContent writes the content to the file on node as root.
+func uploadContent(ctx context.Context, node types.Node, content []byte, remote string, mode os.FileMode) error {
+	return withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		return tunnel.UploadReader(bytes.NewReader(content), int64(len(content)), remote, hosts.TransferOptions{Mode: mode, Sudo: true})
+	})
+}
+
+// downloadFile copies the file owned by root on node to local.
+func downloadFile(ctx context.Context, node types.Node, remote, local string) error {
+	return withTunnel(ctx, node, func(tunnel *hosts.Tunnel) error {
+		return tunnel.DownloadContext(ctx, remote, local, hosts.TransferOptions{Sudo: true})
+	})
+}
+
+func withTunnel(ctx context.Context, node types.Node, fn func(tunnel *hosts.Tunnel) error) error {
+	dialer, err := hosts.SSHDialer(&hosts.Host{Node: node})
+	if err != nil {
+		return err
+	}
+	tunnel, err := dialer.OpenTunnelContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tunnel.Close()
+	}()
+	return fn(tunnel)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+// nodeVersionInterval is the interval of checking whether the upgraded node is ready in the new version.
+const nodeVersionInterval = 10 * time.Second
+
+func UpgradeK3sCluster(ctx context.Context, merged *types.Cluster, version string) error {
+	if merged.Logger != nil {
+		logger = merged.Logger
+	} else {
+		logger = common.NewLogger(common.Debug, nil)
+	}
+
+	logger.Infof("[%s] executing upgrade k3s cluster logic\n", merged.Provider)
+	ReportPhase(ctx, types.PhaseUpgrading)
+
+	if version == "" {
+		return errors.New("[cluster] k3s version can not be empty")
+	}
+	if merged.Token == "" {
+		return errors.New("[cluster] k3s token can not be empty")
+	}
+	if err := CheckAirGap(merged.AirGapDir); err != nil {
+		return err
+	}
+	if merged.IP == "" {
+		if len(merged.MasterNodes) <= 0 || len(merged.MasterNodes[0].InternalIPAddress) <= 0 {
+			return errors.New("[cluster] master node internal ip address can not be empty")
+		}
+		merged.IP = merged.MasterNodes[0].InternalIPAddress[0]
+	}
+
+	p, err := providers.GetProvider(merged.Provider)
+	if err != nil {
+		return err
+	}
+
+	client, err := GetClusterConfig(merged.Name, fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile))
+	if err != nil {
+		return err
+	}
+
+	// upgrade masters one at a time, then the workers.
+	nodes := make([]types.Node, 0, len(merged.MasterNodes)+len(merged.WorkerNodes))
+	nodes = append(nodes, merged.MasterNodes...)
+	nodes = append(nodes, merged.WorkerNodes...)
+
+	// record current versions of nodes for rolling back.
+	previous := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		kubeNode, err := getKubeNode(client, node)
+		if err != nil {
+			return err
+		}
+		if kubeNode == nil {
+			return fmt.Errorf("[cluster] node %s is not registered in k3s cluster", node.InstanceID)
+		}
+		previous[node.InstanceID] = kubeNode.Status.NodeInfo.KubeletVersion
+	}
+
+	// only the binary in the air-gap directory can be installed, check it's the upgrading version before draining any node.
+	if len(nodes) > 0 {
+		if err := checkAirGapVersion(ctx, merged, nodes[0], version); err != nil {
+			return err
+		}
+	}
+
+	tasks := make([]nodeTask, 0, len(nodes))
+	for _, node := range nodes {
+		task := nodeTask{name: node.InstanceID, node: node}
+		reportNode(ctx, task.progress(types.OperationPending, nil))
+		tasks = append(tasks, task)
+	}
+
+	upgraded := make([]types.Node, 0, len(nodes))
+	for _, task := range tasks {
+		node := task.node
+		if previous[node.InstanceID] == version {
+			logger.Infof("[%s] node %s is already in version %s, skip upgrading\n", merged.Provider, node.InstanceID, version)
+			reportNode(ctx, task.progress(types.OperationSucceeded, nil))
+			continue
+		}
+		// nodes are left in their current versions once canceled, the upgraded ones are rolled back.
+		if err := ctx.Err(); err != nil {
+			logger.Errorf("[%s] upgrading is canceled before node %s\n", merged.Provider, node.InstanceID)
+			rollbackUpgrade(p, client, merged, upgraded, previous)
+			return err
+		}
+		upgraded = append(upgraded, node)
+		reportNode(ctx, task.progress(types.OperationRunning, nil))
+		logger.Infof("[%s] upgrading node %s from %s to %s...\n", merged.Provider, node.InstanceID, previous[node.InstanceID], version)
+		if err := upgradeNode(ctx, p, client, merged, node, version); err != nil {
+			logger.Errorf("[%s] failed to upgrade node %s: %v\n", merged.Provider, node.InstanceID, err)
+			if ctx.Err() != nil {
+				reportNode(ctx, task.progress(types.OperationCanceled, nil))
+			} else {
+				reportNode(ctx, task.progress(types.OperationFailed, err))
+			}
+			rollbackUpgrade(p, client, merged, upgraded, previous)
+			return err
+		}
+		reportNode(ctx, task.progress(types.OperationSucceeded, nil))
+		logger.Infof("[%s] successfully upgraded node %s\n", merged.Provider, node.InstanceID)
+	}
+
+	merged.K3sVersion = version
+
+	// write current cluster to state file.
+	if err := SaveState(merged); err != nil {
+		return err
+	}
+
+	logger.Infof("[%s] successfully executed upgrade k3s cluster logic\n", merged.Provider)
+	return nil
+}
+
+func upgradeNode(ctx context.Context, p providers.Provider, client *kubernetes.Clientset, merged *types.Cluster, node types.Node, version string) error {
+	kubeNode, err := getKubeNode(client, node)
+	if err != nil {
+		return err
+	}
+	if kubeNode == nil {
+		return fmt.Errorf("[cluster] node %s is not registered in k3s cluster", node.InstanceID)
+	}
+
+	logger.Infof("[%s] draining k3s node %s...\n", merged.Provider, kubeNode.Name)
+	if err := drainNode(client, kubeNode); err != nil {
+		return err
+	}
+
+	if err := prepareAirGap(ctx, merged, node); err != nil {
+		return err
+	}
+
+	cmd := genInstallCommand(p, merged, node, version, "")
+	logger.Debugf("[cluster] k3s upgrade command: %s\n", cmd)
+	if _, err := executeWithContext(ctx, &hosts.Host{Node: node}, []string{cmd}); err != nil {
+		return err
+	}
+
+	if err := waitForNodeVersion(client, node, version); err != nil {
+		return err
+	}
+
+	// uncordon node after upgraded.
+	kubeNode, err = getKubeNode(client, node)
+	if err != nil {
+		return err
+	}
+	if kubeNode == nil {
+		return fmt.Errorf("[cluster] node %s is not registered in k3s cluster", node.InstanceID)
+	}
+	if err := drain.RunCordonOrUncordon(&drain.Helper{Client: client, Out: logger.Out, ErrOut: logger.Out}, kubeNode, false); err != nil {
+		return fmt.Errorf("[cluster] failed to uncordon k3s node %s: %v", kubeNode.Name, err)
+	}
+
+	return nil
+}
+
+func rollbackUpgrade(p providers.Provider, client *kubernetes.Clientset, merged *types.Cluster, upgraded []types.Node, previous map[string]string) {
+	logger.Infof("[%s] executing rollback upgrade logic...\n", merged.Provider)
+
+	// the air-gap directory only contains the binary of upgrading version, previous versions can't be installed.
+	if merged.AirGapDir != "" {
+		for _, node := range upgraded {
+			logger.Errorf("[%s] rolling back is not supported in air-gap mode, node %s must be rolled back to %s manually\n",
+				merged.Provider, node.InstanceID, previous[node.InstanceID])
+		}
+		return
+	}
+
+	for i := len(upgraded) - 1; i >= 0; i-- {
+		node := upgraded[i]
+		version := previous[node.InstanceID]
+		if version == "" {
+			logger.Warnf("[%s] previous version of node %s is unknown, skip rolling back\n", merged.Provider, node.InstanceID)
+			continue
+		}
+		logger.Infof("[%s] rolling back node %s to %s...\n", merged.Provider, node.InstanceID, version)
+		// rolling back is not canceled with the upgrading, otherwise the cluster is left in mixed versions.
+		if err := upgradeNode(context.Background(), p, client, merged, node, version); err != nil {
+			logger.Errorf("[%s] failed to roll back node %s: %v\n", merged.Provider, node.InstanceID, err)
+		}
+	}
+
+	logger.Infof("[%s] successfully executed rollback upgrade logic\n", merged.Provider)
+}
+
+// genInstallCommand generates the k3s install command of the existing node according to its role,
+// which is used to upgrade or re-join the node.
+func genInstallCommand(p providers.Provider, merged *types.Cluster, node types.Node, version, channel string) string {
+	publicIP := node.ExternalIP()
+
+	if !node.Master {
+		extraArgs := fmt.Sprintf("--node-external-ip %s %s", publicIP, merged.WorkerExtraArgs)
+		extraArgs += p.GenerateWorkerExtraArgs(merged, node)
+		return fmt.Sprintf(joinCommand, installScript(merged), installEnv(merged), merged.IP, merged.Token,
+			strings.TrimSpace(extraArgs), genK3sVersion(version, channel))
+	}
+
+	extraArgs := merged.MasterExtraArgs
+	if merged.DataStore != "" {
+		extraArgs += " --datastore-endpoint " + merged.DataStore
+	}
+	if merged.Network != "" {
+		extraArgs += fmt.Sprintf(" --flannel-backend=%s", merged.Network)
+	}
+	if merged.ClusterCIDR != "" {
+		extraArgs += " --cluster-cidr " + merged.ClusterCIDR
+	}
+	extraArgs += p.GenerateMasterExtraArgs(merged, node)
+
+	// the first master is initialized by init command, others are joined to it.
+	if isInitMaster(merged, node) {
+		if merged.Cluster {
+			extraArgs += " --cluster-init"
+		}
+		return fmt.Sprintf(initCommand, installScript(merged), installEnv(merged), merged.Token, publicIP, publicIP,
+			strings.TrimSpace(extraArgs), genK3sVersion(version, channel))
+	}
+
+	extraArgs = fmt.Sprintf("server --server https://%s:6443 --tls-san %s --node-external-ip %s %s", merged.IP, publicIP, publicIP, extraArgs)
+	return fmt.Sprintf(joinCommand, installScript(merged), installEnv(merged), merged.IP, merged.Token,
+		strings.TrimSpace(extraArgs), genK3sVersion(version, channel))
+}
+
+// isInitMaster returns whether the node is the master initialized the cluster,
+// which is found by the ip of cluster if the state is saved before the init master is recorded.
+func isInitMaster(merged *types.Cluster, node types.Node) bool {
+	if merged.Status.InitMaster != "" {
+		return node.InstanceID == merged.Status.InitMaster
+	}
+	return len(node.InternalIPAddress) > 0 && node.InternalIPAddress[0] == merged.IP
+}
+
+func waitForNodeVersion(client *kubernetes.Clientset, node types.Node, version string) error {
+	instance := []types.ClusterNode{{InstanceID: node.InstanceID, InternalIP: node.InternalIPAddress}}
+	backoff := wait.Backoff{
+		Duration: nodeVersionInterval,
+		Factor:   1,
+		Steps:    int(common.UpgradeNodeTimeout / nodeVersionInterval),
+	}
+	if err := utils.WaitForBackoff(func() (bool, error) {
+		nodes, err := DescribeClusterNodes(client, instance)
+		if err != nil || len(nodes) == 0 {
+			// k3s api server may be unavailable while master is restarting.
+			return false, nil
+		}
+		return nodes[0].Version == version && nodes[0].Status == "Ready", nil
+	}, backoff); err != nil {
+		return fmt.Errorf("[cluster] node %s is not ready with version %s: %v", node.InstanceID, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+// stubProvider generates no extra args of provider for the k3s install commands.
+type stubProvider struct {
+	providers.Provider
+}
+
+func (stubProvider) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	return ""
+}
+
+func (stubProvider) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return ""
+}
+
+// lbCluster returns the cluster with embedded etcd created with the ip of a load balancer,
+// the init master isn't the first master in its status.
+func lbCluster() *types.Cluster {
+	return &types.Cluster{
+		Metadata: types.Metadata{Name: "fake", Provider: "fake", Token: "token", IP: "192.0.2.100", Cluster: true},
+		Status: types.Status{
+			MasterNodes: []types.Node{
+				{InstanceID: "master-1", Master: true, InternalIPAddress: []string{"10.0.0.2"}, PublicIPAddress: []string{"203.0.113.2"}},
+				{InstanceID: "master-0", Master: true, InternalIPAddress: []string{"10.0.0.1"}, PublicIPAddress: []string{"203.0.113.1"}},
+			},
+			InitMaster: "master-0",
+		},
+	}
+}
+
+func TestGenInstallCommandOfInitMaster(t *testing.T) {
+	merged := lbCluster()
+	for _, node := range merged.MasterNodes {
+		cmd := genInstallCommand(stubProvider{}, merged, node, "v1.19.5+k3s1", "")
+		init := strings.Contains(cmd, "--cluster-init")
+		join := strings.Contains(cmd, "--server https://192.0.2.100:6443")
+		if node.InstanceID == merged.Status.InitMaster && (!init || join) {
+			t.Errorf("init master %s isn't installed by init command: %s", node.InstanceID, cmd)
+		}
+		if node.InstanceID != merged.Status.InitMaster && (init || !join) {
+			t.Errorf("master %s isn't joined to the cluster ip: %s", node.InstanceID, cmd)
+		}
+	}
+}
+
+func TestGetEtcdMaster(t *testing.T) {
+	merged := lbCluster()
+	node, err := getEtcdMaster(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.InstanceID != "master-0" {
+		t.Errorf("etcd master is %s, expected master-0", node.InstanceID)
+	}
+
+	// the init master of state saved before it's recorded is found by the ip of cluster.
+	merged.Status.InitMaster = ""
+	merged.IP = "10.0.0.1"
+	node, err = getEtcdMaster(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.InstanceID != "master-0" {
+		t.Errorf("etcd master of legacy state is %s, expected master-0", node.InstanceID)
+	}
+}
@@ -11,8 +11,16 @@ import (
 
 const (
 	BindPrefix         = "autok3s.providers.%s.%s"
+	ProfileBindPrefix  = "autok3s.profiles.%s.%s.%s"
+	ProfilePrefix      = "autok3s.profiles.%s"
+	DefaultProfile     = "default"
 	ConfigFile         = "config.yaml"
 	StateFile          = ".state"
+	StateDBFile        = ".state.db"
+	HistoryFile        = ".history"
+	StateBackendFile   = "file"
+	StateBackendBolt   = "bolt"
+	MasterKeyFileName  = ".master.key"
 	KubeCfgFile        = ".kube/config"
 	KubeCfgTempName    = "autok3s-temp"
 	K3sManifestsDir    = "/var/lib/rancher/k3s/server/manifests"
@@ -23,6 +31,8 @@ const (
 	StatusStopped      = "Stopped"
 	StatusCreating     = "Creating"
 	StatusJoin         = "Join"
+	StatusUpgrade      = "Upgrade"
+	StatusRestore      = "Restore"
 	StatusFailed       = "Failed"
 	UsageInfoTitle     = "=========================== Prompt Info ==========================="
 	UsageContext       = "Use 'autok3s kubectl config use-context %s'"
@@ -37,6 +47,17 @@ var (
 		Factor:   1,
 		Steps:    5,
 	} // retry 5 times, total 120 seconds.
+	// StateBackend is the backend to store cluster states, supports file and bolt.
+	StateBackend = StateBackendFile
+	// Concurrency is the max number of nodes to install k3s concurrently.
+	Concurrency = 5
+	// MasterKeyFile is the file of master key to encrypt secrets, defaults to .master.key under cfg path.
+	MasterKeyFile = ""
+	// MasterKeyring reads and saves master key in the OS keyring instead of the key file.
+	MasterKeyring = false
+	// UpgradeNodeTimeout is the timeout of waiting for each node to be ready in the new version when upgrading,
+	// which includes downloading k3s and restarting it.
+	UpgradeNodeTimeout = 10 * time.Minute
 )
 
 func GetDefaultSSHKeyPath(clusterName, providerName string) string {
@@ -47,6 +68,13 @@ func GetClusterPath(clusterName, providerName string) string {
 	return filepath.Join(CfgPath, providerName, "clusters", clusterName)
 }
 
+func GetMasterKeyFile() string {
+	if MasterKeyFile != "" {
+		return MasterKeyFile
+	}
+	return filepath.Join(CfgPath, MasterKeyFileName)
+}
+
 func GetClusterStatePath() string {
 	return filepath.Join(CfgPath, "clusters")
 }
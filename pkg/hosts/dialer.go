@@ -1,6 +1,7 @@
 package hosts
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -33,6 +34,8 @@ type Dialer struct {
 	netConn    string
 
 	useSSHAgentAuth bool
+
+	bastion *Dialer
 }
 
 type DialersOptions struct {
@@ -44,18 +47,29 @@ func SSHDialer(h *Host) (*Dialer, error) {
 }
 
 func (d *Dialer) OpenTunnel(timeout bool) (*Tunnel, error) {
+	return d.OpenTunnelContext(context.Background(), timeout)
+}
+
+// OpenTunnelContext opens the ssh tunnel with retries, which stops retrying once ctx is done.
+func (d *Dialer) OpenTunnelContext(ctx context.Context, timeout bool) (*Tunnel, error) {
 	wait.ErrWaitTimeout = fmt.Errorf("[dialer] calling openTunnel error. address [%s]", d.sshAddress)
 
 	var conn *ssh.Client
 	var err error
 
 	if err := wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
 		conn, err = d.getSSHTunnelConnection(timeout)
 		if err != nil {
 			return false, nil
 		}
 		return true, nil
 	}); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, fmt.Errorf("[dialer] failed to open ssh tunnel using address [%s]: %v", d.sshAddress, err)
 	}
 
@@ -65,12 +79,12 @@ func (d *Dialer) OpenTunnel(timeout bool) (*Tunnel, error) {
 func newDialer(h *Host, kind string) (*Dialer, error) {
 	var d *Dialer
 
-	if len(h.PublicIPAddress) <= 0 {
+	if h.ExternalIP() == "" {
 		return nil, errors.New("[dialer] no node IP is specified")
 	}
 
 	d = &Dialer{
-		sshAddress:      fmt.Sprintf("%s:%s", h.PublicIPAddress[0], h.Port),
+		sshAddress:      fmt.Sprintf("%s:%s", h.ExternalIP(), h.Port),
 		username:        h.User,
 		password:        h.Password,
 		passphrase:      h.SSHKeyPassphrase,
@@ -99,9 +113,53 @@ func newDialer(h *Host, kind string) (*Dialer, error) {
 		d.netConn = tcpNetProtocol
 	}
 
+	// the node is dialed through the last bastion, which is dialed through the previous one.
+	for _, b := range h.Bastions {
+		bastion, err := newBastionDialer(h, b, kind)
+		if err != nil {
+			return nil, fmt.Errorf("[dialer] invalid bastion [%s]: %v", b.Host, err)
+		}
+		bastion.bastion = d.bastion
+		d.bastion = bastion
+	}
+
 	return d, nil
 }
 
+func newBastionDialer(h *Host, b types.Bastion, kind string) (*Dialer, error) {
+	if b.Port == "" {
+		b.Port = "22"
+	}
+	// bastion uses the credentials of node if not specified.
+	if b.User == "" {
+		b.User = h.User
+	}
+	if b.Password == "" && b.SSHKey == "" && b.SSHKeyPath == "" && !b.SSHAgentAuth {
+		b.Password = h.Password
+		b.SSHKey = h.SSHKey
+		b.SSHKeyPath = h.SSHKeyPath
+		b.SSHCert = h.SSHCert
+		b.SSHCertPath = h.SSHCertPath
+		b.SSHKeyPassphrase = h.SSHKeyPassphrase
+		b.SSHAgentAuth = h.SSHAgentAuth
+	}
+
+	return newDialer(&Host{Node: types.Node{
+		PublicIPAddress: []string{b.Host},
+		SSH: types.SSH{
+			Port:             b.Port,
+			User:             b.User,
+			Password:         b.Password,
+			SSHKey:           b.SSHKey,
+			SSHKeyPath:       b.SSHKeyPath,
+			SSHCert:          b.SSHCert,
+			SSHCertPath:      b.SSHCertPath,
+			SSHKeyPassphrase: b.SSHKeyPassphrase,
+			SSHAgentAuth:     b.SSHAgentAuth,
+		},
+	}}, kind)
+}
+
 func (d *Dialer) getSSHTunnelConnection(t bool) (*ssh.Client, error) {
 	timeout := time.Duration((common.Backoff.Steps - 1) * int(common.Backoff.Duration))
 	if !t {
@@ -112,6 +170,31 @@ func (d *Dialer) getSSHTunnelConnection(t bool) (*ssh.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	// establish connection with SSH server.
-	return ssh.Dial(tcpNetProtocol, d.sshAddress, cfg)
+	if d.bastion == nil {
+		// establish connection with SSH server.
+		return ssh.Dial(tcpNetProtocol, d.sshAddress, cfg)
+	}
+
+	// establish connection with SSH server through the bastion, which may be connected through other bastions.
+	bastion, err := d.bastion.getSSHTunnelConnection(t)
+	if err != nil {
+		return nil, fmt.Errorf("[dialer] failed to connect bastion [%s]: %v", d.bastion.sshAddress, err)
+	}
+	conn, err := bastion.Dial(tcpNetProtocol, d.sshAddress)
+	if err != nil {
+		_ = bastion.Close()
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.sshAddress, cfg)
+	if err != nil {
+		_ = bastion.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	// bastion connection is released when the node connection is closed.
+	go func() {
+		_ = client.Wait()
+		_ = bastion.Close()
+	}()
+	return client, nil
 }
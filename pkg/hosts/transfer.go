@@ -0,0 +1,292 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// stagingDirCommand creates the private directory on nodes which files are staged in when sudo or ownership is required,
+	// so that staged files can't be read by other users.
+	stagingDirCommand  = "mktemp -d /tmp/.autok3s-XXXXXXXX"
+	removeDirCommand   = "rm -rf %s"
+	installFileCommand = "%smkdir -p %s && %sinstall -m %04o%s %s %s"
+	stageFileCommand   = "%scp -p %s %s && %schown $(id -u):$(id -g) %s"
+	checksumCommand    = "%ssha256sum %s"
+	sudoPrefix         = "sudo "
+)
+
+// TransferOptions are options of transferring files between local and nodes.
+type TransferOptions struct {
+	// Mode is the permission of the destination file, the mode of source file is used if not set.
+	Mode os.FileMode
+	// Owner and Group change the ownership of the uploaded file, which are ignored when downloading.
+	Owner string
+	Group string
+	// Sudo transfers the file through a staging file in a private directory under /tmp,
+	// so that files owned by root can be transferred.
+	Sudo bool
+	// Progress is called with the transferred and total bytes during transferring, total is 0 if unknown.
+	Progress func(transferred, total int64)
+}
+
+// Upload copies the local file src to the remote path dst through sftp and verifies the sha256 checksum,
+// parent directories of dst are created if not exist.
+func (t *Tunnel) Upload(src, dst string, opts TransferOptions) error {
+	local, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = local.Close()
+	}()
+	info, err := local.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", src)
+	}
+	if opts.Mode == 0 {
+		opts.Mode = info.Mode().Perm()
+	}
+	return t.UploadReader(local, info.Size(), dst, opts)
+}
+
+// UploadContext uploads the file like Upload, the ssh connection is closed to stop uploading once ctx is done.
+func (t *Tunnel) UploadContext(ctx context.Context, src, dst string, opts TransferOptions) error {
+	return t.withContext(ctx, func() error {
+		return t.Upload(src, dst, opts)
+	})
+}
+
+// UploadReader copies the content of r with size bytes to the remote path dst like Upload,
+// the file mode is 0644 if not set in opts.
+func (t *Tunnel) UploadReader(r io.Reader, size int64, dst string, opts TransferOptions) error {
+	if opts.Mode == 0 {
+		opts.Mode = 0644
+	}
+
+	client, err := sftp.NewClient(t.conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	staged := opts.Sudo || opts.Owner != "" || opts.Group != ""
+	target := dst
+	if staged {
+		staging, cleanup, err := t.stagingPath(dst)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		target = staging
+	} else if err := client.MkdirAll(path.Dir(dst)); err != nil {
+		return err
+	}
+
+	remote, err := client.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	// the file is only accessible by owner until it's written, then changed to the expected mode.
+	if err := remote.Chmod(0600); err != nil {
+		_ = remote.Close()
+		return err
+	}
+	hash := sha256.New()
+	if _, err := remote.ReadFrom(io.TeeReader(r, io.MultiWriter(hash, newProgressWriter(size, opts.Progress)))); err != nil {
+		_ = remote.Close()
+		return err
+	}
+	if err := remote.Close(); err != nil {
+		return err
+	}
+
+	if staged {
+		sudo := sudoCommand(opts.Sudo)
+		ownership := ""
+		if opts.Owner != "" {
+			ownership += " -o " + shellQuote(opts.Owner)
+		}
+		if opts.Group != "" {
+			ownership += " -g " + shellQuote(opts.Group)
+		}
+		if _, err := t.Output(fmt.Sprintf(installFileCommand, sudo, shellQuote(path.Dir(dst)), sudo,
+			opts.Mode.Perm(), ownership, shellQuote(target), shellQuote(dst))); err != nil {
+			return err
+		}
+	} else if err := client.Chmod(dst, opts.Mode.Perm()); err != nil {
+		return err
+	}
+
+	return t.verifyChecksum(dst, opts.Sudo, hash.Sum(nil))
+}
+
+// Download copies the remote file src to the local path dst through sftp and verifies the sha256 checksum.
+func (t *Tunnel) Download(src, dst string, opts TransferOptions) error {
+	local, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	mode, err := t.download(src, local, opts)
+	if cerr := local.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	if opts.Mode != 0 {
+		mode = opts.Mode
+	}
+	return os.Chmod(dst, mode.Perm())
+}
+
+// DownloadContext downloads the file like Download, the ssh connection is closed to stop downloading once ctx is done.
+func (t *Tunnel) DownloadContext(ctx context.Context, src, dst string, opts TransferOptions) error {
+	return t.withContext(ctx, func() error {
+		return t.Download(src, dst, opts)
+	})
+}
+
+// DownloadWriter copies the content of remote file src to w like Download.
+func (t *Tunnel) DownloadWriter(src string, w io.Writer, opts TransferOptions) error {
+	_, err := t.download(src, w, opts)
+	return err
+}
+
+func (t *Tunnel) download(src string, w io.Writer, opts TransferOptions) (os.FileMode, error) {
+	client, err := sftp.NewClient(t.conn)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	source := src
+	if opts.Sudo {
+		staging, cleanup, err := t.stagingPath(src)
+		if err != nil {
+			return 0, err
+		}
+		defer cleanup()
+		source = staging
+		if _, err := t.Output(fmt.Sprintf(stageFileCommand, sudoPrefix, shellQuote(src), shellQuote(source),
+			sudoPrefix, shellQuote(source))); err != nil {
+			return 0, err
+		}
+	}
+
+	remote, err := client.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = remote.Close()
+	}()
+	info, err := remote.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", src)
+	}
+
+	hash := sha256.New()
+	if _, err := remote.WriteTo(io.MultiWriter(w, hash, newProgressWriter(info.Size(), opts.Progress))); err != nil {
+		return 0, err
+	}
+	return info.Mode(), t.verifyChecksum(source, false, hash.Sum(nil))
+}
+
+// verifyChecksum compares the sha256 checksum of the remote file with the transferred content.
+func (t *Tunnel) verifyChecksum(file string, sudo bool, sum []byte) error {
+	out, err := t.Output(fmt.Sprintf(checksumCommand, sudoCommand(sudo), shellQuote(file)))
+	if err != nil {
+		return fmt.Errorf("failed to get checksum of %s: %w", file, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 || fields[0] != hex.EncodeToString(sum) {
+		return fmt.Errorf("checksum of %s mismatched, expected %s but got %s", file, hex.EncodeToString(sum), out)
+	}
+	return nil
+}
+
+// Output runs the command and returns its stdout, stderr is returned in the error if failed.
+func (t *Tunnel) Output(cmd string) (string, error) {
+	session, err := t.conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(cmd); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+type progressWriter struct {
+	transferred int64
+	total       int64
+	fn          func(transferred, total int64)
+}
+
+func newProgressWriter(total int64, fn func(transferred, total int64)) io.Writer {
+	if fn == nil {
+		return ioutil.Discard
+	}
+	return &progressWriter{total: total, fn: fn}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.transferred += int64(len(p))
+	w.fn(w.transferred, w.total)
+	return len(p), nil
+}
+
+// stagingPath returns the path to stage the file in a new private directory, the returned func removes the directory.
+func (t *Tunnel) stagingPath(file string) (string, func(), error) {
+	dir, err := t.Output(stagingDirCommand)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if dir == "" {
+		return "", nil, fmt.Errorf("failed to create staging directory: mktemp outputs nothing")
+	}
+	return path.Join(dir, path.Base(file)), func() {
+		_, _ = t.Output(fmt.Sprintf(removeDirCommand, shellQuote(dir)))
+	}, nil
+}
+
+func sudoCommand(sudo bool) string {
+	if sudo {
+		return sudoPrefix
+	}
+	return ""
+}
+
+// shellQuote quotes s with single quotes so that it's passed to remote shell as is.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
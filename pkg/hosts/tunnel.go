@@ -2,6 +2,7 @@ package hosts
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"sync"
@@ -104,6 +105,35 @@ func (t *Tunnel) Run() error {
 	return t.executeCommands()
 }
 
+// RunContext runs the commands like Run, the ssh connection is closed to stop the running command once ctx is done.
+func (t *Tunnel) RunContext(ctx context.Context) error {
+	return t.withContext(ctx, t.Run)
+}
+
+// withContext runs fn on the ssh connection, which is closed once ctx is done.
+func (t *Tunnel) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = t.conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := fn(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
 func (t *Tunnel) SetStdio(stdout, stderr io.Writer) *Tunnel {
 	t.Stdout = stdout
 	t.Stderr = stderr
@@ -2,6 +2,7 @@ package alibaba
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -55,7 +56,6 @@ const (
 	defaultRegion            = "cn-hangzhou"
 	vpcCidrBlock             = "10.0.0.0/8"
 	vSwitchCidrBlock         = "10.3.0.0/20"
-	ipRange                  = "0.0.0.0/0"
 	vpcName                  = "autok3s-aliyun-vpc"
 	vSwitchName              = "autok3s-aliyun-vswitch"
 	defaultZoneID            = "cn-hangzhou-i"
@@ -82,8 +82,8 @@ type Alibaba struct {
 	alibaba.Options `json:",inline"`
 	types.Status    `json:"status"`
 
-	c      *ecs.Client
-	v      *vpc.Client
+	c      ecsClient
+	v      vpcClient
 	m      *sync.Map
 	logger *logrus.Logger
 }
@@ -130,11 +130,19 @@ func (p *Alibaba) GetProviderName() string {
 	return p.Provider
 }
 
+func (p *Alibaba) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Alibaba) GetClusterName() string {
+	return p.Name
+}
+
 func (p *Alibaba) GenerateClusterName() {
 	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.GetProviderName())
 }
 
-func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
+func (p *Alibaba) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
 	logFile, err := common.GetLogFile(p.Name)
 	if err != nil {
 		return err
@@ -166,7 +174,7 @@ func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
 				if p.CloudControllerManager {
 					p.logger.Infof("K3s UI URL: https://<using `kubectl get svc -A` get UI address>:8999")
 				} else {
-					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].PublicIPAddress[0])
+					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].ExternalIP())
 				}
 			}
 			cluster.SaveClusterState(c, common.StatusRunning)
@@ -189,6 +197,7 @@ func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
 		return err
 	}
 
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(func() error {
 		return nil
 	}, ssh)
@@ -198,7 +207,7 @@ func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
 
 	c.Logger = p.logger
 	// initialize K3s cluster.
-	if err = cluster.InitK3sCluster(c); err != nil {
+	if err = cluster.InitK3sCluster(ctx, c); err != nil {
 		return
 	}
 	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
@@ -236,7 +245,7 @@ func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
 				base64.StdEncoding.EncodeToString([]byte(tmpl)), common.K3sManifestsDir))
 		}
 		p.logger.Infof("[%s] start deploy Alibaba additional manifests\n", p.GetProviderName())
-		if err := cluster.DeployExtraManifest(c, extraManifests); err != nil {
+		if err := cluster.DeployExtraManifest(ctx, c, extraManifests); err != nil {
 			return err
 		}
 		p.logger.Infof("[%s] successfully deploy Alibaba additional manifests\n", p.GetProviderName())
@@ -245,7 +254,7 @@ func (p *Alibaba) CreateK3sCluster(ssh *types.SSH) (err error) {
 	return nil
 }
 
-func (p *Alibaba) JoinK3sNode(ssh *types.SSH) (err error) {
+func (p *Alibaba) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
 	if p.m == nil {
 		p.m = new(syncmap.Map)
 	}
@@ -283,6 +292,7 @@ func (p *Alibaba) JoinK3sNode(ssh *types.SSH) (err error) {
 		return err
 	}
 
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(p.joinCheck, ssh)
 	if err != nil {
 		return err
@@ -310,7 +320,7 @@ func (p *Alibaba) JoinK3sNode(ssh *types.SSH) (err error) {
 	c.Logger = p.logger
 	added.Logger = p.logger
 	// join K3s node.
-	if err := cluster.JoinK3sNode(c, added); err != nil {
+	if err := cluster.JoinK3sNode(ctx, c, added); err != nil {
 		return err
 	}
 
@@ -377,7 +387,7 @@ func (p *Alibaba) Rollback() error {
 	return logFile.Close()
 }
 
-func (p *Alibaba) DeleteK3sCluster(f bool) error {
+func (p *Alibaba) DeleteK3sCluster(ctx context.Context, f bool) error {
 	isConfirmed := true
 
 	if !f {
@@ -399,6 +409,7 @@ func (p *Alibaba) DeleteK3sCluster(f bool) error {
 		}()
 		p.logger = common.NewLogger(common.Debug, logFile)
 		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
 
 		if err := p.generateClientSDK(); err != nil {
 			return err
@@ -414,6 +425,357 @@ func (p *Alibaba) DeleteK3sCluster(f bool) error {
 	return nil
 }
 
+func (p *Alibaba) RemoveK3sNode(node string, f bool) error {
+	n, ok := putil.FindNode(p.Status, node)
+	if !ok {
+		return fmt.Errorf("[%s] calling preflight error: node `%s` do not exist in cluster %s", p.GetProviderName(), node, p.Name)
+	}
+
+	isConfirmed := true
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to remove node %s from cluster %s", p.GetProviderName(), n.InstanceID, p.Name))
+	}
+	if !isConfirmed {
+		return nil
+	}
+
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing remove node logic...\n", p.GetProviderName())
+
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RemoveK3sNode(c, n); err != nil {
+		return err
+	}
+
+	p.releaseNodeEipAddresses(n.EipAllocationIds)
+
+	p.logger.Debugf("[%s] instance %s will be deleted\n", p.GetProviderName(), n.InstanceID)
+	request := ecs.CreateDeleteInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = p.Region
+	request.InstanceId = &[]string{n.InstanceID}
+	request.Force = requests.NewBoolean(true)
+	request.TerminateSubscription = requests.NewBoolean(true)
+
+	if err := wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+		response, err := p.c.DeleteInstances(request)
+		if err != nil || !response.IsSuccess() {
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("[%s] calling deleteInstance error, msg: %v", p.GetProviderName(), err)
+	}
+
+	// sync master/worker count
+	p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+	p.Metadata.Master = strconv.Itoa(len(p.Status.MasterNodes))
+	p.Metadata.Worker = strconv.Itoa(len(p.Status.WorkerNodes))
+	c = &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed remove node logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Alibaba) UpgradeK3sCluster(ctx context.Context, version string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		// the cluster is still running with origin version after rolled back.
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove upgrade state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusUpgrade)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing upgrade logic...\n", p.GetProviderName())
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusUpgrade)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.UpgradeK3sCluster(ctx, c, version); err != nil {
+		return err
+	}
+	p.K3sVersion = version
+
+	p.logger.Infof("[%s] successfully executed upgrade logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Alibaba) SaveSnapshot(name string) (*types.Snapshot, error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing save snapshot logic...\n", p.GetProviderName())
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	snapshot, err := cluster.SaveSnapshot(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.Infof("[%s] successfully executed save snapshot logic\n", p.GetProviderName())
+	return snapshot, nil
+}
+
+func (p *Alibaba) ListSnapshots() ([]types.Snapshot, error) {
+	return cluster.ListSnapshots(&types.Cluster{Metadata: p.Metadata})
+}
+
+func (p *Alibaba) RestoreSnapshot(name string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove restore state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRestore)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing restore snapshot logic...\n", p.GetProviderName())
+
+	c.Status.Status = "restoring"
+	err = cluster.SaveClusterState(c, common.StatusRestore)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.RestoreSnapshot(c, name); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed restore snapshot logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Alibaba) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing check logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return nil, err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return nil, err
+	}
+
+	if repair {
+		ids := make([]string, 0)
+		p.m.Range(func(key, value interface{}) bool {
+			if value.(types.Node).InstanceStatus == alibaba.StatusStopped {
+				ids = append(ids, key.(string))
+			}
+			return true
+		})
+		if len(ids) > 0 {
+			p.logger.Infof("[%s] starting stopped instances %s...\n", p.GetProviderName(), ids)
+			if err := p.startInstances(ids); err != nil {
+				return nil, err
+			}
+			if err := p.getInstanceStatus(alibaba.StatusRunning); err != nil {
+				return nil, err
+			}
+			// public ip address may be changed after instance restarted.
+			if _, err := p.syncClusterInstance(ssh); err != nil {
+				return nil, err
+			}
+			p.syncNodeAddresses()
+		}
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	reports, err := cluster.CheckK3sCluster(c)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if reports, err = cluster.RepairK3sCluster(c, reports); err != nil {
+			return nil, err
+		}
+		if err := cluster.SaveState(c); err != nil {
+			return nil, fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+		}
+	}
+
+	p.logger.Infof("[%s] successfully executed check logic\n", p.GetProviderName())
+	return reports, nil
+}
+
+func (p *Alibaba) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// instances are created when creating cluster or joining nodes, pre-flight checks are executed on them before installing k3s.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, instances don't exist until created", p.GetProviderName())
+}
+
+func (p *Alibaba) StartK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing start logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+
+	// record public ip addresses, which may be changed after instances started.
+	previous := make(map[string]string)
+	ids := make([]string, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, node := range nodes {
+			if len(node.PublicIPAddress) > 0 {
+				previous[node.InstanceID] = node.PublicIPAddress[0]
+			}
+			if node.InstanceStatus == alibaba.StatusStopped {
+				ids = append(ids, node.InstanceID)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] starting instances %s...\n", p.GetProviderName(), ids)
+		if err := p.startInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(alibaba.StatusRunning); err != nil {
+		return err
+	}
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+	p.syncNodeAddresses()
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RefreshNodeAddresses(c, previous); err != nil {
+		return err
+	}
+
+	c.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed start logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Alibaba) StopK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing stop logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	if _, err := p.syncClusterInstance(p.GetSSHConfig()); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		if value.(types.Node).InstanceStatus != alibaba.StatusStopped {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] stopping instances %s...\n", p.GetProviderName(), ids)
+		if err := p.stopInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(alibaba.StatusStopped); err != nil {
+		return err
+	}
+	p.syncNodeStatusWithInstance(nil)
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed stop logic\n", p.GetProviderName())
+	return nil
+}
+
 func (p *Alibaba) SSHK3sNode(ssh *types.SSH, node string) error {
 	p.logger = common.NewLogger(common.Debug, nil)
 	p.logger.Infof("[%s] executing ssh logic...\n", p.GetProviderName())
@@ -434,6 +796,9 @@ func (p *Alibaba) SSHK3sNode(ssh *types.SSH, node string) error {
 			instanceInfo = instance.EipAddress.IpAddress
 		} else if instance.EipAddress.IpAddress == "" && len(instance.PublicIpAddress.IpAddress) > 0 {
 			instanceInfo = instance.PublicIpAddress.IpAddress[0]
+		} else if len(instance.VpcAttributes.PrivateIpAddress.IpAddress) > 0 {
+			// nodes without public ip are connected through bastions by internal ip.
+			instanceInfo = instance.VpcAttributes.PrivateIpAddress.IpAddress[0]
 		}
 		if instanceInfo != "" {
 			for _, t := range instance.Tags.Tag {
@@ -704,25 +1069,19 @@ func (p *Alibaba) SetConfig(config []byte) error {
 
 func (p *Alibaba) generateClientSDK() error {
 	if p.AccessKey == "" {
-		p.AccessKey = viper.GetString(p.GetProviderName(), accessKeyID)
+		p.AccessKey = viper.GetCredential(p.GetProviderName(), p.Credential, accessKeyID)
 	}
 
 	if p.AccessSecret == "" {
-		p.AccessSecret = viper.GetString(p.GetProviderName(), accessKeySecret)
+		p.AccessSecret = viper.GetCredential(p.GetProviderName(), p.Credential, accessKeySecret)
 	}
 
-	client, err := ecs.NewClientWithAccessKey(p.Region, p.AccessKey, p.AccessSecret)
+	c, v, err := newClientSDK(p.Region, p.AccessKey, p.AccessSecret)
 	if err != nil {
 		return err
 	}
-	client.EnableAsync(5, 1000)
-	p.c = client
-
-	vpcClient, err := vpc.NewClientWithAccessKey(p.Region, p.AccessKey, p.AccessSecret)
-	if err != nil {
-		return err
-	}
-	p.v = vpcClient
+	p.c = c
+	p.v = v
 
 	return nil
 }
@@ -837,6 +1196,54 @@ func (p *Alibaba) deleteCluster(f bool) error {
 	return nil
 }
 
+func (p *Alibaba) startInstances(ids []string) error {
+	request := ecs.CreateStartInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = p.Region
+	request.InstanceId = &ids
+
+	response, err := p.c.StartInstances(request)
+	if err != nil {
+		return fmt.Errorf("[%s] calling startInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("[%s] calling startInstances error, msg: %s", p.GetProviderName(), response.GetHttpContentString())
+	}
+	return nil
+}
+
+func (p *Alibaba) stopInstances(ids []string) error {
+	request := ecs.CreateStopInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = p.Region
+	request.InstanceId = &ids
+
+	response, err := p.c.StopInstances(request)
+	if err != nil {
+		return fmt.Errorf("[%s] calling stopInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("[%s] calling stopInstances error, msg: %s", p.GetProviderName(), response.GetHttpContentString())
+	}
+	return nil
+}
+
+// syncNodeAddresses updates ip addresses of nodes with the synchronized instances.
+func (p *Alibaba) syncNodeAddresses() {
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := p.Status.WorkerNodes
+		if v.Master {
+			nodes = p.Status.MasterNodes
+		}
+		if index, b := putil.IsExistedNodes(nodes, v.InstanceID); b {
+			nodes[index].InternalIPAddress = v.InternalIPAddress
+			nodes[index].PublicIPAddress = v.PublicIPAddress
+		}
+		return true
+	})
+}
+
 func (p *Alibaba) getInstanceStatus(aimStatus string) error {
 	ids := make([]string, 0)
 	p.m.Range(func(key, value interface{}) bool {
@@ -1020,6 +1427,12 @@ func (p *Alibaba) getVpcCIDR() (string, error) {
 }
 
 func (p *Alibaba) CreateCheck(ssh *types.SSH) error {
+	if err := cluster.CheckAirGap(p.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	if _, err := putil.FirewallPolicy(p.Metadata, ""); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
 	if p.KeyPair != "" && ssh.SSHKeyPath == "" {
 		return fmt.Errorf("[%s] calling preflight error: must set --ssh-key-path with --key-pair %s", p.GetProviderName(), p.KeyPair)
 	}
@@ -1282,6 +1695,33 @@ func (p *Alibaba) releaseEipAddresses(rollBack bool) {
 	}
 }
 
+func (p *Alibaba) releaseNodeEipAddresses(allocationIds []string) {
+	if len(allocationIds) == 0 {
+		return
+	}
+
+	for _, allocationID := range allocationIds {
+		if err := p.unassociateEipAddress(allocationID); err != nil {
+			p.logger.Errorf("[%s] error when unassociating eip address %s: %v\n", p.GetProviderName(), allocationID, err)
+		}
+	}
+
+	// eip can be released only when status is `Available`.
+	if err := p.getEipStatus(allocationIds, eipStatusAvailable); err != nil {
+		p.logger.Errorf("[%s] error when query eip status: %v\n", p.GetProviderName(), err)
+	}
+
+	for _, allocationID := range allocationIds {
+		p.logger.Debugf("[%s] releasing eip: %s\n", p.GetProviderName(), allocationID)
+
+		if err := p.releaseEipAddress(allocationID); err != nil {
+			p.logger.Errorf("[%s] error when releasing eip address %s: %v\n", p.GetProviderName(), allocationID, err)
+		} else {
+			p.logger.Debugf("[%s] successfully released eip: %s\n", p.GetProviderName(), allocationID)
+		}
+	}
+}
+
 func (p *Alibaba) getEipStatus(allocationIds []string, aimStatus string) error {
 	if allocationIds == nil || len(allocationIds) == 0 {
 		return fmt.Errorf("[%s] allocationIds can not be empty", p.GetProviderName())
@@ -1390,6 +1830,14 @@ func (p *Alibaba) generateInstance(fn checkFun, ssh *types.SSH) (*types.Cluster,
 		if err != nil {
 			return nil, err
 		}
+	} else if p.AdminCIDRs != "" || p.FirewallRules != "" {
+		sg, err := p.getSecurityGroup(p.SecurityGroup)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.configDefaultSecurityPermissions(sg); err != nil {
+			return nil, err
+		}
 	}
 
 	needUploadKeyPair := false
@@ -1801,91 +2249,68 @@ func (p *Alibaba) configSecurityGroup() error {
 	}
 
 	p.SecurityGroup = securityGroup.SecurityGroupId
-	permissionList := p.configDefaultSecurityPermissions(securityGroup)
-	for _, perm := range permissionList {
-		args := ecs.CreateAuthorizeSecurityGroupRequest()
-		args.Scheme = "https"
-		args.RegionId = p.Region
-		args.SecurityGroupId = securityGroup.SecurityGroupId
-		args.IpProtocol = perm.IpProtocol
-		args.PortRange = perm.PortRange
-		args.SourceCidrIp = ipRange
-		args.Description = perm.Description
-		_, err := p.c.AuthorizeSecurityGroup(args)
-		if err != nil {
-			p.logger.Errorf("[%s] Add permission %v to securityGroup %s error: %v", p.GetProviderName(), perm, securityGroup.SecurityGroupId, err)
-			continue
-		}
-	}
-
-	return nil
+	return p.configDefaultSecurityPermissions(securityGroup)
 }
 
-func (p *Alibaba) configDefaultSecurityPermissions(sg *ecs.DescribeSecurityGroupAttributeResponse) []ecs.Permission {
-	hasSSHPort := false
-	hasAPIServerPort := false
-	hasKubeletPort := false
-	for _, perm := range sg.Permissions.Permission {
-		portRange := strings.Split(perm.PortRange, "/")
-
-		p.logger.Debugf("[%s] get portRange %v for security group %s\n", p.GetProviderName(), portRange, sg.SecurityGroupId)
-		fromPort, _ := strconv.Atoi(portRange[0])
-		switch fromPort {
-		case 22:
-			hasSSHPort = true
-		case 6443:
-			hasAPIServerPort = true
-		case 10250:
-			hasKubeletPort = true
-		}
-	}
-
-	perms := make([]ecs.Permission, 0)
-
-	if !hasSSHPort {
-		perms = append(perms, ecs.Permission{
-			IpProtocol:  "tcp",
-			PortRange:   "22/22",
-			Description: "accept for ssh(generated by autok3s)",
-		})
+// configDefaultSecurityPermissions reconciles the ingress rules of security group with the firewall policy,
+// only rules generated by autok3s for the cluster are revoked, the default security group is shared by clusters in the vpc.
+func (p *Alibaba) configDefaultSecurityPermissions(sg *ecs.DescribeSecurityGroupAttributeResponse) error {
+	_, cidr, err := p.getVSwitchCIDR()
+	if err != nil {
+		return fmt.Errorf("[%s] failed to get vswitch cidr with id %s, error: %v", p.GetProviderName(), p.VSwitch, err)
 	}
-
-	if p.Network == "" || p.Network == "vxlan" {
-		// udp 8472 for flannel vxlan
-		perms = append(perms, ecs.Permission{
-			IpProtocol:  "udp",
-			PortRange:   "8472/8472",
-			Description: "accept for k3s vxlan(generated by autok3s)",
-		})
+	desired, err := putil.FirewallPolicy(p.Metadata, cidr)
+	if err != nil {
+		return err
 	}
 
-	// port 6443 for kubernetes api-server
-	if !hasAPIServerPort {
-		perms = append(perms, ecs.Permission{
-			IpProtocol:  "tcp",
-			PortRange:   "6443/6443",
-			Description: "accept for kube api-server(generated by autok3s)",
+	existing := make([]putil.FirewallRule, 0)
+	for _, perm := range sg.Permissions.Permission {
+		if (perm.Direction != "" && perm.Direction != "ingress") || perm.SourceCidrIp == "" {
+			continue
+		}
+		fromPort, toPort, err := putil.ParsePortRange(perm.PortRange)
+		if err != nil {
+			p.logger.Debugf("[%s] skip portRange %s for security group %s\n", p.GetProviderName(), perm.PortRange, sg.SecurityGroupId)
+			continue
+		}
+		existing = append(existing, putil.FirewallRule{
+			Protocol:    strings.ToLower(perm.IpProtocol),
+			FromPort:    fromPort,
+			ToPort:      toPort,
+			CIDR:        perm.SourceCidrIp,
+			Description: perm.Description,
 		})
 	}
 
-	// 10250 for kubelet
-	if !hasKubeletPort {
-		perms = append(perms, ecs.Permission{
-			IpProtocol:  "tcp",
-			PortRange:   "10250/10250",
-			Description: "accept for kubelet(generated by autok3s)",
-		})
+	authorize, revoke := putil.ReconcileFirewall(p.Name, desired, existing)
+	for _, rule := range revoke {
+		args := ecs.CreateRevokeSecurityGroupRequest()
+		args.Scheme = "https"
+		args.RegionId = p.Region
+		args.SecurityGroupId = sg.SecurityGroupId
+		args.IpProtocol = rule.Protocol
+		args.PortRange = fmt.Sprintf("%d/%d", rule.FromPort, rule.ToPort)
+		args.SourceCidrIp = rule.CIDR
+		if _, err := p.c.RevokeSecurityGroup(args); err != nil {
+			return fmt.Errorf("[%s] revoke permission %v from securityGroup %s error: %v", p.GetProviderName(), rule, sg.SecurityGroupId, err)
+		}
 	}
-
-	if p.UI {
-		perms = append(perms, ecs.Permission{
-			IpProtocol:  "tcp",
-			PortRange:   "8999/8999",
-			Description: "accept for dashboard(generated by autok3s)",
-		})
+	for _, rule := range authorize {
+		args := ecs.CreateAuthorizeSecurityGroupRequest()
+		args.Scheme = "https"
+		args.RegionId = p.Region
+		args.SecurityGroupId = sg.SecurityGroupId
+		args.IpProtocol = rule.Protocol
+		args.PortRange = fmt.Sprintf("%d/%d", rule.FromPort, rule.ToPort)
+		args.SourceCidrIp = rule.CIDR
+		args.Description = rule.Description
+		if _, err := p.c.AuthorizeSecurityGroup(args); err != nil {
+			return fmt.Errorf("[%s] add permission %v to securityGroup %s error: %v", p.GetProviderName(), rule, sg.SecurityGroupId, err)
+		}
 	}
 
-	return perms
+	return nil
 }
 
 func (p *Alibaba) getSecurityGroup(id string) (*ecs.DescribeSecurityGroupAttributeResponse, error) {
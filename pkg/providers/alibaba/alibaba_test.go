@@ -0,0 +1,200 @@
+package alibaba
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typesAli "github.com/cnrancher/autok3s/pkg/types/alibaba"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAlibaba(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Alibaba Provider Suite")
+}
+
+var _ = Describe("Alibaba provider with fake ecs and vpc clients", func() {
+	var (
+		cloud      *fakeCloud
+		ssh        *types.SSH
+		cleanup    func()
+		newClient  func(region, accessKey, accessSecret string) (ecsClient, vpcClient, error)
+		newAlibaba func(master, worker string) *Alibaba
+		deleted    func() []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		cleanup, err = fake.SetupCfgPath()
+		Expect(err).NotTo(HaveOccurred())
+
+		cloud = newFakeCloud()
+		newClient = newClientSDK
+		newClientSDK = func(region, accessKey, accessSecret string) (ecsClient, vpcClient, error) {
+			return &fakeECS{cloud}, &fakeVPC{cloud}, nil
+		}
+		ssh = &types.SSH{User: defaultUser, Port: "22", SSHKeyPath: "/dev/null"}
+		newAlibaba = func(master, worker string) *Alibaba {
+			p := newProvider()
+			p.Name = "fake"
+			p.Master = master
+			p.Worker = worker
+			p.AccessKey = "fake"
+			p.AccessSecret = "fake"
+			p.KeyPair = "fake"
+			p.logger = fake.Logger()
+			return p
+		}
+		deleted = func() []string {
+			ids := make([]string, 0)
+			for _, instance := range cloud.instances {
+				if instance.Status == statusDeleted {
+					ids = append(ids, instance.InstanceId)
+				}
+			}
+			return ids
+		}
+	})
+
+	AfterEach(func() {
+		newClientSDK = newClient
+		cleanup()
+	})
+
+	It("creates, joins, rolls back and deletes the instances of cluster with eip", func() {
+		p := newAlibaba("1", "1")
+		p.EIP = true
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+
+		c, err := p.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		Expect(c.MasterNodes[0].InstanceStatus).To(Equal(typesAli.StatusRunning))
+		Expect(c.MasterNodes[0].PublicIPAddress[0]).To(Equal(cloud.instances[0].EipAddress.IpAddress))
+		Expect(p.VSwitch).To(Equal(cloud.vSwitches[0].VSwitchId))
+		Expect(p.SecurityGroup).To(Equal(cloud.securityGroups[0].SecurityGroupId))
+		Expect(cloud.securityGroups[0].Permissions.Permission).NotTo(BeEmpty())
+		Expect(cloud.eips).To(HaveLen(2))
+
+		// join a worker with the cluster state, and roll it back.
+		j := newAlibaba("0", "1")
+		j.EIP = true
+		j.Status = fake.LoadStatus(c.Status)
+		j.VSwitch = p.VSwitch
+		j.SecurityGroup = p.SecurityGroup
+		joined, err := j.generateInstance(j.joinCheck, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(joined.MasterNodes).To(HaveLen(1))
+		Expect(joined.WorkerNodes).To(HaveLen(2))
+		Expect(cloud.eips).To(HaveLen(3))
+
+		Expect(j.Rollback()).To(Succeed())
+		Expect(deleted()).To(HaveLen(1))
+		Expect(cloud.eips).To(HaveLen(2))
+		exist, ids, err := p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeTrue())
+		Expect(ids).To(ConsistOf(c.MasterNodes[0].InstanceID, c.WorkerNodes[0].InstanceID))
+
+		Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+		Expect(deleted()).To(HaveLen(3))
+		Expect(cloud.eips).To(BeEmpty())
+		exist, _, err = p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeFalse())
+	})
+
+	It("creates and joins the k3s cluster on the instances through ssh", func() {
+		server, err := fake.NewSSHServer()
+		Expect(err).NotTo(HaveOccurred())
+		defer server.Close()
+		ssh.Password = "fake"
+		ssh.Bastions = []types.Bastion{server.Bastion()}
+
+		p := newAlibaba("1", "1")
+		p.EIP = true
+		p.Token = "fake-token"
+		p.UI = true
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+		Expect(p.CreateK3sCluster(context.Background(), ssh)).To(Succeed())
+		Expect(p.Status.MasterNodes).To(HaveLen(1))
+		Expect(p.Status.WorkerNodes).To(HaveLen(1))
+
+		master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+		masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+		Expect(master.Commands()).To(ContainElement(And(
+			ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("INSTALL_K3S_EXEC='server"))))
+		ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+		Expect(ok).To(BeTrue())
+		Expect(ui).NotTo(BeEmpty())
+		worker := server.Node(p.Status.WorkerNodes[0].PublicIPAddress[0])
+		Expect(worker.Commands()).To(ContainElement(And(
+			ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+
+		// kubeconfig of cluster is merged, and the cluster is saved in state.
+		kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+		state, err := cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Status.Status).To(Equal(common.StatusRunning))
+		Expect(state.Token).To(Equal("fake-token"))
+
+		// join a worker to the cluster in state.
+		j := newAlibaba("0", "1")
+		j.EIP = true
+		j.Status = fake.LoadStatus(state.Status)
+		j.Token = state.Token
+		j.IP = state.IP
+		j.VSwitch = p.VSwitch
+		j.SecurityGroup = p.SecurityGroup
+		Expect(j.JoinK3sNode(context.Background(), ssh)).To(Succeed())
+		Expect(j.Status.WorkerNodes).To(HaveLen(2))
+		for _, n := range j.Status.WorkerNodes {
+			if n.InstanceID == p.Status.WorkerNodes[0].InstanceID {
+				continue
+			}
+			Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+				ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+		}
+		state, err = cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Worker).To(Equal("2"))
+	})
+
+	It("keeps the rules of security group if the vswitch cidr isn't found", func() {
+		p := newAlibaba("1", "0")
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+		_, err := p.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		sg := cloud.securityGroups[0]
+		permissions := len(sg.Permissions.Permission)
+
+		p.VSwitch = "vsw-missing"
+		p.AdminCIDRs = "192.0.2.0/24"
+		Expect(p.configDefaultSecurityPermissions(sg)).To(MatchError(ContainSubstring("failed to get vswitch cidr")))
+		Expect(sg.Permissions.Permission).To(HaveLen(permissions))
+		for _, perm := range sg.Permissions.Permission {
+			if perm.PortRange == "10250/10250" || perm.PortRange == "8472/8472" {
+				Expect(perm.SourceCidrIp).To(Equal(cloud.vSwitches[0].CidrBlock))
+			}
+		}
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newAlibaba("0", "1")
+		_, err := p.generateInstance(p.joinCheck, ssh)
+		Expect(err).To(HaveOccurred())
+		Expect(cloud.instances).To(BeEmpty())
+	})
+})
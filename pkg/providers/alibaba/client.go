@@ -0,0 +1,60 @@
+package alibaba
+
+import (
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+)
+
+// ecsClient is the subset of ecs api used by the provider, which is replaced by an in-memory fake in tests.
+type ecsClient interface {
+	RunInstances(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error)
+	StartInstances(request *ecs.StartInstancesRequest) (*ecs.StartInstancesResponse, error)
+	StopInstances(request *ecs.StopInstancesRequest) (*ecs.StopInstancesResponse, error)
+	DeleteInstances(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error)
+	DescribeInstances(request *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error)
+	DescribeInstanceStatus(request *ecs.DescribeInstanceStatusRequest) (*ecs.DescribeInstanceStatusResponse, error)
+	ListTagResources(request *ecs.ListTagResourcesRequest) (*ecs.ListTagResourcesResponse, error)
+
+	DescribeVpcs(request *ecs.DescribeVpcsRequest) (*ecs.DescribeVpcsResponse, error)
+	DescribeVSwitches(request *ecs.DescribeVSwitchesRequest) (*ecs.DescribeVSwitchesResponse, error)
+
+	DescribeSecurityGroups(request *ecs.DescribeSecurityGroupsRequest) (*ecs.DescribeSecurityGroupsResponse, error)
+	DescribeSecurityGroupAttribute(request *ecs.DescribeSecurityGroupAttributeRequest) (*ecs.DescribeSecurityGroupAttributeResponse, error)
+	CreateSecurityGroup(request *ecs.CreateSecurityGroupRequest) (*ecs.CreateSecurityGroupResponse, error)
+	AuthorizeSecurityGroup(request *ecs.AuthorizeSecurityGroupRequest) (*ecs.AuthorizeSecurityGroupResponse, error)
+	RevokeSecurityGroup(request *ecs.RevokeSecurityGroupRequest) (*ecs.RevokeSecurityGroupResponse, error)
+}
+
+// vpcClient is the subset of vpc api used by the provider, which is replaced by an in-memory fake in tests.
+type vpcClient interface {
+	CreateVpc(request *vpc.CreateVpcRequest) (*vpc.CreateVpcResponse, error)
+	DescribeVpcs(request *vpc.DescribeVpcsRequest) (*vpc.DescribeVpcsResponse, error)
+	DescribeVpcAttribute(request *vpc.DescribeVpcAttributeRequest) (*vpc.DescribeVpcAttributeResponse, error)
+	CreateVSwitch(request *vpc.CreateVSwitchRequest) (*vpc.CreateVSwitchResponse, error)
+	DescribeVSwitches(request *vpc.DescribeVSwitchesRequest) (*vpc.DescribeVSwitchesResponse, error)
+	DescribeVSwitchAttributes(request *vpc.DescribeVSwitchAttributesRequest) (*vpc.DescribeVSwitchAttributesResponse, error)
+
+	AllocateEipAddress(request *vpc.AllocateEipAddressRequest) (*vpc.AllocateEipAddressResponse, error)
+	AssociateEipAddress(request *vpc.AssociateEipAddressRequest) (*vpc.AssociateEipAddressResponse, error)
+	UnassociateEipAddress(request *vpc.UnassociateEipAddressRequest) (*vpc.UnassociateEipAddressResponse, error)
+	ReleaseEipAddress(request *vpc.ReleaseEipAddressRequest) (*vpc.ReleaseEipAddressResponse, error)
+	DescribeEipAddresses(request *vpc.DescribeEipAddressesRequest) (*vpc.DescribeEipAddressesResponse, error)
+
+	TagResources(request *vpc.TagResourcesRequest) (*vpc.TagResourcesResponse, error)
+	ListTagResources(request *vpc.ListTagResourcesRequest) (*vpc.ListTagResourcesResponse, error)
+}
+
+// newClientSDK creates the ecs and vpc clients, tests replace it to run the provider without network access.
+var newClientSDK = func(region, accessKey, accessSecret string) (ecsClient, vpcClient, error) {
+	c, err := ecs.NewClientWithAccessKey(region, accessKey, accessSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.EnableAsync(5, 1000)
+
+	v, err := vpc.NewClientWithAccessKey(region, accessKey, accessSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, v, nil
+}
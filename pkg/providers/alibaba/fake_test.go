@@ -0,0 +1,633 @@
+package alibaba
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	typesAli "github.com/cnrancher/autok3s/pkg/types/alibaba"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+)
+
+const (
+	statusStopping = "Stopping"
+	statusDeleted  = "Deleted"
+)
+
+// fakeCloud is the in-memory state of alibaba cloud shared by the fake ecs and vpc clients.
+// Instances are pending after created and become running when the status is described,
+// stopping instances become stopped in the same way.
+type fakeCloud struct {
+	mu sync.Mutex
+
+	seq            int
+	instances      []*ecs.Instance
+	vpcs           []*vpc.Vpc
+	vSwitches      []*vpc.VSwitch
+	eips           []*vpc.EipAddress
+	securityGroups []*ecs.DescribeSecurityGroupAttributeResponse
+	// tags of resources by resource type and id.
+	tags map[string]map[string]map[string]string
+}
+
+type fakeECS struct {
+	*fakeCloud
+}
+
+type fakeVPC struct {
+	*fakeCloud
+}
+
+func newFakeCloud() *fakeCloud {
+	return &fakeCloud{tags: map[string]map[string]map[string]string{}}
+}
+
+func (f *fakeCloud) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s-%08d", prefix, f.seq)
+}
+
+func (f *fakeCloud) tag(resourceType, id string, tags map[string]string) {
+	resourceType = strings.ToLower(resourceType)
+	if f.tags[resourceType] == nil {
+		f.tags[resourceType] = map[string]map[string]string{}
+	}
+	if f.tags[resourceType][id] == nil {
+		f.tags[resourceType][id] = map[string]string{}
+	}
+	for k, v := range tags {
+		f.tags[resourceType][id][k] = v
+	}
+}
+
+// listTags returns one tag resource for each tag of the resources which have all the tags like alibaba cloud.
+func (f *fakeCloud) listTags(resourceType string, ids []string, tags map[string]string) [][2]string {
+	resources := make([][2]string, 0)
+	for id, resourceTags := range f.tags[strings.ToLower(resourceType)] {
+		if len(ids) > 0 && !containsString(ids, id) {
+			continue
+		}
+		if !matchTags(resourceTags, tags) {
+			continue
+		}
+		for k := range resourceTags {
+			resources = append(resources, [2]string{id, k})
+		}
+	}
+	return resources
+}
+
+func (f *fakeCloud) instance(id string) *ecs.Instance {
+	for _, instance := range f.instances {
+		if instance.InstanceId == id && instance.Status != statusDeleted {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (f *fakeCloud) eip(id string) *vpc.EipAddress {
+	for _, eip := range f.eips {
+		if eip.AllocationId == id {
+			return eip
+		}
+	}
+	return nil
+}
+
+func (f *fakeCloud) securityGroup(id string) *ecs.DescribeSecurityGroupAttributeResponse {
+	for _, group := range f.securityGroups {
+		if group.SecurityGroupId == id {
+			return group
+		}
+	}
+	return nil
+}
+
+func (f *fakeECS) RunInstances(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	num, err := strconv.Atoi(string(request.Amount))
+	if err != nil {
+		return nil, err
+	}
+	bandwidth, _ := strconv.Atoi(string(request.InternetMaxBandwidthOut))
+	tags := map[string]string{}
+	if request.Tag != nil {
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+	}
+
+	response := ecs.CreateRunInstancesResponse()
+	for i := 0; i < num; i++ {
+		id := f.nextID("i")
+		instance := &ecs.Instance{
+			InstanceId:   id,
+			InstanceName: request.InstanceName,
+			ZoneId:       request.ZoneId,
+			Status:       typesAli.StatusPending,
+		}
+		instance.VpcAttributes.VSwitchId = request.VSwitchId
+		instance.VpcAttributes.PrivateIpAddress.IpAddress = []string{fmt.Sprintf("10.3.0.%d", f.seq)}
+		if bandwidth > 0 {
+			instance.PublicIpAddress.IpAddress = []string{fmt.Sprintf("203.0.113.%d", f.seq)}
+		}
+		f.instances = append(f.instances, instance)
+		f.tag("instance", id, tags)
+		response.InstanceIdSets.InstanceIdSet = append(response.InstanceIdSets.InstanceIdSet, id)
+	}
+	return response, succeed(response)
+}
+
+func (f *fakeECS) StartInstances(request *ecs.StartInstancesRequest) (*ecs.StartInstancesResponse, error) {
+	response := ecs.CreateStartInstancesResponse()
+	return response, f.setStatus(*request.InstanceId, typesAli.StatusPending, response)
+}
+
+func (f *fakeECS) StopInstances(request *ecs.StopInstancesRequest) (*ecs.StopInstancesResponse, error) {
+	response := ecs.CreateStopInstancesResponse()
+	return response, f.setStatus(*request.InstanceId, statusStopping, response)
+}
+
+func (f *fakeECS) DeleteInstances(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error) {
+	response := ecs.CreateDeleteInstancesResponse()
+	if err := f.setStatus(*request.InstanceId, statusDeleted, response); err != nil {
+		return response, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range *request.InstanceId {
+		delete(f.tags["instance"], id)
+	}
+	return response, nil
+}
+
+func (f *fakeECS) setStatus(ids []string, status string, response responses.AcsResponse) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		instance := f.instance(id)
+		if instance == nil {
+			return fmt.Errorf("InvalidInstanceId.NotFound: instance %s does not exist", id)
+		}
+		if status == statusDeleted && instance.EipAddress.AllocationId != "" {
+			return fmt.Errorf("DependencyViolation.EipAddress: eip of instance %s is not unassociated", id)
+		}
+		instance.Status = status
+	}
+	return succeed(response)
+}
+
+func (f *fakeECS) DescribeInstanceStatus(request *ecs.DescribeInstanceStatusRequest) (*ecs.DescribeInstanceStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := ecs.CreateDescribeInstanceStatusResponse()
+	for _, id := range *request.InstanceId {
+		instance := f.instance(id)
+		if instance == nil {
+			continue
+		}
+		switch instance.Status {
+		case typesAli.StatusPending:
+			instance.Status = typesAli.StatusRunning
+		case statusStopping:
+			instance.Status = typesAli.StatusStopped
+		}
+		response.InstanceStatuses.InstanceStatus = append(response.InstanceStatuses.InstanceStatus, ecs.InstanceStatus{
+			InstanceId: id,
+			Status:     instance.Status,
+		})
+	}
+	return response, succeed(response)
+}
+
+func (f *fakeECS) DescribeInstances(request *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := map[string]string{}
+	if request.Tag != nil {
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+	}
+	response := ecs.CreateDescribeInstancesResponse()
+	for _, instance := range f.instances {
+		if instance.Status == statusDeleted || (request.ZoneId != "" && instance.ZoneId != request.ZoneId) ||
+			!matchTags(f.tags["instance"][instance.InstanceId], tags) {
+			continue
+		}
+		i := *instance
+		for k, v := range f.tags["instance"][instance.InstanceId] {
+			i.Tags.Tag = append(i.Tags.Tag, ecs.Tag{TagKey: k, TagValue: v})
+		}
+		response.Instances.Instance = append(response.Instances.Instance, i)
+	}
+	response.TotalCount = len(response.Instances.Instance)
+	response.PageNumber = 1
+	return response, succeed(response)
+}
+
+func (f *fakeECS) ListTagResources(request *ecs.ListTagResourcesRequest) (*ecs.ListTagResourcesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	if request.ResourceId != nil {
+		ids = *request.ResourceId
+	}
+	tags := map[string]string{}
+	if request.Tag != nil {
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+	}
+	response := ecs.CreateListTagResourcesResponse()
+	for _, r := range f.listTags(request.ResourceType, ids, tags) {
+		response.TagResources.TagResource = append(response.TagResources.TagResource, ecs.TagResource{
+			ResourceType: request.ResourceType,
+			ResourceId:   r[0],
+			TagKey:       r[1],
+		})
+	}
+	return response, succeed(response)
+}
+
+func (f *fakeECS) DescribeVpcs(request *ecs.DescribeVpcsRequest) (*ecs.DescribeVpcsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := ecs.CreateDescribeVpcsResponse()
+	for _, v := range f.vpcs {
+		if request.VpcId == "" || v.VpcId == request.VpcId {
+			response.Vpcs.Vpc = append(response.Vpcs.Vpc, ecs.Vpc{VpcId: v.VpcId, VpcName: v.VpcName, CidrBlock: v.CidrBlock, Status: v.Status})
+		}
+	}
+	response.TotalCount = len(response.Vpcs.Vpc)
+	return response, succeed(response)
+}
+
+func (f *fakeECS) DescribeVSwitches(request *ecs.DescribeVSwitchesRequest) (*ecs.DescribeVSwitchesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := ecs.CreateDescribeVSwitchesResponse()
+	for _, v := range f.vSwitches {
+		if (request.VSwitchId == "" || v.VSwitchId == request.VSwitchId) && (request.ZoneId == "" || v.ZoneId == request.ZoneId) {
+			response.VSwitches.VSwitch = append(response.VSwitches.VSwitch, ecs.VSwitch{
+				VSwitchId:   v.VSwitchId,
+				VSwitchName: v.VSwitchName,
+				VpcId:       v.VpcId,
+				ZoneId:      v.ZoneId,
+				CidrBlock:   v.CidrBlock,
+				Status:      v.Status,
+			})
+		}
+	}
+	response.TotalCount = len(response.VSwitches.VSwitch)
+	return response, succeed(response)
+}
+
+func (f *fakeECS) DescribeSecurityGroups(request *ecs.DescribeSecurityGroupsRequest) (*ecs.DescribeSecurityGroupsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := map[string]string{}
+	if request.Tag != nil {
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+	}
+	response := ecs.CreateDescribeSecurityGroupsResponse()
+	for _, group := range f.securityGroups {
+		if (request.VpcId != "" && group.VpcId != request.VpcId) ||
+			(request.SecurityGroupName != "" && group.SecurityGroupName != request.SecurityGroupName) ||
+			!matchTags(f.tags["securitygroup"][group.SecurityGroupId], tags) {
+			continue
+		}
+		response.SecurityGroups.SecurityGroup = append(response.SecurityGroups.SecurityGroup, ecs.SecurityGroup{
+			SecurityGroupId:   group.SecurityGroupId,
+			SecurityGroupName: group.SecurityGroupName,
+			VpcId:             group.VpcId,
+		})
+	}
+	response.TotalCount = len(response.SecurityGroups.SecurityGroup)
+	return response, succeed(response)
+}
+
+func (f *fakeECS) DescribeSecurityGroupAttribute(request *ecs.DescribeSecurityGroupAttributeRequest) (*ecs.DescribeSecurityGroupAttributeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := f.securityGroup(request.SecurityGroupId)
+	if group == nil {
+		return nil, fmt.Errorf("InvalidSecurityGroupId.NotFound: security group %s does not exist", request.SecurityGroupId)
+	}
+	response := ecs.CreateDescribeSecurityGroupAttributeResponse()
+	response.SecurityGroupId = group.SecurityGroupId
+	response.SecurityGroupName = group.SecurityGroupName
+	response.VpcId = group.VpcId
+	response.Permissions.Permission = append(response.Permissions.Permission, group.Permissions.Permission...)
+	return response, succeed(response)
+}
+
+func (f *fakeECS) CreateSecurityGroup(request *ecs.CreateSecurityGroupRequest) (*ecs.CreateSecurityGroupResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID("sg")
+	group := ecs.CreateDescribeSecurityGroupAttributeResponse()
+	group.SecurityGroupId = id
+	group.SecurityGroupName = request.SecurityGroupName
+	group.VpcId = request.VpcId
+	f.securityGroups = append(f.securityGroups, group)
+	if request.Tag != nil {
+		tags := map[string]string{}
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+		f.tag("securitygroup", id, tags)
+	}
+	response := ecs.CreateCreateSecurityGroupResponse()
+	response.SecurityGroupId = id
+	return response, succeed(response)
+}
+
+func (f *fakeECS) AuthorizeSecurityGroup(request *ecs.AuthorizeSecurityGroupRequest) (*ecs.AuthorizeSecurityGroupResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := f.securityGroup(request.SecurityGroupId)
+	if group == nil {
+		return nil, fmt.Errorf("InvalidSecurityGroupId.NotFound: security group %s does not exist", request.SecurityGroupId)
+	}
+	if findPermission(group.Permissions.Permission, request.IpProtocol, request.PortRange, request.SourceCidrIp) < 0 {
+		group.Permissions.Permission = append(group.Permissions.Permission, ecs.Permission{
+			IpProtocol:   strings.ToUpper(request.IpProtocol),
+			PortRange:    request.PortRange,
+			SourceCidrIp: request.SourceCidrIp,
+			Description:  request.Description,
+			Direction:    "ingress",
+			Policy:       "Accept",
+		})
+	}
+	response := ecs.CreateAuthorizeSecurityGroupResponse()
+	return response, succeed(response)
+}
+
+func (f *fakeECS) RevokeSecurityGroup(request *ecs.RevokeSecurityGroupRequest) (*ecs.RevokeSecurityGroupResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := f.securityGroup(request.SecurityGroupId)
+	if group == nil {
+		return nil, fmt.Errorf("InvalidSecurityGroupId.NotFound: security group %s does not exist", request.SecurityGroupId)
+	}
+	if index := findPermission(group.Permissions.Permission, request.IpProtocol, request.PortRange, request.SourceCidrIp); index >= 0 {
+		group.Permissions.Permission = append(group.Permissions.Permission[:index], group.Permissions.Permission[index+1:]...)
+	}
+	response := ecs.CreateRevokeSecurityGroupResponse()
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) CreateVpc(request *vpc.CreateVpcRequest) (*vpc.CreateVpcResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID("vpc")
+	f.vpcs = append(f.vpcs, &vpc.Vpc{VpcId: id, VpcName: request.VpcName, CidrBlock: request.CidrBlock, Status: vpcStatusAvailable})
+	response := vpc.CreateCreateVpcResponse()
+	response.VpcId = id
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) DescribeVpcs(request *vpc.DescribeVpcsRequest) (*vpc.DescribeVpcsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := vpc.CreateDescribeVpcsResponse()
+	for _, v := range f.vpcs {
+		if (request.VpcId == "" || v.VpcId == request.VpcId) && (request.VpcName == "" || v.VpcName == request.VpcName) {
+			response.Vpcs.Vpc = append(response.Vpcs.Vpc, *v)
+		}
+	}
+	response.TotalCount = len(response.Vpcs.Vpc)
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) DescribeVpcAttribute(request *vpc.DescribeVpcAttributeRequest) (*vpc.DescribeVpcAttributeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range f.vpcs {
+		if v.VpcId == request.VpcId {
+			response := vpc.CreateDescribeVpcAttributeResponse()
+			response.VpcId = v.VpcId
+			response.CidrBlock = v.CidrBlock
+			response.Status = v.Status
+			return response, succeed(response)
+		}
+	}
+	return nil, fmt.Errorf("InvalidVpcId.NotFound: vpc %s does not exist", request.VpcId)
+}
+
+func (f *fakeVPC) CreateVSwitch(request *vpc.CreateVSwitchRequest) (*vpc.CreateVSwitchResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID("vsw")
+	f.vSwitches = append(f.vSwitches, &vpc.VSwitch{
+		VSwitchId:   id,
+		VSwitchName: request.VSwitchName,
+		VpcId:       request.VpcId,
+		ZoneId:      request.ZoneId,
+		CidrBlock:   request.CidrBlock,
+		Status:      vpcStatusAvailable,
+	})
+	response := vpc.CreateCreateVSwitchResponse()
+	response.VSwitchId = id
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) DescribeVSwitches(request *vpc.DescribeVSwitchesRequest) (*vpc.DescribeVSwitchesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := vpc.CreateDescribeVSwitchesResponse()
+	for _, v := range f.vSwitches {
+		if (request.VpcId == "" || v.VpcId == request.VpcId) && (request.ZoneId == "" || v.ZoneId == request.ZoneId) &&
+			(request.VSwitchName == "" || v.VSwitchName == request.VSwitchName) {
+			response.VSwitches.VSwitch = append(response.VSwitches.VSwitch, *v)
+		}
+	}
+	response.TotalCount = len(response.VSwitches.VSwitch)
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) DescribeVSwitchAttributes(request *vpc.DescribeVSwitchAttributesRequest) (*vpc.DescribeVSwitchAttributesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range f.vSwitches {
+		if v.VSwitchId == request.VSwitchId {
+			response := vpc.CreateDescribeVSwitchAttributesResponse()
+			response.VSwitchId = v.VSwitchId
+			response.VpcId = v.VpcId
+			response.CidrBlock = v.CidrBlock
+			response.Status = v.Status
+			return response, succeed(response)
+		}
+	}
+	return nil, fmt.Errorf("InvalidVSwitchId.NotFound: vswitch %s does not exist", request.VSwitchId)
+}
+
+func (f *fakeVPC) AllocateEipAddress(request *vpc.AllocateEipAddressRequest) (*vpc.AllocateEipAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID("eip")
+	eip := &vpc.EipAddress{AllocationId: id, IpAddress: fmt.Sprintf("198.51.100.%d", f.seq), Status: eipStatusAvailable}
+	f.eips = append(f.eips, eip)
+	response := vpc.CreateAllocateEipAddressResponse()
+	response.AllocationId = eip.AllocationId
+	response.EipAddress = eip.IpAddress
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) AssociateEipAddress(request *vpc.AssociateEipAddressRequest) (*vpc.AssociateEipAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	eip := f.eip(request.AllocationId)
+	instance := f.instance(request.InstanceId)
+	if eip == nil || instance == nil || eip.Status != eipStatusAvailable {
+		return nil, fmt.Errorf("IncorrectEipStatus: eip %s can't be associated with instance %s", request.AllocationId, request.InstanceId)
+	}
+	eip.Status = eipStatusInUse
+	eip.InstanceId = instance.InstanceId
+	instance.EipAddress.AllocationId = eip.AllocationId
+	instance.EipAddress.IpAddress = eip.IpAddress
+	response := vpc.CreateAssociateEipAddressResponse()
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) UnassociateEipAddress(request *vpc.UnassociateEipAddressRequest) (*vpc.UnassociateEipAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	eip := f.eip(request.AllocationId)
+	if eip == nil {
+		return nil, fmt.Errorf("InvalidAllocationId.NotFound: eip %s does not exist", request.AllocationId)
+	}
+	if instance := f.instance(eip.InstanceId); instance != nil {
+		instance.EipAddress.AllocationId = ""
+		instance.EipAddress.IpAddress = ""
+	}
+	eip.Status = eipStatusAvailable
+	eip.InstanceId = ""
+	response := vpc.CreateUnassociateEipAddressResponse()
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) ReleaseEipAddress(request *vpc.ReleaseEipAddressRequest) (*vpc.ReleaseEipAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, eip := range f.eips {
+		if eip.AllocationId == request.AllocationId {
+			if eip.Status != eipStatusAvailable {
+				return nil, fmt.Errorf("IncorrectEipStatus: eip %s is in use", request.AllocationId)
+			}
+			f.eips = append(f.eips[:i], f.eips[i+1:]...)
+			delete(f.tags[strings.ToLower(resourceTypeEip)], request.AllocationId)
+			response := vpc.CreateReleaseEipAddressResponse()
+			return response, succeed(response)
+		}
+	}
+	return nil, fmt.Errorf("InvalidAllocationId.NotFound: eip %s does not exist", request.AllocationId)
+}
+
+func (f *fakeVPC) DescribeEipAddresses(request *vpc.DescribeEipAddressesRequest) (*vpc.DescribeEipAddressesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := strings.Split(request.AllocationId, ",")
+	response := vpc.CreateDescribeEipAddressesResponse()
+	for _, eip := range f.eips {
+		if request.AllocationId == "" || containsString(ids, eip.AllocationId) {
+			response.EipAddresses.EipAddress = append(response.EipAddresses.EipAddress, *eip)
+		}
+	}
+	response.TotalCount = len(response.EipAddresses.EipAddress)
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) TagResources(request *vpc.TagResourcesRequest) (*vpc.TagResourcesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := map[string]string{}
+	for _, t := range *request.Tag {
+		tags[t.Key] = t.Value
+	}
+	for _, id := range *request.ResourceId {
+		f.tag(request.ResourceType, id, tags)
+	}
+	response := vpc.CreateTagResourcesResponse()
+	return response, succeed(response)
+}
+
+func (f *fakeVPC) ListTagResources(request *vpc.ListTagResourcesRequest) (*vpc.ListTagResourcesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	if request.ResourceId != nil {
+		ids = *request.ResourceId
+	}
+	tags := map[string]string{}
+	if request.Tag != nil {
+		for _, t := range *request.Tag {
+			tags[t.Key] = t.Value
+		}
+	}
+	response := vpc.CreateListTagResourcesResponse()
+	for _, r := range f.listTags(request.ResourceType, ids, tags) {
+		response.TagResources.TagResource = append(response.TagResources.TagResource, vpc.TagResource{
+			ResourceType: request.ResourceType,
+			ResourceId:   r[0],
+			TagKey:       r[1],
+		})
+	}
+	return response, succeed(response)
+}
+
+// succeed fills the response as a successful http response of alibaba cloud, so that IsSuccess returns true.
+func succeed(response responses.AcsResponse) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return responses.Unmarshal(response, &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, requests.Json)
+}
+
+func matchTags(resourceTags, tags map[string]string) bool {
+	if len(resourceTags) == 0 && len(tags) > 0 {
+		return false
+	}
+	for k, v := range tags {
+		if resourceTags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func findPermission(perms []ecs.Permission, protocol, portRange, cidr string) int {
+	for i, p := range perms {
+		if strings.EqualFold(p.IpProtocol, protocol) && p.PortRange == portRange && p.SourceCidrIp == cidr {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
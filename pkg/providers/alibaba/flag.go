@@ -36,6 +36,61 @@ const deleteUsageExample = `  autok3s -d delete \
     --access-secret <access-secret>
 `
 
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider alibaba \
+    --name <cluster name> \
+    --node <instance id or ip> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider alibaba \
+    --name <cluster name> \
+    --k3s-version <k3s version> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider alibaba \
+    --name <cluster name> \
+    --snapshot-name <snapshot name> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+  autok3s snapshot list \
+    --provider alibaba \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider alibaba \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider alibaba \
+    --name <cluster name> \
+    --repair \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider alibaba \
+    --name <cluster name> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider alibaba \
+    --name <cluster name> \
+    --access-key <access-key> \
+    --access-secret <access-secret>
+`
+
 const sshUsageExample = `  autok3s ssh \
     --provider alibaba \
     --name <cluster name> \
@@ -52,6 +107,18 @@ func (p *Alibaba) GetUsageExample(action string) string {
 		return joinUsageExample
 	case "delete":
 		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
 	case "ssh":
 		return sshUsageExample
 	default:
@@ -101,6 +168,12 @@ func (p *Alibaba) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -238,6 +311,9 @@ func (p *Alibaba) overwriteMetadata(matched *types.Cluster) {
 	p.InstallScript = matched.InstallScript
 	p.Network = matched.Network
 	// needed to be overwrite.
+	if p.Credential == "" {
+		p.Credential = matched.Credential
+	}
 	if p.K3sChannel == "" {
 		p.K3sChannel = matched.K3sChannel
 	}
@@ -250,6 +326,15 @@ func (p *Alibaba) overwriteMetadata(matched *types.Cluster) {
 	if p.Registry == "" {
 		p.Registry = matched.Registry
 	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.AdminCIDRs == "" {
+		p.AdminCIDRs = matched.AdminCIDRs
+	}
+	if p.FirewallRules == "" {
+		p.FirewallRules = matched.FirewallRules
+	}
 	if p.MasterExtraArgs == "" {
 		p.MasterExtraArgs = matched.MasterExtraArgs
 	}
@@ -268,6 +353,12 @@ func (p *Alibaba) sharedFlags() []types.Flag {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -391,6 +482,24 @@ func (p *Alibaba) sharedFlags() []types.Flag {
 			V:     p.Registry,
 			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
 		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "admin-cidrs",
+			P:     &p.AdminCIDRs,
+			V:     p.AdminCIDRs,
+			Usage: "CIDRs allowed to access ssh, kube api-server and ui of the security group created by autok3s, separated by comma, default is 0.0.0.0/0",
+		},
+		{
+			Name:  "firewall-rules",
+			P:     &p.FirewallRules,
+			V:     p.FirewallRules,
+			Usage: "Extra ingress rules of the security group in the format of <protocol>:<port>[-<port>]:<cidr>, separated by comma. e.g.(--firewall-rules 'tcp:30000-32767:0.0.0.0/0')",
+		},
 		{
 			Name:  "datastore",
 			P:     &p.DataStore,
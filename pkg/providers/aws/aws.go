@@ -2,6 +2,7 @@ package aws
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -50,7 +51,6 @@ const (
 	ui                       = false
 	cloudControllerManager   = false
 	defaultRegion            = "us-east-1"
-	ipRange                  = "0.0.0.0/0"
 	defaultZoneID            = "us-east-1a"
 	defaultSecurityGroupName = "autok3s"
 	defaultDeviceName        = "/dev/sda1"
@@ -68,7 +68,7 @@ type Amazon struct {
 	typesaws.Options `json:",inline"`
 	types.Status     `json:"status"`
 
-	client *ec2.EC2
+	client ec2Client
 	m      *sync.Map
 	logger *logrus.Logger
 }
@@ -116,11 +116,19 @@ func (p *Amazon) GetProviderName() string {
 	return p.Provider
 }
 
+func (p *Amazon) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Amazon) GetClusterName() string {
+	return p.Name
+}
+
 func (p *Amazon) GenerateClusterName() {
 	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.GetProviderName())
 }
 
-func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
+func (p *Amazon) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
 	logFile, err := common.GetLogFile(p.Name)
 	if err != nil {
 		return err
@@ -152,7 +160,7 @@ func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
 				if p.CloudControllerManager {
 					p.logger.Infof("K3s UI URL: https://<using `kubectl get svc -A` get UI address>:8999")
 				} else {
-					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].PublicIPAddress[0])
+					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].ExternalIP())
 				}
 			}
 			cluster.SaveClusterState(c, common.StatusRunning)
@@ -177,6 +185,7 @@ func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
 		return err
 	}
 
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(func() error {
 		return nil
 	}, ssh)
@@ -184,7 +193,7 @@ func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
 		return err
 	}
 	c.Logger = p.logger
-	if err = cluster.InitK3sCluster(c); err != nil {
+	if err = cluster.InitK3sCluster(ctx, c); err != nil {
 		return err
 	}
 	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
@@ -193,7 +202,7 @@ func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
 		extraManifests := []string{fmt.Sprintf(deployCCMCommand,
 			base64.StdEncoding.EncodeToString([]byte(amazonCCMTmpl)), common.K3sManifestsDir)}
 		p.logger.Infof("[%s] start deploy aws additional manifests\n", p.GetProviderName())
-		if err := cluster.DeployExtraManifest(c, extraManifests); err != nil {
+		if err := cluster.DeployExtraManifest(ctx, c, extraManifests); err != nil {
 			return err
 		}
 		p.logger.Infof("[%s] successfully deploy aws additional manifests\n", p.GetProviderName())
@@ -201,7 +210,7 @@ func (p *Amazon) CreateK3sCluster(ssh *types.SSH) (err error) {
 	return nil
 }
 
-func (p *Amazon) JoinK3sNode(ssh *types.SSH) (err error) {
+func (p *Amazon) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
 	if p.m == nil {
 		p.m = new(syncmap.Map)
 	}
@@ -240,6 +249,7 @@ func (p *Amazon) JoinK3sNode(ssh *types.SSH) (err error) {
 		return err
 	}
 
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(p.joinCheck, ssh)
 	if err != nil {
 		return err
@@ -267,7 +277,7 @@ func (p *Amazon) JoinK3sNode(ssh *types.SSH) (err error) {
 	c.Logger = p.logger
 	added.Logger = p.logger
 	// join K3s node.
-	if err := cluster.JoinK3sNode(c, added); err != nil {
+	if err := cluster.JoinK3sNode(ctx, c, added); err != nil {
 		return err
 	}
 
@@ -275,7 +285,7 @@ func (p *Amazon) JoinK3sNode(ssh *types.SSH) (err error) {
 	return nil
 }
 
-func (p *Amazon) DeleteK3sCluster(f bool) (err error) {
+func (p *Amazon) DeleteK3sCluster(ctx context.Context, f bool) (err error) {
 	isConfirmed := true
 
 	if !f {
@@ -296,6 +306,7 @@ func (p *Amazon) DeleteK3sCluster(f bool) (err error) {
 		}()
 		p.logger = common.NewLogger(common.Debug, logFile)
 		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
 		p.newClient()
 		err = p.deleteCluster(f)
 		if err != nil {
@@ -306,6 +317,335 @@ func (p *Amazon) DeleteK3sCluster(f bool) (err error) {
 	return nil
 }
 
+func (p *Amazon) RemoveK3sNode(node string, f bool) error {
+	n, ok := putil.FindNode(p.Status, node)
+	if !ok {
+		return fmt.Errorf("[%s] calling preflight error: node `%s` do not exist in cluster %s", p.GetProviderName(), node, p.Name)
+	}
+
+	isConfirmed := true
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to remove node %s from cluster %s", p.GetProviderName(), n.InstanceID, p.Name))
+	}
+	if !isConfirmed {
+		return nil
+	}
+
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing remove node logic...\n", p.GetProviderName())
+	p.newClient()
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RemoveK3sNode(c, n); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] terminate instance %s\n", p.GetProviderName(), n.InstanceID)
+	input := &ec2.TerminateInstancesInput{}
+	input.SetInstanceIds(aws.StringSlice([]string{n.InstanceID}))
+	if _, err := p.client.TerminateInstances(input); err != nil {
+		return fmt.Errorf("[%s] calling terminateInstances error, msg: %v", p.GetProviderName(), err)
+	}
+
+	// sync master/worker count
+	p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+	p.Metadata.Master = strconv.Itoa(len(p.Status.MasterNodes))
+	p.Metadata.Worker = strconv.Itoa(len(p.Status.WorkerNodes))
+	c = &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed remove node logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Amazon) UpgradeK3sCluster(ctx context.Context, version string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		// the cluster is still running with origin version after rolled back.
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove upgrade state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusUpgrade)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing upgrade logic...\n", p.GetProviderName())
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusUpgrade)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.UpgradeK3sCluster(ctx, c, version); err != nil {
+		return err
+	}
+	p.K3sVersion = version
+
+	p.logger.Infof("[%s] successfully executed upgrade logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Amazon) SaveSnapshot(name string) (*types.Snapshot, error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing save snapshot logic...\n", p.GetProviderName())
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	snapshot, err := cluster.SaveSnapshot(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.Infof("[%s] successfully executed save snapshot logic\n", p.GetProviderName())
+	return snapshot, nil
+}
+
+func (p *Amazon) ListSnapshots() ([]types.Snapshot, error) {
+	return cluster.ListSnapshots(&types.Cluster{Metadata: p.Metadata})
+}
+
+func (p *Amazon) RestoreSnapshot(name string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove restore state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRestore)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing restore snapshot logic...\n", p.GetProviderName())
+
+	c.Status.Status = "restoring"
+	err = cluster.SaveClusterState(c, common.StatusRestore)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.RestoreSnapshot(c, name); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed restore snapshot logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Amazon) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing check logic...\n", p.GetProviderName())
+	p.newClient()
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return nil, err
+	}
+
+	if repair {
+		ids := make([]string, 0)
+		p.m.Range(func(key, value interface{}) bool {
+			if value.(types.Node).InstanceStatus == ec2.InstanceStateNameStopped {
+				ids = append(ids, key.(string))
+			}
+			return true
+		})
+		if len(ids) > 0 {
+			p.logger.Infof("[%s] starting stopped instances %s...\n", p.GetProviderName(), ids)
+			if err := p.startInstances(ids); err != nil {
+				return nil, err
+			}
+			if err := p.getInstanceStatus(ec2.InstanceStateNameRunning); err != nil {
+				return nil, err
+			}
+			// public ip address may be changed after instance restarted.
+			if _, err := p.syncClusterInstance(ssh); err != nil {
+				return nil, err
+			}
+			p.syncNodeAddresses()
+		}
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	reports, err := cluster.CheckK3sCluster(c)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if reports, err = cluster.RepairK3sCluster(c, reports); err != nil {
+			return nil, err
+		}
+		if err := cluster.SaveState(c); err != nil {
+			return nil, fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+		}
+	}
+
+	p.logger.Infof("[%s] successfully executed check logic\n", p.GetProviderName())
+	return reports, nil
+}
+
+func (p *Amazon) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// instances are created when creating cluster or joining nodes, pre-flight checks are executed on them before installing k3s.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, instances don't exist until created", p.GetProviderName())
+}
+
+func (p *Amazon) StartK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing start logic...\n", p.GetProviderName())
+	p.newClient()
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+
+	// record public ip addresses, which may be changed after instances started.
+	previous := make(map[string]string)
+	ids := make([]string, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, node := range nodes {
+			if len(node.PublicIPAddress) > 0 {
+				previous[node.InstanceID] = node.PublicIPAddress[0]
+			}
+			if node.InstanceStatus == ec2.InstanceStateNameStopped {
+				ids = append(ids, node.InstanceID)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] starting instances %s...\n", p.GetProviderName(), ids)
+		if err := p.startInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(ec2.InstanceStateNameRunning); err != nil {
+		return err
+	}
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+	p.syncNodeAddresses()
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RefreshNodeAddresses(c, previous); err != nil {
+		return err
+	}
+
+	c.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed start logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Amazon) StopK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing stop logic...\n", p.GetProviderName())
+	p.newClient()
+
+	if _, err := p.syncClusterInstance(p.GetSSHConfig()); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		if value.(types.Node).InstanceStatus != ec2.InstanceStateNameStopped {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] stopping instances %s...\n", p.GetProviderName(), ids)
+		if err := p.stopInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(ec2.InstanceStateNameStopped); err != nil {
+		return err
+	}
+	p.syncNodeStatusWithInstance(nil)
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed stop logic\n", p.GetProviderName())
+	return nil
+}
+
 func (p *Amazon) SSHK3sNode(ssh *types.SSH, ip string) error {
 	p.logger = common.NewLogger(common.Debug, nil)
 	p.logger.Infof("[%s] executing ssh logic...\n", p.GetProviderName())
@@ -318,8 +658,11 @@ func (p *Amazon) SSHK3sNode(ssh *types.SSH, ip string) error {
 	if ip == "" {
 		// generate node name
 		for _, instance := range instanceList {
-			instanceInfo := ""
-			instanceInfo = *instance.PublicIpAddress
+			// nodes without public ip are connected through bastions by internal ip.
+			instanceInfo := aws.StringValue(instance.PublicIpAddress)
+			if instanceInfo == "" {
+				instanceInfo = aws.StringValue(instance.PrivateIpAddress)
+			}
 			if instanceInfo != "" {
 				for _, t := range instance.Tags {
 					if aws.StringValue(t.Key) != "master" && aws.StringValue(t.Key) != "worker" {
@@ -653,6 +996,14 @@ func (p *Amazon) generateInstance(fn checkFun, ssh *types.SSH) (*types.Cluster,
 		if err := p.configSecurityGroup(); err != nil {
 			return nil, err
 		}
+	} else if p.AdminCIDRs != "" || p.FirewallRules != "" {
+		group, err := p.getSecurityGroup(aws.String(p.SecurityGroup))
+		if err != nil {
+			return nil, err
+		}
+		if err := p.configPermission(group); err != nil {
+			return nil, err
+		}
 	}
 
 	// run ecs master instances.
@@ -694,17 +1045,17 @@ func (p *Amazon) generateInstance(fn checkFun, ssh *types.SSH) (*types.Cluster,
 
 func (p *Amazon) newClient() {
 	if p.AccessKey == "" {
-		p.AccessKey = viper.GetString(p.GetProviderName(), "access-key")
+		p.AccessKey = viper.GetCredential(p.GetProviderName(), p.Credential, "access-key")
 	}
 
 	if p.SecretKey == "" {
-		p.SecretKey = viper.GetString(p.GetProviderName(), "secret-key")
+		p.SecretKey = viper.GetCredential(p.GetProviderName(), p.Credential, "secret-key")
 	}
 	config := aws.NewConfig()
 	config = config.WithRegion(p.Region)
 	config = config.WithCredentials(credentials.NewStaticCredentials(p.AccessKey, p.SecretKey, ""))
 	sess := session.Must(session.NewSession(config))
-	p.client = ec2.New(sess)
+	p.client = newEC2Client(sess)
 }
 
 func (p *Amazon) runInstances(num int, master bool) error {
@@ -724,7 +1075,7 @@ func (p *Amazon) runInstances(num int, master bool) error {
 		DeviceIndex:              aws.Int64(0), // eth0
 		Groups:                   aws.StringSlice([]string{p.SecurityGroup}),
 		SubnetId:                 &p.SubnetID,
-		AssociatePublicIpAddress: aws.Bool(true),
+		AssociatePublicIpAddress: aws.Bool(!p.PrivateAddressOnly),
 	}}
 
 	var iamProfile *ec2.IamInstanceProfileSpecification
@@ -883,6 +1234,40 @@ func (p *Amazon) setInstanceTags(master bool, instanceIDs []*string) error {
 	return err
 }
 
+func (p *Amazon) startInstances(ids []string) error {
+	if _, err := p.client.StartInstances(&ec2.StartInstancesInput{
+		InstanceIds: aws.StringSlice(ids),
+	}); err != nil {
+		return fmt.Errorf("[%s] calling startInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+func (p *Amazon) stopInstances(ids []string) error {
+	if _, err := p.client.StopInstances(&ec2.StopInstancesInput{
+		InstanceIds: aws.StringSlice(ids),
+	}); err != nil {
+		return fmt.Errorf("[%s] calling stopInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+// syncNodeAddresses updates ip addresses of nodes with the synchronized instances.
+func (p *Amazon) syncNodeAddresses() {
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := p.Status.WorkerNodes
+		if v.Master {
+			nodes = p.Status.MasterNodes
+		}
+		if index, b := putil.IsExistedNodes(nodes, v.InstanceID); b {
+			nodes[index].InternalIPAddress = v.InternalIPAddress
+			nodes[index].PublicIPAddress = v.PublicIPAddress
+		}
+		return true
+	})
+}
+
 func (p *Amazon) getInstanceStatus(aimStatus string) error {
 	ids := make([]string, 0)
 	p.m.Range(func(key, value interface{}) bool {
@@ -903,16 +1288,19 @@ func (p *Amazon) getInstanceStatus(aimStatus string) error {
 				return false, err
 			}
 
-			for _, status := range instances.Reservations[0].Instances {
-				if aws.StringValue(status.State.Name) == aimStatus {
-					if value, ok := p.m.Load(aws.StringValue(status.InstanceId)); ok {
-						v := value.(types.Node)
-						v.InstanceStatus = aimStatus
-						p.m.Store(aws.StringValue(status.InstanceId), v)
+			// instances launched by different requests are in different reservations.
+			for _, reservation := range instances.Reservations {
+				for _, status := range reservation.Instances {
+					if aws.StringValue(status.State.Name) == aimStatus {
+						if value, ok := p.m.Load(aws.StringValue(status.InstanceId)); ok {
+							v := value.(types.Node)
+							v.InstanceStatus = aimStatus
+							p.m.Store(aws.StringValue(status.InstanceId), v)
+						}
+						continue
 					}
-					continue
+					return false, nil
 				}
-				return false, nil
 			}
 			return true, nil
 		}); err != nil {
@@ -1038,6 +1426,12 @@ func (p *Amazon) describeInstances() ([]*ec2.Instance, error) {
 }
 
 func (p *Amazon) CreateCheck(ssh *types.SSH) error {
+	if err := cluster.CheckAirGap(p.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	if _, err := putil.FirewallPolicy(p.Metadata, ""); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
 	if p.KeypairName != "" && ssh.SSHKeyPath == "" {
 		return fmt.Errorf("[%s] calling preflight error: must set --ssh-key-path with --keypair-name %s", p.GetProviderName(), p.KeypairName)
 	}
@@ -1341,18 +1735,7 @@ func (p *Amazon) configSecurityGroup() error {
 		}
 	}
 	p.SecurityGroup = aws.StringValue(securityGroup.GroupId)
-	permissionList := p.configPermission(securityGroup)
-	if len(permissionList) != 0 {
-		p.logger.Debugf("authorizing group %s with permissions: %v", defaultSecurityGroupName, permissionList)
-		_, err := p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-			GroupId:       securityGroup.GroupId,
-			IpPermissions: permissionList,
-		})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return p.configPermission(securityGroup)
 }
 
 func (p *Amazon) getSecurityGroup(id *string) (*ec2.SecurityGroup, error) {
@@ -1365,83 +1748,70 @@ func (p *Amazon) getSecurityGroup(id *string) (*ec2.SecurityGroup, error) {
 	return securityGroups.SecurityGroups[0], nil
 }
 
-func (p *Amazon) configPermission(group *ec2.SecurityGroup) []*ec2.IpPermission {
-	perms := []*ec2.IpPermission{}
-	hasPorts := make(map[string]bool)
-	for _, p := range group.IpPermissions {
-		if p.FromPort != nil {
-			hasPorts[fmt.Sprintf("%d/%s", *p.FromPort, *p.IpProtocol)] = true
-		}
-	}
-
-	if !hasPorts["22/tcp"] {
-		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(22)),
-			ToPort:     aws.Int64(int64(22)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(ipRange)}},
-		})
-	}
-
-	if !hasPorts["6443/tcp"] {
-		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(6443)),
-			ToPort:     aws.Int64(int64(6443)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(ipRange)}},
-		})
+// configPermission reconciles the ingress rules of security group with the firewall policy,
+// only rules generated by autok3s for the cluster are revoked, the default security group is shared by clusters in the vpc.
+func (p *Amazon) configPermission(group *ec2.SecurityGroup) error {
+	cidr, err := p.getSubnetCIDR()
+	if err != nil {
+		return fmt.Errorf("[%s] failed to get subnet cidr with id %s, error: %v", p.GetProviderName(), p.SubnetID, err)
 	}
-
-	if !hasPorts["10250/tcp"] {
-		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(10250)),
-			ToPort:     aws.Int64(int64(10250)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(ipRange)}},
-		})
+	desired, err := putil.FirewallPolicy(p.Metadata, cidr)
+	if err != nil {
+		return err
 	}
 
-	if (p.Network == "" || p.Network == "vxlan") && !hasPorts["8472/udp"] {
-		if !hasPorts["8472/udp"] {
-			// udp 8472 for flannel vxlan
-			perms = append(perms, &ec2.IpPermission{
-				IpProtocol: aws.String("udp"),
-				FromPort:   aws.Int64(int64(8472)),
-				ToPort:     aws.Int64(int64(8472)),
-				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(ipRange)}},
+	existing := make([]putil.FirewallRule, 0)
+	for _, perm := range group.IpPermissions {
+		if perm.FromPort == nil || perm.ToPort == nil {
+			continue
+		}
+		for _, r := range perm.IpRanges {
+			existing = append(existing, putil.FirewallRule{
+				Protocol:    aws.StringValue(perm.IpProtocol),
+				FromPort:    int(aws.Int64Value(perm.FromPort)),
+				ToPort:      int(aws.Int64Value(perm.ToPort)),
+				CIDR:        aws.StringValue(r.CidrIp),
+				Description: aws.StringValue(r.Description),
 			})
 		}
 	}
 
-	if p.Cluster && (!hasPorts["2379/tcp"] || !hasPorts["2380/tcp"]) {
-		cidr, err := p.getSubnetCIDR()
-		if err != nil || cidr == "" {
-			p.logger.Errorf("[%s] failed to get subnet cidr with id %s, error: %v", p.GetProviderName(), p.SubnetID, err)
-			cidr = ipRange
+	authorize, revoke := putil.ReconcileFirewall(p.Name, desired, existing)
+	if len(revoke) != 0 {
+		p.logger.Debugf("revoking group %s with permissions: %v", aws.StringValue(group.GroupId), revoke)
+		if _, err := p.client.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: ipPermissions(revoke, false),
+		}); err != nil {
+			return err
 		}
-		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(2379)),
-			ToPort:     aws.Int64(int64(2379)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(cidr)}},
-		})
-		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(2380)),
-			ToPort:     aws.Int64(int64(2380)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(cidr)}},
-		})
 	}
+	if len(authorize) != 0 {
+		p.logger.Debugf("authorizing group %s with permissions: %v", aws.StringValue(group.GroupId), authorize)
+		if _, err := p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: ipPermissions(authorize, true),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if p.UI && !hasPorts["8999/tcp"] {
+func ipPermissions(rules []putil.FirewallRule, withDescription bool) []*ec2.IpPermission {
+	perms := make([]*ec2.IpPermission, 0, len(rules))
+	for _, r := range rules {
+		ipRange := &ec2.IpRange{CidrIp: aws.String(r.CIDR)}
+		if withDescription {
+			ipRange.Description = aws.String(r.Description)
+		}
 		perms = append(perms, &ec2.IpPermission{
-			IpProtocol: aws.String("tcp"),
-			FromPort:   aws.Int64(int64(8999)),
-			ToPort:     aws.Int64(int64(8999)),
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(ipRange)}},
+			IpProtocol: aws.String(r.Protocol),
+			FromPort:   aws.Int64(int64(r.FromPort)),
+			ToPort:     aws.Int64(int64(r.ToPort)),
+			IpRanges:   []*ec2.IpRange{ipRange},
 		})
 	}
-
 	return perms
 }
 
@@ -0,0 +1,254 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAmazon(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Amazon Provider Suite")
+}
+
+var _ = Describe("Amazon provider with fake ec2 client", func() {
+	var (
+		cloud      *fakeEC2
+		ssh        *types.SSH
+		cleanup    func()
+		newClient  func(sess *session.Session) ec2Client
+		newAmazon  func(master, worker string) *Amazon
+		terminated func() []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		cleanup, err = fake.SetupCfgPath()
+		Expect(err).NotTo(HaveOccurred())
+
+		cloud = newFakeEC2(defaultZoneID)
+		newClient = newEC2Client
+		newEC2Client = func(sess *session.Session) ec2Client {
+			return cloud
+		}
+		ssh = &types.SSH{User: defaultUser, Port: "22", SSHKeyPath: "/dev/null"}
+		newAmazon = func(master, worker string) *Amazon {
+			p := newProvider()
+			p.Name = "fake"
+			p.Master = master
+			p.Worker = worker
+			p.AccessKey = "fake"
+			p.SecretKey = "fake"
+			p.logger = fake.Logger()
+			return p
+		}
+		terminated = func() []string {
+			ids := make([]string, 0)
+			for _, instance := range cloud.instances {
+				if aws.StringValue(instance.State.Name) == "terminated" || aws.StringValue(instance.State.Name) == "shutting-down" {
+					ids = append(ids, aws.StringValue(instance.InstanceId))
+				}
+			}
+			return ids
+		}
+	})
+
+	AfterEach(func() {
+		newEC2Client = newClient
+		cleanup()
+	})
+
+	It("creates, joins, rolls back and deletes the instances of cluster", func() {
+		p := newAmazon("1", "1")
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+		Expect(p.VpcID).To(Equal(fakeVpcID))
+		Expect(p.SubnetID).To(Equal(fakeSubnetID))
+
+		c, err := p.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		Expect(c.MasterNodes[0].InstanceStatus).To(Equal("running"))
+		Expect(c.MasterNodes[0].PublicIPAddress[0]).NotTo(BeEmpty())
+		Expect(p.SecurityGroup).NotTo(BeEmpty())
+		Expect(cloud.securityGroups[0].IpPermissions).NotTo(BeEmpty())
+
+		// join a worker with the cluster state, and roll it back.
+		j := newAmazon("0", "1")
+		j.Status = fake.LoadStatus(c.Status)
+		j.SecurityGroup = p.SecurityGroup
+		j.SubnetID = p.SubnetID
+		joined, err := j.generateInstance(j.joinCheck, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(joined.MasterNodes).To(HaveLen(1))
+		Expect(joined.WorkerNodes).To(HaveLen(2))
+
+		Expect(j.Rollback()).To(Succeed())
+		Expect(terminated()).To(HaveLen(1))
+		exist, ids, err := p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeTrue())
+		Expect(ids).To(ConsistOf(c.MasterNodes[0].InstanceID, c.WorkerNodes[0].InstanceID))
+
+		Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+		Expect(terminated()).To(HaveLen(3))
+		exist, _, err = p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeFalse())
+	})
+
+	It("keeps the rules of other clusters sharing the default security group of vpc", func() {
+		rules := func() []string {
+			list := make([]string, 0)
+			for _, perm := range cloud.securityGroups[0].IpPermissions {
+				for _, r := range perm.IpRanges {
+					list = append(list, fmt.Sprintf("%s/%d/%s/%s", aws.StringValue(perm.IpProtocol), aws.Int64Value(perm.FromPort),
+						aws.StringValue(r.CidrIp), aws.StringValue(r.Description)))
+				}
+			}
+			return list
+		}
+		cloud.subnets = append(cloud.subnets, &ec2.Subnet{
+			SubnetId:         aws.String("subnet-other"),
+			VpcId:            aws.String(fakeVpcID),
+			AvailabilityZone: aws.String(defaultZoneID),
+			CidrBlock:        aws.String("172.31.16.0/20"),
+		})
+
+		a := newAmazon("1", "0")
+		a.AdminCIDRs = "192.0.2.0/24"
+		Expect(a.CreateCheck(ssh)).To(Succeed())
+		_, err := a.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		// rules of older autok3s aren't described.
+		_, err = cloud.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId: aws.String(a.SecurityGroup),
+			IpPermissions: []*ec2.IpPermission{{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(22), ToPort: aws.Int64(22),
+				IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		owned := []string{
+			"tcp/22/192.0.2.0/24/accept for ssh(generated by autok3s for fake)",
+			"tcp/6443/192.0.2.0/24/accept for kube api-server(generated by autok3s for fake)",
+			"tcp/6443/172.31.0.0/20/accept for kube api-server(generated by autok3s for fake)",
+			"tcp/10250/172.31.0.0/20/accept for kubelet(generated by autok3s for fake)",
+			"udp/8472/172.31.0.0/20/accept for k3s vxlan(generated by autok3s for fake)",
+			"tcp/22/0.0.0.0/0/",
+		}
+		Expect(rules()).To(ConsistOf(owned))
+
+		// create another cluster in other subnet of the vpc, then change its admin cidrs.
+		b := newAmazon("1", "0")
+		b.Name = "other"
+		b.SubnetID = "subnet-other"
+		b.AdminCIDRs = "198.51.100.0/24"
+		Expect(b.CreateCheck(ssh)).To(Succeed())
+		_, err = b.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cloud.securityGroups).To(HaveLen(1))
+		Expect(b.SecurityGroup).To(Equal(a.SecurityGroup))
+		Expect(rules()).To(ConsistOf(append(owned,
+			"tcp/22/198.51.100.0/24/accept for ssh(generated by autok3s for other)",
+			"tcp/6443/198.51.100.0/24/accept for kube api-server(generated by autok3s for other)",
+			"tcp/6443/172.31.16.0/20/accept for kube api-server(generated by autok3s for other)",
+			"tcp/10250/172.31.16.0/20/accept for kubelet(generated by autok3s for other)",
+			"udp/8472/172.31.16.0/20/accept for k3s vxlan(generated by autok3s for other)",
+		)))
+
+		b.AdminCIDRs = "203.0.113.0/24"
+		Expect(b.configSecurityGroup()).To(Succeed())
+		Expect(rules()).To(ConsistOf(append(owned,
+			"tcp/22/203.0.113.0/24/accept for ssh(generated by autok3s for other)",
+			"tcp/6443/203.0.113.0/24/accept for kube api-server(generated by autok3s for other)",
+			"tcp/6443/172.31.16.0/20/accept for kube api-server(generated by autok3s for other)",
+			"tcp/10250/172.31.16.0/20/accept for kubelet(generated by autok3s for other)",
+			"udp/8472/172.31.16.0/20/accept for k3s vxlan(generated by autok3s for other)",
+		)))
+	})
+
+	It("fails to config the security group if the subnet cidr isn't found", func() {
+		p := newAmazon("1", "0")
+		p.SubnetID = "subnet-missing"
+		p.VpcID = fakeVpcID
+		p.newClient()
+		Expect(p.configSecurityGroup()).To(MatchError(ContainSubstring("failed to get subnet cidr")))
+		Expect(cloud.securityGroups[0].IpPermissions).To(BeEmpty())
+	})
+
+	It("creates and joins the k3s cluster on the instances through ssh", func() {
+		server, err := fake.NewSSHServer()
+		Expect(err).NotTo(HaveOccurred())
+		defer server.Close()
+		ssh.Password = "fake"
+		ssh.Bastions = []types.Bastion{server.Bastion()}
+
+		p := newAmazon("1", "1")
+		p.Token = "fake-token"
+		p.UI = true
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+		Expect(p.CreateK3sCluster(context.Background(), ssh)).To(Succeed())
+		Expect(p.Status.MasterNodes).To(HaveLen(1))
+		Expect(p.Status.WorkerNodes).To(HaveLen(1))
+
+		master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+		masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+		Expect(master.Commands()).To(ContainElement(And(
+			ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("INSTALL_K3S_EXEC='server"))))
+		ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+		Expect(ok).To(BeTrue())
+		Expect(ui).NotTo(BeEmpty())
+		worker := server.Node(p.Status.WorkerNodes[0].PublicIPAddress[0])
+		Expect(worker.Commands()).To(ContainElement(And(
+			ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+
+		// kubeconfig of cluster is merged, and the cluster is saved in state.
+		kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+		state, err := cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Status.Status).To(Equal(common.StatusRunning))
+		Expect(state.Token).To(Equal("fake-token"))
+		Expect(state.Status.InitMaster).To(Equal(p.Status.MasterNodes[0].InstanceID))
+
+		// join a worker to the cluster in state.
+		j := newAmazon("0", "1")
+		j.Status = fake.LoadStatus(state.Status)
+		j.Token = state.Token
+		j.IP = state.IP
+		j.SecurityGroup = p.SecurityGroup
+		j.SubnetID = p.SubnetID
+		Expect(j.JoinK3sNode(context.Background(), ssh)).To(Succeed())
+		Expect(j.Status.WorkerNodes).To(HaveLen(2))
+		for _, n := range j.Status.WorkerNodes {
+			if n.InstanceID == p.Status.WorkerNodes[0].InstanceID {
+				continue
+			}
+			Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+				ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+		}
+		state, err = cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Worker).To(Equal("2"))
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newAmazon("0", "1")
+		_, err := p.generateInstance(p.joinCheck, ssh)
+		Expect(err).To(HaveOccurred())
+		Expect(cloud.instances).To(BeEmpty())
+	})
+})
@@ -0,0 +1,392 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	fakeVpcID    = "vpc-fake"
+	fakeVpcCIDR  = "172.31.0.0/16"
+	fakeSubnetID = "subnet-fake"
+)
+
+// fakeEC2 is an in-memory ec2 client, instances are pending after created and become running when described,
+// stopping and shutting-down instances become stopped and terminated when described in the same way.
+type fakeEC2 struct {
+	mu sync.Mutex
+
+	seq            int
+	instances      []*ec2.Instance
+	tags           map[string]map[string]string
+	subnets        []*ec2.Subnet
+	keyPairs       map[string]bool
+	securityGroups []*ec2.SecurityGroup
+	spotRequests   map[string][]*string
+}
+
+func newFakeEC2(zone string) *fakeEC2 {
+	return &fakeEC2{
+		tags: map[string]map[string]string{},
+		subnets: []*ec2.Subnet{{
+			SubnetId:         aws.String(fakeSubnetID),
+			VpcId:            aws.String(fakeVpcID),
+			AvailabilityZone: aws.String(zone),
+			CidrBlock:        aws.String("172.31.0.0/20"),
+			DefaultForAz:     aws.Bool(true),
+		}},
+		keyPairs:     map[string]bool{},
+		spotRequests: map[string][]*string{},
+	}
+}
+
+func (f *fakeEC2) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s-%08d", prefix, f.seq)
+}
+
+func (f *fakeEC2) RunInstances(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &ec2.Reservation{Instances: f.runInstances(int(aws.Int64Value(input.MaxCount)), input.NetworkInterfaces)}, nil
+}
+
+func (f *fakeEC2) runInstances(num int, netSpecs []*ec2.InstanceNetworkInterfaceSpecification) []*ec2.Instance {
+	instances := make([]*ec2.Instance, 0, num)
+	for i := 0; i < num; i++ {
+		id := f.nextID("i")
+		instance := &ec2.Instance{
+			InstanceId:       aws.String(id),
+			State:            &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNamePending)},
+			PrivateIpAddress: aws.String(fmt.Sprintf("172.31.0.%d", f.seq)),
+		}
+		if len(netSpecs) > 0 {
+			instance.SubnetId = netSpecs[0].SubnetId
+			if aws.BoolValue(netSpecs[0].AssociatePublicIpAddress) {
+				instance.PublicIpAddress = aws.String(fmt.Sprintf("203.0.113.%d", f.seq))
+			}
+		}
+		f.instances = append(f.instances, instance)
+		instances = append(instances, f.copyInstance(instance))
+	}
+	return instances
+}
+
+func (f *fakeEC2) StartInstances(input *ec2.StartInstancesInput) (*ec2.StartInstancesOutput, error) {
+	return &ec2.StartInstancesOutput{}, f.setState(input.InstanceIds, ec2.InstanceStateNamePending)
+}
+
+func (f *fakeEC2) StopInstances(input *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+	return &ec2.StopInstancesOutput{}, f.setState(input.InstanceIds, ec2.InstanceStateNameStopping)
+}
+
+func (f *fakeEC2) TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	return &ec2.TerminateInstancesOutput{}, f.setState(input.InstanceIds, ec2.InstanceStateNameShuttingDown)
+}
+
+func (f *fakeEC2) setState(ids []*string, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		instance := f.instance(aws.StringValue(id))
+		if instance == nil {
+			return awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("instance %s does not exist", aws.StringValue(id)), nil)
+		}
+		instance.State.Name = aws.String(state)
+	}
+	return nil
+}
+
+func (f *fakeEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reservation := &ec2.Reservation{}
+	for _, instance := range f.instances {
+		if len(input.InstanceIds) > 0 && !containsID(input.InstanceIds, aws.StringValue(instance.InstanceId)) {
+			continue
+		}
+		if !f.matchTags(aws.StringValue(instance.InstanceId), input.Filters) {
+			continue
+		}
+		switch aws.StringValue(instance.State.Name) {
+		case ec2.InstanceStateNamePending:
+			instance.State.Name = aws.String(ec2.InstanceStateNameRunning)
+		case ec2.InstanceStateNameStopping:
+			instance.State.Name = aws.String(ec2.InstanceStateNameStopped)
+		case ec2.InstanceStateNameShuttingDown:
+			instance.State.Name = aws.String(ec2.InstanceStateNameTerminated)
+		}
+		reservation.Instances = append(reservation.Instances, f.copyInstance(instance))
+	}
+	output := &ec2.DescribeInstancesOutput{}
+	if len(reservation.Instances) > 0 {
+		output.Reservations = []*ec2.Reservation{reservation}
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) RequestSpotInstances(input *ec2.RequestSpotInstancesInput) (*ec2.RequestSpotInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requestID := f.nextID("sir")
+	instances := f.runInstances(int(aws.Int64Value(input.InstanceCount)), input.LaunchSpecification.NetworkInterfaces)
+	for _, instance := range instances {
+		f.spotRequests[requestID] = append(f.spotRequests[requestID], instance.InstanceId)
+	}
+	return &ec2.RequestSpotInstancesOutput{
+		SpotInstanceRequests: []*ec2.SpotInstanceRequest{{SpotInstanceRequestId: aws.String(requestID)}},
+	}, nil
+}
+
+func (f *fakeEC2) DescribeSpotInstanceRequests(input *ec2.DescribeSpotInstanceRequestsInput) (*ec2.DescribeSpotInstanceRequestsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &ec2.DescribeSpotInstanceRequestsOutput{}
+	for _, requestID := range input.SpotInstanceRequestIds {
+		ids, ok := f.spotRequests[aws.StringValue(requestID)]
+		if !ok {
+			return nil, awserr.New("InvalidSpotInstanceRequestID.NotFound", "spot request does not exist", nil)
+		}
+		for _, id := range ids {
+			output.SpotInstanceRequests = append(output.SpotInstanceRequests, &ec2.SpotInstanceRequest{
+				SpotInstanceRequestId: requestID,
+				InstanceId:            id,
+			})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) WaitUntilSpotInstanceRequestFulfilled(input *ec2.DescribeSpotInstanceRequestsInput) error {
+	_, err := f.DescribeSpotInstanceRequests(input)
+	return err
+}
+
+func (f *fakeEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range input.Resources {
+		if f.tags[aws.StringValue(id)] == nil {
+			f.tags[aws.StringValue(id)] = map[string]string{}
+		}
+		for _, tag := range input.Tags {
+			f.tags[aws.StringValue(id)][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeEC2) DeleteTags(input *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range input.Resources {
+		for _, tag := range input.Tags {
+			tags := f.tags[aws.StringValue(id)]
+			if v, ok := tags[aws.StringValue(tag.Key)]; ok && (tag.Value == nil || aws.StringValue(tag.Value) == v) {
+				delete(tags, aws.StringValue(tag.Key))
+			}
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeAccountAttributes(input *ec2.DescribeAccountAttributesInput) (*ec2.DescribeAccountAttributesOutput, error) {
+	return &ec2.DescribeAccountAttributesOutput{
+		AccountAttributes: []*ec2.AccountAttribute{{
+			AttributeName:   aws.String("default-vpc"),
+			AttributeValues: []*ec2.AccountAttributeValue{{AttributeValue: aws.String(fakeVpcID)}},
+		}},
+	}, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, subnet := range f.subnets {
+		if matchFilters(input.Filters, map[string]string{
+			"subnet-id":         aws.StringValue(subnet.SubnetId),
+			"vpc-id":            aws.StringValue(subnet.VpcId),
+			"availability-zone": aws.StringValue(subnet.AvailabilityZone),
+		}) {
+			s := *subnet
+			s.Tags = f.resourceTags(aws.StringValue(subnet.SubnetId))
+			output.Subnets = append(output.Subnets, &s)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) DescribeKeyPairs(input *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &ec2.DescribeKeyPairsOutput{}
+	for _, name := range input.KeyNames {
+		if !f.keyPairs[aws.StringValue(name)] {
+			return nil, awserr.New("InvalidKeyPair.NotFound", fmt.Sprintf("key pair %s does not exist", aws.StringValue(name)), nil)
+		}
+		output.KeyPairs = append(output.KeyPairs, &ec2.KeyPairInfo{KeyName: name})
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) ImportKeyPair(input *ec2.ImportKeyPairInput) (*ec2.ImportKeyPairOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keyPairs[aws.StringValue(input.KeyName)] = true
+	return &ec2.ImportKeyPairOutput{KeyName: input.KeyName}, nil
+}
+
+func (f *fakeEC2) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, group := range f.securityGroups {
+		if len(input.GroupIds) > 0 && !containsID(input.GroupIds, aws.StringValue(group.GroupId)) {
+			continue
+		}
+		if !matchFilters(input.Filters, map[string]string{
+			"group-name": aws.StringValue(group.GroupName),
+			"vpc-id":     aws.StringValue(group.VpcId),
+		}) {
+			continue
+		}
+		g := *group
+		g.Tags = f.resourceTags(aws.StringValue(group.GroupId))
+		output.SecurityGroups = append(output.SecurityGroups, &g)
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) CreateSecurityGroup(input *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID("sg")
+	f.securityGroups = append(f.securityGroups, &ec2.SecurityGroup{
+		GroupId:     aws.String(id),
+		GroupName:   input.GroupName,
+		Description: input.Description,
+		VpcId:       input.VpcId,
+	})
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (f *fakeEC2) AuthorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := f.securityGroup(aws.StringValue(input.GroupId))
+	if group == nil {
+		return nil, awserr.New("InvalidGroup.NotFound", "security group does not exist", nil)
+	}
+	for _, perm := range input.IpPermissions {
+		for _, r := range perm.IpRanges {
+			if findPermission(group.IpPermissions, perm, aws.StringValue(r.CidrIp)) >= 0 {
+				return nil, awserr.New("InvalidPermission.Duplicate", "the specified rule already exists", nil)
+			}
+			group.IpPermissions = append(group.IpPermissions, &ec2.IpPermission{
+				IpProtocol: perm.IpProtocol,
+				FromPort:   perm.FromPort,
+				ToPort:     perm.ToPort,
+				IpRanges:   []*ec2.IpRange{r},
+			})
+		}
+	}
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (f *fakeEC2) RevokeSecurityGroupIngress(input *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := f.securityGroup(aws.StringValue(input.GroupId))
+	if group == nil {
+		return nil, awserr.New("InvalidGroup.NotFound", "security group does not exist", nil)
+	}
+	for _, perm := range input.IpPermissions {
+		for _, r := range perm.IpRanges {
+			index := findPermission(group.IpPermissions, perm, aws.StringValue(r.CidrIp))
+			if index < 0 {
+				return nil, awserr.New("InvalidPermission.NotFound", "the specified rule does not exist", nil)
+			}
+			group.IpPermissions = append(group.IpPermissions[:index], group.IpPermissions[index+1:]...)
+		}
+	}
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (f *fakeEC2) instance(id string) *ec2.Instance {
+	for _, instance := range f.instances {
+		if aws.StringValue(instance.InstanceId) == id {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (f *fakeEC2) securityGroup(id string) *ec2.SecurityGroup {
+	for _, group := range f.securityGroups {
+		if aws.StringValue(group.GroupId) == id {
+			return group
+		}
+	}
+	return nil
+}
+
+func (f *fakeEC2) copyInstance(instance *ec2.Instance) *ec2.Instance {
+	i := *instance
+	i.State = &ec2.InstanceState{Name: aws.String(aws.StringValue(instance.State.Name))}
+	i.Tags = f.resourceTags(aws.StringValue(instance.InstanceId))
+	return &i
+}
+
+func (f *fakeEC2) resourceTags(id string) []*ec2.Tag {
+	tags := make([]*ec2.Tag, 0)
+	for k, v := range f.tags[id] {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return tags
+}
+
+// matchTags returns whether the resource matches all `tag:<key>` filters.
+func (f *fakeEC2) matchTags(id string, filters []*ec2.Filter) bool {
+	values := map[string]string{}
+	for k, v := range f.tags[id] {
+		values["tag:"+k] = v
+	}
+	return matchFilters(filters, values)
+}
+
+func matchFilters(filters []*ec2.Filter, values map[string]string) bool {
+	for _, filter := range filters {
+		v, ok := values[aws.StringValue(filter.Name)]
+		if !ok || !containsID(filter.Values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func findPermission(perms []*ec2.IpPermission, perm *ec2.IpPermission, cidr string) int {
+	for i, p := range perms {
+		if strings.EqualFold(aws.StringValue(p.IpProtocol), aws.StringValue(perm.IpProtocol)) &&
+			aws.Int64Value(p.FromPort) == aws.Int64Value(perm.FromPort) && aws.Int64Value(p.ToPort) == aws.Int64Value(perm.ToPort) &&
+			len(p.IpRanges) > 0 && aws.StringValue(p.IpRanges[0].CidrIp) == cidr {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsID(ids []*string, id string) bool {
+	for _, v := range ids {
+		if aws.StringValue(v) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -38,6 +38,61 @@ const deleteUsageExample = `  autok3s -d delete \
     --secret-key <secret-key> 
 `
 
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider aws \
+    --name <cluster name> \
+    --node <instance id or ip> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider aws \
+    --name <cluster name> \
+    --k3s-version <k3s version> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider aws \
+    --name <cluster name> \
+    --snapshot-name <snapshot name> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+  autok3s snapshot list \
+    --provider aws \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider aws \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider aws \
+    --name <cluster name> \
+    --repair \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider aws \
+    --name <cluster name> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider aws \
+    --name <cluster name> \
+    --access-key <access-key> \
+    --secret-key <secret-key>
+`
+
 const sshUsageExample = `  autok3s ssh \
     --provider aws \
     --name <cluster name> \
@@ -54,6 +109,18 @@ func (p *Amazon) GetUsageExample(action string) string {
 		return joinUsageExample
 	case "delete":
 		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
 	case "ssh":
 		return sshUsageExample
 	default:
@@ -90,6 +157,12 @@ func (p *Amazon) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -217,6 +290,9 @@ func (p *Amazon) overwriteMetadata(matched *types.Cluster) {
 	p.InstallScript = matched.InstallScript
 	p.Network = matched.Network
 	// needed to be overwrite.
+	if p.Credential == "" {
+		p.Credential = matched.Credential
+	}
 	if p.K3sChannel == "" {
 		p.K3sChannel = matched.K3sChannel
 	}
@@ -229,6 +305,15 @@ func (p *Amazon) overwriteMetadata(matched *types.Cluster) {
 	if p.Registry == "" {
 		p.Registry = matched.Registry
 	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.AdminCIDRs == "" {
+		p.AdminCIDRs = matched.AdminCIDRs
+	}
+	if p.FirewallRules == "" {
+		p.FirewallRules = matched.FirewallRules
+	}
 	if p.MasterExtraArgs == "" {
 		p.MasterExtraArgs = matched.MasterExtraArgs
 	}
@@ -247,6 +332,12 @@ func (p *Amazon) sharedFlags() []types.Flag {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -341,6 +432,12 @@ func (p *Amazon) sharedFlags() []types.Flag {
 			V:     p.SpotPrice,
 			Usage: "spot instance bid price (in dollar)",
 		},
+		{
+			Name:  "private-address-only",
+			P:     &p.PrivateAddressOnly,
+			V:     p.PrivateAddressOnly,
+			Usage: "Only use a private IP address for instances, which need to be connected through --bastion",
+		},
 		{
 			Name:  "ip",
 			P:     &p.IP,
@@ -389,6 +486,24 @@ func (p *Amazon) sharedFlags() []types.Flag {
 			V:     p.Registry,
 			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
 		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "admin-cidrs",
+			P:     &p.AdminCIDRs,
+			V:     p.AdminCIDRs,
+			Usage: "CIDRs allowed to access ssh, kube api-server and ui of the security group created by autok3s, separated by comma, default is 0.0.0.0/0",
+		},
+		{
+			Name:  "firewall-rules",
+			P:     &p.FirewallRules,
+			V:     p.FirewallRules,
+			Usage: "Extra ingress rules of the security group in the format of <protocol>:<port>[-<port>]:<cidr>, separated by comma. e.g.(--firewall-rules 'tcp:30000-32767:0.0.0.0/0')",
+		},
 		{
 			Name:  "datastore",
 			P:     &p.DataStore,
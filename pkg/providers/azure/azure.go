@@ -0,0 +1,535 @@
+package azure
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typesazure "github.com/cnrancher/autok3s/pkg/types/azure"
+	"github.com/cnrancher/autok3s/pkg/utils"
+	"github.com/cnrancher/autok3s/pkg/viper"
+
+	"golang.org/x/sync/syncmap"
+)
+
+const (
+	providerName = "azure"
+
+	defaultUser            = "azureuser"
+	defaultLocation        = "eastus"
+	k3sVersion             = ""
+	k3sChannel             = "stable"
+	k3sInstallScript       = "https://get.k3s.io"
+	vmSize                 = "Standard_B2s"
+	image                  = "Canonical:0001-com-ubuntu-server-focal:20_04-lts-gen2:latest"
+	diskSize               = "30"
+	networkIPRange         = "10.0.0.0/16"
+	subnetIPRange          = "10.0.0.0/24"
+	subnetName             = "default"
+	securityRulePrefix     = "autok3s-rule-"
+	securityRulePriority   = 100
+	master                 = "0"
+	worker                 = "0"
+	ui                     = false
+	cloudControllerManager = false
+	dockerScript           = "curl -sSL https://get.docker.com | sh - %s"
+)
+
+type Azure struct {
+	types.Metadata     `json:",inline"`
+	typesazure.Options `json:",inline"`
+	types.Status       `json:"status"`
+	putil.Cloud        `json:"-"`
+
+	client armClient
+	// ids of the subnet and security group which network interfaces of machines are created with.
+	subnetID        string
+	securityGroupID string
+	// adminUsername and publicKey are the user created on machines and its authorized key.
+	adminUsername string
+	publicKey     string
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *Azure {
+	p := &Azure{
+		Metadata: types.Metadata{
+			Provider:               providerName,
+			Master:                 master,
+			Worker:                 worker,
+			UI:                     ui,
+			CloudControllerManager: cloudControllerManager,
+			K3sVersion:             k3sVersion,
+			K3sChannel:             k3sChannel,
+			InstallScript:          k3sInstallScript,
+			Cluster:                false,
+			DockerScript:           dockerScript,
+		},
+		Options: typesazure.Options{
+			Location: defaultLocation,
+			VMSize:   vmSize,
+			Image:    image,
+			DiskSize: diskSize,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+	}
+	p.Cloud = putil.Cloud{
+		Driver:        p,
+		Metadata:      &p.Metadata,
+		Options:       &p.Options,
+		Status:        &p.Status,
+		RunningStatus: typesazure.StatusRunning,
+		StoppedStatus: typesazure.StatusDeallocated,
+		Nodes:         new(syncmap.Map),
+	}
+	return p
+}
+
+func (p *Azure) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Azure) GetClusterName() string {
+	return p.Name
+}
+
+func (p *Azure) GetRegion() string {
+	return p.Location
+}
+
+func (p *Azure) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Location, p.GetProviderName())
+}
+
+func (p *Azure) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	if option, ok := cluster.Options.(typesazure.Options); ok && cluster.CloudControllerManager {
+		return fmt.Sprintf(" --kubelet-arg=cloud-provider=external --kubelet-arg=provider-id=azure:///subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s",
+			option.SubscriptionID, option.ResourceGroup, master.InstanceID)
+	}
+	return ""
+}
+
+func (p *Azure) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *Azure) CreateCheck(ssh *types.SSH) error {
+	if _, err := parseImage(p.Image); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	if _, err := strconv.Atoi(p.DiskSize); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: invalid disk size %s", p.GetProviderName(), p.DiskSize)
+	}
+	return p.Cloud.CreateCheck(ssh)
+}
+
+func (p *Azure) NewClient() error {
+	if p.SubscriptionID == "" {
+		p.SubscriptionID = viper.GetCredential(p.GetProviderName(), p.Credential, "subscription-id")
+	}
+	if p.TenantID == "" {
+		p.TenantID = viper.GetCredential(p.GetProviderName(), p.Credential, "tenant-id")
+	}
+	if p.ClientID == "" {
+		p.ClientID = viper.GetCredential(p.GetProviderName(), p.Credential, "client-id")
+	}
+	if p.ClientSecret == "" {
+		p.ClientSecret = viper.GetCredential(p.GetProviderName(), p.Credential, "client-secret")
+	}
+	client, err := newARMClient(p.SubscriptionID, p.TenantID, p.ClientID, p.ClientSecret)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to create azure resource manager client: %v", p.GetProviderName(), err)
+	}
+	p.client = client
+	return nil
+}
+
+// PrepareResources reads the public key authorized on machines, and creates or reconciles the resource group,
+// virtual network and security group of machines.
+func (p *Azure) PrepareResources(ssh *types.SSH) error {
+	if ssh.SSHKeyPath == "" {
+		keyPath := common.GetDefaultSSHKeyPath(p.Name, p.GetProviderName())
+		if _, err := os.Stat(keyPath); err == nil {
+			ssh.SSHKeyPath = keyPath
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	publicKey, err := putil.CreateKeyPair(ssh, p.GetProviderName(), p.Name, "")
+	if err != nil {
+		return fmt.Errorf("[%s] failed to read public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	p.adminUsername, p.publicKey = ssh.User, strings.TrimSpace(string(publicKey))
+
+	if err := p.configResourceGroup(); err != nil {
+		return err
+	}
+	clusterCIDR, err := p.configNetwork()
+	if err != nil {
+		return err
+	}
+	return p.configSecurityGroup(clusterCIDR)
+}
+
+// configResourceGroup creates the resource group for cluster if it's not specified.
+func (p *Azure) configResourceGroup() error {
+	name := p.resourceGroup()
+	g, err := p.client.GetResourceGroup(name)
+	if err != nil {
+		return fmt.Errorf("[%s] calling getResourceGroup error, msg: %v", p.GetProviderName(), err)
+	}
+	if g == nil {
+		if p.ResourceGroup != "" {
+			return fmt.Errorf("[%s] calling preflight error: resource group %s is not found", p.GetProviderName(), p.ResourceGroup)
+		}
+		p.Logger.Infof("[%s] creating resource group %s...\n", p.GetProviderName(), name)
+		if err := p.client.CreateResourceGroup(resourceGroup{Name: name, Location: p.Location, Tags: p.clusterTags()}); err != nil {
+			return fmt.Errorf("[%s] calling createResourceGroup error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	p.ResourceGroup = name
+	return nil
+}
+
+// configNetwork returns the address prefix of the subnet of machines, the virtual network is created for cluster if it's not specified,
+// and the first subnet of the network is used if the subnet is not specified.
+func (p *Azure) configNetwork() (string, error) {
+	name := p.VirtualNetwork
+	if name == "" {
+		name = p.clusterTag()
+	}
+	n, err := p.client.GetVirtualNetwork(p.ResourceGroup, name)
+	if err != nil {
+		return "", fmt.Errorf("[%s] calling getVirtualNetwork error, msg: %v", p.GetProviderName(), err)
+	}
+	if n == nil {
+		if p.VirtualNetwork != "" {
+			return "", fmt.Errorf("[%s] calling preflight error: virtual network %s is not found", p.GetProviderName(), p.VirtualNetwork)
+		}
+		p.Logger.Infof("[%s] creating virtual network %s...\n", p.GetProviderName(), name)
+		if err := p.client.CreateVirtualNetwork(p.ResourceGroup, virtualNetwork{
+			Name:          name,
+			Location:      p.Location,
+			Tags:          p.clusterTags(),
+			AddressPrefix: networkIPRange,
+			Subnets:       []subnet{{Name: subnetName, AddressPrefix: subnetIPRange}},
+		}); err != nil {
+			return "", fmt.Errorf("[%s] calling createVirtualNetwork error, msg: %v", p.GetProviderName(), err)
+		}
+		if n, err = p.client.GetVirtualNetwork(p.ResourceGroup, name); err != nil || n == nil {
+			return "", fmt.Errorf("[%s] calling getVirtualNetwork error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	for _, s := range n.Subnets {
+		if p.Subnet == "" || s.Name == p.Subnet {
+			p.VirtualNetwork, p.Subnet, p.subnetID = n.Name, s.Name, s.ID
+			return s.AddressPrefix, nil
+		}
+	}
+	return "", fmt.Errorf("[%s] calling preflight error: subnet %s is not found in virtual network %s", p.GetProviderName(), p.Subnet, n.Name)
+}
+
+// configSecurityGroup creates the security group for cluster if it's not specified, and reconciles rules of the group owned by cluster
+// with the policy, rules which aren't created by autok3s, e.g. rules of load balancers, are kept.
+func (p *Azure) configSecurityGroup(clusterCIDR string) error {
+	name := p.SecurityGroup
+	if name == "" {
+		name = p.clusterTag()
+	}
+	nsg, err := p.client.GetSecurityGroup(p.ResourceGroup, name)
+	if err != nil {
+		return fmt.Errorf("[%s] calling getSecurityGroup error, msg: %v", p.GetProviderName(), err)
+	}
+	if nsg != nil && !matchTags(nsg.Tags, p.clusterTags()) {
+		// rules of the specified security group are managed by users.
+		p.SecurityGroup, p.securityGroupID = nsg.Name, nsg.ID
+		return nil
+	}
+	if nsg == nil {
+		if p.SecurityGroup != "" {
+			return fmt.Errorf("[%s] calling preflight error: security group %s is not found", p.GetProviderName(), p.SecurityGroup)
+		}
+		p.Logger.Infof("[%s] creating security group %s...\n", p.GetProviderName(), name)
+		if err := p.client.CreateSecurityGroup(p.ResourceGroup, securityGroup{Name: name, Location: p.Location, Tags: p.clusterTags()}); err != nil {
+			return fmt.Errorf("[%s] calling createSecurityGroup error, msg: %v", p.GetProviderName(), err)
+		}
+		if nsg, err = p.client.GetSecurityGroup(p.ResourceGroup, name); err != nil || nsg == nil {
+			return fmt.Errorf("[%s] calling getSecurityGroup error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+
+	policy, err := putil.FirewallPolicy(p.Metadata, clusterCIDR)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	existing := map[string]securityRule{}
+	for _, r := range nsg.Rules {
+		if strings.HasPrefix(r.Name, securityRulePrefix) {
+			existing[r.Name] = r
+		}
+	}
+	for _, r := range p.securityRules(policy) {
+		if v, ok := existing[r.Name]; !ok || !reflect.DeepEqual(v, r) {
+			if err := p.client.PutSecurityRule(p.ResourceGroup, nsg.Name, r); err != nil {
+				return fmt.Errorf("[%s] calling putSecurityRule error, msg: %v", p.GetProviderName(), err)
+			}
+		}
+		delete(existing, r.Name)
+	}
+	for name := range existing {
+		if err := p.client.DeleteSecurityRule(p.ResourceGroup, nsg.Name, name); err != nil {
+			return fmt.Errorf("[%s] calling deleteSecurityRule error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	p.SecurityGroup, p.securityGroupID = nsg.Name, nsg.ID
+	return nil
+}
+
+// securityRules translates the policy to inbound rules grouped by protocol and source cidr.
+func (p *Azure) securityRules(policy []putil.FirewallRule) []securityRule {
+	rules := make([]securityRule, 0)
+	index := map[string]int{}
+	for _, r := range policy {
+		protocol := strings.Title(strings.ToLower(r.Protocol))
+		key := protocol + "/" + r.CIDR
+		i, ok := index[key]
+		if !ok {
+			i = len(rules)
+			index[key] = i
+			rules = append(rules, securityRule{
+				Name:                fmt.Sprintf("%s%d", securityRulePrefix, i),
+				Priority:            securityRulePriority + i,
+				Protocol:            protocol,
+				SourceAddressPrefix: r.CIDR,
+				Description:         fmt.Sprintf("accept for cluster %s(%s)", p.Name, putil.FirewallRuleOwner),
+			})
+		}
+		if !containsString(rules[i].DestinationPortRanges, r.Port()) {
+			rules[i].DestinationPortRanges = append(rules[i].DestinationPortRanges, r.Port())
+		}
+	}
+	return rules
+}
+
+func (p *Azure) RunInstance(master bool) (*putil.Instance, error) {
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(fmt.Sprintf("%s%s-%s-%s", common.TagClusterPrefix, strings.Split(p.Name, ".")[0], role, suffix))
+	img, err := parseImage(p.Image)
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(p.DiskSize)
+	if err != nil {
+		return nil, err
+	}
+	tags := p.clusterTags()
+	tags["role"] = role
+
+	p.Logger.Infof("[%s] creating virtual machine %s...\n", p.GetProviderName(), name)
+	if err := p.client.CreateVirtualMachine(p.ResourceGroup, vmCreateOpts{
+		Name:            name,
+		Location:        p.Location,
+		Size:            p.VMSize,
+		Image:           *img,
+		DiskSizeGB:      size,
+		SubnetID:        p.subnetID,
+		SecurityGroupID: p.securityGroupID,
+		AdminUsername:   p.adminUsername,
+		PublicKey:       p.publicKey,
+		Tags:            tags,
+	}); err != nil {
+		return nil, fmt.Errorf("[%s] calling createVirtualMachine error, msg: %v", p.GetProviderName(), err)
+	}
+	return &putil.Instance{ID: name, Master: master}, nil
+}
+
+// ConfigResources does nothing, the security group is applied to network interfaces when machines are created.
+func (p *Azure) ConfigResources() error {
+	return nil
+}
+
+func (p *Azure) ListInstances() ([]putil.Instance, error) {
+	if p.client == nil {
+		if err := p.NewClient(); err != nil {
+			return nil, err
+		}
+	}
+	vms, err := p.client.ListVirtualMachines(p.resourceGroup(), p.clusterTags())
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]putil.Instance, 0, len(vms))
+	for _, vm := range vms {
+		instances = append(instances, putil.Instance{
+			ID:        vm.Name,
+			Master:    vm.Tags["role"] == "master",
+			Status:    vm.PowerState,
+			PublicIP:  vm.PublicIP,
+			PrivateIP: vm.PrivateIP,
+		})
+	}
+	return instances, nil
+}
+
+func (p *Azure) StartInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.StartVirtualMachine(p.resourceGroup(), id); err != nil {
+			return fmt.Errorf("[%s] calling startVirtualMachine error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// StopInstances deallocates machines, which are not billed for compute resources when deallocated.
+func (p *Azure) StopInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.DeallocateVirtualMachine(p.resourceGroup(), id); err != nil {
+			return fmt.Errorf("[%s] calling deallocateVirtualMachine error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveInstances deletes machines along with their disks, network interfaces and public ips,
+// the api returns when the delete operations are done.
+func (p *Azure) RemoveInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.DeleteVirtualMachine(p.resourceGroup(), id); err != nil {
+			return fmt.Errorf("[%s] calling deleteVirtualMachine error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveResources deletes the resource group created for cluster with everything in it,
+// or the security group and virtual network created for cluster in the specified resource group.
+func (p *Azure) RemoveResources() error {
+	group := p.resourceGroup()
+	g, err := p.client.GetResourceGroup(group)
+	if err != nil {
+		return fmt.Errorf("[%s] calling getResourceGroup error, msg: %v", p.GetProviderName(), err)
+	}
+	if g == nil {
+		return nil
+	}
+	if matchTags(g.Tags, p.clusterTags()) {
+		p.Logger.Infof("[%s] remove resource group %s\n", p.GetProviderName(), group)
+		if err := p.client.DeleteResourceGroup(group); err != nil {
+			return fmt.Errorf("[%s] calling deleteResourceGroup error, msg: %v", p.GetProviderName(), err)
+		}
+		return nil
+	}
+
+	nsg, err := p.client.GetSecurityGroup(group, p.clusterTag())
+	if err != nil {
+		return fmt.Errorf("[%s] calling getSecurityGroup error, msg: %v", p.GetProviderName(), err)
+	}
+	if nsg != nil && matchTags(nsg.Tags, p.clusterTags()) {
+		p.Logger.Infof("[%s] remove security group %s\n", p.GetProviderName(), nsg.Name)
+		if err := p.client.DeleteSecurityGroup(group, nsg.Name); err != nil {
+			return fmt.Errorf("[%s] calling deleteSecurityGroup error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	n, err := p.client.GetVirtualNetwork(group, p.clusterTag())
+	if err != nil {
+		return fmt.Errorf("[%s] calling getVirtualNetwork error, msg: %v", p.GetProviderName(), err)
+	}
+	if n != nil && matchTags(n.Tags, p.clusterTags()) {
+		p.Logger.Infof("[%s] remove virtual network %s\n", p.GetProviderName(), n.Name)
+		if err := p.client.DeleteVirtualNetwork(group, n.Name); err != nil {
+			return fmt.Errorf("[%s] calling deleteVirtualNetwork error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// CloudControllerManifest returns the manifest of azure cloud controller manager and cloud node manager,
+// which are authorized by the service principal of cluster.
+func (p *Azure) CloudControllerManifest() (string, error) {
+	b, err := json.Marshal(cloudConfig{
+		Cloud:               "AzurePublicCloud",
+		TenantID:            p.TenantID,
+		SubscriptionID:      p.SubscriptionID,
+		AADClientID:         p.ClientID,
+		AADClientSecret:     p.ClientSecret,
+		ResourceGroup:       p.ResourceGroup,
+		Location:            p.Location,
+		VMType:              "standard",
+		SubnetName:          p.Subnet,
+		SecurityGroupName:   p.SecurityGroup,
+		VnetName:            p.VirtualNetwork,
+		VnetResourceGroup:   p.ResourceGroup,
+		LoadBalancerSku:     "standard",
+		UseInstanceMetadata: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(azureCCMTmpl, base64.StdEncoding.EncodeToString(b)), nil
+}
+
+// resourceGroup returns the resource group of machines, which is named after the cluster tag if it's not specified.
+func (p *Azure) resourceGroup() string {
+	if p.ResourceGroup != "" {
+		return p.ResourceGroup
+	}
+	return p.clusterTag()
+}
+
+// clusterTag returns the name of resources created for cluster, which only allows lowercase letters, numbers and dashes.
+func (p *Azure) clusterTag() string {
+	return strings.ToLower(common.TagClusterPrefix + strings.Replace(p.Name, ".", "-", -1))
+}
+
+func (p *Azure) clusterTags() map[string]string {
+	return map[string]string{
+		"autok3s": "true",
+		"cluster": p.clusterTag(),
+	}
+}
+
+// parseImage parses the image urn in the format of <publisher>:<offer>:<sku>:<version>.
+func parseImage(urn string) (*imageReference, error) {
+	parts := strings.Split(urn, ":")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid image %s, must be in the format of <publisher>:<offer>:<sku>:<version>", urn)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid image %s, must be in the format of <publisher>:<offer>:<sku>:<version>", urn)
+		}
+	}
+	return &imageReference{Publisher: parts[0], Offer: parts[1], Sku: parts[2], Version: parts[3]}, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAzure(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Azure Provider Suite")
+}
+
+// newTestAzure returns the provider of cluster "Fake" with the numbers of master and worker,
+// the name isn't lower case as names of resources must be.
+func newTestAzure(master, worker string) *Azure {
+	p := newProvider()
+	p.Name = "Fake"
+	p.Master = master
+	p.Worker = worker
+	p.SubscriptionID = "fake"
+	p.TenantID = "fake"
+	p.ClientID = "fake"
+	p.ClientSecret = "fake"
+	p.Logger = fake.Logger()
+	return p
+}
+
+// loadTestAzure returns the provider with options merged from cluster state, as commands other than create do.
+func loadTestAzure(master, worker string) *Azure {
+	p := newTestAzure(master, worker)
+	Expect(p.MergeClusterOptions()).To(Succeed())
+	p.GenerateClusterName()
+	return p
+}
+
+// decodeCloudConfig returns the cloud config in the secret of cloud controller manager manifest.
+func decodeCloudConfig(manifest string) cloudConfig {
+	var encoded string
+	for _, line := range strings.Split(manifest, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "azure.json: ") {
+			encoded = strings.TrimPrefix(strings.TrimSpace(line), "azure.json: ")
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	Expect(err).NotTo(HaveOccurred())
+	conf := cloudConfig{}
+	Expect(json.Unmarshal(b, &conf)).To(Succeed())
+	return conf
+}
+
+var _ = Describe("Azure provider with fake api", func() {
+	var api *fakeARM
+	suite := fake.NewSuite(types.SSH{User: defaultUser, Port: "22"}, func(s *fake.Suite) {
+		api = newFakeARM()
+		s.Replace(&newARMClient, func(subscriptionID, tenantID, clientID, clientSecret string) (armClient, error) {
+			return api, nil
+		})
+	})
+
+	It("creates the virtual machines in the resource group, virtual network and security group of cluster", func() {
+		p := newTestAzure("1", "1")
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		master := c.MasterNodes[0]
+		Expect(master.InstanceID).To(HavePrefix("autok3s-fake-master-"))
+		Expect(master.InstanceStatus).To(Equal("running"))
+		Expect(master.PublicIPAddress[0]).To(HavePrefix("203.0.113."))
+		Expect(master.InternalIPAddress[0]).To(HavePrefix("10.0.0."))
+
+		Expect(p.clusterTag()).To(Equal("autok3s-fake-eastus-azure"))
+		Expect(api.groups).To(HaveLen(1))
+		Expect(api.groups[0].Name).To(Equal(p.clusterTag()))
+		Expect(api.groups[0].Tags).To(HaveKeyWithValue("cluster", p.clusterTag()))
+		Expect(p.ResourceGroup).To(Equal(p.clusterTag()))
+		Expect(p.VirtualNetwork).To(Equal(p.clusterTag()))
+		Expect(p.Subnet).To(Equal(subnetName))
+		Expect(p.SecurityGroup).To(Equal(p.clusterTag()))
+		for _, vm := range api.vms {
+			Expect(vm.group).To(Equal(p.clusterTag()))
+			Expect(vm.opts.AdminUsername).To(Equal(defaultUser))
+			Expect(vm.opts.PublicKey).To(HavePrefix("ssh-rsa "))
+			Expect(vm.opts.Image).To(Equal(imageReference{
+				Publisher: "Canonical", Offer: "0001-com-ubuntu-server-focal", Sku: "20_04-lts-gen2", Version: "latest"}))
+			Expect(vm.opts.DiskSizeGB).To(Equal(30))
+			Expect(vm.opts.SubnetID).To(HaveSuffix("/virtualNetworks/" + p.clusterTag() + "/subnets/" + subnetName))
+			Expect(vm.opts.SecurityGroupID).To(HaveSuffix("/networkSecurityGroups/" + p.clusterTag()))
+			Expect(vm.Tags).To(HaveKeyWithValue("cluster", p.clusterTag()))
+		}
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	It("opens the ports of cluster in the security group and keeps the rules added by others", func() {
+		p := newTestAzure("1", "0")
+		p.AdminCIDRs = "192.0.2.0/24"
+		p.GenerateClusterName()
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+
+		description := "accept for cluster " + p.Name + "(generated by autok3s)"
+		Expect(api.nsgs).To(HaveLen(1))
+		rules := api.nsgs[0].Rules
+		Expect(rules).To(HaveLen(3))
+		Expect(rules[0]).To(Equal(securityRule{Name: "autok3s-rule-0", Priority: 100, Protocol: "Tcp", SourceAddressPrefix: "192.0.2.0/24",
+			DestinationPortRanges: []string{"22", "6443"}, Description: description}))
+		Expect(rules[1]).To(Equal(securityRule{Name: "autok3s-rule-1", Priority: 101, Protocol: "Tcp", SourceAddressPrefix: subnetIPRange,
+			DestinationPortRanges: []string{"6443", "10250"}, Description: description}))
+		Expect(rules[2]).To(Equal(securityRule{Name: "autok3s-rule-2", Priority: 102, Protocol: "Udp", SourceAddressPrefix: subnetIPRange,
+			DestinationPortRanges: []string{"8472"}, Description: description}))
+
+		// the rule of custom source cidr is added when nodes are joined, the rule of load balancer is kept.
+		Expect(api.PutSecurityRule(p.ResourceGroup, p.SecurityGroup, securityRule{Name: "k8s-lb", Priority: 500})).To(Succeed())
+		j := newTestAzure("0", "1")
+		j.Status = fake.LoadStatus(c.Status)
+		j.Options = p.Options
+		j.AdminCIDRs = p.AdminCIDRs
+		j.FirewallRules = "tcp:30000-32767:0.0.0.0/0"
+		j.GenerateClusterName()
+		_, err = j.GenerateInstance(j.JoinCheck, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.nsgs[0].Rules).To(HaveLen(5))
+		Expect(api.nsgs[0].Rules).To(ContainElement(securityRule{Name: "k8s-lb", Priority: 500}))
+		Expect(api.nsgs[0].Rules).To(ContainElement(securityRule{Name: "autok3s-rule-3", Priority: 103, Protocol: "Tcp",
+			SourceAddressPrefix: "0.0.0.0/0", DestinationPortRanges: []string{"30000-32767"}, Description: description}))
+	})
+
+	It("keeps the specified resource group and security group", func() {
+		Expect(api.CreateResourceGroup(resourceGroup{Name: "fake", Location: defaultLocation})).To(Succeed())
+		Expect(api.CreateSecurityGroup("fake", securityGroup{Name: "fake", Location: defaultLocation})).To(Succeed())
+		p := newTestAzure("1", "0")
+		p.GenerateClusterName()
+		p.ResourceGroup = "fake"
+		p.SecurityGroup = "fake"
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(api.vms[0].group).To(Equal("fake"))
+		Expect(api.vms[0].opts.SecurityGroupID).To(HaveSuffix("/networkSecurityGroups/fake"))
+		// rules of the specified security group are managed by users.
+		Expect(api.nsgs).To(HaveLen(1))
+		Expect(api.nsgs[0].Rules).To(BeEmpty())
+		Expect(api.vnets).To(HaveLen(1))
+
+		Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+		Expect(api.vms).To(BeEmpty())
+		Expect(api.vnets).To(BeEmpty())
+		Expect(api.groups).To(HaveLen(1))
+		Expect(api.nsgs).To(HaveLen(1))
+	})
+
+	Context("with the k3s cluster created", func() {
+		var (
+			server *fake.SSHServer
+			p      *Azure
+		)
+
+		BeforeEach(func() {
+			server = suite.SSHServer()
+			p = newTestAzure("1", "1")
+			p.Token = "fake-token"
+			p.UI = true
+			p.CloudControllerManager = true
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("deploys the cloud controller manager with the resources of cluster", func() {
+			master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+			Expect(master.Commands()).To(ContainElement(And(ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("--disable-cloud-controller"),
+				ContainSubstring(fmt.Sprintf("--kubelet-arg=provider-id=azure:///subscriptions/fake/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s",
+					p.clusterTag(), p.Status.MasterNodes[0].InstanceID)))))
+
+			file := filepath.Join(common.K3sManifestsDir, "cloud-controller-manager.yaml")
+			ccm, ok := master.ReadFile(file)
+			Expect(ok).To(BeTrue())
+			conf := decodeCloudConfig(string(ccm))
+			Expect(conf.AADClientSecret).To(Equal("fake"))
+			Expect(conf.ResourceGroup).To(Equal(p.clusterTag()))
+			Expect(conf.VnetName).To(Equal(p.clusterTag()))
+			Expect(conf.SubnetName).To(Equal(subnetName))
+			Expect(conf.SecurityGroupName).To(Equal(p.clusterTag()))
+			// the manifest with credentials is uploaded as a file only readable by root, instead of passed in commands.
+			Expect(master.FileMode(file)).To(Equal(os.FileMode(0600)))
+			Expect(master.Commands()).NotTo(ContainElement(ContainSubstring(base64.StdEncoding.EncodeToString(ccm))))
+			ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+			Expect(ok).To(BeTrue())
+			Expect(ui).NotTo(BeEmpty())
+		})
+
+		It("joins a worker to the cluster in state", func() {
+			masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+			j := loadTestAzure("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).To(Succeed())
+			Expect(j.Status.WorkerNodes).To(HaveLen(2))
+			for _, n := range j.Status.WorkerNodes {
+				Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+					ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"),
+					ContainSubstring("--kubelet-arg=provider-id=azure:///subscriptions/fake/"))))
+			}
+			Expect(api.groups).To(HaveLen(1))
+			Expect(api.vnets).To(HaveLen(1))
+
+			kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("2"))
+		})
+
+		It("removes the worker failed to join by rollback and keeps the resources of cluster", func() {
+			server.Handle(fake.FailJoin)
+			j := loadTestAzure("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			Expect(api.vms).To(HaveLen(3))
+
+			Expect(j.Rollback()).To(Succeed())
+			exist, ids, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeTrue())
+			Expect(ids).To(ConsistOf(p.Status.MasterNodes[0].InstanceID, p.Status.WorkerNodes[0].InstanceID))
+			Expect(api.groups).To(HaveLen(1))
+			Expect(api.vnets).To(HaveLen(1))
+			Expect(api.nsgs).To(HaveLen(1))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status.Status).To(Equal(common.StatusRunning))
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+		})
+
+		It("deletes the virtual machines and the resources of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(api.vms).To(BeEmpty())
+			Expect(api.groups).To(BeEmpty())
+			Expect(api.vnets).To(BeEmpty())
+			Expect(api.nsgs).To(BeEmpty())
+			_, err := os.Stat(common.GetClusterPath(p.Name, providerName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("rejects the image which isn't an urn", func() {
+		p := newTestAzure("1", "0")
+		p.GenerateClusterName()
+		p.Image = "ubuntu-20.04"
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+		Expect(api.groups).To(BeEmpty())
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTestAzure("0", "1")
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(p.JoinCheck, suite.SSH)
+		Expect(err).To(HaveOccurred())
+		Expect(api.vms).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Azure cloud controller manager", func() {
+	It("is configured with the resources of cluster", func() {
+		p := newProvider()
+		p.Name = "fake.eastus.azure"
+		p.SubscriptionID = "fake-subscription"
+		p.TenantID = "fake-tenant"
+		p.ClientID = "fake-client"
+		p.ClientSecret = "fake-secret"
+		p.ResourceGroup = "fake-group"
+		p.VirtualNetwork = "fake-vnet"
+		p.Subnet = "fake-subnet"
+		p.SecurityGroup = "fake-nsg"
+		manifest, err := p.CloudControllerManifest()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(decodeCloudConfig(manifest)).To(Equal(cloudConfig{
+			Cloud:               "AzurePublicCloud",
+			TenantID:            "fake-tenant",
+			SubscriptionID:      "fake-subscription",
+			AADClientID:         "fake-client",
+			AADClientSecret:     "fake-secret",
+			ResourceGroup:       "fake-group",
+			Location:            defaultLocation,
+			VMType:              "standard",
+			SubnetName:          "fake-subnet",
+			SecurityGroupName:   "fake-nsg",
+			VnetName:            "fake-vnet",
+			VnetResourceGroup:   "fake-group",
+			LoadBalancerSku:     "standard",
+			UseInstanceMetadata: true,
+		}))
+	})
+})
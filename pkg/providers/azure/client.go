@@ -0,0 +1,734 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	apiEndpoint      = "https://management.azure.com"
+	tokenURL         = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	managementScope  = "https://management.azure.com/.default"
+	resourcesVersion = "2021-04-01"
+	networkVersion   = "2022-05-01"
+	computeVersion   = "2022-03-01"
+	pollInterval     = 5 * time.Second
+	operationTimeout = 30 * time.Minute
+)
+
+// armClient is the subset of Azure Resource Manager API used by the provider, which is replaced by an in-memory fake in tests.
+// Get methods return nil if the resource is not found, and delete methods succeed if it's already deleted.
+type armClient interface {
+	GetResourceGroup(name string) (*resourceGroup, error)
+	CreateResourceGroup(group resourceGroup) error
+	DeleteResourceGroup(name string) error
+
+	GetVirtualNetwork(group, name string) (*virtualNetwork, error)
+	CreateVirtualNetwork(group string, vnet virtualNetwork) error
+	DeleteVirtualNetwork(group, name string) error
+
+	GetSecurityGroup(group, name string) (*securityGroup, error)
+	CreateSecurityGroup(group string, nsg securityGroup) error
+	DeleteSecurityGroup(group, name string) error
+	PutSecurityRule(group, nsg string, rule securityRule) error
+	DeleteSecurityRule(group, nsg, name string) error
+
+	CreateVirtualMachine(group string, opts vmCreateOpts) error
+	ListVirtualMachines(group string, tags map[string]string) ([]virtualMachine, error)
+	StartVirtualMachine(group, name string) error
+	DeallocateVirtualMachine(group, name string) error
+	DeleteVirtualMachine(group, name string) error
+}
+
+// newARMClient creates the client of subscription authorized by the service principal.
+// Tests replace it to run the provider without Azure.
+var newARMClient = func(subscriptionID, tenantID, clientID, clientSecret string) (armClient, error) {
+	if subscriptionID == "" || tenantID == "" || clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("subscription-id, tenant-id, client-id and client-secret are required")
+	}
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	source := oauth2.ReuseTokenSource(nil, &clientCredentials{
+		tenantID:     tenantID,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       httpClient,
+	})
+	return &restClient{
+		subscription: "/subscriptions/" + subscriptionID,
+		client:       oauth2.NewClient(ctx, source),
+	}, nil
+}
+
+// clientCredentials requests tokens of service principal with the client credentials grant,
+// see: https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-client-creds-grant-flow.
+type clientCredentials struct {
+	tenantID     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+}
+
+func (c *clientCredentials) Token() (*oauth2.Token, error) {
+	resp, err := c.client.PostForm(fmt.Sprintf(tokenURL, url.PathEscape(c.tenantID)), url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"scope":         {managementScope},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out := &struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("failed to request token of client %s: %s", c.clientID, resp.Status)
+	}
+	if out.AccessToken == "" {
+		return nil, fmt.Errorf("failed to request token of client %s: %s %s", c.clientID, out.Error, out.ErrorDescription)
+	}
+	return &oauth2.Token{
+		AccessToken: out.AccessToken,
+		TokenType:   out.TokenType,
+		Expiry:      time.Now().Add(time.Duration(out.ExpiresIn) * time.Second),
+	}, nil
+}
+
+type resourceGroup struct {
+	Name     string
+	Location string
+	Tags     map[string]string
+}
+
+// virtualNetwork is the virtual network with address space and subnets flattened.
+type virtualNetwork struct {
+	Name          string
+	Location      string
+	Tags          map[string]string
+	AddressPrefix string
+	Subnets       []subnet
+}
+
+type subnet struct {
+	ID            string
+	Name          string
+	AddressPrefix string
+}
+
+type securityGroup struct {
+	ID       string
+	Name     string
+	Location string
+	Tags     map[string]string
+	Rules    []securityRule
+}
+
+// securityRule is the inbound rule which allows traffic.
+type securityRule struct {
+	Name                  string
+	Priority              int
+	Protocol              string
+	SourceAddressPrefix   string
+	DestinationPortRanges []string
+	Description           string
+}
+
+type imageReference struct {
+	Publisher string `json:"publisher"`
+	Offer     string `json:"offer"`
+	Sku       string `json:"sku"`
+	Version   string `json:"version"`
+}
+
+// vmCreateOpts describes the virtual machine, the public ip and network interface of which are created along with it.
+type vmCreateOpts struct {
+	Name            string
+	Location        string
+	Size            string
+	Image           imageReference
+	DiskSizeGB      int
+	SubnetID        string
+	SecurityGroupID string
+	AdminUsername   string
+	PublicKey       string
+	Tags            map[string]string
+}
+
+// virtualMachine is the virtual machine with power state and addresses of primary network interface flattened.
+type virtualMachine struct {
+	Name       string
+	Tags       map[string]string
+	PowerState string
+	PublicIP   string
+	PrivateIP  string
+}
+
+type subResource struct {
+	ID string `json:"id"`
+}
+
+type apiResourceGroup struct {
+	Name     string            `json:"name,omitempty"`
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+type apiVirtualNetwork struct {
+	Name       string            `json:"name,omitempty"`
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties struct {
+		AddressSpace struct {
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"addressSpace"`
+		Subnets []apiSubnet `json:"subnets,omitempty"`
+	} `json:"properties"`
+}
+
+type apiSubnet struct {
+	ID         string `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Properties struct {
+		AddressPrefix string `json:"addressPrefix"`
+	} `json:"properties"`
+}
+
+type apiSecurityGroup struct {
+	ID         string            `json:"id,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties struct {
+		SecurityRules []apiSecurityRule `json:"securityRules,omitempty"`
+	} `json:"properties"`
+}
+
+type apiSecurityRule struct {
+	Name       string `json:"name,omitempty"`
+	Properties struct {
+		Description              string   `json:"description,omitempty"`
+		Priority                 int      `json:"priority"`
+		Direction                string   `json:"direction"`
+		Access                   string   `json:"access"`
+		Protocol                 string   `json:"protocol"`
+		SourceAddressPrefix      string   `json:"sourceAddressPrefix,omitempty"`
+		SourcePortRange          string   `json:"sourcePortRange,omitempty"`
+		DestinationAddressPrefix string   `json:"destinationAddressPrefix,omitempty"`
+		DestinationPortRanges    []string `json:"destinationPortRanges,omitempty"`
+	} `json:"properties"`
+}
+
+type apiPublicIPAddress struct {
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	SKU      struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+	Properties struct {
+		PublicIPAllocationMethod string `json:"publicIPAllocationMethod,omitempty"`
+		IPAddress                string `json:"ipAddress,omitempty"`
+	} `json:"properties"`
+}
+
+type apiNetworkInterface struct {
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties struct {
+		NetworkSecurityGroup *subResource         `json:"networkSecurityGroup,omitempty"`
+		IPConfigurations     []apiIPConfiguration `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+type apiIPConfiguration struct {
+	Name       string `json:"name"`
+	Properties struct {
+		Subnet                    *subResource `json:"subnet,omitempty"`
+		PrivateIPAddress          string       `json:"privateIPAddress,omitempty"`
+		PrivateIPAllocationMethod string       `json:"privateIPAllocationMethod,omitempty"`
+		PublicIPAddress           *subResource `json:"publicIPAddress,omitempty"`
+	} `json:"properties"`
+}
+
+type apiVirtualMachine struct {
+	Name       string            `json:"name,omitempty"`
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties struct {
+		HardwareProfile struct {
+			VMSize string `json:"vmSize"`
+		} `json:"hardwareProfile"`
+		StorageProfile *apiStorageProfile `json:"storageProfile,omitempty"`
+		OSProfile      *apiOSProfile      `json:"osProfile,omitempty"`
+		NetworkProfile struct {
+			NetworkInterfaces []apiNetworkInterfaceReference `json:"networkInterfaces"`
+		} `json:"networkProfile"`
+	} `json:"properties"`
+}
+
+type apiStorageProfile struct {
+	ImageReference imageReference `json:"imageReference"`
+	OSDisk         struct {
+		CreateOption string `json:"createOption"`
+		DeleteOption string `json:"deleteOption"`
+		DiskSizeGB   int    `json:"diskSizeGB,omitempty"`
+		ManagedDisk  struct {
+			StorageAccountType string `json:"storageAccountType"`
+		} `json:"managedDisk"`
+	} `json:"osDisk"`
+}
+
+type apiOSProfile struct {
+	ComputerName       string `json:"computerName"`
+	AdminUsername      string `json:"adminUsername"`
+	LinuxConfiguration struct {
+		DisablePasswordAuthentication bool `json:"disablePasswordAuthentication"`
+		SSH                           struct {
+			PublicKeys []apiSSHPublicKey `json:"publicKeys"`
+		} `json:"ssh"`
+	} `json:"linuxConfiguration"`
+}
+
+type apiSSHPublicKey struct {
+	Path    string `json:"path"`
+	KeyData string `json:"keyData"`
+}
+
+type apiNetworkInterfaceReference struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Primary      bool   `json:"primary"`
+		DeleteOption string `json:"deleteOption,omitempty"`
+	} `json:"properties"`
+}
+
+type apiInstanceView struct {
+	Statuses []struct {
+		Code string `json:"code"`
+	} `json:"statuses"`
+}
+
+type apiOperation struct {
+	Status string `json:"status"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type apiError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Code       string
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s %s: %s (%s)", e.Method, e.Path, e.Message, e.Code)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// restClient calls Azure Resource Manager api, see: https://docs.microsoft.com/en-us/rest/api/azure.
+type restClient struct {
+	// subscription is the path of subscription, e.g. /subscriptions/00000000-0000-0000-0000-000000000000.
+	subscription string
+	client       *http.Client
+}
+
+func (c *restClient) GetResourceGroup(name string) (*resourceGroup, error) {
+	out := &apiResourceGroup{}
+	if ok, err := c.get(c.subscription+"/resourcegroups/"+name, resourcesVersion, out); !ok {
+		return nil, err
+	}
+	return &resourceGroup{Name: out.Name, Location: out.Location, Tags: out.Tags}, nil
+}
+
+func (c *restClient) CreateResourceGroup(group resourceGroup) error {
+	return c.operate(http.MethodPut, c.subscription+"/resourcegroups/"+group.Name, resourcesVersion,
+		apiResourceGroup{Location: group.Location, Tags: group.Tags})
+}
+
+func (c *restClient) DeleteResourceGroup(name string) error {
+	return c.operate(http.MethodDelete, c.subscription+"/resourcegroups/"+name, resourcesVersion, nil)
+}
+
+func (c *restClient) GetVirtualNetwork(group, name string) (*virtualNetwork, error) {
+	out := &apiVirtualNetwork{}
+	if ok, err := c.get(c.network(group, "virtualNetworks", name), networkVersion, out); !ok {
+		return nil, err
+	}
+	vnet := &virtualNetwork{Name: out.Name, Location: out.Location, Tags: out.Tags}
+	if len(out.Properties.AddressSpace.AddressPrefixes) > 0 {
+		vnet.AddressPrefix = out.Properties.AddressSpace.AddressPrefixes[0]
+	}
+	for _, s := range out.Properties.Subnets {
+		vnet.Subnets = append(vnet.Subnets, subnet{ID: s.ID, Name: s.Name, AddressPrefix: s.Properties.AddressPrefix})
+	}
+	return vnet, nil
+}
+
+func (c *restClient) CreateVirtualNetwork(group string, vnet virtualNetwork) error {
+	in := apiVirtualNetwork{Location: vnet.Location, Tags: vnet.Tags}
+	in.Properties.AddressSpace.AddressPrefixes = []string{vnet.AddressPrefix}
+	for _, s := range vnet.Subnets {
+		sn := apiSubnet{Name: s.Name}
+		sn.Properties.AddressPrefix = s.AddressPrefix
+		in.Properties.Subnets = append(in.Properties.Subnets, sn)
+	}
+	return c.operate(http.MethodPut, c.network(group, "virtualNetworks", vnet.Name), networkVersion, in)
+}
+
+func (c *restClient) DeleteVirtualNetwork(group, name string) error {
+	return c.operate(http.MethodDelete, c.network(group, "virtualNetworks", name), networkVersion, nil)
+}
+
+func (c *restClient) GetSecurityGroup(group, name string) (*securityGroup, error) {
+	out := &apiSecurityGroup{}
+	if ok, err := c.get(c.network(group, "networkSecurityGroups", name), networkVersion, out); !ok {
+		return nil, err
+	}
+	nsg := &securityGroup{ID: out.ID, Name: out.Name, Location: out.Location, Tags: out.Tags}
+	for _, r := range out.Properties.SecurityRules {
+		nsg.Rules = append(nsg.Rules, securityRule{
+			Name:                  r.Name,
+			Priority:              r.Properties.Priority,
+			Protocol:              r.Properties.Protocol,
+			SourceAddressPrefix:   r.Properties.SourceAddressPrefix,
+			DestinationPortRanges: r.Properties.DestinationPortRanges,
+			Description:           r.Properties.Description,
+		})
+	}
+	return nsg, nil
+}
+
+// CreateSecurityGroup creates the security group without rules, which are put one by one,
+// so that rules added by others, e.g. cloud controller manager, are kept when rules of cluster are reconciled.
+func (c *restClient) CreateSecurityGroup(group string, nsg securityGroup) error {
+	return c.operate(http.MethodPut, c.network(group, "networkSecurityGroups", nsg.Name), networkVersion,
+		apiSecurityGroup{Location: nsg.Location, Tags: nsg.Tags})
+}
+
+func (c *restClient) DeleteSecurityGroup(group, name string) error {
+	return c.operate(http.MethodDelete, c.network(group, "networkSecurityGroups", name), networkVersion, nil)
+}
+
+func (c *restClient) PutSecurityRule(group, nsg string, rule securityRule) error {
+	in := apiSecurityRule{}
+	in.Properties.Description = rule.Description
+	in.Properties.Priority = rule.Priority
+	in.Properties.Direction = "Inbound"
+	in.Properties.Access = "Allow"
+	in.Properties.Protocol = rule.Protocol
+	in.Properties.SourceAddressPrefix = rule.SourceAddressPrefix
+	in.Properties.SourcePortRange = "*"
+	in.Properties.DestinationAddressPrefix = "*"
+	in.Properties.DestinationPortRanges = rule.DestinationPortRanges
+	return c.operate(http.MethodPut, c.network(group, "networkSecurityGroups", nsg)+"/securityRules/"+rule.Name, networkVersion, in)
+}
+
+func (c *restClient) DeleteSecurityRule(group, nsg, name string) error {
+	return c.operate(http.MethodDelete, c.network(group, "networkSecurityGroups", nsg)+"/securityRules/"+name, networkVersion, nil)
+}
+
+// CreateVirtualMachine creates the public ip and network interface named after the virtual machine, then the machine itself.
+func (c *restClient) CreateVirtualMachine(group string, opts vmCreateOpts) error {
+	ipPath := c.network(group, "publicIPAddresses", opts.Name+"-ip")
+	ip := apiPublicIPAddress{Location: opts.Location, Tags: opts.Tags}
+	ip.SKU.Name = "Standard"
+	ip.Properties.PublicIPAllocationMethod = "Static"
+	if err := c.operate(http.MethodPut, ipPath, networkVersion, ip); err != nil {
+		return err
+	}
+
+	nicPath := c.network(group, "networkInterfaces", opts.Name+"-nic")
+	nic := apiNetworkInterface{Location: opts.Location, Tags: opts.Tags}
+	if opts.SecurityGroupID != "" {
+		nic.Properties.NetworkSecurityGroup = &subResource{ID: opts.SecurityGroupID}
+	}
+	ipConfig := apiIPConfiguration{Name: "ipconfig1"}
+	ipConfig.Properties.Subnet = &subResource{ID: opts.SubnetID}
+	ipConfig.Properties.PrivateIPAllocationMethod = "Dynamic"
+	ipConfig.Properties.PublicIPAddress = &subResource{ID: ipPath}
+	nic.Properties.IPConfigurations = []apiIPConfiguration{ipConfig}
+	if err := c.operate(http.MethodPut, nicPath, networkVersion, nic); err != nil {
+		return err
+	}
+
+	vm := apiVirtualMachine{Location: opts.Location, Tags: opts.Tags}
+	vm.Properties.HardwareProfile.VMSize = opts.Size
+	storage := &apiStorageProfile{ImageReference: opts.Image}
+	storage.OSDisk.CreateOption = "FromImage"
+	storage.OSDisk.DeleteOption = "Delete"
+	storage.OSDisk.DiskSizeGB = opts.DiskSizeGB
+	storage.OSDisk.ManagedDisk.StorageAccountType = "StandardSSD_LRS"
+	vm.Properties.StorageProfile = storage
+	osProfile := &apiOSProfile{ComputerName: opts.Name, AdminUsername: opts.AdminUsername}
+	osProfile.LinuxConfiguration.DisablePasswordAuthentication = true
+	osProfile.LinuxConfiguration.SSH.PublicKeys = []apiSSHPublicKey{{
+		Path:    fmt.Sprintf("/home/%s/.ssh/authorized_keys", opts.AdminUsername),
+		KeyData: opts.PublicKey,
+	}}
+	vm.Properties.OSProfile = osProfile
+	ref := apiNetworkInterfaceReference{ID: nicPath}
+	ref.Properties.Primary = true
+	vm.Properties.NetworkProfile.NetworkInterfaces = []apiNetworkInterfaceReference{ref}
+	return c.operate(http.MethodPut, c.compute(group, opts.Name), computeVersion, vm)
+}
+
+func (c *restClient) ListVirtualMachines(group string, tags map[string]string) ([]virtualMachine, error) {
+	items := make([]apiVirtualMachine, 0)
+	err := c.list(c.url(c.subscription+"/resourceGroups/"+group+"/providers/Microsoft.Compute/virtualMachines", computeVersion),
+		func(b json.RawMessage) error {
+			page := make([]apiVirtualMachine, 0)
+			if err := json.Unmarshal(b, &page); err != nil {
+				return err
+			}
+			items = append(items, page...)
+			return nil
+		})
+	if isNotFound(err) {
+		// the resource group isn't created or is deleted along with machines.
+		return []virtualMachine{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vms := make([]virtualMachine, 0, len(items))
+	for _, i := range items {
+		if !matchTags(i.Tags, tags) {
+			continue
+		}
+		vm := virtualMachine{Name: i.Name, Tags: i.Tags}
+		view := &apiInstanceView{}
+		if _, err := c.get(c.compute(group, i.Name)+"/instanceView", computeVersion, view); err != nil {
+			return nil, err
+		}
+		for _, s := range view.Statuses {
+			if strings.HasPrefix(s.Code, "PowerState/") {
+				vm.PowerState = strings.TrimPrefix(s.Code, "PowerState/")
+			}
+		}
+		if len(i.Properties.NetworkProfile.NetworkInterfaces) > 0 {
+			if vm.PrivateIP, vm.PublicIP, err = c.addresses(i.Properties.NetworkProfile.NetworkInterfaces[0].ID); err != nil {
+				return nil, err
+			}
+		}
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// addresses returns the private and public ip of the primary ip configuration of network interface.
+func (c *restClient) addresses(nicID string) (string, string, error) {
+	nic := &apiNetworkInterface{}
+	if ok, err := c.get(nicID, networkVersion, nic); !ok || len(nic.Properties.IPConfigurations) == 0 {
+		return "", "", err
+	}
+	ipConfig := nic.Properties.IPConfigurations[0]
+	if ipConfig.Properties.PublicIPAddress == nil {
+		return ipConfig.Properties.PrivateIPAddress, "", nil
+	}
+	ip := &apiPublicIPAddress{}
+	if _, err := c.get(ipConfig.Properties.PublicIPAddress.ID, networkVersion, ip); err != nil {
+		return "", "", err
+	}
+	return ipConfig.Properties.PrivateIPAddress, ip.Properties.IPAddress, nil
+}
+
+func (c *restClient) StartVirtualMachine(group, name string) error {
+	return c.operate(http.MethodPost, c.compute(group, name)+"/start", computeVersion, nil)
+}
+
+func (c *restClient) DeallocateVirtualMachine(group, name string) error {
+	return c.operate(http.MethodPost, c.compute(group, name)+"/deallocate", computeVersion, nil)
+}
+
+// DeleteVirtualMachine deletes the virtual machine along with its os disk, then the network interface and public ip.
+func (c *restClient) DeleteVirtualMachine(group, name string) error {
+	if err := c.operate(http.MethodDelete, c.compute(group, name), computeVersion, nil); err != nil {
+		return err
+	}
+	if err := c.operate(http.MethodDelete, c.network(group, "networkInterfaces", name+"-nic"), networkVersion, nil); err != nil {
+		return err
+	}
+	return c.operate(http.MethodDelete, c.network(group, "publicIPAddresses", name+"-ip"), networkVersion, nil)
+}
+
+func (c *restClient) network(group, resourceType, name string) string {
+	return fmt.Sprintf("%s/resourceGroups/%s/providers/Microsoft.Network/%s/%s", c.subscription, group, resourceType, name)
+}
+
+func (c *restClient) compute(group, name string) string {
+	return fmt.Sprintf("%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s", c.subscription, group, name)
+}
+
+func (c *restClient) url(path, apiVersion string) string {
+	return apiEndpoint + path + "?api-version=" + apiVersion
+}
+
+// get requests the resource, false is returned if it's not found or failed to get.
+func (c *restClient) get(path, apiVersion string, out interface{}) (bool, error) {
+	if _, err := c.do(http.MethodGet, c.url(path, apiVersion), nil, out); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// operate requests the api which may start a long running operation, and waits for the operation to be done,
+// see: https://docs.microsoft.com/en-us/azure/azure-resource-manager/management/async-operations.
+func (c *restClient) operate(method, path, apiVersion string, in interface{}) error {
+	resp, err := c.do(method, c.url(path, apiVersion), in, nil)
+	if err != nil {
+		if method == http.MethodDelete && isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	deadline := time.Now().Add(operationTimeout)
+	if u := resp.Header.Get("Azure-AsyncOperation"); u != "" {
+		for {
+			time.Sleep(retryAfter(resp))
+			op := &apiOperation{}
+			if resp, err = c.do(http.MethodGet, u, nil, op); err != nil {
+				return err
+			}
+			switch op.Status {
+			case "Succeeded":
+				return nil
+			case "Failed", "Canceled":
+				if op.Error != nil {
+					return fmt.Errorf("%s %s: %s (%s)", method, path, op.Error.Message, op.Error.Code)
+				}
+				return fmt.Errorf("%s %s: operation is %s", method, path, strings.ToLower(op.Status))
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s %s: operation is not done in %s", method, path, operationTimeout)
+			}
+		}
+	}
+	if u := resp.Header.Get("Location"); u != "" && resp.StatusCode == http.StatusAccepted {
+		for resp.StatusCode == http.StatusAccepted {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s %s: operation is not done in %s", method, path, operationTimeout)
+			}
+			time.Sleep(retryAfter(resp))
+			if resp, err = c.do(http.MethodGet, u, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// list requests all pages of the resources, items of each page are passed to fn.
+func (c *restClient) list(u string, fn func(items json.RawMessage) error) error {
+	for u != "" {
+		out := &struct {
+			Value    json.RawMessage `json:"value"`
+			NextLink string          `json:"nextLink"`
+		}{}
+		if _, err := c.do(http.MethodGet, u, nil, out); err != nil {
+			return err
+		}
+		if len(out.Value) > 0 {
+			if err := fn(out.Value); err != nil {
+				return err
+			}
+		}
+		u = out.NextLink
+	}
+	return nil
+}
+
+func (c *restClient) do(method, u string, in, out interface{}) (*http.Response, error) {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+		_ = json.Unmarshal(b, e)
+		return nil, &apiError{
+			StatusCode: resp.StatusCode,
+			Method:     method,
+			Path:       req.URL.Path,
+			Code:       e.Error.Code,
+			Message:    e.Error.Message,
+		}
+	}
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// retryAfter returns the interval of polling the operation, which is suggested by the Retry-After header.
+func retryAfter(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return pollInterval
+}
+
+func matchTags(tags, filters map[string]string) bool {
+	for k, v := range filters {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+package azure
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/azure"
+)
+
+const fakeSubscription = "/subscriptions/fake"
+
+// fakeARM is an in-memory Azure subscription, virtual machines are running with addresses assigned once created.
+type fakeARM struct {
+	mu sync.Mutex
+
+	seq    int
+	groups []*resourceGroup
+	vnets  []*fakeVirtualNetwork
+	nsgs   []*fakeSecurityGroup
+	vms    []*fakeVirtualMachine
+}
+
+type fakeVirtualNetwork struct {
+	virtualNetwork
+	group string
+}
+
+type fakeSecurityGroup struct {
+	securityGroup
+	group string
+}
+
+type fakeVirtualMachine struct {
+	virtualMachine
+	group string
+	opts  vmCreateOpts
+}
+
+func newFakeARM() *fakeARM {
+	return &fakeARM{}
+}
+
+func (f *fakeARM) GetResourceGroup(name string) (*resourceGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, g := range f.groups {
+		if g.Name == name {
+			v := *g
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeARM) CreateResourceGroup(group resourceGroup) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, g := range f.groups {
+		if g.Name == group.Name {
+			return fmt.Errorf("resource group %s already exists", group.Name)
+		}
+	}
+	f.groups = append(f.groups, &group)
+	return nil
+}
+
+// DeleteResourceGroup deletes the resource group with everything in it.
+func (f *fakeARM) DeleteResourceGroup(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, g := range f.groups {
+		if g.Name != name {
+			continue
+		}
+		f.groups = append(f.groups[:i], f.groups[i+1:]...)
+		vnets := make([]*fakeVirtualNetwork, 0)
+		for _, n := range f.vnets {
+			if n.group != name {
+				vnets = append(vnets, n)
+			}
+		}
+		nsgs := make([]*fakeSecurityGroup, 0)
+		for _, n := range f.nsgs {
+			if n.group != name {
+				nsgs = append(nsgs, n)
+			}
+		}
+		vms := make([]*fakeVirtualMachine, 0)
+		for _, vm := range f.vms {
+			if vm.group != name {
+				vms = append(vms, vm)
+			}
+		}
+		f.vnets, f.nsgs, f.vms = vnets, nsgs, vms
+		return nil
+	}
+	return nil
+}
+
+func (f *fakeARM) GetVirtualNetwork(group, name string) (*virtualNetwork, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range f.vnets {
+		if n.group == group && n.Name == name {
+			v := n.virtualNetwork
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeARM) CreateVirtualNetwork(group string, vnet virtualNetwork) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkGroup(group); err != nil {
+		return err
+	}
+	subnets := make([]subnet, 0, len(vnet.Subnets))
+	for _, s := range vnet.Subnets {
+		s.ID = fmt.Sprintf("%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s", fakeSubscription, group, vnet.Name, s.Name)
+		subnets = append(subnets, s)
+	}
+	vnet.Subnets = subnets
+	f.vnets = append(f.vnets, &fakeVirtualNetwork{virtualNetwork: vnet, group: group})
+	return nil
+}
+
+func (f *fakeARM) DeleteVirtualNetwork(group, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, n := range f.vnets {
+		if n.group == group && n.Name == name {
+			f.vnets = append(f.vnets[:i], f.vnets[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeARM) GetSecurityGroup(group, name string) (*securityGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	nsg := f.securityGroup(group, name)
+	if nsg == nil {
+		return nil, nil
+	}
+	v := nsg.securityGroup
+	v.Rules = append([]securityRule{}, nsg.Rules...)
+	return &v, nil
+}
+
+func (f *fakeARM) CreateSecurityGroup(group string, nsg securityGroup) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkGroup(group); err != nil {
+		return err
+	}
+	nsg.ID = fmt.Sprintf("%s/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/%s", fakeSubscription, group, nsg.Name)
+	f.nsgs = append(f.nsgs, &fakeSecurityGroup{securityGroup: nsg, group: group})
+	return nil
+}
+
+func (f *fakeARM) DeleteSecurityGroup(group, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, n := range f.nsgs {
+		if n.group == group && n.Name == name {
+			f.nsgs = append(f.nsgs[:i], f.nsgs[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeARM) PutSecurityRule(group, nsg string, rule securityRule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.securityGroup(group, nsg)
+	if n == nil {
+		return fmt.Errorf("security group not found: %s", nsg)
+	}
+	for i, r := range n.Rules {
+		if r.Name == rule.Name {
+			n.Rules[i] = rule
+			return nil
+		}
+		if r.Priority == rule.Priority {
+			return fmt.Errorf("priority %d of rule %s is used by rule %s", rule.Priority, rule.Name, r.Name)
+		}
+	}
+	n.Rules = append(n.Rules, rule)
+	return nil
+}
+
+func (f *fakeARM) DeleteSecurityRule(group, nsg, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.securityGroup(group, nsg)
+	if n == nil {
+		return fmt.Errorf("security group not found: %s", nsg)
+	}
+	for i, r := range n.Rules {
+		if r.Name == name {
+			n.Rules = append(n.Rules[:i], n.Rules[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeARM) CreateVirtualMachine(group string, opts vmCreateOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkGroup(group); err != nil {
+		return err
+	}
+	if opts.SubnetID == "" {
+		return fmt.Errorf("subnet of virtual machine %s is required", opts.Name)
+	}
+	for _, vm := range f.vms {
+		if vm.group == group && vm.Name == opts.Name {
+			return fmt.Errorf("virtual machine %s already exists", opts.Name)
+		}
+	}
+	f.seq++
+	f.vms = append(f.vms, &fakeVirtualMachine{
+		virtualMachine: virtualMachine{
+			Name:       opts.Name,
+			Tags:       opts.Tags,
+			PowerState: azure.StatusRunning,
+			PublicIP:   fmt.Sprintf("203.0.113.%d", f.seq),
+			PrivateIP:  fmt.Sprintf("10.0.0.%d", f.seq+3),
+		},
+		group: group,
+		opts:  opts,
+	})
+	return nil
+}
+
+func (f *fakeARM) ListVirtualMachines(group string, tags map[string]string) ([]virtualMachine, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vms := make([]virtualMachine, 0)
+	for _, vm := range f.vms {
+		if vm.group == group && matchTags(vm.Tags, tags) {
+			vms = append(vms, vm.virtualMachine)
+		}
+	}
+	return vms, nil
+}
+
+func (f *fakeARM) StartVirtualMachine(group, name string) error {
+	return f.setPowerState(group, name, azure.StatusRunning)
+}
+
+func (f *fakeARM) DeallocateVirtualMachine(group, name string) error {
+	return f.setPowerState(group, name, azure.StatusDeallocated)
+}
+
+func (f *fakeARM) setPowerState(group, name, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, vm := range f.vms {
+		if vm.group == group && vm.Name == name {
+			vm.PowerState = state
+			return nil
+		}
+	}
+	return fmt.Errorf("virtual machine not found: %s", name)
+}
+
+func (f *fakeARM) DeleteVirtualMachine(group, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, vm := range f.vms {
+		if vm.group == group && vm.Name == name {
+			f.vms = append(f.vms[:i], f.vms[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeARM) checkGroup(name string) error {
+	for _, g := range f.groups {
+		if g.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("resource group not found: %s", name)
+}
+
+func (f *fakeARM) securityGroup(group, name string) *fakeSecurityGroup {
+	for _, n := range f.nsgs {
+		if n.group == group && n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
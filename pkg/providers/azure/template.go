@@ -0,0 +1,140 @@
+package azure
+
+// cloudConfig is the `azure.json` of azure cloud provider,
+// see: https://kubernetes-sigs.github.io/cloud-provider-azure/install/configs.
+type cloudConfig struct {
+	Cloud               string `json:"cloud"`
+	TenantID            string `json:"tenantId"`
+	SubscriptionID      string `json:"subscriptionId"`
+	AADClientID         string `json:"aadClientId"`
+	AADClientSecret     string `json:"aadClientSecret"`
+	ResourceGroup       string `json:"resourceGroup"`
+	Location            string `json:"location"`
+	VMType              string `json:"vmType"`
+	SubnetName          string `json:"subnetName"`
+	SecurityGroupName   string `json:"securityGroupName"`
+	VnetName            string `json:"vnetName"`
+	VnetResourceGroup   string `json:"vnetResourceGroup"`
+	LoadBalancerSku     string `json:"loadBalancerSku"`
+	UseInstanceMetadata bool   `json:"useInstanceMetadata"`
+}
+
+// azureCCMTmpl is formatted with base64 encoded cloud config, nodes are initialized by the cloud node manager on each node,
+// and routes are not managed because pods network is provided by flannel.
+const azureCCMTmpl = `
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: azure-cloud-provider
+  namespace: kube-system
+type: Opaque
+data:
+  azure.json: %s
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: ServiceAccount
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: azure-cloud-controller-manager
+  namespace: kube-system
+spec:
+  replicas: 1
+  revisionHistoryLimit: 2
+  selector:
+    matchLabels:
+      app: azure-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        app: azure-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      dnsPolicy: Default
+      hostNetwork: true
+      tolerations:
+      - key: node.cloudprovider.kubernetes.io/uninitialized
+        value: "true"
+        effect: NoSchedule
+      - key: CriticalAddonsOnly
+        operator: Exists
+      - key: node-role.kubernetes.io/master
+        effect: NoSchedule
+      - key: node.kubernetes.io/not-ready
+        effect: NoSchedule
+      containers:
+      - name: azure-cloud-controller-manager
+        image: mcr.microsoft.com/oss/kubernetes/azure-cloud-controller-manager:v1.27.5
+        command:
+        - cloud-controller-manager
+        - --cloud-provider=azure
+        - --cloud-config=/etc/kubernetes/azure.json
+        - --leader-elect=false
+        - --allocate-node-cidrs=false
+        - --configure-cloud-routes=false
+        - --controllers=*,-cloud-node
+        resources:
+          requests:
+            cpu: 100m
+            memory: 128Mi
+        volumeMounts:
+        - name: cloud-config
+          mountPath: /etc/kubernetes
+          readOnly: true
+      volumes:
+      - name: cloud-config
+        secret:
+          secretName: azure-cloud-provider
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: azure-cloud-node-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      app: azure-cloud-node-manager
+  template:
+    metadata:
+      labels:
+        app: azure-cloud-node-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      hostNetwork: true
+      tolerations:
+      - operator: Exists
+      containers:
+      - name: azure-cloud-node-manager
+        image: mcr.microsoft.com/oss/kubernetes/azure-cloud-node-manager:v1.27.5
+        command:
+        - cloud-node-manager
+        - --node-name=$(NODE_NAME)
+        env:
+        - name: NODE_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.nodeName
+        resources:
+          requests:
+            cpu: 50m
+            memory: 50Mi
+`
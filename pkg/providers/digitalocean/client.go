@@ -0,0 +1,351 @@
+package digitalocean
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const apiEndpoint = "https://api.digitalocean.com/v2"
+
+// doClient is the subset of DigitalOcean API used by the provider, which is replaced by an in-memory fake in tests.
+type doClient interface {
+	CreateDroplet(opts dropletCreateOpts) (*droplet, error)
+	ListDroplets(tag string) ([]droplet, error)
+	PowerOnDroplet(id int) error
+	ShutdownDroplet(id int) error
+	DeleteDroplet(id int) error
+
+	GetSSHKey(fingerprint string) (*sshKey, error)
+	ListSSHKeys() ([]sshKey, error)
+	CreateSSHKey(name, publicKey string) (*sshKey, error)
+	DeleteSSHKey(id int) error
+
+	ListFirewalls() ([]firewall, error)
+	CreateFirewall(fw firewall) (*firewall, error)
+	UpdateFirewall(fw firewall) error
+	DeleteFirewall(id string) error
+
+	GetVPC(id string) (*vpc, error)
+}
+
+// newDOClient creates the client authorized by access token, tests replace it to run the provider without DigitalOcean.
+var newDOClient = func(token string) (doClient, error) {
+	if token == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+	return &restClient{
+		endpoint: apiEndpoint,
+		token:    token,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// droplet is the DigitalOcean droplet with addresses flattened.
+type droplet struct {
+	ID        int
+	Name      string
+	Status    string
+	Tags      []string
+	VpcUUID   string
+	PublicIP  string
+	PrivateIP string
+}
+
+type dropletCreateOpts struct {
+	Name    string   `json:"name"`
+	Region  string   `json:"region"`
+	Size    string   `json:"size"`
+	Image   string   `json:"image"`
+	SSHKeys []int    `json:"ssh_keys,omitempty"`
+	VpcUUID string   `json:"vpc_uuid,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+type sshKey struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"public_key"`
+}
+
+type firewall struct {
+	ID            string         `json:"id,omitempty"`
+	Name          string         `json:"name"`
+	InboundRules  []firewallRule `json:"inbound_rules"`
+	OutboundRules []firewallRule `json:"outbound_rules"`
+	Tags          []string       `json:"tags"`
+}
+
+type firewallRule struct {
+	Protocol     string           `json:"protocol"`
+	Ports        string           `json:"ports,omitempty"`
+	Sources      *firewallTargets `json:"sources,omitempty"`
+	Destinations *firewallTargets `json:"destinations,omitempty"`
+}
+
+type firewallTargets struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+type vpc struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Region  string `json:"region"`
+	IPRange string `json:"ip_range"`
+}
+
+// apiDroplet is the droplet returned by api.
+type apiDroplet struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Tags     []string `json:"tags"`
+	VpcUUID  string   `json:"vpc_uuid"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+func (d apiDroplet) convert() droplet {
+	v := droplet{
+		ID:      d.ID,
+		Name:    d.Name,
+		Status:  d.Status,
+		Tags:    d.Tags,
+		VpcUUID: d.VpcUUID,
+	}
+	for _, n := range d.Networks.V4 {
+		switch n.Type {
+		case "public":
+			v.PublicIP = n.IPAddress
+		case "private":
+			v.PrivateIP = n.IPAddress
+		}
+	}
+	return v
+}
+
+type apiError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+type pagination struct {
+	Links struct {
+		Pages struct {
+			Next string `json:"next"`
+		} `json:"pages"`
+	} `json:"links"`
+}
+
+// restClient calls DigitalOcean api, see: https://developers.digitalocean.com/documentation/v2.
+type restClient struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func (c *restClient) CreateDroplet(opts dropletCreateOpts) (*droplet, error) {
+	out := &struct {
+		Droplet apiDroplet `json:"droplet"`
+	}{}
+	if err := c.do(http.MethodPost, "/droplets", nil, opts, out); err != nil {
+		return nil, err
+	}
+	d := out.Droplet.convert()
+	return &d, nil
+}
+
+func (c *restClient) ListDroplets(tag string) ([]droplet, error) {
+	items := make([]apiDroplet, 0)
+	if err := c.list("/droplets", url.Values{"tag_name": {tag}}, "droplets", &items); err != nil {
+		return nil, err
+	}
+	droplets := make([]droplet, 0, len(items))
+	for _, d := range items {
+		droplets = append(droplets, d.convert())
+	}
+	return droplets, nil
+}
+
+func (c *restClient) PowerOnDroplet(id int) error {
+	return c.action(id, "power_on")
+}
+
+func (c *restClient) ShutdownDroplet(id int) error {
+	return c.action(id, "shutdown")
+}
+
+func (c *restClient) action(id int, action string) error {
+	in := map[string]string{"type": action}
+	return c.do(http.MethodPost, fmt.Sprintf("/droplets/%d/actions", id), nil, in, nil)
+}
+
+func (c *restClient) DeleteDroplet(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/droplets/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) GetSSHKey(fingerprint string) (*sshKey, error) {
+	out := &struct {
+		SSHKey sshKey `json:"ssh_key"`
+	}{}
+	err := c.do(http.MethodGet, "/account/keys/"+fingerprint, nil, nil, out)
+	if err != nil {
+		if e, ok := err.(*responseError); ok && e.status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &out.SSHKey, nil
+}
+
+func (c *restClient) ListSSHKeys() ([]sshKey, error) {
+	keys := make([]sshKey, 0)
+	err := c.list("/account/keys", nil, "ssh_keys", &keys)
+	return keys, err
+}
+
+func (c *restClient) CreateSSHKey(name, publicKey string) (*sshKey, error) {
+	out := &struct {
+		SSHKey sshKey `json:"ssh_key"`
+	}{}
+	in := map[string]string{"name": name, "public_key": publicKey}
+	if err := c.do(http.MethodPost, "/account/keys", nil, in, out); err != nil {
+		return nil, err
+	}
+	return &out.SSHKey, nil
+}
+
+func (c *restClient) DeleteSSHKey(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/account/keys/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) ListFirewalls() ([]firewall, error) {
+	firewalls := make([]firewall, 0)
+	err := c.list("/firewalls", nil, "firewalls", &firewalls)
+	return firewalls, err
+}
+
+func (c *restClient) CreateFirewall(fw firewall) (*firewall, error) {
+	out := &struct {
+		Firewall firewall `json:"firewall"`
+	}{}
+	if err := c.do(http.MethodPost, "/firewalls", nil, fw, out); err != nil {
+		return nil, err
+	}
+	return &out.Firewall, nil
+}
+
+func (c *restClient) UpdateFirewall(fw firewall) error {
+	return c.do(http.MethodPut, "/firewalls/"+fw.ID, nil, fw, nil)
+}
+
+func (c *restClient) DeleteFirewall(id string) error {
+	return c.do(http.MethodDelete, "/firewalls/"+id, nil, nil, nil)
+}
+
+func (c *restClient) GetVPC(id string) (*vpc, error) {
+	out := &struct {
+		VPC vpc `json:"vpc"`
+	}{}
+	if err := c.do(http.MethodGet, "/vpcs/"+id, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return &out.VPC, nil
+}
+
+// list requests all pages of the resources, items of the key in each page are appended to items which is a pointer of slice.
+func (c *restClient) list(path string, query url.Values, key string, items interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "200")
+	v := reflect.ValueOf(items).Elem()
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var b json.RawMessage
+		if err := c.do(http.MethodGet, path, query, nil, &b); err != nil {
+			return err
+		}
+		out := map[string]json.RawMessage{}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal(out[key], p.Interface()); err != nil {
+			return err
+		}
+		v.Set(reflect.AppendSlice(v, p.Elem()))
+		links := &pagination{}
+		if err := json.Unmarshal(b, links); err != nil {
+			return err
+		}
+		if links.Links.Pages.Next == "" {
+			return nil
+		}
+	}
+}
+
+// responseError is the error responded by api.
+type responseError struct {
+	status  int
+	message string
+}
+
+func (e *responseError) Error() string {
+	return e.message
+}
+
+func (c *restClient) do(method, path string, query url.Values, in, out interface{}) error {
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &apiError{}
+		if err := json.Unmarshal(b, e); err == nil && e.ID != "" {
+			return &responseError{status: resp.StatusCode, message: fmt.Sprintf("%s %s: %s (%s)", method, path, e.Message, e.ID)}
+		}
+		return &responseError{status: resp.StatusCode, message: fmt.Sprintf("%s %s: %s", method, path, resp.Status)}
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,436 @@
+package digitalocean
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typesdigitalocean "github.com/cnrancher/autok3s/pkg/types/digitalocean"
+	"github.com/cnrancher/autok3s/pkg/utils"
+	"github.com/cnrancher/autok3s/pkg/viper"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/sync/syncmap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	providerName = "digitalocean"
+
+	defaultUser            = "root"
+	defaultRegion          = "nyc1"
+	k3sVersion             = ""
+	k3sChannel             = "stable"
+	k3sInstallScript       = "https://get.k3s.io"
+	size                   = "s-2vcpu-4gb"
+	image                  = "ubuntu-20-04-x64"
+	master                 = "0"
+	worker                 = "0"
+	ui                     = false
+	cloudControllerManager = false
+	dockerScript           = "curl -sSL https://get.docker.com | sh - %s"
+	masterTag              = "autok3s:master"
+	workerTag              = "autok3s:worker"
+)
+
+type DigitalOcean struct {
+	types.Metadata            `json:",inline"`
+	typesdigitalocean.Options `json:",inline"`
+	types.Status              `json:"status"`
+	putil.Cloud               `json:"-"`
+
+	client doClient
+	// keyID is id of the ssh key which droplets are created with.
+	keyID int
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *DigitalOcean {
+	p := &DigitalOcean{
+		Metadata: types.Metadata{
+			Provider:               providerName,
+			Master:                 master,
+			Worker:                 worker,
+			UI:                     ui,
+			CloudControllerManager: cloudControllerManager,
+			K3sVersion:             k3sVersion,
+			K3sChannel:             k3sChannel,
+			InstallScript:          k3sInstallScript,
+			Cluster:                false,
+			DockerScript:           dockerScript,
+		},
+		Options: typesdigitalocean.Options{
+			Region: defaultRegion,
+			Size:   size,
+			Image:  image,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+	}
+	p.Cloud = putil.Cloud{
+		Driver:        p,
+		Metadata:      &p.Metadata,
+		Options:       &p.Options,
+		Status:        &p.Status,
+		RunningStatus: typesdigitalocean.StatusActive,
+		StoppedStatus: typesdigitalocean.StatusOff,
+		Nodes:         new(syncmap.Map),
+	}
+	return p
+}
+
+func (p *DigitalOcean) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *DigitalOcean) GetClusterName() string {
+	return p.Name
+}
+
+func (p *DigitalOcean) GetRegion() string {
+	return p.Region
+}
+
+func (p *DigitalOcean) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.GetProviderName())
+}
+
+func (p *DigitalOcean) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	extraArgs := ""
+	// nodes communicate with each other through the private network, whose interface isn't the default route of droplets.
+	if len(master.InternalIPAddress) > 0 && master.InternalIPAddress[0] != master.ExternalIP() {
+		ip := master.InternalIPAddress[0]
+		extraArgs += fmt.Sprintf(" --node-ip %s --flannel-iface='$(ip -o -4 addr show to %s | cut -d\" \" -f2)'", ip, ip)
+	}
+	if cluster.CloudControllerManager {
+		extraArgs += fmt.Sprintf(" --kubelet-arg=cloud-provider=external --kubelet-arg=provider-id=digitalocean://%s", master.InstanceID)
+	}
+	return extraArgs
+}
+
+func (p *DigitalOcean) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *DigitalOcean) NewClient() error {
+	if p.AccessToken == "" {
+		p.AccessToken = viper.GetCredential(p.GetProviderName(), p.Credential, "access-token")
+	}
+	client, err := newDOClient(p.AccessToken)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to create digitalocean client: %v", p.GetProviderName(), err)
+	}
+	p.client = client
+	return nil
+}
+
+// PrepareResources uploads the ssh key which droplets are created with.
+func (p *DigitalOcean) PrepareResources(ssh *types.SSH) error {
+	keyID, err := p.createKeyPair(ssh)
+	if err != nil {
+		return err
+	}
+	p.keyID = keyID
+	return nil
+}
+
+// createKeyPair returns id of the ssh key injected to droplets, the public key is uploaded if it doesn't exist in the account.
+func (p *DigitalOcean) createKeyPair(ssh *types.SSH) (int, error) {
+	if ssh.SSHKeyPath == "" {
+		keyPath := common.GetDefaultSSHKeyPath(p.Name, p.GetProviderName())
+		if _, err := os.Stat(keyPath); err == nil {
+			ssh.SSHKeyPath = keyPath
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	publicKey, err := putil.CreateKeyPair(ssh, p.GetProviderName(), p.Name, "")
+	if err != nil {
+		return 0, fmt.Errorf("[%s] failed to read public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] failed to parse public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	key, err := p.client.GetSSHKey(gossh.FingerprintLegacyMD5(pub))
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling getSSHKey error, msg: %v", p.GetProviderName(), err)
+	}
+	if key != nil {
+		return key.ID, nil
+	}
+
+	p.Logger.Infof("[%s] uploading ssh key %s...\n", p.GetProviderName(), p.Name)
+	key, err = p.client.CreateSSHKey(p.Name, strings.TrimSpace(string(publicKey)))
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling createSSHKey error, msg: %v", p.GetProviderName(), err)
+	}
+	return key.ID, nil
+}
+
+// ConfigResources creates the firewall of cluster or reconciles its rules with the policy,
+// cluster ports are only opened to the vpc of droplets.
+// The firewall is applied to droplets by the cluster tag, which exists once droplets are created.
+func (p *DigitalOcean) ConfigResources() error {
+	vpcUUID := p.VpcUUID
+	if vpcUUID == "" {
+		droplets, err := p.client.ListDroplets(p.clusterTag())
+		if err != nil {
+			return fmt.Errorf("[%s] failed to get droplets for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		}
+		for _, d := range droplets {
+			if d.VpcUUID != "" {
+				vpcUUID = d.VpcUUID
+				break
+			}
+		}
+	}
+	clusterCIDR := ""
+	if vpcUUID != "" {
+		v, err := p.client.GetVPC(vpcUUID)
+		if err != nil {
+			return fmt.Errorf("[%s] calling getVPC error, msg: %v", p.GetProviderName(), err)
+		}
+		clusterCIDR = v.IPRange
+	}
+	policy, err := putil.FirewallPolicy(p.Metadata, clusterCIDR)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+
+	f, err := p.getFirewall()
+	if err != nil {
+		return err
+	}
+	fw := firewall{
+		Name:          p.Name,
+		InboundRules:  firewallRules(policy),
+		OutboundRules: outboundRules(),
+		Tags:          []string{p.clusterTag()},
+	}
+	if f == nil {
+		p.Logger.Infof("[%s] creating firewall %s...\n", p.GetProviderName(), p.Name)
+		if _, err := p.client.CreateFirewall(fw); err != nil {
+			return fmt.Errorf("[%s] calling createFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+		return nil
+	}
+	fw.ID = f.ID
+	if err := p.client.UpdateFirewall(fw); err != nil {
+		return fmt.Errorf("[%s] calling updateFirewall error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+// getFirewall returns the firewall created for cluster, which is named after cluster and applied to the cluster tag.
+func (p *DigitalOcean) getFirewall() (*firewall, error) {
+	firewalls, err := p.client.ListFirewalls()
+	if err != nil {
+		return nil, fmt.Errorf("[%s] calling listFirewalls error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, f := range firewalls {
+		if f.Name == p.Name && containsString(f.Tags, p.clusterTag()) {
+			return &f, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *DigitalOcean) RunInstance(master bool) (*putil.Instance, error) {
+	role := "worker"
+	tag := workerTag
+	if master {
+		role = "master"
+		tag = masterTag
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s%s-%s-%s", common.TagClusterPrefix, strings.Split(p.Name, ".")[0], role, suffix)
+
+	p.Logger.Infof("[%s] creating droplet %s...\n", p.GetProviderName(), name)
+	d, err := p.client.CreateDroplet(dropletCreateOpts{
+		Name:    name,
+		Region:  p.Region,
+		Size:    p.Size,
+		Image:   p.Image,
+		SSHKeys: []int{p.keyID},
+		VpcUUID: p.VpcUUID,
+		Tags:    []string{"autok3s", p.clusterTag(), tag},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[%s] calling createDroplet error, msg: %v", p.GetProviderName(), err)
+	}
+	return &putil.Instance{ID: strconv.Itoa(d.ID), Master: master, Status: d.Status}, nil
+}
+
+func (p *DigitalOcean) ListInstances() ([]putil.Instance, error) {
+	if p.client == nil {
+		if err := p.NewClient(); err != nil {
+			return nil, err
+		}
+	}
+	droplets, err := p.client.ListDroplets(p.clusterTag())
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]putil.Instance, 0, len(droplets))
+	for _, d := range droplets {
+		instances = append(instances, putil.Instance{
+			ID:        strconv.Itoa(d.ID),
+			Master:    containsString(d.Tags, masterTag),
+			Status:    d.Status,
+			PublicIP:  d.PublicIP,
+			PrivateIP: d.PrivateIP,
+		})
+	}
+	return instances, nil
+}
+
+func (p *DigitalOcean) StartInstances(ids []string) error {
+	for _, id := range ids {
+		dropletID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.PowerOnDroplet(dropletID); err != nil {
+			return fmt.Errorf("[%s] calling powerOnDroplet error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *DigitalOcean) StopInstances(ids []string) error {
+	for _, id := range ids {
+		dropletID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.ShutdownDroplet(dropletID); err != nil {
+			return fmt.Errorf("[%s] calling shutdownDroplet error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveInstances deletes droplets and waits for them to be deleted.
+func (p *DigitalOcean) RemoveInstances(ids []string) error {
+	for _, id := range ids {
+		dropletID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.DeleteDroplet(dropletID); err != nil {
+			return fmt.Errorf("[%s] calling deleteDroplet error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	wait.ErrWaitTimeout = fmt.Errorf("[%s] calling deleteDroplet error. dropletID: %s, message: not deleted", p.GetProviderName(), ids)
+	return wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+		droplets, err := p.client.ListDroplets(p.clusterTag())
+		if err != nil {
+			return false, err
+		}
+		for _, d := range droplets {
+			if containsString(ids, strconv.Itoa(d.ID)) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// RemoveResources deletes the firewall and ssh key created for cluster.
+func (p *DigitalOcean) RemoveResources() error {
+	f, err := p.getFirewall()
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		p.Logger.Infof("[%s] remove firewall %s\n", p.GetProviderName(), f.Name)
+		if err := p.client.DeleteFirewall(f.ID); err != nil {
+			return fmt.Errorf("[%s] calling deleteFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	keys, err := p.client.ListSSHKeys()
+	if err != nil {
+		return fmt.Errorf("[%s] calling listSSHKeys error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, k := range keys {
+		// keys of account aren't tagged, the key uploaded for cluster is named after cluster.
+		if k.Name != p.Name {
+			continue
+		}
+		p.Logger.Infof("[%s] remove ssh key %s\n", p.GetProviderName(), k.Name)
+		if err := p.client.DeleteSSHKey(k.ID); err != nil {
+			return fmt.Errorf("[%s] calling deleteSSHKey error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *DigitalOcean) CloudControllerManifest() (string, error) {
+	return fmt.Sprintf(digitaloceanCCMTmpl, base64.StdEncoding.EncodeToString([]byte(p.AccessToken))), nil
+}
+
+// clusterTag returns the tag of droplets in cluster, dots in cluster name aren't allowed in tags.
+func (p *DigitalOcean) clusterTag() string {
+	return common.TagClusterPrefix + strings.Replace(p.Name, ".", "-", -1)
+}
+
+// firewallRules translates the policy to inbound rules of firewall, rules of the same port are merged.
+func firewallRules(policy []putil.FirewallRule) []firewallRule {
+	rules := make([]firewallRule, 0)
+	index := map[string]int{}
+	for _, r := range policy {
+		key := fmt.Sprintf("%s/%s", strings.ToLower(r.Protocol), r.Port())
+		if i, ok := index[key]; ok {
+			if !containsString(rules[i].Sources.Addresses, r.CIDR) {
+				rules[i].Sources.Addresses = append(rules[i].Sources.Addresses, r.CIDR)
+			}
+			continue
+		}
+		index[key] = len(rules)
+		rules = append(rules, firewallRule{
+			Protocol: strings.ToLower(r.Protocol),
+			Ports:    r.Port(),
+			Sources:  &firewallTargets{Addresses: []string{r.CIDR}},
+		})
+	}
+	return rules
+}
+
+// outboundRules allows all outbound traffic, which is denied by firewalls of digitalocean without rules.
+func outboundRules() []firewallRule {
+	all := &firewallTargets{Addresses: []string{"0.0.0.0/0", "::/0"}}
+	return []firewallRule{
+		{Protocol: "tcp", Ports: "0", Destinations: all},
+		{Protocol: "udp", Ports: "0", Destinations: all},
+		{Protocol: "icmp", Destinations: all},
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
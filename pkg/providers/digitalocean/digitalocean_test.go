@@ -0,0 +1,213 @@
+package digitalocean
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDigitalOcean(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DigitalOcean Provider Suite")
+}
+
+// newTestDigitalOcean returns the provider of cluster "fake" with the numbers of master and worker.
+func newTestDigitalOcean(master, worker string) *DigitalOcean {
+	p := newProvider()
+	p.Name = "fake"
+	p.Master = master
+	p.Worker = worker
+	p.AccessToken = "token"
+	p.Logger = fake.Logger()
+	return p
+}
+
+// loadTestDigitalOcean returns the provider with options merged from cluster state, as commands other than create do.
+func loadTestDigitalOcean(master, worker string) *DigitalOcean {
+	p := newTestDigitalOcean(master, worker)
+	Expect(p.MergeClusterOptions()).To(Succeed())
+	p.GenerateClusterName()
+	return p
+}
+
+var _ = Describe("DigitalOcean provider with fake api", func() {
+	var api *fakeDO
+	suite := fake.NewSuite(types.SSH{User: defaultUser, Port: "22"}, func(s *fake.Suite) {
+		api = newFakeDO()
+		s.Replace(&newDOClient, func(token string) (doClient, error) {
+			return api, nil
+		})
+	})
+
+	It("creates the tagged droplets with the uploaded key", func() {
+		p := newTestDigitalOcean("1", "1")
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		master := c.MasterNodes[0]
+		Expect(master.InstanceStatus).To(Equal("active"))
+		Expect(master.PublicIPAddress[0]).To(HavePrefix("203.0.113."))
+		Expect(master.InternalIPAddress[0]).To(HavePrefix("10.10.0."))
+		Expect(p.GenerateMasterExtraArgs(c, master)).To(ContainSubstring("--node-ip " + master.InternalIPAddress[0]))
+
+		Expect(p.clusterTag()).To(Equal("autok3s-fake-nyc1-digitalocean"))
+		Expect(api.keys).To(HaveLen(1))
+		for _, d := range api.droplets {
+			Expect(d.opts.SSHKeys).To(ConsistOf(api.keys[0].ID))
+			Expect(d.Tags).To(ContainElement(p.clusterTag()))
+		}
+		Expect(api.droplets[0].Tags).To(ContainElement(masterTag))
+		Expect(api.droplets[1].Tags).To(ContainElement(workerTag))
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	It("applies the firewall to the cluster tag with the ports of cluster opened to the admin cidrs and vpc", func() {
+		p := newTestDigitalOcean("1", "0")
+		p.AdminCIDRs = "192.0.2.0/24"
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(api.firewalls).To(HaveLen(1))
+		Expect(api.firewalls[0].Tags).To(ConsistOf(p.clusterTag()))
+		Expect(api.firewalls[0].InboundRules).To(ConsistOf(
+			firewallRule{Protocol: "tcp", Ports: "22", Sources: &firewallTargets{Addresses: []string{"192.0.2.0/24"}}},
+			firewallRule{Protocol: "tcp", Ports: "6443", Sources: &firewallTargets{Addresses: []string{"192.0.2.0/24", fakeVPCIPRange}}},
+			firewallRule{Protocol: "tcp", Ports: "10250", Sources: &firewallTargets{Addresses: []string{fakeVPCIPRange}}},
+			firewallRule{Protocol: "udp", Ports: "8472", Sources: &firewallTargets{Addresses: []string{fakeVPCIPRange}}},
+		))
+		Expect(api.firewalls[0].OutboundRules).To(ConsistOf(outboundRules()))
+	})
+
+	Context("with the k3s cluster created", func() {
+		var (
+			server *fake.SSHServer
+			p      *DigitalOcean
+		)
+
+		BeforeEach(func() {
+			server = suite.SSHServer()
+			p = newTestDigitalOcean("1", "1")
+			p.Token = "fake-token"
+			p.UI = true
+			p.CloudControllerManager = true
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("deploys the cloud controller manager with the access token", func() {
+			master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+			Expect(master.Commands()).To(ContainElement(And(
+				ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("--disable-cloud-controller"),
+				ContainSubstring("--kubelet-arg=provider-id=digitalocean://"))))
+
+			file := filepath.Join(common.K3sManifestsDir, "cloud-controller-manager.yaml")
+			ccm, ok := master.ReadFile(file)
+			Expect(ok).To(BeTrue())
+			Expect(string(ccm)).To(ContainSubstring(base64.StdEncoding.EncodeToString([]byte("token"))))
+			// the manifest with credentials is uploaded as a file only readable by root, instead of passed in commands.
+			Expect(master.FileMode(file)).To(Equal(os.FileMode(0600)))
+			Expect(master.Commands()).NotTo(ContainElement(ContainSubstring(base64.StdEncoding.EncodeToString(ccm))))
+			ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+			Expect(ok).To(BeTrue())
+			Expect(ui).NotTo(BeEmpty())
+		})
+
+		It("joins a worker to the cluster in state", func() {
+			masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+			j := loadTestDigitalOcean("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).To(Succeed())
+			Expect(j.Status.WorkerNodes).To(HaveLen(2))
+			for _, n := range j.Status.WorkerNodes {
+				Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+					ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"),
+					ContainSubstring("--kubelet-arg=provider-id=digitalocean://"))))
+			}
+			Expect(api.keys).To(HaveLen(1))
+			Expect(api.firewalls).To(HaveLen(1))
+
+			kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("2"))
+		})
+
+		It("removes the worker failed to join by rollback and keeps the resources of cluster", func() {
+			server.Handle(fake.FailJoin)
+			j := loadTestDigitalOcean("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			Expect(api.droplets).To(HaveLen(3))
+
+			Expect(j.Rollback()).To(Succeed())
+			exist, ids, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeTrue())
+			Expect(ids).To(ConsistOf(p.Status.MasterNodes[0].InstanceID, p.Status.WorkerNodes[0].InstanceID))
+			Expect(api.keys).To(HaveLen(1))
+			Expect(api.firewalls).To(HaveLen(1))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status.Status).To(Equal(common.StatusRunning))
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+		})
+
+		It("deletes the droplets and the resources of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(api.droplets).To(BeEmpty())
+			Expect(api.keys).To(BeEmpty())
+			Expect(api.firewalls).To(BeEmpty())
+			_, err := os.Stat(common.GetClusterPath(p.Name, providerName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("keeps the ssh key and firewall which aren't created for cluster", func() {
+		other := newTestDigitalOcean("1", "0")
+		other.Name = "other"
+		other.GenerateClusterName()
+		_, err := other.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the public key of other cluster is reused.
+		p := newTestDigitalOcean("1", "0")
+		p.GenerateClusterName()
+		_, err = p.GenerateInstance(func() error { return nil }, &types.SSH{User: defaultUser, Port: "22", SSHKeyPath: suite.SSH.SSHKeyPath})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.keys).To(HaveLen(1))
+		Expect(api.firewalls).To(HaveLen(2))
+
+		Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+		Expect(api.droplets).To(HaveLen(1))
+		Expect(api.keys).To(HaveLen(1))
+		Expect(api.firewalls).To(HaveLen(1))
+		Expect(api.firewalls[0].Name).To(Equal(other.Name))
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTestDigitalOcean("0", "1")
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(p.JoinCheck, suite.SSH)
+		Expect(err).To(HaveOccurred())
+		Expect(api.droplets).To(BeEmpty())
+	})
+})
@@ -0,0 +1,212 @@
+package digitalocean
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/digitalocean"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	fakeVPCUUID    = "fake-vpc"
+	fakeVPCIPRange = "10.10.0.0/16"
+)
+
+// fakeDO is an in-memory DigitalOcean account, droplets are active with addresses assigned once created.
+type fakeDO struct {
+	mu sync.Mutex
+
+	seq       int
+	droplets  []*fakeDroplet
+	keys      []*sshKey
+	firewalls []*firewall
+}
+
+type fakeDroplet struct {
+	droplet
+	opts dropletCreateOpts
+}
+
+func newFakeDO() *fakeDO {
+	return &fakeDO{}
+}
+
+func (f *fakeDO) nextID() int {
+	f.seq++
+	return f.seq
+}
+
+func (f *fakeDO) CreateDroplet(opts dropletCreateOpts) (*droplet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID()
+	vpcUUID := opts.VpcUUID
+	if vpcUUID == "" {
+		vpcUUID = fakeVPCUUID
+	}
+	d := &fakeDroplet{
+		droplet: droplet{
+			ID:        id,
+			Name:      opts.Name,
+			Status:    digitalocean.StatusActive,
+			Tags:      opts.Tags,
+			VpcUUID:   vpcUUID,
+			PublicIP:  fmt.Sprintf("203.0.113.%d", id),
+			PrivateIP: fmt.Sprintf("10.10.0.%d", id),
+		},
+		opts: opts,
+	}
+	f.droplets = append(f.droplets, d)
+	v := d.droplet
+	return &v, nil
+}
+
+func (f *fakeDO) ListDroplets(tag string) ([]droplet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	droplets := make([]droplet, 0)
+	for _, d := range f.droplets {
+		if containsString(d.Tags, tag) {
+			droplets = append(droplets, d.droplet)
+		}
+	}
+	return droplets, nil
+}
+
+func (f *fakeDO) PowerOnDroplet(id int) error {
+	return f.setStatus(id, digitalocean.StatusActive)
+}
+
+func (f *fakeDO) ShutdownDroplet(id int) error {
+	return f.setStatus(id, digitalocean.StatusOff)
+}
+
+func (f *fakeDO) setStatus(id int, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range f.droplets {
+		if d.ID == id {
+			d.Status = status
+			return nil
+		}
+	}
+	return fmt.Errorf("droplet not found: %d", id)
+}
+
+func (f *fakeDO) DeleteDroplet(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, d := range f.droplets {
+		if d.ID == id {
+			f.droplets = append(f.droplets[:i], f.droplets[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("droplet not found: %d", id)
+}
+
+func (f *fakeDO) GetSSHKey(fingerprint string) (*sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, k := range f.keys {
+		if k.Fingerprint == fingerprint {
+			v := *k
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeDO) ListSSHKeys() ([]sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]sshKey, 0, len(f.keys))
+	for _, k := range f.keys {
+		keys = append(keys, *k)
+	}
+	return keys, nil
+}
+
+func (f *fakeDO) CreateSSHKey(name, publicKey string) (*sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	fingerprint := gossh.FingerprintLegacyMD5(pub)
+	for _, k := range f.keys {
+		if k.Fingerprint == fingerprint {
+			return nil, fmt.Errorf("ssh key is already in use on your account: %s", fingerprint)
+		}
+	}
+	k := &sshKey{ID: f.nextID(), Name: name, Fingerprint: fingerprint, PublicKey: publicKey}
+	f.keys = append(f.keys, k)
+	v := *k
+	return &v, nil
+}
+
+func (f *fakeDO) DeleteSSHKey(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, k := range f.keys {
+		if k.ID == id {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("ssh key not found: %d", id)
+}
+
+func (f *fakeDO) ListFirewalls() ([]firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firewalls := make([]firewall, 0, len(f.firewalls))
+	for _, fw := range f.firewalls {
+		firewalls = append(firewalls, *fw)
+	}
+	return firewalls, nil
+}
+
+func (f *fakeDO) CreateFirewall(fw firewall) (*firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fw.ID = "fw-" + strconv.Itoa(f.nextID())
+	f.firewalls = append(f.firewalls, &fw)
+	v := fw
+	return &v, nil
+}
+
+func (f *fakeDO) UpdateFirewall(fw firewall) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, v := range f.firewalls {
+		if v.ID == fw.ID {
+			f.firewalls[i] = &fw
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %s", fw.ID)
+}
+
+func (f *fakeDO) DeleteFirewall(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, fw := range f.firewalls {
+		if fw.ID == id {
+			f.firewalls = append(f.firewalls[:i], f.firewalls[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %s", id)
+}
+
+func (f *fakeDO) GetVPC(id string) (*vpc, error) {
+	if id != fakeVPCUUID {
+		return nil, fmt.Errorf("vpc not found: %s", id)
+	}
+	return &vpc{ID: id, Name: "default-nyc1", Region: defaultRegion, IPRange: fakeVPCIPRange}, nil
+}
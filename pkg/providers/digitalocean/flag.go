@@ -0,0 +1,425 @@
+package digitalocean
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cnrancher/autok3s/pkg/types/digitalocean"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const createUsageExample = `  autok3s -d create \
+    --provider digitalocean \
+    --name <cluster name> \
+    --master 1
+`
+
+const joinUsageExample = `  autok3s -d join \
+    --provider digitalocean \
+    --name <cluster name> \
+    --worker 1
+`
+
+const deleteUsageExample = `  autok3s -d delete \
+    --provider digitalocean \
+    --name <cluster name>
+`
+
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider digitalocean \
+    --name <cluster name> \
+    --node <droplet id or ip>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider digitalocean \
+    --name <cluster name> \
+    --k3s-version <k3s version>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider digitalocean \
+    --name <cluster name> \
+    --snapshot-name <snapshot name>
+  autok3s snapshot list \
+    --provider digitalocean \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider digitalocean \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider digitalocean \
+    --name <cluster name> \
+    --repair
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider digitalocean \
+    --name <cluster name>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider digitalocean \
+    --name <cluster name>
+`
+
+const sshUsageExample = `  autok3s ssh \
+    --provider digitalocean \
+    --name <cluster name>
+`
+
+func (p *DigitalOcean) GetUsageExample(action string) string {
+	switch action {
+	case "create":
+		return createUsageExample
+	case "join":
+		return joinUsageExample
+	case "delete":
+		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
+	case "ssh":
+		return sshUsageExample
+	default:
+		return ""
+	}
+}
+
+func (p *DigitalOcean) GetOptionFlags() []types.Flag {
+	fs := p.sharedFlags()
+	fs = append(fs, []types.Flag{
+		{
+			Name:  "ui",
+			P:     &p.UI,
+			V:     p.UI,
+			Usage: "Enable K3s UI.",
+		},
+		{
+			Name:  "cluster",
+			P:     &p.Cluster,
+			V:     p.Cluster,
+			Usage: "Form k3s cluster using embedded etcd (requires K8s >= 1.19)",
+		},
+	}...)
+	return fs
+}
+
+func (p *DigitalOcean) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "DigitalOcean region of droplets, e.g.(nyc1, sfo3, fra1)",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *DigitalOcean) GetJoinFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := p.sharedFlags()
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *DigitalOcean) GetSSHFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "DigitalOcean region of droplets, e.g.(nyc1, sfo3, fra1)",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *DigitalOcean) GetCredentialFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:     "access-token",
+			P:        &p.AccessToken,
+			V:        p.AccessToken,
+			Usage:    "DigitalOcean personal access token",
+			Required: true,
+			EnvVar:   "DIGITALOCEAN_ACCESS_TOKEN",
+		},
+	}
+
+	return fs
+}
+
+func (p *DigitalOcean) GetSSHConfig() *types.SSH {
+	ssh := &types.SSH{
+		User: defaultUser,
+		Port: "22",
+	}
+	return ssh
+}
+
+func (p *DigitalOcean) BindCredentialFlags() *pflag.FlagSet {
+	nfs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	nfs.StringVar(&p.AccessToken, "access-token", p.AccessToken, "DigitalOcean personal access token")
+	return nfs
+}
+
+func (p *DigitalOcean) MergeClusterOptions() error {
+	clusters, err := cluster.ReadFromState(&types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+	})
+	if err != nil {
+		return err
+	}
+
+	var matched *types.Cluster
+	for _, c := range clusters {
+		if c.Provider == p.Provider && c.Name == fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.Provider) {
+			matched = &c
+		}
+	}
+
+	if matched != nil {
+		p.overwriteMetadata(matched)
+		// delete command need merge status value.
+		source := reflect.ValueOf(&p.Options).Elem()
+		b, err := json.Marshal(matched.Options)
+		if err != nil {
+			return err
+		}
+		opt := &digitalocean.Options{}
+		err = json.Unmarshal(b, opt)
+		if err != nil {
+			return err
+		}
+		target := reflect.ValueOf(opt).Elem()
+		utils.MergeConfig(source, target)
+	}
+
+	return nil
+}
+
+func (p *DigitalOcean) overwriteMetadata(matched *types.Cluster) {
+	// doesn't need to be overwrite.
+	p.Status = matched.Status
+	p.Token = matched.Token
+	p.IP = matched.IP
+	p.UI = matched.UI
+	p.CloudControllerManager = matched.CloudControllerManager
+	p.Cluster = matched.Cluster
+	p.ClusterCIDR = matched.ClusterCIDR
+	p.DataStore = matched.DataStore
+	p.Mirror = matched.Mirror
+	p.DockerMirror = matched.DockerMirror
+	p.InstallScript = matched.InstallScript
+	p.Network = matched.Network
+	// needed to be overwrite.
+	if p.Credential == "" {
+		p.Credential = matched.Credential
+	}
+	if p.K3sChannel == "" {
+		p.K3sChannel = matched.K3sChannel
+	}
+	if p.K3sVersion == "" {
+		p.K3sVersion = matched.K3sVersion
+	}
+	if p.InstallScript == "" {
+		p.InstallScript = matched.InstallScript
+	}
+	if p.Registry == "" {
+		p.Registry = matched.Registry
+	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.MasterExtraArgs == "" {
+		p.MasterExtraArgs = matched.MasterExtraArgs
+	}
+	if p.WorkerExtraArgs == "" {
+		p.WorkerExtraArgs = matched.WorkerExtraArgs
+	}
+}
+
+func (p *DigitalOcean) sharedFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "DigitalOcean region of droplets, e.g.(nyc1, sfo3, fra1)",
+		},
+		{
+			Name:  "size",
+			P:     &p.Size,
+			V:     p.Size,
+			Usage: "Size slug of droplets, e.g.(s-2vcpu-4gb, c-4)",
+		},
+		{
+			Name:  "image",
+			P:     &p.Image,
+			V:     p.Image,
+			Usage: "Slug of the system image of droplets, e.g.(ubuntu-20-04-x64)",
+		},
+		{
+			Name:  "vpc-uuid",
+			P:     &p.VpcUUID,
+			V:     p.VpcUUID,
+			Usage: "UUID of an existing vpc of droplets, the default vpc of region is used if not specified",
+		},
+		{
+			Name:  "ip",
+			P:     &p.IP,
+			V:     p.IP,
+			Usage: "IP of an existing k3s server",
+		},
+		{
+			Name:  "k3s-version",
+			P:     &p.K3sVersion,
+			V:     p.K3sVersion,
+			Usage: "Used to specify the version of k3s cluster, overrides k3s-channel",
+		},
+		{
+			Name:  "k3s-channel",
+			P:     &p.K3sChannel,
+			V:     p.K3sChannel,
+			Usage: "Used to specify the release channel of k3s. e.g.(stable, latest, or i.e. v1.18)",
+		},
+		{
+			Name:  "k3s-install-script",
+			P:     &p.InstallScript,
+			V:     p.InstallScript,
+			Usage: "Change the default upstream k3s install script address",
+		},
+		{
+			Name:  "cloud-controller-manager",
+			P:     &p.CloudControllerManager,
+			V:     p.CloudControllerManager,
+			Usage: "Enable cloud-controller-manager component",
+		},
+		{
+			Name:  "master-extra-args",
+			P:     &p.MasterExtraArgs,
+			V:     p.MasterExtraArgs,
+			Usage: "Master extra arguments for k3s installer, wrapped in quotes. e.g.(--master-extra-args '--no-deploy metrics-server')",
+		},
+		{
+			Name:  "worker-extra-args",
+			P:     &p.WorkerExtraArgs,
+			V:     p.WorkerExtraArgs,
+			Usage: "Worker extra arguments for k3s installer, wrapped in quotes. e.g.(--worker-extra-args '--node-taint key=value:NoExecute')",
+		},
+		{
+			Name:  "registry",
+			P:     &p.Registry,
+			V:     p.Registry,
+			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
+		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "admin-cidrs",
+			P:     &p.AdminCIDRs,
+			V:     p.AdminCIDRs,
+			Usage: "CIDRs allowed to access ssh, kube api-server and ui of the firewall created by autok3s, separated by comma, default is 0.0.0.0/0",
+		},
+		{
+			Name:  "firewall-rules",
+			P:     &p.FirewallRules,
+			V:     p.FirewallRules,
+			Usage: "Extra inbound rules of the firewall in the format of <protocol>:<port>[-<port>]:<cidr>, separated by comma. e.g.(--firewall-rules 'tcp:30000-32767:0.0.0.0/0')",
+		},
+		{
+			Name:  "datastore",
+			P:     &p.DataStore,
+			V:     p.DataStore,
+			Usage: "K3s datastore, HA mode `create/join` master node needed this flag",
+		},
+		{
+			Name:  "token",
+			P:     &p.Token,
+			V:     p.Token,
+			Usage: "K3s master token, if empty will automatically generated",
+		},
+		{
+			Name:  "master",
+			P:     &p.Master,
+			V:     p.Master,
+			Usage: "Number of master node",
+		},
+		{
+			Name:  "worker",
+			P:     &p.Worker,
+			V:     p.Worker,
+			Usage: "Number of worker node",
+		},
+	}
+
+	return fs
+}
@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerClient is the subset of docker engine api used by the provider, which can be replaced in tests.
+type dockerClient interface {
+	PullImage(image string) error
+	CreateNetwork(name string, labels map[string]string) error
+	RemoveNetwork(name string) error
+	CreateContainer(name string, config *containerConfig) (string, error)
+	StartContainer(id string) error
+	StopContainer(id string) error
+	RemoveContainer(id string) error
+	ListContainers(labels map[string]string) ([]container, error)
+	CopyToContainer(id string, files map[string][]byte) error
+	CopyFromContainer(id, file string) ([]byte, error)
+}
+
+var newDockerClient = func(host string) (dockerClient, error) {
+	return newEngineClient(host)
+}
+
+type containerConfig struct {
+	Image            string
+	Cmd              []string
+	Env              []string            `json:",omitempty"`
+	Labels           map[string]string   `json:",omitempty"`
+	Hostname         string              `json:",omitempty"`
+	ExposedPorts     map[string]struct{} `json:",omitempty"`
+	HostConfig       hostConfig
+	NetworkingConfig networkingConfig
+}
+
+type hostConfig struct {
+	Privileged    bool
+	Tmpfs         map[string]string        `json:",omitempty"`
+	PortBindings  map[string][]portBinding `json:",omitempty"`
+	NetworkMode   string                   `json:",omitempty"`
+	RestartPolicy restartPolicy
+}
+
+type portBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string
+}
+
+type restartPolicy struct {
+	Name string
+}
+
+type networkingConfig struct {
+	EndpointsConfig map[string]endpointSettings
+}
+
+type endpointSettings struct {
+	Aliases   []string `json:",omitempty"`
+	IPAddress string   `json:",omitempty"`
+}
+
+type container struct {
+	ID              string `json:"Id"`
+	Names           []string
+	State           string
+	Labels          map[string]string
+	NetworkSettings networkSettings
+}
+
+type networkSettings struct {
+	Networks map[string]endpointSettings
+}
+
+// engineClient talks to docker engine api through unix socket or tcp, the api version negotiated by daemon is used.
+type engineClient struct {
+	client *http.Client
+	base   string
+}
+
+func newEngineClient(host string) (*engineClient, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %s: %v", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		return &engineClient{
+			client: &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			}},
+			base: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &engineClient{client: &http.Client{}, base: "http://" + u.Host}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host %s, only unix and tcp schemes are supported", host)
+	}
+}
+
+func (e *engineClient) PullImage(image string) error {
+	resp, err := e.request(http.MethodGet, "/images/"+image+"/json", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	repo, tag := image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+	resp, err = e.request(http.MethodPost, "/images/create", url.Values{"fromImage": {repo}, "tag": {tag}}, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	// errors of pulling are reported in the progress stream.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, message.Error)
+		}
+	}
+}
+
+func (e *engineClient) CreateNetwork(name string, labels map[string]string) error {
+	body := map[string]interface{}{
+		"Name":           name,
+		"CheckDuplicate": true,
+		"Labels":         labels,
+	}
+	return e.call(http.MethodPost, "/networks/create", nil, body, nil, http.StatusConflict)
+}
+
+func (e *engineClient) RemoveNetwork(name string) error {
+	return e.call(http.MethodDelete, "/networks/"+name, nil, nil, nil, http.StatusNotFound)
+}
+
+func (e *engineClient) CreateContainer(name string, config *containerConfig) (string, error) {
+	created := struct {
+		ID string `json:"Id"`
+	}{}
+	if err := e.call(http.MethodPost, "/containers/create", url.Values{"name": {name}}, config, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (e *engineClient) StartContainer(id string) error {
+	return e.call(http.MethodPost, "/containers/"+id+"/start", nil, nil, nil, http.StatusNotModified)
+}
+
+func (e *engineClient) StopContainer(id string) error {
+	return e.call(http.MethodPost, "/containers/"+id+"/stop", nil, nil, nil, http.StatusNotModified)
+}
+
+func (e *engineClient) RemoveContainer(id string) error {
+	return e.call(http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}, "v": {"1"}}, nil, nil, http.StatusNotFound)
+}
+
+func (e *engineClient) ListContainers(labels map[string]string) ([]container, error) {
+	selectors := make([]string, 0, len(labels))
+	for k, v := range labels {
+		selectors = append(selectors, fmt.Sprintf("%s=%s", k, v))
+	}
+	filters, err := json.Marshal(map[string][]string{"label": selectors})
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]container, 0)
+	err = e.call(http.MethodGet, "/containers/json", url.Values{"all": {"1"}, "filters": {string(filters)}}, nil, &containers)
+	return containers, err
+}
+
+func (e *engineClient) CopyToContainer(id string, files map[string][]byte) error {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: strings.TrimPrefix(name, "/"),
+			Mode: 0600,
+			Size: int64(len(content)),
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	resp, err := e.request(http.MethodPut, "/containers/"+id+"/archive", url.Values{"path": {"/"}}, buf, "application/x-tar")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (e *engineClient) CopyFromContainer(id, file string) ([]byte, error) {
+	resp, err := e.request(http.MethodGet, "/containers/"+id+"/archive", url.Values{"path": {file}}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(resp.Body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("file %s is not found in container %s", file, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && header.Name == path.Base(file) {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// call sends the request with json body and decodes the json response, the accepted status codes are not treated as errors.
+func (e *engineClient) call(method, p string, query url.Values, in, out interface{}, accepted ...int) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	resp, err := e.request(method, p, query, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, code := range accepted {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func (e *engineClient) request(method, p string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	u := e.base + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to docker engine: %v", err)
+	}
+	return resp, nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	message := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(b, &message); err != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(b))
+	}
+	return fmt.Errorf("docker engine responds %d: %s", resp.StatusCode, message.Message)
+}
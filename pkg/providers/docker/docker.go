@@ -0,0 +1,914 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/types/docker"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/syncmap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	providerName = "docker"
+
+	defaultImage  = "rancher/k3s"
+	defaultRegion = "local" // containers always run on the local docker host.
+	k3sVersion    = ""
+	master        = "0"
+	worker        = "0"
+	apiServerPort = "6443/tcp"
+	kubeCfgFile   = "/etc/rancher/k3s/k3s.yaml"
+)
+
+type Docker struct {
+	types.Metadata `json:",inline"`
+	docker.Options `json:",inline"`
+	types.Status   `json:"status"`
+
+	client dockerClient
+	m      *sync.Map
+	logger *logrus.Logger
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *Docker {
+	return &Docker{
+		Metadata: types.Metadata{
+			Provider:   providerName,
+			Master:     master,
+			Worker:     worker,
+			K3sVersion: k3sVersion,
+			Cluster:    false,
+		},
+		Options: docker.Options{
+			Image: defaultImage,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+		m: new(syncmap.Map),
+	}
+}
+
+func (p *Docker) GetProviderName() string {
+	return p.Provider
+}
+
+func (p *Docker) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Docker) GetClusterName() string {
+	return p.Name
+}
+
+func (p *Docker) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, defaultRegion, p.GetProviderName())
+}
+
+func (p *Docker) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	return ""
+}
+
+func (p *Docker) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *Docker) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		if err != nil {
+			p.logger.Errorf("[%s] failed to create cluster: %v", p.GetProviderName(), err)
+			if c == nil {
+				c = &types.Cluster{
+					Metadata: p.Metadata,
+					Options:  p.Options,
+					Status:   p.Status,
+				}
+			}
+			c.Status.Status = common.StatusFailed
+			cluster.SaveClusterState(c, common.StatusFailed)
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusCreating)))
+		}
+		if err == nil && len(p.Status.MasterNodes) > 0 {
+			p.logger.Info(common.UsageInfoTitle)
+			p.logger.Infof(common.UsageContext, p.Name)
+			p.logger.Info(common.UsagePods)
+			cluster.SaveClusterState(c, common.StatusRunning)
+			// remove creating state file and save running state
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusCreating)))
+		}
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing create logic...\n", p.GetProviderName())
+
+	if p.Token == "" {
+		if p.Token, err = utils.RandomToken(16); err != nil {
+			return err
+		}
+	}
+	if p.APIPort == "" {
+		if p.APIPort, err = freePort(); err != nil {
+			return err
+		}
+	}
+	c.Metadata = p.Metadata
+	c.Options = p.Options
+	c.Status.Status = common.StatusCreating
+	err = cluster.SaveClusterState(c, common.StatusCreating)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	c, err = p.generateContainers(func() error {
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseInstalling)
+	if err = p.saveKubeConfig(); err != nil {
+		return err
+	}
+	c.Status.Status = common.StatusRunning
+	if err = cluster.SaveState(c); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Docker) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
+	if p.m == nil {
+		p.m = new(syncmap.Map)
+	}
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		if err != nil {
+			if c != nil {
+				c.Status.Status = common.StatusFailed
+				cluster.SaveClusterState(c, common.StatusFailed)
+			}
+		} else {
+			cluster.SaveClusterState(c, common.StatusRunning)
+		}
+		// remove join state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusJoin)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing join logic...\n", p.GetProviderName())
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusJoin)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	c, err = p.generateContainers(p.joinCheck)
+	if err != nil {
+		return err
+	}
+	c.Status.Status = common.StatusRunning
+	if err = cluster.SaveState(c); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed join logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Docker) DeleteK3sCluster(ctx context.Context, f bool) error {
+	isConfirmed := true
+
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to delete cluster %s", p.GetProviderName(), p.Name))
+	}
+	if isConfirmed {
+		logFile, err := common.GetLogFile(p.Name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			logFile.Close()
+			// remove log file
+			os.Remove(filepath.Join(common.GetLogPath(), p.Name))
+			// remove state file
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRunning)))
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusFailed)))
+		}()
+		p.logger = common.NewLogger(common.Debug, logFile)
+		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
+		if err := p.newClient(); err != nil {
+			return err
+		}
+		if err := p.deleteCluster(f); err != nil {
+			return err
+		}
+		p.logger.Infof("[%s] successfully excuted delete cluster logic\n", p.GetProviderName())
+	}
+	return nil
+}
+
+func (p *Docker) RemoveK3sNode(node string, f bool) error {
+	return p.CommandNotSupport("remove-node")
+}
+
+func (p *Docker) UpgradeK3sCluster(ctx context.Context, version string) error {
+	return p.CommandNotSupport("upgrade")
+}
+
+func (p *Docker) SaveSnapshot(name string) (*types.Snapshot, error) {
+	return nil, p.CommandNotSupport("snapshot")
+}
+
+func (p *Docker) ListSnapshots() ([]types.Snapshot, error) {
+	return nil, p.CommandNotSupport("snapshot")
+}
+
+func (p *Docker) RestoreSnapshot(name string) error {
+	return p.CommandNotSupport("snapshot")
+}
+
+func (p *Docker) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	return nil, p.CommandNotSupport("check")
+}
+
+func (p *Docker) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// nodes are containers created from k3s image, there is nothing to check before creating them.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, containers don't exist until created", p.GetProviderName())
+}
+
+func (p *Docker) StartK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing start logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+
+	containers, err := p.client.ListContainers(p.clusterLabels())
+	if err != nil {
+		return fmt.Errorf("[%s] calling listContainers error, msg: %v", p.GetProviderName(), err)
+	}
+	// servers are started before agents.
+	for _, role := range []string{"master", "worker"} {
+		for _, c := range containers {
+			if c.Labels[role] == "true" && c.State != docker.StatusRunning {
+				p.logger.Infof("[%s] starting container %s...\n", p.GetProviderName(), containerName(c))
+				if err := p.client.StartContainer(c.ID); err != nil {
+					return fmt.Errorf("[%s] calling startContainer error, msg: %v", p.GetProviderName(), err)
+				}
+			}
+		}
+	}
+	if err := p.syncNodeStatus(); err != nil {
+		return err
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed start logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Docker) StopK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing stop logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+
+	containers, err := p.client.ListContainers(p.clusterLabels())
+	if err != nil {
+		return fmt.Errorf("[%s] calling listContainers error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, c := range containers {
+		if c.State == docker.StatusRunning {
+			p.logger.Infof("[%s] stopping container %s...\n", p.GetProviderName(), containerName(c))
+			if err := p.client.StopContainer(c.ID); err != nil {
+				return fmt.Errorf("[%s] calling stopContainer error, msg: %v", p.GetProviderName(), err)
+			}
+		}
+	}
+	if err := p.syncNodeStatus(); err != nil {
+		return err
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed stop logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Docker) SSHK3sNode(ssh *types.SSH, node string) error {
+	return p.CommandNotSupport("ssh")
+}
+
+func (p *Docker) CommandNotSupport(commandName string) error {
+	return fmt.Errorf("[%s] dose not support command: [%s]", p.GetProviderName(), commandName)
+}
+
+func (p *Docker) IsClusterExist() (bool, []string, error) {
+	ids := make([]string, 0)
+
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return false, ids, err
+		}
+	}
+
+	containers, err := p.client.ListContainers(p.clusterLabels())
+	if err != nil {
+		return false, ids, err
+	}
+	for _, c := range containers {
+		ids = append(ids, containerName(c))
+	}
+
+	return len(ids) > 0, ids, nil
+}
+
+func (p *Docker) Rollback() error {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing rollback logic...\n", p.GetProviderName())
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		if v.RollBack {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+
+	p.logger.Debugf("[%s] containers %s will be rollback\n", p.GetProviderName(), ids)
+
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		if err := p.client.RemoveContainer(id); err != nil {
+			return err
+		}
+	}
+	// the network is only removed when no node of cluster is left.
+	if exist, _, err := p.IsClusterExist(); err != nil {
+		return err
+	} else if !exist {
+		if err := p.client.RemoveNetwork(p.networkName()); err != nil {
+			return err
+		}
+	}
+
+	p.logger.Infof("[%s] successfully executed rollback logic\n", p.GetProviderName())
+
+	return logFile.Close()
+}
+
+func (p *Docker) DescribeCluster(kubecfg string) *types.ClusterInfo {
+	c := p.GetCluster(kubecfg)
+	c.Name = strings.Split(p.Name, ".")[0]
+
+	instanceNodes := make([]types.ClusterNode, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, n := range nodes {
+			instanceNodes = append(instanceNodes, types.ClusterNode{
+				InstanceID:              n.InstanceID,
+				InstanceStatus:          n.InstanceStatus,
+				InternalIP:              n.InternalIPAddress,
+				ExternalIP:              n.PublicIPAddress,
+				Status:                  types.ClusterStatusUnknown,
+				ContainerRuntimeVersion: types.ClusterStatusUnknown,
+				Version:                 types.ClusterStatusUnknown,
+			})
+		}
+	}
+	c.Nodes = instanceNodes
+	if c.Status != types.ClusterStatusRunning {
+		return c
+	}
+
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		return c
+	}
+	nodes, err := cluster.DescribeClusterNodes(client, instanceNodes)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to list nodes of cluster %s: %v", p.GetProviderName(), p.Name, err)
+		return c
+	}
+	c.Nodes = nodes
+	return c
+}
+
+func (p *Docker) GetCluster(kubecfg string) *types.ClusterInfo {
+	p.logger = common.NewLogger(common.Debug, nil)
+	c := &types.ClusterInfo{
+		Name:     p.Name,
+		Region:   defaultRegion,
+		Provider: p.GetProviderName(),
+		Master:   strconv.Itoa(len(p.Status.MasterNodes)),
+		Worker:   strconv.Itoa(len(p.Status.WorkerNodes)),
+	}
+	// containers may be stopped or removed outside of autok3s, their status are synced from docker engine.
+	if err := p.syncNodeStatus(); err != nil {
+		p.logger.Errorf("[%s] failed to get containers for cluster %s: %v", p.GetProviderName(), p.Name, err)
+	}
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Status = types.ClusterStatusUnknown
+		c.Version = types.ClusterStatusUnknown
+		return c
+	}
+	c.Status = cluster.GetClusterStatus(client)
+	if c.Status == types.ClusterStatusRunning {
+		c.Version = cluster.GetClusterVersion(client)
+	} else {
+		c.Version = types.ClusterStatusUnknown
+	}
+	return c
+}
+
+func (p *Docker) GetClusterConfig() (map[string]schemas.Field, error) {
+	return utils.ConvertToFields(p.Metadata)
+}
+
+func (p *Docker) GetProviderOption() (map[string]schemas.Field, error) {
+	return utils.ConvertToFields(p.Options)
+}
+
+func (p *Docker) SetConfig(config []byte) error {
+	c := types.Cluster{}
+	err := json.Unmarshal(config, &c)
+	if err != nil {
+		return err
+	}
+	sourceMeta := reflect.ValueOf(&p.Metadata).Elem()
+	targetMeta := reflect.ValueOf(&c.Metadata).Elem()
+	utils.MergeConfig(sourceMeta, targetMeta)
+	sourceOption := reflect.ValueOf(&p.Options).Elem()
+	b, err := json.Marshal(c.Options)
+	if err != nil {
+		return err
+	}
+	opt := &docker.Options{}
+	err = json.Unmarshal(b, opt)
+	if err != nil {
+		return err
+	}
+	targetOption := reflect.ValueOf(opt).Elem()
+	utils.MergeConfig(sourceOption, targetOption)
+
+	return nil
+}
+
+func (p *Docker) CreateCheck(ssh *types.SSH) error {
+	masterNum, err := strconv.Atoi(p.Master)
+	if masterNum < 1 || err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` number must >= 1",
+			p.GetProviderName())
+	}
+	if masterNum > 1 && !p.Cluster && p.DataStore == "" {
+		return fmt.Errorf("[%s] calling preflight error: need to set `--cluster` or `--datastore` when `--master` number > 1",
+			p.GetProviderName())
+	}
+	if _, err := strconv.Atoi(p.Worker); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			p.GetProviderName())
+	}
+	if strings.Contains(p.MasterExtraArgs, "--datastore-endpoint") && p.DataStore != "" {
+		return fmt.Errorf("[%s] calling preflight error: `--masterExtraArgs='--datastore-endpoint'` is duplicated with `--datastore`",
+			p.GetProviderName())
+	}
+
+	exist, _, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` is already exist",
+			p.GetProviderName(), p.Name)
+	}
+	return nil
+}
+
+func (p *Docker) joinCheck() error {
+	masterNum, err := strconv.Atoi(p.Master)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` must be number",
+			p.GetProviderName())
+	}
+	workerNum, err := strconv.Atoi(p.Worker)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			p.GetProviderName())
+	}
+	if masterNum < 1 && workerNum < 1 {
+		return fmt.Errorf("[%s] calling preflight error: `--master` or `--worker` number must >= 1", p.GetProviderName())
+	}
+	if masterNum > 0 && !p.Cluster && p.DataStore == "" {
+		return fmt.Errorf("[%s] calling preflight error: masters can only be joined to the cluster with `--cluster` or `--datastore`",
+			p.GetProviderName())
+	}
+
+	exist, ids, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if !exist || len(p.Status.MasterNodes) == 0 {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist",
+			p.GetProviderName(), p.Name)
+	}
+
+	// remove nodes whose containers are removed from .state file.
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, n := range nodes {
+			if !containsString(ids, n.InstanceID) {
+				p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+			}
+		}
+	}
+	if len(p.Status.MasterNodes) == 0 {
+		return fmt.Errorf("[%s] calling preflight error: no master container of cluster `%s` is found",
+			p.GetProviderName(), p.Name)
+	}
+	return nil
+}
+
+func (p *Docker) newClient() error {
+	client, err := newDockerClient(p.DockerHost)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to connect to docker engine: %v", p.GetProviderName(), err)
+	}
+	p.client = client
+	return nil
+}
+
+func (p *Docker) generateContainers(fn func() error) (*types.Cluster, error) {
+	if err := p.newClient(); err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	masterNum, _ := strconv.Atoi(p.Master)
+	workerNum, _ := strconv.Atoi(p.Worker)
+
+	p.logger.Infof("[%s] %d masters and %d workers will be added\n", p.GetProviderName(), masterNum, workerNum)
+
+	image := p.image()
+	p.logger.Infof("[%s] pulling image %s...\n", p.GetProviderName(), image)
+	if err := p.client.PullImage(image); err != nil {
+		return nil, fmt.Errorf("[%s] calling pullImage error, msg: %v", p.GetProviderName(), err)
+	}
+	if err := p.client.CreateNetwork(p.networkName(), p.clusterLabels()); err != nil {
+		return nil, fmt.Errorf("[%s] calling createNetwork error, msg: %v", p.GetProviderName(), err)
+	}
+
+	files := map[string][]byte{}
+	if p.Registry != "" {
+		var err error
+		if files, err = cluster.RegistryFiles(p.Registry); err != nil {
+			return nil, err
+		}
+	}
+
+	// nodes are joined to the first server, which is created at first when creating cluster.
+	server := ""
+	if len(p.Status.MasterNodes) > 0 {
+		server = p.Status.MasterNodes[0].InstanceID
+	}
+	names := make([]string, 0, masterNum+workerNum)
+	for i := 0; i < masterNum+workerNum; i++ {
+		name, err := p.runContainer(i < masterNum, server, files)
+		if err != nil {
+			return nil, err
+		}
+		if server == "" {
+			server = name
+		}
+		names = append(names, name)
+	}
+
+	if err := p.syncNodeStatus(); err != nil {
+		return nil, err
+	}
+
+	// nodes are appended in the order of creation, so that the first server is always the first master node.
+	for _, name := range names {
+		value, _ := p.m.Load(name)
+		v := value.(types.Node)
+		if v.Master {
+			p.Status.MasterNodes = append(p.Status.MasterNodes, v)
+		} else {
+			p.Status.WorkerNodes = append(p.Status.WorkerNodes, v)
+		}
+	}
+
+	p.Master = strconv.Itoa(len(p.MasterNodes))
+	p.Worker = strconv.Itoa(len(p.WorkerNodes))
+
+	return &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}, nil
+}
+
+func (p *Docker) runContainer(master bool, server string, files map[string][]byte) (string, error) {
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s-%s", p.networkName(), role, suffix)
+	labels := p.clusterLabels()
+	labels[role] = "true"
+
+	config := &containerConfig{
+		Image:    p.image(),
+		Hostname: name,
+		Labels:   labels,
+		Env:      []string{"K3S_TOKEN=" + p.Token},
+		HostConfig: hostConfig{
+			Privileged:    true,
+			Tmpfs:         map[string]string{"/run": "", "/var/run": ""},
+			NetworkMode:   p.networkName(),
+			RestartPolicy: restartPolicy{Name: "unless-stopped"},
+		},
+		NetworkingConfig: networkingConfig{
+			EndpointsConfig: map[string]endpointSettings{
+				p.networkName(): {Aliases: []string{name}},
+			},
+		},
+	}
+	if master {
+		config.Cmd = p.serverArgs(server == "")
+		if server == "" {
+			// only api server of the first server is published to host, which is used in kubeconfig.
+			config.ExposedPorts = map[string]struct{}{apiServerPort: {}}
+			config.HostConfig.PortBindings = map[string][]portBinding{
+				apiServerPort: {{HostIP: "127.0.0.1", HostPort: p.APIPort}},
+			}
+		} else if p.DataStore == "" {
+			config.Env = append(config.Env, fmt.Sprintf("K3S_URL=https://%s:6443", server))
+		}
+	} else {
+		config.Cmd = append([]string{"agent"}, strings.Fields(p.WorkerExtraArgs)...)
+		config.Env = append(config.Env, fmt.Sprintf("K3S_URL=https://%s:6443", server))
+	}
+
+	p.logger.Infof("[%s] creating container %s...\n", p.GetProviderName(), name)
+	if _, err := p.client.CreateContainer(name, config); err != nil {
+		return "", fmt.Errorf("[%s] calling createContainer error, msg: %v", p.GetProviderName(), err)
+	}
+	p.m.Store(name, types.Node{
+		Master:         master,
+		RollBack:       true,
+		InstanceID:     name,
+		InstanceStatus: docker.StatusCreated,
+		Current:        true,
+	})
+	if len(files) > 0 {
+		if err := p.client.CopyToContainer(name, files); err != nil {
+			return "", fmt.Errorf("[%s] calling copyToContainer error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	if err := p.client.StartContainer(name); err != nil {
+		return "", fmt.Errorf("[%s] calling startContainer error, msg: %v", p.GetProviderName(), err)
+	}
+	return name, nil
+}
+
+func (p *Docker) serverArgs(first bool) []string {
+	args := []string{"server", "--tls-san", "127.0.0.1"}
+	if p.Cluster && first {
+		args = append(args, "--cluster-init")
+	}
+	if p.DataStore != "" {
+		args = append(args, "--datastore-endpoint", p.DataStore)
+	}
+	return append(args, strings.Fields(p.MasterExtraArgs)...)
+}
+
+// syncNodeStatus syncs status and ip address of nodes with containers.
+func (p *Docker) syncNodeStatus() error {
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return err
+		}
+	}
+	containers, err := p.client.ListContainers(p.clusterLabels())
+	if err != nil {
+		return fmt.Errorf("[%s] calling listContainers error, msg: %v", p.GetProviderName(), err)
+	}
+	update := func(n *types.Node) {
+		n.InstanceStatus = docker.StatusRemoved
+		for _, c := range containers {
+			if containerName(c) == n.InstanceID {
+				n.InstanceStatus = c.State
+				if ip := c.NetworkSettings.Networks[p.networkName()].IPAddress; ip != "" {
+					n.InternalIPAddress = []string{ip}
+				}
+				return
+			}
+		}
+	}
+	for i := range p.Status.MasterNodes {
+		update(&p.Status.MasterNodes[i])
+	}
+	for i := range p.Status.WorkerNodes {
+		update(&p.Status.WorkerNodes[i])
+	}
+	if p.m != nil {
+		p.m.Range(func(key, value interface{}) bool {
+			v := value.(types.Node)
+			update(&v)
+			p.m.Store(key, v)
+			return true
+		})
+	}
+	return nil
+}
+
+// saveKubeConfig merges kubeconfig of the first server to local kubeconfig, the api server is accessed through the published port.
+func (p *Docker) saveKubeConfig() error {
+	var cfg []byte
+	p.logger.Infof("[%s] waiting for k3s server %s to be ready...\n", p.GetProviderName(), p.Status.MasterNodes[0].InstanceID)
+	if err := wait.PollImmediate(2*time.Second, 2*time.Minute, func() (bool, error) {
+		b, err := p.client.CopyFromContainer(p.Status.MasterNodes[0].InstanceID, kubeCfgFile)
+		if err != nil {
+			p.logger.Debugf("[%s] kubeconfig is not ready: %v\n", p.GetProviderName(), err)
+			return false, nil
+		}
+		cfg = b
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("[%s] failed to get kubeconfig from container %s: %v", p.GetProviderName(), p.Status.MasterNodes[0].InstanceID, err)
+	}
+
+	content := strings.Replace(string(cfg), "127.0.0.1:6443", "127.0.0.1:"+p.APIPort, -1)
+	return cluster.SaveCfg(content, "127.0.0.1", p.Name)
+}
+
+func (p *Docker) deleteCluster(f bool) error {
+	exist, ids, err := p.IsClusterExist()
+	if err != nil && !f {
+		return fmt.Errorf("[%s] calling deleteCluster error, msg: %v", p.GetProviderName(), err)
+	}
+	if !exist {
+		p.logger.Errorf("[%s] cluster %s is not exist", p.GetProviderName(), p.Name)
+		if !f {
+			return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist", p.GetProviderName(), p.Name)
+		}
+		return nil
+	}
+
+	p.logger.Infof("[%s] remove containers %v", p.GetProviderName(), ids)
+	for _, id := range ids {
+		if err := p.client.RemoveContainer(id); err != nil {
+			return err
+		}
+	}
+	if err := p.client.RemoveNetwork(p.networkName()); err != nil {
+		return err
+	}
+
+	err = cluster.OverwriteCfg(p.Name)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .cfg file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	err = cluster.DeleteState(p.Name, p.Provider)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully deleted cluster %s\n", p.GetProviderName(), p.Name)
+	return nil
+}
+
+func (p *Docker) clusterLabels() map[string]string {
+	return map[string]string{
+		"autok3s": "true",
+		"cluster": common.TagClusterPrefix + p.Name,
+	}
+}
+
+// networkName returns the name of network shared by containers of cluster, which is also the prefix of container names.
+func (p *Docker) networkName() string {
+	return common.TagClusterPrefix + strings.Split(p.Name, ".")[0]
+}
+
+// image returns the k3s image whose tag is the k3s version, e.g. v1.19.5+k3s1 is tagged as v1.19.5-k3s1.
+func (p *Docker) image() string {
+	if strings.Contains(p.Image[strings.LastIndex(p.Image, "/")+1:], ":") {
+		return p.Image
+	}
+	if p.K3sVersion == "" {
+		return p.Image + ":latest"
+	}
+	return p.Image + ":" + strings.Replace(p.K3sVersion, "+", "-", -1)
+}
+
+func containerName(c container) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// freePort returns a free local port for api server of cluster.
+func freePort() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	return port, err
+}
@@ -0,0 +1,191 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestDocker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Docker Provider Suite")
+}
+
+// newTestDocker returns the provider of cluster "fake" with the numbers of master and worker.
+func newTestDocker(master, worker string) *Docker {
+	p := newProvider()
+	p.Name = "fake"
+	p.Master = master
+	p.Worker = worker
+	p.logger = fake.Logger()
+	return p
+}
+
+// loadTestDocker returns the provider with options merged from cluster state, as commands other than create do.
+func loadTestDocker(master, worker string) *Docker {
+	p := newTestDocker(master, worker)
+	Expect(p.MergeClusterOptions()).To(Succeed())
+	p.GenerateClusterName()
+	return p
+}
+
+var _ = Describe("Docker provider with fake docker engine", func() {
+	var engine *fakeDocker
+	suite := fake.NewSuite(types.SSH{}, func(s *fake.Suite) {
+		engine = newFakeDocker()
+		s.Replace(&newDockerClient, func(host string) (dockerClient, error) {
+			return engine, nil
+		})
+	})
+
+	names := func(role string) []string {
+		containers, err := engine.ListContainers(map[string]string{role: "true"})
+		Expect(err).NotTo(HaveOccurred())
+		result := make([]string, 0, len(containers))
+		for _, c := range containers {
+			result = append(result, containerName(c))
+		}
+		return result
+	}
+
+	It("creates the containers of cluster with the registry and api port", func() {
+		registry := filepath.Join(common.CfgPath, "registries.yaml")
+		Expect(ioutil.WriteFile(registry, []byte("mirrors:\n  docker.io:\n    endpoint:\n    - https://mirror.example.com\n"), 0600)).To(Succeed())
+
+		p := newTestDocker("1", "1")
+		p.GenerateClusterName()
+		p.K3sVersion = "v1.19.5+k3s1"
+		p.Registry = registry
+		p.APIPort = "16443"
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+		Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+
+		Expect(engine.images).To(HaveKey("rancher/k3s:v1.19.5-k3s1"))
+		Expect(engine.networks).To(HaveKey("autok3s-fake"))
+		Expect(p.MasterNodes).To(HaveLen(1))
+		Expect(p.WorkerNodes).To(HaveLen(1))
+		Expect(names("master")).To(ConsistOf(p.MasterNodes[0].InstanceID))
+		Expect(names("worker")).To(ConsistOf(p.WorkerNodes[0].InstanceID))
+		Expect(p.MasterNodes[0].InstanceStatus).To(Equal("running"))
+		Expect(p.MasterNodes[0].InternalIPAddress).NotTo(BeEmpty())
+
+		server := engine.find(p.MasterNodes[0].InstanceID)
+		Expect(server.config.HostConfig.PortBindings[apiServerPort]).To(ConsistOf(portBinding{HostIP: "127.0.0.1", HostPort: "16443"}))
+		Expect(string(server.files["/etc/rancher/k3s/registries.yaml"])).To(ContainSubstring("https://mirror.example.com"))
+		agent := engine.find(p.WorkerNodes[0].InstanceID)
+		Expect(agent.config.Env).To(ContainElement(fmt.Sprintf("K3S_URL=https://%s:6443", p.MasterNodes[0].InstanceID)))
+		Expect(agent.config.Env).To(ContainElement("K3S_TOKEN=" + p.Token))
+
+		cfg, err := clientcmd.LoadFromFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Contexts).To(HaveKey(p.Name))
+		Expect(cfg.Clusters[p.Name].Server).To(Equal("https://127.0.0.1:16443"))
+	})
+
+	Context("with the cluster created", func() {
+		var p *Docker
+
+		BeforeEach(func() {
+			p = newTestDocker("1", "1")
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("joins a worker to the server of cluster in state", func() {
+			j := loadTestDocker("0", "1")
+			Expect(j.Token).To(Equal(p.Token))
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).To(Succeed())
+			Expect(j.WorkerNodes).To(HaveLen(2))
+			Expect(names("worker")).To(HaveLen(2))
+			for _, n := range j.WorkerNodes {
+				Expect(engine.find(n.InstanceID).config.Env).To(ContainElement(fmt.Sprintf("K3S_URL=https://%s:6443", p.MasterNodes[0].InstanceID)))
+			}
+
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("2"))
+		})
+
+		It("removes the worker failed to join by rollback and keeps the cluster in state", func() {
+			engine.startErr = errors.New("failed to start container")
+			j := loadTestDocker("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			engine.startErr = nil
+			Expect(names("worker")).To(HaveLen(2))
+
+			Expect(j.Rollback()).To(Succeed())
+			Expect(names("worker")).To(ConsistOf(p.WorkerNodes[0].InstanceID))
+			Expect(engine.networks).To(HaveKey("autok3s-fake"))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+			Expect(state.WorkerNodes[0].InstanceID).To(Equal(p.WorkerNodes[0].InstanceID))
+		})
+
+		It("stops and starts the containers of cluster", func() {
+			server := engine.find(p.MasterNodes[0].InstanceID)
+			s := loadTestDocker("0", "0")
+			Expect(s.StopK3sCluster()).To(Succeed())
+			Expect(server.State).To(Equal("exited"))
+			Expect(s.StartK3sCluster()).To(Succeed())
+			Expect(server.State).To(Equal("running"))
+		})
+
+		It("deletes the containers, network and kubeconfig of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(engine.containers).To(BeEmpty())
+			Expect(engine.networks).To(BeEmpty())
+			exist, _, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeFalse())
+			cfg, err := clientcmd.LoadFromFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Contexts).NotTo(HaveKey(p.Name))
+		})
+	})
+
+	It("joins masters to the first server of cluster with embedded etcd", func() {
+		p := newTestDocker("3", "0")
+		p.GenerateClusterName()
+		p.Cluster = true
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+		Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		Expect(p.MasterNodes).To(HaveLen(3))
+
+		first := engine.find(p.MasterNodes[0].InstanceID)
+		Expect(first.config.Cmd).To(ContainElement("--cluster-init"))
+		Expect(first.config.HostConfig.PortBindings).To(HaveKey(apiServerPort))
+		for _, n := range p.MasterNodes[1:] {
+			c := engine.find(n.InstanceID)
+			Expect(c.config.Cmd).NotTo(ContainElement("--cluster-init"))
+			Expect(c.config.HostConfig.PortBindings).To(BeEmpty())
+			Expect(c.config.Env).To(ContainElement(fmt.Sprintf("K3S_URL=https://%s:6443", p.MasterNodes[0].InstanceID)))
+		}
+	})
+
+	It("fails to create the cluster with multiple masters on sqlite", func() {
+		p := newTestDocker("2", "0")
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := loadTestDocker("0", "1")
+		Expect(p.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+		Expect(engine.containers).To(BeEmpty())
+	})
+})
@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/docker"
+)
+
+const fakeKubeConfig = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: ZmFrZQ==
+    server: https://127.0.0.1:6443
+  name: default
+contexts:
+- context:
+    cluster: default
+    user: default
+  name: default
+current-context: default
+kind: Config
+preferences: {}
+users:
+- name: default
+  user:
+    password: fake
+    username: admin
+`
+
+// fakeDocker is an in-memory docker engine, containers are running after started and k3s servers have kubeconfig at once.
+type fakeDocker struct {
+	mu sync.Mutex
+
+	seq        int
+	images     map[string]bool
+	networks   map[string]map[string]string
+	containers []*fakeContainer
+	// startErr is returned by StartContainer if it's set.
+	startErr error
+}
+
+type fakeContainer struct {
+	container
+	config *containerConfig
+	files  map[string][]byte
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		images:   map[string]bool{},
+		networks: map[string]map[string]string{},
+	}
+}
+
+func (f *fakeDocker) PullImage(image string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[image] = true
+	return nil
+}
+
+func (f *fakeDocker) CreateNetwork(name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.networks[name]; !ok {
+		f.networks[name] = labels
+	}
+	return nil
+}
+
+func (f *fakeDocker) RemoveNetwork(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.containers {
+		if _, ok := c.NetworkSettings.Networks[name]; ok {
+			return fmt.Errorf("network %s has active endpoints", name)
+		}
+	}
+	delete(f.networks, name)
+	return nil
+}
+
+func (f *fakeDocker) CreateContainer(name string, config *containerConfig) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.images[config.Image] {
+		return "", fmt.Errorf("no such image: %s", config.Image)
+	}
+	if _, ok := f.networks[config.HostConfig.NetworkMode]; !ok {
+		return "", fmt.Errorf("network %s not found", config.HostConfig.NetworkMode)
+	}
+	if f.find(name) != nil {
+		return "", fmt.Errorf("container name %s is already in use", name)
+	}
+	f.seq++
+	c := &fakeContainer{
+		container: container{
+			ID:     fmt.Sprintf("%064d", f.seq),
+			Names:  []string{"/" + name},
+			State:  docker.StatusCreated,
+			Labels: config.Labels,
+			NetworkSettings: networkSettings{Networks: map[string]endpointSettings{
+				config.HostConfig.NetworkMode: {IPAddress: fmt.Sprintf("172.18.0.%d", f.seq+1)},
+			}},
+		},
+		config: config,
+		files:  map[string][]byte{},
+	}
+	f.containers = append(f.containers, c)
+	return c.ID, nil
+}
+
+func (f *fakeDocker) StartContainer(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.startErr != nil {
+		return f.startErr
+	}
+	c := f.find(id)
+	if c == nil {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	c.State = docker.StatusRunning
+	if len(c.config.Cmd) > 0 && c.config.Cmd[0] == "server" {
+		c.files[kubeCfgFile] = []byte(fakeKubeConfig)
+	}
+	return nil
+}
+
+func (f *fakeDocker) StopContainer(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.find(id)
+	if c == nil {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	c.State = docker.StatusExited
+	return nil
+}
+
+func (f *fakeDocker) RemoveContainer(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, c := range f.containers {
+		if c.ID == id || containerName(c.container) == id {
+			f.containers = append(f.containers[:i], f.containers[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeDocker) ListContainers(labels map[string]string) ([]container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	containers := make([]container, 0)
+	for _, c := range f.containers {
+		matched := true
+		for k, v := range labels {
+			if c.Labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			containers = append(containers, c.container)
+		}
+	}
+	return containers, nil
+}
+
+func (f *fakeDocker) CopyToContainer(id string, files map[string][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.find(id)
+	if c == nil {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	for name, content := range files {
+		c.files[name] = content
+	}
+	return nil
+}
+
+func (f *fakeDocker) CopyFromContainer(id, file string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.find(id)
+	if c == nil {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+	content, ok := c.files[file]
+	if !ok {
+		return nil, fmt.Errorf("could not find the file %s in container %s", file, id)
+	}
+	return content, nil
+}
+
+// find returns the container by id or name.
+func (f *fakeDocker) find(id string) *fakeContainer {
+	for _, c := range f.containers {
+		if c.ID == id || containerName(c.container) == strings.TrimPrefix(id, "/") {
+			return c
+		}
+	}
+	return nil
+}
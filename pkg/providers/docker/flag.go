@@ -0,0 +1,256 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/types/docker"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const createUsageExample = `  autok3s -d create \
+    --provider docker \
+    --name <cluster name> \
+    --master 1 \
+    --worker 1
+`
+
+const joinUsageExample = `  autok3s -d join \
+    --provider docker \
+    --name <cluster name> \
+    --worker 1
+`
+
+const deleteUsageExample = `  autok3s -d delete \
+    --provider docker \
+    --name <cluster name>
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider docker \
+    --name <cluster name>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider docker \
+    --name <cluster name>
+`
+
+func (p *Docker) GetUsageExample(action string) string {
+	switch action {
+	case "create":
+		return createUsageExample
+	case "join":
+		return joinUsageExample
+	case "delete":
+		return deleteUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
+	default:
+		return "not support"
+	}
+}
+
+func (p *Docker) GetOptionFlags() []types.Flag {
+	fs := p.sharedFlags()
+	fs = append(fs, []types.Flag{
+		{
+			Name:  "cluster",
+			P:     &p.Cluster,
+			V:     p.Cluster,
+			Usage: "Form k3s cluster using embedded etcd (requires K8s >= 1.19)",
+		},
+		{
+			Name:  "api-port",
+			P:     &p.APIPort,
+			V:     p.APIPort,
+			Usage: "Local port on which the api server of cluster is published, a free port is used if not specified",
+		},
+	}...)
+	return fs
+}
+
+func (p *Docker) GetJoinFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := p.sharedFlags()
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Docker) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:   "docker-host",
+			P:      &p.DockerHost,
+			V:      p.DockerHost,
+			Usage:  "Docker engine to connect to, e.g.(unix:///var/run/docker.sock or tcp://127.0.0.1:2375)",
+			EnvVar: "DOCKER_HOST",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Docker) GetSSHFlags(cmd *cobra.Command) *pflag.FlagSet {
+	return cmd.Flags()
+}
+
+func (p *Docker) GetCredentialFlags() []types.Flag {
+	return []types.Flag{}
+}
+
+func (p *Docker) GetSSHConfig() *types.SSH {
+	return &types.SSH{}
+}
+
+func (p *Docker) BindCredentialFlags() *pflag.FlagSet {
+	nfs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	return nfs
+}
+
+func (p *Docker) MergeClusterOptions() error {
+	clusters, err := cluster.ReadFromState(&types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+	})
+	if err != nil {
+		return err
+	}
+
+	var matched *types.Cluster
+	for _, c := range clusters {
+		if c.Provider == p.Provider && c.Name == fmt.Sprintf("%s.%s.%s", p.Name, defaultRegion, p.Provider) {
+			matched = &c
+		}
+	}
+
+	if matched != nil {
+		p.overwriteMetadata(matched)
+		// delete command need merge status value.
+		source := reflect.ValueOf(&p.Options).Elem()
+		b, err := json.Marshal(matched.Options)
+		if err != nil {
+			return err
+		}
+		opt := &docker.Options{}
+		err = json.Unmarshal(b, opt)
+		if err != nil {
+			return err
+		}
+		target := reflect.ValueOf(opt).Elem()
+		utils.MergeConfig(source, target)
+	}
+
+	return nil
+}
+
+func (p *Docker) overwriteMetadata(matched *types.Cluster) {
+	// doesn't need to be overwrite.
+	p.Status = matched.Status
+	p.Token = matched.Token
+	p.Cluster = matched.Cluster
+	p.DataStore = matched.DataStore
+	// needed to be overwrite.
+	if p.K3sVersion == "" {
+		p.K3sVersion = matched.K3sVersion
+	}
+	if p.Registry == "" {
+		p.Registry = matched.Registry
+	}
+	if p.MasterExtraArgs == "" {
+		p.MasterExtraArgs = matched.MasterExtraArgs
+	}
+	if p.WorkerExtraArgs == "" {
+		p.WorkerExtraArgs = matched.WorkerExtraArgs
+	}
+}
+
+func (p *Docker) sharedFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			ShortHand: "n",
+			Usage:     "Set the name of the kubeconfig context",
+			Required:  true,
+		},
+		{
+			Name:   "docker-host",
+			P:      &p.DockerHost,
+			V:      p.DockerHost,
+			Usage:  "Docker engine to connect to, e.g.(unix:///var/run/docker.sock or tcp://127.0.0.1:2375)",
+			EnvVar: "DOCKER_HOST",
+		},
+		{
+			Name:  "image",
+			P:     &p.Image,
+			V:     p.Image,
+			Usage: "K3s image of nodes, which is tagged with k3s version if no tag is specified",
+		},
+		{
+			Name:  "k3s-version",
+			P:     &p.K3sVersion,
+			V:     p.K3sVersion,
+			Usage: "Used to specify the version of k3s cluster, the latest image is used if not specified",
+		},
+		{
+			Name:  "master-extra-args",
+			P:     &p.MasterExtraArgs,
+			V:     p.MasterExtraArgs,
+			Usage: "Master extra arguments for k3s server, wrapped in quotes. e.g.(--master-extra-args '--no-deploy metrics-server')",
+		},
+		{
+			Name:  "worker-extra-args",
+			P:     &p.WorkerExtraArgs,
+			V:     p.WorkerExtraArgs,
+			Usage: "Worker extra arguments for k3s agent, wrapped in quotes. e.g.(--worker-extra-args '--node-taint key=value:NoExecute')",
+		},
+		{
+			Name:  "registry",
+			P:     &p.Registry,
+			V:     p.Registry,
+			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
+		},
+		{
+			Name:  "datastore",
+			P:     &p.DataStore,
+			V:     p.DataStore,
+			Usage: "K3s datastore, HA mode `create/join` master node needed this flag",
+		},
+		{
+			Name:  "token",
+			P:     &p.Token,
+			V:     p.Token,
+			Usage: "K3s master token, if empty will automatically generated",
+		},
+		{
+			Name:  "master",
+			P:     &p.Master,
+			V:     p.Master,
+			Usage: "Number of master node",
+		},
+		{
+			Name:  "worker",
+			P:     &p.Worker,
+			V:     p.Worker,
+			Usage: "Number of worker node",
+		},
+	}
+
+	return fs
+}
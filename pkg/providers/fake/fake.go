@@ -0,0 +1,57 @@
+// Package fake provides the fakes shared by the offline tests of providers,
+// the cloud APIs of each provider are faked in its own tests.
+package fake
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SetupCfgPath points common.CfgPath to a new temporary folder with the log and state folders created like autok3s,
+// the returned func removes the folder and restores common.CfgPath.
+func SetupCfgPath() (func(), error) {
+	cfgPath := common.CfgPath
+	dir, err := ioutil.TempDir("", "autok3s")
+	if err != nil {
+		return nil, err
+	}
+	common.CfgPath = dir
+	for _, folder := range []string{common.GetLogPath(), common.GetClusterStatePath()} {
+		if err := utils.EnsureFolderExist(folder); err != nil {
+			common.CfgPath = cfgPath
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	return func() {
+		_ = os.RemoveAll(dir)
+		common.CfgPath = cfgPath
+	}, nil
+}
+
+// Logger returns the logger which discards all logs.
+func Logger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logger
+}
+
+// LoadStatus returns the status of cluster as it's loaded from the state, in which nodes are never rolled back.
+func LoadStatus(s types.Status) types.Status {
+	status := types.Status{Status: s.Status, InitMaster: s.InitMaster}
+	for _, n := range s.MasterNodes {
+		n.RollBack = false
+		status.MasterNodes = append(status.MasterNodes, n)
+	}
+	for _, n := range s.WorkerNodes {
+		n.RollBack = false
+		status.WorkerNodes = append(status.WorkerNodes, n)
+	}
+	return status
+}
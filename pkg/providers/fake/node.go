@@ -0,0 +1,470 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// KubeConfigFile and TokenFile are written on the node once k3s server is installed.
+	KubeConfigFile = "/etc/rancher/k3s/k3s.yaml"
+	TokenFile      = "/var/lib/rancher/k3s/server/node-token"
+
+	kubeConfig = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: ZmFrZQ==
+    server: https://127.0.0.1:6443
+  name: default
+contexts:
+- context:
+    cluster: default
+    user: default
+  name: default
+current-context: default
+kind: Config
+preferences: {}
+users:
+- name: default
+  user:
+    client-certificate-data: ZmFrZQ==
+    client-key-data: ZmFrZQ==
+`
+)
+
+var tokenRegexp = regexp.MustCompile(`K3S_TOKEN='([^']*)'`)
+
+// Node is an in-memory host, which records the commands run on it.
+type Node struct {
+	// Host is the address which the node is dialed with.
+	Host string
+
+	mu       sync.Mutex
+	seq      int
+	commands []string
+	files    map[string]*file
+	dirs     map[string]bool
+}
+
+type file struct {
+	content []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newNode(host string) *Node {
+	return &Node{
+		Host:  host,
+		files: make(map[string]*file),
+		dirs:  map[string]bool{"/": true},
+	}
+}
+
+// Commands returns the commands run on the node in order.
+func (n *Node) Commands() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.commands...)
+}
+
+// ReadFile returns the content of file on the node, false is returned if not exist.
+func (n *Node) ReadFile(name string) ([]byte, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, ok := n.files[name]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), f.content...), true
+}
+
+// FileMode returns the mode of file on the node, 0 is returned if not exist.
+func (n *Node) FileMode(name string) os.FileMode {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if f, ok := n.files[name]; ok {
+		return f.mode
+	}
+	return 0
+}
+
+// WriteFile writes the content to file on the node.
+func (n *Node) WriteFile(name string, content []byte, mode os.FileMode) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.files[name] = &file{content: append([]byte(nil), content...), mode: mode, modTime: time.Now()}
+}
+
+// Run runs the command like a linux host which is ready to install k3s:
+// pre-flight checks pass, files can be managed by shell commands,
+// and the kubeconfig and token files are written once k3s server is installed.
+// Other commands succeed without output.
+func (n *Node) Run(cmd string) (string, uint32) {
+	cmd = strings.TrimSpace(cmd)
+	switch {
+	case strings.HasPrefix(cmd, "uname -s"):
+		return "Linux\nx86_64\nubuntu\n", 0
+	case strings.HasPrefix(cmd, "command -v curl"):
+		return "/usr/bin/curl\n", 0
+	case strings.HasPrefix(cmd, "df -Pk"):
+		return "/dev/sda1 41152736 1048576 40104160 3% /\n", 0
+	case strings.HasPrefix(cmd, "grep MemTotal"):
+		return "MemTotal:        4035652 kB\n", 0
+	case strings.HasPrefix(cmd, "date +%s"):
+		return strconv.FormatInt(time.Now().Unix(), 10) + "\n", 0
+	case strings.HasPrefix(cmd, "getenforce"):
+		return "Disabled\n", 0
+	case strings.Contains(cmd, "INSTALL_K3S_EXEC='server"):
+		token := ""
+		if m := tokenRegexp.FindStringSubmatch(cmd); m != nil {
+			token = m[1]
+		}
+		n.WriteFile(KubeConfigFile, []byte(kubeConfig), 0600)
+		n.WriteFile(TokenFile, []byte(token+"\n"), 0600)
+		return "", 0
+	}
+
+	var out strings.Builder
+	for _, segment := range strings.Split(cmd, " && ") {
+		o, status := n.exec(splitWords(segment))
+		out.WriteString(o)
+		if status != 0 {
+			return out.String(), status
+		}
+	}
+	return out.String(), 0
+}
+
+// exec runs the file commands used to transfer files, others succeed without output.
+func (n *Node) exec(args []string) (string, uint32) {
+	if len(args) > 0 && args[0] == "sudo" {
+		args = args[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return "", 0
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch args[0] {
+	case "mktemp":
+		n.seq++
+		dir := strings.Replace(args[len(args)-1], "XXXXXXXX", fmt.Sprintf("%08d", n.seq), 1)
+		n.dirs[dir] = true
+		return dir + "\n", 0
+	case "mkdir":
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				n.dirs[arg] = true
+			}
+		}
+		return "", 0
+	case "install", "cp":
+		var mode os.FileMode
+		files := make([]string, 0, 2)
+		dir := false
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "-d":
+				dir = true
+			case "-m":
+				i++
+				m, err := strconv.ParseUint(args[i], 8, 32)
+				if err != nil {
+					return fmt.Sprintf("%s: invalid mode %s", args[0], args[i]), 1
+				}
+				mode = os.FileMode(m)
+			case "-o", "-g":
+				i++
+			case "-p":
+			default:
+				files = append(files, args[i])
+			}
+		}
+		if dir {
+			for _, f := range files {
+				n.dirs[f] = true
+			}
+			return "", 0
+		}
+		if len(files) != 2 {
+			return fmt.Sprintf("%s: missing file operand", args[0]), 1
+		}
+		src, ok := n.files[files[0]]
+		if !ok {
+			return fmt.Sprintf("%s: cannot stat '%s': No such file or directory", args[0], files[0]), 1
+		}
+		if mode == 0 {
+			mode = src.mode
+		}
+		n.files[files[1]] = &file{content: src.content, mode: mode, modTime: time.Now()}
+		return "", 0
+	case "cat":
+		var out bytes.Buffer
+		for _, name := range args[1:] {
+			f, ok := n.files[name]
+			if !ok {
+				return fmt.Sprintf("cat: %s: No such file or directory", name), 1
+			}
+			out.Write(f.content)
+		}
+		return out.String(), 0
+	case "sha256sum":
+		var out strings.Builder
+		for _, name := range args[1:] {
+			f, ok := n.files[name]
+			if !ok {
+				return fmt.Sprintf("sha256sum: %s: No such file or directory", name), 1
+			}
+			sum := sha256.Sum256(f.content)
+			fmt.Fprintf(&out, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+		}
+		return out.String(), 0
+	case "rm":
+		for _, name := range args[1:] {
+			if strings.HasPrefix(name, "-") {
+				continue
+			}
+			n.remove(name)
+		}
+		return "", 0
+	}
+	return "", 0
+}
+
+func (n *Node) record(cmd string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.commands = append(n.commands, strings.TrimSpace(cmd))
+}
+
+// remove removes the file or folder with all files in it, n.mu must be held.
+func (n *Node) remove(name string) {
+	delete(n.files, name)
+	delete(n.dirs, name)
+	for p := range n.files {
+		if strings.HasPrefix(p, name+"/") {
+			delete(n.files, p)
+		}
+	}
+	for p := range n.dirs {
+		if strings.HasPrefix(p, name+"/") {
+			delete(n.dirs, p)
+		}
+	}
+}
+
+// stat returns the info of file or folder, n.mu must be held.
+func (n *Node) stat(name string) (os.FileInfo, bool) {
+	if f, ok := n.files[name]; ok {
+		return &fileInfo{name: path.Base(name), size: int64(len(f.content)), mode: f.mode, modTime: f.modTime}, true
+	}
+	isDir := n.dirs[name]
+	for p := range n.files {
+		if strings.HasPrefix(p, strings.TrimSuffix(name, "/")+"/") {
+			isDir = true
+			break
+		}
+	}
+	if !isDir {
+		return nil, false
+	}
+	return &fileInfo{name: path.Base(name), mode: os.ModeDir | 0755, modTime: time.Now()}, true
+}
+
+func (n *Node) handlers() sftp.Handlers {
+	h := &nodeHandler{node: n}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+// nodeHandler serves the files of node through sftp.
+type nodeHandler struct {
+	node *Node
+}
+
+func (h *nodeHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	content, ok := h.node.ReadFile(r.Filepath)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return bytes.NewReader(content), nil
+}
+
+func (h *nodeHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	h.node.mu.Lock()
+	defer h.node.mu.Unlock()
+	f, ok := h.node.files[r.Filepath]
+	if !ok || r.Pflags().Trunc {
+		f = &file{mode: 0644, modTime: time.Now()}
+		if ok {
+			f.mode = h.node.files[r.Filepath].mode
+		}
+		h.node.files[r.Filepath] = f
+	}
+	return &fileWriter{node: h.node, file: f}, nil
+}
+
+func (h *nodeHandler) Filecmd(r *sftp.Request) error {
+	n := h.node
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch r.Method {
+	case "Setstat":
+		f, ok := n.files[r.Filepath]
+		if !ok {
+			if _, ok := n.stat(r.Filepath); ok {
+				return nil
+			}
+			return os.ErrNotExist
+		}
+		if r.AttrFlags().Permissions {
+			f.mode = r.Attributes().FileMode().Perm()
+		}
+	case "Mkdir":
+		n.dirs[r.Filepath] = true
+	case "Rename":
+		f, ok := n.files[r.Filepath]
+		if !ok {
+			return os.ErrNotExist
+		}
+		delete(n.files, r.Filepath)
+		n.files[r.Target] = f
+	case "Remove", "Rmdir":
+		if _, ok := n.stat(r.Filepath); !ok {
+			return os.ErrNotExist
+		}
+		n.remove(r.Filepath)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+	return nil
+}
+
+func (h *nodeHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	n := h.node
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch r.Method {
+	case "Stat":
+		info, ok := n.stat(r.Filepath)
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return listerAt{info}, nil
+	case "List":
+		if _, ok := n.stat(r.Filepath); !ok {
+			return nil, os.ErrNotExist
+		}
+		prefix := strings.TrimSuffix(r.Filepath, "/") + "/"
+		infos := make(listerAt, 0)
+		for p := range n.files {
+			if strings.HasPrefix(p, prefix) && !strings.Contains(p[len(prefix):], "/") {
+				info, _ := n.stat(p)
+				infos = append(infos, info)
+			}
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Name() < infos[j].Name()
+		})
+		return infos, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type fileWriter struct {
+	node *Node
+	file *file
+}
+
+func (w *fileWriter) WriteAt(p []byte, off int64) (int, error) {
+	w.node.mu.Lock()
+	defer w.node.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(w.file.content)) {
+		content := make([]byte, end)
+		copy(content, w.file.content)
+		w.file.content = content
+	}
+	copy(w.file.content[off:], p)
+	w.file.modTime = time.Now()
+	return len(p), nil
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() os.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() interface{}   { return nil }
+
+// splitWords splits the command into words like shell, quotes are removed.
+func splitWords(s string) []string {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
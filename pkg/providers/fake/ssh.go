@@ -0,0 +1,258 @@
+package fake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// CommandFunc runs the command on the node and returns the output and exit status,
+// the output is written to stderr if the status is not 0.
+type CommandFunc func(node *Node, cmd string) (string, uint32)
+
+// SSHServer is an in-process ssh server which acts as the bastion of nodes,
+// the nodes dialed through it are in-memory hosts which run commands and serve files through sftp.
+// Any user and credentials are accepted.
+type SSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu      sync.Mutex
+	nodes   map[string]*Node
+	conns   map[net.Conn]struct{}
+	command CommandFunc
+	closed  bool
+}
+
+// NewSSHServer starts the ssh server listening on a random port of 127.0.0.1.
+func NewSSHServer() (*SSHServer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &SSHServer{
+		listener: listener,
+		config:   config,
+		nodes:    make(map[string]*Node),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Bastion returns the bastion which nodes are dialed through.
+func (s *SSHServer) Bastion() types.Bastion {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return types.Bastion{Host: host, Port: port}
+}
+
+// Node returns the node with the host address, which is created if not dialed yet.
+func (s *SSHServer) Node(host string) *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[host]
+	if !ok {
+		n = newNode(host)
+		s.nodes[host] = n
+	}
+	return n
+}
+
+// Handle replaces the command handler of nodes, which is Node.Run by default.
+func (s *SSHServer) Handle(fn CommandFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.command = fn
+}
+
+// Close stops the server and closes all connections.
+func (s *SSHServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	return s.listener.Close()
+}
+
+func (s *SSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+		go s.handleConn(conn, host)
+	}
+}
+
+// handleConn serves the ssh connection of the node with the host address,
+// the nodes dialed through the connection are served on the forwarded channels.
+func (s *SSHServer) handleConn(conn net.Conn, host string) {
+	if !s.track(conn) {
+		return
+	}
+	defer s.untrack(conn)
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = sconn.Close()
+	}()
+	go ssh.DiscardRequests(reqs)
+
+	node := s.Node(host)
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			ch, reqs, err := nc.Accept()
+			if err != nil {
+				continue
+			}
+			go s.handleSession(node, ch, reqs)
+		case "direct-tcpip":
+			var target struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			if err := ssh.Unmarshal(nc.ExtraData(), &target); err != nil {
+				_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, reqs, err := nc.Accept()
+			if err != nil {
+				continue
+			}
+			go ssh.DiscardRequests(reqs)
+			go s.handleConn(&channelConn{Channel: ch}, target.Host)
+		default:
+			_ = nc.Reject(ssh.UnknownChannelType, nc.ChannelType())
+		}
+	}
+}
+
+func (s *SSHServer) handleSession(node *Node, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer func() {
+		_ = ch.Close()
+	}()
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			out, status := s.run(node, payload.Command)
+			if status == 0 {
+				_, _ = io.WriteString(ch, out)
+			} else {
+				_, _ = io.WriteString(ch.Stderr(), out)
+			}
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			server := sftp.NewRequestServer(ch, node.handlers())
+			_ = server.Serve()
+			_ = server.Close()
+			return
+		default:
+			if req.WantReply {
+				_ = req.Reply(true, nil)
+			}
+		}
+	}
+}
+
+func (s *SSHServer) run(node *Node, cmd string) (string, uint32) {
+	node.record(cmd)
+	s.mu.Lock()
+	fn := s.command
+	s.mu.Unlock()
+	if fn != nil {
+		return fn(node, cmd)
+	}
+	return node.Run(cmd)
+}
+
+func (s *SSHServer) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = conn.Close()
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *SSHServer) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	_ = conn.Close()
+}
+
+// channelConn serves the forwarded channel as the connection of node.
+type channelConn struct {
+	ssh.Channel
+}
+
+func (c *channelConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func (c *channelConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func (c *channelConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *channelConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *channelConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package fake
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// Suite is the environment of the specs of provider with fake api, which is set up before each spec and torn down after it.
+// The cluster state is saved in a temporary cfg path, and the replaced variables of provider package are restored.
+type Suite struct {
+	// SSH is the config to connect the nodes of cluster, which is reset for each spec.
+	SSH *types.SSH
+
+	ssh      types.SSH
+	restores []func()
+}
+
+// NewSuite registers the setup and teardown of suite in the current ginkgo container,
+// setup is called after the cfg path is ready to replace the api client of provider.
+func NewSuite(ssh types.SSH, setup func(s *Suite)) *Suite {
+	s := &Suite{ssh: ssh}
+	ginkgo.BeforeEach(func() {
+		cleanup, err := SetupCfgPath()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		s.restores = []func(){cleanup}
+		config := s.ssh
+		s.SSH = &config
+		if setup != nil {
+			setup(s)
+		}
+	})
+	ginkgo.AfterEach(func() {
+		for i := len(s.restores) - 1; i >= 0; i-- {
+			s.restores[i]()
+		}
+		s.restores = nil
+	})
+	return s
+}
+
+// Replace sets the variable pointed by ptr to value until the spec ends, e.g. the constructor of api client.
+func (s *Suite) Replace(ptr, value interface{}) {
+	v := reflect.ValueOf(ptr).Elem()
+	origin := reflect.New(v.Type()).Elem()
+	origin.Set(v)
+	v.Set(reflect.ValueOf(value))
+	s.restores = append(s.restores, func() {
+		v.Set(origin)
+	})
+}
+
+// SSHServer starts the fake ssh server which is closed when the spec ends,
+// the nodes of cluster are dialed through it with the ssh config of suite.
+func (s *Suite) SSHServer() *SSHServer {
+	server, err := NewSSHServer()
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	s.restores = append(s.restores, func() {
+		_ = server.Close()
+	})
+	s.SSH.Password = "fake"
+	s.SSH.Bastions = []types.Bastion{server.Bastion()}
+	return server
+}
+
+// FailJoin is the command handler which fails to install k3s on the nodes joining the cluster,
+// it's used to roll back the nodes failed to join.
+func FailJoin(node *Node, cmd string) (string, uint32) {
+	if strings.Contains(cmd, "K3S_URL=") {
+		return "failed to install k3s", 1
+	}
+	return node.Run(cmd)
+}
@@ -0,0 +1,435 @@
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	apiEndpoint  = "https://compute.googleapis.com/compute/v1/projects/"
+	computeScope = "https://www.googleapis.com/auth/compute"
+	tokenURL     = "https://oauth2.googleapis.com/token"
+)
+
+// computeClient is the subset of Compute Engine API used by the provider, which is replaced by an in-memory fake in tests.
+type computeClient interface {
+	InsertInstance(zone string, opts instanceCreateOpts) error
+	ListInstances(zone string, labels map[string]string) ([]instance, error)
+	StartInstance(zone, name string) error
+	StopInstance(zone, name string) error
+	DeleteInstance(zone, name string) error
+
+	ListFirewalls() ([]firewall, error)
+	InsertFirewall(fw firewall) error
+	UpdateFirewall(fw firewall) error
+	DeleteFirewall(name string) error
+
+	GetSubnetwork(region, name string) (*subnetwork, error)
+}
+
+// newComputeClient creates the client authorized by service account key, the project of key is used if project is empty.
+// Tests replace it to run the provider without Google Cloud.
+var newComputeClient = func(serviceAccount, project string) (computeClient, error) {
+	key, err := parseServiceAccount(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	if project == "" {
+		project = key.ProjectID
+	}
+	if project == "" {
+		return nil, fmt.Errorf("project is required")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = tokenURL
+	}
+	conf := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{computeScope},
+		TokenURL:     key.TokenURI,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: 60 * time.Second})
+	return &restClient{
+		endpoint: apiEndpoint + project,
+		client:   conf.Client(ctx),
+	}, nil
+}
+
+// serviceAccountKey is the json key of service account.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// parseServiceAccount parses the service account key, which is the json content or path of the key file.
+func parseServiceAccount(s string) (*serviceAccountKey, error) {
+	b, err := readServiceAccount(s)
+	if err != nil {
+		return nil, err
+	}
+	key := &serviceAccountKey{}
+	if err := json.Unmarshal(b, key); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %v", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("invalid service account key: must be the json key of service account")
+	}
+	return key, nil
+}
+
+// readServiceAccount returns the json key of service account, which is the json key itself or the path of key file.
+func readServiceAccount(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("service account is required")
+	}
+	if strings.HasPrefix(s, "{") {
+		return []byte(s), nil
+	}
+	content, err := ioutil.ReadFile(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key file %s: %v", s, err)
+	}
+	return content, nil
+}
+
+// instance is the Compute Engine instance with the first network interface flattened.
+type instance struct {
+	Name       string
+	Status     string
+	Labels     map[string]string
+	Tags       []string
+	Subnetwork string
+	PublicIP   string
+	PrivateIP  string
+}
+
+type instanceCreateOpts struct {
+	Name        string
+	MachineType string
+	Image       string
+	DiskSizeGb  string
+	Network     string
+	Subnetwork  string
+	SSHKeys     string
+	Labels      map[string]string
+	Tags        []string
+}
+
+type firewall struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	Network      string            `json:"network,omitempty"`
+	Direction    string            `json:"direction,omitempty"`
+	SourceRanges []string          `json:"sourceRanges,omitempty"`
+	Allowed      []firewallAllowed `json:"allowed,omitempty"`
+	TargetTags   []string          `json:"targetTags,omitempty"`
+}
+
+type firewallAllowed struct {
+	IPProtocol string   `json:"IPProtocol"`
+	Ports      []string `json:"ports,omitempty"`
+}
+
+type subnetwork struct {
+	Name        string `json:"name"`
+	Region      string `json:"region"`
+	IPCidrRange string `json:"ipCidrRange"`
+}
+
+type metadataItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// apiInstance is the instance requested and returned by api.
+type apiInstance struct {
+	Name        string            `json:"name"`
+	Status      string            `json:"status,omitempty"`
+	MachineType string            `json:"machineType,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Tags        struct {
+		Items []string `json:"items,omitempty"`
+	} `json:"tags"`
+	Metadata struct {
+		Items []metadataItem `json:"items,omitempty"`
+	} `json:"metadata"`
+	Disks             []apiDisk             `json:"disks,omitempty"`
+	NetworkInterfaces []apiNetworkInterface `json:"networkInterfaces,omitempty"`
+}
+
+type apiDisk struct {
+	Boot             bool `json:"boot"`
+	AutoDelete       bool `json:"autoDelete"`
+	InitializeParams struct {
+		SourceImage string `json:"sourceImage,omitempty"`
+		DiskSizeGb  string `json:"diskSizeGb,omitempty"`
+	} `json:"initializeParams"`
+}
+
+type apiNetworkInterface struct {
+	Network       string            `json:"network,omitempty"`
+	Subnetwork    string            `json:"subnetwork,omitempty"`
+	NetworkIP     string            `json:"networkIP,omitempty"`
+	AccessConfigs []apiAccessConfig `json:"accessConfigs,omitempty"`
+}
+
+type apiAccessConfig struct {
+	Type  string `json:"type,omitempty"`
+	Name  string `json:"name,omitempty"`
+	NatIP string `json:"natIP,omitempty"`
+}
+
+func (i apiInstance) convert() instance {
+	v := instance{
+		Name:   i.Name,
+		Status: i.Status,
+		Labels: i.Labels,
+		Tags:   i.Tags.Items,
+	}
+	if len(i.NetworkInterfaces) > 0 {
+		n := i.NetworkInterfaces[0]
+		// the subnetwork is the url of resource, e.g. https://.../regions/us-central1/subnetworks/default.
+		v.Subnetwork = n.Subnetwork[strings.LastIndex(n.Subnetwork, "/")+1:]
+		v.PrivateIP = n.NetworkIP
+		if len(n.AccessConfigs) > 0 {
+			v.PublicIP = n.AccessConfigs[0].NatIP
+		}
+	}
+	return v
+}
+
+type operation struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	SelfLink string `json:"selfLink"`
+	Error    *struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// restClient calls Compute Engine api, see: https://cloud.google.com/compute/docs/reference/rest/v1.
+type restClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (c *restClient) InsertInstance(zone string, opts instanceCreateOpts) error {
+	in := apiInstance{
+		Name:        opts.Name,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, opts.MachineType),
+		Labels:      opts.Labels,
+	}
+	in.Tags.Items = opts.Tags
+	in.Metadata.Items = []metadataItem{{Key: "ssh-keys", Value: opts.SSHKeys}}
+	disk := apiDisk{Boot: true, AutoDelete: true}
+	disk.InitializeParams.SourceImage = opts.Image
+	disk.InitializeParams.DiskSizeGb = opts.DiskSizeGb
+	in.Disks = []apiDisk{disk}
+	in.NetworkInterfaces = []apiNetworkInterface{{
+		Network:       opts.Network,
+		Subnetwork:    opts.Subnetwork,
+		AccessConfigs: []apiAccessConfig{{Type: "ONE_TO_ONE_NAT", Name: "External NAT"}},
+	}}
+	return c.operate(http.MethodPost, fmt.Sprintf("/zones/%s/instances", zone), in)
+}
+
+func (c *restClient) ListInstances(zone string, labels map[string]string) ([]instance, error) {
+	items := make([]apiInstance, 0)
+	query := url.Values{"filter": {labelFilter(labels)}}
+	err := c.list(fmt.Sprintf("/zones/%s/instances", zone), query, func(b json.RawMessage) error {
+		page := make([]apiInstance, 0)
+		if err := json.Unmarshal(b, &page); err != nil {
+			return err
+		}
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]instance, 0, len(items))
+	for _, i := range items {
+		instances = append(instances, i.convert())
+	}
+	return instances, nil
+}
+
+func (c *restClient) StartInstance(zone, name string) error {
+	return c.operate(http.MethodPost, fmt.Sprintf("/zones/%s/instances/%s/start", zone, name), nil)
+}
+
+func (c *restClient) StopInstance(zone, name string) error {
+	return c.operate(http.MethodPost, fmt.Sprintf("/zones/%s/instances/%s/stop", zone, name), nil)
+}
+
+func (c *restClient) DeleteInstance(zone, name string) error {
+	return c.operate(http.MethodDelete, fmt.Sprintf("/zones/%s/instances/%s", zone, name), nil)
+}
+
+func (c *restClient) ListFirewalls() ([]firewall, error) {
+	firewalls := make([]firewall, 0)
+	err := c.list("/global/firewalls", nil, func(b json.RawMessage) error {
+		page := make([]firewall, 0)
+		if err := json.Unmarshal(b, &page); err != nil {
+			return err
+		}
+		firewalls = append(firewalls, page...)
+		return nil
+	})
+	return firewalls, err
+}
+
+func (c *restClient) InsertFirewall(fw firewall) error {
+	return c.operate(http.MethodPost, "/global/firewalls", fw)
+}
+
+func (c *restClient) UpdateFirewall(fw firewall) error {
+	return c.operate(http.MethodPut, "/global/firewalls/"+fw.Name, fw)
+}
+
+func (c *restClient) DeleteFirewall(name string) error {
+	return c.operate(http.MethodDelete, "/global/firewalls/"+name, nil)
+}
+
+func (c *restClient) GetSubnetwork(region, name string) (*subnetwork, error) {
+	out := &subnetwork{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/regions/%s/subnetworks/%s", region, name), nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// operate requests the api which returns an operation, and waits for the operation to be done.
+func (c *restClient) operate(method, path string, in interface{}) error {
+	op := &operation{}
+	if err := c.do(method, path, nil, in, op); err != nil {
+		return err
+	}
+	for op.Status != "DONE" {
+		// the wait method returns when the operation is done or after about 2 minutes.
+		if err := c.do(http.MethodPost, op.SelfLink+"/wait", nil, nil, op); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		msgs := make([]string, 0, len(op.Error.Errors))
+		for _, e := range op.Error.Errors {
+			msgs = append(msgs, fmt.Sprintf("%s (%s)", e.Message, e.Code))
+		}
+		return fmt.Errorf("%s %s: %s", method, path, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// list requests all pages of the resources, items of each page are passed to fn.
+func (c *restClient) list(path string, query url.Values, fn func(items json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("maxResults", "500")
+	for {
+		out := &struct {
+			Items         json.RawMessage `json:"items"`
+			NextPageToken string          `json:"nextPageToken"`
+		}{}
+		if err := c.do(http.MethodGet, path, query, nil, out); err != nil {
+			return err
+		}
+		if len(out.Items) > 0 {
+			if err := fn(out.Items); err != nil {
+				return err
+			}
+		}
+		if out.NextPageToken == "" {
+			return nil
+		}
+		query.Set("pageToken", out.NextPageToken)
+	}
+}
+
+func (c *restClient) do(method, path string, query url.Values, in, out interface{}) error {
+	u := path
+	if !strings.HasPrefix(path, "https://") {
+		u = c.endpoint + path
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &apiError{}
+		if err := json.Unmarshal(b, e); err == nil && e.Error.Message != "" {
+			return fmt.Errorf("%s %s: %s", method, path, e.Error.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// labelFilter returns the filter expression of list api matching all the labels.
+func labelFilter(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	exprs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		exprs = append(exprs, fmt.Sprintf("(labels.%s = %q)", k, labels[k]))
+	}
+	return strings.Join(exprs, " ")
+}
@@ -0,0 +1,163 @@
+package google
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/google"
+)
+
+const (
+	fakeSubnetwork  = "default"
+	fakeSubnetRange = "10.128.0.0/20"
+)
+
+// fakeCompute is an in-memory Compute Engine project, instances are running with addresses assigned once inserted.
+type fakeCompute struct {
+	mu sync.Mutex
+
+	seq       int
+	instances []*fakeInstance
+	firewalls []*firewall
+}
+
+type fakeInstance struct {
+	instance
+	zone string
+	opts instanceCreateOpts
+}
+
+func newFakeCompute() *fakeCompute {
+	return &fakeCompute{}
+}
+
+func (f *fakeCompute) InsertInstance(zone string, opts instanceCreateOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, i := range f.instances {
+		if i.Name == opts.Name && i.zone == zone {
+			return fmt.Errorf("instance %s already exists", opts.Name)
+		}
+	}
+	f.seq++
+	f.instances = append(f.instances, &fakeInstance{
+		instance: instance{
+			Name:       opts.Name,
+			Status:     google.StatusRunning,
+			Labels:     opts.Labels,
+			Tags:       opts.Tags,
+			Subnetwork: fakeSubnetwork,
+			PublicIP:   fmt.Sprintf("203.0.113.%d", f.seq),
+			PrivateIP:  fmt.Sprintf("10.128.0.%d", f.seq),
+		},
+		zone: zone,
+		opts: opts,
+	})
+	return nil
+}
+
+func (f *fakeCompute) ListInstances(zone string, labels map[string]string) ([]instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	instances := make([]instance, 0)
+	for _, i := range f.instances {
+		if i.zone == zone && matchLabels(i.Labels, labels) {
+			instances = append(instances, i.instance)
+		}
+	}
+	return instances, nil
+}
+
+func (f *fakeCompute) StartInstance(zone, name string) error {
+	return f.setStatus(zone, name, google.StatusRunning)
+}
+
+func (f *fakeCompute) StopInstance(zone, name string) error {
+	return f.setStatus(zone, name, google.StatusTerminated)
+}
+
+func (f *fakeCompute) setStatus(zone, name, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, i := range f.instances {
+		if i.Name == name && i.zone == zone {
+			i.Status = status
+			return nil
+		}
+	}
+	return fmt.Errorf("instance not found: %s", name)
+}
+
+func (f *fakeCompute) DeleteInstance(zone, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, i := range f.instances {
+		if i.Name == name && i.zone == zone {
+			f.instances = append(f.instances[:k], f.instances[k+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("instance not found: %s", name)
+}
+
+func (f *fakeCompute) ListFirewalls() ([]firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firewalls := make([]firewall, 0, len(f.firewalls))
+	for _, fw := range f.firewalls {
+		firewalls = append(firewalls, *fw)
+	}
+	return firewalls, nil
+}
+
+func (f *fakeCompute) InsertFirewall(fw firewall) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range f.firewalls {
+		if v.Name == fw.Name {
+			return fmt.Errorf("firewall %s already exists", fw.Name)
+		}
+	}
+	f.firewalls = append(f.firewalls, &fw)
+	return nil
+}
+
+func (f *fakeCompute) UpdateFirewall(fw firewall) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, v := range f.firewalls {
+		if v.Name == fw.Name {
+			f.firewalls[i] = &fw
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %s", fw.Name)
+}
+
+func (f *fakeCompute) DeleteFirewall(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, fw := range f.firewalls {
+		if fw.Name == name {
+			f.firewalls = append(f.firewalls[:i], f.firewalls[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %s", name)
+}
+
+func (f *fakeCompute) GetSubnetwork(region, name string) (*subnetwork, error) {
+	if name != fakeSubnetwork {
+		return nil, fmt.Errorf("subnetwork not found: %s", name)
+	}
+	return &subnetwork{Name: name, Region: region, IPCidrRange: fakeSubnetRange}, nil
+}
+
+func matchLabels(labels, filters map[string]string) bool {
+	for k, v := range filters {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
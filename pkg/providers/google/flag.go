@@ -0,0 +1,461 @@
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cnrancher/autok3s/pkg/types/google"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const createUsageExample = `  autok3s -d create \
+    --provider google \
+    --name <cluster name> \
+    --master 1
+`
+
+const joinUsageExample = `  autok3s -d join \
+    --provider google \
+    --name <cluster name> \
+    --worker 1
+`
+
+const deleteUsageExample = `  autok3s -d delete \
+    --provider google \
+    --name <cluster name>
+`
+
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider google \
+    --name <cluster name> \
+    --node <instance name or ip>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider google \
+    --name <cluster name> \
+    --k3s-version <k3s version>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider google \
+    --name <cluster name> \
+    --snapshot-name <snapshot name>
+  autok3s snapshot list \
+    --provider google \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider google \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider google \
+    --name <cluster name> \
+    --repair
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider google \
+    --name <cluster name>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider google \
+    --name <cluster name>
+`
+
+const sshUsageExample = `  autok3s ssh \
+    --provider google \
+    --name <cluster name>
+`
+
+func (p *Google) GetUsageExample(action string) string {
+	switch action {
+	case "create":
+		return createUsageExample
+	case "join":
+		return joinUsageExample
+	case "delete":
+		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
+	case "ssh":
+		return sshUsageExample
+	default:
+		return ""
+	}
+}
+
+func (p *Google) GetOptionFlags() []types.Flag {
+	fs := p.sharedFlags()
+	fs = append(fs, []types.Flag{
+		{
+			Name:  "ui",
+			P:     &p.UI,
+			V:     p.UI,
+			Usage: "Enable K3s UI.",
+		},
+		{
+			Name:  "cluster",
+			P:     &p.Cluster,
+			V:     p.Cluster,
+			Usage: "Form k3s cluster using embedded etcd (requires K8s >= 1.19)",
+		},
+	}...)
+	return fs
+}
+
+func (p *Google) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "Compute Engine region of instances, e.g.(us-central1, europe-west1)",
+		},
+		{
+			Name:  "zone",
+			P:     &p.Zone,
+			V:     p.Zone,
+			Usage: "Compute Engine zone of instances in region, e.g.(us-central1-a, europe-west1-b)",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Google) GetJoinFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := p.sharedFlags()
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Google) GetSSHFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "Compute Engine region of instances, e.g.(us-central1, europe-west1)",
+		},
+		{
+			Name:  "zone",
+			P:     &p.Zone,
+			V:     p.Zone,
+			Usage: "Compute Engine zone of instances in region, e.g.(us-central1-a, europe-west1-b)",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Google) GetCredentialFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:     "service-account",
+			P:        &p.ServiceAccount,
+			V:        p.ServiceAccount,
+			Usage:    "Json key or path of the key file of Google Cloud service account",
+			Required: true,
+			EnvVar:   "GOOGLE_APPLICATION_CREDENTIALS",
+		},
+	}
+
+	return fs
+}
+
+func (p *Google) GetSSHConfig() *types.SSH {
+	ssh := &types.SSH{
+		User: defaultUser,
+		Port: "22",
+	}
+	return ssh
+}
+
+func (p *Google) BindCredentialFlags() *pflag.FlagSet {
+	nfs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	nfs.StringVar(&p.ServiceAccount, "service-account", p.ServiceAccount, "Json key or path of the key file of Google Cloud service account")
+	return nfs
+}
+
+func (p *Google) MergeClusterOptions() error {
+	clusters, err := cluster.ReadFromState(&types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+	})
+	if err != nil {
+		return err
+	}
+
+	var matched *types.Cluster
+	for _, c := range clusters {
+		if c.Provider == p.Provider && c.Name == fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.Provider) {
+			matched = &c
+		}
+	}
+
+	if matched != nil {
+		p.overwriteMetadata(matched)
+		// delete command need merge status value.
+		source := reflect.ValueOf(&p.Options).Elem()
+		b, err := json.Marshal(matched.Options)
+		if err != nil {
+			return err
+		}
+		opt := &google.Options{}
+		err = json.Unmarshal(b, opt)
+		if err != nil {
+			return err
+		}
+		target := reflect.ValueOf(opt).Elem()
+		utils.MergeConfig(source, target)
+	}
+
+	return nil
+}
+
+func (p *Google) overwriteMetadata(matched *types.Cluster) {
+	// doesn't need to be overwrite.
+	p.Status = matched.Status
+	p.Token = matched.Token
+	p.IP = matched.IP
+	p.UI = matched.UI
+	p.CloudControllerManager = matched.CloudControllerManager
+	p.Cluster = matched.Cluster
+	p.ClusterCIDR = matched.ClusterCIDR
+	p.DataStore = matched.DataStore
+	p.Mirror = matched.Mirror
+	p.DockerMirror = matched.DockerMirror
+	p.InstallScript = matched.InstallScript
+	p.Network = matched.Network
+	// needed to be overwrite.
+	if p.Credential == "" {
+		p.Credential = matched.Credential
+	}
+	if p.K3sChannel == "" {
+		p.K3sChannel = matched.K3sChannel
+	}
+	if p.K3sVersion == "" {
+		p.K3sVersion = matched.K3sVersion
+	}
+	if p.InstallScript == "" {
+		p.InstallScript = matched.InstallScript
+	}
+	if p.Registry == "" {
+		p.Registry = matched.Registry
+	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.MasterExtraArgs == "" {
+		p.MasterExtraArgs = matched.MasterExtraArgs
+	}
+	if p.WorkerExtraArgs == "" {
+		p.WorkerExtraArgs = matched.WorkerExtraArgs
+	}
+}
+
+func (p *Google) sharedFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
+		{
+			Name:  "region",
+			P:     &p.Region,
+			V:     p.Region,
+			Usage: "Compute Engine region of instances, e.g.(us-central1, europe-west1)",
+		},
+		{
+			Name:  "zone",
+			P:     &p.Zone,
+			V:     p.Zone,
+			Usage: "Compute Engine zone of instances in region, e.g.(us-central1-a, europe-west1-b)",
+		},
+		{
+			Name:  "project",
+			P:     &p.Project,
+			V:     p.Project,
+			Usage: "ID of Google Cloud project, the project of service account is used if not specified",
+		},
+		{
+			Name:  "machine-type",
+			P:     &p.MachineType,
+			V:     p.MachineType,
+			Usage: "Machine type of instances, e.g.(e2-medium, n2-standard-2)",
+		},
+		{
+			Name:  "image",
+			P:     &p.Image,
+			V:     p.Image,
+			Usage: "Source image of boot disk of instances, e.g.(projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts)",
+		},
+		{
+			Name:  "disk-size",
+			P:     &p.DiskSize,
+			V:     p.DiskSize,
+			Usage: "Size of boot disk of instances in GB",
+		},
+		{
+			Name:  "vpc",
+			P:     &p.VPC,
+			V:     p.VPC,
+			Usage: "Name of an existing vpc network of instances",
+		},
+		{
+			Name:  "subnetwork",
+			P:     &p.Subnetwork,
+			V:     p.Subnetwork,
+			Usage: "Name of an existing subnetwork in region, required by vpc networks in custom subnet mode",
+		},
+		{
+			Name:  "ip",
+			P:     &p.IP,
+			V:     p.IP,
+			Usage: "IP of an existing k3s server",
+		},
+		{
+			Name:  "k3s-version",
+			P:     &p.K3sVersion,
+			V:     p.K3sVersion,
+			Usage: "Used to specify the version of k3s cluster, overrides k3s-channel",
+		},
+		{
+			Name:  "k3s-channel",
+			P:     &p.K3sChannel,
+			V:     p.K3sChannel,
+			Usage: "Used to specify the release channel of k3s. e.g.(stable, latest, or i.e. v1.18)",
+		},
+		{
+			Name:  "k3s-install-script",
+			P:     &p.InstallScript,
+			V:     p.InstallScript,
+			Usage: "Change the default upstream k3s install script address",
+		},
+		{
+			Name:  "cloud-controller-manager",
+			P:     &p.CloudControllerManager,
+			V:     p.CloudControllerManager,
+			Usage: "Enable cloud-controller-manager component",
+		},
+		{
+			Name:  "master-extra-args",
+			P:     &p.MasterExtraArgs,
+			V:     p.MasterExtraArgs,
+			Usage: "Master extra arguments for k3s installer, wrapped in quotes. e.g.(--master-extra-args '--no-deploy metrics-server')",
+		},
+		{
+			Name:  "worker-extra-args",
+			P:     &p.WorkerExtraArgs,
+			V:     p.WorkerExtraArgs,
+			Usage: "Worker extra arguments for k3s installer, wrapped in quotes. e.g.(--worker-extra-args '--node-taint key=value:NoExecute')",
+		},
+		{
+			Name:  "registry",
+			P:     &p.Registry,
+			V:     p.Registry,
+			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
+		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "admin-cidrs",
+			P:     &p.AdminCIDRs,
+			V:     p.AdminCIDRs,
+			Usage: "CIDRs allowed to access ssh, kube api-server and ui of the firewall created by autok3s, separated by comma, default is 0.0.0.0/0",
+		},
+		{
+			Name:  "firewall-rules",
+			P:     &p.FirewallRules,
+			V:     p.FirewallRules,
+			Usage: "Extra inbound rules of the firewall in the format of <protocol>:<port>[-<port>]:<cidr>, separated by comma. e.g.(--firewall-rules 'tcp:30000-32767:0.0.0.0/0')",
+		},
+		{
+			Name:  "datastore",
+			P:     &p.DataStore,
+			V:     p.DataStore,
+			Usage: "K3s datastore, HA mode `create/join` master node needed this flag",
+		},
+		{
+			Name:  "token",
+			P:     &p.Token,
+			V:     p.Token,
+			Usage: "K3s master token, if empty will automatically generated",
+		},
+		{
+			Name:  "master",
+			P:     &p.Master,
+			V:     p.Master,
+			Usage: "Number of master node",
+		},
+		{
+			Name:  "worker",
+			P:     &p.Worker,
+			V:     p.Worker,
+			Usage: "Number of worker node",
+		},
+	}
+
+	return fs
+}
@@ -0,0 +1,419 @@
+package google
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typesgoogle "github.com/cnrancher/autok3s/pkg/types/google"
+	"github.com/cnrancher/autok3s/pkg/utils"
+	"github.com/cnrancher/autok3s/pkg/viper"
+
+	"golang.org/x/sync/syncmap"
+)
+
+const (
+	providerName = "google"
+
+	defaultUser            = "ubuntu"
+	defaultRegion          = "us-central1"
+	defaultZone            = "us-central1-a"
+	k3sVersion             = ""
+	k3sChannel             = "stable"
+	k3sInstallScript       = "https://get.k3s.io"
+	machineType            = "e2-medium"
+	image                  = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts"
+	diskSize               = "30"
+	vpc                    = "default"
+	master                 = "0"
+	worker                 = "0"
+	ui                     = false
+	cloudControllerManager = false
+	dockerScript           = "curl -sSL https://get.docker.com | sh - %s"
+	firewallDirection      = "INGRESS"
+)
+
+type Google struct {
+	types.Metadata      `json:",inline"`
+	typesgoogle.Options `json:",inline"`
+	types.Status        `json:"status"`
+	putil.Cloud         `json:"-"`
+
+	client computeClient
+	// sshKeys is the `ssh-keys` metadata of instances.
+	sshKeys string
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *Google {
+	p := &Google{
+		Metadata: types.Metadata{
+			Provider:               providerName,
+			Master:                 master,
+			Worker:                 worker,
+			UI:                     ui,
+			K3sVersion:             k3sVersion,
+			K3sChannel:             k3sChannel,
+			InstallScript:          k3sInstallScript,
+			Cluster:                false,
+			DockerScript:           dockerScript,
+			CloudControllerManager: cloudControllerManager,
+		},
+		Options: typesgoogle.Options{
+			Region:      defaultRegion,
+			Zone:        defaultZone,
+			MachineType: machineType,
+			Image:       image,
+			DiskSize:    diskSize,
+			VPC:         vpc,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+	}
+	p.Cloud = putil.Cloud{
+		Driver:        p,
+		Metadata:      &p.Metadata,
+		Options:       &p.Options,
+		Status:        &p.Status,
+		RunningStatus: typesgoogle.StatusRunning,
+		StoppedStatus: typesgoogle.StatusTerminated,
+		Nodes:         new(syncmap.Map),
+	}
+	return p
+}
+
+func (p *Google) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Google) GetClusterName() string {
+	return p.Name
+}
+
+func (p *Google) GetRegion() string {
+	return p.Region
+}
+
+func (p *Google) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.GetProviderName())
+}
+
+func (p *Google) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	extraArgs := ""
+	// nodes communicate with each other through the private network, whose interface isn't the default route of instances.
+	if len(master.InternalIPAddress) > 0 && master.InternalIPAddress[0] != master.ExternalIP() {
+		ip := master.InternalIPAddress[0]
+		extraArgs += fmt.Sprintf(" --node-ip %s --flannel-iface='$(ip -o -4 addr show to %s | cut -d\" \" -f2)'", ip, ip)
+	}
+	if option, ok := cluster.Options.(typesgoogle.Options); ok && cluster.CloudControllerManager {
+		extraArgs += fmt.Sprintf(" --kubelet-arg=cloud-provider=external --kubelet-arg=provider-id=gce://%s/%s/%s", project(option), option.Zone, master.InstanceID)
+	}
+	return extraArgs
+}
+
+func (p *Google) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *Google) CreateCheck(ssh *types.SSH) error {
+	if !strings.HasPrefix(p.Zone, p.Region+"-") {
+		return fmt.Errorf("[%s] calling preflight error: zone `%s` is not in region `%s`", p.GetProviderName(), p.Zone, p.Region)
+	}
+	return p.Cloud.CreateCheck(ssh)
+}
+
+func (p *Google) NewClient() error {
+	if p.ServiceAccount == "" {
+		p.ServiceAccount = viper.GetCredential(p.GetProviderName(), p.Credential, "service-account")
+	}
+	client, err := newComputeClient(p.ServiceAccount, p.Project)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to create compute engine client: %v", p.GetProviderName(), err)
+	}
+	p.client = client
+	return nil
+}
+
+// PrepareResources generates the `ssh-keys` metadata of instances, with which the user is created and authorized by the public key.
+func (p *Google) PrepareResources(ssh *types.SSH) error {
+	if ssh.SSHKeyPath == "" {
+		keyPath := common.GetDefaultSSHKeyPath(p.Name, p.GetProviderName())
+		if _, err := os.Stat(keyPath); err == nil {
+			ssh.SSHKeyPath = keyPath
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	publicKey, err := putil.CreateKeyPair(ssh, p.GetProviderName(), p.Name, "")
+	if err != nil {
+		return fmt.Errorf("[%s] failed to read public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	p.sshKeys = fmt.Sprintf("%s:%s", ssh.User, strings.TrimSpace(string(publicKey)))
+	return nil
+}
+
+// ConfigResources creates firewalls of cluster or reconciles them with the policy,
+// a firewall is created for each source cidr of the policy because the source ranges are shared by rules of a firewall.
+// Firewalls are applied to instances by the cluster tag, cluster ports are opened to the subnetwork of instances.
+func (p *Google) ConfigResources() error {
+	subnet := p.Subnetwork
+	if subnet == "" {
+		instances, err := p.client.ListInstances(p.Zone, p.clusterLabels())
+		if err != nil {
+			return fmt.Errorf("[%s] failed to get instances for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		}
+		for _, i := range instances {
+			if i.Subnetwork != "" {
+				subnet = i.Subnetwork
+				break
+			}
+		}
+	}
+	clusterCIDR := ""
+	if subnet != "" {
+		s, err := p.client.GetSubnetwork(p.Region, subnet)
+		if err != nil {
+			return fmt.Errorf("[%s] calling getSubnetwork error, msg: %v", p.GetProviderName(), err)
+		}
+		clusterCIDR = s.IPCidrRange
+	}
+	policy, err := putil.FirewallPolicy(p.Metadata, clusterCIDR)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+
+	existing, err := p.listFirewalls()
+	if err != nil {
+		return err
+	}
+	desired := p.firewalls(policy)
+	for _, f := range desired {
+		if _, ok := existing[f.Name]; ok {
+			if err := p.client.UpdateFirewall(f); err != nil {
+				return fmt.Errorf("[%s] calling updateFirewall error, msg: %v", p.GetProviderName(), err)
+			}
+			delete(existing, f.Name)
+			continue
+		}
+		p.Logger.Infof("[%s] creating firewall %s...\n", p.GetProviderName(), f.Name)
+		if err := p.client.InsertFirewall(f); err != nil {
+			return fmt.Errorf("[%s] calling insertFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	for name := range existing {
+		p.Logger.Infof("[%s] remove firewall %s\n", p.GetProviderName(), name)
+		if err := p.client.DeleteFirewall(name); err != nil {
+			return fmt.Errorf("[%s] calling deleteFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// firewalls translates the policy to firewalls grouped by source cidr, rules of the same protocol are merged.
+func (p *Google) firewalls(policy []putil.FirewallRule) []firewall {
+	firewalls := make([]firewall, 0)
+	index := map[string]int{}
+	for _, r := range policy {
+		i, ok := index[r.CIDR]
+		if !ok {
+			i = len(firewalls)
+			index[r.CIDR] = i
+			firewalls = append(firewalls, firewall{
+				Name:         fmt.Sprintf("%s-%d", p.clusterTag(), i),
+				Description:  fmt.Sprintf("accept for cluster %s(%s)", p.Name, putil.FirewallRuleOwner),
+				Network:      "global/networks/" + p.VPC,
+				Direction:    firewallDirection,
+				SourceRanges: []string{r.CIDR},
+				TargetTags:   []string{p.clusterTag()},
+			})
+		}
+		f := &firewalls[i]
+		protocol := strings.ToLower(r.Protocol)
+		j := 0
+		for ; j < len(f.Allowed); j++ {
+			if f.Allowed[j].IPProtocol == protocol {
+				break
+			}
+		}
+		if j == len(f.Allowed) {
+			f.Allowed = append(f.Allowed, firewallAllowed{IPProtocol: protocol})
+		}
+		if !containsString(f.Allowed[j].Ports, r.Port()) {
+			f.Allowed[j].Ports = append(f.Allowed[j].Ports, r.Port())
+		}
+	}
+	return firewalls
+}
+
+// listFirewalls returns firewalls created for cluster by name, which are named after the cluster tag and applied to it.
+func (p *Google) listFirewalls() (map[string]firewall, error) {
+	firewalls, err := p.client.ListFirewalls()
+	if err != nil {
+		return nil, fmt.Errorf("[%s] calling listFirewalls error, msg: %v", p.GetProviderName(), err)
+	}
+	owned := make(map[string]firewall)
+	for _, f := range firewalls {
+		if strings.HasPrefix(f.Name, p.clusterTag()+"-") && containsString(f.TargetTags, p.clusterTag()) {
+			owned[f.Name] = f
+		}
+	}
+	return owned, nil
+}
+
+func (p *Google) RunInstance(master bool) (*putil.Instance, error) {
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(fmt.Sprintf("%s%s-%s-%s", common.TagClusterPrefix, strings.Split(p.Name, ".")[0], role, suffix))
+	labels := p.clusterLabels()
+	labels["role"] = role
+	subnet := ""
+	if p.Subnetwork != "" {
+		subnet = fmt.Sprintf("regions/%s/subnetworks/%s", p.Region, p.Subnetwork)
+	}
+
+	p.Logger.Infof("[%s] creating instance %s...\n", p.GetProviderName(), name)
+	if err := p.client.InsertInstance(p.Zone, instanceCreateOpts{
+		Name:        name,
+		MachineType: p.MachineType,
+		Image:       p.Image,
+		DiskSizeGb:  p.DiskSize,
+		Network:     "global/networks/" + p.VPC,
+		Subnetwork:  subnet,
+		SSHKeys:     p.sshKeys,
+		Labels:      labels,
+		Tags:        []string{p.clusterTag()},
+	}); err != nil {
+		return nil, fmt.Errorf("[%s] calling insertInstance error, msg: %v", p.GetProviderName(), err)
+	}
+	return &putil.Instance{ID: name, Master: master}, nil
+}
+
+func (p *Google) ListInstances() ([]putil.Instance, error) {
+	if p.client == nil {
+		if err := p.NewClient(); err != nil {
+			return nil, err
+		}
+	}
+	instances, err := p.client.ListInstances(p.Zone, p.clusterLabels())
+	if err != nil {
+		return nil, err
+	}
+	list := make([]putil.Instance, 0, len(instances))
+	for _, i := range instances {
+		list = append(list, putil.Instance{
+			ID:        i.Name,
+			Master:    i.Labels["role"] == "master",
+			Status:    i.Status,
+			PublicIP:  i.PublicIP,
+			PrivateIP: i.PrivateIP,
+		})
+	}
+	return list, nil
+}
+
+func (p *Google) StartInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.StartInstance(p.Zone, id); err != nil {
+			return fmt.Errorf("[%s] calling startInstance error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *Google) StopInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.StopInstance(p.Zone, id); err != nil {
+			return fmt.Errorf("[%s] calling stopInstance error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveInstances deletes instances, the api returns when the delete operations are done.
+func (p *Google) RemoveInstances(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.DeleteInstance(p.Zone, id); err != nil {
+			return fmt.Errorf("[%s] calling deleteInstance error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveResources deletes the firewalls created for cluster.
+func (p *Google) RemoveResources() error {
+	firewalls, err := p.listFirewalls()
+	if err != nil {
+		return err
+	}
+	for name := range firewalls {
+		p.Logger.Infof("[%s] remove firewall %s\n", p.GetProviderName(), name)
+		if err := p.client.DeleteFirewall(name); err != nil {
+			return fmt.Errorf("[%s] calling deleteFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// CloudControllerManifest returns the manifest of gce cloud controller manager authorized by the service account key.
+func (p *Google) CloudControllerManifest() (string, error) {
+	key, err := readServiceAccount(p.ServiceAccount)
+	if err != nil {
+		return "", err
+	}
+	conf := fmt.Sprintf(gceConfTmpl, project(p.Options), p.VPC, p.clusterTag())
+	if p.Subnetwork != "" {
+		conf += fmt.Sprintf("subnetwork-name = %s\n", p.Subnetwork)
+	}
+	return fmt.Sprintf(googleCCMTmpl, base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString([]byte(conf))), nil
+}
+
+// project returns the project of instances, which is the project of service account key if it's not specified.
+func project(option typesgoogle.Options) string {
+	if option.Project != "" {
+		return option.Project
+	}
+	key, err := parseServiceAccount(option.ServiceAccount)
+	if err != nil {
+		return ""
+	}
+	return key.ProjectID
+}
+
+// clusterTag returns the network tag and label value of instances in cluster, which only allow lowercase letters, numbers and dashes.
+func (p *Google) clusterTag() string {
+	return strings.ToLower(common.TagClusterPrefix + strings.Replace(p.Name, ".", "-", -1))
+}
+
+func (p *Google) clusterLabels() map[string]string {
+	return map[string]string{
+		"autok3s": "true",
+		"cluster": p.clusterTag(),
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
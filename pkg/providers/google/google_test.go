@@ -0,0 +1,250 @@
+package google
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGoogle(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Google Provider Suite")
+}
+
+// fakeServiceAccount is the json key of service account in project "fake".
+const fakeServiceAccount = `{"type": "service_account", "project_id": "fake", "private_key": "key", "client_email": "autok3s@fake.iam.gserviceaccount.com"}`
+
+// newTestGoogle returns the provider of cluster "Fake" with the numbers of master and worker,
+// the name isn't lower case as names of resources must be.
+func newTestGoogle(master, worker string) *Google {
+	p := newProvider()
+	p.Name = "Fake"
+	p.Master = master
+	p.Worker = worker
+	p.ServiceAccount = fakeServiceAccount
+	p.Logger = fake.Logger()
+	return p
+}
+
+// loadTestGoogle returns the provider with options merged from cluster state, as commands other than create do.
+func loadTestGoogle(master, worker string) *Google {
+	p := newTestGoogle(master, worker)
+	Expect(p.MergeClusterOptions()).To(Succeed())
+	p.GenerateClusterName()
+	return p
+}
+
+var _ = Describe("Google provider with fake api", func() {
+	var api *fakeCompute
+	suite := fake.NewSuite(types.SSH{User: defaultUser, Port: "22"}, func(s *fake.Suite) {
+		api = newFakeCompute()
+		s.Replace(&newComputeClient, func(serviceAccount, project string) (computeClient, error) {
+			return api, nil
+		})
+	})
+
+	It("creates the instances labeled and tagged with the cluster tag", func() {
+		p := newTestGoogle("1", "1")
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		master := c.MasterNodes[0]
+		Expect(master.InstanceID).To(HavePrefix("autok3s-fake-master-"))
+		Expect(master.InstanceStatus).To(Equal("RUNNING"))
+		Expect(master.PublicIPAddress[0]).To(HavePrefix("203.0.113."))
+		Expect(master.InternalIPAddress[0]).To(HavePrefix("10.128.0."))
+		Expect(p.GenerateMasterExtraArgs(c, master)).To(ContainSubstring("--node-ip " + master.InternalIPAddress[0]))
+
+		Expect(p.clusterTag()).To(Equal("autok3s-fake-us-central1-google"))
+		for _, i := range api.instances {
+			Expect(i.zone).To(Equal(defaultZone))
+			Expect(i.opts.SSHKeys).To(HavePrefix(defaultUser + ":ssh-rsa "))
+			Expect(i.Labels).To(HaveKeyWithValue("cluster", p.clusterTag()))
+			Expect(i.Tags).To(ConsistOf(p.clusterTag()))
+		}
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	It("applies a firewall of each source cidr to the cluster tag", func() {
+		p := newTestGoogle("1", "0")
+		p.AdminCIDRs = "192.0.2.0/24"
+		p.GenerateClusterName()
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(api.firewalls).To(HaveLen(2))
+		admin, internal := api.firewalls[0], api.firewalls[1]
+		Expect(admin.SourceRanges).To(ConsistOf("192.0.2.0/24"))
+		Expect(admin.Allowed).To(ConsistOf(firewallAllowed{IPProtocol: "tcp", Ports: []string{"22", "6443"}}))
+		Expect(admin.TargetTags).To(ConsistOf(p.clusterTag()))
+		Expect(internal.SourceRanges).To(ConsistOf(fakeSubnetRange))
+		Expect(internal.Allowed).To(ConsistOf(
+			firewallAllowed{IPProtocol: "tcp", Ports: []string{"6443", "10250"}},
+			firewallAllowed{IPProtocol: "udp", Ports: []string{"8472"}},
+		))
+		Expect(internal.TargetTags).To(ConsistOf(p.clusterTag()))
+
+		// the new source cidr of custom rules gets a firewall when nodes are joined.
+		j := newTestGoogle("0", "1")
+		j.Status = fake.LoadStatus(c.Status)
+		j.AdminCIDRs = p.AdminCIDRs
+		j.FirewallRules = "tcp:30000-32767:0.0.0.0/0"
+		j.GenerateClusterName()
+		_, err = j.GenerateInstance(j.JoinCheck, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.firewalls).To(HaveLen(3))
+		Expect(api.firewalls[2].SourceRanges).To(ConsistOf("0.0.0.0/0"))
+		Expect(api.firewalls[2].Allowed).To(ConsistOf(firewallAllowed{IPProtocol: "tcp", Ports: []string{"30000-32767"}}))
+		Expect(api.firewalls[2].TargetTags).To(ConsistOf(p.clusterTag()))
+	})
+
+	Context("with the k3s cluster created", func() {
+		var (
+			server *fake.SSHServer
+			p      *Google
+		)
+
+		BeforeEach(func() {
+			server = suite.SSHServer()
+			p = newTestGoogle("1", "1")
+			p.Token = "fake-token"
+			p.UI = true
+			p.CloudControllerManager = true
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("deploys the cloud controller manager with the service account in the project of key", func() {
+			master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+			Expect(master.Commands()).To(ContainElement(And(
+				ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("--disable-cloud-controller"),
+				ContainSubstring(fmt.Sprintf("--kubelet-arg=provider-id=gce://fake/%s/%s", p.Zone, p.Status.MasterNodes[0].InstanceID)))))
+
+			file := filepath.Join(common.K3sManifestsDir, "cloud-controller-manager.yaml")
+			ccm, ok := master.ReadFile(file)
+			Expect(ok).To(BeTrue())
+			Expect(string(ccm)).To(ContainSubstring("key.json: " + base64.StdEncoding.EncodeToString([]byte(fakeServiceAccount))))
+			// the manifest with credentials is uploaded as a file only readable by root, instead of passed in commands.
+			Expect(master.FileMode(file)).To(Equal(os.FileMode(0600)))
+			Expect(master.Commands()).NotTo(ContainElement(ContainSubstring(base64.StdEncoding.EncodeToString(ccm))))
+			ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+			Expect(ok).To(BeTrue())
+			Expect(ui).NotTo(BeEmpty())
+		})
+
+		It("joins a worker to the cluster in state", func() {
+			masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+			j := loadTestGoogle("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).To(Succeed())
+			Expect(j.Status.WorkerNodes).To(HaveLen(2))
+			for _, n := range j.Status.WorkerNodes {
+				Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+					ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"),
+					ContainSubstring("--kubelet-arg=provider-id=gce://fake/"))))
+			}
+
+			kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("2"))
+		})
+
+		It("removes the worker failed to join by rollback and keeps the firewalls of cluster", func() {
+			server.Handle(fake.FailJoin)
+			j := loadTestGoogle("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			Expect(api.instances).To(HaveLen(3))
+
+			Expect(j.Rollback()).To(Succeed())
+			exist, ids, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeTrue())
+			Expect(ids).To(ConsistOf(p.Status.MasterNodes[0].InstanceID, p.Status.WorkerNodes[0].InstanceID))
+			Expect(api.firewalls).To(HaveLen(2))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status.Status).To(Equal(common.StatusRunning))
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+		})
+
+		It("deletes the instances and the firewalls of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(api.instances).To(BeEmpty())
+			Expect(api.firewalls).To(BeEmpty())
+			_, err := os.Stat(common.GetClusterPath(p.Name, providerName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("rejects the zone which isn't in region", func() {
+		p := newTestGoogle("1", "0")
+		p.GenerateClusterName()
+		p.Zone = "europe-west1-b"
+		Expect(p.CreateCheck(suite.SSH)).To(MatchError(ContainSubstring("is not in region")))
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTestGoogle("0", "1")
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(p.JoinCheck, suite.SSH)
+		Expect(err).To(HaveOccurred())
+		Expect(api.instances).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Google service account", func() {
+	It("is parsed from the json key or key file", func() {
+		content := fakeServiceAccount
+		key, err := parseServiceAccount(content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.ProjectID).To(Equal("fake"))
+
+		dir, err := ioutil.TempDir("", "autok3s")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "key.json")
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		key, err = parseServiceAccount(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.ClientEmail).To(Equal("autok3s@fake.iam.gserviceaccount.com"))
+
+		_, err = parseServiceAccount(`{"type": "authorized_user"}`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("authorizes the cloud controller manager in the project of key", func() {
+		content := fakeServiceAccount
+		p := newProvider()
+		p.Name = "fake.us-central1.google"
+		p.ServiceAccount = content
+		p.Subnetwork = "fake-subnet"
+		manifest, err := p.CloudControllerManifest()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest).To(ContainSubstring("key.json: " + base64.StdEncoding.EncodeToString([]byte(content))))
+		conf := "[global]\nproject-id = fake\nnetwork-name = default\nnode-tags = autok3s-fake-us-central1-google\ntoken-url = nil\nsubnetwork-name = fake-subnet\n"
+		Expect(manifest).To(ContainSubstring("gce.conf: " + base64.StdEncoding.EncodeToString([]byte(conf))))
+
+		_, err = newProvider().CloudControllerManifest()
+		Expect(err).To(HaveOccurred())
+	})
+})
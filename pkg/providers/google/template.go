@@ -0,0 +1,100 @@
+package google
+
+// gceConfTmpl is the cloud config of gce cloud provider formatted with project, network and the network tag of instances,
+// `token-url = nil` makes the cloud provider authorized by the service account key of GOOGLE_APPLICATION_CREDENTIALS.
+const gceConfTmpl = `[global]
+project-id = %s
+network-name = %s
+node-tags = %s
+token-url = nil
+`
+
+// googleCCMTmpl is formatted with base64 encoded service account key and cloud config,
+// routes are not managed because pods network is provided by flannel.
+const googleCCMTmpl = `
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: gce
+  namespace: kube-system
+type: Opaque
+data:
+  key.json: %s
+  gce.conf: %s
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: ServiceAccount
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gce-cloud-controller-manager
+  namespace: kube-system
+spec:
+  replicas: 1
+  revisionHistoryLimit: 2
+  selector:
+    matchLabels:
+      app: gce-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        app: gce-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      dnsPolicy: Default
+      hostNetwork: true
+      tolerations:
+      - key: node.cloudprovider.kubernetes.io/uninitialized
+        value: "true"
+        effect: NoSchedule
+      - key: CriticalAddonsOnly
+        operator: Exists
+      - key: node-role.kubernetes.io/master
+        effect: NoSchedule
+      - key: node.kubernetes.io/not-ready
+        effect: NoSchedule
+      containers:
+      - name: gce-cloud-controller-manager
+        image: registry.k8s.io/cloud-provider-gcp/cloud-controller-manager:v27.1.6
+        command:
+        - /usr/local/bin/cloud-controller-manager
+        - --cloud-provider=gce
+        - --cloud-config=/etc/gce/gce.conf
+        - --leader-elect=false
+        - --allocate-node-cidrs=false
+        - --configure-cloud-routes=false
+        - --controllers=cloud-node,cloud-node-lifecycle,service
+        resources:
+          requests:
+            cpu: 100m
+            memory: 50Mi
+        env:
+        - name: GOOGLE_APPLICATION_CREDENTIALS
+          value: /etc/gce/key.json
+        volumeMounts:
+        - name: gce
+          mountPath: /etc/gce
+          readOnly: true
+      volumes:
+      - name: gce
+        secret:
+          secretName: gce
+`
@@ -0,0 +1,435 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiEndpoint = "https://api.hetzner.cloud/v1"
+
+// hcloudClient is the subset of Hetzner Cloud API used by the provider, which is replaced by an in-memory fake in tests.
+type hcloudClient interface {
+	CreateServer(opts serverCreateOpts) (*server, error)
+	ListServers(labels map[string]string) ([]server, error)
+	PowerOnServer(id int) error
+	ShutdownServer(id int) error
+	DeleteServer(id int) error
+
+	GetSSHKeyByFingerprint(fingerprint string) (*sshKey, error)
+	ListSSHKeys(labels map[string]string) ([]sshKey, error)
+	CreateSSHKey(name, publicKey string, labels map[string]string) (*sshKey, error)
+	DeleteSSHKey(id int) error
+
+	GetFirewall(name string) (*firewall, error)
+	ListFirewalls(labels map[string]string) ([]firewall, error)
+	CreateFirewall(name string, rules []firewallRule, labels map[string]string) (*firewall, error)
+	SetFirewallRules(id int, rules []firewallRule) error
+	DeleteFirewall(id int) error
+
+	GetNetwork(name string) (*network, error)
+	ListNetworks(labels map[string]string) ([]network, error)
+	CreateNetwork(opts networkCreateOpts) (*network, error)
+	DeleteNetwork(id int) error
+
+	GetLocation(name string) (*location, error)
+}
+
+// newHcloudClient creates the client authorized by api token, tests replace it to run the provider without Hetzner Cloud.
+var newHcloudClient = func(token string) (hcloudClient, error) {
+	if token == "" {
+		return nil, fmt.Errorf("api token is required")
+	}
+	return &restClient{
+		endpoint: apiEndpoint,
+		token:    token,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// server is the Hetzner Cloud server with addresses flattened.
+type server struct {
+	ID        int
+	Name      string
+	Status    string
+	Labels    map[string]string
+	PublicIP  string
+	PrivateIP string
+}
+
+type serverCreateOpts struct {
+	Name       string            `json:"name"`
+	ServerType string            `json:"server_type"`
+	Image      string            `json:"image"`
+	Location   string            `json:"location,omitempty"`
+	SSHKeys    []int             `json:"ssh_keys,omitempty"`
+	Networks   []int             `json:"networks,omitempty"`
+	Firewalls  []serverFirewall  `json:"firewalls,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type serverFirewall struct {
+	Firewall int `json:"firewall"`
+}
+
+type sshKey struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Fingerprint string            `json:"fingerprint"`
+	PublicKey   string            `json:"public_key"`
+	Labels      map[string]string `json:"labels"`
+}
+
+type firewall struct {
+	ID     int               `json:"id"`
+	Name   string            `json:"name"`
+	Rules  []firewallRule    `json:"rules"`
+	Labels map[string]string `json:"labels"`
+}
+
+type firewallRule struct {
+	Direction   string   `json:"direction"`
+	Protocol    string   `json:"protocol"`
+	Port        string   `json:"port,omitempty"`
+	SourceIPs   []string `json:"source_ips"`
+	Description string   `json:"description,omitempty"`
+}
+
+type network struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	IPRange string            `json:"ip_range"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type networkCreateOpts struct {
+	Name    string            `json:"name"`
+	IPRange string            `json:"ip_range"`
+	Subnets []networkSubnet   `json:"subnets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+type networkSubnet struct {
+	Type        string `json:"type"`
+	NetworkZone string `json:"network_zone"`
+	IPRange     string `json:"ip_range"`
+}
+
+type location struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	NetworkZone string `json:"network_zone"`
+}
+
+// apiServer is the server returned by api.
+type apiServer struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	Labels    map[string]string `json:"labels"`
+	PublicNet struct {
+		IPv4 struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+	} `json:"public_net"`
+	PrivateNet []struct {
+		Network int    `json:"network"`
+		IP      string `json:"ip"`
+	} `json:"private_net"`
+}
+
+func (s apiServer) convert() server {
+	v := server{
+		ID:       s.ID,
+		Name:     s.Name,
+		Status:   s.Status,
+		Labels:   s.Labels,
+		PublicIP: s.PublicNet.IPv4.IP,
+	}
+	if len(s.PrivateNet) > 0 {
+		v.PrivateIP = s.PrivateNet[0].IP
+	}
+	return v
+}
+
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type pagination struct {
+	Meta struct {
+		Pagination struct {
+			NextPage int `json:"next_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+// restClient calls Hetzner Cloud api, see: https://docs.hetzner.cloud.
+type restClient struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func (c *restClient) CreateServer(opts serverCreateOpts) (*server, error) {
+	out := &struct {
+		Server apiServer `json:"server"`
+	}{}
+	if err := c.do(http.MethodPost, "/servers", nil, opts, out); err != nil {
+		return nil, err
+	}
+	s := out.Server.convert()
+	return &s, nil
+}
+
+func (c *restClient) ListServers(labels map[string]string) ([]server, error) {
+	servers := make([]server, 0)
+	err := c.list("/servers", labelSelector(labels), func(b []byte) error {
+		out := &struct {
+			Servers []apiServer `json:"servers"`
+		}{}
+		if err := json.Unmarshal(b, out); err != nil {
+			return err
+		}
+		for _, s := range out.Servers {
+			servers = append(servers, s.convert())
+		}
+		return nil
+	})
+	return servers, err
+}
+
+func (c *restClient) PowerOnServer(id int) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/servers/%d/actions/poweron", id), nil, nil, nil)
+}
+
+func (c *restClient) ShutdownServer(id int) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/servers/%d/actions/shutdown", id), nil, nil, nil)
+}
+
+func (c *restClient) DeleteServer(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/servers/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) GetSSHKeyByFingerprint(fingerprint string) (*sshKey, error) {
+	keys := make([]sshKey, 0)
+	err := c.list("/ssh_keys", url.Values{"fingerprint": {fingerprint}}, func(b []byte) error {
+		return appendItems(b, "ssh_keys", &keys)
+	})
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+func (c *restClient) ListSSHKeys(labels map[string]string) ([]sshKey, error) {
+	keys := make([]sshKey, 0)
+	err := c.list("/ssh_keys", labelSelector(labels), func(b []byte) error {
+		return appendItems(b, "ssh_keys", &keys)
+	})
+	return keys, err
+}
+
+func (c *restClient) CreateSSHKey(name, publicKey string, labels map[string]string) (*sshKey, error) {
+	out := &struct {
+		SSHKey sshKey `json:"ssh_key"`
+	}{}
+	in := map[string]interface{}{"name": name, "public_key": publicKey, "labels": labels}
+	if err := c.do(http.MethodPost, "/ssh_keys", nil, in, out); err != nil {
+		return nil, err
+	}
+	return &out.SSHKey, nil
+}
+
+func (c *restClient) DeleteSSHKey(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/ssh_keys/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) GetFirewall(name string) (*firewall, error) {
+	firewalls := make([]firewall, 0)
+	err := c.list("/firewalls", url.Values{"name": {name}}, func(b []byte) error {
+		return appendItems(b, "firewalls", &firewalls)
+	})
+	if err != nil || len(firewalls) == 0 {
+		return nil, err
+	}
+	return &firewalls[0], nil
+}
+
+func (c *restClient) ListFirewalls(labels map[string]string) ([]firewall, error) {
+	firewalls := make([]firewall, 0)
+	err := c.list("/firewalls", labelSelector(labels), func(b []byte) error {
+		return appendItems(b, "firewalls", &firewalls)
+	})
+	return firewalls, err
+}
+
+func (c *restClient) CreateFirewall(name string, rules []firewallRule, labels map[string]string) (*firewall, error) {
+	out := &struct {
+		Firewall firewall `json:"firewall"`
+	}{}
+	in := map[string]interface{}{"name": name, "rules": rules, "labels": labels}
+	if err := c.do(http.MethodPost, "/firewalls", nil, in, out); err != nil {
+		return nil, err
+	}
+	return &out.Firewall, nil
+}
+
+func (c *restClient) SetFirewallRules(id int, rules []firewallRule) error {
+	in := map[string]interface{}{"rules": rules}
+	return c.do(http.MethodPost, fmt.Sprintf("/firewalls/%d/actions/set_rules", id), nil, in, nil)
+}
+
+func (c *restClient) DeleteFirewall(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/firewalls/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) GetNetwork(name string) (*network, error) {
+	networks := make([]network, 0)
+	err := c.list("/networks", url.Values{"name": {name}}, func(b []byte) error {
+		return appendItems(b, "networks", &networks)
+	})
+	if err != nil || len(networks) == 0 {
+		return nil, err
+	}
+	return &networks[0], nil
+}
+
+func (c *restClient) ListNetworks(labels map[string]string) ([]network, error) {
+	networks := make([]network, 0)
+	err := c.list("/networks", labelSelector(labels), func(b []byte) error {
+		return appendItems(b, "networks", &networks)
+	})
+	return networks, err
+}
+
+func (c *restClient) CreateNetwork(opts networkCreateOpts) (*network, error) {
+	out := &struct {
+		Network network `json:"network"`
+	}{}
+	if err := c.do(http.MethodPost, "/networks", nil, opts, out); err != nil {
+		return nil, err
+	}
+	return &out.Network, nil
+}
+
+func (c *restClient) DeleteNetwork(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/networks/%d", id), nil, nil, nil)
+}
+
+func (c *restClient) GetLocation(name string) (*location, error) {
+	locations := make([]location, 0)
+	err := c.list("/locations", url.Values{"name": {name}}, func(b []byte) error {
+		return appendItems(b, "locations", &locations)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("location %s is not found", name)
+	}
+	return &locations[0], nil
+}
+
+// list requests all pages of the resources, each page is decoded by fn.
+func (c *restClient) list(path string, query url.Values, fn func(b []byte) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "50")
+	for page := 1; page > 0; {
+		query.Set("page", strconv.Itoa(page))
+		var b json.RawMessage
+		if err := c.do(http.MethodGet, path, query, nil, &b); err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+		p := &pagination{}
+		if err := json.Unmarshal(b, p); err != nil {
+			return err
+		}
+		page = p.Meta.Pagination.NextPage
+	}
+	return nil
+}
+
+func (c *restClient) do(method, path string, query url.Values, in, out interface{}) error {
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &apiError{}
+		if err := json.Unmarshal(b, e); err == nil && e.Error.Code != "" {
+			return fmt.Errorf("%s %s: %s (%s)", method, path, e.Error.Message, e.Error.Code)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// appendItems appends the list of resources in the response by the key to items, which is a pointer of slice.
+func appendItems(b []byte, key string, items interface{}) error {
+	out := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(items).Elem()
+	page := reflect.New(v.Type())
+	if err := json.Unmarshal(out[key], page.Interface()); err != nil {
+		return err
+	}
+	v.Set(reflect.AppendSlice(v, page.Elem()))
+	return nil
+}
+
+// labelSelector returns the query to filter resources by labels.
+func labelSelector(labels map[string]string) url.Values {
+	selectors := make([]string, 0, len(labels))
+	for k, v := range labels {
+		selectors = append(selectors, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(selectors)
+	return url.Values{"label_selector": {strings.Join(selectors, ",")}}
+}
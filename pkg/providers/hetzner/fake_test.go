@@ -0,0 +1,280 @@
+package hetzner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/hetzner"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// fakeHcloud is an in-memory Hetzner Cloud project, servers are running with addresses assigned once created.
+type fakeHcloud struct {
+	mu sync.Mutex
+
+	seq       int
+	servers   []*fakeServer
+	keys      []*sshKey
+	firewalls []*firewall
+	networks  []*network
+}
+
+type fakeServer struct {
+	server
+	opts serverCreateOpts
+}
+
+func newFakeHcloud() *fakeHcloud {
+	return &fakeHcloud{}
+}
+
+func (f *fakeHcloud) nextID() int {
+	f.seq++
+	return f.seq
+}
+
+func (f *fakeHcloud) CreateServer(opts serverCreateOpts) (*server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.servers {
+		if s.Name == opts.Name {
+			return nil, fmt.Errorf("server name is already used: %s", opts.Name)
+		}
+	}
+	id := f.nextID()
+	s := &fakeServer{
+		server: server{
+			ID:        id,
+			Name:      opts.Name,
+			Status:    hetzner.StatusRunning,
+			Labels:    opts.Labels,
+			PublicIP:  fmt.Sprintf("203.0.113.%d", id),
+			PrivateIP: fmt.Sprintf("10.0.0.%d", id),
+		},
+		opts: opts,
+	}
+	f.servers = append(f.servers, s)
+	v := s.server
+	return &v, nil
+}
+
+func (f *fakeHcloud) ListServers(labels map[string]string) ([]server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	servers := make([]server, 0)
+	for _, s := range f.servers {
+		if matchLabels(s.Labels, labels) {
+			servers = append(servers, s.server)
+		}
+	}
+	return servers, nil
+}
+
+func (f *fakeHcloud) PowerOnServer(id int) error {
+	return f.setStatus(id, hetzner.StatusRunning)
+}
+
+func (f *fakeHcloud) ShutdownServer(id int) error {
+	return f.setStatus(id, hetzner.StatusOff)
+}
+
+func (f *fakeHcloud) setStatus(id int, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.findServer(id)
+	if s == nil {
+		return fmt.Errorf("server not found: %d", id)
+	}
+	s.Status = status
+	return nil
+}
+
+func (f *fakeHcloud) DeleteServer(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, s := range f.servers {
+		if s.ID == id {
+			f.servers = append(f.servers[:i], f.servers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("server not found: %d", id)
+}
+
+func (f *fakeHcloud) GetSSHKeyByFingerprint(fingerprint string) (*sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, k := range f.keys {
+		if k.Fingerprint == fingerprint {
+			v := *k
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeHcloud) ListSSHKeys(labels map[string]string) ([]sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]sshKey, 0)
+	for _, k := range f.keys {
+		if matchLabels(k.Labels, labels) {
+			keys = append(keys, *k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeHcloud) CreateSSHKey(name, publicKey string, labels map[string]string) (*sshKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	fingerprint := gossh.FingerprintLegacyMD5(pub)
+	for _, k := range f.keys {
+		if k.Fingerprint == fingerprint {
+			return nil, fmt.Errorf("ssh key is not unique: %s", fingerprint)
+		}
+	}
+	k := &sshKey{ID: f.nextID(), Name: name, Fingerprint: fingerprint, PublicKey: publicKey, Labels: labels}
+	f.keys = append(f.keys, k)
+	v := *k
+	return &v, nil
+}
+
+func (f *fakeHcloud) DeleteSSHKey(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, k := range f.keys {
+		if k.ID == id {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("ssh key not found: %d", id)
+}
+
+func (f *fakeHcloud) GetFirewall(name string) (*firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fw := range f.firewalls {
+		if fw.Name == name {
+			v := *fw
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeHcloud) ListFirewalls(labels map[string]string) ([]firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firewalls := make([]firewall, 0)
+	for _, fw := range f.firewalls {
+		if matchLabels(fw.Labels, labels) {
+			firewalls = append(firewalls, *fw)
+		}
+	}
+	return firewalls, nil
+}
+
+func (f *fakeHcloud) CreateFirewall(name string, rules []firewallRule, labels map[string]string) (*firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fw := &firewall{ID: f.nextID(), Name: name, Rules: rules, Labels: labels}
+	f.firewalls = append(f.firewalls, fw)
+	v := *fw
+	return &v, nil
+}
+
+func (f *fakeHcloud) SetFirewallRules(id int, rules []firewallRule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fw := range f.firewalls {
+		if fw.ID == id {
+			fw.Rules = rules
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %d", id)
+}
+
+func (f *fakeHcloud) DeleteFirewall(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.servers {
+		for _, fw := range s.opts.Firewalls {
+			if fw.Firewall == id {
+				return fmt.Errorf("firewall %d is still in use", id)
+			}
+		}
+	}
+	for i, fw := range f.firewalls {
+		if fw.ID == id {
+			f.firewalls = append(f.firewalls[:i], f.firewalls[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("firewall not found: %d", id)
+}
+
+func (f *fakeHcloud) GetNetwork(name string) (*network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range f.networks {
+		if n.Name == name {
+			v := *n
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeHcloud) ListNetworks(labels map[string]string) ([]network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	networks := make([]network, 0)
+	for _, n := range f.networks {
+		if matchLabels(n.Labels, labels) {
+			networks = append(networks, *n)
+		}
+	}
+	return networks, nil
+}
+
+func (f *fakeHcloud) CreateNetwork(opts networkCreateOpts) (*network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := &network{ID: f.nextID(), Name: opts.Name, IPRange: opts.IPRange, Labels: opts.Labels}
+	f.networks = append(f.networks, n)
+	v := *n
+	return &v, nil
+}
+
+func (f *fakeHcloud) DeleteNetwork(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, n := range f.networks {
+		if n.ID == id {
+			f.networks = append(f.networks[:i], f.networks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("network not found: %d", id)
+}
+
+func (f *fakeHcloud) GetLocation(name string) (*location, error) {
+	return &location{ID: 1, Name: name, NetworkZone: "eu-central"}, nil
+}
+
+func (f *fakeHcloud) findServer(id int) *fakeServer {
+	for _, s := range f.servers {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
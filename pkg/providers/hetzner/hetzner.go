@@ -0,0 +1,467 @@
+package hetzner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typeshetzner "github.com/cnrancher/autok3s/pkg/types/hetzner"
+	"github.com/cnrancher/autok3s/pkg/utils"
+	"github.com/cnrancher/autok3s/pkg/viper"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/sync/syncmap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	providerName = "hetzner"
+
+	defaultUser            = "root"
+	defaultLocation        = "nbg1"
+	k3sVersion             = ""
+	k3sChannel             = "stable"
+	k3sInstallScript       = "https://get.k3s.io"
+	serverType             = "cx21"
+	image                  = "ubuntu-20.04"
+	networkIPRange         = "10.0.0.0/16"
+	subnetIPRange          = "10.0.0.0/24"
+	master                 = "0"
+	worker                 = "0"
+	ui                     = false
+	cloudControllerManager = false
+	dockerScript           = "curl -sSL https://get.docker.com | sh - %s"
+)
+
+type Hetzner struct {
+	types.Metadata       `json:",inline"`
+	typeshetzner.Options `json:",inline"`
+	types.Status         `json:"status"`
+	putil.Cloud          `json:"-"`
+
+	client hcloudClient
+	// ids of the ssh key, private network and firewall which servers are created with.
+	keyID      int
+	networkID  int
+	firewallID int
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *Hetzner {
+	p := &Hetzner{
+		Metadata: types.Metadata{
+			Provider:               providerName,
+			Master:                 master,
+			Worker:                 worker,
+			UI:                     ui,
+			CloudControllerManager: cloudControllerManager,
+			K3sVersion:             k3sVersion,
+			K3sChannel:             k3sChannel,
+			InstallScript:          k3sInstallScript,
+			Cluster:                false,
+			DockerScript:           dockerScript,
+		},
+		Options: typeshetzner.Options{
+			Location:   defaultLocation,
+			ServerType: serverType,
+			Image:      image,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+	}
+	p.Cloud = putil.Cloud{
+		Driver:        p,
+		Metadata:      &p.Metadata,
+		Options:       &p.Options,
+		Status:        &p.Status,
+		RunningStatus: typeshetzner.StatusRunning,
+		StoppedStatus: typeshetzner.StatusOff,
+		Nodes:         new(syncmap.Map),
+	}
+	return p
+}
+
+func (p *Hetzner) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Hetzner) GetClusterName() string {
+	return p.Name
+}
+
+func (p *Hetzner) GetRegion() string {
+	return p.Location
+}
+
+func (p *Hetzner) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Location, p.GetProviderName())
+}
+
+func (p *Hetzner) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	extraArgs := ""
+	// nodes communicate with each other through the private network, whose interface isn't the default route of servers.
+	if len(master.InternalIPAddress) > 0 && master.InternalIPAddress[0] != master.ExternalIP() {
+		ip := master.InternalIPAddress[0]
+		extraArgs += fmt.Sprintf(" --node-ip %s --flannel-iface='$(ip -o -4 addr show to %s | cut -d\" \" -f2)'", ip, ip)
+	}
+	if cluster.CloudControllerManager {
+		extraArgs += fmt.Sprintf(" --kubelet-arg=cloud-provider=external --kubelet-arg=provider-id=hcloud://%s", master.InstanceID)
+	}
+	return extraArgs
+}
+
+func (p *Hetzner) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *Hetzner) NewClient() error {
+	if p.APIToken == "" {
+		p.APIToken = viper.GetCredential(p.GetProviderName(), p.Credential, "api-token")
+	}
+	client, err := newHcloudClient(p.APIToken)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to create hetzner cloud client: %v", p.GetProviderName(), err)
+	}
+	p.client = client
+	return nil
+}
+
+// PrepareResources uploads the ssh key, and creates or reconciles the private network and firewall of servers.
+func (p *Hetzner) PrepareResources(ssh *types.SSH) error {
+	keyID, err := p.createKeyPair(ssh)
+	if err != nil {
+		return err
+	}
+	networkID, err := p.configNetwork()
+	if err != nil {
+		return err
+	}
+	firewallID, err := p.configFirewall()
+	if err != nil {
+		return err
+	}
+	p.keyID, p.networkID, p.firewallID = keyID, networkID, firewallID
+	return nil
+}
+
+// createKeyPair returns id of the ssh key injected to servers, the public key is uploaded if it doesn't exist in the project.
+func (p *Hetzner) createKeyPair(ssh *types.SSH) (int, error) {
+	if ssh.SSHKeyPath == "" {
+		keyPath := common.GetDefaultSSHKeyPath(p.Name, p.GetProviderName())
+		if _, err := os.Stat(keyPath); err == nil {
+			ssh.SSHKeyPath = keyPath
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	publicKey, err := putil.CreateKeyPair(ssh, p.GetProviderName(), p.Name, "")
+	if err != nil {
+		return 0, fmt.Errorf("[%s] failed to read public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] failed to parse public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	key, err := p.client.GetSSHKeyByFingerprint(gossh.FingerprintLegacyMD5(pub))
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling getSSHKey error, msg: %v", p.GetProviderName(), err)
+	}
+	if key != nil {
+		return key.ID, nil
+	}
+
+	p.Logger.Infof("[%s] uploading ssh key %s...\n", p.GetProviderName(), p.Name)
+	key, err = p.client.CreateSSHKey(p.Name, strings.TrimSpace(string(publicKey)), p.clusterLabels())
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling createSSHKey error, msg: %v", p.GetProviderName(), err)
+	}
+	return key.ID, nil
+}
+
+// configNetwork returns id of the private network of servers, the network is created for cluster if it's not specified.
+func (p *Hetzner) configNetwork() (int, error) {
+	name := p.PrivateNetwork
+	if name == "" {
+		name = p.Name
+	}
+	n, err := p.client.GetNetwork(name)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling getNetwork error, msg: %v", p.GetProviderName(), err)
+	}
+	if n == nil {
+		if p.PrivateNetwork != "" {
+			return 0, fmt.Errorf("[%s] calling preflight error: private network %s is not found", p.GetProviderName(), p.PrivateNetwork)
+		}
+		l, err := p.client.GetLocation(p.Location)
+		if err != nil {
+			return 0, fmt.Errorf("[%s] calling getLocation error, msg: %v", p.GetProviderName(), err)
+		}
+		p.Logger.Infof("[%s] creating private network %s...\n", p.GetProviderName(), name)
+		n, err = p.client.CreateNetwork(networkCreateOpts{
+			Name:    name,
+			IPRange: networkIPRange,
+			Subnets: []networkSubnet{{Type: "cloud", NetworkZone: l.NetworkZone, IPRange: subnetIPRange}},
+			Labels:  p.clusterLabels(),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("[%s] calling createNetwork error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	p.PrivateNetwork = n.Name
+	return n.ID, nil
+}
+
+// configFirewall returns id of the firewall of servers, the firewall is created for cluster if it's not specified,
+// and rules of the firewall owned by cluster are reconciled with the policy.
+func (p *Hetzner) configFirewall() (int, error) {
+	name := p.Firewall
+	if name == "" {
+		name = p.Name
+	}
+	f, err := p.client.GetFirewall(name)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling getFirewall error, msg: %v", p.GetProviderName(), err)
+	}
+	if f != nil && !matchLabels(f.Labels, p.clusterLabels()) {
+		// rules of the specified firewall are managed by users.
+		p.Firewall = f.Name
+		return f.ID, nil
+	}
+	if f == nil && p.Firewall != "" {
+		return 0, fmt.Errorf("[%s] calling preflight error: firewall %s is not found", p.GetProviderName(), p.Firewall)
+	}
+
+	// traffic in private network isn't filtered by firewalls, so cluster ports are only opened to the network.
+	policy, err := putil.FirewallPolicy(p.Metadata, networkIPRange)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	rules := firewallRules(policy)
+	if f == nil {
+		p.Logger.Infof("[%s] creating firewall %s...\n", p.GetProviderName(), name)
+		f, err = p.client.CreateFirewall(name, rules, p.clusterLabels())
+		if err != nil {
+			return 0, fmt.Errorf("[%s] calling createFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	} else if err := p.client.SetFirewallRules(f.ID, rules); err != nil {
+		return 0, fmt.Errorf("[%s] calling setFirewallRules error, msg: %v", p.GetProviderName(), err)
+	}
+	p.Firewall = f.Name
+	return f.ID, nil
+}
+
+func (p *Hetzner) RunInstance(master bool) (*putil.Instance, error) {
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s%s-%s-%s", common.TagClusterPrefix, strings.Split(p.Name, ".")[0], role, suffix)
+	labels := p.clusterLabels()
+	labels[role] = "true"
+
+	p.Logger.Infof("[%s] creating server %s...\n", p.GetProviderName(), name)
+	s, err := p.client.CreateServer(serverCreateOpts{
+		Name:       name,
+		ServerType: p.ServerType,
+		Image:      p.Image,
+		Location:   p.Location,
+		SSHKeys:    []int{p.keyID},
+		Networks:   []int{p.networkID},
+		Firewalls:  []serverFirewall{{Firewall: p.firewallID}},
+		Labels:     labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[%s] calling createServer error, msg: %v", p.GetProviderName(), err)
+	}
+	return &putil.Instance{ID: strconv.Itoa(s.ID), Master: master, Status: s.Status}, nil
+}
+
+// ConfigResources does nothing, the firewall is applied to servers when they are created.
+func (p *Hetzner) ConfigResources() error {
+	return nil
+}
+
+func (p *Hetzner) ListInstances() ([]putil.Instance, error) {
+	if p.client == nil {
+		if err := p.NewClient(); err != nil {
+			return nil, err
+		}
+	}
+	servers, err := p.client.ListServers(p.clusterLabels())
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]putil.Instance, 0, len(servers))
+	for _, s := range servers {
+		instances = append(instances, putil.Instance{
+			ID:        strconv.Itoa(s.ID),
+			Master:    s.Labels["master"] == "true",
+			Status:    s.Status,
+			PublicIP:  s.PublicIP,
+			PrivateIP: s.PrivateIP,
+		})
+	}
+	return instances, nil
+}
+
+func (p *Hetzner) StartInstances(ids []string) error {
+	for _, id := range ids {
+		serverID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.PowerOnServer(serverID); err != nil {
+			return fmt.Errorf("[%s] calling powerOnServer error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *Hetzner) StopInstances(ids []string) error {
+	for _, id := range ids {
+		serverID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.ShutdownServer(serverID); err != nil {
+			return fmt.Errorf("[%s] calling shutdownServer error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// RemoveInstances deletes servers and waits for them to be deleted, so that resources used by them can be deleted.
+func (p *Hetzner) RemoveInstances(ids []string) error {
+	for _, id := range ids {
+		serverID, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		if err := p.client.DeleteServer(serverID); err != nil {
+			return fmt.Errorf("[%s] calling deleteServer error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	wait.ErrWaitTimeout = fmt.Errorf("[%s] calling deleteServer error. serverID: %s, message: not deleted", p.GetProviderName(), ids)
+	return wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+		servers, err := p.client.ListServers(p.clusterLabels())
+		if err != nil {
+			return false, err
+		}
+		for _, s := range servers {
+			if containsString(ids, strconv.Itoa(s.ID)) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// RemoveResources deletes the ssh key, firewall and private network created for cluster.
+func (p *Hetzner) RemoveResources() error {
+	labels := p.clusterLabels()
+	firewalls, err := p.client.ListFirewalls(labels)
+	if err != nil {
+		return fmt.Errorf("[%s] calling listFirewalls error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, f := range firewalls {
+		p.Logger.Infof("[%s] remove firewall %s\n", p.GetProviderName(), f.Name)
+		if err := p.client.DeleteFirewall(f.ID); err != nil {
+			return fmt.Errorf("[%s] calling deleteFirewall error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	networks, err := p.client.ListNetworks(labels)
+	if err != nil {
+		return fmt.Errorf("[%s] calling listNetworks error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, n := range networks {
+		p.Logger.Infof("[%s] remove private network %s\n", p.GetProviderName(), n.Name)
+		if err := p.client.DeleteNetwork(n.ID); err != nil {
+			return fmt.Errorf("[%s] calling deleteNetwork error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	keys, err := p.client.ListSSHKeys(labels)
+	if err != nil {
+		return fmt.Errorf("[%s] calling listSSHKeys error, msg: %v", p.GetProviderName(), err)
+	}
+	for _, k := range keys {
+		p.Logger.Infof("[%s] remove ssh key %s\n", p.GetProviderName(), k.Name)
+		if err := p.client.DeleteSSHKey(k.ID); err != nil {
+			return fmt.Errorf("[%s] calling deleteSSHKey error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *Hetzner) CloudControllerManifest() (string, error) {
+	return fmt.Sprintf(hetznerCCMTmpl, base64.StdEncoding.EncodeToString([]byte(p.APIToken)),
+		base64.StdEncoding.EncodeToString([]byte(p.PrivateNetwork))), nil
+}
+
+func (p *Hetzner) clusterLabels() map[string]string {
+	return map[string]string{
+		"autok3s": "true",
+		"cluster": common.TagClusterPrefix + p.Name,
+	}
+}
+
+// firewallRules translates the policy to inbound rules of firewall, rules of the same port are merged.
+func firewallRules(policy []putil.FirewallRule) []firewallRule {
+	rules := make([]firewallRule, 0)
+	index := map[string]int{}
+	for _, r := range policy {
+		key := fmt.Sprintf("%s/%s", strings.ToLower(r.Protocol), r.Port())
+		if i, ok := index[key]; ok {
+			if !containsString(rules[i].SourceIPs, r.CIDR) {
+				rules[i].SourceIPs = append(rules[i].SourceIPs, r.CIDR)
+			}
+			continue
+		}
+		index[key] = len(rules)
+		rules = append(rules, firewallRule{
+			Direction:   "in",
+			Protocol:    strings.ToLower(r.Protocol),
+			Port:        r.Port(),
+			SourceIPs:   []string{r.CIDR},
+			Description: r.Description,
+		})
+	}
+	return rules
+}
+
+func matchLabels(labels, filters map[string]string) bool {
+	for k, v := range filters {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
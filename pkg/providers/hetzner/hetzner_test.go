@@ -0,0 +1,221 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typeshetzner "github.com/cnrancher/autok3s/pkg/types/hetzner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestHetzner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hetzner Provider Suite")
+}
+
+// newTestHetzner returns the provider of cluster "fake" with the numbers of master and worker.
+func newTestHetzner(master, worker string) *Hetzner {
+	p := newProvider()
+	p.Name = "fake"
+	p.Master = master
+	p.Worker = worker
+	p.APIToken = "token"
+	p.Logger = fake.Logger()
+	return p
+}
+
+// loadTestHetzner returns the provider with options merged from cluster state, as commands other than create do.
+func loadTestHetzner(master, worker string) *Hetzner {
+	p := newTestHetzner(master, worker)
+	Expect(p.MergeClusterOptions()).To(Succeed())
+	p.GenerateClusterName()
+	return p
+}
+
+var _ = Describe("Hetzner provider with fake api", func() {
+	var api *fakeHcloud
+	suite := fake.NewSuite(types.SSH{User: defaultUser, Port: "22"}, func(s *fake.Suite) {
+		api = newFakeHcloud()
+		s.Replace(&newHcloudClient, func(token string) (hcloudClient, error) {
+			return api, nil
+		})
+	})
+
+	It("creates the servers with the uploaded key, private network and firewall of cluster", func() {
+		p := newTestHetzner("1", "1")
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		master := c.MasterNodes[0]
+		Expect(master.InstanceStatus).To(Equal("running"))
+		Expect(master.PublicIPAddress[0]).To(HavePrefix("203.0.113."))
+		Expect(master.InternalIPAddress[0]).To(HavePrefix("10.0.0."))
+		Expect(p.GenerateMasterExtraArgs(c, master)).To(ContainSubstring("--node-ip " + master.InternalIPAddress[0]))
+
+		Expect(api.keys).To(HaveLen(1))
+		Expect(api.networks).To(HaveLen(1))
+		Expect(api.firewalls).To(HaveLen(1))
+		options := c.Options.(typeshetzner.Options)
+		Expect(options.PrivateNetwork).To(Equal(p.Name))
+		Expect(options.Firewall).To(Equal(p.Name))
+		for _, s := range api.servers {
+			Expect(s.opts.SSHKeys).To(ConsistOf(api.keys[0].ID))
+			Expect(s.opts.Networks).To(ConsistOf(api.networks[0].ID))
+			Expect(s.opts.Firewalls).To(ConsistOf(serverFirewall{Firewall: api.firewalls[0].ID}))
+			Expect(s.Labels).To(HaveKeyWithValue("cluster", common.TagClusterPrefix+p.Name))
+		}
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	It("opens the ports of cluster to the admin cidrs and the private network in firewall", func() {
+		p := newTestHetzner("1", "0")
+		p.AdminCIDRs = "192.0.2.0/24"
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+
+		owner := "(generated by autok3s for " + p.Name + ")"
+		Expect(api.firewalls).To(HaveLen(1))
+		Expect(api.firewalls[0].Rules).To(ConsistOf(
+			firewallRule{Direction: "in", Protocol: "tcp", Port: "22", SourceIPs: []string{"192.0.2.0/24"},
+				Description: "accept for ssh" + owner},
+			firewallRule{Direction: "in", Protocol: "tcp", Port: "6443", SourceIPs: []string{"192.0.2.0/24", networkIPRange},
+				Description: "accept for kube api-server" + owner},
+			firewallRule{Direction: "in", Protocol: "tcp", Port: "10250", SourceIPs: []string{networkIPRange},
+				Description: "accept for kubelet" + owner},
+			firewallRule{Direction: "in", Protocol: "udp", Port: "8472", SourceIPs: []string{networkIPRange},
+				Description: "accept for k3s vxlan" + owner},
+		))
+	})
+
+	Context("with the k3s cluster created", func() {
+		var (
+			server *fake.SSHServer
+			p      *Hetzner
+		)
+
+		BeforeEach(func() {
+			server = suite.SSHServer()
+			p = newTestHetzner("1", "1")
+			p.Token = "fake-token"
+			p.UI = true
+			p.CloudControllerManager = true
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("deploys the cloud controller manager with the api token and private network of cluster", func() {
+			master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+			Expect(master.Commands()).To(ContainElement(And(
+				ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("--disable-cloud-controller"),
+				ContainSubstring("--kubelet-arg=provider-id=hcloud://"))))
+
+			file := filepath.Join(common.K3sManifestsDir, "cloud-controller-manager.yaml")
+			ccm, ok := master.ReadFile(file)
+			Expect(ok).To(BeTrue())
+			Expect(string(ccm)).To(ContainSubstring(base64.StdEncoding.EncodeToString([]byte("token"))))
+			Expect(string(ccm)).To(ContainSubstring(base64.StdEncoding.EncodeToString([]byte(p.Name))))
+			// the manifest with credentials is uploaded as a file only readable by root, instead of passed in commands.
+			Expect(master.FileMode(file)).To(Equal(os.FileMode(0600)))
+			Expect(master.Commands()).NotTo(ContainElement(ContainSubstring(base64.StdEncoding.EncodeToString(ccm))))
+			ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+			Expect(ok).To(BeTrue())
+			Expect(ui).NotTo(BeEmpty())
+		})
+
+		It("joins a worker to the cluster in state", func() {
+			masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+			j := loadTestHetzner("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).To(Succeed())
+			Expect(j.Status.WorkerNodes).To(HaveLen(2))
+			for _, n := range j.Status.WorkerNodes {
+				Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+					ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"),
+					ContainSubstring("--kubelet-arg=provider-id=hcloud://"))))
+			}
+			Expect(api.keys).To(HaveLen(1))
+			Expect(api.networks).To(HaveLen(1))
+			Expect(api.firewalls).To(HaveLen(1))
+
+			kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Worker).To(Equal("2"))
+		})
+
+		It("removes the worker failed to join by rollback and keeps the resources of cluster", func() {
+			server.Handle(fake.FailJoin)
+			j := loadTestHetzner("0", "1")
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			Expect(api.servers).To(HaveLen(3))
+
+			Expect(j.Rollback()).To(Succeed())
+			exist, ids, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeTrue())
+			Expect(ids).To(ConsistOf(p.Status.MasterNodes[0].InstanceID, p.Status.WorkerNodes[0].InstanceID))
+			Expect(api.keys).To(HaveLen(1))
+			Expect(api.networks).To(HaveLen(1))
+			Expect(api.firewalls).To(HaveLen(1))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status.Status).To(Equal(common.StatusRunning))
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+		})
+
+		It("deletes the servers and the resources of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(api.servers).To(BeEmpty())
+			Expect(api.keys).To(BeEmpty())
+			Expect(api.networks).To(BeEmpty())
+			Expect(api.firewalls).To(BeEmpty())
+			_, err := os.Stat(common.GetClusterPath(p.Name, providerName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("keeps the firewall specified by users", func() {
+		fw, err := api.CreateFirewall("shared", nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		p := newTestHetzner("1", "0")
+		p.GenerateClusterName()
+		p.Firewall = "shared"
+		_, err = p.GenerateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.servers[0].opts.Firewalls).To(ConsistOf(serverFirewall{Firewall: fw.ID}))
+		Expect(api.firewalls).To(HaveLen(1))
+		Expect(api.firewalls[0].Rules).To(BeEmpty())
+
+		Expect(p.Rollback()).To(Succeed())
+		Expect(api.servers).To(BeEmpty())
+		Expect(api.networks).To(BeEmpty())
+		Expect(api.firewalls).To(HaveLen(1))
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTestHetzner("0", "1")
+		p.GenerateClusterName()
+		_, err := p.GenerateInstance(p.JoinCheck, suite.SSH)
+		Expect(err).To(HaveOccurred())
+		Expect(api.servers).To(BeEmpty())
+	})
+})
@@ -0,0 +1,93 @@
+package hetzner
+
+// hetznerCCMTmpl is formatted with base64 encoded api token and network, routes are not managed because pods network is provided by flannel.
+const hetznerCCMTmpl = `
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: hcloud
+  namespace: kube-system
+type: Opaque
+data:
+  token: %s
+  network: %s
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: ServiceAccount
+  name: cloud-controller-manager
+  namespace: kube-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hcloud-cloud-controller-manager
+  namespace: kube-system
+spec:
+  replicas: 1
+  revisionHistoryLimit: 2
+  selector:
+    matchLabels:
+      app: hcloud-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        app: hcloud-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      dnsPolicy: Default
+      hostNetwork: true
+      tolerations:
+      - key: node.cloudprovider.kubernetes.io/uninitialized
+        value: "true"
+        effect: NoSchedule
+      - key: CriticalAddonsOnly
+        operator: Exists
+      - key: node-role.kubernetes.io/master
+        effect: NoSchedule
+      - key: node.kubernetes.io/not-ready
+        effect: NoSchedule
+      containers:
+      - name: hcloud-cloud-controller-manager
+        image: hetznercloud/hcloud-cloud-controller-manager:v1.9.1
+        command:
+        - /bin/hcloud-cloud-controller-manager
+        - --cloud-provider=hcloud
+        - --leader-elect=false
+        - --allow-untagged-cloud
+        resources:
+          requests:
+            cpu: 100m
+            memory: 50Mi
+        env:
+        - name: NODE_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.nodeName
+        - name: HCLOUD_TOKEN
+          valueFrom:
+            secretKeyRef:
+              name: hcloud
+              key: token
+        - name: HCLOUD_NETWORK
+          valueFrom:
+            secretKeyRef:
+              name: hcloud
+              key: network
+        - name: HCLOUD_NETWORK_ROUTES_ENABLED
+          value: "false"
+`
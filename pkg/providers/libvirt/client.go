@@ -0,0 +1,199 @@
+package libvirt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// virtClient is the subset of libvirt and image operations used by the provider, which is replaced by an in-memory fake in tests.
+type virtClient interface {
+	CreateDisk(base, disk, sizeGB string) error
+	CreateSeed(seed string, userData, metaData []byte) error
+
+	DefineDomain(xml string) error
+	StartDomain(name string) error
+	ShutdownDomain(name string) error
+	DestroyDomain(name string) error
+	UndefineDomain(name string) error
+
+	ListDomains(tags map[string]string) ([]domain, error)
+	DomainAddress(name string) (string, error)
+}
+
+// newVirtClient creates the client connected to libvirt uri, tests replace it to run the provider without hypervisor.
+var newVirtClient = func(uri string) (virtClient, error) {
+	for _, bin := range []string{"virsh", "qemu-img", "genisoimage"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return nil, fmt.Errorf("%s is required to manage virtual machines: %v", bin, err)
+		}
+	}
+	return &virshClient{uri: uri}, nil
+}
+
+// domain is the virtual machine with autok3s tags.
+type domain struct {
+	Name  string
+	State string
+	Tags  map[string]string
+}
+
+// domainTags is the autok3s metadata of domain xml.
+type domainTags struct {
+	XMLName xml.Name `xml:"tags"`
+	Tags    []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"tag"`
+}
+
+// virshClient manages virtual machines with virsh, disks with qemu-img and cloud-init seeds with genisoimage.
+type virshClient struct {
+	uri string
+}
+
+func (c *virshClient) CreateDisk(base, disk, sizeGB string) error {
+	_, err := run("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", base, disk, sizeGB+"G")
+	return err
+}
+
+func (c *virshClient) CreateSeed(seed string, userData, metaData []byte) error {
+	dir, err := ioutil.TempDir("", "autok3s-seed")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// the volume id and file names are required by cloud-init NoCloud datasource.
+	if err := ioutil.WriteFile(filepath.Join(dir, "user-data"), userData, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "meta-data"), metaData, 0600); err != nil {
+		return err
+	}
+	_, err = run("genisoimage", "-output", seed, "-volid", "cidata", "-joliet", "-rock",
+		filepath.Join(dir, "user-data"), filepath.Join(dir, "meta-data"))
+	return err
+}
+
+func (c *virshClient) DefineDomain(xml string) error {
+	f, err := ioutil.TempFile("", "autok3s-domain")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(xml); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	_, err = c.virsh("define", f.Name())
+	return err
+}
+
+func (c *virshClient) StartDomain(name string) error {
+	_, err := c.virsh("start", name)
+	return err
+}
+
+func (c *virshClient) ShutdownDomain(name string) error {
+	_, err := c.virsh("shutdown", name)
+	return err
+}
+
+func (c *virshClient) DestroyDomain(name string) error {
+	if _, err := c.virsh("destroy", name); err != nil && !strings.Contains(err.Error(), "not running") {
+		return err
+	}
+	return nil
+}
+
+func (c *virshClient) UndefineDomain(name string) error {
+	_, err := c.virsh("undefine", name)
+	return err
+}
+
+func (c *virshClient) ListDomains(tags map[string]string) ([]domain, error) {
+	out, err := c.virsh("list", "--all", "--name")
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]domain, 0)
+	for _, name := range strings.Fields(out) {
+		// domains which are not created by autok3s don't have the metadata.
+		metadata, err := c.virsh("metadata", name, "--uri", metadataNamespace)
+		if err != nil {
+			continue
+		}
+		t := &domainTags{}
+		if err := xml.Unmarshal([]byte(metadata), t); err != nil {
+			continue
+		}
+		d := domain{Name: name, Tags: map[string]string{}}
+		for _, tag := range t.Tags {
+			d.Tags[tag.Key] = tag.Value
+		}
+		if !matchTags(d.Tags, tags) {
+			continue
+		}
+		state, err := c.virsh("domstate", name)
+		if err != nil {
+			return nil, err
+		}
+		d.State = strings.TrimSpace(state)
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+func (c *virshClient) DomainAddress(name string) (string, error) {
+	out, err := c.virsh("domifaddr", name, "--source", "lease")
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		// e.g. vnet0      52:54:00:8a:3f:01    ipv4         192.168.122.10/24
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 4 && fields[2] == "ipv4" {
+			ip, _, err := net.ParseCIDR(fields[3])
+			if err != nil {
+				return "", err
+			}
+			return ip.String(), nil
+		}
+	}
+	return "", nil
+}
+
+func (c *virshClient) virsh(args ...string) (string, error) {
+	return run("virsh", append([]string{"-c", c.uri}, args...)...)
+}
+
+func run(name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s: %v, %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func matchTags(tags, filters map[string]string) bool {
+	for k, v := range filters {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
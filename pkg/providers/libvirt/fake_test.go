@@ -0,0 +1,151 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/libvirt"
+)
+
+// fakeVirt is an in-memory hypervisor, virtual machines are running with ip address leased once started.
+type fakeVirt struct {
+	mu sync.Mutex
+
+	seq     int
+	disks   map[string]string
+	seeds   map[string]string
+	domains []*fakeDomain
+}
+
+type fakeDomain struct {
+	domain
+	xml string
+	ip  string
+}
+
+// fakeDomainXML is the part of domain xml read by fake hypervisor.
+type fakeDomainXML struct {
+	Name string `xml:"name"`
+	Tags []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"metadata>tags>tag"`
+}
+
+func newFakeVirt() *fakeVirt {
+	return &fakeVirt{
+		disks: map[string]string{},
+		seeds: map[string]string{},
+	}
+}
+
+func (f *fakeVirt) CreateDisk(base, disk, sizeGB string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disks[disk] = base
+	return nil
+}
+
+func (f *fakeVirt) CreateSeed(seed string, userData, metaData []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seeds[seed] = string(userData)
+	return nil
+}
+
+func (f *fakeVirt) DefineDomain(content string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := &fakeDomainXML{}
+	if err := xml.Unmarshal([]byte(content), d); err != nil {
+		return fmt.Errorf("invalid domain xml: %v", err)
+	}
+	if f.find(d.Name) != nil {
+		return fmt.Errorf("domain %s already exists", d.Name)
+	}
+	tags := map[string]string{}
+	for _, t := range d.Tags {
+		tags[t.Key] = t.Value
+	}
+	f.domains = append(f.domains, &fakeDomain{
+		domain: domain{Name: d.Name, State: libvirt.StatusStopped, Tags: tags},
+		xml:    content,
+	})
+	return nil
+}
+
+func (f *fakeVirt) StartDomain(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.find(name)
+	if d == nil {
+		return fmt.Errorf("domain not found: %s", name)
+	}
+	if d.State == libvirt.StatusRunning {
+		return fmt.Errorf("domain %s is already active", name)
+	}
+	f.seq++
+	d.State = libvirt.StatusRunning
+	d.ip = fmt.Sprintf("192.168.122.%d", f.seq+1)
+	return nil
+}
+
+func (f *fakeVirt) ShutdownDomain(name string) error {
+	return f.DestroyDomain(name)
+}
+
+func (f *fakeVirt) DestroyDomain(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.find(name)
+	if d == nil {
+		return fmt.Errorf("domain not found: %s", name)
+	}
+	d.State = libvirt.StatusStopped
+	d.ip = ""
+	return nil
+}
+
+func (f *fakeVirt) UndefineDomain(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, d := range f.domains {
+		if d.Name == name {
+			f.domains = append(f.domains[:i], f.domains[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("domain not found: %s", name)
+}
+
+func (f *fakeVirt) ListDomains(tags map[string]string) ([]domain, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	domains := make([]domain, 0)
+	for _, d := range f.domains {
+		if matchTags(d.Tags, tags) {
+			domains = append(domains, d.domain)
+		}
+	}
+	return domains, nil
+}
+
+func (f *fakeVirt) DomainAddress(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.find(name)
+	if d == nil {
+		return "", fmt.Errorf("domain not found: %s", name)
+	}
+	return d.ip, nil
+}
+
+func (f *fakeVirt) find(name string) *fakeDomain {
+	for _, d := range f.domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
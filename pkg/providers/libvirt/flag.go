@@ -0,0 +1,381 @@
+package libvirt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cnrancher/autok3s/pkg/types/libvirt"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const createUsageExample = `  autok3s -d create \
+    --provider libvirt \
+    --name <cluster name> \
+    --master 1
+`
+
+const joinUsageExample = `  autok3s -d join \
+    --provider libvirt \
+    --name <cluster name> \
+    --worker 1
+`
+
+const deleteUsageExample = `  autok3s -d delete \
+    --provider libvirt \
+    --name <cluster name>
+`
+
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider libvirt \
+    --name <cluster name> \
+    --node <virtual machine name or ip>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider libvirt \
+    --name <cluster name> \
+    --k3s-version <k3s version>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider libvirt \
+    --name <cluster name> \
+    --snapshot-name <snapshot name>
+  autok3s snapshot list \
+    --provider libvirt \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider libvirt \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider libvirt \
+    --name <cluster name> \
+    --repair
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider libvirt \
+    --name <cluster name>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider libvirt \
+    --name <cluster name>
+`
+
+const sshUsageExample = `  autok3s ssh \
+    --provider libvirt \
+    --name <cluster name>
+`
+
+func (p *Libvirt) GetUsageExample(action string) string {
+	switch action {
+	case "create":
+		return createUsageExample
+	case "join":
+		return joinUsageExample
+	case "delete":
+		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
+	case "ssh":
+		return sshUsageExample
+	default:
+		return ""
+	}
+}
+
+func (p *Libvirt) GetOptionFlags() []types.Flag {
+	fs := p.sharedFlags()
+	fs = append(fs, []types.Flag{
+		{
+			Name:  "cluster",
+			P:     &p.Cluster,
+			V:     p.Cluster,
+			Usage: "Form k3s cluster using embedded etcd (requires K8s >= 1.19)",
+		},
+	}...)
+	return fs
+}
+
+func (p *Libvirt) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:   "uri",
+			P:      &p.URI,
+			V:      p.URI,
+			Usage:  "Libvirt connection uri, e.g.(qemu:///system or qemu:///session)",
+			EnvVar: "LIBVIRT_DEFAULT_URI",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Libvirt) GetJoinFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := p.sharedFlags()
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Libvirt) GetSSHFlags(cmd *cobra.Command) *pflag.FlagSet {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:   "uri",
+			P:      &p.URI,
+			V:      p.URI,
+			Usage:  "Libvirt connection uri, e.g.(qemu:///system or qemu:///session)",
+			EnvVar: "LIBVIRT_DEFAULT_URI",
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
+}
+
+func (p *Libvirt) GetCredentialFlags() []types.Flag {
+	return []types.Flag{}
+}
+
+func (p *Libvirt) GetSSHConfig() *types.SSH {
+	ssh := &types.SSH{
+		User: defaultUser,
+		Port: "22",
+	}
+	return ssh
+}
+
+func (p *Libvirt) BindCredentialFlags() *pflag.FlagSet {
+	nfs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	return nfs
+}
+
+func (p *Libvirt) MergeClusterOptions() error {
+	clusters, err := cluster.ReadFromState(&types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+	})
+	if err != nil {
+		return err
+	}
+
+	var matched *types.Cluster
+	for _, c := range clusters {
+		if c.Provider == p.Provider && c.Name == fmt.Sprintf("%s.%s.%s", p.Name, defaultRegion, p.Provider) {
+			matched = &c
+		}
+	}
+
+	if matched != nil {
+		p.overwriteMetadata(matched)
+		// delete command need merge status value.
+		source := reflect.ValueOf(&p.Options).Elem()
+		b, err := json.Marshal(matched.Options)
+		if err != nil {
+			return err
+		}
+		opt := &libvirt.Options{}
+		err = json.Unmarshal(b, opt)
+		if err != nil {
+			return err
+		}
+		target := reflect.ValueOf(opt).Elem()
+		utils.MergeConfig(source, target)
+	}
+
+	return nil
+}
+
+func (p *Libvirt) overwriteMetadata(matched *types.Cluster) {
+	// doesn't need to be overwrite.
+	p.Status = matched.Status
+	p.Token = matched.Token
+	p.IP = matched.IP
+	p.Cluster = matched.Cluster
+	p.ClusterCIDR = matched.ClusterCIDR
+	p.DataStore = matched.DataStore
+	p.Mirror = matched.Mirror
+	p.DockerMirror = matched.DockerMirror
+	p.InstallScript = matched.InstallScript
+	p.Network = matched.Network
+	// needed to be overwrite.
+	if p.K3sChannel == "" {
+		p.K3sChannel = matched.K3sChannel
+	}
+	if p.K3sVersion == "" {
+		p.K3sVersion = matched.K3sVersion
+	}
+	if p.InstallScript == "" {
+		p.InstallScript = matched.InstallScript
+	}
+	if p.Registry == "" {
+		p.Registry = matched.Registry
+	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.MasterExtraArgs == "" {
+		p.MasterExtraArgs = matched.MasterExtraArgs
+	}
+	if p.WorkerExtraArgs == "" {
+		p.WorkerExtraArgs = matched.WorkerExtraArgs
+	}
+}
+
+func (p *Libvirt) sharedFlags() []types.Flag {
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+		{
+			Name:   "uri",
+			P:      &p.URI,
+			V:      p.URI,
+			Usage:  "Libvirt connection uri, e.g.(qemu:///system or qemu:///session)",
+			EnvVar: "LIBVIRT_DEFAULT_URI",
+		},
+		{
+			Name:  "image",
+			P:     &p.Image,
+			V:     p.Image,
+			Usage: "Url or local path of the cloud image with cloud-init, which is downloaded once and shared by clusters",
+		},
+		{
+			Name:  "cpu",
+			P:     &p.CPU,
+			V:     p.CPU,
+			Usage: "Number of virtual cpus of virtual machine",
+		},
+		{
+			Name:  "memory",
+			P:     &p.Memory,
+			V:     p.Memory,
+			Usage: "Memory of virtual machine in MiB",
+		},
+		{
+			Name:  "disk-size",
+			P:     &p.DiskSize,
+			V:     p.DiskSize,
+			Usage: "Disk size of virtual machine in GB",
+		},
+		{
+			Name:  "network-name",
+			P:     &p.NetworkName,
+			V:     p.NetworkName,
+			Usage: "Libvirt network of virtual machines, which must lease addresses by dhcp",
+		},
+		{
+			Name:  "ip",
+			P:     &p.IP,
+			V:     p.IP,
+			Usage: "IP of an existing k3s server",
+		},
+		{
+			Name:  "k3s-version",
+			P:     &p.K3sVersion,
+			V:     p.K3sVersion,
+			Usage: "Used to specify the version of k3s cluster, overrides k3s-channel",
+		},
+		{
+			Name:  "k3s-channel",
+			P:     &p.K3sChannel,
+			V:     p.K3sChannel,
+			Usage: "Used to specify the release channel of k3s. e.g.(stable, latest, or i.e. v1.18)",
+		},
+		{
+			Name:  "k3s-install-script",
+			P:     &p.InstallScript,
+			V:     p.InstallScript,
+			Usage: "Change the default upstream k3s install script address",
+		},
+		{
+			Name:  "master-extra-args",
+			P:     &p.MasterExtraArgs,
+			V:     p.MasterExtraArgs,
+			Usage: "Master extra arguments for k3s installer, wrapped in quotes. e.g.(--master-extra-args '--no-deploy metrics-server')",
+		},
+		{
+			Name:  "worker-extra-args",
+			P:     &p.WorkerExtraArgs,
+			V:     p.WorkerExtraArgs,
+			Usage: "Worker extra arguments for k3s installer, wrapped in quotes. e.g.(--worker-extra-args '--node-taint key=value:NoExecute')",
+		},
+		{
+			Name:  "registry",
+			P:     &p.Registry,
+			V:     p.Registry,
+			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
+		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "datastore",
+			P:     &p.DataStore,
+			V:     p.DataStore,
+			Usage: "K3s datastore, HA mode `create/join` master node needed this flag",
+		},
+		{
+			Name:  "token",
+			P:     &p.Token,
+			V:     p.Token,
+			Usage: "K3s master token, if empty will automatically generated",
+		},
+		{
+			Name:  "master",
+			P:     &p.Master,
+			V:     p.Master,
+			Usage: "Number of master node",
+		},
+		{
+			Name:  "worker",
+			P:     &p.Worker,
+			V:     p.Worker,
+			Usage: "Number of worker node",
+		},
+	}
+
+	return fs
+}
@@ -0,0 +1,1431 @@
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	putil "github.com/cnrancher/autok3s/pkg/providers/utils"
+	"github.com/cnrancher/autok3s/pkg/types"
+	typeslibvirt "github.com/cnrancher/autok3s/pkg/types/libvirt"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/syncmap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	providerName = "libvirt"
+
+	defaultUser      = "ubuntu"
+	defaultRegion    = "local" // virtual machines always run on the local hypervisor.
+	k3sVersion       = ""
+	k3sChannel       = "stable"
+	k3sInstallScript = "https://get.k3s.io"
+	image            = "https://cloud-images.ubuntu.com/focal/current/focal-server-cloudimg-amd64.img" // Ubuntu Server 20.04 LTS cloud image
+	cpu              = "2"
+	memory           = "2048" // MiB
+	diskSize         = "20"   // GB
+	uri              = "qemu:///system"
+	networkName      = "default"
+	master           = "0"
+	worker           = "0"
+	dockerScript     = "curl -sSL https://get.docker.com | sh - %s"
+)
+
+type Libvirt struct {
+	types.Metadata       `json:",inline"`
+	typeslibvirt.Options `json:",inline"`
+	types.Status         `json:"status"`
+
+	client virtClient
+	m      *sync.Map
+	logger *logrus.Logger
+}
+
+func init() {
+	providers.RegisterProvider(providerName, func() (providers.Provider, error) {
+		return newProvider(), nil
+	})
+}
+
+func newProvider() *Libvirt {
+	return &Libvirt{
+		Metadata: types.Metadata{
+			Provider:      providerName,
+			Master:        master,
+			Worker:        worker,
+			K3sVersion:    k3sVersion,
+			K3sChannel:    k3sChannel,
+			InstallScript: k3sInstallScript,
+			Cluster:       false,
+			DockerScript:  dockerScript,
+		},
+		Options: typeslibvirt.Options{
+			URI:         uri,
+			Image:       image,
+			CPU:         cpu,
+			Memory:      memory,
+			DiskSize:    diskSize,
+			NetworkName: networkName,
+		},
+		Status: types.Status{
+			MasterNodes: make([]types.Node, 0),
+			WorkerNodes: make([]types.Node, 0),
+		},
+		m: new(syncmap.Map),
+	}
+}
+
+type checkFun func() error
+
+func (p *Libvirt) GetProviderName() string {
+	return p.Provider
+}
+
+func (p *Libvirt) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Libvirt) GetClusterName() string {
+	return p.Name
+}
+
+func (p *Libvirt) GenerateClusterName() {
+	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, defaultRegion, p.GetProviderName())
+}
+
+func (p *Libvirt) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
+	return ""
+}
+
+func (p *Libvirt) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
+	return p.GenerateMasterExtraArgs(cluster, worker)
+}
+
+func (p *Libvirt) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		if err != nil {
+			p.logger.Errorf("[%s] failed to create cluster: %v", p.GetProviderName(), err)
+			if c == nil {
+				c = &types.Cluster{
+					Metadata: p.Metadata,
+					Options:  p.Options,
+					Status:   p.Status,
+				}
+			}
+			c.Status.Status = common.StatusFailed
+			cluster.SaveClusterState(c, common.StatusFailed)
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusCreating)))
+		}
+		if err == nil && len(p.Status.MasterNodes) > 0 {
+			p.logger.Info(common.UsageInfoTitle)
+			p.logger.Infof(common.UsageContext, p.Name)
+			p.logger.Info(common.UsagePods)
+			cluster.SaveClusterState(c, common.StatusRunning)
+			// remove creating state file and save running state
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusCreating)))
+		}
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing create logic...\n", p.GetProviderName())
+	if ssh.User == "" {
+		ssh.User = defaultUser
+	}
+	if ssh.Port == "" {
+		ssh.Port = "22"
+	}
+
+	c.Status.Status = common.StatusCreating
+	err = cluster.SaveClusterState(c, common.StatusCreating)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	c, err = p.generateInstance(func() error {
+		return nil
+	}, ssh)
+	if err != nil {
+		return err
+	}
+	c.Logger = p.logger
+	if err = cluster.InitK3sCluster(ctx, c); err != nil {
+		return err
+	}
+	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
+	if p.m == nil {
+		p.m = new(syncmap.Map)
+	}
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		if err != nil {
+			if c != nil {
+				c.Status.Status = common.StatusFailed
+				cluster.SaveClusterState(c, common.StatusFailed)
+			}
+		} else {
+			cluster.SaveClusterState(c, common.StatusRunning)
+		}
+		// remove join state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusJoin)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing join logic...\n", p.GetProviderName())
+	if ssh.User == "" {
+		ssh.User = defaultUser
+	}
+	if ssh.Port == "" {
+		ssh.Port = "22"
+	}
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusJoin)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	c, err = p.generateInstance(p.joinCheck, ssh)
+	if err != nil {
+		return err
+	}
+
+	added := &types.Cluster{
+		Metadata: c.Metadata,
+		Options:  c.Options,
+		Status:   types.Status{},
+	}
+
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		// filter the number of nodes that are not generated by current command.
+		if v.Current {
+			if v.Master {
+				added.Status.MasterNodes = append(added.Status.MasterNodes, v)
+			} else {
+				added.Status.WorkerNodes = append(added.Status.WorkerNodes, v)
+			}
+		}
+		return true
+	})
+
+	c.Logger = p.logger
+	added.Logger = p.logger
+	// join K3s node.
+	if err := cluster.JoinK3sNode(ctx, c, added); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed join logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) DeleteK3sCluster(ctx context.Context, f bool) error {
+	isConfirmed := true
+
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to delete cluster %s", p.GetProviderName(), p.Name))
+	}
+	if isConfirmed {
+		logFile, err := common.GetLogFile(p.Name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			logFile.Close()
+			// remove log file
+			os.Remove(filepath.Join(common.GetLogPath(), p.Name))
+			// remove state file
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRunning)))
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusFailed)))
+		}()
+		p.logger = common.NewLogger(common.Debug, logFile)
+		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
+		if err := p.newClient(); err != nil {
+			return err
+		}
+		if err := p.deleteCluster(f); err != nil {
+			return err
+		}
+		p.logger.Infof("[%s] successfully excuted delete cluster logic\n", p.GetProviderName())
+	}
+	return nil
+}
+
+func (p *Libvirt) RemoveK3sNode(node string, f bool) error {
+	n, ok := putil.FindNode(p.Status, node)
+	if !ok {
+		return fmt.Errorf("[%s] calling preflight error: node `%s` do not exist in cluster %s", p.GetProviderName(), node, p.Name)
+	}
+
+	isConfirmed := true
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to remove node %s from cluster %s", p.GetProviderName(), n.InstanceID, p.Name))
+	}
+	if !isConfirmed {
+		return nil
+	}
+
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing remove node logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RemoveK3sNode(c, n); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] remove virtual machine %s\n", p.GetProviderName(), n.InstanceID)
+	if err := p.removeDomains([]string{n.InstanceID}); err != nil {
+		return err
+	}
+
+	// sync master/worker count
+	p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+	p.Metadata.Master = strconv.Itoa(len(p.Status.MasterNodes))
+	p.Metadata.Worker = strconv.Itoa(len(p.Status.WorkerNodes))
+	c = &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed remove node logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) UpgradeK3sCluster(ctx context.Context, version string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		// the cluster is still running with origin version after rolled back.
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove upgrade state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusUpgrade)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing upgrade logic...\n", p.GetProviderName())
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusUpgrade)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.UpgradeK3sCluster(ctx, c, version); err != nil {
+		return err
+	}
+	p.K3sVersion = version
+
+	p.logger.Infof("[%s] successfully executed upgrade logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) SaveSnapshot(name string) (*types.Snapshot, error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing save snapshot logic...\n", p.GetProviderName())
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	snapshot, err := cluster.SaveSnapshot(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.Infof("[%s] successfully executed save snapshot logic\n", p.GetProviderName())
+	return snapshot, nil
+}
+
+func (p *Libvirt) ListSnapshots() ([]types.Snapshot, error) {
+	return cluster.ListSnapshots(&types.Cluster{Metadata: p.Metadata})
+}
+
+func (p *Libvirt) RestoreSnapshot(name string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove restore state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRestore)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing restore snapshot logic...\n", p.GetProviderName())
+
+	c.Status.Status = "restoring"
+	err = cluster.SaveClusterState(c, common.StatusRestore)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.RestoreSnapshot(c, name); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed restore snapshot logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing check logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return nil, err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return nil, err
+	}
+
+	if repair {
+		ids := make([]string, 0)
+		p.m.Range(func(key, value interface{}) bool {
+			if value.(types.Node).InstanceStatus != typeslibvirt.StatusRunning {
+				ids = append(ids, key.(string))
+			}
+			return true
+		})
+		if len(ids) > 0 {
+			p.logger.Infof("[%s] starting stopped virtual machines %s...\n", p.GetProviderName(), ids)
+			if err := p.startDomains(ids); err != nil {
+				return nil, err
+			}
+			if err := p.getInstanceStatus(typeslibvirt.StatusRunning); err != nil {
+				return nil, err
+			}
+			p.syncNodeAddresses()
+		}
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	reports, err := cluster.CheckK3sCluster(c)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if reports, err = cluster.RepairK3sCluster(c, reports); err != nil {
+			return nil, err
+		}
+		if err := cluster.SaveState(c); err != nil {
+			return nil, fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+		}
+	}
+
+	p.logger.Infof("[%s] successfully executed check logic\n", p.GetProviderName())
+	return reports, nil
+}
+
+func (p *Libvirt) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// virtual machines are created when creating cluster or joining nodes, pre-flight checks are executed on them before installing k3s.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, virtual machines don't exist until created", p.GetProviderName())
+}
+
+func (p *Libvirt) StartK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing start logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+
+	// record ip addresses, which may be changed after virtual machines started.
+	previous := make(map[string]string)
+	ids := make([]string, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, node := range nodes {
+			if len(node.PublicIPAddress) > 0 {
+				previous[node.InstanceID] = node.PublicIPAddress[0]
+			}
+			if node.InstanceStatus != typeslibvirt.StatusRunning {
+				ids = append(ids, node.InstanceID)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] starting virtual machines %s...\n", p.GetProviderName(), ids)
+		if err := p.startDomains(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(typeslibvirt.StatusRunning); err != nil {
+		return err
+	}
+	p.syncNodeAddresses()
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RefreshNodeAddresses(c, previous); err != nil {
+		return err
+	}
+
+	c.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed start logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) StopK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing stop logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+
+	if _, err := p.syncClusterInstance(p.GetSSHConfig()); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		if value.(types.Node).InstanceStatus == typeslibvirt.StatusRunning {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+	for _, id := range ids {
+		p.logger.Infof("[%s] shutting down virtual machine %s...\n", p.GetProviderName(), id)
+		if err := p.client.ShutdownDomain(id); err != nil {
+			return fmt.Errorf("[%s] calling shutdownDomain error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	if err := p.getInstanceStatus(typeslibvirt.StatusStopped); err != nil {
+		return err
+	}
+	p.syncNodeStatusWithInstance(nil)
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed stop logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Libvirt) SSHK3sNode(ssh *types.SSH, ip string) error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing ssh logic...\n", p.GetProviderName())
+	if err := p.newClient(); err != nil {
+		return err
+	}
+	domains, err := p.syncClusterInstance(ssh)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]string, len(domains))
+	if ip == "" {
+		// generate node name
+		for _, d := range domains {
+			value, ok := p.m.Load(d.Name)
+			if !ok {
+				continue
+			}
+			v := value.(types.Node)
+			if len(v.PublicIPAddress) == 0 || v.PublicIPAddress[0] == "" {
+				continue
+			}
+			instanceInfo := v.PublicIPAddress[0]
+			if v.Master {
+				instanceInfo = fmt.Sprintf("%s (master)", instanceInfo)
+			} else {
+				instanceInfo = fmt.Sprintf("%s (worker)", instanceInfo)
+			}
+			if d.State != typeslibvirt.StatusRunning {
+				instanceInfo = fmt.Sprintf("%s - Unhealthy(instance is %s)", instanceInfo, d.State)
+			}
+			ids[d.Name] = instanceInfo
+		}
+	}
+
+	// sync master/worker count
+	p.Metadata.Master = strconv.Itoa(len(p.Status.MasterNodes))
+	p.Metadata.Worker = strconv.Itoa(len(p.Status.WorkerNodes))
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	err = cluster.SaveState(c)
+
+	if err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+	if ip == "" {
+		ip = strings.Split(utils.AskForSelectItem(fmt.Sprintf("[%s] choose ssh node to connect", p.GetProviderName()), ids), " (")[0]
+	}
+
+	if ip == "" {
+		return fmt.Errorf("[%s] choose incorrect ssh node", p.GetProviderName())
+	}
+
+	// ssh K3s node.
+	if err := cluster.SSHK3sNode(ip, c, ssh); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed ssh logic\n", p.GetProviderName())
+
+	return nil
+}
+
+func (p *Libvirt) CommandNotSupport(commandName string) error {
+	return fmt.Errorf("[%s] dose not support command: [%s]", p.GetProviderName(), commandName)
+}
+
+func (p *Libvirt) IsClusterExist() (bool, []string, error) {
+	ids := make([]string, 0)
+
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return false, ids, err
+		}
+	}
+
+	domains, err := p.client.ListDomains(p.clusterTags())
+	if err != nil {
+		return false, ids, err
+	}
+	for _, d := range domains {
+		ids = append(ids, d.Name)
+	}
+
+	return len(ids) > 0, ids, nil
+}
+
+func (p *Libvirt) Rollback() error {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing rollback logic...\n", p.GetProviderName())
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		if v.RollBack {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+
+	p.logger.Debugf("[%s] virtual machines %s will be rollback\n", p.GetProviderName(), ids)
+
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return err
+		}
+	}
+	if err := p.removeDomains(ids); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed rollback logic\n", p.GetProviderName())
+
+	return logFile.Close()
+}
+
+func (p *Libvirt) DescribeCluster(kubecfg string) *types.ClusterInfo {
+	p.logger = common.NewLogger(common.Debug, nil)
+	c := &types.ClusterInfo{
+		Name:     strings.Split(p.Name, ".")[0],
+		Region:   defaultRegion,
+		Provider: p.GetProviderName(),
+	}
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Status = types.ClusterStatusUnknown
+		c.Version = types.ClusterStatusUnknown
+		return c
+	}
+	c.Status = cluster.GetClusterStatus(client)
+
+	domains, err := p.listDomains()
+	if err != nil || len(domains) == 0 {
+		p.logger.Errorf("[%s] failed to get virtual machines for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Master = "0"
+		c.Worker = "0"
+		return c
+	}
+	instanceNodes := make([]types.ClusterNode, 0)
+	masterCount := 0
+	workerCount := 0
+	for _, d := range domains {
+		n := types.ClusterNode{
+			InstanceID:              d.Name,
+			InstanceStatus:          d.State,
+			Status:                  types.ClusterStatusUnknown,
+			ContainerRuntimeVersion: types.ClusterStatusUnknown,
+			Version:                 types.ClusterStatusUnknown,
+		}
+		// addresses are only leased to running virtual machines.
+		if d.State == typeslibvirt.StatusRunning {
+			if ip, err := p.client.DomainAddress(d.Name); err == nil && ip != "" {
+				n.InternalIP = []string{ip}
+				n.ExternalIP = []string{ip}
+			}
+		}
+		if d.Tags["master"] == "true" {
+			masterCount++
+		} else {
+			workerCount++
+		}
+		instanceNodes = append(instanceNodes, n)
+	}
+
+	c.Master = strconv.Itoa(masterCount)
+	c.Worker = strconv.Itoa(workerCount)
+	c.Nodes = instanceNodes
+	if c.Status == types.ClusterStatusRunning {
+		c.Version = cluster.GetClusterVersion(client)
+		nodes, err := cluster.DescribeClusterNodes(client, instanceNodes)
+		if err != nil {
+			p.logger.Errorf("[%s] failed to list nodes of cluster %s: %v", p.GetProviderName(), p.Name, err)
+			return c
+		}
+		c.Nodes = nodes
+	} else {
+		c.Version = types.ClusterStatusUnknown
+	}
+	return c
+}
+
+func (p *Libvirt) GetCluster(kubecfg string) *types.ClusterInfo {
+	p.logger = common.NewLogger(common.Debug, nil)
+	c := &types.ClusterInfo{
+		Name:     p.Name,
+		Region:   defaultRegion,
+		Provider: p.GetProviderName(),
+	}
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Status = types.ClusterStatusUnknown
+		c.Version = types.ClusterStatusUnknown
+		return c
+	}
+	c.Status = cluster.GetClusterStatus(client)
+	if c.Status == types.ClusterStatusRunning {
+		c.Version = cluster.GetClusterVersion(client)
+	} else {
+		c.Version = types.ClusterStatusUnknown
+	}
+
+	domains, err := p.listDomains()
+	if err != nil || len(domains) == 0 {
+		p.logger.Errorf("[%s] failed to get virtual machines for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Master = "0"
+		c.Worker = "0"
+		return c
+	}
+	masterCount := 0
+	workerCount := 0
+	for _, d := range domains {
+		if d.Tags["master"] == "true" {
+			masterCount++
+		} else {
+			workerCount++
+		}
+	}
+
+	c.Master = strconv.Itoa(masterCount)
+	c.Worker = strconv.Itoa(workerCount)
+
+	return c
+}
+
+func (p *Libvirt) GetClusterConfig() (map[string]schemas.Field, error) {
+	config := p.GetSSHConfig()
+	sshConfig, err := utils.ConvertToFields(*config)
+	if err != nil {
+		return nil, err
+	}
+	metaConfig, err := utils.ConvertToFields(p.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range sshConfig {
+		metaConfig[k] = v
+	}
+	return metaConfig, nil
+}
+
+func (p *Libvirt) GetProviderOption() (map[string]schemas.Field, error) {
+	return utils.ConvertToFields(p.Options)
+}
+
+func (p *Libvirt) SetConfig(config []byte) error {
+	c := types.Cluster{}
+	err := json.Unmarshal(config, &c)
+	if err != nil {
+		return err
+	}
+	sourceMeta := reflect.ValueOf(&p.Metadata).Elem()
+	targetMeta := reflect.ValueOf(&c.Metadata).Elem()
+	utils.MergeConfig(sourceMeta, targetMeta)
+	sourceOption := reflect.ValueOf(&p.Options).Elem()
+	b, err := json.Marshal(c.Options)
+	if err != nil {
+		return err
+	}
+	opt := &typeslibvirt.Options{}
+	err = json.Unmarshal(b, opt)
+	if err != nil {
+		return err
+	}
+	targetOption := reflect.ValueOf(opt).Elem()
+	utils.MergeConfig(sourceOption, targetOption)
+
+	return nil
+}
+
+func (p *Libvirt) CreateCheck(ssh *types.SSH) error {
+	if err := cluster.CheckAirGap(p.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	masterNum, err := strconv.Atoi(p.Master)
+	if masterNum < 1 || err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` number must >= 1",
+			p.GetProviderName())
+	}
+	if masterNum > 1 && !p.Cluster && p.DataStore == "" {
+		return fmt.Errorf("[%s] calling preflight error: need to set `--cluster` or `--datastore` when `--master` number > 1",
+			p.GetProviderName())
+	}
+	if _, err := strconv.Atoi(p.Worker); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			p.GetProviderName())
+	}
+	if strings.Contains(p.MasterExtraArgs, "--datastore-endpoint") && p.DataStore != "" {
+		return fmt.Errorf("[%s] calling preflight error: `--masterExtraArgs='--datastore-endpoint'` is duplicated with `--datastore`",
+			p.GetProviderName())
+	}
+	for _, v := range []struct{ name, value string }{
+		{"cpu", p.CPU},
+		{"memory", p.Memory},
+		{"disk-size", p.DiskSize},
+	} {
+		if n, err := strconv.Atoi(v.value); err != nil || n < 1 {
+			return fmt.Errorf("[%s] calling preflight error: `--%s` must be a positive number", p.GetProviderName(), v.name)
+		}
+	}
+
+	exist, _, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` is already exist",
+			p.GetProviderName(), p.Name)
+	}
+	return nil
+}
+
+func (p *Libvirt) joinCheck() error {
+	if strings.Contains(p.MasterExtraArgs, "--datastore-endpoint") && p.DataStore != "" {
+		return fmt.Errorf("[%s] calling preflight error: `--masterExtraArgs='--datastore-endpoint'` is duplicated with `--datastore`",
+			p.GetProviderName())
+	}
+
+	masterNum, err := strconv.Atoi(p.Master)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` must be number",
+			p.GetProviderName())
+	}
+	workerNum, err := strconv.Atoi(p.Worker)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			p.GetProviderName())
+	}
+	if masterNum < 1 && workerNum < 1 {
+		return fmt.Errorf("[%s] calling preflight error: `--master` or `--worker` number must >= 1", p.GetProviderName())
+	}
+
+	exist, ids, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist",
+			p.GetProviderName(), p.Name)
+	}
+
+	// remove nodes whose virtual machines are removed from .state file.
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, n := range nodes {
+			if !containsString(ids, n.InstanceID) {
+				p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Libvirt) newClient() error {
+	client, err := newVirtClient(p.URI)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to connect to libvirt %s: %v", p.GetProviderName(), p.URI, err)
+	}
+	p.client = client
+	return nil
+}
+
+func (p *Libvirt) generateInstance(fn checkFun, ssh *types.SSH) (*types.Cluster, error) {
+	if err := p.newClient(); err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	masterNum, _ := strconv.Atoi(p.Master)
+	workerNum, _ := strconv.Atoi(p.Worker)
+
+	p.logger.Infof("[%s] %d masters and %d workers will be added\n", p.GetProviderName(), masterNum, workerNum)
+
+	publicKey, err := p.createKeyPair(ssh)
+	if err != nil {
+		return nil, err
+	}
+	base, err := p.prepareImage()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < masterNum+workerNum; i++ {
+		if err := p.runInstance(i < masterNum, base, ssh.User, publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.getInstanceStatus(typeslibvirt.StatusRunning); err != nil {
+		return nil, err
+	}
+
+	return p.assembleInstanceStatus(ssh)
+}
+
+// createKeyPair returns the public key injected to virtual machines by cloud-init, the key pair of cluster is generated if no ssh key is specified.
+func (p *Libvirt) createKeyPair(ssh *types.SSH) ([]byte, error) {
+	if ssh.SSHKeyPath == "" {
+		keyPath := common.GetDefaultSSHKeyPath(p.Name, p.GetProviderName())
+		if _, err := os.Stat(keyPath); err == nil {
+			ssh.SSHKeyPath = keyPath
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	publicKey, err := putil.CreateKeyPair(ssh, p.GetProviderName(), p.Name, "")
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to read public key of %s: %v", p.GetProviderName(), ssh.SSHKeyPath, err)
+	}
+	return bytes.TrimSpace(publicKey), nil
+}
+
+// prepareImage returns the local path of cloud image, which is downloaded and shared by clusters if it's an url.
+func (p *Libvirt) prepareImage() (string, error) {
+	if !strings.HasPrefix(p.Image, "http://") && !strings.HasPrefix(p.Image, "https://") {
+		base, err := filepath.Abs(p.Image)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(base); err != nil {
+			return "", fmt.Errorf("[%s] calling preflight error: cloud image %s is not found: %v", p.GetProviderName(), p.Image, err)
+		}
+		return base, nil
+	}
+
+	dir := filepath.Join(common.CfgPath, p.GetProviderName(), "images")
+	base := filepath.Join(dir, path.Base(p.Image))
+	if _, err := os.Stat(base); err == nil {
+		return base, nil
+	}
+	if err := utils.EnsureFolderExist(dir); err != nil {
+		return "", err
+	}
+
+	p.logger.Infof("[%s] downloading cloud image %s...\n", p.GetProviderName(), p.Image)
+	resp, err := http.Get(p.Image)
+	if err != nil {
+		return "", fmt.Errorf("[%s] failed to download cloud image %s: %v", p.GetProviderName(), p.Image, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("[%s] failed to download cloud image %s: %s", p.GetProviderName(), p.Image, resp.Status)
+	}
+	// image is downloaded to a temporary file at first, so that an incomplete image is never used.
+	tmp := base + ".download"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("[%s] failed to download cloud image %s: %v", p.GetProviderName(), p.Image, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return base, os.Rename(tmp, base)
+}
+
+func (p *Libvirt) runInstance(master bool, base, user string, publicKey []byte) error {
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	suffix, err := utils.RandomToken(3)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%s-%s-%s", common.TagClusterPrefix, strings.Split(p.Name, ".")[0], role, suffix)
+	tags := p.clusterTags()
+	tags[role] = "true"
+
+	// disk and cloud-init seed of virtual machine are stored with the cluster.
+	dir := filepath.Join(common.GetClusterPath(p.Name, p.GetProviderName()), name)
+	if err := utils.EnsureFolderExist(dir); err != nil {
+		return err
+	}
+	disk := filepath.Join(dir, "disk.qcow2")
+	seed := filepath.Join(dir, "seed.iso")
+
+	p.logger.Infof("[%s] creating virtual machine %s...\n", p.GetProviderName(), name)
+	if err := p.client.CreateDisk(base, disk, p.DiskSize); err != nil {
+		return fmt.Errorf("[%s] calling createDisk error, msg: %v", p.GetProviderName(), err)
+	}
+	userData, err := render(userDataTmpl, map[string]string{
+		"Name":      name,
+		"User":      user,
+		"PublicKey": string(publicKey),
+	})
+	if err != nil {
+		return err
+	}
+	metaData, err := render(metaDataTmpl, map[string]string{"Name": name})
+	if err != nil {
+		return err
+	}
+	if err := p.client.CreateSeed(seed, userData, metaData); err != nil {
+		return fmt.Errorf("[%s] calling createSeed error, msg: %v", p.GetProviderName(), err)
+	}
+	domainXML, err := render(domainTmpl, map[string]interface{}{
+		"Name":    name,
+		"Tags":    tags,
+		"CPU":     p.CPU,
+		"Memory":  p.Memory,
+		"Disk":    disk,
+		"Seed":    seed,
+		"Network": p.NetworkName,
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.client.DefineDomain(string(domainXML)); err != nil {
+		return fmt.Errorf("[%s] calling defineDomain error, msg: %v", p.GetProviderName(), err)
+	}
+	p.m.Store(name, types.Node{
+		Master:         master,
+		RollBack:       true,
+		InstanceID:     name,
+		InstanceStatus: typeslibvirt.StatusStopped,
+	})
+	if err := p.client.StartDomain(name); err != nil {
+		return fmt.Errorf("[%s] calling startDomain error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+func (p *Libvirt) startDomains(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.StartDomain(id); err != nil {
+			return fmt.Errorf("[%s] calling startDomain error, msg: %v", p.GetProviderName(), err)
+		}
+	}
+	return nil
+}
+
+// removeDomains destroys and undefines virtual machines, and removes their disks.
+func (p *Libvirt) removeDomains(ids []string) error {
+	for _, id := range ids {
+		if err := p.client.DestroyDomain(id); err != nil {
+			return fmt.Errorf("[%s] calling destroyDomain error, msg: %v", p.GetProviderName(), err)
+		}
+		if err := p.client.UndefineDomain(id); err != nil {
+			return fmt.Errorf("[%s] calling undefineDomain error, msg: %v", p.GetProviderName(), err)
+		}
+		if err := os.RemoveAll(filepath.Join(common.GetClusterPath(p.Name, p.GetProviderName()), id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getInstanceStatus waits for virtual machines to be in aim status, running virtual machines also need to have ip address leased.
+func (p *Libvirt) getInstanceStatus(aimStatus string) error {
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		ids = append(ids, key.(string))
+		return true
+	})
+
+	if len(ids) > 0 {
+		p.logger.Debugf("[%s] waiting for the virtual machines %s to be in `%s` status...\n", p.GetProviderName(), ids, aimStatus)
+		wait.ErrWaitTimeout = fmt.Errorf("[%s] calling getInstanceStatus error. instanceName: %s, message: not `%s` status",
+			p.GetProviderName(), ids, aimStatus)
+
+		if err := wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+			domains, err := p.client.ListDomains(p.clusterTags())
+			if err != nil {
+				return false, err
+			}
+			states := make(map[string]string, len(domains))
+			for _, d := range domains {
+				states[d.Name] = d.State
+			}
+
+			for _, id := range ids {
+				if states[id] != aimStatus {
+					return false, nil
+				}
+				value, _ := p.m.Load(id)
+				v := value.(types.Node)
+				v.InstanceStatus = aimStatus
+				if aimStatus == typeslibvirt.StatusRunning {
+					ip, err := p.client.DomainAddress(id)
+					if err != nil || ip == "" {
+						return false, nil
+					}
+					v.InternalIPAddress = []string{ip}
+					v.PublicIPAddress = []string{ip}
+				}
+				p.m.Store(id, v)
+			}
+			return true, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	p.logger.Debugf("[%s] virtual machines %s are in `%s` status\n", p.GetProviderName(), ids, aimStatus)
+
+	return nil
+}
+
+func (p *Libvirt) assembleInstanceStatus(ssh *types.SSH) (*types.Cluster, error) {
+	domains, err := p.listDomains()
+	if err != nil || len(domains) == 0 {
+		return nil, fmt.Errorf("[%s] there's no virtual machine for cluster %s: %v", p.GetProviderName(), p.Name, err)
+	}
+
+	for _, d := range domains {
+		if value, ok := p.m.Load(d.Name); ok {
+			v := value.(types.Node)
+			// add only nodes that run the current command.
+			v.Current = true
+			v.SSH = *ssh
+			p.m.Store(d.Name, v)
+			continue
+		}
+		n := types.Node{
+			Master:         d.Tags["master"] == "true",
+			RollBack:       false,
+			InstanceID:     d.Name,
+			InstanceStatus: d.State,
+		}
+		if ip, err := p.client.DomainAddress(d.Name); err == nil && ip != "" {
+			n.InternalIPAddress = []string{ip}
+			n.PublicIPAddress = []string{ip}
+		}
+		p.m.Store(d.Name, n)
+	}
+
+	p.syncNodeStatusWithInstance(ssh)
+
+	return &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}, nil
+}
+
+func (p *Libvirt) syncClusterInstance(ssh *types.SSH) ([]domain, error) {
+	domains, err := p.listDomains()
+	if err != nil || len(domains) == 0 {
+		return nil, fmt.Errorf("[%s] there's no exist virtual machine for cluster %s: %v", p.GetProviderName(), p.Name, err)
+	}
+
+	for _, d := range domains {
+		// sync all virtual machines that belong to current clusters
+		n := types.Node{
+			Master:         d.Tags["master"] == "true",
+			InstanceID:     d.Name,
+			InstanceStatus: d.State,
+			SSH:            *ssh,
+		}
+		if d.State == typeslibvirt.StatusRunning {
+			if ip, err := p.client.DomainAddress(d.Name); err == nil && ip != "" {
+				n.InternalIPAddress = []string{ip}
+				n.PublicIPAddress = []string{ip}
+			}
+		}
+		p.m.Store(d.Name, n)
+	}
+
+	p.syncNodeStatusWithInstance(ssh)
+
+	return domains, nil
+}
+
+func (p *Libvirt) syncNodeStatusWithInstance(ssh *types.SSH) {
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := p.Status.WorkerNodes
+		if v.Master {
+			nodes = p.Status.MasterNodes
+		}
+		index, b := putil.IsExistedNodes(nodes, v.InstanceID)
+		if !b {
+			nodes = append(nodes, v)
+		} else {
+			node := nodes[index]
+			if ssh != nil {
+				if node.SSH.User == "" || node.SSH.Port == "" || (node.SSH.Password == "" && node.SSH.SSHKeyPath == "") {
+					node.SSH = *ssh
+				}
+			}
+			node.InstanceStatus = v.InstanceStatus
+			nodes[index] = node
+		}
+		if v.Master {
+			p.Status.MasterNodes = nodes
+		} else {
+			p.Status.WorkerNodes = nodes
+		}
+		return true
+	})
+}
+
+func (p *Libvirt) syncNodeAddresses() {
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := p.Status.WorkerNodes
+		if v.Master {
+			nodes = p.Status.MasterNodes
+		}
+		if index, b := putil.IsExistedNodes(nodes, v.InstanceID); b {
+			nodes[index].InternalIPAddress = v.InternalIPAddress
+			nodes[index].PublicIPAddress = v.PublicIPAddress
+		}
+		return true
+	})
+}
+
+func (p *Libvirt) listDomains() ([]domain, error) {
+	if p.client == nil {
+		if err := p.newClient(); err != nil {
+			return nil, err
+		}
+	}
+	domains, err := p.client.ListDomains(p.clusterTags())
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to get virtual machines for cluster %s: %v", p.GetProviderName(), p.Name, err)
+	}
+	return domains, nil
+}
+
+func (p *Libvirt) deleteCluster(f bool) error {
+	exist, ids, err := p.IsClusterExist()
+	if err != nil && !f {
+		return fmt.Errorf("[%s] calling deleteCluster error, msg: %v", p.GetProviderName(), err)
+	}
+	if !exist {
+		p.logger.Errorf("[%s] cluster %s is not exist", p.GetProviderName(), p.Name)
+		if !f {
+			return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist", p.GetProviderName(), p.Name)
+		}
+		return nil
+	}
+
+	p.logger.Infof("[%s] remove virtual machines %v", p.GetProviderName(), ids)
+	if err := p.removeDomains(ids); err != nil {
+		return err
+	}
+
+	err = cluster.OverwriteCfg(p.Name)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .cfg file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	err = cluster.DeleteState(p.Name, p.Provider)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	// remove default key-pair folder
+	err = os.RemoveAll(common.GetClusterPath(p.Name, p.GetProviderName()))
+	if err != nil && !f {
+		return fmt.Errorf("[%s] remove cluster store folder (%s) error, msg: %v", p.GetProviderName(), common.GetClusterPath(p.Name, p.GetProviderName()), err)
+	}
+
+	p.logger.Infof("[%s] successfully deleted cluster %s\n", p.GetProviderName(), p.Name)
+	return nil
+}
+
+func (p *Libvirt) clusterTags() map[string]string {
+	return map[string]string{
+		"autok3s": "true",
+		"cluster": common.TagClusterPrefix + p.Name,
+	}
+}
+
+// render executes the template, values in domain xml are escaped by `xml` function.
+func render(tmpl string, data interface{}) ([]byte, error) {
+	t, err := template.New("").Funcs(template.FuncMap{
+		"xml": func(s string) (string, error) {
+			var b bytes.Buffer
+			err := xml.EscapeText(&b, []byte(s))
+			return b.String(), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package libvirt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLibvirt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Libvirt Provider Suite")
+}
+
+// newTestLibvirt returns the provider of cluster "fake" with the numbers of master and worker.
+func newTestLibvirt(master, worker string) *Libvirt {
+	p := newProvider()
+	p.Name = "fake"
+	p.Master = master
+	p.Worker = worker
+	p.logger = fake.Logger()
+	return p
+}
+
+var _ = Describe("Libvirt provider with fake hypervisor", func() {
+	var (
+		virt       *fakeVirt
+		cloudImage string
+	)
+	suite := fake.NewSuite(types.SSH{User: defaultUser, Port: "22"}, func(s *fake.Suite) {
+		cloudImage = filepath.Join(common.CfgPath, "cloudimg.img")
+		Expect(ioutil.WriteFile(cloudImage, []byte("fake"), 0600)).To(Succeed())
+		virt = newFakeVirt()
+		s.Replace(&newVirtClient, func(uri string) (virtClient, error) {
+			return virt, nil
+		})
+	})
+
+	It("creates the virtual machines of cluster with the generated key pair injected by cloud-init", func() {
+		p := newTestLibvirt("1", "1")
+		p.Image = cloudImage
+		p.GenerateClusterName()
+		Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+
+		c, err := p.generateInstance(func() error { return nil }, suite.SSH)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		Expect(c.MasterNodes[0].InstanceStatus).To(Equal("running"))
+		Expect(c.MasterNodes[0].PublicIPAddress[0]).NotTo(BeEmpty())
+		Expect(c.MasterNodes[0].InternalIPAddress).To(Equal(c.MasterNodes[0].PublicIPAddress))
+		Expect(strings.HasPrefix(c.MasterNodes[0].InstanceID, "autok3s-fake-master-")).To(BeTrue())
+		Expect(virt.find(c.MasterNodes[0].InstanceID).Tags).To(HaveKeyWithValue("master", "true"))
+
+		Expect(suite.SSH.SSHKeyPath).To(Equal(common.GetDefaultSSHKeyPath(p.Name, providerName)))
+		publicKey, err := ioutil.ReadFile(suite.SSH.SSHKeyPath + ".pub")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(virt.seeds).To(HaveLen(2))
+		for _, userData := range virt.seeds {
+			Expect(userData).To(ContainSubstring("- name: ubuntu"))
+			Expect(userData).To(ContainSubstring(strings.TrimSpace(string(publicKey))))
+		}
+		for _, base := range virt.disks {
+			Expect(base).To(Equal(cloudImage))
+		}
+		Expect(p.CreateCheck(suite.SSH)).NotTo(Succeed())
+	})
+
+	Context("with the k3s cluster created", func() {
+		var (
+			server *fake.SSHServer
+			p      *Libvirt
+		)
+
+		BeforeEach(func() {
+			server = suite.SSHServer()
+			p = newTestLibvirt("1", "1")
+			p.Image = cloudImage
+			p.Token = "fake-token"
+			p.GenerateClusterName()
+			Expect(p.CreateCheck(suite.SSH)).To(Succeed())
+			Expect(p.CreateK3sCluster(context.Background(), suite.SSH)).To(Succeed())
+		})
+
+		It("removes the worker failed to join by rollback and keeps the cluster in state", func() {
+			server.Handle(fake.FailJoin)
+			j := newTestLibvirt("0", "1")
+			Expect(j.MergeClusterOptions()).To(Succeed())
+			j.GenerateClusterName()
+			Expect(j.JoinK3sNode(context.Background(), suite.SSH)).NotTo(Succeed())
+			Expect(virt.domains).To(HaveLen(3))
+
+			Expect(j.Rollback()).To(Succeed())
+			exist, ids, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeTrue())
+			Expect(ids).To(ConsistOf(p.MasterNodes[0].InstanceID, p.WorkerNodes[0].InstanceID))
+			state, err := cluster.GetClusterByID(p.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status.Status).To(Equal(common.StatusRunning))
+			Expect(state.Worker).To(Equal("1"))
+			Expect(state.WorkerNodes).To(HaveLen(1))
+			Expect(state.WorkerNodes[0].InstanceID).To(Equal(p.WorkerNodes[0].InstanceID))
+		})
+
+		It("deletes the virtual machines and the folder of cluster", func() {
+			Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+			Expect(virt.domains).To(BeEmpty())
+			exist, _, err := p.IsClusterExist()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exist).To(BeFalse())
+			_, err = os.Stat(common.GetClusterPath(p.Name, providerName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTestLibvirt("0", "1")
+		p.Image = cloudImage
+		p.GenerateClusterName()
+		_, err := p.generateInstance(p.joinCheck, suite.SSH)
+		Expect(err).To(HaveOccurred())
+		Expect(virt.domains).To(BeEmpty())
+	})
+
+	It("escapes values of domain xml", func() {
+		b, err := render(domainTmpl, map[string]interface{}{
+			"Name":    "vm",
+			"Tags":    map[string]string{"cluster": "a'b<c>&"},
+			"CPU":     cpu,
+			"Memory":  memory,
+			"Disk":    "/tmp/disk.qcow2",
+			"Seed":    "/tmp/seed.iso",
+			"Network": networkName,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(virt.DefineDomain(string(b))).To(Succeed())
+		Expect(virt.find("vm").Tags).To(HaveKeyWithValue("cluster", "a'b<c>&"))
+	})
+})
@@ -0,0 +1,65 @@
+package libvirt
+
+// metadataNamespace is the namespace of autok3s metadata in domain xml, which holds tags of instances.
+const metadataNamespace = "https://github.com/cnrancher/autok3s"
+
+const domainTmpl = `<domain type='kvm'>
+  <name>{{ xml .Name }}</name>
+  <metadata>
+    <autok3s:tags xmlns:autok3s='` + metadataNamespace + `'>
+{{- range $key, $value := .Tags }}
+      <autok3s:tag key='{{ xml $key }}' value='{{ xml $value }}'/>
+{{- end }}
+    </autok3s:tags>
+  </metadata>
+  <memory unit='MiB'>{{ xml .Memory }}</memory>
+  <vcpu>{{ xml .CPU }}</vcpu>
+  <os>
+    <type>hvm</type>
+    <boot dev='hd'/>
+  </os>
+  <features>
+    <acpi/>
+    <apic/>
+  </features>
+  <cpu mode='host-passthrough'/>
+  <devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='{{ xml .Disk }}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <driver name='qemu' type='raw'/>
+      <source file='{{ xml .Seed }}'/>
+      <target dev='sda' bus='sata'/>
+      <readonly/>
+    </disk>
+    <interface type='network'>
+      <source network='{{ xml .Network }}'/>
+      <model type='virtio'/>
+    </interface>
+    <serial type='pty'>
+      <target port='0'/>
+    </serial>
+    <console type='pty'>
+      <target type='serial' port='0'/>
+    </console>
+  </devices>
+</domain>
+`
+
+// userDataTmpl is the cloud-init user data which creates the ssh user with public key.
+const userDataTmpl = `#cloud-config
+hostname: {{ .Name }}
+users:
+  - name: {{ .User }}
+    sudo: ALL=(ALL) NOPASSWD:ALL
+    shell: /bin/bash
+    ssh_authorized_keys:
+      - {{ .PublicKey }}
+`
+
+const metaDataTmpl = `instance-id: {{ .Name }}
+local-hostname: {{ .Name }}
+`
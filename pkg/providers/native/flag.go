@@ -1,6 +1,7 @@
 package native
 
 import (
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/utils"
 
@@ -15,6 +16,34 @@ const createUsageExample = `  autok3s -d create \
     --worker-ips <worker-ips>
 `
 
+const createWithInventoryUsageExample = `  autok3s -d create \
+    --provider native \
+    --inventory <inventory-file>
+`
+
+const inventoryExample = `
+  # inventory file example, vars of inventory and group are inherited by hosts:
+  vars:
+    user: root
+    ssh-key-path: ~/.ssh/id_rsa
+  masters:
+    hosts:
+      <public-ip>:
+        internal-ip: <internal-ip>
+        labels:
+          disk: ssd
+  workers:
+    vars:
+      user: ubuntu
+    hosts:
+      <public-ip>:
+        ssh-port: "2222"
+        bastions:
+        - host: <bastion-ip>
+          user: root
+          ssh-key-path: ~/.ssh/bastion_rsa
+`
+
 const joinUsageExample = `  autok3s -d join \
     --provider native \
     --ssh-key-path <ssh-key-path> \
@@ -23,12 +52,19 @@ const joinUsageExample = `  autok3s -d join \
     --worker-ips <worker-ips>
 `
 
+const deleteUsageExample = `  autok3s -d delete \
+    --provider native \
+    --name <cluster name>
+`
+
 func (p *Native) GetUsageExample(action string) string {
 	switch action {
 	case "create":
-		return createUsageExample
+		return createUsageExample + createWithInventoryUsageExample + inventoryExample
 	case "join":
 		return joinUsageExample
+	case "delete":
+		return deleteUsageExample
 	default:
 		return "not support"
 	}
@@ -64,7 +100,18 @@ func (p *Native) GetSSHFlags(cmd *cobra.Command) *pflag.FlagSet {
 }
 
 func (p *Native) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
-	return cmd.Flags()
+	fs := []types.Flag{
+		{
+			Name:      "name",
+			P:         &p.Name,
+			V:         p.Name,
+			Usage:     "Set the name of the kubeconfig context",
+			ShortHand: "n",
+			Required:  true,
+		},
+	}
+
+	return utils.ConvertFlags(cmd, fs)
 }
 
 func (p *Native) GetCredentialFlags() []types.Flag {
@@ -86,9 +133,69 @@ func (p *Native) BindCredentialFlags() *pflag.FlagSet {
 }
 
 func (p *Native) MergeClusterOptions() error {
+	clusters, err := cluster.ReadFromState(&types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+	})
+	if err != nil {
+		return err
+	}
+
+	var matched *types.Cluster
+	for _, c := range clusters {
+		if c.Provider == p.Provider && c.Name == p.Name {
+			matched = &c
+		}
+	}
+
+	// hosts are specified by flags or inventory, only metadata and status need to be merged.
+	if matched != nil {
+		p.overwriteMetadata(matched)
+	}
+
 	return nil
 }
 
+func (p *Native) overwriteMetadata(matched *types.Cluster) {
+	// doesn't need to be overwrite.
+	p.Status = matched.Status
+	p.UI = matched.UI
+	p.Cluster = matched.Cluster
+	p.ClusterCIDR = matched.ClusterCIDR
+	p.DataStore = matched.DataStore
+	p.Mirror = matched.Mirror
+	p.DockerMirror = matched.DockerMirror
+	p.Network = matched.Network
+	// needed to be overwrite.
+	if p.Token == "" {
+		p.Token = matched.Token
+	}
+	if p.IP == "" {
+		p.IP = matched.IP
+	}
+	if p.K3sChannel == "" {
+		p.K3sChannel = matched.K3sChannel
+	}
+	if p.K3sVersion == "" {
+		p.K3sVersion = matched.K3sVersion
+	}
+	if p.InstallScript == "" {
+		p.InstallScript = matched.InstallScript
+	}
+	if p.Registry == "" {
+		p.Registry = matched.Registry
+	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.MasterExtraArgs == "" {
+		p.MasterExtraArgs = matched.MasterExtraArgs
+	}
+	if p.WorkerExtraArgs == "" {
+		p.WorkerExtraArgs = matched.WorkerExtraArgs
+	}
+}
+
 func (p *Native) sharedFlags() []types.Flag {
 	fs := []types.Flag{
 		{
@@ -141,6 +248,12 @@ func (p *Native) sharedFlags() []types.Flag {
 			V:     p.Registry,
 			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
 		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
 		{
 			Name:  "datastore",
 			P:     &p.DataStore,
@@ -165,6 +278,12 @@ func (p *Native) sharedFlags() []types.Flag {
 			V:     p.WorkerIps,
 			Usage: "Public IPs of worker nodes on which to install agent, multiple IPs are separated by commas",
 		},
+		{
+			Name:  "inventory",
+			P:     &p.Inventory,
+			V:     p.Inventory,
+			Usage: "Inventory file of hosts which specifies ssh config, bastion and labels of each host, can not be used with master-ips and worker-ips",
+		},
 	}
 
 	return fs
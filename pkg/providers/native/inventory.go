@@ -0,0 +1,81 @@
+package native
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/types/native"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/ghodss/yaml"
+)
+
+func readInventory(path string) (*native.Inventory, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to read inventory file %s: %v", ProviderName, path, err)
+	}
+
+	inventory := &native.Inventory{}
+	if err := yaml.Unmarshal(b, inventory); err != nil {
+		return nil, fmt.Errorf("[%s] failed to parse inventory file %s: %v", ProviderName, path, err)
+	}
+	return inventory, nil
+}
+
+// syncInventoryNodes stores hosts of inventory file to nodes map,
+// ssh flags are overwritten by inventory vars, group vars and host values in order.
+func (p *Native) syncInventoryNodes(ssh *types.SSH) error {
+	inventory, err := readInventory(p.Inventory)
+	if err != nil {
+		return err
+	}
+
+	groups := []struct {
+		group  native.Group
+		master bool
+	}{
+		{inventory.Masters, true},
+		{inventory.Workers, false},
+	}
+	for _, g := range groups {
+		ips := make([]string, 0, len(g.group.Hosts))
+		for ip := range g.group.Hosts {
+			ips = append(ips, ip)
+		}
+		sort.Strings(ips)
+
+		for _, ip := range ips {
+			host := mergeHost(inventory.Vars, g.group.Vars, g.group.Hosts[ip])
+			hostSSH := *ssh
+			utils.MergeConfig(reflect.ValueOf(&hostSSH).Elem(), reflect.ValueOf(&host.SSH).Elem())
+			internalIP := host.InternalIP
+			if internalIP == "" {
+				internalIP = ip
+			}
+			p.storeNode(ip, internalIP, g.master, hostSSH, host.Labels)
+		}
+	}
+
+	return nil
+}
+
+func mergeHost(hosts ...native.Host) native.Host {
+	merged := native.Host{}
+	for _, h := range hosts {
+		utils.MergeConfig(reflect.ValueOf(&merged.SSH).Elem(), reflect.ValueOf(&h.SSH).Elem())
+		if h.InternalIP != "" {
+			merged.InternalIP = h.InternalIP
+		}
+		for k, v := range h.Labels {
+			if merged.Labels == nil {
+				merged.Labels = make(map[string]string)
+			}
+			merged.Labels[k] = v
+		}
+	}
+	return merged
+}
@@ -1,11 +1,13 @@
 package native
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -81,21 +83,38 @@ func (p *Native) GetProviderName() string {
 	return "native"
 }
 
+func (p *Native) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Native) GetClusterName() string {
+	return p.Name
+}
+
 func (p *Native) GenerateClusterName() {
 	// no need to support.
 }
 
 func (p *Native) GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string {
-	// no need to support.
-	return ""
+	// labels of inventory host are registered as node labels.
+	keys := make([]string, 0, len(master.Labels))
+	for k := range master.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := ""
+	for _, k := range keys {
+		args += fmt.Sprintf(" --node-label %s=%s", k, master.Labels[k])
+	}
+	return args
 }
 
 func (p *Native) GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string {
-	// no need to support.
-	return ""
+	return p.GenerateMasterExtraArgs(cluster, worker)
 }
 
-func (p *Native) CreateK3sCluster(ssh *types.SSH) (err error) {
+func (p *Native) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
 	logFile, err := common.GetLogFile(p.Name)
 	if err != nil {
 		return err
@@ -124,7 +143,7 @@ func (p *Native) CreateK3sCluster(ssh *types.SSH) (err error) {
 			p.logger.Infof(common.UsageContext, p.Name)
 			p.logger.Info(common.UsagePods)
 			if p.UI {
-				p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].PublicIPAddress[0])
+				p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].ExternalIP())
 			}
 			cluster.SaveClusterState(c, common.StatusRunning)
 			// remove creating state file and save running state
@@ -135,13 +154,7 @@ func (p *Native) CreateK3sCluster(ssh *types.SSH) (err error) {
 	p.logger = common.NewLogger(common.Debug, logFile)
 	p.logger.Infof("[%s] executing create logic...\n", p.GetProviderName())
 
-	// set ssh default value
-	if ssh.User == "" {
-		ssh.User = defaultUser
-	}
-	if ssh.Password == "" && ssh.SSHKeyPath == "" {
-		ssh.SSHKeyPath = defaultSSHKeyPath
-	}
+	setSSHDefault(ssh)
 	c.Status.Status = common.StatusCreating
 	err = cluster.SaveClusterState(c, common.StatusCreating)
 	if err != nil {
@@ -157,14 +170,14 @@ func (p *Native) CreateK3sCluster(ssh *types.SSH) (err error) {
 	c.DockerMirror = dockerMirror
 	c.Logger = p.logger
 	// initialize K3s cluster.
-	if err = cluster.InitK3sCluster(c); err != nil {
+	if err = cluster.InitK3sCluster(ctx, c); err != nil {
 		return
 	}
 	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
 	return nil
 }
 
-func (p *Native) JoinK3sNode(ssh *types.SSH) (err error) {
+func (p *Native) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
 	if p.m == nil {
 		p.m = new(syncmap.Map)
 	}
@@ -192,13 +205,7 @@ func (p *Native) JoinK3sNode(ssh *types.SSH) (err error) {
 	}()
 	p.logger = common.NewLogger(common.Debug, logFile)
 	p.logger.Infof("[%s] executing join logic...\n", p.GetProviderName())
-	// set ssh default value
-	if ssh.User == "" {
-		ssh.User = defaultUser
-	}
-	if ssh.Password == "" && ssh.SSHKeyPath == "" {
-		ssh.SSHKeyPath = defaultSSHKeyPath
-	}
+	setSSHDefault(ssh)
 
 	c.Status.Status = "upgrading"
 	err = cluster.SaveClusterState(c, common.StatusJoin)
@@ -250,7 +257,7 @@ func (p *Native) JoinK3sNode(ssh *types.SSH) (err error) {
 	c.Logger = p.logger
 	added.Logger = p.logger
 	// join K3s node.
-	if err := cluster.JoinK3sNode(c, added); err != nil {
+	if err := cluster.JoinK3sNode(ctx, c, added); err != nil {
 		return err
 	}
 
@@ -287,14 +294,128 @@ func (p *Native) Rollback() error {
 }
 
 func (p *Native) CreateCheck(ssh *types.SSH) error {
-	if p.MasterIps == "" {
+	if err := cluster.CheckAirGap(p.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	if p.Inventory != "" && (p.MasterIps != "" || p.WorkerIps != "") {
+		return fmt.Errorf("[%s] inventory can not be used with master-ips or worker-ips", p.GetProviderName())
+	}
+	if p.Inventory != "" {
+		inventory, err := readInventory(p.Inventory)
+		if err != nil {
+			return err
+		}
+		if len(inventory.Masters.Hosts) == 0 {
+			return fmt.Errorf("[%s] cluster must have one master when create", p.GetProviderName())
+		}
+	} else if p.MasterIps == "" {
 		return fmt.Errorf("[%s] cluster must have one master when create", p.GetProviderName())
 	}
+
+	exist, _, err := p.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` is already exist", p.GetProviderName(), p.Name)
+	}
 	return nil
 }
 
-func (p *Native) DeleteK3sCluster(f bool) error {
-	return p.CommandNotSupport("delete")
+func (p *Native) DeleteK3sCluster(ctx context.Context, f bool) error {
+	isConfirmed := true
+
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to delete cluster %s", p.GetProviderName(), p.Name))
+	}
+	if isConfirmed {
+		logFile, err := common.GetLogFile(p.Name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			logFile.Close()
+			// remove log file
+			os.Remove(filepath.Join(common.GetLogPath(), p.Name))
+			// remove state file
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRunning)))
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusFailed)))
+		}()
+		p.logger = common.NewLogger(common.Debug, logFile)
+		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
+		err = p.deleteCluster(f)
+		if err != nil {
+			return err
+		}
+		p.logger.Infof("[%s] successfully excuted delete cluster logic\n", p.GetProviderName())
+	}
+	return nil
+}
+
+func (p *Native) RemoveK3sNode(node string, f bool) error {
+	return p.CommandNotSupport("remove-node")
+}
+
+func (p *Native) UpgradeK3sCluster(ctx context.Context, version string) error {
+	return p.CommandNotSupport("upgrade")
+}
+
+func (p *Native) SaveSnapshot(name string) (*types.Snapshot, error) {
+	return nil, p.CommandNotSupport("snapshot")
+}
+
+func (p *Native) ListSnapshots() ([]types.Snapshot, error) {
+	return nil, p.CommandNotSupport("snapshot")
+}
+
+func (p *Native) RestoreSnapshot(name string) error {
+	return p.CommandNotSupport("snapshot")
+}
+
+func (p *Native) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	return nil, p.CommandNotSupport("check")
+}
+
+func (p *Native) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	if p.m == nil {
+		p.m = new(syncmap.Map)
+	}
+	p.logger = common.NewLogger(common.Debug, nil)
+	setSSHDefault(ssh)
+
+	c, err := p.assembleNodeStatus(ssh)
+	if err != nil {
+		return nil, err
+	}
+	// only the nodes which k3s will be installed on are checked.
+	nodes := make([]types.Node, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		if v := value.(types.Node); v.Current {
+			nodes = append(nodes, v)
+		}
+		return true
+	})
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Master != nodes[j].Master {
+			return nodes[i].Master
+		}
+		return nodes[i].InstanceID < nodes[j].InstanceID
+	})
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("[%s] no new node is specified for pre-flight checks", p.GetProviderName())
+	}
+
+	c.Logger = p.logger
+	return cluster.PreflightK3sNodes(context.Background(), c, nodes)
+}
+
+func (p *Native) StartK3sCluster() error {
+	return p.CommandNotSupport("start")
+}
+
+func (p *Native) StopK3sCluster() error {
+	return p.CommandNotSupport("stop")
 }
 
 func (p *Native) SSHK3sNode(ssh *types.SSH, ip string) error {
@@ -306,15 +427,83 @@ func (p *Native) CommandNotSupport(commandName string) error {
 }
 
 func (p *Native) DescribeCluster(kubecfg string) *types.ClusterInfo {
-	return &types.ClusterInfo{}
+	c := p.GetCluster(kubecfg)
+
+	instanceNodes := make([]types.ClusterNode, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, n := range nodes {
+			instanceNodes = append(instanceNodes, types.ClusterNode{
+				InstanceID:              n.InstanceID,
+				InstanceStatus:          n.InstanceStatus,
+				InternalIP:              n.InternalIPAddress,
+				ExternalIP:              n.PublicIPAddress,
+				Status:                  types.ClusterStatusUnknown,
+				ContainerRuntimeVersion: types.ClusterStatusUnknown,
+				Version:                 types.ClusterStatusUnknown,
+			})
+		}
+	}
+	c.Nodes = instanceNodes
+	if c.Status != types.ClusterStatusRunning {
+		return c
+	}
+
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		return c
+	}
+	nodes, err := cluster.DescribeClusterNodes(client, instanceNodes)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to list nodes of cluster %s: %v", p.GetProviderName(), p.Name, err)
+		return c
+	}
+	c.Nodes = nodes
+	return c
 }
 
 func (p *Native) GetCluster(kubecfg string) *types.ClusterInfo {
-	return &types.ClusterInfo{}
+	p.logger = common.NewLogger(common.Debug, nil)
+	c := &types.ClusterInfo{
+		Name:     p.Name,
+		Provider: p.GetProviderName(),
+		Master:   strconv.Itoa(len(p.Status.MasterNodes)),
+		Worker:   strconv.Itoa(len(p.Status.WorkerNodes)),
+	}
+	client, err := cluster.GetClusterConfig(p.Name, kubecfg)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", p.GetProviderName(), p.Name, err)
+		c.Status = types.ClusterStatusUnknown
+		c.Version = types.ClusterStatusUnknown
+		return c
+	}
+	c.Status = cluster.GetClusterStatus(client)
+	if c.Status == types.ClusterStatusRunning {
+		c.Version = cluster.GetClusterVersion(client)
+	} else {
+		c.Version = types.ClusterStatusUnknown
+	}
+	return c
 }
 
 func (p *Native) IsClusterExist() (bool, []string, error) {
-	return false, []string{}, nil
+	ids := make([]string, 0)
+
+	store, err := cluster.GetStateStore()
+	if err != nil {
+		return false, ids, err
+	}
+	state, err := store.Get(p.Name, p.GetProviderName())
+	if err != nil || state == nil {
+		return false, ids, err
+	}
+
+	for _, nodes := range [][]types.Node{state.MasterNodes, state.WorkerNodes} {
+		for _, n := range nodes {
+			ids = append(ids, n.InstanceID)
+		}
+	}
+
+	return len(ids) > 0, ids, nil
 }
 
 func (p *Native) GetClusterConfig() (map[string]schemas.Field, error) {
@@ -362,7 +551,50 @@ func (p *Native) SetConfig(config []byte) error {
 	return nil
 }
 
+func (p *Native) deleteCluster(f bool) error {
+	exist, _, err := p.IsClusterExist()
+	if err != nil && !f {
+		return fmt.Errorf("[%s] calling deleteCluster error, msg: %v", p.GetProviderName(), err)
+	}
+	if !exist {
+		p.logger.Errorf("[%s] cluster %s is not exist", p.GetProviderName(), p.Name)
+		if !f {
+			return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist", p.GetProviderName(), p.Name)
+		}
+		return nil
+	}
+
+	// hosts are not owned by autok3s, uninstall k3s from every host instead of releasing them.
+	nodes := append(append([]types.Node{}, p.Status.MasterNodes...), p.Status.WorkerNodes...)
+	warnMsg := cluster.UninstallK3sNodes(nodes)
+	for _, w := range warnMsg {
+		p.logger.Warnf("[%s] %s\n", p.GetProviderName(), w)
+	}
+	if len(warnMsg) > 0 && !f {
+		return fmt.Errorf("[%s] failed to uninstall k3s from some hosts, use --force to ignore", p.GetProviderName())
+	}
+
+	err = cluster.OverwriteCfg(p.Name)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .cfg file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	err = cluster.DeleteState(p.Name, p.Provider)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully deleted cluster %s\n", p.GetProviderName(), p.Name)
+	return nil
+}
+
 func (p *Native) assembleNodeStatus(ssh *types.SSH) (*types.Cluster, error) {
+	if p.Inventory != "" {
+		if err := p.syncInventoryNodes(ssh); err != nil {
+			return nil, err
+		}
+	}
+
 	if p.MasterIps != "" {
 		masterIps := strings.Split(p.MasterIps, ",")
 		p.syncNodesMap(masterIps, true, ssh)
@@ -407,16 +639,35 @@ func (p *Native) assembleNodeStatus(ssh *types.SSH) (*types.Cluster, error) {
 
 func (p *Native) syncNodesMap(ipList []string, master bool, ssh *types.SSH) {
 	for _, ip := range ipList {
-		currentID := strings.Replace(ip, ".", "-", -1)
-		p.m.Store(currentID, types.Node{
-			Master:            master,
-			RollBack:          true,
-			InstanceID:        currentID,
-			InstanceStatus:    native.StatusRunning,
-			InternalIPAddress: []string{ip},
-			PublicIPAddress:   []string{ip},
-			Current:           true,
-			SSH:               *ssh,
-		})
+		p.storeNode(ip, ip, master, *ssh, nil)
+	}
+}
+
+func (p *Native) storeNode(ip, internalIP string, master bool, ssh types.SSH, labels map[string]string) {
+	currentID := strings.Replace(ip, ".", "-", -1)
+	// hosts which are already in cluster should not be installed again.
+	if _, ok := putil.FindNode(p.Status, currentID); ok {
+		return
+	}
+	p.m.Store(currentID, types.Node{
+		Master:            master,
+		RollBack:          true,
+		InstanceID:        currentID,
+		InstanceStatus:    native.StatusRunning,
+		InternalIPAddress: []string{internalIP},
+		PublicIPAddress:   []string{ip},
+		Current:           true,
+		SSH:               ssh,
+		Labels:            labels,
+	})
+}
+
+// setSSHDefault sets the default ssh user and private key path.
+func setSSHDefault(ssh *types.SSH) {
+	if ssh.User == "" {
+		ssh.User = defaultUser
+	}
+	if ssh.Password == "" && ssh.SSHKeyPath == "" {
+		ssh.SSHKeyPath = defaultSSHKeyPath
 	}
 }
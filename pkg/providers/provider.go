@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -24,6 +25,8 @@ var (
 // Provider is an abstract, pluggable interface for k3s provider
 type Provider interface {
 	GetProviderName() string
+	// Get the name of credential profile used by the cluster.
+	GetCredentialProfile() string
 	// Get command usage example.
 	GetUsageExample(action string) string
 	// Create flags of provider options.
@@ -40,16 +43,36 @@ type Provider interface {
 	BindCredentialFlags() *pflag.FlagSet
 	// Generate cluster name.
 	GenerateClusterName()
+	// Get cluster name, which is the generated one after GenerateClusterName is called.
+	GetClusterName() string
 	// Generate create/join extra args for master nodes
 	GenerateMasterExtraArgs(cluster *types.Cluster, master types.Node) string
 	// Generate create/join extra args for worker nodes
 	GenerateWorkerExtraArgs(cluster *types.Cluster, worker types.Node) string
 	// K3s create cluster interface.
-	CreateK3sCluster(ssh *types.SSH) error
+	CreateK3sCluster(ctx context.Context, ssh *types.SSH) error
 	// K3s join node interface.
-	JoinK3sNode(ssh *types.SSH) error
+	JoinK3sNode(ctx context.Context, ssh *types.SSH) error
 	// K3s delete cluster interface.
-	DeleteK3sCluster(f bool) error
+	DeleteK3sCluster(ctx context.Context, f bool) error
+	// K3s remove node interface.
+	RemoveK3sNode(node string, f bool) error
+	// K3s upgrade cluster interface.
+	UpgradeK3sCluster(ctx context.Context, version string) error
+	// K3s save etcd snapshot interface.
+	SaveSnapshot(name string) (*types.Snapshot, error)
+	// K3s list etcd snapshots interface.
+	ListSnapshots() ([]types.Snapshot, error)
+	// K3s restore etcd snapshot interface.
+	RestoreSnapshot(name string) error
+	// K3s check and repair cluster interface.
+	CheckK3sCluster(repair bool) ([]types.NodeCheck, error)
+	// K3s pre-flight check nodes interface.
+	PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error)
+	// K3s start cluster interface.
+	StartK3sCluster() error
+	// K3s stop cluster interface.
+	StopK3sCluster() error
 	// K3s ssh node interface.
 	SSHK3sNode(ssh *types.SSH, node string) error
 	// K3s check cluster exist.
@@ -0,0 +1,65 @@
+package tencent
+
+import (
+	tencentCommon "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
+	tag "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/tag/v20180813"
+	vpc "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/vpc/v20170312"
+)
+
+// cvmClient is the subset of cvm api used by the provider, which is replaced by an in-memory fake in tests.
+type cvmClient interface {
+	RunInstances(request *cvm.RunInstancesRequest) (*cvm.RunInstancesResponse, error)
+	StartInstances(request *cvm.StartInstancesRequest) (*cvm.StartInstancesResponse, error)
+	StopInstances(request *cvm.StopInstancesRequest) (*cvm.StopInstancesResponse, error)
+	TerminateInstances(request *cvm.TerminateInstancesRequest) (*cvm.TerminateInstancesResponse, error)
+	DescribeInstances(request *cvm.DescribeInstancesRequest) (*cvm.DescribeInstancesResponse, error)
+	DescribeInstancesStatus(request *cvm.DescribeInstancesStatusRequest) (*cvm.DescribeInstancesStatusResponse, error)
+}
+
+// vpcClient is the subset of vpc api used by the provider, which is replaced by an in-memory fake in tests.
+type vpcClient interface {
+	CreateVpc(request *vpc.CreateVpcRequest) (*vpc.CreateVpcResponse, error)
+	DescribeVpcs(request *vpc.DescribeVpcsRequest) (*vpc.DescribeVpcsResponse, error)
+	CreateSubnet(request *vpc.CreateSubnetRequest) (*vpc.CreateSubnetResponse, error)
+	DescribeSubnets(request *vpc.DescribeSubnetsRequest) (*vpc.DescribeSubnetsResponse, error)
+
+	CreateSecurityGroup(request *vpc.CreateSecurityGroupRequest) (*vpc.CreateSecurityGroupResponse, error)
+	DescribeSecurityGroups(request *vpc.DescribeSecurityGroupsRequest) (*vpc.DescribeSecurityGroupsResponse, error)
+	CreateSecurityGroupPolicies(request *vpc.CreateSecurityGroupPoliciesRequest) (*vpc.CreateSecurityGroupPoliciesResponse, error)
+	DeleteSecurityGroupPolicies(request *vpc.DeleteSecurityGroupPoliciesRequest) (*vpc.DeleteSecurityGroupPoliciesResponse, error)
+	DescribeSecurityGroupPolicies(request *vpc.DescribeSecurityGroupPoliciesRequest) (*vpc.DescribeSecurityGroupPoliciesResponse, error)
+
+	AllocateAddresses(request *vpc.AllocateAddressesRequest) (*vpc.AllocateAddressesResponse, error)
+	AssociateAddress(request *vpc.AssociateAddressRequest) (*vpc.AssociateAddressResponse, error)
+	DisassociateAddress(request *vpc.DisassociateAddressRequest) (*vpc.DisassociateAddressResponse, error)
+	ReleaseAddresses(request *vpc.ReleaseAddressesRequest) (*vpc.ReleaseAddressesResponse, error)
+	DescribeAddresses(request *vpc.DescribeAddressesRequest) (*vpc.DescribeAddressesResponse, error)
+	DescribeTaskResult(request *vpc.DescribeTaskResultRequest) (*vpc.DescribeTaskResultResponse, error)
+}
+
+// tagClient is the subset of tag api used by the provider, which is replaced by an in-memory fake in tests.
+type tagClient interface {
+	DescribeResourcesByTags(request *tag.DescribeResourcesByTagsRequest) (*tag.DescribeResourcesByTagsResponse, error)
+}
+
+// newClientSDK creates the cvm, vpc and tag clients, tests replace it to run the provider without network access.
+var newClientSDK = func(credential *tencentCommon.Credential, region string, cpf *profile.ClientProfile) (cvmClient, vpcClient, tagClient, error) {
+	c, err := cvm.NewClient(credential, region, cpf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	v, err := vpc.NewClient(credential, region, cpf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// region for tag clients is not necessary
+	t, err := tag.NewClient(credential, region, cpf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c, v, t, nil
+}
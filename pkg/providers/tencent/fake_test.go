@@ -0,0 +1,540 @@
+package tencent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/types/tencent"
+
+	tencentCommon "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
+	tag "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/tag/v20180813"
+	vpc "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/vpc/v20170312"
+)
+
+const (
+	statusStopping    = "STOPPING"
+	statusTerminating = "TERMINATING"
+	addressUnbind     = "UNBIND"
+	addressBind       = "BIND"
+)
+
+// fakeCloud is the in-memory state of tencent cloud shared by the fake cvm, vpc and tag clients.
+// Instances are pending after created and become running when the status is described,
+// stopping instances become stopped in the same way. Vpc tasks of eips are always succeeded.
+type fakeCloud struct {
+	mu sync.Mutex
+
+	seq            int
+	instances      []*cvm.Instance
+	vpcs           []*vpc.Vpc
+	subnets        []*vpc.Subnet
+	addresses      []*vpc.Address
+	securityGroups []*vpc.SecurityGroup
+	policies       map[string]*vpc.SecurityGroupPolicySet
+	// tags of resources by id.
+	tags map[string]map[string]string
+}
+
+// resourceTypes maps the prefix of resource id to the service type and resource prefix of tag api.
+var resourceTypes = map[string][2]string{
+	"ins":    {"cvm", "instance"},
+	"eip":    {tencent.ServiceTypeEIP, tencent.ResourcePrefixEIP},
+	"sg":     {"cvm", "sg"},
+	"vpc":    {"vpc", "vpc"},
+	"subnet": {"vpc", "subnet"},
+}
+
+type fakeCVM struct {
+	*fakeCloud
+}
+
+type fakeVPC struct {
+	*fakeCloud
+}
+
+type fakeTag struct {
+	*fakeCloud
+}
+
+func newFakeCloud() *fakeCloud {
+	return &fakeCloud{
+		policies: map[string]*vpc.SecurityGroupPolicySet{},
+		tags:     map[string]map[string]string{},
+	}
+}
+
+func (f *fakeCloud) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s-%08d", prefix, f.seq)
+}
+
+func (f *fakeCloud) taskID() *string {
+	f.seq++
+	return tencentCommon.StringPtr(strconv.Itoa(f.seq))
+}
+
+func (f *fakeCloud) tag(id string, keys, values []*string) {
+	if f.tags[id] == nil {
+		f.tags[id] = map[string]string{}
+	}
+	for i := range keys {
+		f.tags[id][*keys[i]] = *values[i]
+	}
+}
+
+func (f *fakeCloud) instance(id string) *cvm.Instance {
+	for _, instance := range f.instances {
+		if *instance.InstanceId == id && *instance.InstanceState != statusTerminating {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (f *fakeCloud) address(id string) *vpc.Address {
+	for _, address := range f.addresses {
+		if *address.AddressId == id {
+			return address
+		}
+	}
+	return nil
+}
+
+func (f *fakeCVM) RunInstances(request *cvm.RunInstancesRequest) (*cvm.RunInstancesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys, values []*string
+	for _, spec := range request.TagSpecification {
+		for _, t := range spec.Tags {
+			keys = append(keys, t.Key)
+			values = append(values, t.Value)
+		}
+	}
+	ids := make([]*string, 0)
+	for i := int64(0); i < *request.InstanceCount; i++ {
+		id := f.nextID("ins")
+		instance := &cvm.Instance{
+			InstanceId:          tencentCommon.StringPtr(id),
+			InstanceName:        request.InstanceName,
+			InstanceState:       tencentCommon.StringPtr(tencent.StatusPending),
+			Placement:           request.Placement,
+			VirtualPrivateCloud: request.VirtualPrivateCloud,
+			SecurityGroupIds:    request.SecurityGroupIds,
+			PrivateIpAddresses:  tencentCommon.StringPtrs([]string{fmt.Sprintf("192.168.3.%d", f.seq)}),
+		}
+		if request.InternetAccessible != nil && request.InternetAccessible.PublicIpAssigned != nil &&
+			*request.InternetAccessible.PublicIpAssigned {
+			instance.PublicIpAddresses = tencentCommon.StringPtrs([]string{fmt.Sprintf("203.0.113.%d", f.seq)})
+		}
+		f.instances = append(f.instances, instance)
+		f.tag(id, keys, values)
+		ids = append(ids, instance.InstanceId)
+	}
+	response := cvm.NewRunInstancesResponse()
+	return response, respond(response, map[string]interface{}{"InstanceIdSet": ids})
+}
+
+func (f *fakeCVM) StartInstances(request *cvm.StartInstancesRequest) (*cvm.StartInstancesResponse, error) {
+	response := cvm.NewStartInstancesResponse()
+	if err := f.setState(request.InstanceIds, tencent.StatusPending); err != nil {
+		return nil, err
+	}
+	return response, respond(response, map[string]interface{}{})
+}
+
+func (f *fakeCVM) StopInstances(request *cvm.StopInstancesRequest) (*cvm.StopInstancesResponse, error) {
+	response := cvm.NewStopInstancesResponse()
+	if err := f.setState(request.InstanceIds, statusStopping); err != nil {
+		return nil, err
+	}
+	return response, respond(response, map[string]interface{}{})
+}
+
+func (f *fakeCVM) TerminateInstances(request *cvm.TerminateInstancesRequest) (*cvm.TerminateInstancesResponse, error) {
+	response := cvm.NewTerminateInstancesResponse()
+	if err := f.setState(request.InstanceIds, statusTerminating); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range request.InstanceIds {
+		delete(f.tags, *id)
+	}
+	return response, respond(response, map[string]interface{}{})
+}
+
+func (f *fakeCVM) setState(ids []*string, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		if f.instance(*id) == nil {
+			return fmt.Errorf("InvalidInstanceId.NotFound: instance %s does not exist", *id)
+		}
+	}
+	for _, id := range ids {
+		f.instance(*id).InstanceState = tencentCommon.StringPtr(state)
+	}
+	return nil
+}
+
+func (f *fakeCVM) DescribeInstances(request *cvm.DescribeInstancesRequest) (*cvm.DescribeInstancesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filters := make([]*vpc.Filter, 0, len(request.Filters))
+	for _, filter := range request.Filters {
+		filters = append(filters, &vpc.Filter{Name: filter.Name, Values: filter.Values})
+	}
+	instances := make([]*cvm.Instance, 0)
+	for _, instance := range f.instances {
+		if *instance.InstanceState == statusTerminating || !matchFilters(filters, f.tags[*instance.InstanceId], nil) {
+			continue
+		}
+		i := *instance
+		for k, v := range f.tags[*instance.InstanceId] {
+			i.Tags = append(i.Tags, &cvm.Tag{Key: tencentCommon.StringPtr(k), Value: tencentCommon.StringPtr(v)})
+		}
+		instances = append(instances, &i)
+	}
+	response := cvm.NewDescribeInstancesResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(instances), "InstanceSet": instances})
+}
+
+func (f *fakeCVM) DescribeInstancesStatus(request *cvm.DescribeInstancesStatusRequest) (*cvm.DescribeInstancesStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	statuses := make([]*cvm.InstanceStatus, 0)
+	for _, id := range request.InstanceIds {
+		instance := f.instance(*id)
+		if instance == nil {
+			continue
+		}
+		switch *instance.InstanceState {
+		case tencent.StatusPending:
+			instance.InstanceState = tencentCommon.StringPtr(tencent.StatusRunning)
+		case statusStopping:
+			instance.InstanceState = tencentCommon.StringPtr(tencent.StatusStopped)
+		}
+		statuses = append(statuses, &cvm.InstanceStatus{InstanceId: instance.InstanceId, InstanceState: instance.InstanceState})
+	}
+	response := cvm.NewDescribeInstancesStatusResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(statuses), "InstanceStatusSet": statuses})
+}
+
+func (f *fakeVPC) CreateVpc(request *vpc.CreateVpcRequest) (*vpc.CreateVpcResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v := &vpc.Vpc{
+		VpcId:     tencentCommon.StringPtr(f.nextID("vpc")),
+		VpcName:   request.VpcName,
+		CidrBlock: request.CidrBlock,
+	}
+	f.vpcs = append(f.vpcs, v)
+	f.tagResource(*v.VpcId, request.Tags)
+	response := vpc.NewCreateVpcResponse()
+	return response, respond(response, map[string]interface{}{"Vpc": v})
+}
+
+func (f *fakeVPC) DescribeVpcs(request *vpc.DescribeVpcsRequest) (*vpc.DescribeVpcsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vpcs := make([]*vpc.Vpc, 0)
+	for _, v := range f.vpcs {
+		if (len(request.VpcIds) == 0 || containsID(request.VpcIds, *v.VpcId)) &&
+			matchFilters(request.Filters, f.tags[*v.VpcId], map[string]string{"vpc-name": *v.VpcName}) {
+			vpcs = append(vpcs, v)
+		}
+	}
+	response := vpc.NewDescribeVpcsResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(vpcs), "VpcSet": vpcs})
+}
+
+func (f *fakeVPC) CreateSubnet(request *vpc.CreateSubnetRequest) (*vpc.CreateSubnetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subnet := &vpc.Subnet{
+		SubnetId:   tencentCommon.StringPtr(f.nextID("subnet")),
+		SubnetName: request.SubnetName,
+		VpcId:      request.VpcId,
+		Zone:       request.Zone,
+		CidrBlock:  request.CidrBlock,
+	}
+	f.subnets = append(f.subnets, subnet)
+	f.tagResource(*subnet.SubnetId, request.Tags)
+	response := vpc.NewCreateSubnetResponse()
+	return response, respond(response, map[string]interface{}{"Subnet": subnet})
+}
+
+func (f *fakeVPC) DescribeSubnets(request *vpc.DescribeSubnetsRequest) (*vpc.DescribeSubnetsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subnets := make([]*vpc.Subnet, 0)
+	for _, subnet := range f.subnets {
+		if (len(request.SubnetIds) == 0 || containsID(request.SubnetIds, *subnet.SubnetId)) &&
+			matchFilters(request.Filters, f.tags[*subnet.SubnetId], map[string]string{"subnet-name": *subnet.SubnetName}) {
+			subnets = append(subnets, subnet)
+		}
+	}
+	response := vpc.NewDescribeSubnetsResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(subnets), "SubnetSet": subnets})
+}
+
+func (f *fakeVPC) CreateSecurityGroup(request *vpc.CreateSecurityGroupRequest) (*vpc.CreateSecurityGroupResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group := &vpc.SecurityGroup{
+		SecurityGroupId:   tencentCommon.StringPtr(f.nextID("sg")),
+		SecurityGroupName: request.GroupName,
+		SecurityGroupDesc: request.GroupDescription,
+	}
+	f.securityGroups = append(f.securityGroups, group)
+	f.policies[*group.SecurityGroupId] = &vpc.SecurityGroupPolicySet{}
+	f.tagResource(*group.SecurityGroupId, request.Tags)
+	response := vpc.NewCreateSecurityGroupResponse()
+	return response, respond(response, map[string]interface{}{"SecurityGroup": group})
+}
+
+func (f *fakeVPC) DescribeSecurityGroups(request *vpc.DescribeSecurityGroupsRequest) (*vpc.DescribeSecurityGroupsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	groups := make([]*vpc.SecurityGroup, 0)
+	for _, group := range f.securityGroups {
+		if (len(request.SecurityGroupIds) == 0 || containsID(request.SecurityGroupIds, *group.SecurityGroupId)) &&
+			matchFilters(request.Filters, f.tags[*group.SecurityGroupId], map[string]string{"security-group-name": *group.SecurityGroupName}) {
+			groups = append(groups, group)
+		}
+	}
+	response := vpc.NewDescribeSecurityGroupsResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(groups), "SecurityGroupSet": groups})
+}
+
+func (f *fakeVPC) DescribeSecurityGroupPolicies(request *vpc.DescribeSecurityGroupPoliciesRequest) (*vpc.DescribeSecurityGroupPoliciesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	policies, ok := f.policies[*request.SecurityGroupId]
+	if !ok {
+		return nil, fmt.Errorf("ResourceNotFound: security group %s does not exist", *request.SecurityGroupId)
+	}
+	response := vpc.NewDescribeSecurityGroupPoliciesResponse()
+	return response, respond(response, map[string]interface{}{"SecurityGroupPolicySet": policies})
+}
+
+func (f *fakeVPC) CreateSecurityGroupPolicies(request *vpc.CreateSecurityGroupPoliciesRequest) (*vpc.CreateSecurityGroupPoliciesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	policies, ok := f.policies[*request.SecurityGroupId]
+	if !ok {
+		return nil, fmt.Errorf("ResourceNotFound: security group %s does not exist", *request.SecurityGroupId)
+	}
+	for _, p := range request.SecurityGroupPolicySet.Ingress {
+		if findPolicy(policies.Ingress, p) < 0 {
+			policies.Ingress = append(policies.Ingress, p)
+		}
+	}
+	for _, p := range request.SecurityGroupPolicySet.Egress {
+		if findPolicy(policies.Egress, p) < 0 {
+			policies.Egress = append(policies.Egress, p)
+		}
+	}
+	response := vpc.NewCreateSecurityGroupPoliciesResponse()
+	return response, respond(response, map[string]interface{}{})
+}
+
+func (f *fakeVPC) DeleteSecurityGroupPolicies(request *vpc.DeleteSecurityGroupPoliciesRequest) (*vpc.DeleteSecurityGroupPoliciesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	policies, ok := f.policies[*request.SecurityGroupId]
+	if !ok {
+		return nil, fmt.Errorf("ResourceNotFound: security group %s does not exist", *request.SecurityGroupId)
+	}
+	for _, p := range request.SecurityGroupPolicySet.Ingress {
+		if index := findPolicy(policies.Ingress, p); index >= 0 {
+			policies.Ingress = append(policies.Ingress[:index], policies.Ingress[index+1:]...)
+		}
+	}
+	response := vpc.NewDeleteSecurityGroupPoliciesResponse()
+	return response, respond(response, map[string]interface{}{})
+}
+
+func (f *fakeVPC) AllocateAddresses(request *vpc.AllocateAddressesRequest) (*vpc.AllocateAddressesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]*string, 0)
+	for i := int64(0); i < *request.AddressCount; i++ {
+		address := &vpc.Address{
+			AddressId:     tencentCommon.StringPtr(f.nextID("eip")),
+			AddressIp:     tencentCommon.StringPtr(fmt.Sprintf("198.51.100.%d", f.seq)),
+			AddressStatus: tencentCommon.StringPtr(addressUnbind),
+		}
+		f.addresses = append(f.addresses, address)
+		f.tagResource(*address.AddressId, request.Tags)
+		ids = append(ids, address.AddressId)
+	}
+	response := vpc.NewAllocateAddressesResponse()
+	return response, respond(response, map[string]interface{}{"AddressSet": ids, "TaskId": f.taskID()})
+}
+
+func (f *fakeVPC) AssociateAddress(request *vpc.AssociateAddressRequest) (*vpc.AssociateAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	address := f.address(*request.AddressId)
+	instance := f.instance(*request.InstanceId)
+	if address == nil || instance == nil || *address.AddressStatus != addressUnbind {
+		return nil, fmt.Errorf("InvalidAddressState: eip %s can't be associated with instance %s", *request.AddressId, *request.InstanceId)
+	}
+	address.AddressStatus = tencentCommon.StringPtr(addressBind)
+	address.InstanceId = instance.InstanceId
+	instance.PublicIpAddresses = []*string{address.AddressIp}
+	response := vpc.NewAssociateAddressResponse()
+	return response, respond(response, map[string]interface{}{"TaskId": f.taskID()})
+}
+
+func (f *fakeVPC) DisassociateAddress(request *vpc.DisassociateAddressRequest) (*vpc.DisassociateAddressResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	address := f.address(*request.AddressId)
+	if address == nil {
+		return nil, fmt.Errorf("InvalidAddressId.NotFound: eip %s does not exist", *request.AddressId)
+	}
+	if address.InstanceId != nil {
+		if instance := f.instance(*address.InstanceId); instance != nil {
+			instance.PublicIpAddresses = nil
+		}
+	}
+	address.AddressStatus = tencentCommon.StringPtr(addressUnbind)
+	address.InstanceId = nil
+	response := vpc.NewDisassociateAddressResponse()
+	return response, respond(response, map[string]interface{}{"TaskId": f.taskID()})
+}
+
+func (f *fakeVPC) ReleaseAddresses(request *vpc.ReleaseAddressesRequest) (*vpc.ReleaseAddressesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range request.AddressIds {
+		address := f.address(*id)
+		if address == nil {
+			return nil, fmt.Errorf("InvalidAddressId.NotFound: eip %s does not exist", *id)
+		}
+		if *address.AddressStatus != addressUnbind {
+			return nil, fmt.Errorf("InvalidAddressState: eip %s is in use", *id)
+		}
+	}
+	addresses := make([]*vpc.Address, 0)
+	for _, address := range f.addresses {
+		if !containsID(request.AddressIds, *address.AddressId) {
+			addresses = append(addresses, address)
+		} else {
+			delete(f.tags, *address.AddressId)
+		}
+	}
+	f.addresses = addresses
+	response := vpc.NewReleaseAddressesResponse()
+	return response, respond(response, map[string]interface{}{"TaskId": f.taskID()})
+}
+
+func (f *fakeVPC) DescribeAddresses(request *vpc.DescribeAddressesRequest) (*vpc.DescribeAddressesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	addresses := make([]*vpc.Address, 0)
+	for _, address := range f.addresses {
+		attrs := map[string]string{"address-id": *address.AddressId}
+		if address.InstanceId != nil {
+			attrs["instance-id"] = *address.InstanceId
+		}
+		if (len(request.AddressIds) == 0 || containsID(request.AddressIds, *address.AddressId)) &&
+			matchFilters(request.Filters, f.tags[*address.AddressId], attrs) {
+			addresses = append(addresses, address)
+		}
+	}
+	response := vpc.NewDescribeAddressesResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(addresses), "AddressSet": addresses})
+}
+
+func (f *fakeVPC) DescribeTaskResult(request *vpc.DescribeTaskResultRequest) (*vpc.DescribeTaskResultResponse, error) {
+	response := vpc.NewDescribeTaskResultResponse()
+	return response, respond(response, map[string]interface{}{"TaskId": request.TaskId, "Result": tencent.Success})
+}
+
+func (f *fakeVPC) tagResource(id string, tags []*vpc.Tag) {
+	for _, t := range tags {
+		f.tag(id, []*string{t.Key}, []*string{t.Value})
+	}
+}
+
+func (f *fakeTag) DescribeResourcesByTags(request *tag.DescribeResourcesByTagsRequest) (*tag.DescribeResourcesByTagsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filters := make([]*vpc.Filter, 0, len(request.TagFilters))
+	for _, filter := range request.TagFilters {
+		filters = append(filters, &vpc.Filter{Name: tencentCommon.StringPtr("tag:" + *filter.TagKey), Values: filter.TagValue})
+	}
+	rows := make([]*tag.ResourceTag, 0)
+	for id, tags := range f.tags {
+		if !matchFilters(filters, tags, nil) {
+			continue
+		}
+		// resource ids of tencent cloud are prefixed with the resource type, e.g. ins-xxx and eip-xxx.
+		resource := resourceTypes[strings.SplitN(id, "-", 2)[0]]
+		row := &tag.ResourceTag{
+			ServiceType:    tencentCommon.StringPtr(resource[0]),
+			ResourcePrefix: tencentCommon.StringPtr(resource[1]),
+			ResourceId:     tencentCommon.StringPtr(id),
+		}
+		for k, v := range tags {
+			row.Tags = append(row.Tags, &tag.Tag{TagKey: tencentCommon.StringPtr(k), TagValue: tencentCommon.StringPtr(v)})
+		}
+		rows = append(rows, row)
+	}
+	response := tag.NewDescribeResourcesByTagsResponse()
+	return response, respond(response, map[string]interface{}{"TotalCount": len(rows), "Rows": rows})
+}
+
+// respond fills the anonymous response struct of tencent cloud sdk with the body.
+func respond(response interface{}, body map[string]interface{}) error {
+	body["RequestId"] = "fake"
+	b, err := json.Marshal(map[string]interface{}{"Response": body})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, response)
+}
+
+// matchFilters matches the tags and attributes of resource with filters, values of the same filter are ORed.
+func matchFilters(filters []*vpc.Filter, tags, attrs map[string]string) bool {
+	for _, filter := range filters {
+		var (
+			value string
+			ok    bool
+		)
+		if strings.HasPrefix(*filter.Name, "tag:") {
+			value, ok = tags[strings.TrimPrefix(*filter.Name, "tag:")]
+		} else {
+			value, ok = attrs[*filter.Name]
+		}
+		if !ok || !containsID(filter.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func findPolicy(policies []*vpc.SecurityGroupPolicy, p *vpc.SecurityGroupPolicy) int {
+	for i, policy := range policies {
+		if strings.EqualFold(*policy.Protocol, *p.Protocol) && *policy.Port == *p.Port && *policy.CidrBlock == *p.CidrBlock {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsID(ids []*string, id string) bool {
+	for _, v := range ids {
+		if v != nil && *v == id {
+			return true
+		}
+	}
+	return false
+}
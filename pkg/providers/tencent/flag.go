@@ -36,6 +36,61 @@ const deleteUsageExample = `  autok3s -d delete \
     --secret-key <secret-key>
 `
 
+const removeNodeUsageExample = `  autok3s -d remove-node \
+    --provider tencent \
+    --name <cluster name> \
+    --node <instance id or ip> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
+const upgradeUsageExample = `  autok3s -d upgrade \
+    --provider tencent \
+    --name <cluster name> \
+    --k3s-version <k3s version> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
+const snapshotUsageExample = `  autok3s -d snapshot save \
+    --provider tencent \
+    --name <cluster name> \
+    --snapshot-name <snapshot name> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+  autok3s snapshot list \
+    --provider tencent \
+    --name <cluster name>
+  autok3s -d snapshot restore \
+    --provider tencent \
+    --name <cluster name> \
+    --snapshot-name <snapshot file name> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
+const checkUsageExample = `  autok3s -d check \
+    --provider tencent \
+    --name <cluster name> \
+    --repair \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
+const startUsageExample = `  autok3s -d start \
+    --provider tencent \
+    --name <cluster name> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
+const stopUsageExample = `  autok3s -d stop \
+    --provider tencent \
+    --name <cluster name> \
+    --secret-id <secret-id> \
+    --secret-key <secret-key>
+`
+
 const sshUsageExample = `  autok3s ssh \
     --provider tencent \
     --name <cluster name> \
@@ -51,6 +106,18 @@ func (p *Tencent) GetUsageExample(action string) string {
 		return joinUsageExample
 	case "delete":
 		return deleteUsageExample
+	case "remove-node":
+		return removeNodeUsageExample
+	case "upgrade":
+		return upgradeUsageExample
+	case "snapshot":
+		return snapshotUsageExample
+	case "check":
+		return checkUsageExample
+	case "start":
+		return startUsageExample
+	case "stop":
+		return stopUsageExample
 	case "ssh":
 		return sshUsageExample
 	default:
@@ -122,6 +189,12 @@ func (p *Tencent) GetDeleteFlags(cmd *cobra.Command) *pflag.FlagSet {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -235,6 +308,9 @@ func (p *Tencent) overwriteMetadata(matched *types.Cluster) {
 	p.InstallScript = matched.InstallScript
 	p.Network = matched.Network
 	// needed to be overwrite.
+	if p.Credential == "" {
+		p.Credential = matched.Credential
+	}
 	if p.K3sChannel == "" {
 		p.K3sChannel = matched.K3sChannel
 	}
@@ -247,6 +323,15 @@ func (p *Tencent) overwriteMetadata(matched *types.Cluster) {
 	if p.Registry == "" {
 		p.Registry = matched.Registry
 	}
+	if p.AirGapDir == "" {
+		p.AirGapDir = matched.AirGapDir
+	}
+	if p.AdminCIDRs == "" {
+		p.AdminCIDRs = matched.AdminCIDRs
+	}
+	if p.FirewallRules == "" {
+		p.FirewallRules = matched.FirewallRules
+	}
 	if p.MasterExtraArgs == "" {
 		p.MasterExtraArgs = matched.MasterExtraArgs
 	}
@@ -265,6 +350,12 @@ func (p *Tencent) sharedFlags() []types.Flag {
 			ShortHand: "n",
 			Required:  true,
 		},
+		{
+			Name:  "credential",
+			P:     &p.Credential,
+			V:     p.Credential,
+			Usage: "Name of the credential profile to use, the default credential is used if not specified",
+		},
 		{
 			Name:   "region",
 			P:      &p.Region,
@@ -388,6 +479,24 @@ func (p *Tencent) sharedFlags() []types.Flag {
 			V:     p.Registry,
 			Usage: "K3s registry file, see: https://rancher.com/docs/k3s/latest/en/installation/private-registry",
 		},
+		{
+			Name:  "airgap-dir",
+			P:     &p.AirGapDir,
+			V:     p.AirGapDir,
+			Usage: "Local directory of k3s binary `k3s`, install script `install.sh` and images tarball `k3s-airgap-images-*`, which are uploaded to nodes for installing without Internet access",
+		},
+		{
+			Name:  "admin-cidrs",
+			P:     &p.AdminCIDRs,
+			V:     p.AdminCIDRs,
+			Usage: "CIDRs allowed to access ssh, kube api-server and ui of the security group created by autok3s, separated by comma, default is 0.0.0.0/0",
+		},
+		{
+			Name:  "firewall-rules",
+			P:     &p.FirewallRules,
+			V:     p.FirewallRules,
+			Usage: "Extra ingress rules of the security group in the format of <protocol>:<port>[-<port>]:<cidr>, separated by comma. e.g.(--firewall-rules 'tcp:30000-32767:0.0.0.0/0')",
+		},
 		{
 			Name:  "datastore",
 			P:     &p.DataStore,
@@ -2,6 +2,7 @@ package tencent
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -79,9 +80,9 @@ type Tencent struct {
 	tencent.Options `json:",inline"`
 	types.Status    `json:"status"`
 
-	c      *cvm.Client
-	v      *vpc.Client
-	t      *tag.Client
+	c      cvmClient
+	v      vpcClient
+	t      tagClient
 	r      *tke.Client
 	m      *sync.Map
 	logger *logrus.Logger
@@ -127,11 +128,19 @@ func (p *Tencent) GetProviderName() string {
 	return ProviderName
 }
 
+func (p *Tencent) GetCredentialProfile() string {
+	return p.Credential
+}
+
+func (p *Tencent) GetClusterName() string {
+	return p.Name
+}
+
 func (p *Tencent) GenerateClusterName() {
 	p.Name = fmt.Sprintf("%s.%s.%s", p.Name, p.Region, p.GetProviderName())
 }
 
-func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
+func (p *Tencent) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
 	logFile, err := common.GetLogFile(p.Name)
 	if err != nil {
 		return err
@@ -163,7 +172,7 @@ func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
 				if p.CloudControllerManager {
 					p.logger.Infof("K3s UI URL: https://<using `kubectl get svc -A` get UI address>:8999")
 				} else {
-					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].PublicIPAddress[0])
+					p.logger.Infof("K3s UI URL: https://%s:8999", p.Status.MasterNodes[0].ExternalIP())
 				}
 			}
 			cluster.SaveClusterState(c, common.StatusRunning)
@@ -184,6 +193,7 @@ func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
 		return err
 	}
 
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(func() error {
 		return nil
 	}, ssh)
@@ -193,7 +203,7 @@ func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
 
 	c.Logger = p.logger
 	// initialize K3s cluster.
-	if err = cluster.InitK3sCluster(c); err != nil {
+	if err = cluster.InitK3sCluster(ctx, c); err != nil {
 		return
 	}
 	p.logger.Infof("[%s] successfully executed create logic\n", p.GetProviderName())
@@ -219,7 +229,7 @@ func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
 				base64.StdEncoding.EncodeToString([]byte(tmpl)), common.K3sManifestsDir))
 		}
 		p.logger.Infof("[%s] start deploy tencent additional manifests\n", p.GetProviderName())
-		if err := cluster.DeployExtraManifest(c, extraManifests); err != nil {
+		if err := cluster.DeployExtraManifest(ctx, c, extraManifests); err != nil {
 			return err
 		}
 		p.logger.Infof("[%s] successfully deploy tencent additional manifests\n", p.GetProviderName())
@@ -228,7 +238,7 @@ func (p *Tencent) CreateK3sCluster(ssh *types.SSH) (err error) {
 	return nil
 }
 
-func (p *Tencent) JoinK3sNode(ssh *types.SSH) (err error) {
+func (p *Tencent) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
 	if p.m == nil {
 		p.m = new(syncmap.Map)
 	}
@@ -266,6 +276,7 @@ func (p *Tencent) JoinK3sNode(ssh *types.SSH) (err error) {
 	if err != nil {
 		return err
 	}
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
 	c, err = p.generateInstance(p.joinCheck, ssh)
 	if err != nil {
 		return err
@@ -293,7 +304,7 @@ func (p *Tencent) JoinK3sNode(ssh *types.SSH) (err error) {
 	c.Logger = p.logger
 	added.Logger = p.logger
 	// join K3s node.
-	if err := cluster.JoinK3sNode(c, added); err != nil {
+	if err := cluster.JoinK3sNode(ctx, c, added); err != nil {
 		return err
 	}
 
@@ -383,7 +394,7 @@ func (p *Tencent) Rollback() error {
 	return logFile.Close()
 }
 
-func (p *Tencent) DeleteK3sCluster(f bool) error {
+func (p *Tencent) DeleteK3sCluster(ctx context.Context, f bool) error {
 	isConfirmed := true
 
 	if !f {
@@ -405,6 +416,7 @@ func (p *Tencent) DeleteK3sCluster(f bool) error {
 		}()
 		p.logger = common.NewLogger(common.Debug, logFile)
 		p.logger.Infof("[%s] executing delete cluster logic...\n", p.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
 
 		if err := p.generateClientSDK(); err != nil {
 			return err
@@ -420,6 +432,344 @@ func (p *Tencent) DeleteK3sCluster(f bool) error {
 	return nil
 }
 
+func (p *Tencent) RemoveK3sNode(node string, f bool) error {
+	n, ok := putil.FindNode(p.Status, node)
+	if !ok {
+		return fmt.Errorf("[%s] calling preflight error: node `%s` do not exist in cluster %s", p.GetProviderName(), node, p.Name)
+	}
+
+	isConfirmed := true
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to remove node %s from cluster %s", p.GetProviderName(), n.InstanceID, p.Name))
+	}
+	if !isConfirmed {
+		return nil
+	}
+
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing remove node logic...\n", p.GetProviderName())
+
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RemoveK3sNode(c, n); err != nil {
+		return err
+	}
+
+	p.releaseNodeAddresses(n.EipAllocationIds)
+
+	p.logger.Debugf("[%s] instance %s will be deleted\n", p.GetProviderName(), n.InstanceID)
+	if err := p.terminateInstances([]string{n.InstanceID}); err != nil {
+		return err
+	}
+
+	// sync master/worker count
+	p.Status = putil.RemoveNode(p.Status, n.InstanceID)
+	p.Metadata.Master = strconv.Itoa(len(p.Status.MasterNodes))
+	p.Metadata.Worker = strconv.Itoa(len(p.Status.WorkerNodes))
+	c = &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed remove node logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Tencent) UpgradeK3sCluster(ctx context.Context, version string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		// the cluster is still running with origin version after rolled back.
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove upgrade state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusUpgrade)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing upgrade logic...\n", p.GetProviderName())
+
+	c.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(c, common.StatusUpgrade)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.UpgradeK3sCluster(ctx, c, version); err != nil {
+		return err
+	}
+	p.K3sVersion = version
+
+	p.logger.Infof("[%s] successfully executed upgrade logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Tencent) SaveSnapshot(name string) (*types.Snapshot, error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing save snapshot logic...\n", p.GetProviderName())
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	snapshot, err := cluster.SaveSnapshot(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.Infof("[%s] successfully executed save snapshot logic\n", p.GetProviderName())
+	return snapshot, nil
+}
+
+func (p *Tencent) ListSnapshots() ([]types.Snapshot, error) {
+	return cluster.ListSnapshots(&types.Cluster{Metadata: p.Metadata})
+}
+
+func (p *Tencent) RestoreSnapshot(name string) (err error) {
+	logFile, err := common.GetLogFile(p.Name)
+	if err != nil {
+		return err
+	}
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	defer func() {
+		c.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(c, common.StatusRunning)
+		// remove restore state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", p.Name, common.StatusRestore)))
+		logFile.Close()
+	}()
+
+	p.logger = common.NewLogger(common.Debug, logFile)
+	p.logger.Infof("[%s] executing restore snapshot logic...\n", p.GetProviderName())
+
+	c.Status.Status = "restoring"
+	err = cluster.SaveClusterState(c, common.StatusRestore)
+	if err != nil {
+		return err
+	}
+
+	c.Logger = p.logger
+	if err = cluster.RestoreSnapshot(c, name); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed restore snapshot logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Tencent) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing check logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return nil, err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return nil, err
+	}
+
+	if repair {
+		ids := make([]string, 0)
+		p.m.Range(func(key, value interface{}) bool {
+			if value.(types.Node).InstanceStatus == tencent.StatusStopped {
+				ids = append(ids, key.(string))
+			}
+			return true
+		})
+		if len(ids) > 0 {
+			p.logger.Infof("[%s] starting stopped instances %s...\n", p.GetProviderName(), ids)
+			if err := p.startInstances(ids); err != nil {
+				return nil, err
+			}
+			if err := p.getInstanceStatus(tencent.StatusRunning); err != nil {
+				return nil, err
+			}
+			// public ip address may be changed after instance restarted.
+			if _, err := p.syncClusterInstance(ssh); err != nil {
+				return nil, err
+			}
+			p.syncNodeAddresses()
+		}
+	}
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	reports, err := cluster.CheckK3sCluster(c)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if reports, err = cluster.RepairK3sCluster(c, reports); err != nil {
+			return nil, err
+		}
+		if err := cluster.SaveState(c); err != nil {
+			return nil, fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+		}
+	}
+
+	p.logger.Infof("[%s] successfully executed check logic\n", p.GetProviderName())
+	return reports, nil
+}
+
+func (p *Tencent) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// instances are created when creating cluster or joining nodes, pre-flight checks are executed on them before installing k3s.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, instances don't exist until created", p.GetProviderName())
+}
+
+func (p *Tencent) StartK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing start logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	ssh := p.GetSSHConfig()
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+
+	// record public ip addresses, which may be changed after instances started.
+	previous := make(map[string]string)
+	ids := make([]string, 0)
+	for _, nodes := range [][]types.Node{p.Status.MasterNodes, p.Status.WorkerNodes} {
+		for _, node := range nodes {
+			if len(node.PublicIPAddress) > 0 {
+				previous[node.InstanceID] = node.PublicIPAddress[0]
+			}
+			if node.InstanceStatus == tencent.StatusStopped {
+				ids = append(ids, node.InstanceID)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] starting instances %s...\n", p.GetProviderName(), ids)
+		if err := p.startInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(tencent.StatusRunning); err != nil {
+		return err
+	}
+	if _, err := p.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+	p.syncNodeAddresses()
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+		Logger:   p.logger,
+	}
+	if err := cluster.RefreshNodeAddresses(c, previous); err != nil {
+		return err
+	}
+
+	c.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(c, common.StatusRunning); err != nil {
+		return err
+	}
+
+	p.logger.Infof("[%s] successfully executed start logic\n", p.GetProviderName())
+	return nil
+}
+
+func (p *Tencent) StopK3sCluster() error {
+	p.logger = common.NewLogger(common.Debug, nil)
+	p.logger.Infof("[%s] executing stop logic...\n", p.GetProviderName())
+	if err := p.generateClientSDK(); err != nil {
+		return err
+	}
+
+	if _, err := p.syncClusterInstance(p.GetSSHConfig()); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0)
+	p.m.Range(func(key, value interface{}) bool {
+		if value.(types.Node).InstanceStatus != tencent.StatusStopped {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+	if len(ids) > 0 {
+		p.logger.Infof("[%s] stopping instances %s...\n", p.GetProviderName(), ids)
+		if err := p.stopInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := p.getInstanceStatus(tencent.StatusStopped); err != nil {
+		return err
+	}
+	p.syncNodeStatusWithInstance(nil)
+
+	c := &types.Cluster{
+		Metadata: p.Metadata,
+		Options:  p.Options,
+		Status:   p.Status,
+	}
+	c.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(c); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", p.GetProviderName(), err)
+	}
+
+	p.logger.Infof("[%s] successfully executed stop logic\n", p.GetProviderName())
+	return nil
+}
+
 func (p *Tencent) SSHK3sNode(ssh *types.SSH, ip string) error {
 	p.logger = common.NewLogger(common.Debug, nil)
 	p.logger.Infof("[%s] executing ssh logic...\n", p.GetProviderName())
@@ -435,8 +785,13 @@ func (p *Tencent) SSHK3sNode(ssh *types.SSH, ip string) error {
 
 	ids := make(map[string]string, len(instanceList))
 	for _, instance := range instanceList {
-		if instance.PublicIpAddresses != nil {
-			instanceInfo := *instance.PublicIpAddresses[0]
+		// nodes without public ip are connected through bastions by internal ip.
+		addresses := instance.PublicIpAddresses
+		if len(addresses) == 0 {
+			addresses = instance.PrivateIpAddresses
+		}
+		if len(addresses) > 0 {
+			instanceInfo := *addresses[0]
 			for _, t := range instance.Tags {
 				if *t.Key != "master" && *t.Key != "worker" {
 					continue
@@ -702,11 +1057,11 @@ func (p *Tencent) SetConfig(config []byte) error {
 
 func (p *Tencent) generateClientSDK() error {
 	if p.SecretID == "" {
-		p.SecretID = viper.GetString(p.GetProviderName(), secretID)
+		p.SecretID = viper.GetCredential(p.GetProviderName(), p.Credential, secretID)
 	}
 
 	if p.SecretKey == "" {
-		p.SecretKey = viper.GetString(p.GetProviderName(), secretKey)
+		p.SecretKey = viper.GetCredential(p.GetProviderName(), p.Credential, secretKey)
 	}
 
 	credential := tencentCommon.NewCredential(
@@ -717,24 +1072,11 @@ func (p *Tencent) generateClientSDK() error {
 	if p.EndpointURL != "" {
 		cpf.HttpProfile.Endpoint = p.EndpointURL
 	}
-	if client, err := cvm.NewClient(credential, p.Region, cpf); err == nil {
-		p.c = client
-	} else {
-		return err
-	}
-
-	if vpcClient, err := vpc.NewClient(credential, p.Region, cpf); err == nil {
-		p.v = vpcClient
-	} else {
-		return err
-	}
-
-	// region for tag clients is not necessary
-	if tagClient, err := tag.NewClient(credential, p.Region, cpf); err == nil {
-		p.t = tagClient
-	} else {
+	c, v, t, err := newClientSDK(credential, p.Region, cpf)
+	if err != nil {
 		return err
 	}
+	p.c, p.v, p.t = c, v, t
 
 	if tkeClient, err := tke.NewClient(credential, p.Region, cpf); err == nil {
 		p.r = tkeClient
@@ -780,6 +1122,10 @@ func (p *Tencent) generateInstance(fn checkFun, ssh *types.SSH) (*types.Cluster,
 		if err != nil {
 			return nil, err
 		}
+	} else if p.AdminCIDRs != "" || p.FirewallRules != "" {
+		if err = p.configDefaultSecurityPermission(); err != nil {
+			return nil, err
+		}
 	}
 
 	needUploadKeyPair := false
@@ -952,6 +1298,12 @@ func (p *Tencent) deleteCluster(f bool) error {
 }
 
 func (p *Tencent) CreateCheck(ssh *types.SSH) error {
+	if err := cluster.CheckAirGap(p.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
+	if _, err := putil.FirewallPolicy(p.Metadata, ""); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", p.GetProviderName(), err)
+	}
 	if p.KeyIds != "" && ssh.SSHKeyPath == "" {
 		return fmt.Errorf("[%s] calling preflight error: --ssh-key-path must set with --key-pair %s", p.GetProviderName(), p.KeyIds)
 	}
@@ -1123,7 +1475,8 @@ func (p *Tencent) runInstances(num int, master bool, password string) error {
 	request.InternetAccessible = &cvm.InternetAccessible{
 		InternetChargeType:      tencentCommon.StringPtr(internetChargeType),
 		InternetMaxBandwidthOut: tencentCommon.Int64Ptr(bandwidth),
-		PublicIpAssigned:        tencentCommon.BoolPtr(!p.PublicIPAssignedEIP),
+		// no public ip is assigned if bandwidth is 0, instances need to be connected through bastions.
+		PublicIpAssigned: tencentCommon.BoolPtr(!p.PublicIPAssignedEIP && bandwidth > 0),
 	}
 
 	// tags
@@ -1304,6 +1657,34 @@ func (p *Tencent) disassociateAddress(addressID string) (uint64, error) {
 	return taskID, nil
 }
 
+func (p *Tencent) releaseNodeAddresses(addressIds []string) {
+	if len(addressIds) == 0 {
+		return
+	}
+
+	for _, addressID := range addressIds {
+		taskID, err := p.disassociateAddress(addressID)
+		if err != nil {
+			p.logger.Errorf("[%s] error when disassociating eip %s, message: %v", p.GetProviderName(), addressID, err)
+			continue
+		}
+		if taskID != 0 {
+			if err := p.describeVpcTaskResult(taskID); err != nil {
+				p.logger.Errorf("[%s] error when query eip disassociate task result, message: %v", p.GetProviderName(), err)
+			}
+		}
+	}
+
+	taskID, err := p.releaseAddresses(addressIds)
+	if err != nil {
+		p.logger.Errorf("[%s] failed to release eip %v, message: %v", p.GetProviderName(), addressIds, err)
+		return
+	}
+	if err := p.describeVpcTaskResult(taskID); err != nil {
+		p.logger.Errorf("[%s] failed to query release eip task result, message: %v", p.GetProviderName(), err)
+	}
+}
+
 func (p *Tencent) describeVpcTaskResult(taskID uint64) error {
 	request := vpc.NewDescribeTaskResultRequest()
 	request.TaskId = tencentCommon.Uint64Ptr(taskID)
@@ -1333,6 +1714,42 @@ func (p *Tencent) describeVpcTaskResult(taskID uint64) error {
 	return nil
 }
 
+func (p *Tencent) startInstances(ids []string) error {
+	request := cvm.NewStartInstancesRequest()
+	request.InstanceIds = tencentCommon.StringPtrs(ids)
+
+	if _, err := p.c.StartInstances(request); err != nil {
+		return fmt.Errorf("[%s] calling startInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+func (p *Tencent) stopInstances(ids []string) error {
+	request := cvm.NewStopInstancesRequest()
+	request.InstanceIds = tencentCommon.StringPtrs(ids)
+
+	if _, err := p.c.StopInstances(request); err != nil {
+		return fmt.Errorf("[%s] calling stopInstances error, msg: %v", p.GetProviderName(), err)
+	}
+	return nil
+}
+
+// syncNodeAddresses updates ip addresses of nodes with the synchronized instances.
+func (p *Tencent) syncNodeAddresses() {
+	p.m.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := p.Status.WorkerNodes
+		if v.Master {
+			nodes = p.Status.MasterNodes
+		}
+		if index, b := putil.IsExistedNodes(nodes, v.InstanceID); b {
+			nodes[index].InternalIPAddress = v.InternalIPAddress
+			nodes[index].PublicIPAddress = v.PublicIPAddress
+		}
+		return true
+	})
+}
+
 func (p *Tencent) getInstanceStatus(aimStatus string) error {
 	ids := make([]string, 0)
 	p.m.Range(func(key, value interface{}) bool {
@@ -1563,8 +1980,10 @@ func (p *Tencent) generateDefaultSecurityGroup() error {
 	return nil
 }
 
+// configDefaultSecurityPermission reconciles the ingress rules of security group with the firewall policy,
+// only rules generated by autok3s for the cluster are revoked, the default security group is shared by clusters in the vpc.
 func (p *Tencent) configDefaultSecurityPermission() error {
-	p.logger.Debugf("[%s] check rules of security group %s\n", p.GetProviderName(), defaultSecurityGroupName)
+	p.logger.Debugf("[%s] check rules of security group %s\n", p.GetProviderName(), p.SecurityGroupIds)
 	// get security group rules
 	request := vpc.NewDescribeSecurityGroupPoliciesRequest()
 	request.SecurityGroupId = tencentCommon.StringPtr(p.SecurityGroupIds)
@@ -1582,128 +2001,59 @@ func (p *Tencent) configDefaultSecurityPermission() error {
 	} else {
 		cidr = subnetCidrBlock
 	}
-	hasSSHPort := false
-	hasAPIServerPort := false
-	hasKubeletPort := false
-	hasVXlanPort := false
-	hasUIPort := false
+	desired, err := putil.FirewallPolicy(p.Metadata, cidr)
+	if err != nil {
+		return err
+	}
+
+	existing := make([]putil.FirewallRule, 0)
 	hasEgress := false
-	hasEtcdServerPort := false
-	hasEtcdPeerPort := false
-	if response != nil && response.Response != nil &&
-		response.Response.SecurityGroupPolicySet != nil && response.Response.SecurityGroupPolicySet.Ingress != nil {
-		rules := response.Response.SecurityGroupPolicySet.Ingress
-		for _, rule := range rules {
-			ports := *rule.Port
-			portArray := strings.Split(ports, ",")
-			for _, p := range portArray {
-				fromPort, _ := strconv.Atoi(p)
-				switch fromPort {
-				case 22:
-					hasSSHPort = true
-				case 6443:
-					hasAPIServerPort = true
-				case 10250:
-					hasKubeletPort = true
-				case 8472:
-					hasVXlanPort = true
-				case 8999:
-					hasUIPort = true
-				case 2379:
-					if *rule.CidrBlock == cidr || *rule.CidrBlock == ipRange {
-						hasEtcdServerPort = true
-					}
-				case 2380:
-					if *rule.CidrBlock == cidr || *rule.CidrBlock == ipRange {
-						hasEtcdPeerPort = true
-					}
-				}
+	if response != nil && response.Response != nil && response.Response.SecurityGroupPolicySet != nil {
+		for _, rule := range response.Response.SecurityGroupPolicySet.Ingress {
+			if rule.CidrBlock == nil || rule.Port == nil || rule.Protocol == nil || rule.Action == nil ||
+				*rule.CidrBlock == "" || !strings.EqualFold(*rule.Action, "ACCEPT") {
+				continue
 			}
-
-		}
-		eRules := response.Response.SecurityGroupPolicySet.Egress
-		if len(eRules) > 0 {
+			// rules of all ports or multiple ports are not generated by autok3s.
+			fromPort, toPort, err := putil.ParsePortRange(*rule.Port)
+			if err != nil {
+				continue
+			}
+			description := ""
+			if rule.PolicyDescription != nil {
+				description = *rule.PolicyDescription
+			}
+			existing = append(existing, putil.FirewallRule{
+				Protocol:    strings.ToLower(*rule.Protocol),
+				FromPort:    fromPort,
+				ToPort:      toPort,
+				CIDR:        *rule.CidrBlock,
+				Description: description,
+			})
+		}
+		if len(response.Response.SecurityGroupPolicySet.Egress) > 0 {
 			hasEgress = true
 		}
 	}
 
-	perms := []*vpc.SecurityGroupPolicy{}
-
-	if !hasSSHPort {
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("22"),
-			CidrBlock:         tencentCommon.StringPtr(ipRange),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for ssh(generated by autok3s)"),
-		})
-	}
-
-	if (p.Network == "" || p.Network == "vxlan") && !hasVXlanPort {
-		// udp 8472 for flannel vxlan
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("UDP"),
-			Port:              tencentCommon.StringPtr("8472"),
-			CidrBlock:         tencentCommon.StringPtr(ipRange),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for k3s vxlan(generated by autok3s)"),
-		})
-	}
-
-	// port 6443 for kubernetes api-server
-	if !hasAPIServerPort {
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("6443"),
-			CidrBlock:         tencentCommon.StringPtr(ipRange),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for kube api-server(generated by autok3s)"),
-		})
-	}
-
-	// 10250 for kubelet
-	if !hasKubeletPort {
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("10250"),
-			CidrBlock:         tencentCommon.StringPtr(ipRange),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for kubelet(generated by autok3s)"),
-		})
-	}
-
-	if !hasEtcdServerPort || !hasEtcdPeerPort {
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("2379"),
-			CidrBlock:         tencentCommon.StringPtr(cidr),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for etcd(generated by autok3s)"),
-		})
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("2380"),
-			CidrBlock:         tencentCommon.StringPtr(cidr),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for etcd(generated by autok3s)"),
-		})
-	}
-
-	if p.UI && !hasUIPort {
-		perms = append(perms, &vpc.SecurityGroupPolicy{
-			Protocol:          tencentCommon.StringPtr("TCP"),
-			Port:              tencentCommon.StringPtr("8999"),
-			CidrBlock:         tencentCommon.StringPtr(ipRange),
-			Action:            tencentCommon.StringPtr("ACCEPT"),
-			PolicyDescription: tencentCommon.StringPtr("accept for dashboard(generated by autok3s)"),
-		})
+	authorize, revoke := putil.ReconcileFirewall(p.Name, desired, existing)
+	if len(revoke) > 0 {
+		args := vpc.NewDeleteSecurityGroupPoliciesRequest()
+		args.SecurityGroupId = tencentCommon.StringPtr(p.SecurityGroupIds)
+		args.SecurityGroupPolicySet = &vpc.SecurityGroupPolicySet{
+			Ingress: securityGroupPolicies(revoke),
+		}
+		_, err = p.v.DeleteSecurityGroupPolicies(args)
+		if err != nil {
+			return err
+		}
 	}
 
-	if len(perms) > 0 {
+	if len(authorize) > 0 {
 		args := vpc.NewCreateSecurityGroupPoliciesRequest()
 		args.SecurityGroupId = tencentCommon.StringPtr(p.SecurityGroupIds)
 		args.SecurityGroupPolicySet = &vpc.SecurityGroupPolicySet{
-			Ingress: perms,
+			Ingress: securityGroupPolicies(authorize),
 		}
 		_, err = p.v.CreateSecurityGroupPolicies(args)
 		if err != nil {
@@ -1735,6 +2085,20 @@ func (p *Tencent) configDefaultSecurityPermission() error {
 	return nil
 }
 
+func securityGroupPolicies(rules []putil.FirewallRule) []*vpc.SecurityGroupPolicy {
+	perms := make([]*vpc.SecurityGroupPolicy, 0, len(rules))
+	for _, r := range rules {
+		perms = append(perms, &vpc.SecurityGroupPolicy{
+			Protocol:          tencentCommon.StringPtr(strings.ToUpper(r.Protocol)),
+			Port:              tencentCommon.StringPtr(r.Port()),
+			CidrBlock:         tencentCommon.StringPtr(r.CIDR),
+			Action:            tencentCommon.StringPtr("ACCEPT"),
+			PolicyDescription: tencentCommon.StringPtr(r.Description),
+		})
+	}
+	return perms
+}
+
 func (p *Tencent) allocateEIPForInstance(num int, master bool) ([]uint64, error) {
 	eipIds := []uint64{}
 	eips, taskID, err := p.allocateAddresses(num)
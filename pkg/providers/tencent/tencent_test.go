@@ -0,0 +1,187 @@
+package tencent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers/fake"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/types/tencent"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	tencentCommon "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+func TestTencent(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tencent Provider Suite")
+}
+
+var _ = Describe("Tencent provider with fake cvm, vpc and tag clients", func() {
+	var (
+		cloud       *fakeCloud
+		ssh         *types.SSH
+		cleanup     func()
+		newClient   func(credential *tencentCommon.Credential, region string, cpf *profile.ClientProfile) (cvmClient, vpcClient, tagClient, error)
+		newTencent  func(master, worker string) *Tencent
+		terminating func() []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		cleanup, err = fake.SetupCfgPath()
+		Expect(err).NotTo(HaveOccurred())
+
+		cloud = newFakeCloud()
+		newClient = newClientSDK
+		newClientSDK = func(credential *tencentCommon.Credential, region string, cpf *profile.ClientProfile) (cvmClient, vpcClient, tagClient, error) {
+			return &fakeCVM{cloud}, &fakeVPC{cloud}, &fakeTag{cloud}, nil
+		}
+		ssh = &types.SSH{User: defaultUser, Port: "22", SSHKeyPath: "/dev/null"}
+		newTencent = func(master, worker string) *Tencent {
+			p := NewProvider()
+			p.Name = "fake"
+			p.Master = master
+			p.Worker = worker
+			p.SecretID = "fake"
+			p.SecretKey = "fake"
+			p.KeyIds = "fake"
+			p.logger = fake.Logger()
+			return p
+		}
+		terminating = func() []string {
+			ids := make([]string, 0)
+			for _, instance := range cloud.instances {
+				if *instance.InstanceState == statusTerminating {
+					ids = append(ids, *instance.InstanceId)
+				}
+			}
+			return ids
+		}
+	})
+
+	AfterEach(func() {
+		newClientSDK = newClient
+		cleanup()
+	})
+
+	It("creates, joins, rolls back and deletes the instances of cluster with eip", func() {
+		p := newTencent("1", "1")
+		p.PublicIPAssignedEIP = true
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+
+		c, err := p.generateInstance(func() error { return nil }, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.MasterNodes).To(HaveLen(1))
+		Expect(c.WorkerNodes).To(HaveLen(1))
+		Expect(c.MasterNodes[0].InstanceStatus).To(Equal(tencent.StatusRunning))
+		Expect(c.MasterNodes[0].PublicIPAddress).To(ConsistOf(*cloud.addresses[0].AddressIp))
+		Expect(p.VpcID).To(Equal(*cloud.vpcs[0].VpcId))
+		Expect(p.SubnetID).To(Equal(*cloud.subnets[0].SubnetId))
+		Expect(p.SecurityGroupIds).To(Equal(*cloud.securityGroups[0].SecurityGroupId))
+		Expect(cloud.policies[p.SecurityGroupIds].Ingress).NotTo(BeEmpty())
+		Expect(cloud.policies[p.SecurityGroupIds].Egress).To(HaveLen(1))
+		Expect(cloud.addresses).To(HaveLen(2))
+
+		// join a worker with the cluster state, and roll it back.
+		j := newTencent("0", "1")
+		j.PublicIPAssignedEIP = true
+		j.Status = fake.LoadStatus(c.Status)
+		j.VpcID = p.VpcID
+		j.SubnetID = p.SubnetID
+		j.SecurityGroupIds = p.SecurityGroupIds
+		joined, err := j.generateInstance(j.joinCheck, ssh)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(joined.MasterNodes).To(HaveLen(1))
+		Expect(joined.WorkerNodes).To(HaveLen(2))
+		Expect(cloud.addresses).To(HaveLen(3))
+
+		Expect(j.Rollback()).To(Succeed())
+		Expect(terminating()).To(HaveLen(1))
+		Expect(cloud.addresses).To(HaveLen(2))
+		exist, ids, err := p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeTrue())
+		Expect(ids).To(ConsistOf(c.MasterNodes[0].InstanceID, c.WorkerNodes[0].InstanceID))
+
+		Expect(p.DeleteK3sCluster(context.Background(), true)).To(Succeed())
+		Expect(terminating()).To(HaveLen(3))
+		Expect(cloud.addresses).To(BeEmpty())
+		exist, _, err = p.IsClusterExist()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exist).To(BeFalse())
+	})
+
+	It("creates and joins the k3s cluster on the instances through ssh", func() {
+		server, err := fake.NewSSHServer()
+		Expect(err).NotTo(HaveOccurred())
+		defer server.Close()
+		ssh.Password = "fake"
+		ssh.Bastions = []types.Bastion{server.Bastion()}
+
+		p := newTencent("1", "1")
+		p.PublicIPAssignedEIP = true
+		p.Token = "fake-token"
+		p.UI = true
+		Expect(p.CreateCheck(ssh)).To(Succeed())
+		Expect(p.CreateK3sCluster(context.Background(), ssh)).To(Succeed())
+		Expect(p.Status.MasterNodes).To(HaveLen(1))
+		Expect(p.Status.WorkerNodes).To(HaveLen(1))
+
+		master := server.Node(p.Status.MasterNodes[0].PublicIPAddress[0])
+		masterIP := p.Status.MasterNodes[0].InternalIPAddress[0]
+		Expect(master.Commands()).To(ContainElement(And(
+			ContainSubstring("K3S_TOKEN='fake-token'"), ContainSubstring("INSTALL_K3S_EXEC='server"))))
+		ui, ok := master.ReadFile(filepath.Join(common.K3sManifestsDir, "ui.yaml"))
+		Expect(ok).To(BeTrue())
+		Expect(ui).NotTo(BeEmpty())
+		worker := server.Node(p.Status.WorkerNodes[0].PublicIPAddress[0])
+		Expect(worker.Commands()).To(ContainElement(And(
+			ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+
+		// kubeconfig of cluster is merged, and the cluster is saved in state.
+		kubeCfg, err := ioutil.ReadFile(filepath.Join(common.CfgPath, common.KubeCfgFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(kubeCfg)).To(ContainSubstring(fmt.Sprintf("https://%s:6443", p.Status.MasterNodes[0].PublicIPAddress[0])))
+		state, err := cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Status.Status).To(Equal(common.StatusRunning))
+		Expect(state.Token).To(Equal("fake-token"))
+
+		// join a worker to the cluster in state.
+		j := newTencent("0", "1")
+		j.PublicIPAssignedEIP = true
+		j.Status = fake.LoadStatus(state.Status)
+		j.Token = state.Token
+		j.IP = state.IP
+		j.VpcID = p.VpcID
+		j.SubnetID = p.SubnetID
+		j.SecurityGroupIds = p.SecurityGroupIds
+		Expect(j.JoinK3sNode(context.Background(), ssh)).To(Succeed())
+		Expect(j.Status.WorkerNodes).To(HaveLen(2))
+		for _, n := range j.Status.WorkerNodes {
+			if n.InstanceID == p.Status.WorkerNodes[0].InstanceID {
+				continue
+			}
+			Expect(server.Node(n.PublicIPAddress[0]).Commands()).To(ContainElement(And(
+				ContainSubstring(fmt.Sprintf("K3S_URL='https://%s:6443'", masterIP)), ContainSubstring("K3S_TOKEN='fake-token'"))))
+		}
+		state, err = cluster.GetClusterByID(p.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Worker).To(Equal("2"))
+	})
+
+	It("fails to join the cluster which doesn't exist", func() {
+		p := newTencent("0", "1")
+		_, err := p.generateInstance(p.joinCheck, ssh)
+		Expect(err).To(HaveOccurred())
+		Expect(cloud.instances).To(BeEmpty())
+	})
+})
@@ -0,0 +1,1153 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/types"
+	"github.com/cnrancher/autok3s/pkg/utils"
+
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/syncmap"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ccmManifest is the manifest of cloud controller manager in the auto-deploying manifests folder of k3s.
+const ccmManifest = "cloud-controller-manager.yaml"
+
+// Instance is the instance of cloud which runs a node of cluster.
+type Instance struct {
+	ID        string
+	Master    bool
+	Status    string
+	PublicIP  string
+	PrivateIP string
+}
+
+// CloudDriver operates the instances of cluster and the resources they use through the api of cloud.
+type CloudDriver interface {
+	// GetSSHConfig returns the default ssh config of instances.
+	GetSSHConfig() *types.SSH
+	// GetRegion returns the region where the instances of cluster run.
+	GetRegion() string
+	// NewClient creates the client of cloud api with the credential of provider.
+	NewClient() error
+	// PrepareResources creates or reconciles the resources which instances are created with, such as ssh keys and networks.
+	PrepareResources(ssh *types.SSH) error
+	// RunInstance creates an instance of master or worker in cluster.
+	RunInstance(master bool) (*Instance, error)
+	// ConfigResources creates or reconciles the resources which are applied to the running instances, such as firewalls.
+	ConfigResources() error
+	// ListInstances returns all instances of cluster.
+	ListInstances() ([]Instance, error)
+	StartInstances(ids []string) error
+	StopInstances(ids []string) error
+	// RemoveInstances deletes instances, and returns when they are deleted.
+	RemoveInstances(ids []string) error
+	// RemoveResources deletes the resources created for cluster.
+	RemoveResources() error
+	// CloudControllerManifest returns the manifest of cloud controller manager deployed to masters.
+	CloudControllerManifest() (string, error)
+}
+
+// Cloud implements the cluster logic of providers whose nodes are instances of cloud,
+// the instances are operated by the driver of provider.
+type Cloud struct {
+	Driver   CloudDriver
+	Metadata *types.Metadata
+	// Options is the pointer to options of provider.
+	Options interface{}
+	Status  *types.Status
+	// RunningStatus and StoppedStatus are the status of running and stopped instances.
+	RunningStatus string
+	StoppedStatus string
+
+	// Nodes are the nodes of cluster operated by the current command, keyed by instance id.
+	Nodes  *sync.Map
+	Logger *logrus.Logger
+}
+
+func (c *Cloud) GetProviderName() string {
+	return c.Metadata.Provider
+}
+
+func (c *Cloud) CreateK3sCluster(ctx context.Context, ssh *types.SSH) (err error) {
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	k := c.cluster()
+	defer func() {
+		if err != nil {
+			c.Logger.Errorf("[%s] failed to create cluster: %v", c.GetProviderName(), err)
+			if k == nil {
+				k = c.cluster()
+			}
+			k.Status.Status = common.StatusFailed
+			cluster.SaveClusterState(k, common.StatusFailed)
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusCreating)))
+		}
+		if err == nil && len(c.Status.MasterNodes) > 0 {
+			c.Logger.Info(common.UsageInfoTitle)
+			c.Logger.Infof(common.UsageContext, c.Metadata.Name)
+			c.Logger.Info(common.UsagePods)
+			if c.Metadata.UI {
+				c.Logger.Infof("K3s UI URL: https://%s:8999", c.Status.MasterNodes[0].ExternalIP())
+			}
+			cluster.SaveClusterState(k, common.StatusRunning)
+			// remove creating state file and save running state
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusCreating)))
+		}
+		logFile.Close()
+	}()
+
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing create logic...\n", c.GetProviderName())
+	c.defaultSSH(ssh)
+
+	k.Status.Status = common.StatusCreating
+	err = cluster.SaveClusterState(k, common.StatusCreating)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	k, err = c.GenerateInstance(func() error {
+		return nil
+	}, ssh)
+	if err != nil {
+		return err
+	}
+	k.Logger = c.Logger
+	if err = cluster.InitK3sCluster(ctx, k); err != nil {
+		return err
+	}
+	c.Logger.Infof("[%s] successfully executed create logic\n", c.GetProviderName())
+
+	if k.CloudControllerManager {
+		manifest, err := c.Driver.CloudControllerManifest()
+		if err != nil {
+			return err
+		}
+		c.Logger.Infof("[%s] start deploy cloud controller manager manifests\n", c.GetProviderName())
+		if err := cluster.DeployManifest(ctx, k, ccmManifest, []byte(manifest)); err != nil {
+			return err
+		}
+		c.Logger.Infof("[%s] successfully deploy cloud controller manager manifests\n", c.GetProviderName())
+	}
+	return nil
+}
+
+func (c *Cloud) JoinK3sNode(ctx context.Context, ssh *types.SSH) (err error) {
+	if c.Nodes == nil {
+		c.Nodes = new(syncmap.Map)
+	}
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	k := c.cluster()
+	defer func() {
+		if err != nil {
+			if k != nil {
+				k.Status.Status = common.StatusFailed
+				cluster.SaveClusterState(k, common.StatusFailed)
+			}
+		} else {
+			cluster.SaveClusterState(k, common.StatusRunning)
+		}
+		// remove join state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusJoin)))
+		logFile.Close()
+	}()
+
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing join logic...\n", c.GetProviderName())
+	c.defaultSSH(ssh)
+
+	k.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(k, common.StatusJoin)
+	if err != nil {
+		return err
+	}
+
+	cluster.ReportPhase(ctx, types.PhaseProvisioning)
+	k, err = c.GenerateInstance(c.JoinCheck, ssh)
+	if err != nil {
+		return err
+	}
+
+	added := &types.Cluster{
+		Metadata: k.Metadata,
+		Options:  k.Options,
+		Status:   types.Status{},
+	}
+
+	c.Nodes.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		// filter the number of nodes that are not generated by current command.
+		if v.Current {
+			if v.Master {
+				added.Status.MasterNodes = append(added.Status.MasterNodes, v)
+			} else {
+				added.Status.WorkerNodes = append(added.Status.WorkerNodes, v)
+			}
+		}
+		return true
+	})
+
+	k.Logger = c.Logger
+	added.Logger = c.Logger
+	// join K3s node.
+	if err := cluster.JoinK3sNode(ctx, k, added); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] successfully executed join logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) DeleteK3sCluster(ctx context.Context, f bool) error {
+	isConfirmed := true
+
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to delete cluster %s", c.GetProviderName(), c.Metadata.Name))
+	}
+	if isConfirmed {
+		logFile, err := common.GetLogFile(c.Metadata.Name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			logFile.Close()
+			// remove log file
+			os.Remove(filepath.Join(common.GetLogPath(), c.Metadata.Name))
+			// remove state file
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusRunning)))
+			os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusFailed)))
+		}()
+		c.Logger = common.NewLogger(common.Debug, logFile)
+		c.Logger.Infof("[%s] executing delete cluster logic...\n", c.GetProviderName())
+		cluster.ReportPhase(ctx, types.PhaseDeleting)
+		if err := c.Driver.NewClient(); err != nil {
+			return err
+		}
+		if err := c.deleteCluster(f); err != nil {
+			return err
+		}
+		c.Logger.Infof("[%s] successfully excuted delete cluster logic\n", c.GetProviderName())
+	}
+	return nil
+}
+
+func (c *Cloud) RemoveK3sNode(node string, f bool) error {
+	n, ok := FindNode(*c.Status, node)
+	if !ok {
+		return fmt.Errorf("[%s] calling preflight error: node `%s` do not exist in cluster %s", c.GetProviderName(), node, c.Metadata.Name)
+	}
+
+	isConfirmed := true
+	if !f {
+		isConfirmed = utils.AskForConfirmation(fmt.Sprintf("[%s] are you sure to remove node %s from cluster %s", c.GetProviderName(), n.InstanceID, c.Metadata.Name))
+	}
+	if !isConfirmed {
+		return nil
+	}
+
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing remove node logic...\n", c.GetProviderName())
+	if err := c.Driver.NewClient(); err != nil {
+		return err
+	}
+
+	k := c.cluster()
+	k.Logger = c.Logger
+	if err := cluster.RemoveK3sNode(k, n); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] remove instance %s\n", c.GetProviderName(), n.InstanceID)
+	if err := c.Driver.RemoveInstances([]string{n.InstanceID}); err != nil {
+		return err
+	}
+
+	// sync master/worker count
+	*c.Status = RemoveNode(*c.Status, n.InstanceID)
+	c.Metadata.Master = strconv.Itoa(len(c.Status.MasterNodes))
+	c.Metadata.Worker = strconv.Itoa(len(c.Status.WorkerNodes))
+	k = c.cluster()
+	if err := cluster.SaveState(k); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", c.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(k, common.StatusRunning); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] successfully executed remove node logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) UpgradeK3sCluster(ctx context.Context, version string) (err error) {
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	k := c.cluster()
+	defer func() {
+		// the cluster is still running with origin version after rolled back.
+		k.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(k, common.StatusRunning)
+		// remove upgrade state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusUpgrade)))
+		logFile.Close()
+	}()
+
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing upgrade logic...\n", c.GetProviderName())
+
+	k.Status.Status = "upgrading"
+	err = cluster.SaveClusterState(k, common.StatusUpgrade)
+	if err != nil {
+		return err
+	}
+
+	k.Logger = c.Logger
+	if err = cluster.UpgradeK3sCluster(ctx, k, version); err != nil {
+		return err
+	}
+	c.Metadata.K3sVersion = version
+
+	c.Logger.Infof("[%s] successfully executed upgrade logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) SaveSnapshot(name string) (*types.Snapshot, error) {
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logFile.Close()
+	}()
+
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing save snapshot logic...\n", c.GetProviderName())
+
+	k := c.cluster()
+	k.Logger = c.Logger
+	snapshot, err := cluster.SaveSnapshot(k, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger.Infof("[%s] successfully executed save snapshot logic\n", c.GetProviderName())
+	return snapshot, nil
+}
+
+func (c *Cloud) ListSnapshots() ([]types.Snapshot, error) {
+	return cluster.ListSnapshots(&types.Cluster{Metadata: *c.Metadata})
+}
+
+func (c *Cloud) RestoreSnapshot(name string) (err error) {
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	k := c.cluster()
+	defer func() {
+		k.Status.Status = common.StatusRunning
+		cluster.SaveClusterState(k, common.StatusRunning)
+		// remove restore state file and save running state
+		os.Remove(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", c.Metadata.Name, common.StatusRestore)))
+		logFile.Close()
+	}()
+
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing restore snapshot logic...\n", c.GetProviderName())
+
+	k.Status.Status = "restoring"
+	err = cluster.SaveClusterState(k, common.StatusRestore)
+	if err != nil {
+		return err
+	}
+
+	k.Logger = c.Logger
+	if err = cluster.RestoreSnapshot(k, name); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] successfully executed restore snapshot logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) CheckK3sCluster(repair bool) ([]types.NodeCheck, error) {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	c.Logger.Infof("[%s] executing check logic...\n", c.GetProviderName())
+	if err := c.Driver.NewClient(); err != nil {
+		return nil, err
+	}
+
+	ssh := c.Driver.GetSSHConfig()
+	if _, err := c.syncClusterInstance(ssh); err != nil {
+		return nil, err
+	}
+
+	if repair {
+		ids := make([]string, 0)
+		c.Nodes.Range(func(key, value interface{}) bool {
+			if value.(types.Node).InstanceStatus != c.RunningStatus {
+				ids = append(ids, key.(string))
+			}
+			return true
+		})
+		if len(ids) > 0 {
+			c.Logger.Infof("[%s] starting stopped instances %s...\n", c.GetProviderName(), ids)
+			if err := c.Driver.StartInstances(ids); err != nil {
+				return nil, err
+			}
+			if err := c.getInstanceStatus(c.RunningStatus); err != nil {
+				return nil, err
+			}
+			c.syncNodeAddresses()
+		}
+	}
+
+	k := c.cluster()
+	k.Logger = c.Logger
+	reports, err := cluster.CheckK3sCluster(k)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if reports, err = cluster.RepairK3sCluster(k, reports); err != nil {
+			return nil, err
+		}
+		if err := cluster.SaveState(k); err != nil {
+			return nil, fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", c.GetProviderName(), err)
+		}
+	}
+
+	c.Logger.Infof("[%s] successfully executed check logic\n", c.GetProviderName())
+	return reports, nil
+}
+
+func (c *Cloud) PreflightK3sNodes(ssh *types.SSH) ([]types.NodePreflight, error) {
+	// instances are created when creating cluster or joining nodes, pre-flight checks are executed on them before installing k3s.
+	return nil, fmt.Errorf("[%s] pre-flight only is not supported, instances don't exist until created", c.GetProviderName())
+}
+
+func (c *Cloud) StartK3sCluster() error {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	c.Logger.Infof("[%s] executing start logic...\n", c.GetProviderName())
+	if err := c.Driver.NewClient(); err != nil {
+		return err
+	}
+
+	ssh := c.Driver.GetSSHConfig()
+	if _, err := c.syncClusterInstance(ssh); err != nil {
+		return err
+	}
+
+	// record ip addresses, which may be changed after instances started.
+	previous := make(map[string]string)
+	ids := make([]string, 0)
+	for _, nodes := range [][]types.Node{c.Status.MasterNodes, c.Status.WorkerNodes} {
+		for _, node := range nodes {
+			if len(node.PublicIPAddress) > 0 {
+				previous[node.InstanceID] = node.PublicIPAddress[0]
+			}
+			if node.InstanceStatus != c.RunningStatus {
+				ids = append(ids, node.InstanceID)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		c.Logger.Infof("[%s] starting instances %s...\n", c.GetProviderName(), ids)
+		if err := c.Driver.StartInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := c.getInstanceStatus(c.RunningStatus); err != nil {
+		return err
+	}
+	c.syncNodeAddresses()
+
+	k := c.cluster()
+	k.Logger = c.Logger
+	if err := cluster.RefreshNodeAddresses(k, previous); err != nil {
+		return err
+	}
+
+	k.Status.Status = common.StatusRunning
+	if err := cluster.SaveState(k); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", c.GetProviderName(), err)
+	}
+	if err := cluster.SaveClusterState(k, common.StatusRunning); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] successfully executed start logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) StopK3sCluster() error {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	c.Logger.Infof("[%s] executing stop logic...\n", c.GetProviderName())
+	if err := c.Driver.NewClient(); err != nil {
+		return err
+	}
+
+	if _, err := c.syncClusterInstance(c.Driver.GetSSHConfig()); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0)
+	c.Nodes.Range(func(key, value interface{}) bool {
+		if value.(types.Node).InstanceStatus == c.RunningStatus {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+	if len(ids) > 0 {
+		c.Logger.Infof("[%s] stopping instances %s...\n", c.GetProviderName(), ids)
+		if err := c.Driver.StopInstances(ids); err != nil {
+			return err
+		}
+	}
+	if err := c.getInstanceStatus(c.StoppedStatus); err != nil {
+		return err
+	}
+	c.syncNodeStatusWithInstance(nil)
+
+	k := c.cluster()
+	k.Status.Status = types.ClusterStatusStopped
+	if err := cluster.SaveState(k); err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", c.GetProviderName(), err)
+	}
+
+	c.Logger.Infof("[%s] successfully executed stop logic\n", c.GetProviderName())
+	return nil
+}
+
+func (c *Cloud) SSHK3sNode(ssh *types.SSH, ip string) error {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	c.Logger.Infof("[%s] executing ssh logic...\n", c.GetProviderName())
+	if err := c.Driver.NewClient(); err != nil {
+		return err
+	}
+	instances, err := c.syncClusterInstance(ssh)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]string, len(instances))
+	if ip == "" {
+		// generate node name
+		for _, i := range instances {
+			value, ok := c.Nodes.Load(i.ID)
+			if !ok {
+				continue
+			}
+			v := value.(types.Node)
+			if len(v.PublicIPAddress) == 0 || v.PublicIPAddress[0] == "" {
+				continue
+			}
+			instanceInfo := v.PublicIPAddress[0]
+			if v.Master {
+				instanceInfo = fmt.Sprintf("%s (master)", instanceInfo)
+			} else {
+				instanceInfo = fmt.Sprintf("%s (worker)", instanceInfo)
+			}
+			if i.Status != c.RunningStatus {
+				instanceInfo = fmt.Sprintf("%s - Unhealthy(instance is %s)", instanceInfo, i.Status)
+			}
+			ids[v.InstanceID] = instanceInfo
+		}
+	}
+
+	// sync master/worker count
+	c.Metadata.Master = strconv.Itoa(len(c.Status.MasterNodes))
+	c.Metadata.Worker = strconv.Itoa(len(c.Status.WorkerNodes))
+	k := c.cluster()
+	err = cluster.SaveState(k)
+
+	if err != nil {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: [%v]", c.GetProviderName(), err)
+	}
+	if ip == "" {
+		ip = strings.Split(utils.AskForSelectItem(fmt.Sprintf("[%s] choose ssh node to connect", c.GetProviderName()), ids), " (")[0]
+	}
+
+	if ip == "" {
+		return fmt.Errorf("[%s] choose incorrect ssh node", c.GetProviderName())
+	}
+
+	// ssh K3s node.
+	if err := cluster.SSHK3sNode(ip, k, ssh); err != nil {
+		return err
+	}
+
+	c.Logger.Infof("[%s] successfully executed ssh logic\n", c.GetProviderName())
+
+	return nil
+}
+
+func (c *Cloud) CommandNotSupport(commandName string) error {
+	return fmt.Errorf("[%s] dose not support command: [%s]", c.GetProviderName(), commandName)
+}
+
+func (c *Cloud) IsClusterExist() (bool, []string, error) {
+	ids := make([]string, 0)
+
+	instances, err := c.listInstances()
+	if err != nil {
+		return false, ids, err
+	}
+	for _, i := range instances {
+		ids = append(ids, i.ID)
+	}
+
+	return len(ids) > 0, ids, nil
+}
+
+func (c *Cloud) Rollback() error {
+	logFile, err := common.GetLogFile(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	c.Logger = common.NewLogger(common.Debug, logFile)
+	c.Logger.Infof("[%s] executing rollback logic...\n", c.GetProviderName())
+	ids := make([]string, 0)
+	c.Nodes.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		if v.RollBack {
+			ids = append(ids, key.(string))
+		}
+		return true
+	})
+
+	c.Logger.Debugf("[%s] instances %s will be rollback\n", c.GetProviderName(), ids)
+
+	if err := c.Driver.RemoveInstances(ids); err != nil {
+		return err
+	}
+	// resources created for cluster are useless if there's no instance left.
+	if exist, _, err := c.IsClusterExist(); err == nil && !exist {
+		if err := c.Driver.RemoveResources(); err != nil {
+			return err
+		}
+	}
+
+	c.Logger.Infof("[%s] successfully executed rollback logic\n", c.GetProviderName())
+
+	return logFile.Close()
+}
+
+func (c *Cloud) DescribeCluster(kubecfg string) *types.ClusterInfo {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	info := &types.ClusterInfo{
+		Name:     strings.Split(c.Metadata.Name, ".")[0],
+		Region:   c.Driver.GetRegion(),
+		Provider: c.GetProviderName(),
+	}
+	client, err := cluster.GetClusterConfig(c.Metadata.Name, kubecfg)
+	if err != nil {
+		c.Logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+		info.Status = types.ClusterStatusUnknown
+		info.Version = types.ClusterStatusUnknown
+		return info
+	}
+	info.Status = cluster.GetClusterStatus(client)
+
+	instances, err := c.listInstances()
+	if err != nil || len(instances) == 0 {
+		c.Logger.Errorf("[%s] failed to get instances for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+		info.Master = "0"
+		info.Worker = "0"
+		return info
+	}
+	instanceNodes := make([]types.ClusterNode, 0)
+	masterCount := 0
+	workerCount := 0
+	for _, i := range instances {
+		n := types.ClusterNode{
+			InstanceID:              i.ID,
+			InstanceStatus:          i.Status,
+			InternalIP:              []string{i.PrivateIP},
+			ExternalIP:              []string{i.PublicIP},
+			Status:                  types.ClusterStatusUnknown,
+			ContainerRuntimeVersion: types.ClusterStatusUnknown,
+			Version:                 types.ClusterStatusUnknown,
+		}
+		if i.Master {
+			masterCount++
+		} else {
+			workerCount++
+		}
+		instanceNodes = append(instanceNodes, n)
+	}
+
+	info.Master = strconv.Itoa(masterCount)
+	info.Worker = strconv.Itoa(workerCount)
+	info.Nodes = instanceNodes
+	if info.Status == types.ClusterStatusRunning {
+		info.Version = cluster.GetClusterVersion(client)
+		nodes, err := cluster.DescribeClusterNodes(client, instanceNodes)
+		if err != nil {
+			c.Logger.Errorf("[%s] failed to list nodes of cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+			return info
+		}
+		info.Nodes = nodes
+	} else {
+		info.Version = types.ClusterStatusUnknown
+	}
+	return info
+}
+
+func (c *Cloud) GetCluster(kubecfg string) *types.ClusterInfo {
+	c.Logger = common.NewLogger(common.Debug, nil)
+	info := &types.ClusterInfo{
+		Name:     c.Metadata.Name,
+		Region:   c.Driver.GetRegion(),
+		Provider: c.GetProviderName(),
+	}
+	client, err := cluster.GetClusterConfig(c.Metadata.Name, kubecfg)
+	if err != nil {
+		c.Logger.Errorf("[%s] failed to generate kube client for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+		info.Status = types.ClusterStatusUnknown
+		info.Version = types.ClusterStatusUnknown
+		return info
+	}
+	info.Status = cluster.GetClusterStatus(client)
+	if info.Status == types.ClusterStatusRunning {
+		info.Version = cluster.GetClusterVersion(client)
+	} else {
+		info.Version = types.ClusterStatusUnknown
+	}
+
+	instances, err := c.listInstances()
+	if err != nil || len(instances) == 0 {
+		c.Logger.Errorf("[%s] failed to get instances for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+		info.Master = "0"
+		info.Worker = "0"
+		return info
+	}
+	masterCount := 0
+	workerCount := 0
+	for _, i := range instances {
+		if i.Master {
+			masterCount++
+		} else {
+			workerCount++
+		}
+	}
+
+	info.Master = strconv.Itoa(masterCount)
+	info.Worker = strconv.Itoa(workerCount)
+
+	return info
+}
+
+func (c *Cloud) GetClusterConfig() (map[string]schemas.Field, error) {
+	config := c.Driver.GetSSHConfig()
+	sshConfig, err := utils.ConvertToFields(*config)
+	if err != nil {
+		return nil, err
+	}
+	metaConfig, err := utils.ConvertToFields(*c.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range sshConfig {
+		metaConfig[k] = v
+	}
+	return metaConfig, nil
+}
+
+func (c *Cloud) GetProviderOption() (map[string]schemas.Field, error) {
+	return utils.ConvertToFields(reflect.ValueOf(c.Options).Elem().Interface())
+}
+
+func (c *Cloud) SetConfig(config []byte) error {
+	k := types.Cluster{}
+	err := json.Unmarshal(config, &k)
+	if err != nil {
+		return err
+	}
+	sourceMeta := reflect.ValueOf(c.Metadata).Elem()
+	targetMeta := reflect.ValueOf(&k.Metadata).Elem()
+	utils.MergeConfig(sourceMeta, targetMeta)
+	sourceOption := reflect.ValueOf(c.Options).Elem()
+	b, err := json.Marshal(k.Options)
+	if err != nil {
+		return err
+	}
+	opt := reflect.New(sourceOption.Type())
+	err = json.Unmarshal(b, opt.Interface())
+	if err != nil {
+		return err
+	}
+	targetOption := opt.Elem()
+	utils.MergeConfig(sourceOption, targetOption)
+
+	return nil
+}
+
+func (c *Cloud) CreateCheck(ssh *types.SSH) error {
+	if err := cluster.CheckAirGap(c.Metadata.AirGapDir); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", c.GetProviderName(), err)
+	}
+	if _, err := FirewallPolicy(*c.Metadata, ""); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: %v", c.GetProviderName(), err)
+	}
+	masterNum, err := strconv.Atoi(c.Metadata.Master)
+	if masterNum < 1 || err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` number must >= 1",
+			c.GetProviderName())
+	}
+	if masterNum > 1 && !c.Metadata.Cluster && c.Metadata.DataStore == "" {
+		return fmt.Errorf("[%s] calling preflight error: need to set `--cluster` or `--datastore` when `--master` number > 1",
+			c.GetProviderName())
+	}
+	if _, err := strconv.Atoi(c.Metadata.Worker); err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			c.GetProviderName())
+	}
+	if strings.Contains(c.Metadata.MasterExtraArgs, "--datastore-endpoint") && c.Metadata.DataStore != "" {
+		return fmt.Errorf("[%s] calling preflight error: `--masterExtraArgs='--datastore-endpoint'` is duplicated with `--datastore`",
+			c.GetProviderName())
+	}
+	exist, _, err := c.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` is already exist",
+			c.GetProviderName(), c.Metadata.Name)
+	}
+	return nil
+}
+
+// JoinCheck validates the join flags, and removes nodes whose instances are removed from status.
+func (c *Cloud) JoinCheck() error {
+	if strings.Contains(c.Metadata.MasterExtraArgs, "--datastore-endpoint") && c.Metadata.DataStore != "" {
+		return fmt.Errorf("[%s] calling preflight error: `--masterExtraArgs='--datastore-endpoint'` is duplicated with `--datastore`",
+			c.GetProviderName())
+	}
+
+	masterNum, err := strconv.Atoi(c.Metadata.Master)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--master` must be number",
+			c.GetProviderName())
+	}
+	workerNum, err := strconv.Atoi(c.Metadata.Worker)
+	if err != nil {
+		return fmt.Errorf("[%s] calling preflight error: `--worker` must be number",
+			c.GetProviderName())
+	}
+	if masterNum < 1 && workerNum < 1 {
+		return fmt.Errorf("[%s] calling preflight error: `--master` or `--worker` number must >= 1", c.GetProviderName())
+	}
+
+	exist, ids, err := c.IsClusterExist()
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist",
+			c.GetProviderName(), c.Metadata.Name)
+	}
+
+	// remove nodes whose instances are removed from .state file.
+	for _, nodes := range [][]types.Node{c.Status.MasterNodes, c.Status.WorkerNodes} {
+		for _, n := range nodes {
+			if !containsString(ids, n.InstanceID) {
+				*c.Status = RemoveNode(*c.Status, n.InstanceID)
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateInstance runs the masters and workers to be added after checked by fn, and returns the cluster with them.
+func (c *Cloud) GenerateInstance(fn func() error, ssh *types.SSH) (*types.Cluster, error) {
+	if err := c.Driver.NewClient(); err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	masterNum, _ := strconv.Atoi(c.Metadata.Master)
+	workerNum, _ := strconv.Atoi(c.Metadata.Worker)
+
+	c.Logger.Infof("[%s] %d masters and %d workers will be added in region %s\n", c.GetProviderName(), masterNum, workerNum, c.Driver.GetRegion())
+
+	if err := c.Driver.PrepareResources(ssh); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < masterNum+workerNum; i++ {
+		instance, err := c.Driver.RunInstance(i < masterNum)
+		if err != nil {
+			return nil, err
+		}
+		c.Nodes.Store(instance.ID, types.Node{
+			Master:         instance.Master,
+			RollBack:       true,
+			InstanceID:     instance.ID,
+			InstanceStatus: instance.Status,
+		})
+	}
+
+	if err := c.getInstanceStatus(c.RunningStatus); err != nil {
+		return nil, err
+	}
+
+	if err := c.Driver.ConfigResources(); err != nil {
+		return nil, err
+	}
+
+	k, err := c.assembleInstanceStatus(ssh)
+	if err != nil {
+		return nil, err
+	}
+	if k.CloudControllerManager {
+		k.MasterExtraArgs += " --disable-cloud-controller --no-deploy servicelb"
+	}
+	return k, nil
+}
+
+func (c *Cloud) cluster() *types.Cluster {
+	return &types.Cluster{
+		Metadata: *c.Metadata,
+		Options:  reflect.ValueOf(c.Options).Elem().Interface(),
+		Status:   *c.Status,
+	}
+}
+
+func (c *Cloud) defaultSSH(ssh *types.SSH) {
+	config := c.Driver.GetSSHConfig()
+	if ssh.User == "" {
+		ssh.User = config.User
+	}
+	if ssh.Port == "" {
+		ssh.Port = config.Port
+	}
+}
+
+// getInstanceStatus waits for instances to be in aim status, running instances also need to have ip addresses assigned.
+func (c *Cloud) getInstanceStatus(aimStatus string) error {
+	ids := make([]string, 0)
+	c.Nodes.Range(func(key, value interface{}) bool {
+		ids = append(ids, key.(string))
+		return true
+	})
+
+	if len(ids) > 0 {
+		c.Logger.Debugf("[%s] waiting for the instances %s to be in `%s` status...\n", c.GetProviderName(), ids, aimStatus)
+		wait.ErrWaitTimeout = fmt.Errorf("[%s] calling getInstanceStatus error. instance: %s, message: not `%s` status",
+			c.GetProviderName(), ids, aimStatus)
+
+		if err := wait.ExponentialBackoff(common.Backoff, func() (bool, error) {
+			instances, err := c.Driver.ListInstances()
+			if err != nil {
+				return false, err
+			}
+			states := make(map[string]Instance, len(instances))
+			for _, i := range instances {
+				states[i.ID] = i
+			}
+
+			for _, id := range ids {
+				i, ok := states[id]
+				if !ok || i.Status != aimStatus {
+					return false, nil
+				}
+				if aimStatus == c.RunningStatus && (i.PublicIP == "" || i.PrivateIP == "") {
+					return false, nil
+				}
+				value, _ := c.Nodes.Load(id)
+				v := value.(types.Node)
+				v.InstanceStatus = aimStatus
+				if aimStatus == c.RunningStatus {
+					v.InternalIPAddress = []string{i.PrivateIP}
+					v.PublicIPAddress = []string{i.PublicIP}
+				}
+				c.Nodes.Store(id, v)
+			}
+			return true, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	c.Logger.Debugf("[%s] instances %s are in `%s` status\n", c.GetProviderName(), ids, aimStatus)
+
+	return nil
+}
+
+func (c *Cloud) assembleInstanceStatus(ssh *types.SSH) (*types.Cluster, error) {
+	instances, err := c.listInstances()
+	if err != nil || len(instances) == 0 {
+		return nil, fmt.Errorf("[%s] there's no instance for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+	}
+
+	for _, i := range instances {
+		if value, ok := c.Nodes.Load(i.ID); ok {
+			v := value.(types.Node)
+			// add only nodes that run the current command.
+			v.Current = true
+			v.SSH = *ssh
+			c.Nodes.Store(i.ID, v)
+			continue
+		}
+		c.Nodes.Store(i.ID, i.node())
+	}
+
+	c.syncNodeStatusWithInstance(ssh)
+
+	return c.cluster(), nil
+}
+
+func (c *Cloud) syncClusterInstance(ssh *types.SSH) ([]Instance, error) {
+	instances, err := c.listInstances()
+	if err != nil || len(instances) == 0 {
+		return nil, fmt.Errorf("[%s] there's no exist instance for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+	}
+
+	for _, i := range instances {
+		// sync all instances that belong to current clusters
+		n := i.node()
+		n.SSH = *ssh
+		c.Nodes.Store(n.InstanceID, n)
+	}
+
+	c.syncNodeStatusWithInstance(ssh)
+
+	return instances, nil
+}
+
+func (c *Cloud) syncNodeStatusWithInstance(ssh *types.SSH) {
+	c.Nodes.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := c.Status.WorkerNodes
+		if v.Master {
+			nodes = c.Status.MasterNodes
+		}
+		index, b := IsExistedNodes(nodes, v.InstanceID)
+		if !b {
+			nodes = append(nodes, v)
+		} else {
+			node := nodes[index]
+			if ssh != nil {
+				if node.SSH.User == "" || node.SSH.Port == "" || (node.SSH.Password == "" && node.SSH.SSHKeyPath == "") {
+					node.SSH = *ssh
+				}
+			}
+			node.InstanceStatus = v.InstanceStatus
+			nodes[index] = node
+		}
+		if v.Master {
+			c.Status.MasterNodes = nodes
+		} else {
+			c.Status.WorkerNodes = nodes
+		}
+		return true
+	})
+}
+
+func (c *Cloud) syncNodeAddresses() {
+	c.Nodes.Range(func(key, value interface{}) bool {
+		v := value.(types.Node)
+		nodes := c.Status.WorkerNodes
+		if v.Master {
+			nodes = c.Status.MasterNodes
+		}
+		if index, b := IsExistedNodes(nodes, v.InstanceID); b {
+			nodes[index].InternalIPAddress = v.InternalIPAddress
+			nodes[index].PublicIPAddress = v.PublicIPAddress
+		}
+		return true
+	})
+}
+
+func (c *Cloud) listInstances() ([]Instance, error) {
+	instances, err := c.Driver.ListInstances()
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to get instances for cluster %s: %v", c.GetProviderName(), c.Metadata.Name, err)
+	}
+	return instances, nil
+}
+
+func (c *Cloud) deleteCluster(f bool) error {
+	exist, ids, err := c.IsClusterExist()
+	if err != nil && !f {
+		return fmt.Errorf("[%s] calling deleteCluster error, msg: %v", c.GetProviderName(), err)
+	}
+	if !exist {
+		c.Logger.Errorf("[%s] cluster %s is not exist", c.GetProviderName(), c.Metadata.Name)
+		if !f {
+			return fmt.Errorf("[%s] calling preflight error: cluster name `%s` do not exist", c.GetProviderName(), c.Metadata.Name)
+		}
+		return nil
+	}
+
+	c.Logger.Infof("[%s] remove instances %v", c.GetProviderName(), ids)
+	if err := c.Driver.RemoveInstances(ids); err != nil {
+		return err
+	}
+	if err := c.Driver.RemoveResources(); err != nil && !f {
+		return err
+	}
+
+	err = cluster.OverwriteCfg(c.Metadata.Name)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .cfg file error, msg: %v", c.GetProviderName(), err)
+	}
+
+	err = cluster.DeleteState(c.Metadata.Name, c.Metadata.Provider)
+	if err != nil && !f {
+		return fmt.Errorf("[%s] synchronizing .state file error, msg: %v", c.GetProviderName(), err)
+	}
+
+	// remove default key-pair folder
+	err = os.RemoveAll(common.GetClusterPath(c.Metadata.Name, c.GetProviderName()))
+	if err != nil && !f {
+		return fmt.Errorf("[%s] remove cluster store folder (%s) error, msg: %v", c.GetProviderName(), common.GetClusterPath(c.Metadata.Name, c.GetProviderName()), err)
+	}
+
+	c.Logger.Infof("[%s] successfully deleted cluster %s\n", c.GetProviderName(), c.Metadata.Name)
+	return nil
+}
+
+func (i Instance) node() types.Node {
+	n := types.Node{
+		Master:         i.Master,
+		RollBack:       false,
+		InstanceID:     i.ID,
+		InstanceStatus: i.Status,
+	}
+	if i.PrivateIP != "" {
+		n.InternalIPAddress = []string{i.PrivateIP}
+	}
+	if i.PublicIP != "" {
+		n.PublicIPAddress = []string{i.PublicIP}
+	}
+	return n
+}
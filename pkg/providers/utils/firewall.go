@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/types"
+)
+
+const (
+	// FirewallRuleOwner is the mark in description of rules generated by autok3s, which is followed by the cluster name
+	// in rules of firewall policy, only rules of the cluster are revoked when reconciling.
+	FirewallRuleOwner = "generated by autok3s"
+	// AnyCIDR is the default admin cidr and the cluster cidr if the vpc/subnet cidr is unknown.
+	AnyCIDR = "0.0.0.0/0"
+)
+
+// FirewallRule is a provider-neutral ingress rule of security group, which is translated to rules of cloud providers.
+type FirewallRule struct {
+	Protocol    string
+	FromPort    int
+	ToPort      int
+	CIDR        string
+	Description string
+}
+
+// Port returns the port range of rule, e.g. 22 or 30000-32767.
+func (r FirewallRule) Port() string {
+	if r.FromPort == r.ToPort {
+		return strconv.Itoa(r.FromPort)
+	}
+	return fmt.Sprintf("%d-%d", r.FromPort, r.ToPort)
+}
+
+// OwnedBy returns whether the rule is created by autok3s for the cluster.
+// Rules of older autok3s are not marked with the cluster, which may be required by any cluster sharing the group.
+func (r FirewallRule) OwnedBy(cluster string) bool {
+	return strings.HasSuffix(r.Description, ownerMark(cluster))
+}
+
+func (r FirewallRule) key() string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(r.Protocol), r.Port())
+}
+
+// FirewallPolicy returns the ingress rules of security group for the cluster:
+// ssh, ui and kube api-server are allowed from admin cidrs, kube api-server, kubelet, vxlan and etcd are allowed from
+// the cluster cidr which is the vpc/subnet cidr of instances, the custom rules are appended.
+// The cluster ports are opened to any cidr if the cluster cidr is unknown, so errors of looking it up must not be ignored.
+// Descriptions of rules are marked with the name of cluster.
+func FirewallPolicy(m types.Metadata, clusterCIDR string) ([]FirewallRule, error) {
+	adminCIDRs, err := parseCIDRs(m.AdminCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	if len(adminCIDRs) == 0 {
+		adminCIDRs = []string{AnyCIDR}
+	}
+	if clusterCIDR == "" {
+		clusterCIDR = AnyCIDR
+	}
+
+	rules := make([]FirewallRule, 0)
+	add := func(protocol string, port int, description string, cidrs ...string) {
+		for _, cidr := range cidrs {
+			rules = append(rules, FirewallRule{
+				Protocol:    protocol,
+				FromPort:    port,
+				ToPort:      port,
+				CIDR:        cidr,
+				Description: "accept for " + description,
+			})
+		}
+	}
+	add("tcp", 22, "ssh", adminCIDRs...)
+	add("tcp", 6443, "kube api-server", appendCIDR(adminCIDRs, clusterCIDR)...)
+	add("tcp", 10250, "kubelet", clusterCIDR)
+	if m.Network == "" || m.Network == "vxlan" {
+		// udp 8472 for flannel vxlan
+		add("udp", 8472, "k3s vxlan", clusterCIDR)
+	}
+	if m.Cluster {
+		add("tcp", 2379, "etcd", clusterCIDR)
+		add("tcp", 2380, "etcd", clusterCIDR)
+	}
+	if m.UI {
+		add("tcp", 8999, "dashboard", adminCIDRs...)
+	}
+
+	custom, err := ParseFirewallRules(m.FirewallRules)
+	if err != nil {
+		return nil, err
+	}
+	rules = append(rules, custom...)
+	for i := range rules {
+		rules[i].Description += ownerMark(m.Name)
+	}
+	return rules, nil
+}
+
+// ParseFirewallRules parses custom rules in the format of <protocol>:<port>[-<port>]:<cidr> separated by comma,
+// e.g. tcp:30000-32767:0.0.0.0/0,udp:51820:10.0.0.0/8.
+func ParseFirewallRules(s string) ([]FirewallRule, error) {
+	rules := make([]FirewallRule, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid firewall rule %s, must be in the format of <protocol>:<port>[-<port>]:<cidr>", item)
+		}
+		protocol := strings.ToLower(parts[0])
+		if protocol != "tcp" && protocol != "udp" {
+			return nil, fmt.Errorf("invalid protocol of firewall rule %s, must be tcp or udp", item)
+		}
+		ports := strings.SplitN(parts[1], "-", 2)
+		from, err := parsePort(ports[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port of firewall rule %s: %v", item, err)
+		}
+		to := from
+		if len(ports) == 2 {
+			if to, err = parsePort(ports[1]); err != nil || to < from {
+				return nil, fmt.Errorf("invalid port range of firewall rule %s", item)
+			}
+		}
+		if _, _, err := net.ParseCIDR(parts[2]); err != nil {
+			return nil, fmt.Errorf("invalid cidr of firewall rule %s: %v", item, err)
+		}
+		rules = append(rules, FirewallRule{
+			Protocol:    protocol,
+			FromPort:    from,
+			ToPort:      to,
+			CIDR:        parts[2],
+			Description: "custom rule",
+		})
+	}
+	return rules, nil
+}
+
+// ReconcileFirewall compares the desired rules with the existing rules of security group,
+// returns the rules to authorize and the rules of cluster to revoke. Only rules owned by the cluster on the protocol and ports of
+// desired rules are revoked, so that rules added by users and owned by other clusters sharing the group are kept.
+func ReconcileFirewall(cluster string, desired, existing []FirewallRule) (authorize, revoke []FirewallRule) {
+	desiredCIDRs := map[string][]string{}
+	for _, r := range desired {
+		desiredCIDRs[r.key()] = append(desiredCIDRs[r.key()], r.CIDR)
+	}
+	existingCIDRs := map[string][]string{}
+	for _, r := range existing {
+		existingCIDRs[r.key()] = append(existingCIDRs[r.key()], r.CIDR)
+	}
+
+	for _, r := range desired {
+		if !containsString(existingCIDRs[r.key()], r.CIDR) {
+			authorize = append(authorize, r)
+			existingCIDRs[r.key()] = append(existingCIDRs[r.key()], r.CIDR)
+		}
+	}
+	for _, r := range existing {
+		if cidrs, ok := desiredCIDRs[r.key()]; ok && r.OwnedBy(cluster) && !containsString(cidrs, r.CIDR) {
+			revoke = append(revoke, r)
+		}
+	}
+	return authorize, revoke
+}
+
+// ParsePortRange parses the port range of cloud providers, e.g. 22, 22/22 and 30000-32767.
+func ParsePortRange(s string) (int, int, error) {
+	sep := "-"
+	if strings.Contains(s, "/") {
+		sep = "/"
+	}
+	ports := strings.SplitN(s, sep, 2)
+	from, err := strconv.Atoi(ports[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if len(ports) == 2 {
+		if to, err = strconv.Atoi(ports[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return from, to, nil
+}
+
+func ownerMark(cluster string) string {
+	return fmt.Sprintf("(%s for %s)", FirewallRuleOwner, cluster)
+}
+
+func parseCIDRs(s string) ([]string, error) {
+	cidrs := make([]string, 0)
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid admin cidr %s: %v", cidr, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d is out of range", port)
+	}
+	return port, nil
+}
+
+func appendCIDR(cidrs []string, cidr string) []string {
+	if containsString(cidrs, cidr) {
+		return cidrs
+	}
+	return append(append([]string{}, cidrs...), cidr)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -49,6 +49,33 @@ func IsExistedNodes(nodes []types.Node, instance string) (int, bool) {
 	return -1, false
 }
 
+func FindNode(status types.Status, node string) (types.Node, bool) {
+	nodes := append(append([]types.Node{}, status.MasterNodes...), status.WorkerNodes...)
+	for _, n := range nodes {
+		if n.InstanceID == node {
+			return n, true
+		}
+		for _, ip := range append(append([]string{}, n.PublicIPAddress...), n.InternalIPAddress...) {
+			if ip == node {
+				return n, true
+			}
+		}
+	}
+
+	return types.Node{}, false
+}
+
+func RemoveNode(status types.Status, instance string) types.Status {
+	if index, b := IsExistedNodes(status.MasterNodes, instance); b {
+		status.MasterNodes = append(status.MasterNodes[:index], status.MasterNodes[index+1:]...)
+	}
+	if index, b := IsExistedNodes(status.WorkerNodes, instance); b {
+		status.WorkerNodes = append(status.WorkerNodes[:index], status.WorkerNodes[index+1:]...)
+	}
+
+	return status
+}
+
 func CreateKeyPair(ssh *types.SSH, providerName, name, keypair string) ([]byte, error) {
 	var keyPath string
 	if ssh.SSHKeyPath == "" && keypair == "" {
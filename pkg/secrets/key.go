@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+)
+
+const (
+	// EnvMasterKey is the env var of master key, which takes precedence over the keyring and key file.
+	EnvMasterKey = "AUTOK3S_MASTER_KEY"
+
+	previousKeysSuffix = ".previous"
+	keyringService     = "autok3s"
+	keyringAccount     = "master-key"
+)
+
+// IsEnvKey returns whether the master key is from env var, which can't be saved by autok3s.
+func IsEnvKey() bool {
+	return os.Getenv(EnvMasterKey) != ""
+}
+
+func keySource() string {
+	switch {
+	case IsEnvKey():
+		return "env " + EnvMasterKey
+	case common.MasterKeyring:
+		return "keyring"
+	default:
+		return "file " + common.GetMasterKeyFile()
+	}
+}
+
+func cacheKey() string {
+	return fmt.Sprintf("%s:%t:%s", os.Getenv(EnvMasterKey), common.MasterKeyring, common.GetMasterKeyFile())
+}
+
+// readMasterKeys returns the current master key and the previous ones kept by rotation,
+// current key is empty if not exist.
+func readMasterKeys() (string, []string, error) {
+	var (
+		current, previous string
+		err               error
+	)
+	switch {
+	case common.MasterKeyring:
+		if current, err = keyringGet(keyringAccount); err != nil {
+			return "", nil, err
+		}
+		if previous, err = keyringGet(keyringAccount + previousKeysSuffix); err != nil {
+			return "", nil, err
+		}
+	default:
+		if current, err = readKeyFile(common.GetMasterKeyFile()); err != nil {
+			return "", nil, err
+		}
+		if previous, err = readKeyFile(common.GetMasterKeyFile() + previousKeysSuffix); err != nil {
+			return "", nil, err
+		}
+	}
+	// env key overrides the saved one, previous keys are still saved by autok3s.
+	if IsEnvKey() {
+		current = os.Getenv(EnvMasterKey)
+	}
+	return current, strings.Fields(previous), nil
+}
+
+func saveMasterKey(key string) error {
+	switch {
+	case IsEnvKey():
+		// env key can only be changed by user.
+		return nil
+	case common.MasterKeyring:
+		return keyringSet(keyringAccount, key)
+	default:
+		return writeKeyFile(common.GetMasterKeyFile(), key)
+	}
+}
+
+func savePreviousKeys(keys []string) error {
+	if common.MasterKeyring && !IsEnvKey() {
+		if len(keys) == 0 {
+			return keyringDelete(keyringAccount + previousKeysSuffix)
+		}
+		return keyringSet(keyringAccount+previousKeysSuffix, strings.Join(keys, " "))
+	}
+	name := common.GetMasterKeyFile() + previousKeysSuffix
+	if len(keys) == 0 {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("[secrets] failed to remove previous master keys %s: %v", name, err)
+		}
+		return nil
+	}
+	return writeKeyFile(name, strings.Join(keys, "\n"))
+}
+
+func readKeyFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("[secrets] failed to read master key file %s: %v", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func writeKeyFile(name, key string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return fmt.Errorf("[secrets] failed to create folder of master key file %s: %v", name, err)
+	}
+	if err := ioutil.WriteFile(name, []byte(key+"\n"), 0600); err != nil {
+		return fmt.Errorf("[secrets] failed to write master key file %s: %v", name, err)
+	}
+	return nil
+}
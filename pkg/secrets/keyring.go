@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// the OS keyring is accessed through its command line tool, which is security on macOS
+// and secret-tool of libsecret on Linux.
+
+const (
+	// exit status of security when the item is not found, which is errSecItemNotFound.
+	securityNotFoundStatus = 44
+	// secret-tool exits with 1 and prints nothing when the item is not found.
+	secretToolNotFoundStatus = 1
+)
+
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	default:
+		return "", fmt.Errorf("[secrets] keyring is not supported on %s", runtime.GOOS)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if isKeyringNotFound(err, stderr) {
+			return "", nil
+		}
+		return "", fmt.Errorf("[secrets] failed to read master key from keyring: %v, %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keyringSet(account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// the secret is passed through the interactive mode of security, so it never shows up in process arguments.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			keyringService, account, quoteSecurityArg(secret)))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", keyringService+" "+account, "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return fmt.Errorf("[secrets] keyring is not supported on %s", runtime.GOOS)
+	}
+	return runKeyring(cmd, "save")
+}
+
+func keyringDelete(account string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account)
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", account)
+	default:
+		return fmt.Errorf("[secrets] keyring is not supported on %s", runtime.GOOS)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if isKeyringNotFound(err, stderr) {
+			return nil
+		}
+		return fmt.Errorf("[secrets] failed to delete master key from keyring: %v, %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// isKeyringNotFound returns whether the keyring command failed because the item is not found,
+// other failures, e.g. the keyring is locked or not available, must not be treated as a missing key.
+func isKeyringNotFound(err error, stderr *bytes.Buffer) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return exitErr.ExitCode() == securityNotFoundStatus
+	case "linux":
+		return exitErr.ExitCode() == secretToolNotFoundStatus && strings.TrimSpace(stderr.String()) == ""
+	}
+	return false
+}
+
+// quoteSecurityArg quotes the argument for the interactive mode of security.
+func quoteSecurityArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func runKeyring(cmd *exec.Cmd, op string) error {
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("[secrets] failed to %s master key in keyring: %v, %s", op, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
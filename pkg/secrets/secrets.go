@@ -0,0 +1,180 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks the encrypted values, values without it are treated as plaintext for compatibility.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	// keys[0] encrypts secrets, all of them are tried to decrypt secrets.
+	keys    [][]byte
+	keysKey string
+	keysMu  sync.Mutex
+)
+
+// IsEncrypted returns whether s is encrypted by Encrypt.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encryptedPrefix)
+}
+
+// Encrypt encrypts s with the master key, empty and encrypted values are returned as is.
+func Encrypt(s string) (string, error) {
+	if s == "" || IsEncrypted(s) {
+		return s, nil
+	}
+	ks, err := loadKeys()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(ks[0])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("[secrets] failed to generate nonce: %v", err)
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(s), nil)), nil
+}
+
+// Decrypt decrypts s encrypted by Encrypt, plaintext values are returned as is.
+func Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("[secrets] invalid encrypted value: %v", err)
+	}
+	ks, err := loadKeys()
+	if err != nil {
+		return "", err
+	}
+	for _, k := range ks {
+		gcm, err := newGCM(k)
+		if err != nil {
+			return "", err
+		}
+		if len(data) < gcm.NonceSize() {
+			return "", errors.New("[secrets] invalid encrypted value: too short")
+		}
+		plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", fmt.Errorf("[secrets] failed to decrypt secret with master key from %s, the key may be changed", keySource())
+}
+
+// Rotate makes secrets encrypted with newKey from now on, and persists it to the key source.
+// the current key is kept as a previous key to decrypt secrets which are not re-encrypted yet,
+// call CompleteRotation after all secrets are re-encrypted.
+func Rotate(newKey string) error {
+	if newKey == "" {
+		return errors.New("[secrets] new master key can not be empty")
+	}
+	if strings.ContainsAny(newKey, " \t\r\n") {
+		return errors.New("[secrets] new master key can not contain whitespaces")
+	}
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	current, previous, err := readMasterKeys()
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		// previous key is persisted before the new one, so that it will not be lost if rotation is interrupted,
+		// except the env key which is kept by user and should never be written to disk.
+		if !IsEnvKey() {
+			if err := savePreviousKeys(append([]string{current}, previous...)); err != nil {
+				return err
+			}
+		}
+		previous = append([]string{current}, previous...)
+	}
+	if err := saveMasterKey(newKey); err != nil {
+		return err
+	}
+
+	keys = deriveKeys(newKey, previous)
+	keysKey = cacheKey()
+	return nil
+}
+
+// CompleteRotation removes the previous keys kept by Rotate.
+func CompleteRotation() error {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if err := savePreviousKeys(nil); err != nil {
+		return err
+	}
+	if len(keys) > 1 {
+		keys = keys[:1]
+	}
+	return nil
+}
+
+// GenerateKey returns a random master key.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("[secrets] failed to generate master key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// loadKeys returns keys of current key source, the master key is generated if not exist.
+func loadKeys() ([][]byte, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	key := cacheKey()
+	if keys != nil && keysKey == key {
+		return keys, nil
+	}
+
+	current, previous, err := readMasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	if current == "" {
+		if current, err = GenerateKey(); err != nil {
+			return nil, err
+		}
+		if err := saveMasterKey(current); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, keysKey = deriveKeys(current, previous), key
+	return keys, nil
+}
+
+func deriveKeys(current string, previous []string) [][]byte {
+	result := make([][]byte, 0, len(previous)+1)
+	for _, k := range append([]string{current}, previous...) {
+		sum := sha256.Sum256([]byte(k))
+		result = append(result, sum[:])
+	}
+	return result
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("[secrets] invalid master key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
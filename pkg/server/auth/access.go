@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+// permission is the lowest roles required to access the schema.
+type permission struct {
+	// read is required by get, list and watch.
+	read Role
+	// write is required by create, update, delete and actions not specified in actions.
+	write   Role
+	actions map[string]Role
+}
+
+var (
+	defaultPermission = permission{read: RoleViewer, write: RoleAdmin}
+
+	permissions = map[string]permission{
+		"provider": {read: RoleViewer, write: RoleAdmin},
+		"cluster": {read: RoleViewer, write: RoleOperator, actions: map[string]Role{
+			"snapshotList": RoleViewer,
+		}},
+		// credentials contain secrets of cloud providers.
+		"credential": {read: RoleAdmin, write: RoleAdmin},
+		// kubeconfig and kubectl shell of clusters.
+		"config": {read: RoleOperator, write: RoleAdmin},
+		// ssh shell of nodes.
+		"mutual": {read: RoleAdmin, write: RoleAdmin},
+		"logs":   {read: RoleViewer, write: RoleAdmin},
+		// operation history contains actors and parameters of operations, operators can cancel running operations.
+		"operation": {read: RoleOperator, write: RoleOperator},
+	}
+)
+
+// AccessControl enforces role of the authenticated user on schemas and actions,
+// on top of the methods allowed by schemas.
+type AccessControl struct {
+	server.SchemaBasedAccess
+}
+
+func (a *AccessControl) CanAction(apiOp *types.APIRequest, schema *types.APISchema, name string) error {
+	if err := a.SchemaBasedAccess.CanAction(apiOp, schema, name); err != nil {
+		return err
+	}
+	p := getPermission(schema.ID)
+	required, ok := p.actions[name]
+	if !ok {
+		required = p.write
+	}
+	return authorize(apiOp, schema, required, "perform action "+name+" on")
+}
+
+func (a *AccessControl) CanCreate(apiOp *types.APIRequest, schema *types.APISchema) error {
+	if err := a.SchemaBasedAccess.CanCreate(apiOp, schema); err != nil {
+		return err
+	}
+	return authorize(apiOp, schema, getPermission(schema.ID).write, "create")
+}
+
+func (a *AccessControl) CanList(apiOp *types.APIRequest, schema *types.APISchema) error {
+	if err := a.SchemaBasedAccess.CanList(apiOp, schema); err != nil {
+		return err
+	}
+	return authorize(apiOp, schema, getPermission(schema.ID).read, "list")
+}
+
+func (a *AccessControl) CanGet(apiOp *types.APIRequest, schema *types.APISchema) error {
+	if err := a.SchemaBasedAccess.CanGet(apiOp, schema); err != nil {
+		return err
+	}
+	return authorize(apiOp, schema, getPermission(schema.ID).read, "get")
+}
+
+func (a *AccessControl) CanUpdate(apiOp *types.APIRequest, obj types.APIObject, schema *types.APISchema) error {
+	if err := a.SchemaBasedAccess.CanUpdate(apiOp, obj, schema); err != nil {
+		return err
+	}
+	return authorize(apiOp, schema, getPermission(schema.ID).write, "update")
+}
+
+func (a *AccessControl) CanDelete(apiOp *types.APIRequest, obj types.APIObject, schema *types.APISchema) error {
+	if err := a.SchemaBasedAccess.CanDelete(apiOp, obj, schema); err != nil {
+		return err
+	}
+	return authorize(apiOp, schema, getPermission(schema.ID).write, "delete")
+}
+
+func (a *AccessControl) CanWatch(apiOp *types.APIRequest, schema *types.APISchema) error {
+	return a.CanList(apiOp, schema)
+}
+
+func getPermission(schemaID string) permission {
+	if p, ok := permissions[schemaID]; ok {
+		return p
+	}
+	return defaultPermission
+}
+
+func authorize(apiOp *types.APIRequest, schema *types.APISchema, required Role, verb string) error {
+	user, ok := UserFrom(apiOp.Request.Context())
+	if !ok {
+		return apierror.NewAPIError(validation.Unauthorized, "request is not authenticated")
+	}
+	if !user.Role.Includes(required) {
+		return apierror.NewAPIError(validation.PermissionDenied,
+			fmt.Sprintf("user %s with role %s can not %s %s", user.Name, user.Role, verb, schema.ID))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas"
+)
+
+func newSchema(id string, actions ...string) *types.APISchema {
+	schema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                id,
+			CollectionMethods: []string{http.MethodGet, http.MethodPost},
+			ResourceMethods:   []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		},
+		ActionHandlers: map[string]http.Handler{},
+	}
+	for _, action := range actions {
+		schema.ActionHandlers[action] = http.NotFoundHandler()
+	}
+	return schema
+}
+
+func newRequest(user *User) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodGet, "/v1", nil)
+	if user != nil {
+		req = req.WithContext(WithUser(req.Context(), user))
+	}
+	return &types.APIRequest{Request: req}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		role, other Role
+		want        bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleOperator, true},
+		{RoleAdmin, RoleViewer, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleOperator, RoleOperator, true},
+		{RoleOperator, RoleViewer, true},
+		{RoleViewer, RoleOperator, false},
+		{RoleViewer, RoleViewer, true},
+		{Role("root"), RoleViewer, false},
+		{RoleAdmin, Role(""), false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Includes(tt.other); got != tt.want {
+			t.Errorf("Role(%q).Includes(%q) = %t, want %t", tt.role, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	a := &AccessControl{}
+	verbs := map[string]func(apiOp *types.APIRequest, schema *types.APISchema) error{
+		"get":    a.CanGet,
+		"list":   a.CanList,
+		"watch":  a.CanWatch,
+		"create": a.CanCreate,
+		"update": func(apiOp *types.APIRequest, schema *types.APISchema) error {
+			return a.CanUpdate(apiOp, types.APIObject{}, schema)
+		},
+		"delete": func(apiOp *types.APIRequest, schema *types.APISchema) error {
+			return a.CanDelete(apiOp, types.APIObject{}, schema)
+		},
+		"action upgrade": func(apiOp *types.APIRequest, schema *types.APISchema) error {
+			return a.CanAction(apiOp, schema, "upgrade")
+		},
+		"action snapshotList": func(apiOp *types.APIRequest, schema *types.APISchema) error {
+			return a.CanAction(apiOp, schema, "snapshotList")
+		},
+	}
+
+	// lowest role allowed for each verb, empty means no role is allowed.
+	tests := []struct {
+		schema string
+		verb   string
+		lowest Role
+	}{
+		{"cluster", "get", RoleViewer},
+		{"cluster", "list", RoleViewer},
+		{"cluster", "watch", RoleViewer},
+		{"cluster", "create", RoleOperator},
+		{"cluster", "delete", RoleOperator},
+		{"cluster", "action upgrade", RoleOperator},
+		{"cluster", "action snapshotList", RoleViewer},
+		{"provider", "get", RoleViewer},
+		{"provider", "update", RoleAdmin},
+		{"credential", "get", RoleAdmin},
+		{"credential", "list", RoleAdmin},
+		{"credential", "create", RoleAdmin},
+		{"config", "get", RoleOperator},
+		{"config", "update", RoleAdmin},
+		{"mutual", "get", RoleAdmin},
+		{"logs", "list", RoleViewer},
+		{"operation", "list", RoleOperator},
+		{"operation", "delete", RoleOperator},
+		{"unknown", "get", RoleViewer},
+		{"unknown", "create", RoleAdmin},
+	}
+	for _, tt := range tests {
+		schema := newSchema(tt.schema, "upgrade", "snapshotList")
+		for _, role := range []Role{RoleViewer, RoleOperator, RoleAdmin} {
+			err := verbs[tt.verb](newRequest(&User{Name: "test", Role: role}), schema)
+			if allowed := role.Includes(tt.lowest); allowed != (err == nil) {
+				t.Errorf("%s %s by %s: allowed = %t, got error %v", tt.verb, tt.schema, role, allowed, err)
+			}
+		}
+		if err := verbs[tt.verb](newRequest(nil), schema); err == nil {
+			t.Errorf("%s %s without user should be denied", tt.verb, tt.schema)
+		}
+	}
+}
+
+func TestAccessControlUnknownAction(t *testing.T) {
+	a := &AccessControl{}
+	if err := a.CanAction(newRequest(&User{Name: "admin", Role: RoleAdmin}), newSchema("cluster"), "upgrade"); err == nil {
+		t.Errorf("action which is not defined by schema should be denied")
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+
+	// Anonymous is the actor of requests when authentication is not enabled.
+	Anonymous = "anonymous"
+)
+
+var (
+	// ErrInvalidCredentials is returned when credentials of request are not matched.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	roleLevels = map[Role]int{
+		RoleViewer:   1,
+		RoleOperator: 2,
+		RoleAdmin:    3,
+	}
+)
+
+type userKey struct{}
+
+// Role is the role of user, higher role has all permissions of the lower ones: viewer < operator < admin.
+type Role string
+
+// Valid returns whether role is one of viewer, operator and admin.
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Includes returns whether role r has all permissions of role o.
+func (r Role) Includes(o Role) bool {
+	return r.Valid() && o.Valid() && roleLevels[r] >= roleLevels[o]
+}
+
+// User is the authenticated user of request.
+type User struct {
+	Name string
+	Role Role
+}
+
+// Authenticator authenticates user of request.
+type Authenticator interface {
+	// Authenticate returns nil user and nil error if request doesn't carry credentials for the authenticator.
+	Authenticate(req *http.Request) (*User, error)
+}
+
+type unionAuthenticator []Authenticator
+
+// Union returns an authenticator which tries authenticators in order until the user is authenticated.
+func Union(authenticators ...Authenticator) Authenticator {
+	return unionAuthenticator(authenticators)
+}
+
+func (u unionAuthenticator) Authenticate(req *http.Request) (*User, error) {
+	var errs []string
+	for _, a := range u {
+		user, err := a.Authenticate(req)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, ", "))
+	}
+	return nil, nil
+}
+
+// WithUser returns a copy of ctx with the authenticated user.
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the authenticated user stored in ctx.
+func UserFrom(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey{}).(*User)
+	return user, ok && user != nil
+}
+
+// ActorFrom returns the name of authenticated user of request, or anonymous if authentication is not enabled.
+func ActorFrom(req *http.Request) string {
+	if user, ok := UserFrom(req.Context()); ok {
+		return user.Name
+	}
+	return Anonymous
+}
+
+// Middleware rejects requests which are not authenticated by the authenticator,
+// the authenticated user is stored in context of request.
+func Middleware(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, err := a.Authenticate(req)
+			if err != nil {
+				logrus.Debugf("failed to authenticate request %s %s from %s: %v", req.Method, req.URL.Path, req.RemoteAddr, err)
+			}
+			if user == nil {
+				// browsers prompt for basic credentials, which are sent for websocket requests as well.
+				rw.Header().Set("WWW-Authenticate", `Basic realm="autok3s"`)
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(rw, req.WithContext(WithUser(req.Context(), user)))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type certAuthenticator struct {
+	defaultRole Role
+}
+
+// NewCertAuthenticator returns an authenticator which authenticates requests with verified client certificates,
+// name of user is the common name, role of user is the first organization which is a valid role,
+// defaultRole is used if there isn't any.
+func NewCertAuthenticator(defaultRole Role) (Authenticator, error) {
+	if !defaultRole.Valid() {
+		return nil, fmt.Errorf("invalid default role %q of client certificate", defaultRole)
+	}
+	return &certAuthenticator{defaultRole: defaultRole}, nil
+}
+
+func (c *certAuthenticator) Authenticate(req *http.Request) (*User, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	subject := req.TLS.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil, fmt.Errorf("common name of client certificate is empty")
+	}
+	role := c.defaultRole
+	for _, o := range subject.Organization {
+		if Role(o).Valid() {
+			role = Role(o)
+			break
+		}
+	}
+	return &User{Name: subject.CommonName, Role: role}, nil
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// File is the authentication file which contains users and bearer tokens, e.g.
+//
+//	users:
+//	- name: admin
+//	  password: $2y$10$... # bcrypt hash, can be generated by `htpasswd -nbBC 10 "" <password>`
+//	  role: admin
+//	tokens:
+//	- name: ci
+//	  token: <random string>
+//	  role: operator
+type File struct {
+	Users  []FileUser  `json:"users,omitempty"`
+	Tokens []FileToken `json:"tokens,omitempty"`
+}
+
+type FileUser struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+type FileToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+type fileAuthenticator struct {
+	users  map[string]FileUser
+	tokens []FileToken
+}
+
+// NewFileAuthenticator returns an authenticator which authenticates requests with basic credentials
+// and bearer tokens defined in the authentication file.
+func NewFileAuthenticator(path string) (Authenticator, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authentication file %s: %v", path, err)
+	}
+	f := &File{}
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("failed to parse authentication file %s: %v", path, err)
+	}
+
+	a := &fileAuthenticator{users: make(map[string]FileUser, len(f.Users))}
+	for _, u := range f.Users {
+		if u.Name == "" || u.Password == "" {
+			return nil, fmt.Errorf("name and password of user must be specified in authentication file %s", path)
+		}
+		if !u.Role.Valid() {
+			return nil, fmt.Errorf("invalid role %q of user %s in authentication file %s", u.Role, u.Name, path)
+		}
+		if _, err := bcrypt.Cost([]byte(u.Password)); err != nil {
+			return nil, fmt.Errorf("password of user %s in authentication file %s is not a bcrypt hash: %v", u.Name, path, err)
+		}
+		if _, ok := a.users[u.Name]; ok {
+			return nil, fmt.Errorf("duplicate user %s in authentication file %s", u.Name, path)
+		}
+		a.users[u.Name] = u
+	}
+	for _, t := range f.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return nil, fmt.Errorf("name and token must be specified in authentication file %s", path)
+		}
+		if !t.Role.Valid() {
+			return nil, fmt.Errorf("invalid role %q of token %s in authentication file %s", t.Role, t.Name, path)
+		}
+		a.tokens = append(a.tokens, t)
+	}
+	if len(a.users) == 0 && len(a.tokens) == 0 {
+		return nil, fmt.Errorf("no user or token is defined in authentication file %s", path)
+	}
+
+	return a, nil
+}
+
+func (a *fileAuthenticator) Authenticate(req *http.Request) (*User, error) {
+	if name, password, ok := req.BasicAuth(); ok {
+		u, ok := a.users[name]
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+			return nil, ErrInvalidCredentials
+		}
+		return &User{Name: u.Name, Role: u.Role}, nil
+	}
+
+	token := bearerToken(req)
+	if token == "" {
+		return nil, nil
+	}
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &User{Name: t.Name, Role: t.Role}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func bearerToken(req *http.Request) string {
+	parts := strings.SplitN(strings.TrimSpace(req.Header.Get("Authorization")), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeAuthFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "autok3s-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "auth.yaml")
+	if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestFileAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, cleanup := writeAuthFile(t, `users:
+- name: admin
+  password: `+string(hash)+`
+  role: admin
+tokens:
+- name: ci
+  token: ci-token
+  role: operator
+`)
+	defer cleanup()
+	a, err := NewFileAuthenticator(name)
+	if err != nil {
+		t.Fatalf("NewFileAuthenticator() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(req *http.Request)
+		want    *User
+		wantErr bool
+	}{
+		{
+			name:  "basic auth",
+			setup: func(req *http.Request) { req.SetBasicAuth("admin", "secret") },
+			want:  &User{Name: "admin", Role: RoleAdmin},
+		},
+		{
+			name:    "wrong password",
+			setup:   func(req *http.Request) { req.SetBasicAuth("admin", "wrong") },
+			wantErr: true,
+		},
+		{
+			name:    "unknown user",
+			setup:   func(req *http.Request) { req.SetBasicAuth("nobody", "secret") },
+			wantErr: true,
+		},
+		{
+			name:  "bearer token",
+			setup: func(req *http.Request) { req.Header.Set("Authorization", "Bearer ci-token") },
+			want:  &User{Name: "ci", Role: RoleOperator},
+		},
+		{
+			name:    "wrong token",
+			setup:   func(req *http.Request) { req.Header.Set("Authorization", "Bearer wrong") },
+			wantErr: true,
+		},
+		{
+			name:  "no credentials",
+			setup: func(req *http.Request) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1", nil)
+			tt.setup(req)
+			user, err := a.Authenticate(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if (user == nil) != (tt.want == nil) || (user != nil && *user != *tt.want) {
+				t.Errorf("Authenticate() = %+v, want %+v", user, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFileAuthenticatorInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "empty", content: ""},
+		{name: "plaintext password", content: "users:\n- name: admin\n  password: secret\n  role: admin\n"},
+		{name: "invalid role", content: "tokens:\n- name: ci\n  token: ci-token\n  role: root\n"},
+		{name: "missing token", content: "tokens:\n- name: ci\n  role: viewer\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, cleanup := writeAuthFile(t, tt.content)
+			defer cleanup()
+			if _, err := NewFileAuthenticator(name); err == nil {
+				t.Errorf("NewFileAuthenticator() should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AllowedOrigins are the origins allowed to upgrade websocket besides the same origin, `*` allows all origins.
+var AllowedOrigins []string
+
+// CheckOrigin returns whether the websocket upgrade request is from the same origin or one of the allowed origins.
+// requests without Origin header are not from browsers and always allowed.
+func CheckOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	for _, allowed := range AllowedOrigins {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) || strings.EqualFold(allowed, u.Host) {
+			return true
+		}
+	}
+	return false
+}
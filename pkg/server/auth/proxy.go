@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type proxyAuthenticator struct {
+	userHeader  string
+	roleHeader  string
+	defaultRole Role
+}
+
+// NewProxyAuthenticator returns an authenticator which trusts the user set in header by the authenticating proxy,
+// role of user is read from roleHeader, defaultRole is used if it's not set.
+// it must only be used when autok3s can't be reached without going through the proxy.
+func NewProxyAuthenticator(userHeader, roleHeader string, defaultRole Role) (Authenticator, error) {
+	if userHeader == "" {
+		return nil, fmt.Errorf("user header of auth proxy can not be empty")
+	}
+	if !defaultRole.Valid() {
+		return nil, fmt.Errorf("invalid default role %q of auth proxy", defaultRole)
+	}
+	return &proxyAuthenticator{
+		userHeader:  userHeader,
+		roleHeader:  roleHeader,
+		defaultRole: defaultRole,
+	}, nil
+}
+
+func (p *proxyAuthenticator) Authenticate(req *http.Request) (*User, error) {
+	name := req.Header.Get(p.userHeader)
+	if name == "" {
+		return nil, nil
+	}
+	role := p.defaultRole
+	if p.roleHeader != "" {
+		if r := req.Header.Get(p.roleHeader); r != "" {
+			role = Role(r)
+		}
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role %q of user %s from auth proxy", role, name)
+	}
+	return &User{Name: name, Role: role}, nil
+}
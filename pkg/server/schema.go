@@ -6,6 +6,7 @@ import (
 	"github.com/cnrancher/autok3s/pkg/server/store/cluster"
 	"github.com/cnrancher/autok3s/pkg/server/store/credential"
 	"github.com/cnrancher/autok3s/pkg/server/store/kubectl"
+	"github.com/cnrancher/autok3s/pkg/server/store/operation"
 	"github.com/cnrancher/autok3s/pkg/server/store/provider"
 	"github.com/cnrancher/autok3s/pkg/server/store/websocket"
 	autok3stypes "github.com/cnrancher/autok3s/pkg/types/apis"
@@ -23,6 +24,14 @@ func initProvider(s *types.APISchemas) {
 }
 
 func initCluster(s *types.APISchemas) {
+	s.MustImportAndCustomize(autok3stypes.UpgradeInput{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{}
+		schema.ResourceMethods = []string{}
+	})
+	s.MustImportAndCustomize(autok3stypes.SnapshotInput{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{}
+		schema.ResourceMethods = []string{}
+	})
 	s.MustImportAndCustomize(autok3stypes.Cluster{}, func(schema *types.APISchema) {
 		schema.Store = &cluster.Store{}
 		schema.CollectionMethods = []string{http.MethodGet, http.MethodPost}
@@ -30,6 +39,18 @@ func initCluster(s *types.APISchemas) {
 		schema.ResourceActions["join"] = wranglertypes.Action{
 			Input: "cluster",
 		}
+		schema.ResourceActions["upgrade"] = wranglertypes.Action{
+			Input: "upgradeInput",
+		}
+		schema.ResourceActions["snapshotSave"] = wranglertypes.Action{
+			Input: "snapshotInput",
+		}
+		schema.ResourceActions["snapshotList"] = wranglertypes.Action{}
+		schema.ResourceActions["snapshotRestore"] = wranglertypes.Action{
+			Input: "snapshotInput",
+		}
+		schema.ResourceActions["start"] = wranglertypes.Action{}
+		schema.ResourceActions["stop"] = wranglertypes.Action{}
 		schema.Formatter = cluster.Formatter
 		schema.ActionHandlers = cluster.HandleCluster()
 		schema.ByIDHandler = cluster.LinkCluster
@@ -68,3 +89,19 @@ func initLogs(s *types.APISchemas) {
 		schema.ListHandler = websocket.LogHandler
 	})
 }
+
+func initEvents(s *types.APISchemas) {
+	s.MustImportAndCustomize(autok3stypes.Events{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{}
+		schema.ListHandler = websocket.EventHandler
+	})
+}
+
+func initOperation(s *types.APISchemas) {
+	s.MustImportAndCustomize(autok3stypes.Operation{}, func(schema *types.APISchema) {
+		schema.Store = &operation.Store{}
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet, http.MethodDelete}
+	})
+}
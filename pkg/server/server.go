@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/cnrancher/autok3s/pkg/server/auth"
 	"github.com/cnrancher/autok3s/pkg/server/ui"
 
 	"github.com/gorilla/mux"
@@ -11,20 +12,54 @@ import (
 	"github.com/rancher/apiserver/pkg/server"
 	"github.com/rancher/apiserver/pkg/store/apiroot"
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/sirupsen/logrus"
 
 	// pprof
 	"net/http/pprof"
 )
 
-func Start() http.Handler {
+// Options are the options of serving autok3s API and UI.
+type Options struct {
+	// AuthFile is the file of users and bearer tokens, see auth.File.
+	AuthFile string
+	// ProxyUserHeader is the header of user set by the authenticating proxy.
+	ProxyUserHeader string
+	// ProxyRoleHeader is the header of role set by the authenticating proxy.
+	ProxyRoleHeader string
+	// ProxyDefaultRole is the role of user from the authenticating proxy if role header is not set.
+	ProxyDefaultRole string
+	// AllowedOrigins are the origins allowed to upgrade websocket besides the same origin.
+	AllowedOrigins []string
+	// EnablePprof enables profiling handlers under /debug/pprof.
+	EnablePprof bool
+	// TLSCert and TLSKey are the serving certificate and key, a self-signed certificate is used if not specified.
+	TLSCert string
+	TLSKey  string
+	// TLSClientCA is the CA bundle to verify client certificates, which are used to authenticate requests.
+	TLSClientCA string
+}
+
+func Start(opts Options) (http.Handler, error) {
+	authenticator, err := newAuthenticator(opts)
+	if err != nil {
+		return nil, err
+	}
+	auth.AllowedOrigins = opts.AllowedOrigins
+
 	s := server.DefaultAPIServer()
+	if authenticator != nil {
+		s.AccessControl = &auth.AccessControl{}
+	}
 	initMutual(s.Schemas)
 	initProvider(s.Schemas)
 	initCluster(s.Schemas)
 	initCredential(s.Schemas)
 	initKubeconfig(s.Schemas)
 	initLogs(s.Schemas)
+	initEvents(s.Schemas)
+	initOperation(s.Schemas)
 	apiroot.Register(s.Schemas, []string{"v1"})
+	guardCustomHandlers(s.Schemas)
 	router := mux.NewRouter()
 	router.UseEncodedPath()
 	router.StrictSlash(true)
@@ -42,17 +77,9 @@ func Start() http.Handler {
 		http.Redirect(rw, req, "/ui/", http.StatusFound)
 	})
 
-	// profiling handlers for pprof under /debug/pprof
-	router.HandleFunc("/debug/pprof", pprof.Index)
-	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
-	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-
-	// Manually add support for paths linked to by index page at /debug/pprof/
-	router.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
-	router.Handle("/debug/pprof/heap", pprof.Handler("heap"))
-	router.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
-	router.Handle("/debug/pprof/block", pprof.Handler("block"))
+	if opts.EnablePprof {
+		handlePprof(router)
+	}
 
 	router.Path("/{prefix}/{type}").Handler(s)
 	router.Path("/{prefix}/{type}/{name}").Queries("link", "{link}").Handler(s)
@@ -68,7 +95,84 @@ func Start() http.Handler {
 		})
 	})
 
-	return router
+	if authenticator == nil {
+		logrus.Warn("no authentication is configured, anyone who can reach the server has full access to it")
+		return router, nil
+	}
+	return auth.Middleware(authenticator)(router), nil
+}
+
+func newAuthenticator(opts Options) (auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+	if opts.TLSClientCA != "" {
+		a, err := auth.NewCertAuthenticator(auth.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if opts.AuthFile != "" {
+		a, err := auth.NewFileAuthenticator(opts.AuthFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if opts.ProxyUserHeader != "" {
+		a, err := auth.NewProxyAuthenticator(opts.ProxyUserHeader, opts.ProxyRoleHeader, auth.Role(opts.ProxyDefaultRole))
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+	return auth.Union(authenticators...), nil
+}
+
+// guardCustomHandlers checks access of requests handled by custom list and byID handlers,
+// which are not checked by apiserver.
+func guardCustomHandlers(s *types.APISchemas) {
+	for _, schema := range s.Schemas {
+		if listHandler := schema.ListHandler; listHandler != nil {
+			schema.ListHandler = func(apiOp *types.APIRequest) (types.APIObjectList, error) {
+				if err := canGetOrList(apiOp); err != nil {
+					return types.APIObjectList{}, err
+				}
+				return listHandler(apiOp)
+			}
+		}
+		if byIDHandler := schema.ByIDHandler; byIDHandler != nil {
+			schema.ByIDHandler = func(apiOp *types.APIRequest) (types.APIObject, error) {
+				if err := canGetOrList(apiOp); err != nil {
+					return types.APIObject{}, err
+				}
+				return byIDHandler(apiOp)
+			}
+		}
+	}
+}
+
+func canGetOrList(apiOp *types.APIRequest) error {
+	if apiOp.Name == "" {
+		return apiOp.AccessControl.CanList(apiOp, apiOp.Schema)
+	}
+	return apiOp.AccessControl.CanGet(apiOp, apiOp.Schema)
+}
+
+// handlePprof registers profiling handlers for pprof under /debug/pprof.
+func handlePprof(router *mux.Router) {
+	router.HandleFunc("/debug/pprof", pprof.Index)
+	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+
+	// Manually add support for paths linked to by index page at /debug/pprof/
+	router.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
+	router.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+	router.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
+	router.Handle("/debug/pprof/block", pprof.Handler("block"))
 }
 
 func serve(next http.Handler) http.Handler {
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +10,9 @@ import (
 	com "github.com/cnrancher/autok3s/cmd/common"
 	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/providers"
+	storeutils "github.com/cnrancher/autok3s/pkg/server/store/utils"
+	autok3stypes "github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/types/apis"
 
 	"github.com/gorilla/mux"
@@ -19,18 +23,36 @@ import (
 )
 
 const (
-	actionJoin = "join"
-	linkNodes  = "nodes"
+	actionJoin            = "join"
+	actionUpgrade         = "upgrade"
+	actionSnapshotSave    = "snapshotSave"
+	actionSnapshotList    = "snapshotList"
+	actionSnapshotRestore = "snapshotRestore"
+	actionStart           = "start"
+	actionStop            = "stop"
+	linkNodes             = "nodes"
 )
 
 func Formatter(request *types.APIRequest, resource *types.RawResource) {
 	resource.Links[linkNodes] = request.URLBuilder.Link(resource.Schema, resource.ID, linkNodes)
 	resource.AddAction(request, actionJoin)
+	resource.AddAction(request, actionUpgrade)
+	resource.AddAction(request, actionSnapshotSave)
+	resource.AddAction(request, actionSnapshotList)
+	resource.AddAction(request, actionSnapshotRestore)
+	resource.AddAction(request, actionStart)
+	resource.AddAction(request, actionStop)
 }
 
 func HandleCluster() map[string]http.Handler {
 	return map[string]http.Handler{
-		actionJoin: joinHandler(),
+		actionJoin:            joinHandler(),
+		actionUpgrade:         upgradeHandler(),
+		actionSnapshotSave:    snapshotSaveHandler(),
+		actionSnapshotList:    snapshotListHandler(),
+		actionSnapshotRestore: snapshotRestoreHandler(),
+		actionStart:           startHandler(),
+		actionStop:            stopHandler(),
 	}
 }
 
@@ -90,8 +112,12 @@ func joinHandler() http.Handler {
 			return
 		}
 
+		op := storeutils.BeginOperation(req, autok3stypes.OperationJoin, clusterID, c.Provider,
+			json.RawMessage(body), storeutils.CredentialFlagNames(provider)...)
+		ctx := cluster.WithOperation(context.Background(), op)
 		go func() {
-			err := provider.JoinK3sNode(&apiCluster.SSH)
+			err := provider.JoinK3sNode(ctx, &apiCluster.SSH)
+			cluster.EndOperation(op, err)
 			if err != nil {
 				logrus.Errorf("join cluster error: %v", err)
 				err = provider.Rollback()
@@ -99,10 +125,230 @@ func joinHandler() http.Handler {
 			}
 		}()
 
+		writeOperation(rw, op)
+	})
+}
+
+func upgradeHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		clusterID := vars["name"]
+		if clusterID == "" {
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			rw.Write([]byte("clusterID cannot be empty"))
+			return
+		}
+
+		c, err := cluster.GetClusterByID(clusterID)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(fmt.Sprintf("cluster %s is not found", clusterID)))
+			return
+		}
+		provider, err := com.GetProviderByState(*c)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(fmt.Sprintf("provider %s is not found", c.Provider)))
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		input := &apis.UpgradeInput{}
+		err = json.Unmarshal(body, input)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		if input.K3sVersion == "" {
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			rw.Write([]byte("k3s-version cannot be empty"))
+			return
+		}
+
+		op := storeutils.BeginOperation(req, autok3stypes.OperationUpgrade, clusterID, c.Provider, input)
+		ctx := cluster.WithOperation(context.Background(), op)
+		go func() {
+			err := provider.UpgradeK3sCluster(ctx, input.K3sVersion)
+			cluster.EndOperation(op, err)
+			if err != nil {
+				logrus.Errorf("upgrade cluster error: %v", err)
+			}
+		}()
+
+		writeOperation(rw, op)
+	})
+}
+
+func snapshotSaveHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider, ok := getActionProvider(rw, req)
+		if !ok {
+			return
+		}
+		input := &apis.SnapshotInput{}
+		if !readActionInput(rw, req, input) {
+			return
+		}
+
+		go func() {
+			if _, err := provider.SaveSnapshot(input.Name); err != nil {
+				logrus.Errorf("save snapshot error: %v", err)
+			}
+		}()
+
 		rw.WriteHeader(http.StatusOK)
 	})
 }
 
+func snapshotListHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider, ok := getActionProvider(rw, req)
+		if !ok {
+			return
+		}
+		snapshots, err := provider.ListSnapshots()
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		b, err := json.Marshal(snapshots)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(b)
+	})
+}
+
+func snapshotRestoreHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider, ok := getActionProvider(rw, req)
+		if !ok {
+			return
+		}
+		input := &apis.SnapshotInput{}
+		if !readActionInput(rw, req, input) {
+			return
+		}
+		if input.Name == "" {
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			rw.Write([]byte("name cannot be empty"))
+			return
+		}
+
+		go func() {
+			if err := provider.RestoreSnapshot(input.Name); err != nil {
+				logrus.Errorf("restore snapshot error: %v", err)
+			}
+		}()
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func startHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider, ok := getActionProvider(rw, req)
+		if !ok {
+			return
+		}
+
+		go func() {
+			if err := provider.StartK3sCluster(); err != nil {
+				logrus.Errorf("start cluster error: %v", err)
+			}
+		}()
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func stopHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider, ok := getActionProvider(rw, req)
+		if !ok {
+			return
+		}
+
+		go func() {
+			if err := provider.StopK3sCluster(); err != nil {
+				logrus.Errorf("stop cluster error: %v", err)
+			}
+		}()
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+// getActionProvider returns the provider of the cluster in request path, error is written to response if failed.
+func getActionProvider(rw http.ResponseWriter, req *http.Request) (providers.Provider, bool) {
+	vars := mux.Vars(req)
+	clusterID := vars["name"]
+	if clusterID == "" {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		rw.Write([]byte("clusterID cannot be empty"))
+		return nil, false
+	}
+
+	c, err := cluster.GetClusterByID(clusterID)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(fmt.Sprintf("cluster %s is not found", clusterID)))
+		return nil, false
+	}
+	provider, err := com.GetProviderByState(*c)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(fmt.Sprintf("provider %s is not found", c.Provider)))
+		return nil, false
+	}
+	return provider, true
+}
+
+// writeOperation writes the operation started by the action to response, so that it can be tracked and canceled
+// through the operation resource.
+func writeOperation(rw http.ResponseWriter, op *autok3stypes.Operation) {
+	b, err := json.Marshal(apis.Operation{Operation: cluster.SnapshotOperation(op)})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(b)
+}
+
+// readActionInput unmarshals request body to input, error is written to response if failed.
+func readActionInput(rw http.ResponseWriter, req *http.Request, input interface{}) bool {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(body, input); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return false
+	}
+	return true
+}
+
 func nodesHandler(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
 	clusterInfo, err := cluster.GetClusterByID(id)
 	if err != nil {
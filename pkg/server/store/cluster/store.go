@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -12,12 +13,12 @@ import (
 	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/common"
 	"github.com/cnrancher/autok3s/pkg/providers"
+	storeutils "github.com/cnrancher/autok3s/pkg/server/store/utils"
 	autok3stypes "github.com/cnrancher/autok3s/pkg/types"
 	"github.com/cnrancher/autok3s/pkg/types/apis"
 	"github.com/cnrancher/autok3s/pkg/utils"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
 
-	"github.com/fsnotify/fsnotify"
-	"github.com/ghodss/yaml"
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/store/empty"
 	"github.com/rancher/apiserver/pkg/types"
@@ -41,7 +42,9 @@ func (c *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 	if err != nil {
 		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, err.Error())
 	}
-	err = p.SetConfig(b)
+	if err := p.SetConfig(b); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidFormat, err.Error())
+	}
 	p.GenerateClusterName()
 
 	config := apis.Cluster{}
@@ -51,6 +54,9 @@ func (c *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 	}
 	// save credential config
 	if providerName != "native" {
+		if err := autok3sviper.ValidateProfileName(p.GetCredentialProfile()); err != nil {
+			return types.APIObject{}, apierror.NewAPIError(validation.InvalidFormat, err.Error())
+		}
 		if err := viper.ReadInConfig(); err != nil {
 			return types.APIObject{}, err
 		}
@@ -58,10 +64,10 @@ func (c *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 		options := data.Data().Map("options")
 		for _, credential := range credFlags {
 			if v, ok := options[credential.Name]; ok {
-				viper.Set(fmt.Sprintf(common.BindPrefix, providerName, credential.Name), v)
+				viper.Set(autok3sviper.CredentialKey(providerName, p.GetCredentialProfile(), credential.Name), v)
 			}
 		}
-		if err := viper.WriteConfig(); err != nil {
+		if err := autok3sviper.WriteConfig(); err != nil {
 			return types.APIObject{}, err
 		}
 	}
@@ -69,11 +75,16 @@ func (c *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 	sshConfig := p.GetSSHConfig()
 	utils.MergeConfig(reflect.ValueOf(sshConfig).Elem(), reflect.ValueOf(&config.SSH).Elem())
 
+	op := storeutils.BeginOperation(apiOp.Request, autok3stypes.OperationCreate, p.GetClusterName(), providerName,
+		data.Data(), storeutils.CredentialFlagNames(p)...)
 	if err := p.CreateCheck(sshConfig); err != nil {
+		cluster.EndOperation(op, err)
 		return types.APIObject{}, apierror.NewAPIError(validation.InvalidOption, err.Error())
 	}
+	ctx := cluster.WithOperation(context.Background(), op)
 	go func() {
-		err = p.CreateK3sCluster(sshConfig)
+		err := p.CreateK3sCluster(ctx, sshConfig)
+		cluster.EndOperation(op, err)
 		if err != nil {
 			logrus.Errorf("create cluster error: %v", err)
 			err = p.Rollback()
@@ -81,7 +92,7 @@ func (c *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 		}
 	}()
 
-	return types.APIObject{}, err
+	return toOperationObject(op), nil
 }
 
 func (c *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
@@ -118,26 +129,36 @@ func (c *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string
 	return types.APIObject{
 		Type:   schema.ID,
 		ID:     id,
-		Object: obj,
+		Object: redactCluster(obj, clusterInfo.Provider),
 	}, nil
 }
 
+// redactCluster returns the cluster with secrets redacted, e.g. token, ssh secrets and credentials of provider,
+// which are only used by autok3s itself and should never leave the store.
+func redactCluster(obj interface{}, providerName string) map[string]interface{} {
+	var sensitive []string
+	if p, err := providers.GetProvider(providerName); err == nil {
+		sensitive = storeutils.CredentialFlagNames(p)
+	}
+	return cluster.RedactSecrets(obj, sensitive...)
+}
+
 func (c *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
-	context := strings.Split(id, ".")
-	providerName := context[len(context)-1]
+	parts := strings.Split(id, ".")
+	providerName := parts[len(parts)-1]
 	provider, err := providers.GetProvider(providerName)
 	if err != nil {
 		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, err.Error())
 	}
 	config := autok3stypes.Cluster{
 		Metadata: autok3stypes.Metadata{
-			Name:     context[0],
+			Name:     parts[0],
 			Provider: providerName,
 		},
 	}
-	if len(context) == 3 {
+	if len(parts) == 3 {
 		config.Options = map[string]interface{}{
-			"region": context[1],
+			"region": parts[1],
 		}
 	}
 	b, err := json.Marshal(config)
@@ -153,69 +174,44 @@ func (c *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id stri
 		return types.APIObject{}, err
 	}
 	provider.GenerateClusterName()
-	err = provider.DeleteK3sCluster(true)
-	return types.APIObject{}, err
+	op := storeutils.BeginOperation(apiOp.Request, autok3stypes.OperationDelete, provider.GetClusterName(), providerName, nil)
+	ctx := cluster.WithOperation(context.Background(), op)
+	go func() {
+		err := provider.DeleteK3sCluster(ctx, true)
+		cluster.EndOperation(op, err)
+		if err != nil {
+			logrus.Errorf("delete cluster error: %v", err)
+		}
+	}()
+
+	return toOperationObject(op), nil
 }
 
-func (c *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
-	var (
-		result = make(chan types.APIEvent)
-	)
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return result, err
-	}
-	err = watcher.Add(common.GetClusterStatePath())
-	if err != nil {
-		return result, err
+// toOperationObject returns the operation started by the request, which can be tracked and canceled
+// through the operation resource.
+func toOperationObject(op *autok3stypes.Operation) types.APIObject {
+	return types.APIObject{
+		Type:   "operation",
+		ID:     op.ID,
+		Object: apis.Operation{Operation: cluster.SnapshotOperation(op)},
 	}
+}
 
-	go func() {
-		<-apiOp.Context().Done()
-		watcher.Close()
-		close(result)
-	}()
+func (c *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	result := make(chan types.APIEvent)
+	events := cluster.Subscribe(apiOp.Context())
 
 	go func() {
-		for {
+		defer close(result)
+		for e := range events {
+			event, ok := toClusterEvent(e, schema.ID)
+			if !ok {
+				continue
+			}
 			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				switch event.Op {
-				case fsnotify.Create:
-					result <- toClusterEvent(event.Op, event.Name, schema.ID)
-				case fsnotify.Remove:
-					if strings.HasSuffix(event.Name, fmt.Sprintf("_%s", common.StatusRunning)) ||
-						strings.HasSuffix(event.Name, fmt.Sprintf("_%s", common.StatusFailed)) {
-						context := strings.Split(event.Name, "_")
-						arrayInfo := strings.Split(context[0], ".")
-						result <- types.APIEvent{
-							Name:         "resource.remove",
-							ResourceType: schema.ID,
-							Object: types.APIObject{
-								ID:   context[0],
-								Type: schema.ID,
-								Object: autok3stypes.Cluster{
-									Metadata: autok3stypes.Metadata{
-										Name:     arrayInfo[0],
-										Provider: arrayInfo[len(arrayInfo)-1],
-									},
-								},
-							},
-						}
-					} else if strings.HasSuffix(event.Name, fmt.Sprintf("_%s", common.StatusJoin)) {
-						result <- toClusterEvent(event.Op, event.Name, schema.ID)
-					}
-				}
-			case e, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				result <- types.APIEvent{
-					Error: e,
-				}
+			case result <- event:
+			case <-apiOp.Context().Done():
+				return
 			}
 		}
 	}()
@@ -223,63 +219,70 @@ func (c *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 	return result, nil
 }
 
-func toClusterEvent(op fsnotify.Op, name, id string) types.APIEvent {
-	if strings.HasSuffix(name, fmt.Sprintf("_%s", common.StatusCreating)) ||
-		strings.HasSuffix(name, fmt.Sprintf("_%s", common.StatusFailed)) {
-		r, e := ioutil.ReadFile(name)
-		if e != nil {
-			return types.APIEvent{
-				Error: e,
-			}
-		}
-		processCluster := &autok3stypes.Cluster{}
-		e = yaml.Unmarshal(r, processCluster)
-		if e != nil {
-			return types.APIEvent{
-				Error: e,
-			}
-		}
-		clusterID := processCluster.Name
-		processCluster.Name = strings.Split(processCluster.Name, ".")[0]
-		event := types.APIEvent{
+// toClusterEvent converts the cluster event to api event, false is returned if the event should be ignored,
+// e.g. progress of deleting cluster after it's removed.
+func toClusterEvent(e autok3stypes.ClusterEvent, id string) (types.APIEvent, bool) {
+	shortName := strings.Split(e.Cluster, ".")[0]
+	if e.Type == autok3stypes.ClusterEventRemove {
+		return types.APIEvent{
+			Name:         "resource.remove",
 			ResourceType: id,
 			Object: types.APIObject{
-				ID:     clusterID,
-				Type:   id,
-				Object: processCluster,
+				ID:   e.Cluster,
+				Type: id,
+				Object: autok3stypes.Cluster{
+					Metadata: autok3stypes.Metadata{
+						Name:     shortName,
+						Provider: e.Provider,
+					},
+				},
 			},
-		}
-		if strings.HasSuffix(name, fmt.Sprintf("_%s", common.StatusCreating)) {
-			event.Name = "resource.create"
-			return event
-		}
-		event.Name = "resource.change"
-		return event
+		}, true
 	}
-	// event name is formed by "cfg-path/clusters/cluster-id_event",
-	//e.g. .autok3s/clusters/myk3s.region.provider_Join
-	context := strings.Split(name, "_")
-	contextArray := strings.Split(context[0], "/")
-	clusterInfo, err := cluster.GetClusterByID(contextArray[len(contextArray)-1])
+
+	clusterInfo, err := getClusterState(e)
 	if err != nil {
+		if e.Type == autok3stypes.ClusterEventProgress {
+			return types.APIEvent{}, false
+		}
 		return types.APIEvent{
 			Error: err,
-		}
+		}, true
 	}
-	clusterID := clusterInfo.Name
-	clusterInfo.Name = strings.Split(clusterInfo.Name, ".")[0]
-	if op != fsnotify.Remove && strings.HasSuffix(name, fmt.Sprintf("_%s", common.StatusJoin)) {
+	clusterInfo.Name = shortName
+	switch e.Status {
+	case common.StatusJoin, common.StatusUpgrade:
 		clusterInfo.Status.Status = "upgrading"
+	case common.StatusRestore:
+		clusterInfo.Status.Status = "restoring"
+	}
+
+	name := "resource.change"
+	if e.Type == autok3stypes.ClusterEventCreate {
+		name = "resource.create"
 	}
 	return types.APIEvent{
-		Name:         "resource.change",
+		Name:         name,
 		ResourceType: id,
 		Object: types.APIObject{
-			ID:     clusterID,
+			ID:     e.Cluster,
 			Type:   id,
-			Object: clusterInfo,
+			Object: redactCluster(clusterInfo, clusterInfo.Provider),
 		},
+	}, true
+}
+
+// getClusterState returns the cluster of event from its status file,
+// or from state if the event is not about status, e.g. progress of operations.
+func getClusterState(e autok3stypes.ClusterEvent) (*autok3stypes.Cluster, error) {
+	if e.Status != "" {
+		return cluster.ReadClusterState(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", e.Cluster, e.Status)))
+	}
+	if c, err := cluster.GetClusterByID(e.Cluster); err == nil {
+		return c, nil
 	}
+	// clusters being created are not saved in state yet.
+	return cluster.ReadClusterState(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", e.Cluster, common.StatusCreating)))
 }
 
 func ListCluster() ([]*autok3stypes.ClusterInfo, error) {
@@ -296,7 +299,7 @@ func ListCluster() ([]*autok3stypes.ClusterInfo, error) {
 	for _, fileInfo := range fileList {
 		if strings.HasSuffix(fileInfo.Name(), fmt.Sprintf("_%s", common.StatusCreating)) ||
 			strings.HasSuffix(fileInfo.Name(), fmt.Sprintf("_%s", common.StatusFailed)) {
-			state, err := readClusterState(filepath.Join(common.GetClusterStatePath(), fileInfo.Name()))
+			state, err := cluster.ReadClusterState(filepath.Join(common.GetClusterStatePath(), fileInfo.Name()))
 			if err != nil {
 				logrus.Errorf("failed to read state of cluster %s: %v", strings.Split(fileInfo.Name(), "_")[0], err)
 				continue
@@ -318,14 +321,13 @@ func ListCluster() ([]*autok3stypes.ClusterInfo, error) {
 	}
 
 	// get all clusters from state
-	clusters, err := utils.ReadYaml(v, common.StateFile)
+	store, err := cluster.GetStateStore()
 	if err != nil {
-		return nil, fmt.Errorf("read state file error, msg: %v", err)
+		return nil, err
 	}
-
-	result, err := cluster.ConvertToClusters(clusters)
+	result, err := store.List()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state file, msg: %v", err)
+		return nil, fmt.Errorf("read cluster state error, msg: %v", err)
 	}
 	var p providers.Provider
 	kubeCfg := fmt.Sprintf("%s/%s", common.CfgPath, common.KubeCfgFile)
@@ -356,14 +358,3 @@ func ListCluster() ([]*autok3stypes.ClusterInfo, error) {
 	}
 	return list, nil
 }
-
-func readClusterState(statePath string) (*autok3stypes.Cluster, error) {
-	b, err := ioutil.ReadFile(statePath)
-	if err != nil {
-		return nil, err
-	}
-
-	state := &autok3stypes.Cluster{}
-	err = yaml.Unmarshal(b, state)
-	return state, err
-}
@@ -1,15 +1,15 @@
 package credential
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
 	"github.com/cnrancher/autok3s/pkg/server/store/utils"
 	"github.com/cnrancher/autok3s/pkg/types/apis"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
 
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/store/empty"
@@ -24,15 +24,19 @@ type Store struct {
 }
 
 func (cred *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
-	provider, err := providers.GetProvider(id)
+	providerName, profile := parseID(id)
+	provider, err := providers.GetProvider(providerName)
 	if err != nil {
-		logrus.Errorf("get provider %s error: %v", id, err)
+		logrus.Errorf("get provider %s error: %v", providerName, err)
 		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, err.Error())
 	}
-	fields, err := utils.GetCredentialByProvider(provider)
+	fields, err := utils.GetCredentialByProvider(provider, profile)
 	if err != nil {
 		return types.APIObject{}, err
 	}
+	if !autok3sviper.IsDefaultProfile(profile) && !autok3sviper.ProfileExists(providerName, profile, utils.CredentialFlagNames(provider)) {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("credential profile %s of provider %s is not exist", profile, providerName))
+	}
 	secrets := make(map[string]string, 0)
 	for k, field := range fields {
 		value, ok := field.Default.(string)
@@ -41,7 +45,8 @@ func (cred *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id str
 		}
 	}
 	credential := apis.Credential{
-		Provider:     id,
+		Provider:     providerName,
+		Profile:      profile,
 		SecretFields: fields,
 		Secrets:      secrets,
 	}
@@ -61,7 +66,7 @@ func (cred *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types
 			logrus.Errorf("get provider %s error: %v", p.Name, err)
 			continue
 		}
-		fields, err := utils.GetCredentialByProvider(provider)
+		fields, err := utils.GetCredentialByProvider(provider, "")
 		if err != nil {
 			return result, err
 		}
@@ -74,6 +79,17 @@ func (cred *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types
 			ID:     p.Name,
 			Object: credential,
 		})
+		for _, profile := range autok3sviper.ListProfiles(p.Name) {
+			result.Objects = append(result.Objects, types.APIObject{
+				Type: schema.ID,
+				ID:   toID(p.Name, profile),
+				Object: apis.Credential{
+					Provider:     p.Name,
+					Profile:      profile,
+					SecretFields: fields,
+				},
+			})
+		}
 	}
 	return result, nil
 }
@@ -84,74 +100,98 @@ func (cred *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data
 		return types.APIObject{}, err
 	}
 	id := data.Data().String("provider")
+	profile := data.Data().String("profile")
+	if err := autok3sviper.ValidateProfileName(profile); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidFormat, err.Error())
+	}
 	for key, secret := range secrets {
-		if viper.IsSet(fmt.Sprintf(common.BindPrefix, id, key)) {
-			return types.APIObject{}, apierror.NewAPIError(validation.Conflict, fmt.Sprintf("you have already set credential settings for provider %s", id))
+		if viper.IsSet(autok3sviper.CredentialKey(id, profile, key)) {
+			if autok3sviper.IsDefaultProfile(profile) {
+				return types.APIObject{}, apierror.NewAPIError(validation.Conflict, fmt.Sprintf("you have already set credential settings for provider %s", id))
+			}
+			return types.APIObject{}, apierror.NewAPIError(validation.Conflict, fmt.Sprintf("credential profile %s of provider %s is already exist", profile, id))
 		}
-		viper.Set(fmt.Sprintf(common.BindPrefix, id, key), secret)
+		viper.Set(autok3sviper.CredentialKey(id, profile, key), secret)
 	}
-	if err := viper.WriteConfig(); err != nil {
+	if err := autok3sviper.WriteConfig(); err != nil {
 		return types.APIObject{}, err
 	}
 	if err := viper.MergeInConfig(); err != nil {
 		return types.APIObject{}, err
 	}
-	return cred.ByID(apiOp, schema, id)
+	return cred.ByID(apiOp, schema, toID(id, profile))
 }
 
 func (cred *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
 	if err := viper.ReadInConfig(); err != nil {
 		return types.APIObject{}, err
 	}
+	providerName, profile := parseID(id)
 	secrets := data.Data().Map("secrets")
 	for key, secret := range secrets {
-		if !viper.IsSet(fmt.Sprintf(common.BindPrefix, id, key)) {
-			return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("please set credential settings for provider %s before update", id))
+		if !viper.IsSet(autok3sviper.CredentialKey(providerName, profile, key)) {
+			if autok3sviper.IsDefaultProfile(profile) {
+				return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("please set credential settings for provider %s before update", providerName))
+			}
+			return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("please set %s of credential profile %s for provider %s before update", key, profile, providerName))
 		}
-		viper.Set(fmt.Sprintf(common.BindPrefix, id, key), secret)
+		viper.Set(autok3sviper.CredentialKey(providerName, profile, key), secret)
 	}
-	if err := viper.WriteConfig(); err != nil {
+	if err := autok3sviper.WriteConfig(); err != nil {
 		return types.APIObject{}, err
 	}
 	return cred.ByID(apiOp, schema, id)
 }
 
 func (cred *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
-	provider, err := providers.GetProvider(id)
+	providerName, profile := parseID(id)
+	provider, err := providers.GetProvider(providerName)
 	if err != nil {
-		logrus.Errorf("get provider %s error: %v", id, err)
+		logrus.Errorf("get provider %s error: %v", providerName, err)
 		return types.APIObject{}, err
 	}
 	flags := provider.GetCredentialFlags()
 	if err := viper.ReadInConfig(); err != nil {
 		return types.APIObject{}, err
 	}
-	// remove env vars and viper config for credential
-	for _, flag := range flags {
-		if flag.EnvVar != "" && os.Getenv(flag.EnvVar) != "" {
-			os.Setenv(flag.EnvVar, "")
+
+	if autok3sviper.IsDefaultProfile(profile) {
+		// remove env vars and viper config for credential
+		for _, flag := range flags {
+			if flag.EnvVar != "" && os.Getenv(flag.EnvVar) != "" {
+				os.Setenv(flag.EnvVar, "")
+			}
+		}
+	} else {
+		clusters, err := cluster.ListClustersByCredential(providerName, profile)
+		if err != nil {
+			return types.APIObject{}, err
+		}
+		if len(clusters) > 0 {
+			return types.APIObject{}, apierror.NewAPIError(validation.Conflict,
+				fmt.Sprintf("credential profile %s of provider %s is used by clusters %s", profile, providerName, strings.Join(clusters, ", ")))
 		}
 	}
 
-	settings := viper.AllSettings()
-	providerConfigs, ok := settings["autok3s"].(map[string]interface{})
-	if !ok {
-		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("credential settings for provider %s is not exist", id))
-	}
-	config, ok := providerConfigs["providers"].(map[string]interface{})
-	if !ok {
-		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("credential settings for provider %s is not exist", id))
-	}
-	if _, ok := config[id]; !ok {
-		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("credential settings for provider %s is not exist", id))
-	}
-	delete(config, id)
-	viper.MergeConfigMap(settings)
-	encodedConfig, _ := json.MarshalIndent(settings, "", " ")
-	err = viper.ReadConfig(bytes.NewReader(encodedConfig))
-	if err != nil {
-		return types.APIObject{}, err
+	if err := autok3sviper.DeleteProfile(providerName, profile); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, err.Error())
+	}
+	return types.APIObject{}, nil
+}
+
+// parseID returns the provider and profile of credential id, which is the provider name for the default profile,
+// and `<provider>.<profile>` for named profiles.
+func parseID(id string) (string, string) {
+	parts := strings.SplitN(id, ".", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func toID(provider, profile string) string {
+	if autok3sviper.IsDefaultProfile(profile) {
+		return provider
 	}
-	err = viper.WriteConfig()
-	return types.APIObject{}, err
+	return provider + "." + profile
 }
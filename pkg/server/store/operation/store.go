@@ -0,0 +1,80 @@
+package operation
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/types/apis"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+type Store struct {
+	empty.Store
+}
+
+func (o *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	op, err := cluster.GetOperation(id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if op == nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("operation %s is not found", id))
+	}
+	return types.APIObject{
+		Type:   schema.ID,
+		ID:     id,
+		Object: apis.Operation{Operation: *op},
+	}, nil
+}
+
+// Delete cancels the running operation, the result of operation turns into canceled once the cancellation takes effect.
+func (o *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	op, err := cluster.GetOperation(id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if op == nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("operation %s is not found", id))
+	}
+	if err := cluster.CancelOperation(id); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.Conflict, err.Error())
+	}
+	return o.ByID(apiOp, schema, id)
+}
+
+// List returns operations in the history, which can be filtered by query parameters cluster, provider, action, actor
+// and limit, e.g. /v1/operations?cluster=myk3s&action=create&limit=10
+func (o *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	result := types.APIObjectList{}
+	query := apiOp.Request.URL.Query()
+	filter := cluster.OperationFilter{
+		Cluster:  query.Get("cluster"),
+		Provider: query.Get("provider"),
+		Action:   query.Get("action"),
+		Actor:    query.Get("actor"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return result, apierror.NewAPIError(validation.InvalidOption, fmt.Sprintf("invalid limit %s", limit))
+		}
+		filter.Limit = n
+	}
+	ops, err := cluster.ListOperations(filter)
+	if err != nil {
+		return result, err
+	}
+	for _, op := range ops {
+		result.Objects = append(result.Objects, types.APIObject{
+			Type:   schema.ID,
+			ID:     op.ID,
+			Object: apis.Operation{Operation: op},
+		})
+	}
+	return result, nil
+}
@@ -51,7 +51,7 @@ func toProviderObject(provider providers.Provider, schema *types.APISchema, id s
 	}
 
 	// get credential flag and value
-	opt, err := utils.GetCredentialByProvider(provider)
+	opt, err := utils.GetCredentialByProvider(provider, "")
 	if err != nil {
 		return types.APIObject{}, err
 	}
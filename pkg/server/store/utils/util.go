@@ -1,19 +1,22 @@
 package utils
 
 import (
-	"fmt"
+	"net/http"
 	"os"
 	"reflect"
 
-	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/providers"
+	"github.com/cnrancher/autok3s/pkg/server/auth"
 	"github.com/cnrancher/autok3s/pkg/types"
+	autok3sviper "github.com/cnrancher/autok3s/pkg/viper"
 
 	"github.com/rancher/wrangler/pkg/schemas"
 	"github.com/spf13/viper"
 )
 
-func GetCredentialByProvider(p providers.Provider) (map[string]schemas.Field, error) {
+// GetCredentialByProvider returns credential fields of provider in the profile, env vars only apply to the default profile.
+func GetCredentialByProvider(p providers.Provider, profile string) (map[string]schemas.Field, error) {
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
 	}
@@ -21,10 +24,10 @@ func GetCredentialByProvider(p providers.Provider) (map[string]schemas.Field, er
 	result := make(map[string]schemas.Field, 0)
 	for _, flag := range credFlags {
 		value := ""
-		if flag.EnvVar != "" && os.Getenv(flag.EnvVar) != "" {
+		if autok3sviper.IsDefaultProfile(profile) && flag.EnvVar != "" && os.Getenv(flag.EnvVar) != "" {
 			value = os.Getenv(flag.EnvVar)
 		} else {
-			value = viper.GetString(fmt.Sprintf(common.BindPrefix, p.GetProviderName(), flag.Name))
+			value = autok3sviper.GetCredential(p.GetProviderName(), profile, flag.Name)
 		}
 		result[flag.Name] = schemas.Field{
 			Type:        "password",
@@ -54,3 +57,27 @@ func ConvertFlagsToFields(flags []types.Flag) map[string]schemas.Field {
 	}
 	return result
 }
+
+// BeginOperation records the start of operation requested by req in the history,
+// sensitive are names of extra parameters to be redacted.
+func BeginOperation(req *http.Request, action, clusterName, provider string, params interface{}, sensitive ...string) *types.Operation {
+	op := &types.Operation{
+		Action:     action,
+		Cluster:    clusterName,
+		Provider:   provider,
+		Actor:      auth.ActorFrom(req),
+		Source:     types.OperationSourceAPI,
+		RemoteAddr: req.RemoteAddr,
+	}
+	cluster.BeginOperation(op, params, sensitive...)
+	return op
+}
+
+// CredentialFlagNames returns names of credential flags of provider.
+func CredentialFlagNames(p providers.Provider) []string {
+	names := make([]string, 0)
+	for _, f := range p.GetCredentialFlags() {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cnrancher/autok3s/pkg/cluster"
+	"github.com/cnrancher/autok3s/pkg/server/auth"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+)
+
+// EventHandler streams cluster events through server-sent events,
+// events can be filtered by query parameter cluster, e.g. /v1/events?cluster=myk3s
+func EventHandler(apiOp *types.APIRequest) (types.APIObjectList, error) {
+	if err := eventHandler(apiOp); err != nil {
+		return types.APIObjectList{}, err
+	}
+	return types.APIObjectList{}, validation.ErrComplete
+}
+
+func eventHandler(apiOp *types.APIRequest) error {
+	clusterID := apiOp.Request.URL.Query().Get("cluster")
+
+	w := apiOp.Response
+	f, err := sseWriter(w, apiOp.Request)
+	if err != nil {
+		return err
+	}
+	f.Flush()
+
+	for e := range cluster.Subscribe(apiOp.Context()) {
+		if clusterID != "" && e.Cluster != clusterID && strings.Split(e.Cluster, ".")[0] != clusterID {
+			continue
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b); err != nil {
+			return nil
+		}
+		f.Flush()
+	}
+	return nil
+}
+
+// sseWriter sets headers of server-sent events to the response, cross-origin requests are only allowed
+// from the origins which are allowed to upgrade websocket.
+func sseWriter(w http.ResponseWriter, req *http.Request) (http.Flusher, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("cannot support sse")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	if origin := req.Header.Get("Origin"); origin != "" && auth.CheckOrigin(req) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	return f, nil
+}
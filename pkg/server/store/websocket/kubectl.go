@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/server/store/utils"
+	autok3stypes "github.com/cnrancher/autok3s/pkg/types"
 
 	"github.com/creack/pty"
 	"github.com/rancher/apiserver/pkg/types"
@@ -25,10 +28,14 @@ func KubeHandler(apiOp *types.APIRequest) (types.APIObject, error) {
 	return types.APIObject{}, validation.ErrComplete
 }
 
-func ptyHandler(apiOp *types.APIRequest) error {
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true
-	}
+func ptyHandler(apiOp *types.APIRequest) (err error) {
+	// the id is the kubeconfig context, which ends with the provider name, e.g. myk3s.cn-hangzhou.alibaba
+	parts := strings.Split(apiOp.Name, ".")
+	op := utils.BeginOperation(apiOp.Request, autok3stypes.OperationKubectl, apiOp.Name, parts[len(parts)-1], nil)
+	defer func() {
+		cluster.EndOperation(op, err)
+	}()
+
 	c, err := upgrader.Upgrade(apiOp.Response, apiOp.Request, nil)
 	if err != nil {
 		return err
@@ -50,7 +57,8 @@ func ptyHandler(apiOp *types.APIRequest) error {
 		case <-t.C:
 			_, err := w.Write([]byte("ping"))
 			if err != nil {
-				return err
+				// the session is closed by client.
+				return nil
 			}
 		}
 	}
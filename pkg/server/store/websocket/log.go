@@ -4,13 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/common"
+	autok3stypes "github.com/cnrancher/autok3s/pkg/types"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/hpcloud/tail"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
@@ -25,23 +25,20 @@ func LogHandler(apiOp *types.APIRequest) (types.APIObjectList, error) {
 }
 
 func logHandler(apiOp *types.APIRequest) error {
-	cluster := apiOp.Request.URL.Query().Get("cluster")
+	clusterID := apiOp.Request.URL.Query().Get("cluster")
 
 	w := apiOp.Response
-	f, ok := w.(http.Flusher)
-	if !ok {
-		return fmt.Errorf("cannot support sse")
+	f, err := sseWriter(w, apiOp.Request)
+	if err != nil {
+		return err
 	}
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// subscribe before checking the state, so that the end of process will not be missed.
+	events := cluster.Subscribe(apiOp.Context())
 
 	// check cluster is running/failed state
-	stateDir := common.GetClusterStatePath()
-	logFilePath := filepath.Join(common.GetLogPath(), cluster)
-	if !hasProcessStateFile(cluster) {
+	logFilePath := filepath.Join(common.GetLogPath(), clusterID)
+	if !hasProcessStateFile(clusterID) {
 		// show all logs from file
 		logFile, err := os.Open(logFilePath)
 		if err != nil {
@@ -57,19 +54,6 @@ func logHandler(apiOp *types.APIRequest) error {
 		return logFile.Close()
 	}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		logrus.Debugf("close watch")
-		watcher.Close()
-	}()
-	err = watcher.Add(stateDir)
-	if err != nil {
-		return err
-	}
-
 	t, err := tail.TailFile(logFilePath, tail.Config{
 		Follow:    true,
 		MustExist: true,
@@ -81,13 +65,13 @@ func logHandler(apiOp *types.APIRequest) error {
 
 	for {
 		select {
-		case event, ok := <-watcher.Events:
+		case event, ok := <-events:
 			if !ok {
 				w.Write([]byte("event: close\ndata: close\n\n"))
 				return nil
 			}
-			if event.Op == fsnotify.Remove && isProcessState(event.Name, cluster) {
-				logrus.Infof("close cluster %s logs", cluster)
+			if event.Cluster == clusterID && isProcessEnd(event) {
+				logrus.Infof("close cluster %s logs", clusterID)
 				// the tail is about to close, we need to read last bytes of file to show final log
 				offset, err := t.Tell()
 				if err != nil {
@@ -133,17 +117,19 @@ func logHandler(apiOp *types.APIRequest) error {
 	}
 }
 
-func isProcessState(name, cluster string) bool {
-	return name == filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", cluster, common.StatusCreating)) ||
-		name == filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", cluster, common.StatusJoin))
+// isProcessEnd returns whether the creating or joining process of cluster is ended by the event,
+// whose state is turned into running or failed.
+func isProcessEnd(event autok3stypes.ClusterEvent) bool {
+	return event.Type == autok3stypes.ClusterEventRemove ||
+		(event.Type == autok3stypes.ClusterEventUpdate && (event.Status == common.StatusRunning || event.Status == common.StatusFailed))
 }
 
-func hasProcessStateFile(cluster string) bool {
-	_, err := os.Stat(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", cluster, common.StatusCreating)))
+func hasProcessStateFile(clusterID string) bool {
+	_, err := os.Stat(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", clusterID, common.StatusCreating)))
 	if err == nil {
 		return true
 	}
-	_, err = os.Stat(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", cluster, common.StatusJoin)))
+	_, err = os.Stat(filepath.Join(common.GetClusterStatePath(), fmt.Sprintf("%s_%s", clusterID, common.StatusJoin)))
 	if err == nil {
 		return true
 	}
@@ -2,12 +2,13 @@ package websocket
 
 import (
 	"fmt"
-	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/cnrancher/autok3s/pkg/cluster"
 	"github.com/cnrancher/autok3s/pkg/hosts"
+	"github.com/cnrancher/autok3s/pkg/server/auth"
+	"github.com/cnrancher/autok3s/pkg/server/store/utils"
 	autok3stypes "github.com/cnrancher/autok3s/pkg/types"
 
 	"github.com/gorilla/websocket"
@@ -23,6 +24,7 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize:   10240,
 	HandshakeTimeout:  60 * time.Second,
 	EnableCompression: true,
+	CheckOrigin:       auth.CheckOrigin,
 }
 
 func Handler(apiOp *types.APIRequest) (types.APIObjectList, error) {
@@ -33,7 +35,7 @@ func Handler(apiOp *types.APIRequest) (types.APIObjectList, error) {
 	return types.APIObjectList{}, validation.ErrComplete
 }
 
-func handler(apiOp *types.APIRequest) error {
+func handler(apiOp *types.APIRequest) (err error) {
 	queryParams := apiOp.Request.URL.Query()
 	provider := queryParams.Get("provider")
 	name := queryParams.Get("cluster")
@@ -42,7 +44,6 @@ func handler(apiOp *types.APIRequest) error {
 	width := queryParams.Get("width")
 	rows := 150
 	columns := 300
-	var err error
 	if height != "" {
 		rows, err = strconv.Atoi(height)
 		if err != nil {
@@ -58,9 +59,13 @@ func handler(apiOp *types.APIRequest) error {
 	if provider == "" || name == "" || node == "" {
 		return apierror.NewAPIError(validation.InvalidOption, "provider, cluster, node can't be empty")
 	}
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true
-	}
+	op := utils.BeginOperation(apiOp.Request, autok3stypes.OperationSSH, name, provider, map[string]interface{}{
+		"node": node,
+	})
+	defer func() {
+		cluster.EndOperation(op, err)
+	}()
+
 	c, err := upgrader.Upgrade(apiOp.Response, apiOp.Request, nil)
 	if err != nil {
 		return err
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+const (
+	selfSignedCertFile = "autok3s.crt"
+	selfSignedKeyFile  = "autok3s.key"
+)
+
+// NewTLSConfig returns the tls config of serving, a self-signed certificate for host is generated and persisted
+// under common.CfgPath if the certificate is not specified.
+// client certificates are verified against the client CA bundle if specified, and optional for clients.
+func NewTLSConfig(opts Options, host string) (*tls.Config, error) {
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return nil, fmt.Errorf("tls certificate and key must be specified together")
+	}
+
+	certFile, keyFile := opts.TLSCert, opts.TLSKey
+	if certFile == "" {
+		var err error
+		certFile, keyFile, err = ensureSelfSignedCert(host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate %s and key %s: %v", certFile, keyFile, err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{keyPair},
+	}
+	if opts.TLSClientCA != "" {
+		pool, err := cert.NewPool(opts.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client CA bundle %s: %v", opts.TLSClientCA, err)
+		}
+		config.ClientCAs = pool
+		// browsers without client certificates authenticate with other authenticators.
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// ensureSelfSignedCert returns the self-signed certificate and key under common.CfgPath,
+// which are regenerated if not exist, expiring or not valid for host.
+func ensureSelfSignedCert(host string) (string, string, error) {
+	dir := filepath.Join(common.CfgPath, "tls")
+	certFile := filepath.Join(dir, selfSignedCertFile)
+	keyFile := filepath.Join(dir, selfSignedKeyFile)
+
+	if ok, err := cert.CanReadCertAndKey(certFile, keyFile); err == nil && ok {
+		certs, err := cert.CertsFromFile(certFile)
+		if err == nil && len(certs) > 0 && isValidSelfSignedCert(certs[0], host) {
+			return certFile, keyFile, nil
+		}
+	}
+
+	logrus.Infof("generating self-signed certificate for %s under %s", host, dir)
+	var alternateIPs []net.IP
+	alternateDNS := []string{"localhost"}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		alternateIPs = append(alternateIPs, net.ParseIP("127.0.0.1"))
+	}
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey(host, alternateIPs, alternateDNS)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate self-signed certificate: %v", err)
+	}
+	if err := cert.WriteCert(certFile, certPEM); err != nil {
+		return "", "", fmt.Errorf("failed to write self-signed certificate %s: %v", certFile, err)
+	}
+	if err := keyutil.WriteKey(keyFile, keyPEM); err != nil {
+		return "", "", fmt.Errorf("failed to write self-signed key %s: %v", keyFile, err)
+	}
+	return certFile, keyFile, nil
+}
+
+func isValidSelfSignedCert(c *x509.Certificate, host string) bool {
+	// regenerate the certificate a week before it expires.
+	if time.Now().Add(7 * 24 * time.Hour).After(c.NotAfter) {
+		return false
+	}
+	return c.VerifyHostname(host) == nil
+}
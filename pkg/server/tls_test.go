@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/autok3s/pkg/common"
+
+	"k8s.io/client-go/util/cert"
+)
+
+func setupCfgPath(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "autok3s-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := common.CfgPath
+	common.CfgPath = dir
+	return func() {
+		common.CfgPath = origin
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	defer setupCfgPath(t)()
+
+	certFile, keyFile, err := ensureSelfSignedCert("autok3s.example.com")
+	if err != nil {
+		t.Fatalf("ensureSelfSignedCert() error: %v", err)
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		t.Fatalf("generated certificate and key are not a pair: %v", err)
+	}
+	certs, err := cert.CertsFromFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"autok3s.example.com", "localhost", "127.0.0.1"} {
+		if err := certs[0].VerifyHostname(host); err != nil {
+			t.Errorf("generated certificate is not valid for %s: %v", host, err)
+		}
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		t.Errorf("key file is accessible by others, mode %v", info.Mode().Perm())
+	}
+
+	tests := []struct {
+		name       string
+		host       string
+		regenerate bool
+	}{
+		{name: "same host", host: "autok3s.example.com", regenerate: false},
+		{name: "another host", host: "10.0.0.1", regenerate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := ioutil.ReadFile(certFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := ensureSelfSignedCert(tt.host); err != nil {
+				t.Fatalf("ensureSelfSignedCert() error: %v", err)
+			}
+			after, err := ioutil.ReadFile(certFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if regenerated := !bytes.Equal(before, after); regenerated != tt.regenerate {
+				t.Errorf("certificate regenerated = %t, want %t", regenerated, tt.regenerate)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	defer setupCfgPath(t)()
+
+	if _, err := NewTLSConfig(Options{TLSCert: "server.crt"}, "localhost"); err == nil {
+		t.Errorf("NewTLSConfig() with certificate but no key should fail")
+	}
+
+	config, err := NewTLSConfig(Options{}, "localhost")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error: %v", err)
+	}
+	if len(config.Certificates) != 1 || config.ClientAuth != tls.NoClientCert {
+		t.Errorf("NewTLSConfig() without client CA = %d certificates, client auth %v", len(config.Certificates), config.ClientAuth)
+	}
+
+	// the self-signed certificate works as the client CA bundle as well.
+	certFile := filepath.Join(common.CfgPath, "tls", selfSignedCertFile)
+	config, err = NewTLSConfig(Options{TLSClientCA: certFile}, "localhost")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() with client CA error: %v", err)
+	}
+	if config.ClientCAs == nil || config.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("client certificates should be verified if given, got client auth %v", config.ClientAuth)
+	}
+}
@@ -17,8 +17,17 @@ type Cluster struct {
 	Options        interface{} `json:"options,omitempty"`
 }
 
+type UpgradeInput struct {
+	K3sVersion string `json:"k3s-version"`
+}
+
+type SnapshotInput struct {
+	Name string `json:"name,omitempty"`
+}
+
 type Credential struct {
 	Provider     string                   `json:"provider"`
+	Profile      string                   `json:"profile,omitempty"`
 	SecretFields map[string]schemas.Field `json:"secretFields"`
 	Secrets      map[string]string        `json:"secrets,omitempty"`
 }
@@ -32,3 +41,10 @@ type Config struct {
 
 type Logs struct {
 }
+
+type Events struct {
+}
+
+type Operation struct {
+	types.Operation `json:",inline"`
+}
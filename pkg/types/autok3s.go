@@ -14,17 +14,29 @@ type Cluster struct {
 	Logger *logrus.Logger `json:"-" yaml:"-"`
 }
 
+type ClusterSpec struct {
+	APIVersion string      `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string      `json:"kind" yaml:"kind"`
+	Metadata   Metadata    `json:"metadata" yaml:"metadata"`
+	SSH        SSH         `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	Options    interface{} `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
 type Metadata struct {
 	Name                   string `json:"name" yaml:"name"`
 	Provider               string `json:"provider" yaml:"provider"`
 	Master                 string `json:"master" yaml:"master"`
 	Worker                 string `json:"worker" yaml:"worker"`
+	Credential             string `json:"credential,omitempty" yaml:"credential,omitempty"`
 	Token                  string `json:"token,omitempty" yaml:"token,omitempty"`
 	IP                     string `json:"ip,omitempty" yaml:"ip,omitempty"`
 	ClusterCIDR            string `json:"cluster-cidr,omitempty" yaml:"cluster-cidr,omitempty"`
 	MasterExtraArgs        string `json:"master-extra-args,omitempty" yaml:"master-extra-args,omitempty"`
 	WorkerExtraArgs        string `json:"worker-extra-args,omitempty" yaml:"worker-extra-args,omitempty"`
 	Registry               string `json:"registry,omitempty" yaml:"registry,omitempty"`
+	AirGapDir              string `json:"airgap-dir,omitempty" yaml:"airgap-dir,omitempty"`
+	AdminCIDRs             string `json:"admin-cidrs,omitempty" yaml:"admin-cidrs,omitempty"`
+	FirewallRules          string `json:"firewall-rules,omitempty" yaml:"firewall-rules,omitempty"`
 	DataStore              string `json:"datastore,omitempty" yaml:"datastore,omitempty"`
 	K3sVersion             string `json:"k3s-version,omitempty" yaml:"k3s-version,omitempty"`
 	K3sChannel             string `json:"k3s-channel,omitempty" yaml:"k3s-channel,omitempty"`
@@ -42,22 +54,51 @@ type Status struct {
 	Status      string `json:"status,omitempty"`
 	MasterNodes []Node `json:"master-nodes,omitempty"`
 	WorkerNodes []Node `json:"worker-nodes,omitempty"`
+	InitMaster  string `json:"init-master,omitempty"`
 }
 
 type Node struct {
 	SSH `json:",inline"`
 
-	InstanceID        string   `json:"instance-id,omitempty" yaml:"instance-id,omitempty"`
-	InstanceStatus    string   `json:"instance-status,omitempty" yaml:"instance-status,omitempty"`
-	PublicIPAddress   []string `json:"public-ip-address,omitempty" yaml:"public-ip-address,omitempty"`
-	InternalIPAddress []string `json:"internal-ip-address,omitempty" yaml:"internal-ip-address,omitempty"`
-	EipAllocationIds  []string `json:"eip-allocation-ids,omitempty" yaml:"eip-allocation-ids,omitempty"`
-	Master            bool     `json:"master,omitempty" yaml:"master,omitempty"`
-	RollBack          bool     `json:"-" yaml:"-"`
-	Current           bool     `json:"-" yaml:"-"`
+	InstanceID        string            `json:"instance-id,omitempty" yaml:"instance-id,omitempty"`
+	InstanceStatus    string            `json:"instance-status,omitempty" yaml:"instance-status,omitempty"`
+	PublicIPAddress   []string          `json:"public-ip-address,omitempty" yaml:"public-ip-address,omitempty"`
+	InternalIPAddress []string          `json:"internal-ip-address,omitempty" yaml:"internal-ip-address,omitempty"`
+	EipAllocationIds  []string          `json:"eip-allocation-ids,omitempty" yaml:"eip-allocation-ids,omitempty"`
+	Master            bool              `json:"master,omitempty" yaml:"master,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	RollBack          bool              `json:"-" yaml:"-"`
+	Current           bool              `json:"-" yaml:"-"`
+}
+
+// ExternalIP returns the public ip of node, or the internal ip if node has no public ip,
+// which is only reachable through bastions.
+func (n Node) ExternalIP() string {
+	if len(n.PublicIPAddress) > 0 && n.PublicIPAddress[0] != "" {
+		return n.PublicIPAddress[0]
+	}
+	if len(n.InternalIPAddress) > 0 {
+		return n.InternalIPAddress[0]
+	}
+	return ""
 }
 
 type SSH struct {
+	Port             string    `json:"ssh-port,omitempty" yaml:"ssh-port,omitempty"`
+	User             string    `json:"user,omitempty" yaml:"user,omitempty"`
+	Password         string    `json:"password,omitempty" yaml:"password,omitempty"`
+	SSHKey           string    `json:"ssh-key,omitempty" yaml:"ssh-key,omitempty"`
+	SSHKeyPath       string    `json:"ssh-key-path,omitempty" yaml:"ssh-key-path,omitempty"`
+	SSHCert          string    `json:"ssh-cert,omitempty" yaml:"ssh-cert,omitempty"`
+	SSHCertPath      string    `json:"ssh-cert-path,omitempty" yaml:"ssh-cert-path,omitempty"`
+	SSHKeyPassphrase string    `json:"ssh-key-passphrase,omitempty" yaml:"ssh-key-passphrase,omitempty"`
+	SSHAgentAuth     bool      `json:"ssh-agent-auth,omitempty" yaml:"ssh-agent-auth,omitempty" `
+	Bastions         []Bastion `json:"bastions,omitempty" yaml:"bastions,omitempty"`
+}
+
+// Bastion is the jump host to connect nodes, nodes are connected through all bastions in order.
+type Bastion struct {
+	Host             string `json:"host" yaml:"host"`
 	Port             string `json:"ssh-port,omitempty" yaml:"ssh-port,omitempty"`
 	User             string `json:"user,omitempty" yaml:"user,omitempty"`
 	Password         string `json:"password,omitempty" yaml:"password,omitempty"`
@@ -66,7 +107,7 @@ type SSH struct {
 	SSHCert          string `json:"ssh-cert,omitempty" yaml:"ssh-cert,omitempty"`
 	SSHCertPath      string `json:"ssh-cert-path,omitempty" yaml:"ssh-cert-path,omitempty"`
 	SSHKeyPassphrase string `json:"ssh-key-passphrase,omitempty" yaml:"ssh-key-passphrase,omitempty"`
-	SSHAgentAuth     bool   `json:"ssh-agent-auth,omitempty" yaml:"ssh-agent-auth,omitempty" `
+	SSHAgentAuth     bool   `json:"ssh-agent-auth,omitempty" yaml:"ssh-agent-auth,omitempty"`
 }
 
 type Flag struct {
@@ -79,12 +120,139 @@ type Flag struct {
 	EnvVar    string
 }
 
+const (
+	ClusterSpecAPIVersion = "autok3s.cattle.io/v1alpha1"
+	ClusterSpecKind       = "Cluster"
+)
+
 const (
 	ClusterStatusRunning = "Running"
 	ClusterStatusStopped = "Stopped"
 	ClusterStatusUnknown = "Unknown"
 )
 
+type Snapshot struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Created string `json:"created"`
+}
+
+type NodeCheck struct {
+	InstanceID     string   `json:"instance-id"`
+	Master         bool     `json:"master"`
+	InstanceStatus string   `json:"instance-status"`
+	NodeStatus     string   `json:"node-status"`
+	ServiceStatus  string   `json:"service-status"`
+	TokenMatched   bool     `json:"token-matched"`
+	Problems       []string `json:"problems,omitempty"`
+}
+
+// NodePreflight is the pre-flight check results of node before installing k3s.
+type NodePreflight struct {
+	InstanceID string           `json:"instance-id"`
+	Master     bool             `json:"master"`
+	Checks     []PreflightCheck `json:"checks"`
+}
+
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// results of pre-flight checks.
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+// Operation is the record of an operation on cluster in the history.
+type Operation struct {
+	ID         string                 `json:"id" yaml:"id"`
+	Action     string                 `json:"action" yaml:"action"`
+	Cluster    string                 `json:"cluster" yaml:"cluster"`
+	Provider   string                 `json:"provider" yaml:"provider"`
+	Actor      string                 `json:"actor" yaml:"actor"`
+	Source     string                 `json:"source" yaml:"source"`
+	RemoteAddr string                 `json:"remote-addr,omitempty" yaml:"remote-addr,omitempty"`
+	StartTime  string                 `json:"start-time" yaml:"start-time"`
+	EndTime    string                 `json:"end-time,omitempty" yaml:"end-time,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Phase      string                 `json:"phase,omitempty" yaml:"phase,omitempty"`
+	Nodes      []OperationNode        `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Result     string                 `json:"result" yaml:"result"`
+	Error      string                 `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// OperationNode is the progress of operation on a node.
+type OperationNode struct {
+	Name       string `json:"name" yaml:"name"`
+	InstanceID string `json:"instance-id,omitempty" yaml:"instance-id,omitempty"`
+	Master     bool   `json:"master,omitempty" yaml:"master,omitempty"`
+	Result     string `json:"result" yaml:"result"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+const (
+	OperationCreate  = "create"
+	OperationJoin    = "join"
+	OperationDelete  = "delete"
+	OperationUpgrade = "upgrade"
+	OperationSSH     = "ssh"
+	OperationKubectl = "kubectl"
+)
+
+const (
+	OperationSourceCLI = "cli"
+	OperationSourceAPI = "api"
+)
+
+// results of operations and their nodes.
+const (
+	OperationPending   = "Pending"
+	OperationRunning   = "Running"
+	OperationSucceeded = "Succeeded"
+	OperationFailed    = "Failed"
+	OperationCanceled  = "Canceled"
+)
+
+// phases of operations.
+const (
+	PhaseProvisioning = "Provisioning"
+	PhasePreflight    = "Preflight"
+	PhaseInstalling   = "Installing"
+	PhaseDeploying    = "Deploying"
+	PhaseUpgrading    = "Upgrading"
+	PhaseDeleting     = "Deleting"
+)
+
+// ClusterEvent is published when state of cluster or progress of operation on it is changed.
+type ClusterEvent struct {
+	Type     string `json:"type"`
+	Cluster  string `json:"cluster"`
+	Provider string `json:"provider"`
+	// Status is the status of cluster state, which is set by state changed events.
+	Status string `json:"status,omitempty"`
+	// Operation, Action, Phase, Result and Error are set by progress events of operation.
+	Operation string `json:"operation,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Node is the node whose progress is changed.
+	Node *OperationNode `json:"node,omitempty"`
+	Time string         `json:"time"`
+}
+
+// types of cluster events.
+const (
+	ClusterEventCreate   = "create"
+	ClusterEventUpdate   = "update"
+	ClusterEventRemove   = "remove"
+	ClusterEventProgress = "progress"
+)
+
 type ClusterInfo struct {
 	Name     string        `json:"name,omitempty"`
 	Region   string        `json:"region,omitempty"`
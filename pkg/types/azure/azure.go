@@ -0,0 +1,21 @@
+package azure
+
+var (
+	StatusRunning     = "running"
+	StatusDeallocated = "deallocated"
+)
+
+type Options struct {
+	SubscriptionID string `json:"subscription-id,omitempty" yaml:"subscription-id,omitempty"`
+	TenantID       string `json:"tenant-id,omitempty" yaml:"tenant-id,omitempty"`
+	ClientID       string `json:"client-id,omitempty" yaml:"client-id,omitempty"`
+	ClientSecret   string `json:"client-secret,omitempty" yaml:"client-secret,omitempty"`
+	Location       string `json:"location,omitempty" yaml:"location,omitempty"`
+	ResourceGroup  string `json:"resource-group,omitempty" yaml:"resource-group,omitempty"`
+	VMSize         string `json:"vm-size,omitempty" yaml:"vm-size,omitempty"`
+	Image          string `json:"image,omitempty" yaml:"image,omitempty"`
+	DiskSize       string `json:"disk-size,omitempty" yaml:"disk-size,omitempty"`
+	VirtualNetwork string `json:"virtual-network,omitempty" yaml:"virtual-network,omitempty"`
+	Subnet         string `json:"subnet,omitempty" yaml:"subnet,omitempty"`
+	SecurityGroup  string `json:"security-group,omitempty" yaml:"security-group,omitempty"`
+}
@@ -0,0 +1,14 @@
+package digitalocean
+
+var (
+	StatusActive = "active"
+	StatusOff    = "off"
+)
+
+type Options struct {
+	AccessToken string `json:"access-token,omitempty" yaml:"access-token,omitempty"`
+	Region      string `json:"region,omitempty" yaml:"region,omitempty"`
+	Size        string `json:"size,omitempty" yaml:"size,omitempty"`
+	Image       string `json:"image,omitempty" yaml:"image,omitempty"`
+	VpcUUID     string `json:"vpc-uuid,omitempty" yaml:"vpc-uuid,omitempty"`
+}
@@ -0,0 +1,15 @@
+package docker
+
+var (
+	StatusCreated = "created"
+	StatusRunning = "running"
+	StatusExited  = "exited"
+	// StatusRemoved is the status of node whose container is removed outside of autok3s.
+	StatusRemoved = "removed"
+)
+
+type Options struct {
+	DockerHost string `json:"docker-host,omitempty" yaml:"docker-host,omitempty"`
+	Image      string `json:"image,omitempty" yaml:"image,omitempty"`
+	APIPort    string `json:"api-port,omitempty" yaml:"api-port,omitempty"`
+}
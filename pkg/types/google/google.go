@@ -0,0 +1,18 @@
+package google
+
+var (
+	StatusRunning    = "RUNNING"
+	StatusTerminated = "TERMINATED"
+)
+
+type Options struct {
+	ServiceAccount string `json:"service-account,omitempty" yaml:"service-account,omitempty"`
+	Project        string `json:"project,omitempty" yaml:"project,omitempty"`
+	Region         string `json:"region,omitempty" yaml:"region,omitempty"`
+	Zone           string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	MachineType    string `json:"machine-type,omitempty" yaml:"machine-type,omitempty"`
+	Image          string `json:"image,omitempty" yaml:"image,omitempty"`
+	DiskSize       string `json:"disk-size,omitempty" yaml:"disk-size,omitempty"`
+	VPC            string `json:"vpc,omitempty" yaml:"vpc,omitempty"`
+	Subnetwork     string `json:"subnetwork,omitempty" yaml:"subnetwork,omitempty"`
+}
@@ -0,0 +1,15 @@
+package hetzner
+
+var (
+	StatusRunning = "running"
+	StatusOff     = "off"
+)
+
+type Options struct {
+	APIToken       string `json:"api-token,omitempty" yaml:"api-token,omitempty"`
+	Location       string `json:"location,omitempty" yaml:"location,omitempty"`
+	ServerType     string `json:"server-type,omitempty" yaml:"server-type,omitempty"`
+	Image          string `json:"image,omitempty" yaml:"image,omitempty"`
+	PrivateNetwork string `json:"private-network,omitempty" yaml:"private-network,omitempty"`
+	Firewall       string `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+}
@@ -0,0 +1,15 @@
+package libvirt
+
+var (
+	StatusRunning = "running"
+	StatusStopped = "shut off"
+)
+
+type Options struct {
+	URI         string `json:"uri,omitempty" yaml:"uri,omitempty"`
+	Image       string `json:"image,omitempty" yaml:"image,omitempty"`
+	CPU         string `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory      string `json:"memory,omitempty" yaml:"memory,omitempty"`
+	DiskSize    string `json:"disk-size,omitempty" yaml:"disk-size,omitempty"`
+	NetworkName string `json:"network-name,omitempty" yaml:"network-name,omitempty"`
+}
@@ -1,8 +1,31 @@
 package native
 
+import "github.com/cnrancher/autok3s/pkg/types"
+
 var StatusRunning = "Running"
 
 type Options struct {
 	MasterIps string `json:"master-ips,omitempty" yaml:"master-ips,omitempty"`
 	WorkerIps string `json:"worker-ips,omitempty" yaml:"worker-ips,omitempty"`
+	Inventory string `json:"inventory,omitempty" yaml:"inventory,omitempty"`
+}
+
+// Inventory describes the hosts of native cluster, vars are inherited by groups and hosts.
+type Inventory struct {
+	Vars    Host  `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Masters Group `json:"masters,omitempty" yaml:"masters,omitempty"`
+	Workers Group `json:"workers,omitempty" yaml:"workers,omitempty"`
+}
+
+// Group is a set of hosts keyed by public ip, vars are inherited by hosts.
+type Group struct {
+	Vars  Host            `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Hosts map[string]Host `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+}
+
+type Host struct {
+	types.SSH `json:",inline"`
+
+	InternalIP string            `json:"internal-ip,omitempty" yaml:"internal-ip,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 }
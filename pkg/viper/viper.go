@@ -1,14 +1,172 @@
 package viper
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/cnrancher/autok3s/pkg/common"
+	"github.com/cnrancher/autok3s/pkg/secrets"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
+var profileNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// GetString returns the decrypted credential of provider in the default profile.
 func GetString(p, f string) string {
-	return viper.GetString(fmt.Sprintf(common.BindPrefix, strings.ToLower(p), strings.ToLower(f)))
+	return GetCredential(p, "", f)
+}
+
+// GetCredential returns the decrypted credential of provider in the profile,
+// the default profile is used if profile is empty.
+func GetCredential(p, profile, f string) string {
+	v, err := secrets.Decrypt(viper.GetString(CredentialKey(p, profile, f)))
+	if err != nil {
+		logrus.Errorf("failed to decrypt %s of provider %s: %v", f, p, err)
+		return ""
+	}
+	return v
+}
+
+// CredentialKey returns the config key of provider credential in the profile,
+// credentials of the default profile are kept in the original place for compatibility.
+func CredentialKey(p, profile, f string) string {
+	if IsDefaultProfile(profile) {
+		return fmt.Sprintf(common.BindPrefix, strings.ToLower(p), strings.ToLower(f))
+	}
+	return fmt.Sprintf(common.ProfileBindPrefix, strings.ToLower(p), strings.ToLower(profile), strings.ToLower(f))
+}
+
+// IsDefaultProfile returns whether profile is the default one.
+func IsDefaultProfile(profile string) bool {
+	return profile == "" || profile == common.DefaultProfile
+}
+
+// ValidateProfileName checks whether name can be used as the credential profile name.
+func ValidateProfileName(name string) error {
+	if IsDefaultProfile(name) {
+		return nil
+	}
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid credential profile name %s, must consist of lower case alphanumeric characters or '-'", name)
+	}
+	return nil
+}
+
+// ListProfiles returns the sorted names of named credential profiles of provider, the default profile is not included.
+func ListProfiles(p string) []string {
+	profiles := viper.GetStringMap(fmt.Sprintf(common.ProfilePrefix, strings.ToLower(p)))
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileExists returns whether any of credential fields is set in the profile of provider.
+func ProfileExists(p, profile string, fields []string) bool {
+	for _, f := range fields {
+		if viper.IsSet(CredentialKey(p, profile, f)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteProfile removes the credential profile of provider and writes config file.
+func DeleteProfile(p, profile string) error {
+	settings := viper.AllSettings()
+	var keys []string
+	if IsDefaultProfile(profile) {
+		keys = []string{"autok3s", "providers", strings.ToLower(p)}
+	} else {
+		keys = []string{"autok3s", "profiles", strings.ToLower(p), strings.ToLower(profile)}
+	}
+
+	m := settings
+	for i, k := range keys {
+		if i == len(keys)-1 {
+			if _, ok := m[k]; !ok {
+				return fmt.Errorf("credential profile %s of provider %s is not exist", profileName(profile), p)
+			}
+			delete(m, k)
+			break
+		}
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("credential profile %s of provider %s is not exist", profileName(profile), p)
+		}
+		m = next
+	}
+
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	prefix := strings.Join(keys, ".") + "."
+	deleted := make([]string, 0)
+	for _, k := range viper.AllKeys() {
+		if strings.HasPrefix(k, prefix) {
+			deleted = append(deleted, k)
+		}
+	}
+	if err := viper.ReadConfig(bytes.NewReader(b)); err != nil {
+		return err
+	}
+	// values set by WriteConfig override the config, which are cleared as well.
+	for _, k := range deleted {
+		viper.Set(k, nil)
+	}
+	return WriteConfig()
+}
+
+// WriteConfig encrypts credentials of providers and writes config file.
+func WriteConfig() error {
+	return writeConfig(secrets.Encrypt)
+}
+
+// ReencryptConfig encrypts credentials of providers with the current master key and writes config file.
+func ReencryptConfig() error {
+	return writeConfig(func(s string) (string, error) {
+		plain, err := secrets.Decrypt(s)
+		if err != nil {
+			return "", err
+		}
+		return secrets.Encrypt(plain)
+	})
+}
+
+func writeConfig(encrypt func(string) (string, error)) error {
+	prefixes := []string{
+		strings.SplitN(common.BindPrefix, "%s", 2)[0],
+		strings.SplitN(common.ProfileBindPrefix, "%s", 2)[0],
+	}
+	for _, key := range viper.AllKeys() {
+		if !strings.HasPrefix(key, prefixes[0]) && !strings.HasPrefix(key, prefixes[1]) {
+			continue
+		}
+		v, ok := viper.Get(key).(string)
+		if !ok {
+			continue
+		}
+		encrypted, err := encrypt(v)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %v", key, err)
+		}
+		viper.Set(key, encrypted)
+	}
+	return viper.WriteConfig()
+}
+
+func profileName(profile string) string {
+	if profile == "" {
+		return common.DefaultProfile
+	}
+	return profile
 }